@@ -0,0 +1,179 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/rs/zerolog/log"
+
+	"github.com/vot3k/agent-handoff/agent-manager/internal/config"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/importer"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/logging"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/repository"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/service"
+	"github.com/vot3k/agent-handoff/handoff"
+)
+
+func main() {
+	dir := flag.String("dir", "", "Directory of legacy handoff files (.json, .yaml, .md), searched recursively")
+	modeFlag := flag.String("mode", string(importer.ModeHistory), "history to keep handoffs as completed records, enqueue to queue them again")
+	dryRun := flag.Bool("dry-run", false, "Validate and migrate every file and print the report, but store nothing")
+	project := flag.String("project", "", "Project for handoffs whose project_name cannot be derived")
+	formatFlag := flag.String("format", "", "Print the report as json or yaml instead of text")
+	flag.Parse()
+
+	if *dir == "" {
+		fmt.Fprintf(os.Stderr, "Usage: %s -dir <path> [-mode history|enqueue] [-dry-run] [-project name] [-format json|yaml]\n", os.Args[0])
+		os.Exit(2)
+	}
+
+	// Logs go to stderr; the report is the only output on stdout
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+	if err := logging.Setup(cfg.Logging, "agent-manager-importer"); err != nil {
+		log.Fatal().Err(err).Msg("Invalid logging configuration")
+	}
+
+	mode, err := importer.ParseMode(*modeFlag)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid -mode")
+	}
+	var reportFormat handoff.Format
+	if *formatFlag != "" {
+		if reportFormat, err = handoff.ParseFormat(*formatFlag); err != nil {
+			log.Fatal().Err(err).Msg("Invalid -format")
+		}
+	}
+
+	redisClient, err := repository.NewRedisClient(cfg.Redis)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to initialize Redis client")
+	}
+	defer redisClient.Close()
+
+	handoffService := service.NewHandoffService(repository.NewHandoffRepository(redisClient), cfg)
+	if err := configureService(handoffService, cfg, redisClient); err != nil {
+		log.Fatal().Err(err).Msg("Failed to configure the handoff service")
+	}
+
+	report, err := importer.Run(context.Background(), handoffService, *dir, importer.Options{
+		Mode:    mode,
+		DryRun:  *dryRun,
+		Project: *project,
+	})
+	if err != nil {
+		log.Fatal().Err(err).Str("dir", *dir).Msg("Import failed")
+	}
+
+	if reportFormat != "" {
+		data, err := handoff.Marshal(report, reportFormat)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to encode report")
+		}
+		os.Stdout.Write(data)
+		if reportFormat == handoff.FormatJSON {
+			fmt.Println()
+		}
+	} else {
+		printReport(report)
+	}
+
+	if report.Failed() {
+		os.Exit(1)
+	}
+}
+
+// configureService applies the server's validation policy, signing keys and
+// blob store, so imported handoffs are checked and stored as created ones are
+func configureService(s *service.HandoffService, cfg *config.Config, redisClient *repository.RedisClient) error {
+	if cfg.Validation.PolicyFile != "" {
+		policy, err := handoff.LoadValidationPolicy(cfg.Validation.PolicyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load validation policy: %w", err)
+		}
+		validator, err := handoff.NewHandoffValidatorWithPolicy(policy)
+		if err != nil {
+			return fmt.Errorf("invalid validation policy: %w", err)
+		}
+		s.SetValidator(validator)
+	}
+
+	signaturePolicy, err := handoff.ParseSignaturePolicy(cfg.Signing.Policy)
+	if err != nil {
+		return fmt.Errorf("invalid signing configuration: %w", err)
+	}
+	if cfg.Signing.KeysFile != "" {
+		keys, err := handoff.LoadKeyRegistry(cfg.Signing.KeysFile)
+		if err != nil {
+			return fmt.Errorf("failed to load signing keys: %w", err)
+		}
+		s.SetSigning(keys, signaturePolicy)
+	} else if signaturePolicy.Enforced() {
+		return fmt.Errorf("SIGNATURE_POLICY %s requires SIGNING_KEYS_FILE", signaturePolicy)
+	}
+
+	switch cfg.Payload.BlobStore {
+	case "redis":
+		s.SetBlobStore(redisClient.BlobStore())
+	case "file":
+		store, err := handoff.NewFileBlobStore(cfg.Payload.BlobDir)
+		if err != nil {
+			return fmt.Errorf("failed to create blob store: %w", err)
+		}
+		// The importer exits after one run, so it prunes once rather than periodically
+		if removed, err := store.Prune(handoff.BlobRetention); err != nil {
+			log.Warn().Err(err).Str("path", cfg.Payload.BlobDir).Msg("Failed to prune blobs")
+		} else if removed > 0 {
+			log.Info().Int("removed", removed).Str("path", cfg.Payload.BlobDir).Msg("Pruned expired blobs")
+		}
+		s.SetBlobStore(store)
+	}
+	return nil
+}
+
+func printReport(report *importer.Report) {
+	action := "Importing"
+	if report.DryRun {
+		action = "Dry run of importing"
+	}
+	fmt.Printf("%s %s as %s\n\n", action, report.Root, report.Mode)
+
+	for _, file := range report.Files {
+		fmt.Printf("%-12s %s\n", file.Outcome, file.Path)
+		if file.HandoffID != "" {
+			fmt.Printf("             %s: %s -> %s\n", file.HandoffID, file.FromAgent, file.ToAgent)
+		}
+		for _, note := range file.Notes {
+			fmt.Printf("             note: %s\n", note)
+		}
+		if len(file.Violations) > 0 {
+			for _, violation := range file.Violations {
+				fmt.Printf("             %s\n", violation)
+			}
+		} else if file.Error != "" {
+			fmt.Printf("             error: %s\n", file.Error)
+		}
+	}
+
+	var totals []string
+	for _, outcome := range []importer.Outcome{
+		importer.OutcomeImported, importer.OutcomeWouldImport, importer.OutcomeExists,
+		importer.OutcomeInvalid, importer.OutcomeFailed,
+	} {
+		if n := report.Totals[string(outcome)]; n > 0 {
+			totals = append(totals, fmt.Sprintf("%d %s", n, outcome))
+		}
+	}
+	if report.Skipped > 0 {
+		totals = append(totals, fmt.Sprintf("%d skipped", report.Skipped))
+	}
+	if len(totals) == 0 {
+		totals = append(totals, "no handoff files found")
+	}
+	fmt.Printf("\n%s\n", strings.Join(totals, ", "))
+}
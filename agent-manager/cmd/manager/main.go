@@ -6,15 +6,21 @@ import (
 	"flag"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
 
+	"github.com/vot3k/agent-handoff/agent-manager/internal/config"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/executor"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/logging"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/models"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/repository"
+	"github.com/vot3k/agent-handoff/handoff"
 )
 
 // HandoffPayload represents the structure of messages from the queue.
@@ -28,6 +34,7 @@ type HandoffPayload struct {
 		TaskContext string    `json:"task_context"`
 		Priority    string    `json:"priority"`
 		HandoffID   string    `json:"handoff_id"`
+		ParentID    string    `json:"parent_id,omitempty"`
 	} `json:"metadata"`
 	Content struct {
 		Summary          string                 `json:"summary"`
@@ -36,9 +43,10 @@ type HandoffPayload struct {
 		TechnicalDetails map[string]interface{} `json:"technical_details"`
 		NextSteps        []string               `json:"next_steps"`
 	} `json:"content"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Status    string                `json:"status"`
+	CreatedAt time.Time             `json:"created_at"`
+	UpdatedAt time.Time             `json:"updated_at"`
+	Trace     *handoff.TraceContext `json:"trace,omitempty"`
 }
 
 func main() {
@@ -50,17 +58,41 @@ func main() {
 	payloadFile := flag.String("payload-file", "", "Payload JSON file")
 	payloadStdin := flag.Bool("payload-stdin", false, "Read payload from stdin")
 	projectName := flag.String("project", "", "Project name")
+	formatFlag := flag.String("format", "json", "Payload format in executor mode and archive format in dispatcher mode: json|yaml")
 	flag.Parse()
 
+	// LOG_LEVEL and LOG_FORMAT apply to both modes
+	logConfig, err := config.LoggingFromEnv()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid logging configuration")
+	}
+	if err := logging.Setup(logConfig, "agent-manager-"+*mode); err != nil {
+		log.Fatal().Err(err).Msg("Invalid logging configuration")
+	}
+
+	format, err := handoff.ParseFormat(*formatFlag)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid --format")
+	}
+
+	// Spans of each dispatch and execution continue the handoff's trace
+	tracingConfig, err := config.TracingFromEnv()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid tracing configuration")
+	}
+	if tracer, err = handoff.NewTracer("agent-manager-"+*mode, tracingConfig); err != nil {
+		log.Fatal().Err(err).Msg("Invalid tracing configuration")
+	}
+
 	// Handle different execution modes
 	switch *mode {
 	case "executor":
-		runAgentExecutor(*agentName, *projectName, *payloadFile, *payloadStdin)
+		runAgentExecutor(*agentName, *projectName, *payloadFile, *payloadStdin, format)
 		return
 	case "dispatcher":
 		// Continue with dispatcher mode below
 	default:
-		log.Fatalf("Unknown mode: %s. Use dispatcher or executor", *mode)
+		log.Fatal().Str("mode", *mode).Msg("Unknown mode. Use dispatcher or executor")
 	}
 
 	// Dispatcher mode setup
@@ -72,19 +104,82 @@ func main() {
 	// Initialize agent executor - only built-in executor now
 	agentExecutor, err := executor.NewAgentExecutor(executor.ModeExecutor)
 	if err != nil {
-		log.Fatalf("❌ Failed to initialize built-in executor: %v", err)
+		log.Fatal().Err(err).Msg("Failed to initialize built-in executor")
 	}
-	log.Printf("✅ Using built-in agent executor with tool-agnostic execution")
+	agentExecutor.SetTracer(tracer)
 
-	rdb := redis.NewClient(&redis.Options{Addr: redisAddr})
+	// Signing keys used to verify that handoffs come from the agent they claim
+	signaturePolicy, err := handoff.ParseSignaturePolicy(os.Getenv("SIGNATURE_POLICY"))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid SIGNATURE_POLICY")
+	}
+	var signingKeys *handoff.KeyRegistry
+	if keysFile := os.Getenv("SIGNING_KEYS_FILE"); keysFile != "" {
+		if signingKeys, err = handoff.LoadKeyRegistry(keysFile); err != nil {
+			log.Fatal().Err(err).Msg("Failed to load signing keys")
+		}
+		log.Info().Strs("agents", signingKeys.Agents()).Str("policy", string(signaturePolicy)).Msg("Signing keys loaded")
+	} else if signaturePolicy.Enforced() {
+		log.Fatal().Str("policy", string(signaturePolicy)).Msg("SIGNATURE_POLICY requires SIGNING_KEYS_FILE")
+	}
+	checksumPolicy, err := handoff.ParseChecksumPolicy(os.Getenv("CHECKSUM_POLICY"))
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid CHECKSUM_POLICY")
+	}
 
-	// Test Redis connection
-	if err := rdb.Ping(ctx).Err(); err != nil {
-		log.Fatalf("Failed to connect to Redis at %s: %v", redisAddr, err)
+	metricsRetention := handoff.DefaultMetricsRetention
+	if value := os.Getenv("METRICS_RETENTION"); value != "" {
+		if metricsRetention, err = time.ParseDuration(value); err != nil || metricsRetention <= 0 {
+			log.Fatal().Str("value", value).Msg("Invalid METRICS_RETENTION")
+		}
+		metricsRecorder = handoff.NewMetricsRecorder(metricsRetention)
+	}
+	if value := os.Getenv("SLA_TARGETS"); value != "" {
+		policy, err := handoff.ParseSLAPolicy(value)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid SLA_TARGETS")
+		}
+		slaPolicy = policy
 	}
 
-	log.Printf("Agent Manager service started. Listening for tasks...")
-	log.Printf("Redis address: %s", redisAddr)
+	// Status changes of refused handoffs go through the repository, as in the
+	// HTTP server, so fan-out parents and metrics see them
+	redisClient, err := repository.NewRedisClient(config.RedisConfig{Address: redisAddr})
+	if err != nil {
+		log.Fatal().Err(err).Str("redis_addr", redisAddr).Msg("Failed to connect to Redis")
+	}
+	redisClient.SetMetricsRetention(metricsRetention)
+	handoffs := repository.NewHandoffRepository(redisClient)
+	rdb := redisClient.Client()
+
+	// Offloaded technical_details fields are loaded back before dispatch
+	var blobs handoff.BlobStore
+	switch blobStore := os.Getenv("BLOB_STORE"); blobStore {
+	case "":
+	case "redis":
+		blobs = redisClient.BlobStore()
+	case "file":
+		store, err := handoff.NewFileBlobStore(os.Getenv("BLOB_DIR"))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to open blob store")
+		}
+		// Expire file blobs as the Redis store does
+		go store.RunPrune(ctx, handoff.BlobPruneInterval)
+		blobs = store
+	default:
+		log.Fatal().Str("blob_store", blobStore).Msg("Unknown BLOB_STORE (expected redis or file)")
+	}
+
+	// Heartbeats report this dispatcher as a live consumer of every agent it
+	// has found a queue for
+	heartbeatConfig, err := config.HeartbeatFromEnv()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid heartbeat configuration")
+	}
+	heartbeater := handoff.NewHeartbeater(rdb, heartbeatConfig)
+	go heartbeater.Run(ctx)
+
+	log.Info().Str("redis_addr", redisAddr).Str("instance_id", heartbeater.InstanceID()).Msg("Agent Manager service started. Listening for tasks")
 
 	for {
 		// Scan for all project-specific queues using SCAN for better performance
@@ -97,7 +192,7 @@ func main() {
 			var err error
 			keys, cursor, err = rdb.Scan(ctx, cursor, queuePattern, 10).Result()
 			if err != nil {
-				log.Printf("Error scanning for queues with pattern %s: %v", queuePattern, err)
+				log.Error().Err(err).Str("pattern", queuePattern).Msg("Failed to scan for queues")
 				time.Sleep(5 * time.Second) // Wait before retrying scan
 				break
 			}
@@ -111,6 +206,7 @@ func main() {
 			time.Sleep(2 * time.Second) // No active queues, wait a bit
 			continue
 		}
+		trackAgents(ctx, rdb, heartbeater, queues)
 
 		// Check each queue for messages
 		for _, queueName := range queues {
@@ -118,7 +214,7 @@ func main() {
 			result, err := rdb.ZPopMin(ctx, queueName, 1).Result()
 			if err != nil {
 				if err != redis.Nil {
-					log.Printf("Error checking queue %s: %v", queueName, err)
+					log.Error().Err(err).Str("queue", queueName).Msg("Failed to check queue")
 				}
 				continue
 			}
@@ -128,30 +224,61 @@ func main() {
 			}
 
 			// result[0].Member contains the handoff ID
+			dequeuedAt := time.Now()
 			handoffID := result[0].Member.(string)
-			log.Printf("Received task from queue: %s, handoff ID: %s", queueName, handoffID)
+			checkSLA(rdb, queueName, handoffID)
 
 			// Extract project and agent name from queue name
 			projectName, agentName := extractProjectAndAgentName(queueName)
 			if agentName == "" || projectName == "" {
-				log.Printf("Could not extract project/agent name from queue: %s", queueName)
+				log.Warn().Str("queue", queueName).Str(logging.FieldHandoffID, handoffID).Msg("Could not extract project/agent name from queue")
 				continue
 			}
+			handoffCtx := logging.WithHandoff(ctx, handoffID, projectName, agentName)
+			logger := logging.Ctx(handoffCtx)
+			logger.Info().Str("queue", queueName).Msg("Received task")
 
 			// Retrieve the full handoff data from Redis
 			handoffKey := fmt.Sprintf("handoff:%s", handoffID)
 			taskPayload, err := rdb.Get(ctx, handoffKey).Result()
 			if err != nil {
 				if err == redis.Nil {
-					log.Printf("Handoff data not found for ID: %s", handoffID)
+					logger.Warn().Msg("Handoff data not found")
 				} else {
-					log.Printf("Error retrieving handoff %s: %v", handoffID, err)
+					logger.Error().Err(err).Msg("Failed to retrieve handoff")
 				}
 				continue
 			}
 
+			// Refuse payloads that were modified or corrupted after they were sealed
+			if err := handoff.VerifyPayloadChecksum([]byte(taskPayload)); checksumPolicy.Tolerates(err) {
+				logger.Warn().Err(err).Msg("Processing handoff stored before canonical checksums")
+			} else if err != nil {
+				logger.Warn().Err(err).Msg("Quarantining handoff that failed its integrity check")
+				refuseHandoff(handoffCtx, handoffs, handoffID, taskPayload, models.StatusQuarantined, err)
+				continue
+			}
+
+			// Refuse handoffs not signed by the agent they claim to come from
+			if signaturePolicy.Enforced() {
+				if err := signingKeys.VerifyPayload([]byte(taskPayload)); err != nil {
+					if signaturePolicy == handoff.SignaturePolicyQuarantine {
+						logger.Warn().Err(err).Msg("Quarantining handoff that failed its signature check")
+						refuseHandoff(handoffCtx, handoffs, handoffID, taskPayload, models.StatusQuarantined, err)
+					} else {
+						logger.Warn().Err(err).Msg("Rejecting handoff that failed its signature check")
+						refuseHandoff(handoffCtx, handoffs, handoffID, taskPayload, models.StatusFailed, err)
+					}
+					continue
+				}
+			}
+
 			// Dispatch the task in a new goroutine using built-in executor
-			go dispatchWithBuiltInExecutor(projectName, agentName, taskPayload, agentExecutor)
+			dispatchStarted(agentName)
+			go func() {
+				defer dispatchFinished(agentName)
+				dispatchWithBuiltInExecutor(rdb, blobs, projectName, agentName, taskPayload, agentExecutor, format, dequeuedAt)
+			}()
 		}
 
 		// Small delay to prevent busy-waiting if all queues were empty
@@ -159,6 +286,31 @@ func main() {
 	}
 }
 
+// refuseHandoff takes a handoff that failed its integrity or signature check out
+// of circulation as the HTTP server does: it is marked quarantined (and kept
+// for inspection) or failed, and its fan-out parent records the final status.
+// The status change is counted as a handoff event.
+func refuseHandoff(ctx context.Context, handoffs *repository.HandoffRepository, handoffID, payload string, status models.HandoffStatus, reason error) {
+	logger := logging.Ctx(ctx)
+	var err error
+	if status == models.StatusQuarantined {
+		err = handoffs.Quarantine(ctx, handoffID, reason.Error())
+	} else {
+		err = handoffs.UpdateStatus(ctx, handoffID, status)
+	}
+	if err != nil {
+		logger.Error().Err(err).Str("status", string(status)).Msg("Failed to update refused handoff")
+	}
+
+	var stored HandoffPayload
+	if json.Unmarshal([]byte(payload), &stored) != nil || stored.Metadata.ParentID == "" {
+		return
+	}
+	if err := handoffs.RecordFanOutChild(ctx, stored.Metadata.ParentID, handoffID, status); err != nil {
+		logger.Error().Err(err).Str("parent_id", stored.Metadata.ParentID).Msg("Failed to update fan-out parent")
+	}
+}
+
 // extractProjectAndAgentName extracts the project and agent name from a queue name
 func extractProjectAndAgentName(queueName string) (string, string) {
 	// Expected format: "handoff:project:{projectName}:queue:{agentName}"
@@ -169,8 +321,150 @@ func extractProjectAndAgentName(queueName string) (string, string) {
 	return "", ""
 }
 
-// archiveHandoff saves the successful handoff payload to the file system.
-func archiveHandoff(payload string, handoffData *HandoffPayload, handoffID string) error {
+// trackAgents starts sending heartbeats for the agents of queues not seen
+// before and records them as agents that should have a live consumer
+func trackAgents(ctx context.Context, rdb *redis.Client, heartbeater *handoff.Heartbeater, queues []string) {
+	tracked := false
+	for _, queueName := range queues {
+		_, agentName := extractProjectAndAgentName(queueName)
+		if agentName == "" || heartbeater.Tracks(agentName) {
+			continue
+		}
+		heartbeater.Track(agentName, 0, func() int { return dispatchLoad(agentName) })
+		if err := handoff.RegisterAgentName(ctx, rdb, agentName); err != nil {
+			log.Error().Err(err).Str(logging.FieldAgent, agentName).Msg("Failed to register agent")
+		}
+		log.Info().Str(logging.FieldAgent, agentName).Msg("Sending heartbeats")
+		tracked = true
+	}
+	if tracked {
+		if err := heartbeater.Beat(ctx); err != nil {
+			log.Error().Err(err).Msg("Failed to send heartbeat")
+		}
+	}
+}
+
+// dispatching counts the handoffs being dispatched to each agent, reported as
+// the load in its heartbeats
+var dispatching = struct {
+	sync.Mutex
+	byAgent map[string]int
+}{byAgent: make(map[string]int)}
+
+func dispatchStarted(agentName string) {
+	dispatching.Lock()
+	defer dispatching.Unlock()
+	dispatching.byAgent[agentName]++
+}
+
+func dispatchFinished(agentName string) {
+	dispatching.Lock()
+	defer dispatching.Unlock()
+	dispatching.byAgent[agentName]--
+}
+
+func dispatchLoad(agentName string) int {
+	dispatching.Lock()
+	defer dispatching.Unlock()
+	return dispatching.byAgent[agentName]
+}
+
+// metricLabels returns the metric labels of a dispatched handoff
+func metricLabels(projectName, agentName, priority string) handoff.MetricLabels {
+	return handoff.MetricLabels{Project: projectName, Agent: agentName, Priority: handoff.Priority(priority)}
+}
+
+// metricsRecorder records the dispatcher's handoff events; METRICS_RETENTION
+// sets how long its per-minute buckets are kept
+var metricsRecorder = handoff.NewMetricsRecorder(handoff.DefaultMetricsRetention)
+
+// slaPolicy is how long handoffs of each priority may wait in their queue;
+// SLA_TARGETS overrides it
+var slaPolicy = mustParseSLAPolicy(config.DefaultSLATargets)
+
+func mustParseSLAPolicy(targets string) handoff.SLAPolicy {
+	policy, err := handoff.ParseSLAPolicy(targets)
+	if err != nil {
+		panic(err)
+	}
+	return policy
+}
+
+// checkSLA records an SLA breach on a handoff that waited in its queue longer
+// than its priority allows
+func checkSLA(rdb *redis.Client, queueName, handoffID string) {
+	labels, first, err := handoff.StartSLA(context.Background(), rdb, slaPolicy, queueName, handoffID, time.Now())
+	if err != nil {
+		log.Error().Err(err).Str(logging.FieldHandoffID, handoffID).Msg("Failed to check SLA")
+		return
+	}
+	if first {
+		logging.Handoff(context.Background(), handoffID, labels.Project, labels.Agent).Warn().
+			Str("priority", string(labels.Priority)).Msg("Handoff started past its SLA")
+		recordEvent(rdb, handoff.EventSLABreached, labels)
+	}
+}
+
+// tracer exports the dispatcher's spans; TRACE_EXPORTER and friends configure it
+var tracer *handoff.Tracer
+
+// traceDispatch continues a handoff's trace with the time it waited in its
+// queue and returns the context of the dispatch span
+func traceDispatch(payload *HandoffPayload, projectName, agentName string, dequeuedAt time.Time) (context.Context, *handoff.Span) {
+	ctx := handoff.ContextWithHandoffTrace(context.Background(), payload.Trace)
+	if !payload.CreatedAt.IsZero() {
+		_, wait := tracer.StartAt(ctx, "handoff.queue_wait", handoff.SpanInternal, payload.CreatedAt)
+		wait.SetAttribute("handoff.id", payload.Metadata.HandoffID)
+		wait.SetAttribute("handoff.priority", payload.Metadata.Priority)
+		wait.End(nil)
+	}
+	ctx, span := tracer.StartAt(ctx, "handoff.dispatch", handoff.SpanConsumer, dequeuedAt)
+	span.SetAttribute("handoff.id", payload.Metadata.HandoffID)
+	span.SetAttribute("project", projectName)
+	span.SetAttribute("agent", agentName)
+	return ctx, span
+}
+
+// shutdownTracer exports the spans still queued before the process exits
+func shutdownTracer() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to export remaining spans")
+	}
+}
+
+// recordEvent counts a handoff event for the metrics served by the HTTP server
+func recordEvent(rdb *redis.Client, event handoff.HandoffEvent, labels handoff.MetricLabels) {
+	_, err := rdb.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		metricsRecorder.RecordEvent(context.Background(), pipe, event, labels)
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Str("event", string(event)).Str(logging.FieldProject, labels.Project).Str(logging.FieldAgent, labels.Agent).
+			Msg("Failed to record handoff event")
+	}
+}
+
+// recordExecution counts a finished execution and records how long it took
+func recordExecution(rdb *redis.Client, labels handoff.MetricLabels, success bool, duration time.Duration) {
+	event := handoff.EventFailed
+	if success {
+		event = handoff.EventCompleted
+	}
+	_, err := rdb.Pipelined(context.Background(), func(pipe redis.Pipeliner) error {
+		metricsRecorder.RecordEvent(context.Background(), pipe, event, labels)
+		metricsRecorder.RecordProcessingTime(context.Background(), pipe, labels, duration)
+		return nil
+	})
+	if err != nil {
+		log.Error().Err(err).Str(logging.FieldProject, labels.Project).Str(logging.FieldAgent, labels.Agent).Msg("Failed to record execution")
+	}
+}
+
+// archiveHandoff saves the successful handoff payload to the file system,
+// converted to YAML when that is the archive format.
+func archiveHandoff(payload string, handoffData *HandoffPayload, handoffID string, format handoff.Format) error {
 	var ts time.Time
 	if !handoffData.Metadata.Timestamp.IsZero() {
 		ts = handoffData.Metadata.Timestamp
@@ -185,10 +479,19 @@ func archiveHandoff(payload string, handoffData *HandoffPayload, handoffID strin
 		projectName = "unknown-project"
 	}
 
-	fileName := fmt.Sprintf("%s-%s-%s.json",
+	fileName := fmt.Sprintf("%s-%s-%s%s",
 		ts.UTC().Format("20060102T150405Z"),
 		handoffData.Metadata.ToAgent,
-		handoffID[:8])
+		handoffID[:8],
+		format.Extension())
+
+	data := []byte(payload)
+	if format == handoff.FormatYAML {
+		var err error
+		if data, err = handoff.JSONToYAML(data); err != nil {
+			return fmt.Errorf("failed to convert handoff to YAML: %w", err)
+		}
+	}
 
 	archiveDir := filepath.Join("archive", projectName, datePath)
 	if err := os.MkdirAll(archiveDir, 0755); err != nil {
@@ -196,33 +499,41 @@ func archiveHandoff(payload string, handoffData *HandoffPayload, handoffID strin
 	}
 
 	filePath := filepath.Join(archiveDir, fileName)
-	log.Printf("[Archive] Saving handoff to %s", filePath)
+	log.Debug().Str(logging.FieldHandoffID, handoffID).Str("path", filePath).Msg("Archiving handoff")
 
-	return os.WriteFile(filePath, []byte(payload), 0644)
+	return os.WriteFile(filePath, data, 0644)
 }
 
 // runAgentExecutor executes a single agent directly (executor mode)
-func runAgentExecutor(agentName, projectName, payloadFile string, payloadStdin bool) {
+func runAgentExecutor(agentName, projectName, payloadFile string, payloadStdin bool, format handoff.Format) {
 	if agentName == "" {
-		log.Fatal("❌ Agent name is required in executor mode")
+		log.Fatal().Msg("Agent name is required in executor mode")
 	}
 
 	// Get payload
 	payload := getPayload(payloadFile, payloadStdin)
 	if payload == "" {
-		log.Fatal("❌ Payload is required (use --payload-file or --payload-stdin)")
+		log.Fatal().Msg("Payload is required (use --payload-file or --payload-stdin)")
+	}
+	if format == handoff.FormatYAML {
+		data, err := handoff.YAMLToJSON([]byte(payload))
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to parse YAML payload")
+		}
+		payload = string(data)
 	}
 
 	// Initialize executor
 	agentExecutor, err := executor.NewAgentExecutor(executor.ModeExecutor)
 	if err != nil {
-		log.Fatalf("❌ Failed to initialize executor: %v", err)
+		log.Fatal().Err(err).Msg("Failed to initialize executor")
 	}
+	agentExecutor.SetTracer(tracer)
 
 	// Create execution request
 	req, err := executor.ExtractExecutionRequest(payload, projectName)
 	if err != nil {
-		log.Fatalf("❌ Failed to parse execution request: %v", err)
+		log.Fatal().Err(err).Msg("Failed to parse execution request")
 	}
 
 	// Ensure agent name is set
@@ -230,90 +541,112 @@ func runAgentExecutor(agentName, projectName, payloadFile string, payloadStdin b
 		req.AgentName = agentName
 	}
 
-	log.Printf("🚀 Executing agent '%s' for project '%s'", req.AgentName, req.ProjectName)
-
-	// Execute agent
+	// Execute agent, continuing the trace of the process that started this one
 	ctx := context.Background()
+	if tc, err := handoff.ParseTraceparent(os.Getenv(handoff.TraceparentEnv)); err == nil {
+		ctx = handoff.ContextWithTrace(ctx, tc)
+	}
 	response, err := agentExecutor.Execute(ctx, *req)
+	shutdownTracer()
+	logger := logging.Handoff(ctx, req.HandoffID, req.ProjectName, req.AgentName)
 	if err != nil {
-		log.Fatalf("❌ Agent execution failed: %v", err)
+		logger.Fatal().Err(err).Msg("Agent execution failed")
 	}
 
 	if response.Success {
-		log.Printf("✅ Agent '%s' completed successfully in %v", req.AgentName, response.Duration)
-		log.Printf("📄 Output:\n%s", response.Output)
+		// The agent's output is the result of this command, so it goes to stdout
+		fmt.Println(response.Output)
 
 		if len(response.Artifacts) > 0 {
-			log.Printf("📦 Artifacts: %v", response.Artifacts)
+			logger.Info().Strs("artifacts", response.Artifacts).Msg("Artifacts produced")
 		}
-
-		if len(response.NextHandoffs) > 0 {
-			log.Printf("🔄 Next handoffs: %d pending", len(response.NextHandoffs))
-			for i, handoff := range response.NextHandoffs {
-				log.Printf("   %d. %s: %s", i+1, handoff.ToAgent, handoff.Summary)
-			}
+		for _, next := range response.NextHandoffs {
+			logger.Info().Str("to_agent", next.ToAgent).Str("summary", handoff.RedactSecrets(next.Summary)).Msg("Next handoff pending")
 		}
 
 		os.Exit(0)
 	} else {
-		log.Printf("❌ Agent '%s' failed: %s", req.AgentName, response.Error)
+		logger.Error().Str("error", response.Error).Msg("Agent failed")
 		os.Exit(1)
 	}
 }
 
 // dispatchWithBuiltInExecutor dispatches using the built-in executor
-func dispatchWithBuiltInExecutor(projectName, agentName, payload string, agentExecutor *executor.AgentExecutor) {
-	log.Printf("[Dispatch] Processing task for project '%s', agent '%s' (built-in)", projectName, agentName)
+func dispatchWithBuiltInExecutor(rdb *redis.Client, blobs handoff.BlobStore, projectName, agentName, payload string, agentExecutor *executor.AgentExecutor, archiveFormat handoff.Format, dequeuedAt time.Time) {
+	logger := logging.Handoff(context.Background(), "", projectName, agentName)
 
 	var handoff HandoffPayload
 	if err := json.Unmarshal([]byte(payload), &handoff); err != nil {
-		log.Printf("[ERROR] Failed to decode task payload: %v", err)
+		logger.Error().Err(err).Msg("Failed to decode task payload")
 		return
 	}
 
 	handoffID := handoff.Metadata.HandoffID
 	if handoffID == "" {
-		log.Printf("[ERROR] Missing handoff ID in payload")
+		logger.Error().Msg("Missing handoff ID in payload")
 		return
 	}
 
-	log.Printf("[Dispatch] Invoking built-in agent '%s' for handoff '%s' in project '%s'", agentName, handoffID, projectName)
+	ctx, span := traceDispatch(&handoff, projectName, agentName, dequeuedAt)
+	ctx = logging.WithHandoff(ctx, handoffID, projectName, agentName)
+	logger = logging.Ctx(ctx)
+	logger.Info().Msg("Dispatching handoff to built-in executor")
+	var failure error
+	defer func() { span.End(failure) }()
+
+	// The agent gets every technical_details field inline; the stored payload,
+	// verified before dispatch, is what gets archived
+	inflated, err := inflatePayload(ctx, blobs, payload)
+	if err != nil {
+		logger.Error().Err(err).Msg("Failed to load offloaded fields")
+		failure = err
+		return
+	}
 
 	// Create execution request
-	req, err := executor.ExtractExecutionRequest(payload, projectName)
+	req, err := executor.ExtractExecutionRequest(inflated, projectName)
 	if err != nil {
-		log.Printf("[ERROR] Failed to create execution request: %v", err)
+		logger.Error().Err(err).Msg("Failed to create execution request")
+		failure = err
 		return
 	}
 
 	// Execute using built-in executor
-	ctx := context.Background()
+	start := time.Now()
 	response, err := agentExecutor.Execute(ctx, *req)
 	if err != nil {
-		log.Printf("[FAILURE] Built-in agent '%s' failed: %v", agentName, err)
+		failure = err
+		recordExecution(rdb, metricLabels(projectName, agentName, handoff.Metadata.Priority), false, time.Since(start))
 		return
 	}
+	recordExecution(rdb, metricLabels(projectName, agentName, handoff.Metadata.Priority), response.Success, time.Since(start))
 
 	if response.Success {
-		log.Printf("[SUCCESS] Built-in agent '%s' completed for handoff '%s' in %v", agentName, handoffID, response.Duration)
-		log.Printf("[OUTPUT]\n%s", response.Output)
-
-		if len(response.Artifacts) > 0 {
-			log.Printf("[ARTIFACTS] %v", response.Artifacts)
-		}
+		// Agent output may echo credentials from the handoff, so only its size is logged
+		logger.Debug().Int("output_bytes", len(response.Output)).Strs("artifacts", response.Artifacts).Msg("Agent output")
 
 		// Handle next handoffs
 		if len(response.NextHandoffs) > 0 {
-			log.Printf("[HANDOFFS] Creating %d follow-up handoffs", len(response.NextHandoffs))
+			logger.Info().Int("count", len(response.NextHandoffs)).Msg("Creating follow-up handoffs")
 			// TODO: Implement follow-up handoff creation
 		}
 
-		if err := archiveHandoff(payload, &handoff, handoffID); err != nil {
-			log.Printf("[CRITICAL] Agent '%s' succeeded but failed to archive: %v", agentName, err)
+		if err := archiveHandoff(payload, &handoff, handoffID, archiveFormat); err != nil {
+			logger.Error().Err(err).Msg("Agent succeeded but the handoff could not be archived")
 		}
 	} else {
-		log.Printf("[FAILURE] Built-in agent '%s' failed: %s", agentName, response.Error)
+		failure = fmt.Errorf("agent failed: %s", response.Error)
+	}
+}
+
+// inflatePayload loads a verified payload's offloaded technical_details fields
+// from blobs
+func inflatePayload(ctx context.Context, blobs handoff.BlobStore, payload string) (string, error) {
+	inflated, err := handoff.InflatePayload(ctx, blobs, []byte(payload))
+	if err != nil {
+		return "", err
 	}
+	return string(inflated), nil
 }
 
 // getPayload reads payload from file or stdin
@@ -321,7 +654,7 @@ func getPayload(payloadFile string, payloadStdin bool) string {
 	if payloadStdin {
 		content, err := io.ReadAll(os.Stdin)
 		if err != nil {
-			log.Fatalf("❌ Failed to read payload from stdin: %v", err)
+			log.Fatal().Err(err).Msg("Failed to read payload from stdin")
 		}
 		return strings.TrimSpace(string(content))
 	}
@@ -329,7 +662,7 @@ func getPayload(payloadFile string, payloadStdin bool) string {
 	if payloadFile != "" {
 		content, err := os.ReadFile(payloadFile)
 		if err != nil {
-			log.Fatalf("❌ Failed to read payload file '%s': %v", payloadFile, err)
+			log.Fatal().Err(err).Str("path", payloadFile).Msg("Failed to read payload file")
 		}
 		return strings.TrimSpace(string(content))
 	}
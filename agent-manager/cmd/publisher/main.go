@@ -3,13 +3,19 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"flag"
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+
+	"github.com/vot3k/agent-handoff/agent-manager/internal/config"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/logging"
+	shared "github.com/vot3k/agent-handoff/handoff"
 )
 
 // TestHandoff creates a test handoff message
@@ -30,29 +36,49 @@ type TestHandoff struct {
 		TechnicalDetails map[string]interface{} `json:"technical_details"`
 		NextSteps        []string               `json:"next_steps"`
 	} `json:"content"`
-	Status    string    `json:"status"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	Validation shared.Validation `json:"validation"`
+	Status     string            `json:"status"`
+	CreatedAt  time.Time         `json:"created_at"`
+	UpdatedAt  time.Time         `json:"updated_at"`
 }
 
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Printf("Usage: %s <from_agent> <to_agent> [message]\n", os.Args[0])
-		fmt.Println("Example: go run test-publisher.go architect-expert api-expert")
+	formatFlag := flag.String("format", "", "Also print the published handoff as json or yaml")
+	flag.Parse()
+	args := flag.Args()
+
+	if len(args) < 2 {
+		fmt.Fprintf(os.Stderr, "Usage: %s [--format json|yaml] <from_agent> <to_agent> [message]\n", os.Args[0])
+		fmt.Fprintln(os.Stderr, "Example: go run ./cmd/publisher architect-expert api-expert")
 		os.Exit(1)
 	}
 
-	fromAgent := os.Args[1]
-	toAgent := os.Args[2]
+	logConfig, err := config.LoggingFromEnv()
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid logging configuration")
+	}
+	if err := logging.Setup(logConfig, "agent-manager-publisher"); err != nil {
+		log.Fatal().Err(err).Msg("Invalid logging configuration")
+	}
+
+	var printFormat shared.Format
+	if *formatFlag != "" {
+		if printFormat, err = shared.ParseFormat(*formatFlag); err != nil {
+			log.Fatal().Err(err).Msg("Invalid --format")
+		}
+	}
+
+	fromAgent := args[0]
+	toAgent := args[1]
 	message := "Test handoff message"
-	if len(os.Args) > 3 {
-		message = os.Args[3]
+	if len(args) > 2 {
+		message = args[2]
 	}
 
 	// Get project name from current working directory
 	wd, err := os.Getwd()
 	if err != nil {
-		log.Fatalf("Failed to get current directory: %v", err)
+		log.Fatal().Err(err).Msg("Failed to get current directory")
 	}
 	projectName := filepath.Base(wd)
 
@@ -66,7 +92,7 @@ func main() {
 
 	// Test Redis connection
 	if err := rdb.Ping(ctx).Err(); err != nil {
-		log.Fatalf("Failed to connect to Redis at %s: %v", redisAddr, err)
+		log.Fatal().Err(err).Str("address", redisAddr).Msg("Failed to connect to Redis")
 	}
 
 	// Create test handoff
@@ -84,7 +110,7 @@ func main() {
 	handoff.Metadata.Priority = "normal"
 	handoff.Metadata.HandoffID = fmt.Sprintf("test-%d", time.Now().UnixNano())
 
-	handoff.Content.Summary = message
+	handoff.Content.Summary = shared.RedactSecrets(message)
 	handoff.Content.Requirements = []string{
 		"Process the incoming request",
 		"Generate appropriate output",
@@ -108,46 +134,76 @@ func main() {
 		"Update handoff status",
 	}
 
+	// Seal the handoff so the dispatcher accepts it
+	checksum, err := shared.ComputeChecksum(handoff.Metadata, handoff.Content)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Failed to checksum handoff")
+	}
+	handoff.Validation = shared.Validation{SchemaVersion: "1.0", Checksum: checksum}
+
+	// Sign as the from agent when signing keys are available
+	if keysFile := os.Getenv("SIGNING_KEYS_FILE"); keysFile != "" {
+		keys, err := shared.LoadKeyRegistry(keysFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load signing keys")
+		}
+		if handoff.Validation.Signature, err = keys.SignContent(fromAgent, handoff.Metadata, handoff.Content); err != nil {
+			log.Fatal().Err(err).Msg("Failed to sign handoff")
+		}
+	}
+
 	// Serialize handoff
 	payload, err := json.MarshalIndent(handoff, "", "  ")
 	if err != nil {
-		log.Fatalf("Failed to serialize handoff: %v", err)
+		log.Fatal().Err(err).Msg("Failed to serialize handoff")
 	}
 
 	// Determine target queue
 	queueName := fmt.Sprintf("handoff:project:%s:queue:%s", projectName, toAgent)
 
-	// Store handoff data in Redis with expiration (24 hours)
-	handoffKey := fmt.Sprintf("handoff:%s", handoff.Metadata.HandoffID)
-	if err := rdb.Set(ctx, handoffKey, payload, 24*time.Hour).Err(); err != nil {
-		log.Fatalf("Failed to store handoff data: %v", err)
-	}
-
-	// Push to priority queue (using sorted set like the existing system)
+	// Store the handoff data (expiring after 24 hours) and queue it in one
+	// transaction, as the HTTP server does, recording when it was queued for
+	// queue ages and SLAs and counting it as published.
 	// Priority: normal = 3, with timestamp for FIFO within same priority
+	handoffKey := fmt.Sprintf("handoff:%s", handoff.Metadata.HandoffID)
 	score := 3.0 + float64(time.Now().UnixNano())/1e18
-	if err := rdb.ZAdd(ctx, queueName, &redis.Z{
-		Score:  score,
-		Member: handoff.Metadata.HandoffID,
-	}).Err(); err != nil {
-		log.Fatalf("Failed to queue handoff: %v", err)
+	metrics := shared.NewMetricsRecorder(shared.DefaultMetricsRetention)
+	labels := shared.MetricLabels{Project: projectName, Agent: toAgent, Priority: shared.Priority(handoff.Metadata.Priority)}
+	_, err = rdb.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, handoffKey, payload, 24*time.Hour)
+		pipe.ZAdd(ctx, queueName, &redis.Z{
+			Score:  score,
+			Member: handoff.Metadata.HandoffID,
+		})
+		shared.MarkEnqueued(ctx, pipe, queueName, handoff.Metadata.HandoffID, time.Now())
+		metrics.RecordEvent(ctx, pipe, shared.EventPublished, labels)
+		return nil
+	})
+	if err != nil {
+		log.Fatal().Err(err).Str("queue", queueName).Msg("Failed to queue handoff")
 	}
 
-	fmt.Printf("✅ Published handoff to queue: %s\n", queueName)
-	fmt.Printf("Project: %s\n", projectName)
-	fmt.Printf("📨 Handoff ID: %s\n", handoff.Metadata.HandoffID)
-	fmt.Printf("🔄 From: %s → To: %s\n", fromAgent, toAgent)
-	fmt.Printf("📝 Summary: %s\n", message)
-	fmt.Printf("📊 Queue depth after push: ")
+	published := log.Info().
+		Str(logging.FieldHandoffID, handoff.Metadata.HandoffID).
+		Str(logging.FieldProject, projectName).
+		Str("from_agent", fromAgent).
+		Str("to_agent", toAgent).
+		Str("queue", queueName).
+		Str("summary", handoff.Content.Summary)
 
 	// Check queue depth (using ZCard for sorted sets)
-	depth, err := rdb.ZCard(ctx, queueName).Result()
-	if err != nil {
-		fmt.Printf("(error: %v)\n", err)
+	if depth, err := rdb.ZCard(ctx, queueName).Result(); err != nil {
+		log.Warn().Err(err).Str("queue", queueName).Msg("Failed to read queue depth")
 	} else {
-		fmt.Printf("%d\n", depth)
+		published = published.Int64("queue_depth", depth)
+	}
+	published.Msg("Published handoff; run go run ./cmd/manager to process it")
+
+	if printFormat != "" {
+		document, err := shared.Marshal(handoff, printFormat)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to encode handoff")
+		}
+		fmt.Println(strings.TrimSpace(string(document)))
 	}
-
-	fmt.Println("\n🚀 You can now run the agent-manager to process this handoff:")
-	fmt.Println("   go run ./cmd/manager/main.go")
 }
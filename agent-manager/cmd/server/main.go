@@ -2,33 +2,45 @@ package main
 
 import (
 	"context"
-	"log"
 	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
 	"time"
 
+	"github.com/rs/zerolog/log"
+
 	"github.com/vot3k/agent-handoff/agent-manager/internal/config"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/executor"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/handlers"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/logging"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/middleware"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/repository"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/routing"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/service"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/tools"
+	"github.com/vot3k/agent-handoff/handoff"
 )
 
 func main() {
 	// Load configuration
 	cfg, err := config.Load()
 	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
+		log.Fatal().Err(err).Msg("Failed to load configuration")
+	}
+	if err := logging.Setup(cfg.Logging, "agent-manager-server"); err != nil {
+		log.Fatal().Err(err).Msg("Invalid logging configuration")
 	}
 
 	// Initialize Redis client
 	redisClient, err := repository.NewRedisClient(cfg.Redis)
 	if err != nil {
-		log.Fatalf("Failed to initialize Redis client: %v", err)
+		log.Fatal().Err(err).Msg("Failed to initialize Redis client")
 	}
 	defer redisClient.Close()
+	redisClient.SetMetricsRetention(cfg.Metrics.Retention)
+	redisClient.SetSLAPolicy(cfg.SLA.Targets)
+	redisClient.SetHistoryTiers(cfg.Metrics.History)
 
 	// Initialize repositories
 	handoffRepo := repository.NewHandoffRepository(redisClient)
@@ -36,12 +48,107 @@ func main() {
 	// Initialize services
 	handoffService := service.NewHandoffService(handoffRepo, cfg)
 
+	// Start a trace for each created handoff, exported when TRACE_EXPORTER is set
+	tracer, err := handoff.NewTracer("agent-manager-server", cfg.Tracing)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid tracing configuration")
+	}
+	handoffService.SetTracer(tracer)
+
+	// Enable routing for handoffs that request to_agent "auto"
+	if cfg.Routing.ConfigFile != "" {
+		router, err := routing.LoadRouter(cfg.Routing.ConfigFile, cfg.Routing.FallbackAgent)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load routing configuration")
+		}
+		handoffService.SetRouter(router)
+		log.Info().Str("path", cfg.Routing.ConfigFile).Msg("Routing enabled")
+	}
+
+	// Enforce the shared handoff validation policy
+	if cfg.Validation.PolicyFile != "" {
+		policy, err := handoff.LoadValidationPolicy(cfg.Validation.PolicyFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load validation policy")
+		}
+		validator, err := handoff.NewHandoffValidatorWithPolicy(policy)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid validation policy")
+		}
+		handoffService.SetValidator(validator)
+		log.Info().Str("path", cfg.Validation.PolicyFile).Msg("Validation policy loaded")
+	}
+
+	// Verify request signatures and sign stored handoffs for verified producers
+	signaturePolicy, err := handoff.ParseSignaturePolicy(cfg.Signing.Policy)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid signing configuration")
+	}
+	if cfg.Signing.KeysFile != "" {
+		keys, err := handoff.LoadKeyRegistry(cfg.Signing.KeysFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load signing keys")
+		}
+		handoffService.SetSigning(keys, signaturePolicy)
+		handoffService.SetServerSigning(cfg.Signing.ServerSign)
+		log.Info().Str("path", cfg.Signing.KeysFile).Strs("agents", keys.Agents()).Str("policy", string(signaturePolicy)).Msg("Signing keys loaded")
+		if cfg.Signing.ServerSign {
+			log.Warn().Msg("SIGNING_SERVER_SIGN is on: unsigned requests are signed as their from_agent, so the server is trusted to publish as any agent")
+		}
+	} else if signaturePolicy.Enforced() {
+		log.Fatal().Str("policy", string(signaturePolicy)).Msg("SIGNATURE_POLICY requires SIGNING_KEYS_FILE")
+	}
+	checksumPolicy, err := handoff.ParseChecksumPolicy(cfg.Signing.ChecksumPolicy)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid CHECKSUM_POLICY")
+	}
+	handoffService.SetChecksumPolicy(checksumPolicy)
+
+	// Check artifact paths against the project tree and record their hashes
+	artifactMode, err := handoff.ParseArtifactVerification(cfg.Artifacts.Verification)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid ARTIFACT_VERIFICATION")
+	}
+	if artifactMode != handoff.ArtifactVerificationOff {
+		handoffService.SetArtifactVerification(artifactMode, handoff.ArtifactProjects{
+			BaseDir: cfg.Artifacts.BaseDir,
+			Path:    executor.DetectProjectPath,
+		})
+		log.Info().Str("mode", string(artifactMode)).Str("base_dir", cfg.Artifacts.BaseDir).Msg("Artifact verification enabled")
+	}
+
+	// Offload large technical_details fields
+	switch cfg.Payload.BlobStore {
+	case "redis":
+		handoffService.SetBlobStore(redisClient.BlobStore())
+		log.Info().Str("blob_store", "redis").Msg("Large handoff fields will be offloaded")
+	case "file":
+		store, err := handoff.NewFileBlobStore(cfg.Payload.BlobDir)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create blob store")
+		}
+		handoffService.SetBlobStore(store)
+		// Expire file blobs as the Redis store does
+		go store.RunPrune(context.Background(), handoff.BlobPruneInterval)
+		log.Info().Str("blob_store", "file").Str("path", cfg.Payload.BlobDir).Msg("Large handoff fields will be offloaded")
+	}
+
+	// Return the existing handoff for retried create requests
+	handoffService.SetDeduplication(handoff.DedupPolicy{Window: cfg.Dedup.Window, ByContent: cfg.Dedup.ByContent})
+
 	// Initialize handlers
 	handoffHandler := handlers.NewHandoffHandler(handoffService)
-	healthHandler := handlers.NewHealthHandler(redisClient)
+	healthChecks := append(redisClient.HealthChecks(cfg.Health.Report),
+		tools.HealthCheck(),
+		handoff.DiskHealthCheck(cfg.Health.ArchiveDir),
+	)
+	healthHandler := handlers.NewHealthHandler(handoff.NewHealthChecker(cfg.Health.Report, healthChecks...))
+	metricsHandler := handoff.MetricsHandler(redisClient.CollectMetrics)
+	summaryHandler := handoff.MetricsSummaryHandler(redisClient.QueryRollingMetrics)
+	historyHandler := handoff.HistoryHandler(redisClient.QueryHistory)
 
 	// Setup router with middleware
-	router := setupRouter(handoffHandler, healthHandler)
+	router := setupRouter(handoffHandler, healthHandler, metricsHandler, summaryHandler, historyHandler, int64(cfg.Payload.MaxRequestBytes))
 
 	// Create HTTP server
 	server := &http.Server{
@@ -54,9 +161,9 @@ func main() {
 
 	// Start server in a goroutine
 	go func() {
-		log.Printf("Starting HTTP server on %s", cfg.Server.Address)
+		log.Info().Str("address", cfg.Server.Address).Msg("Starting HTTP server")
 		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
-			log.Fatalf("Server failed to start: %v", err)
+			log.Fatal().Err(err).Msg("Server failed to start")
 		}
 	}()
 
@@ -65,7 +172,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 
-	log.Println("Shutting down server...")
+	log.Info().Msg("Shutting down server")
 
 	// Create a deadline for shutdown
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -73,22 +180,31 @@ func main() {
 
 	// Shutdown server gracefully
 	if err := server.Shutdown(ctx); err != nil {
-		log.Fatalf("Server forced to shutdown: %v", err)
+		log.Fatal().Err(err).Msg("Server forced to shutdown")
+	}
+	if err := tracer.Shutdown(ctx); err != nil {
+		log.Error().Err(err).Msg("Failed to export remaining spans")
 	}
 
-	log.Println("Server exited")
+	log.Info().Msg("Server exited")
 }
 
-func setupRouter(handoffHandler *handlers.HandoffHandler, healthHandler *handlers.HealthHandler) http.Handler {
+func setupRouter(handoffHandler *handlers.HandoffHandler, healthHandler *handlers.HealthHandler, metricsHandler, summaryHandler, historyHandler http.Handler, maxRequestBytes int64) http.Handler {
 	mux := http.NewServeMux()
 
 	// Health check endpoints
 	mux.HandleFunc("/health", healthHandler.Health)
 	mux.HandleFunc("/health/ready", healthHandler.Ready)
 
+	// Prometheus metrics
+	mux.Handle("GET /metrics", metricsHandler)
+	mux.Handle("GET /metrics/summary", summaryHandler)
+	mux.Handle("GET /metrics/history", historyHandler)
+
 	// Handoff management endpoints
 	mux.HandleFunc("POST /api/v1/handoffs", handoffHandler.CreateHandoff)
 	mux.HandleFunc("GET /api/v1/handoffs/{id}", handoffHandler.GetHandoff)
+	mux.HandleFunc("GET /api/v1/handoffs/{id}/offloaded/{field}", handoffHandler.GetOffloadedField)
 	mux.HandleFunc("GET /api/v1/handoffs", handoffHandler.ListHandoffs)
 	mux.HandleFunc("PUT /api/v1/handoffs/{id}/status", handoffHandler.UpdateStatus)
 
@@ -105,6 +221,7 @@ func setupRouter(handoffHandler *handlers.HandoffHandler, healthHandler *handler
 		middleware.Recovery,
 		middleware.Timeout(30*time.Second),
 		middleware.RateLimit(100), // 100 requests per minute
+		middleware.MaxBodySize(maxRequestBytes),
 	)
 
 	return handler
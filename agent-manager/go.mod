@@ -10,4 +10,15 @@ require (
 require (
 	github.com/cespare/xxhash/v2 v2.1.2 // indirect
 	github.com/dgryski/go-rendezvous v0.0.0-20200823014737-9f7001d12a5f // indirect
+	github.com/mattn/go-colorable v0.1.13 // indirect
+	github.com/mattn/go-isatty v0.0.19 // indirect
+	golang.org/x/sys v0.12.0 // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+require (
+	github.com/rs/zerolog v1.31.0
+	github.com/vot3k/agent-handoff/handoff v0.0.0
+)
+
+replace github.com/vot3k/agent-handoff/handoff => ../handoff
@@ -2,10 +2,11 @@ package agents
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/rs/zerolog/log"
 )
 
 // ArchitectureAnalyzer analyzes software architecture
@@ -111,7 +112,7 @@ func (a *ArchitectureAnalyzer) AnalyzeArchitecture() (*ArchitectureAnalysis, err
 		return nil, fmt.Errorf("project path is empty")
 	}
 
-	log.Printf("🏗️ Analyzing architecture at: %s", a.projectPath)
+	log.Info().Str("project_path", a.projectPath).Msg("Analyzing architecture")
 
 	analysis := &ArchitectureAnalysis{
 		Components:      []ArchitectureComponent{},
@@ -132,17 +133,17 @@ func (a *ArchitectureAnalyzer) AnalyzeArchitecture() (*ArchitectureAnalysis, err
 
 	// Analyze components
 	if err := a.analyzeComponents(analysis); err != nil {
-		log.Printf("⚠️ Error analyzing components: %v", err)
+		log.Warn().Err(err).Msg("Failed to analyze components")
 	}
 
 	// Analyze dependencies
 	if err := a.analyzeDependencies(analysis); err != nil {
-		log.Printf("⚠️ Error analyzing dependencies: %v", err)
+		log.Warn().Err(err).Msg("Failed to analyze dependencies")
 	}
 
 	// Analyze layers
 	if err := a.analyzeLayers(analysis); err != nil {
-		log.Printf("⚠️ Error analyzing layers: %v", err)
+		log.Warn().Err(err).Msg("Failed to analyze layers")
 	}
 
 	// Calculate complexity and coupling scores
@@ -151,8 +152,7 @@ func (a *ArchitectureAnalyzer) AnalyzeArchitecture() (*ArchitectureAnalysis, err
 	// Generate recommendations
 	a.generateRecommendations(analysis)
 
-	log.Printf("✅ Architecture analysis completed: %s pattern with %d components", 
-		analysis.Pattern, len(analysis.Components))
+	log.Info().Str("pattern", analysis.Pattern).Int("components", len(analysis.Components)).Msg("Architecture analysis completed")
 
 	return analysis, nil
 }
@@ -218,7 +218,7 @@ func (a *ArchitectureAnalyzer) analyzeDependencies(analysis *ArchitectureAnalysi
 	for _, component := range analysis.Components {
 		deps, err := a.findComponentDependencies(component, analysis.Components)
 		if err != nil {
-			log.Printf("⚠️ Error finding dependencies for %s: %v", component.Name, err)
+			log.Warn().Err(err).Str("component", component.Name).Msg("Failed to find component dependencies")
 			continue
 		}
 		analysis.Dependencies = append(analysis.Dependencies, deps...)
@@ -3,11 +3,12 @@ package agents
 import (
 	"fmt"
 	"io/fs"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/rs/zerolog/log"
 )
 
 // ProjectAnalyzer analyzes projects to provide management insights
@@ -65,7 +66,7 @@ func (p *ProjectAnalyzer) AnalyzeProject() (*ProjectAnalysis, error) {
 		return nil, fmt.Errorf("project path is empty")
 	}
 
-	log.Printf("🔍 Analyzing project at: %s", p.projectPath)
+	log.Info().Str("project_path", p.projectPath).Msg("Analyzing project")
 
 	analysis := &ProjectAnalysis{
 		ProjectPath:  p.projectPath,
@@ -82,7 +83,7 @@ func (p *ProjectAnalyzer) AnalyzeProject() (*ProjectAnalysis, error) {
 
 	// Analyze project structure
 	if err := p.analyzeProjectStructure(analysis); err != nil {
-		log.Printf("⚠️ Error analyzing project structure: %v", err)
+		log.Warn().Err(err).Msg("Failed to analyze project structure")
 	}
 
 	// Detect project type
@@ -90,28 +91,28 @@ func (p *ProjectAnalyzer) AnalyzeProject() (*ProjectAnalysis, error) {
 
 	// Analyze files
 	if err := p.analyzeFiles(analysis); err != nil {
-		log.Printf("⚠️ Error analyzing files: %v", err)
+		log.Warn().Err(err).Msg("Failed to analyze files")
 	}
 
 	// Analyze dependencies
 	if err := p.analyzeDependencies(analysis); err != nil {
-		log.Printf("⚠️ Error analyzing dependencies: %v", err)
+		log.Warn().Err(err).Msg("Failed to analyze dependencies")
 	}
 
 	// Analyze tests
 	if err := p.analyzeTests(analysis); err != nil {
-		log.Printf("⚠️ Error analyzing tests: %v", err)
+		log.Warn().Err(err).Msg("Failed to analyze tests")
 	}
 
 	// Security analysis
 	if err := p.analyzeSecurityIssues(analysis); err != nil {
-		log.Printf("⚠️ Error analyzing security: %v", err)
+		log.Warn().Err(err).Msg("Failed to analyze security")
 	}
 
 	// Detect features
 	p.detectFeatures(analysis)
 
-	log.Printf("✅ Project analysis completed: %s project with %d files", analysis.ProjectType, analysis.FileCount)
+	log.Info().Str("project_type", analysis.ProjectType).Int("files", analysis.FileCount).Msg("Project analysis completed")
 
 	return analysis, nil
 }
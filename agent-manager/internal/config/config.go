@@ -4,15 +4,30 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/vot3k/agent-handoff/agent-manager/internal/logging"
+	"github.com/vot3k/agent-handoff/handoff"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	Server     ServerConfig     `json:"server"`
-	Redis      RedisConfig      `json:"redis"`
-	Env        string           `json:"env"`
-	Pagination PaginationConfig `json:"pagination"`
+	Server     ServerConfig          `json:"server"`
+	Redis      RedisConfig           `json:"redis"`
+	Env        string                `json:"env"`
+	Pagination PaginationConfig      `json:"pagination"`
+	Routing    RoutingConfig         `json:"routing"`
+	Validation ValidationConfig      `json:"validation"`
+	Signing    SigningConfig         `json:"signing"`
+	Artifacts  ArtifactsConfig       `json:"artifacts"`
+	Payload    PayloadConfig         `json:"payload"`
+	Dedup      DedupConfig           `json:"dedup"`
+	Metrics    MetricsConfig         `json:"metrics"`
+	SLA        SLAConfig             `json:"sla"`
+	Tracing    handoff.TracingConfig `json:"tracing"`
+	Logging    logging.Config        `json:"logging"`
+	Health     HealthConfig          `json:"health"`
 }
 
 // ServerConfig holds HTTP server configuration
@@ -36,6 +51,74 @@ type PaginationConfig struct {
 	MaxPageSize     int `json:"max_page_size"`
 }
 
+// RoutingConfig holds configuration for routing handoffs created with to_agent "auto"
+type RoutingConfig struct {
+	ConfigFile    string `json:"config_file"`    // JSON file with a "routes" section; routing is disabled when empty
+	FallbackAgent string `json:"fallback_agent"` // Agent used when no rule matches
+}
+
+// ValidationConfig holds the optional handoff validation policy shared with the handoff service
+type ValidationConfig struct {
+	PolicyFile string `json:"policy_file"` // JSON handoff.ValidationPolicy file; policy checks are disabled when empty
+}
+
+// SigningConfig holds the per-agent handoff signing keys shared with the handoff
+// service and the policy for handoffs stored before canonical checksums
+type SigningConfig struct {
+	KeysFile string `json:"keys_file"` // JSON key registry file (see handoff.LoadKeyRegistry); signing is disabled when empty
+	Policy   string `json:"policy"`    // off, reject or quarantine: what happens to unsigned or badly signed handoffs
+	// Sign handoffs from unsigned requests as their from_agent. The API does not
+	// authenticate callers, so this makes the server a trusted signer for every agent.
+	ServerSign bool `json:"server_sign"`
+	// migrate processes handoffs with no checksum, stored before checksums
+	// were added, with a warning; strict quarantines them
+	ChecksumPolicy string `json:"checksum_policy"`
+}
+
+// ArtifactsConfig controls verification of artifact paths against the project tree
+type ArtifactsConfig struct {
+	Verification string `json:"verification"` // off, warn or enforce: whether missing artifacts are reported or rejected
+	BaseDir      string `json:"base_dir"`     // Directory every verified project must be inside
+}
+
+// PayloadConfig bounds request sizes and selects where large technical_details
+// fields are offloaded. Size limits come from the validation policy.
+type PayloadConfig struct {
+	MaxRequestBytes int    `json:"max_request_bytes"` // Largest request body accepted
+	BlobStore       string `json:"blob_store"`        // redis, file or empty to keep large fields inline
+	BlobDir         string `json:"blob_dir"`          // Directory for the file blob store
+}
+
+// DedupConfig controls how repeated create requests are detected
+type DedupConfig struct {
+	Window    time.Duration `json:"window"`     // How long idempotency keys and fingerprints are remembered; zero disables deduplication
+	ByContent bool          `json:"by_content"` // Also treat identical requests without an idempotency key as duplicates
+}
+
+// MetricsConfig controls the per-minute metric buckets behind windowed counts and percentiles
+type MetricsConfig struct {
+	Retention time.Duration         `json:"retention"` // How long per-minute buckets are kept
+	History   []handoff.HistoryTier `json:"history"`   // From METRICS_HISTORY_TIERS; the tiers the monitor records history at
+}
+
+// DefaultHistoryTiers are the metric history tiers used when METRICS_HISTORY_TIERS is not set
+const DefaultHistoryTiers = "1m:24h,15m:336h,1h:2160h"
+
+// SLAConfig sets how long handoffs of each priority may wait in their queue
+// before processing starts
+type SLAConfig struct {
+	Targets handoff.SLAPolicy `json:"targets"` // From SLA_TARGETS, e.g. "urgent=2m,high=10m"
+}
+
+// DefaultSLATargets are the SLA targets used when SLA_TARGETS is not set
+const DefaultSLATargets = "urgent=2m,high=10m,normal=1h,low=4h"
+
+// HealthConfig sets how /health/ready scores the service
+type HealthConfig struct {
+	Report     handoff.HealthConfig `json:"report"`      // From HEALTH_COMPONENTS, HEALTH_MIN_SCORE and HEALTH_WINDOW
+	ArchiveDir string               `json:"archive_dir"` // Directory the dispatcher archives handoffs in; its free disk space is checked
+}
+
 // Load reads configuration from environment variables with sensible defaults
 func Load() (*Config, error) {
 	cfg := &Config{
@@ -55,6 +138,57 @@ func Load() (*Config, error) {
 			DefaultPageSize: getIntEnv("PAGINATION_DEFAULT_PAGE_SIZE", 20),
 			MaxPageSize:     getIntEnv("PAGINATION_MAX_PAGE_SIZE", 100),
 		},
+		Routing: RoutingConfig{
+			ConfigFile:    getEnv("ROUTING_CONFIG_FILE", ""),
+			FallbackAgent: getEnv("ROUTING_FALLBACK_AGENT", ""),
+		},
+		Validation: ValidationConfig{
+			PolicyFile: getEnv("VALIDATION_POLICY_FILE", ""),
+		},
+		Signing: SigningConfig{
+			KeysFile:       getEnv("SIGNING_KEYS_FILE", ""),
+			Policy:         getEnv("SIGNATURE_POLICY", "off"),
+			ServerSign:     getBoolEnv("SIGNING_SERVER_SIGN", false),
+			ChecksumPolicy: getEnv("CHECKSUM_POLICY", "migrate"),
+		},
+		Artifacts: ArtifactsConfig{
+			Verification: getEnv("ARTIFACT_VERIFICATION", "off"),
+			BaseDir:      getEnv("ARTIFACT_BASE_DIR", ""),
+		},
+		Payload: PayloadConfig{
+			MaxRequestBytes: getIntEnv("MAX_REQUEST_BYTES", 32<<20),
+			BlobStore:       getEnv("BLOB_STORE", ""),
+			BlobDir:         getEnv("BLOB_DIR", ""),
+		},
+		Dedup: DedupConfig{
+			Window:    getDurationEnv("DEDUP_WINDOW", 24*time.Hour),
+			ByContent: getBoolEnv("DEDUP_BY_CONTENT", false),
+		},
+		Metrics: MetricsConfig{
+			Retention: getDurationEnv("METRICS_RETENTION", 7*24*time.Hour),
+		},
+	}
+
+	targets, err := handoff.ParseSLAPolicy(getEnv("SLA_TARGETS", DefaultSLATargets))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SLA_TARGETS: %w", err)
+	}
+	cfg.SLA.Targets = targets
+
+	tiers, err := handoff.ParseHistoryTiers(getEnv("METRICS_HISTORY_TIERS", DefaultHistoryTiers))
+	if err != nil {
+		return nil, fmt.Errorf("invalid METRICS_HISTORY_TIERS: %w", err)
+	}
+	cfg.Metrics.History = tiers
+
+	if cfg.Tracing, err = TracingFromEnv(); err != nil {
+		return nil, err
+	}
+	if cfg.Logging, err = LoggingFromEnv(); err != nil {
+		return nil, err
+	}
+	if cfg.Health, err = HealthFromEnv(); err != nil {
+		return nil, err
 	}
 
 	if err := cfg.Validate(); err != nil {
@@ -90,9 +224,123 @@ func (c *Config) Validate() error {
 	if c.Pagination.DefaultPageSize > c.Pagination.MaxPageSize {
 		return fmt.Errorf("pagination default page size cannot exceed max page size")
 	}
+	if c.Payload.MaxRequestBytes <= 0 {
+		return fmt.Errorf("max request bytes must be positive")
+	}
+	if mode := strings.ToLower(strings.TrimSpace(c.Artifacts.Verification)); mode != "" && mode != "off" && c.Artifacts.BaseDir == "" {
+		return fmt.Errorf("artifact verification requires ARTIFACT_BASE_DIR")
+	}
+	switch c.Payload.BlobStore {
+	case "", "redis":
+	case "file":
+		if c.Payload.BlobDir == "" {
+			return fmt.Errorf("blob store file requires BLOB_DIR")
+		}
+	default:
+		return fmt.Errorf("unknown blob store %q (expected redis or file)", c.Payload.BlobStore)
+	}
+	if c.Dedup.Window < 0 {
+		return fmt.Errorf("dedup window cannot be negative")
+	}
+	if c.Metrics.Retention <= 0 {
+		return fmt.Errorf("metrics retention must be positive")
+	}
 	return nil
 }
 
+// TracingFromEnv reads where spans are exported: TRACE_EXPORTER (otlp, file or
+// unset), TRACE_ENDPOINT, TRACE_HEADERS ("name=value,...") and TRACE_FILE.
+// The dispatcher reads the same variables.
+func TracingFromEnv() (handoff.TracingConfig, error) {
+	cfg := handoff.TracingConfig{
+		Exporter: getEnv("TRACE_EXPORTER", ""),
+		Endpoint: getEnv("TRACE_ENDPOINT", ""),
+		Path:     getEnv("TRACE_FILE", ""),
+	}
+	switch cfg.Exporter {
+	case "", handoff.TraceExporterOTLP:
+	case handoff.TraceExporterFile:
+		if cfg.Path == "" {
+			return cfg, fmt.Errorf("TRACE_EXPORTER file requires TRACE_FILE")
+		}
+	default:
+		return cfg, fmt.Errorf("unknown TRACE_EXPORTER %q (expected otlp or file)", cfg.Exporter)
+	}
+	for _, header := range strings.Split(getEnv("TRACE_HEADERS", ""), ",") {
+		if strings.TrimSpace(header) == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(header, "=")
+		if !ok {
+			return cfg, fmt.Errorf("invalid TRACE_HEADERS entry %q: expected name=value", header)
+		}
+		if cfg.Headers == nil {
+			cfg.Headers = make(map[string]string)
+		}
+		cfg.Headers[strings.TrimSpace(name)] = strings.TrimSpace(value)
+	}
+	return cfg, nil
+}
+
+// LoggingFromEnv reads the log level from LOG_LEVEL and the output format
+// (json or console) from LOG_FORMAT. The dispatcher reads the same variables.
+func LoggingFromEnv() (logging.Config, error) {
+	cfg := logging.Config{
+		Level:  getEnv("LOG_LEVEL", "info"),
+		Format: getEnv("LOG_FORMAT", logging.FormatConsole),
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid logging configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// HealthFromEnv reads the health report's thresholds from HEALTH_COMPONENTS
+// ("component=weight:warn:critical[:required],..."), the score readiness
+// needs from HEALTH_MIN_SCORE, the period failure rate and latency cover
+// from HEALTH_WINDOW and the archive directory from HEALTH_ARCHIVE_DIR
+func HealthFromEnv() (HealthConfig, error) {
+	cfg := HealthConfig{
+		Report: handoff.HealthConfig{
+			MinScore: handoff.DefaultHealthMinScore,
+			Window:   getDurationEnv("HEALTH_WINDOW", handoff.DefaultHealthWindow),
+		},
+		ArchiveDir: getEnv("HEALTH_ARCHIVE_DIR", "archive"),
+	}
+	components, err := handoff.ParseHealthThresholds(getEnv("HEALTH_COMPONENTS", ""))
+	if err != nil {
+		return cfg, fmt.Errorf("invalid HEALTH_COMPONENTS: %w", err)
+	}
+	if len(components) > 0 {
+		cfg.Report.Components = components
+	}
+	if value := os.Getenv("HEALTH_MIN_SCORE"); value != "" {
+		if cfg.Report.MinScore, err = strconv.ParseFloat(value, 64); err != nil {
+			return cfg, fmt.Errorf("invalid HEALTH_MIN_SCORE %q: %w", value, err)
+		}
+	}
+	if err := cfg.Report.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid health configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// HeartbeatFromEnv reads how often the dispatcher sends heartbeats from
+// HEARTBEAT_INTERVAL, how long each counts from HEARTBEAT_TTL and the instance
+// ID and version it reports from HEARTBEAT_INSTANCE_ID and HEARTBEAT_VERSION
+func HeartbeatFromEnv() (handoff.HeartbeatConfig, error) {
+	cfg := handoff.HeartbeatConfig{
+		Interval:   getDurationEnv("HEARTBEAT_INTERVAL", handoff.DefaultHeartbeatInterval),
+		TTL:        getDurationEnv("HEARTBEAT_TTL", 0),
+		InstanceID: getEnv("HEARTBEAT_INSTANCE_ID", ""),
+		Version:    getEnv("HEARTBEAT_VERSION", ""),
+	}
+	if err := cfg.Validate(); err != nil {
+		return cfg, fmt.Errorf("invalid heartbeat configuration: %w", err)
+	}
+	return cfg, nil
+}
+
 // getEnv returns environment variable value or default if not set
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -120,3 +368,13 @@ func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
 	}
 	return defaultValue
 }
+
+// getBoolEnv returns environment variable as bool or default if not set/invalid
+func getBoolEnv(key string, defaultValue bool) bool {
+	if value := os.Getenv(key); value != "" {
+		if boolValue, err := strconv.ParseBool(value); err == nil {
+			return boolValue
+		}
+	}
+	return defaultValue
+}
@@ -3,10 +3,10 @@ package executor
 import (
 	"context"
 	"fmt"
-	"log"
 	"strings"
 
 	"github.com/vot3k/agent-handoff/agent-manager/internal/agents"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/logging"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/tools"
 )
 
@@ -45,8 +45,6 @@ func (b *BuiltInAgentStrategy) CanHandle(agentName string, projectPath string, t
 }
 
 func (b *BuiltInAgentStrategy) Execute(ctx context.Context, req AgentExecutionRequest) (*AgentExecutionResponse, error) {
-	log.Printf("🔧 BuiltInAgentStrategy executing %s", req.AgentName)
-
 	switch req.AgentName {
 	case "project-manager":
 		return b.executeProjectManager(ctx, req)
@@ -61,7 +59,7 @@ func (b *BuiltInAgentStrategy) Execute(ctx context.Context, req AgentExecutionRe
 
 // executeProjectManager implements native project management logic
 func (b *BuiltInAgentStrategy) executeProjectManager(ctx context.Context, req AgentExecutionRequest) (*AgentExecutionResponse, error) {
-	log.Printf("📋 Executing built-in Project Manager")
+	logging.Ctx(ctx).Info().Str("builtin", "project-manager").Msg("Running built-in agent")
 
 	// Create project analyzer
 	analyzer := agents.NewProjectAnalyzer(req.ProjectPath)
@@ -99,7 +97,7 @@ func (b *BuiltInAgentStrategy) executeProjectManager(ctx context.Context, req Ag
 
 // executeArchitectureAnalyzer implements native architecture analysis
 func (b *BuiltInAgentStrategy) executeArchitectureAnalyzer(ctx context.Context, req AgentExecutionRequest) (*AgentExecutionResponse, error) {
-	log.Printf("🏗️ Executing built-in Architecture Analyzer")
+	logging.Ctx(ctx).Info().Str("builtin", "architecture-analyzer").Msg("Running built-in agent")
 
 	analyzer := agents.NewArchitectureAnalyzer(req.ProjectPath)
 
@@ -131,7 +129,7 @@ func (b *BuiltInAgentStrategy) executeArchitectureAnalyzer(ctx context.Context,
 
 // executeAgentManager implements agent orchestration logic
 func (b *BuiltInAgentStrategy) executeAgentManager(ctx context.Context, req AgentExecutionRequest) (*AgentExecutionResponse, error) {
-	log.Printf("🤖 Executing built-in Agent Manager")
+	logging.Ctx(ctx).Info().Str("builtin", "agent-manager").Msg("Running built-in agent")
 
 	// Parse the request to understand what orchestration is needed
 	orchestrationPlan := b.createOrchestrationPlan(req)
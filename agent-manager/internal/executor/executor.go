@@ -3,15 +3,18 @@ package executor
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/rs/zerolog/log"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/logging"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/tools"
+	"github.com/vot3k/agent-handoff/handoff"
 )
 
 // ExecutionMode defines different ways to execute agents
@@ -69,6 +72,7 @@ type AgentExecutor struct {
 	strategies []ExecutionStrategy
 	toolSet    *tools.ToolSet
 	mode       ExecutionMode
+	tracer     *handoff.Tracer
 }
 
 // NewAgentExecutor creates a new agent executor with default strategies
@@ -90,30 +94,71 @@ func NewAgentExecutor(mode ExecutionMode) (*AgentExecutor, error) {
 		NewScriptFallbackStrategy(),
 	}
 
-	log.Printf("✅ AgentExecutor initialized with %d strategies", len(executor.strategies))
-	log.Printf("🔍 Available tools: %v", toolSet.ListAvailable())
+	log.Info().
+		Int("strategies", len(executor.strategies)).
+		Strs("tools", toolSet.ListAvailable()).
+		Msg("Agent executor initialized")
 
 	return executor, nil
 }
 
-// Execute runs an agent using the best available strategy
+// SetTracer exports spans for strategy selection and execution
+func (e *AgentExecutor) SetTracer(tracer *handoff.Tracer) {
+	e.tracer = tracer
+}
+
+// Execute runs an agent using the best available strategy. Its spans continue
+// the trace in ctx or, failing that, the request's TRACEPARENT; the agent
+// process receives the execution span's context in TRACEPARENT. Log lines
+// carry the handoff ID, project, agent and, once chosen, the strategy.
 func (e *AgentExecutor) Execute(ctx context.Context, req AgentExecutionRequest) (*AgentExecutionResponse, error) {
 	start := time.Now()
 
-	log.Printf("🚀 Executing agent '%s' for project '%s'", req.AgentName, req.ProjectName)
-	log.Printf("📄 Task: %s", req.Summary)
+	ctx = logging.WithHandoff(ctx, req.HandoffID, req.ProjectName, req.AgentName)
+	logging.Ctx(ctx).Info().Str("task", handoff.RedactSecrets(req.Summary)).Msg("Executing agent")
+
+	if _, ok := handoff.TraceFromContext(ctx); !ok {
+		if tc, err := handoff.ParseTraceparent(req.Environment[handoff.TraceparentEnv]); err == nil {
+			ctx = handoff.ContextWithTrace(ctx, tc)
+		}
+	}
 
 	// Find the best strategy for this request
+	_, selectSpan := e.tracer.Start(ctx, "executor.select_strategy", handoff.SpanInternal)
+	selectSpan.SetAttribute("agent", req.AgentName)
 	strategy := e.selectStrategy(req)
 	if strategy == nil {
-		return nil, fmt.Errorf("no suitable execution strategy found for agent '%s'", req.AgentName)
+		err := fmt.Errorf("no suitable execution strategy found for agent '%s'", req.AgentName)
+		selectSpan.End(err)
+		logging.Ctx(ctx).Error().Err(err).Msg("Agent execution failed")
+		return nil, err
 	}
+	selectSpan.SetAttribute("strategy", strategy.Name())
+	selectSpan.End(nil)
+
+	ctx = logging.WithStrategy(ctx, strategy.Name())
+	logging.Ctx(ctx).Debug().Msg("Selected execution strategy")
+
+	// Execute using the selected strategy, passing the trace on to the agent
+	ctx, span := e.tracer.Start(ctx, "executor.execute", handoff.SpanInternal)
+	span.SetAttribute("agent", req.AgentName)
+	span.SetAttribute("project", req.ProjectName)
+	span.SetAttribute("strategy", strategy.Name())
+	env := make(map[string]string, len(req.Environment)+1)
+	for key, value := range req.Environment {
+		env[key] = value
+	}
+	env[handoff.TraceparentEnv] = span.Context().Traceparent()
+	req.Environment = env
 
-	log.Printf("🔧 Using strategy: %s", strategy.Name())
-
-	// Execute using the selected strategy
 	response, err := strategy.Execute(ctx, req)
+	if err == nil && response != nil && !response.Success {
+		span.End(errors.New(response.Error))
+	} else {
+		span.End(err)
+	}
 	if err != nil {
+		logging.Ctx(ctx).Error().Err(err).Dur("duration", time.Since(start)).Msg("Agent execution failed")
 		return &AgentExecutionResponse{
 			Success:  false,
 			Error:    err.Error(),
@@ -135,7 +180,7 @@ func (e *AgentExecutor) Execute(ctx context.Context, req AgentExecutionRequest)
 	response.Metadata["agent"] = req.AgentName
 	response.Metadata["project"] = req.ProjectName
 
-	log.Printf("✅ Agent '%s' completed successfully in %v", req.AgentName, response.Duration)
+	logging.Ctx(ctx).Info().Bool("success", response.Success).Dur("duration", response.Duration).Msg("Agent execution finished")
 
 	return response, nil
 }
@@ -205,6 +250,17 @@ func ExtractExecutionRequest(payload string, projectName string) (*AgentExecutio
 	req.Environment["HANDOFF_ID"] = req.HandoffID
 	req.Environment["FROM_AGENT"] = req.FromAgent
 
+	// Continue the trace started when the handoff was created
+	if trace, ok := handoffData["trace"].(map[string]interface{}); ok {
+		tc := handoff.TraceContext{}
+		tc.TraceID, _ = trace["trace_id"].(string)
+		tc.SpanID, _ = trace["span_id"].(string)
+		tc.Sampled, _ = trace["sampled"].(bool)
+		if traceparent := tc.Traceparent(); traceparent != "" {
+			req.Environment[handoff.TraceparentEnv] = traceparent
+		}
+	}
+
 	// Detect project path
 	req.ProjectPath = DetectProjectPath(projectName)
 
@@ -236,7 +292,7 @@ func DetectProjectPath(projectName string) string {
 	for _, path := range commonPaths {
 		if absPath, err := filepath.Abs(path); err == nil {
 			if _, err := os.Stat(absPath); err == nil {
-				log.Printf("📁 Detected project path: %s", absPath)
+				log.Debug().Str("project_path", absPath).Msg("Detected project path")
 				return absPath
 			}
 		}
@@ -244,7 +300,7 @@ func DetectProjectPath(projectName string) string {
 
 	// Strategy 3: Current working directory as fallback
 	if cwd, err := os.Getwd(); err == nil {
-		log.Printf("📁 Using current directory as project path: %s", cwd)
+		log.Debug().Str("project_path", cwd).Msg("Using current directory as project path")
 		return cwd
 	}
 
@@ -292,8 +348,9 @@ func ExtractTaskDescription(payload map[string]interface{}) string {
 	return "Process agent handoff task"
 }
 
-// SetupCommand configures a command with environment and working directory
-func SetupCommand(cmd *exec.Cmd, req AgentExecutionRequest) {
+// SetupCommand configures a command with environment and working directory.
+// Only the variables the manager sets itself are logged with their values.
+func SetupCommand(ctx context.Context, cmd *exec.Cmd, req AgentExecutionRequest) {
 	// Set working directory
 	if req.ProjectPath != "" {
 		cmd.Dir = req.ProjectPath
@@ -306,7 +363,9 @@ func SetupCommand(cmd *exec.Cmd, req AgentExecutionRequest) {
 	}
 	cmd.Env = env
 
-	log.Printf("🔍 Command: %s", strings.Join(cmd.Args, " "))
-	log.Printf("📁 Working directory: %s", cmd.Dir)
-	log.Printf("🌍 Environment: %v", req.Environment)
+	logging.Ctx(ctx).Debug().
+		Str("command", handoff.RedactSecrets(strings.Join(cmd.Args, " "))).
+		Str("dir", cmd.Dir).
+		Interface("environment", logging.RedactEnv(req.Environment)).
+		Msg("Running agent command")
 }
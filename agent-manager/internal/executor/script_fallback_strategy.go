@@ -3,11 +3,12 @@ package executor
 import (
 	"context"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 
+	"github.com/rs/zerolog/log"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/logging"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/tools"
 )
 
@@ -33,18 +34,15 @@ func (s *ScriptFallbackStrategy) CanHandle(agentName string, projectPath string,
 	// Try to find run-agent.sh script
 	scriptPath, err := s.findRunAgentScript(projectPath)
 	if err != nil {
-		log.Printf("⚠️ ScriptFallbackStrategy: %v", err)
+		log.Debug().Err(err).Msg("Script fallback unavailable")
 		return false
 	}
 
 	s.scriptPath = scriptPath
-	log.Printf("📝 ScriptFallbackStrategy: Found script at %s", scriptPath)
 	return true
 }
 
 func (s *ScriptFallbackStrategy) Execute(ctx context.Context, req AgentExecutionRequest) (*AgentExecutionResponse, error) {
-	log.Printf("📝 ScriptFallbackStrategy executing %s with script: %s", req.AgentName, s.scriptPath)
-
 	if s.scriptPath == "" {
 		scriptPath, err := s.findRunAgentScript(req.ProjectPath)
 		if err != nil {
@@ -66,7 +64,7 @@ func (s *ScriptFallbackStrategy) Execute(ctx context.Context, req AgentExecution
 	}
 	cmd.Env = env
 
-	log.Printf("🔍 Executing: %s %s [payload] from directory: %s", s.scriptPath, req.AgentName, scriptDir)
+	logging.Ctx(ctx).Info().Str("script", s.scriptPath).Str("dir", scriptDir).Msg("Running agent script")
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -77,8 +75,6 @@ func (s *ScriptFallbackStrategy) Execute(ctx context.Context, req AgentExecution
 		}, err
 	}
 
-	log.Printf("✅ Script execution completed successfully")
-
 	return &AgentExecutionResponse{
 		Success:   true,
 		Output:    string(output),
@@ -95,10 +91,10 @@ func (s *ScriptFallbackStrategy) findRunAgentScript(projectPath string) (string,
 	// Strategy 1: Check environment variable first
 	if scriptPath := os.Getenv("RUN_AGENT_SCRIPT_PATH"); scriptPath != "" {
 		if _, err := os.Stat(scriptPath); err == nil {
-			log.Printf("📝 Using run-agent.sh from environment variable: %s", scriptPath)
+			log.Debug().Str("script", scriptPath).Msg("Using run-agent.sh from RUN_AGENT_SCRIPT_PATH")
 			return scriptPath, nil
 		}
-		log.Printf("⚠️ RUN_AGENT_SCRIPT_PATH points to non-existent file: %s", scriptPath)
+		log.Warn().Str("script", scriptPath).Msg("RUN_AGENT_SCRIPT_PATH points to a missing file")
 	}
 
 	// Strategy 2: Check project-specific script
@@ -106,7 +102,7 @@ func (s *ScriptFallbackStrategy) findRunAgentScript(projectPath string) (string,
 		projectScript := filepath.Join(projectPath, "run-agent.sh")
 		if _, err := os.Stat(projectScript); err == nil {
 			absPath, _ := filepath.Abs(projectScript)
-			log.Printf("📝 Found project-specific run-agent.sh: %s", absPath)
+			log.Debug().Str("script", absPath).Msg("Found project-specific run-agent.sh")
 			return absPath, nil
 		}
 	}
@@ -119,7 +115,7 @@ func (s *ScriptFallbackStrategy) findRunAgentScript(projectPath string) (string,
 		// Try same directory as executable
 		scriptPath := filepath.Join(execDir, "run-agent.sh")
 		if _, err := os.Stat(scriptPath); err == nil {
-			log.Printf("📝 Found run-agent.sh relative to executable: %s", scriptPath)
+			log.Debug().Str("script", scriptPath).Msg("Found run-agent.sh next to the executable")
 			return scriptPath, nil
 		}
 
@@ -128,7 +124,7 @@ func (s *ScriptFallbackStrategy) findRunAgentScript(projectPath string) (string,
 		scriptPath = filepath.Join(agentManagerDir, "run-agent.sh")
 		if _, err := os.Stat(scriptPath); err == nil {
 			absPath, _ := filepath.Abs(scriptPath)
-			log.Printf("📝 Found run-agent.sh in agent-manager directory: %s", absPath)
+			log.Debug().Str("script", absPath).Msg("Found run-agent.sh in the agent-manager directory")
 			return absPath, nil
 		}
 	}
@@ -144,7 +140,7 @@ func (s *ScriptFallbackStrategy) findRunAgentScript(projectPath string) (string,
 	for _, path := range searchPaths {
 		if absPath, err := filepath.Abs(path); err == nil {
 			if _, err := os.Stat(absPath); err == nil {
-				log.Printf("📝 Found run-agent.sh at: %s", absPath)
+				log.Debug().Str("script", absPath).Msg("Found run-agent.sh")
 				return absPath, nil
 			}
 		}
@@ -268,7 +264,7 @@ exit 0
 		return "", fmt.Errorf("failed to make script executable: %w", err)
 	}
 
-	log.Printf("📝 Created temporary fallback script: %s", tempFile.Name())
+	log.Debug().Str("script", tempFile.Name()).Msg("Created temporary fallback script")
 	return tempFile.Name(), nil
 }
 
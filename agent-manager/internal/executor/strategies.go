@@ -4,11 +4,11 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
 	"strings"
 
+	"github.com/vot3k/agent-handoff/agent-manager/internal/logging"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/tools"
 )
 
@@ -36,8 +36,6 @@ func (t *ToolDetectionStrategy) CanHandle(agentName string, projectPath string,
 }
 
 func (t *ToolDetectionStrategy) Execute(ctx context.Context, req AgentExecutionRequest) (*AgentExecutionResponse, error) {
-	log.Printf("🔧 ToolDetectionStrategy executing %s", req.AgentName)
-
 	switch req.AgentName {
 	case "golang-expert":
 		return t.executeGoExpert(ctx, req)
@@ -131,7 +129,7 @@ func (t *ToolDetectionStrategy) executeTypeScriptExpert(ctx context.Context, req
 
 // executeClaudeTask executes using Claude Code
 func (t *ToolDetectionStrategy) executeClaudeTask(ctx context.Context, req AgentExecutionRequest, agentType string) (*AgentExecutionResponse, error) {
-	log.Printf("🤖 Executing Claude Code task: %s", agentType)
+	logging.Ctx(ctx).Info().Str("tool", "claude").Str("agent_type", agentType).Msg("Running tool task")
 
 	// Create temporary payload file
 	payloadFile, err := CreateTempPayloadFile(req.Payload)
@@ -153,7 +151,7 @@ func (t *ToolDetectionStrategy) executeClaudeTask(ctx context.Context, req Agent
 		"--context-file", payloadFile,
 	)
 
-	SetupCommand(cmd, req)
+	SetupCommand(ctx, cmd, req)
 
 	// Execute command
 	output, err := cmd.CombinedOutput()
@@ -178,13 +176,13 @@ func (t *ToolDetectionStrategy) executeClaudeTask(ctx context.Context, req Agent
 
 // executeCursorTask executes using Cursor
 func (t *ToolDetectionStrategy) executeCursorTask(ctx context.Context, req AgentExecutionRequest) (*AgentExecutionResponse, error) {
-	log.Printf("📝 Executing Cursor task")
+	logging.Ctx(ctx).Info().Str("tool", "cursor").Msg("Running tool task")
 
 	cursorTool := t.toolSet.Available["cursor"]
 
 	// Create a basic cursor command (this would need to be customized based on Cursor's API)
 	cmd := exec.CommandContext(ctx, cursorTool.Path, "--wait", req.ProjectPath)
-	SetupCommand(cmd, req)
+	SetupCommand(ctx, cmd, req)
 
 	output, err := cmd.CombinedOutput()
 	if err != nil {
@@ -204,7 +202,7 @@ func (t *ToolDetectionStrategy) executeCursorTask(ctx context.Context, req Agent
 
 // executeGoDirectTask executes Go tasks directly
 func (t *ToolDetectionStrategy) executeGoDirectTask(ctx context.Context, req AgentExecutionRequest) (*AgentExecutionResponse, error) {
-	log.Printf("🐹 Executing Go direct task")
+	logging.Ctx(ctx).Info().Str("tool", "go").Msg("Running tool task")
 
 	var commands []string
 	var artifacts []string
@@ -237,7 +235,7 @@ func (t *ToolDetectionStrategy) executeGoDirectTask(ctx context.Context, req Age
 	for _, cmdStr := range commands {
 		parts := strings.Fields(cmdStr)
 		cmd := exec.CommandContext(ctx, goTool.Path, parts[1:]...)
-		SetupCommand(cmd, req)
+		SetupCommand(ctx, cmd, req)
 
 		cmdOutput, err := cmd.CombinedOutput()
 		output.WriteString(fmt.Sprintf("$ %s\n", cmdStr))
@@ -264,7 +262,7 @@ func (t *ToolDetectionStrategy) executeGoDirectTask(ctx context.Context, req Age
 func (t *ToolDetectionStrategy) executeGoTest(ctx context.Context, req AgentExecutionRequest) (*AgentExecutionResponse, error) {
 	goTool := t.toolSet.Available["go"]
 	cmd := exec.CommandContext(ctx, goTool.Path, "test", "-v", "./...")
-	SetupCommand(cmd, req)
+	SetupCommand(ctx, cmd, req)
 
 	output, err := cmd.CombinedOutput()
 	success := err == nil
@@ -286,7 +284,7 @@ func (t *ToolDetectionStrategy) executeGoTest(ctx context.Context, req AgentExec
 func (t *ToolDetectionStrategy) executeNpmTest(ctx context.Context, req AgentExecutionRequest) (*AgentExecutionResponse, error) {
 	npmTool := t.toolSet.Available["npm"]
 	cmd := exec.CommandContext(ctx, npmTool.Path, "test")
-	SetupCommand(cmd, req)
+	SetupCommand(ctx, cmd, req)
 
 	output, err := cmd.CombinedOutput()
 	success := err == nil
@@ -346,7 +344,7 @@ func (t *ToolDetectionStrategy) executeDockerTask(ctx context.Context, req Agent
 	for _, cmdStr := range commands {
 		parts := strings.Fields(cmdStr)
 		cmd := exec.CommandContext(ctx, dockerTool.Path, parts[1:]...)
-		SetupCommand(cmd, req)
+		SetupCommand(ctx, cmd, req)
 
 		cmdOutput, err := cmd.CombinedOutput()
 		output.WriteString(fmt.Sprintf("$ %s\n", cmdStr))
@@ -388,7 +386,7 @@ func (t *ToolDetectionStrategy) executeNodeTask(ctx context.Context, req AgentEx
 	}
 
 	cmd := exec.CommandContext(ctx, tool.Path, "run", "build")
-	SetupCommand(cmd, req)
+	SetupCommand(ctx, cmd, req)
 
 	output, err := cmd.CombinedOutput()
 	success := err == nil
@@ -2,6 +2,8 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
 	"strconv"
 	"strings"
@@ -9,6 +11,7 @@ import (
 	"github.com/vot3k/agent-handoff/agent-manager/internal/middleware"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/models"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/service"
+	"github.com/vot3k/agent-handoff/handoff"
 )
 
 // HandoffHandler handles HTTP requests for handoff operations
@@ -26,14 +29,45 @@ func NewHandoffHandler(service service.HandoffServiceInterface) *HandoffHandler
 // CreateHandoff handles POST /api/v1/handoffs
 func (h *HandoffHandler) CreateHandoff(w http.ResponseWriter, r *http.Request) {
 	var req models.CreateHandoffRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeError(w, r, http.StatusBadRequest, "Invalid JSON payload", err)
+	if err := h.decodeRequest(r, &req); err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			h.writeError(w, r, http.StatusRequestEntityTooLarge, "Request body too large", err)
+		} else {
+			h.writeError(w, r, http.StatusBadRequest, "Invalid "+requestFormatName(r)+" payload", err)
+		}
 		return
 	}
 
-	handoff, err := h.service.CreateHandoff(r.Context(), &req)
+	// The header and body field name the same key; refuse requests naming two
+	if key := r.Header.Get("Idempotency-Key"); key != "" {
+		if req.IdempotencyKey != "" && req.IdempotencyKey != key {
+			h.writeError(w, r, http.StatusBadRequest, "Idempotency-Key header does not match idempotency_key", nil)
+			return
+		}
+		req.IdempotencyKey = key
+	}
+
+	// Continue the caller's trace when it sent a traceparent header
+	ctx := r.Context()
+	if tc, err := handoff.ParseTraceparent(r.Header.Get(handoff.TraceparentHeader)); err == nil {
+		ctx = handoff.ContextWithTrace(ctx, tc)
+	}
+
+	created, err := h.service.CreateHandoff(ctx, &req)
+	if errors.Is(err, handoff.ErrDuplicateHandoff) && created != nil {
+		// A retry of an earlier request: return the handoff it created
+		w.Header().Set("Idempotent-Replayed", "true")
+		h.writeResponse(w, r, http.StatusOK, created)
+		return
+	}
 	if err != nil {
-		if strings.Contains(err.Error(), "validation failed") {
+		var validationErr *handoff.ValidationError
+		if errors.As(err, &validationErr) {
+			h.writeValidationError(w, r, validationErr)
+		} else if errors.Is(err, handoff.ErrSignatureMissing) || errors.Is(err, handoff.ErrSignatureInvalid) {
+			h.writeError(w, r, http.StatusUnauthorized, "Request signature rejected", err)
+		} else if strings.Contains(err.Error(), "validation failed") {
 			h.writeError(w, r, http.StatusBadRequest, "Validation failed", err)
 		} else {
 			h.writeError(w, r, http.StatusInternalServerError, "Failed to create handoff", err)
@@ -41,9 +75,7 @@ func (h *HandoffHandler) CreateHandoff(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(handoff)
+	h.writeResponse(w, r, http.StatusCreated, created)
 }
 
 // GetHandoff handles GET /api/v1/handoffs/{id}
@@ -64,8 +96,28 @@ func (h *HandoffHandler) GetHandoff(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(handoff)
+	h.writeResponse(w, r, http.StatusOK, handoff)
+}
+
+// GetOffloadedField handles GET /api/v1/handoffs/{id}/offloaded/{field}
+func (h *HandoffHandler) GetOffloadedField(w http.ResponseWriter, r *http.Request) {
+	handoffID, field := r.PathValue("id"), r.PathValue("field")
+	if handoffID == "" || field == "" {
+		h.writeError(w, r, http.StatusBadRequest, "Missing handoff ID or field", nil)
+		return
+	}
+
+	value, err := h.service.GetOffloadedField(r.Context(), handoffID, field)
+	if err != nil {
+		if strings.Contains(err.Error(), "not found") {
+			h.writeError(w, r, http.StatusNotFound, "Field not found", err)
+		} else {
+			h.writeError(w, r, http.StatusInternalServerError, "Failed to load field", err)
+		}
+		return
+	}
+
+	h.writeResponse(w, r, http.StatusOK, value)
 }
 
 // ListHandoffs handles GET /api/v1/handoffs
@@ -90,8 +142,7 @@ func (h *HandoffHandler) ListHandoffs(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	h.writeResponse(w, r, http.StatusOK, response)
 }
 
 // UpdateStatus handles PUT /api/v1/handoffs/{id}/status
@@ -103,8 +154,8 @@ func (h *HandoffHandler) UpdateStatus(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var req models.UpdateStatusRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		h.writeError(w, r, http.StatusBadRequest, "Invalid JSON payload", err)
+	if err := h.decodeRequest(r, &req); err != nil {
+		h.writeError(w, r, http.StatusBadRequest, "Invalid "+requestFormatName(r)+" payload", err)
 		return
 	}
 
@@ -132,8 +183,7 @@ func (h *HandoffHandler) ListQueues(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	h.writeResponse(w, r, http.StatusOK, map[string]interface{}{
 		"queues": queues,
 		"count":  len(queues),
 	})
@@ -153,13 +203,61 @@ func (h *HandoffHandler) GetQueueDepth(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
+	h.writeResponse(w, r, http.StatusOK, map[string]interface{}{
 		"queue_name": queueName,
 		"depth":      depth,
 	})
 }
 
+// requestFormat returns the format of the request body: YAML when the
+// Content-Type says so, otherwise JSON
+func requestFormat(r *http.Request) handoff.Format {
+	if format, ok := handoff.FormatForContentType(r.Header.Get("Content-Type")); ok {
+		return format
+	}
+	return handoff.FormatJSON
+}
+
+// requestFormatName names the request format in error messages
+func requestFormatName(r *http.Request) string {
+	return strings.ToUpper(string(requestFormat(r)))
+}
+
+// decodeRequest decodes a JSON or YAML request body into v. YAML is converted
+// to JSON first so both formats are validated the same way.
+func (h *HandoffHandler) decodeRequest(r *http.Request, v interface{}) error {
+	if requestFormat(r) != handoff.FormatYAML {
+		return json.NewDecoder(r.Body).Decode(v)
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		return err
+	}
+	return handoff.Unmarshal(body, handoff.FormatYAML, v)
+}
+
+// writeResponse writes v as JSON, or as YAML when the Accept header prefers it
+func (h *HandoffHandler) writeResponse(w http.ResponseWriter, r *http.Request, statusCode int, v interface{}) {
+	format := handoff.NegotiateFormat(r.Header.Get("Accept"))
+	w.Header().Set("Content-Type", format.ContentType())
+	w.Header().Add("Vary", "Accept")
+
+	if format != handoff.FormatYAML {
+		w.WriteHeader(statusCode)
+		json.NewEncoder(w).Encode(v)
+		return
+	}
+	data, err := handoff.Marshal(v, handoff.FormatYAML)
+	if err != nil {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusInternalServerError)
+		json.NewEncoder(w).Encode(map[string]interface{}{"error": "Failed to encode response", "details": err.Error()})
+		return
+	}
+	w.WriteHeader(statusCode)
+	w.Write(data)
+}
+
 // writeError writes an error response in a consistent format
 func (h *HandoffHandler) writeError(w http.ResponseWriter, r *http.Request, statusCode int, message string, err error) {
 	requestID := middleware.GetRequestID(r.Context())
@@ -174,7 +272,18 @@ func (h *HandoffHandler) writeError(w http.ResponseWriter, r *http.Request, stat
 		response["details"] = err.Error()
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
-	json.NewEncoder(w).Encode(response)
+	h.writeResponse(w, r, statusCode, response)
+}
+
+// writeValidationError writes a 422 response listing every validation violation
+func (h *HandoffHandler) writeValidationError(w http.ResponseWriter, r *http.Request, err *handoff.ValidationError) {
+	statusCode := http.StatusUnprocessableEntity
+
+	h.writeResponse(w, r, statusCode, map[string]interface{}{
+		"error":      "Validation failed",
+		"request_id": middleware.GetRequestID(r.Context()),
+		"status":     statusCode,
+		"details":    err.Error(),
+		"violations": err.Report.Violations,
+	})
 }
@@ -7,15 +7,20 @@ import (
 	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/vot3k/agent-handoff/agent-manager/internal/middleware"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/models"
+	"github.com/vot3k/agent-handoff/handoff"
 )
 
 // MockHandoffService is a mock implementation of the handoff service for testing
 type MockHandoffService struct {
-	handoffs map[string]*models.Handoff
+	handoffs  map[string]*models.Handoff
+	createErr error
 }
 
 func NewMockHandoffService() *MockHandoffService {
@@ -28,6 +33,14 @@ func (m *MockHandoffService) CreateHandoff(ctx context.Context, req *models.Crea
 	if err := req.Validate(); err != nil {
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
+	if m.createErr != nil {
+		return nil, m.createErr
+	}
+	for _, existing := range m.handoffs {
+		if req.IdempotencyKey != "" && existing.Metadata.IdempotencyKey == req.IdempotencyKey {
+			return existing, fmt.Errorf("%w: %s", handoff.ErrDuplicateHandoff, existing.Metadata.HandoffID)
+		}
+	}
 
 	now := time.Now()
 	handoff := &models.Handoff{
@@ -39,6 +52,8 @@ func (m *MockHandoffService) CreateHandoff(ctx context.Context, req *models.Crea
 			TaskContext: req.TaskContext,
 			Priority:    req.Priority,
 			HandoffID:   "test-handoff-123",
+
+			IdempotencyKey: req.IdempotencyKey,
 		},
 		Content: models.HandoffContent{
 			Summary:          req.Summary,
@@ -63,6 +78,17 @@ func (m *MockHandoffService) GetHandoff(ctx context.Context, handoffID string) (
 	return nil, fmt.Errorf("handoff not found: %s", handoffID)
 }
 
+func (m *MockHandoffService) GetOffloadedField(ctx context.Context, handoffID, field string) (interface{}, error) {
+	handoff, err := m.GetHandoff(ctx, handoffID)
+	if err != nil {
+		return nil, err
+	}
+	if value, exists := handoff.Content.TechnicalDetails[field]; exists {
+		return value, nil
+	}
+	return nil, fmt.Errorf("technical_details field %s not found", field)
+}
+
 func (m *MockHandoffService) ListHandoffs(ctx context.Context, projectName string, page, pageSize int) (*models.HandoffListResponse, error) {
 	var handoffs []models.Handoff
 	for _, handoff := range m.handoffs {
@@ -187,6 +213,57 @@ func TestHandoffHandler_CreateHandoff(t *testing.T) {
 	}
 }
 
+func TestHandoffHandler_CreateHandoffValidationReport(t *testing.T) {
+	validator := handoff.NewHandoffValidator()
+	report := validator.CheckPolicy(&handoff.Handoff{
+		Metadata: handoff.Metadata{FromAgent: "api-expert", ToAgent: "golang-expert"},
+		Content: handoff.Content{
+			Summary:          "Short",
+			TechnicalDetails: map[string]interface{}{"test_coverage": 120.0, "handlers": []interface{}{"user.go", 7.0}},
+		},
+	})
+
+	mockService := NewMockHandoffService()
+	mockService.createErr = fmt.Errorf("failed to create handoff: %w", report.Err())
+	handler := NewHandoffHandler(mockService)
+
+	payload, _ := json.Marshal(models.CreateHandoffRequest{
+		ProjectName: "test-project",
+		FromAgent:   "api-expert",
+		ToAgent:     "golang-expert",
+		Summary:     "Short",
+	})
+	rr := httptest.NewRecorder()
+	handler.CreateHandoff(rr, httptest.NewRequest("POST", "/api/v1/handoffs", bytes.NewReader(payload)))
+
+	if rr.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, rr.Code)
+	}
+
+	var response struct {
+		Violations []handoff.ValidationViolation `json:"violations"`
+	}
+	if err := json.NewDecoder(rr.Body).Decode(&response); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+
+	paths := make(map[string]handoff.ValidationCode)
+	for _, violation := range response.Violations {
+		paths[violation.Path] = violation.Code
+	}
+	expected := map[string]handoff.ValidationCode{
+		"content.summary":                         handoff.ValidationTooShort,
+		"content.requirements":                    handoff.ValidationTooFew,
+		"content.technical_details.test_coverage": handoff.ValidationOutOfRange,
+		"content.technical_details.handlers[1]":   handoff.ValidationInvalidType,
+	}
+	for path, code := range expected {
+		if paths[path] != code {
+			t.Errorf("expected %s violation at %s, got %v", code, path, response.Violations)
+		}
+	}
+}
+
 func TestHandoffHandler_GetHandoff(t *testing.T) {
 	mockService := NewMockHandoffService()
 	handler := NewHandoffHandler(mockService)
@@ -331,3 +408,206 @@ func TestHandoffHandler_UpdateStatus(t *testing.T) {
 		})
 	}
 }
+
+func TestHandoffHandler_CreateHandoffTooLarge(t *testing.T) {
+	handler := middleware.MaxBodySize(256)(http.HandlerFunc(NewHandoffHandler(NewMockHandoffService()).CreateHandoff))
+
+	payload, _ := json.Marshal(models.CreateHandoffRequest{
+		ProjectName: "test-project",
+		FromAgent:   "api-expert",
+		ToAgent:     "golang-expert",
+		Summary:     strings.Repeat("Implement the invoice API. ", 20),
+	})
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest("POST", "/api/v1/handoffs", bytes.NewReader(payload)))
+
+	if rr.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected status %d, got %d", http.StatusRequestEntityTooLarge, rr.Code)
+	}
+}
+
+func TestHandoffHandler_GetOffloadedField(t *testing.T) {
+	mockService := NewMockHandoffService()
+	handler := NewHandoffHandler(mockService)
+
+	created, _ := mockService.CreateHandoff(context.Background(), &models.CreateHandoffRequest{
+		ProjectName:      "test-project",
+		FromAgent:        "api-expert",
+		ToAgent:          "golang-expert",
+		Summary:          "Test handoff",
+		TechnicalDetails: map[string]interface{}{"schema": "CREATE TABLE invoices (id INT);"},
+	})
+
+	tests := []struct {
+		field      string
+		wantStatus int
+	}{
+		{"schema", http.StatusOK},
+		{"missing", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/handoffs/"+created.Metadata.HandoffID+"/offloaded/"+tt.field, nil)
+			req.SetPathValue("id", created.Metadata.HandoffID)
+			req.SetPathValue("field", tt.field)
+			rr := httptest.NewRecorder()
+			handler.GetOffloadedField(rr, req)
+
+			if rr.Code != tt.wantStatus {
+				t.Fatalf("expected status %d, got %d", tt.wantStatus, rr.Code)
+			}
+			if tt.wantStatus == http.StatusOK && !strings.Contains(rr.Body.String(), "CREATE TABLE invoices") {
+				t.Errorf("expected the field value, got %s", rr.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandoffHandler_CreateHandoffIdempotencyKey(t *testing.T) {
+	handler := NewHandoffHandler(NewMockHandoffService())
+	payload, _ := json.Marshal(models.CreateHandoffRequest{
+		ProjectName:  "test-project",
+		FromAgent:    "api-expert",
+		ToAgent:      "golang-expert",
+		Summary:      "Implement invoice endpoints",
+		Requirements: []string{"REST API"},
+	})
+
+	create := func(key string, body []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "/api/v1/handoffs", bytes.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		rr := httptest.NewRecorder()
+		handler.CreateHandoff(rr, req)
+		return rr
+	}
+
+	if rr := create("invoice-42", payload); rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+
+	rr := create("invoice-42", payload)
+	if rr.Code != http.StatusOK || rr.Header().Get("Idempotent-Replayed") != "true" {
+		t.Errorf("expected a replayed 200, got %d with headers %v", rr.Code, rr.Header())
+	}
+	var replayed models.Handoff
+	if err := json.NewDecoder(rr.Body).Decode(&replayed); err != nil || replayed.Metadata.HandoffID != "test-handoff-123" {
+		t.Errorf("expected the existing handoff, got %v, %v", replayed.Metadata.HandoffID, err)
+	}
+
+	conflicting, _ := json.Marshal(models.CreateHandoffRequest{
+		ProjectName:    "test-project",
+		FromAgent:      "api-expert",
+		ToAgent:        "golang-expert",
+		Summary:        "Implement invoice endpoints",
+		IdempotencyKey: "invoice-43",
+	})
+	if rr := create("invoice-42", conflicting); rr.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for mismatched keys, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestHandoffHandler_CreateHandoffYAML(t *testing.T) {
+	handler := NewHandoffHandler(NewMockHandoffService())
+
+	body := `
+project_name: test-project
+from_agent: api-expert
+to_agent: golang-expert
+summary: Implement invoice endpoints
+requirements:
+  - REST API
+technical_details:
+  handlers: [CreateInvoice]
+`
+	req := httptest.NewRequest("POST", "/api/v1/handoffs", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/yaml")
+	req.Header.Set("Accept", "application/yaml")
+	rr := httptest.NewRecorder()
+	handler.CreateHandoff(rr, req)
+
+	if rr.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, rr.Code, rr.Body.String())
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/yaml" {
+		t.Errorf("expected a YAML response, got %s", ct)
+	}
+	var created models.Handoff
+	if err := handoff.Unmarshal(rr.Body.Bytes(), handoff.FormatYAML, &created); err != nil {
+		t.Fatalf("response is not YAML: %v\n%s", err, rr.Body.String())
+	}
+	if created.Content.Summary != "Implement invoice endpoints" || created.Content.TechnicalDetails["handlers"].([]interface{})[0] != "CreateInvoice" {
+		t.Errorf("unexpected handoff: %+v", created.Content)
+	}
+
+	// The same request as JSON is answered with the same handoff
+	req = httptest.NewRequest("GET", "/api/v1/handoffs/test-handoff-123", nil)
+	req.SetPathValue("id", "test-handoff-123")
+	rr = httptest.NewRecorder()
+	handler.GetHandoff(rr, req)
+	var fetched models.Handoff
+	if err := json.NewDecoder(rr.Body).Decode(&fetched); err != nil || !reflect.DeepEqual(fetched.Content, created.Content) {
+		t.Errorf("expected JSON and YAML to carry the same handoff, got %+v, %v", fetched.Content, err)
+	}
+
+	tests := []struct {
+		name       string
+		body       string
+		wantStatus int
+		wantError  string
+	}{
+		{"malformed YAML", "summary: [unclosed", http.StatusBadRequest, "Invalid YAML payload"},
+		{"fails validation like JSON", "project_name: test-project\nfrom_agent: api-expert\n", http.StatusBadRequest, "Validation failed"},
+		{"alias bomb", "a: &a [x, x, x, x, x, x, x, x]\nb: &b [*a, *a, *a, *a, *a, *a, *a, *a]\nc: &c [*b, *b, *b, *b, *b, *b, *b, *b]\n" +
+			"d: &d [*c, *c, *c, *c, *c, *c, *c, *c]\nsummary: [*d, *d, *d, *d, *d, *d, *d, *d]\n", http.StatusBadRequest, "Invalid YAML payload"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", "/api/v1/handoffs", strings.NewReader(tt.body))
+			req.Header.Set("Content-Type", "application/x-yaml")
+			rr := httptest.NewRecorder()
+			handler.CreateHandoff(rr, req)
+
+			var response map[string]interface{}
+			json.NewDecoder(rr.Body).Decode(&response)
+			if rr.Code != tt.wantStatus || response["error"] != tt.wantError {
+				t.Errorf("expected %d %q, got %d %v", tt.wantStatus, tt.wantError, rr.Code, response)
+			}
+		})
+	}
+}
+
+func TestHealthHandler_Ready(t *testing.T) {
+	redisUp := 1.0
+	checker := handoff.NewHealthChecker(handoff.HealthConfig{}, func(ctx context.Context) []handoff.HealthReading {
+		return []handoff.HealthReading{
+			{Component: handoff.HealthRedis, Value: redisUp},
+			{Component: handoff.HealthQueueBacklog, Value: 150},
+		}
+	})
+	handler := NewHealthHandler(checker)
+
+	ready := func() (int, map[string]interface{}) {
+		rec := httptest.NewRecorder()
+		handler.Ready(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+		var body map[string]interface{}
+		if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+			t.Fatalf("expected JSON, got %q: %v", rec.Body.String(), err)
+		}
+		return rec.Code, body
+	}
+
+	code, body := ready()
+	if code != http.StatusOK || body["status"] != "ready" || body["score"] != 92.5 {
+		t.Fatalf("expected ready with a score of 92.5, got %d: %v", code, body)
+	}
+	backlog := body["checks"].(map[string]interface{})[handoff.HealthQueueBacklog].(map[string]interface{})
+	if backlog["status"] != string(handoff.HealthWarn) || backlog["deduction"] != 7.5 {
+		t.Errorf("expected the backlog to cost 7.5 points, got %v", backlog)
+	}
+
+	// Redis is required, so losing it fails readiness despite a passing score
+	redisUp = 0
+	if code, body = ready(); code != http.StatusServiceUnavailable || body["status"] != "not ready" {
+		t.Errorf("expected not ready without Redis, got %d: %v", code, body)
+	}
+}
@@ -5,18 +5,19 @@ import (
 	"net/http"
 	"time"
 
-	"github.com/vot3k/agent-handoff/agent-manager/internal/repository"
+	"github.com/vot3k/agent-handoff/handoff"
 )
 
 // HealthHandler handles health check endpoints
 type HealthHandler struct {
-	redis *repository.RedisClient
+	checker *handoff.HealthChecker
 }
 
-// NewHealthHandler creates a new health handler
-func NewHealthHandler(redis *repository.RedisClient) *HealthHandler {
+// NewHealthHandler creates a new health handler whose readiness comes from
+// checker's report
+func NewHealthHandler(checker *handoff.HealthChecker) *HealthHandler {
 	return &HealthHandler{
-		redis: redis,
+		checker: checker,
 	}
 }
 
@@ -34,32 +35,29 @@ func (h *HealthHandler) Health(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(response)
 }
 
-// Ready handles GET /health/ready
+// Ready handles GET /health/ready. The service is ready when its health
+// score reaches the minimum and no required component is critical; each
+// component's reading and deduction is listed under checks.
 func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
-	checks := map[string]interface{}{
-		"redis": h.checkRedis(r),
-	}
+	report := h.checker.Report(r.Context())
 
-	allHealthy := true
-	for _, check := range checks {
-		if checkMap, ok := check.(map[string]interface{}); ok {
-			if status, exists := checkMap["status"]; exists && status != "ok" {
-				allHealthy = false
-				break
-			}
-		}
+	checks := make(map[string]handoff.HealthComponentReport, len(report.Components))
+	for _, component := range report.Components {
+		checks[component.Name] = component
 	}
 
 	status := "ready"
 	statusCode := http.StatusOK
-	if !allHealthy {
+	if !report.Ready {
 		status = "not ready"
 		statusCode = http.StatusServiceUnavailable
 	}
 
 	response := map[string]interface{}{
 		"status":    status,
-		"timestamp": time.Now().UTC(),
+		"timestamp": report.CheckedAt.UTC(),
+		"score":     report.Score,
+		"min_score": report.MinScore,
 		"checks":    checks,
 	}
 
@@ -67,23 +65,3 @@ func (h *HealthHandler) Ready(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(statusCode)
 	json.NewEncoder(w).Encode(response)
 }
-
-// checkRedis performs a health check on Redis connection
-func (h *HealthHandler) checkRedis(r *http.Request) map[string]interface{} {
-	start := time.Now()
-	err := h.redis.Ping(r.Context())
-	duration := time.Since(start)
-
-	if err != nil {
-		return map[string]interface{}{
-			"status":   "error",
-			"error":    err.Error(),
-			"duration": duration.String(),
-		}
-	}
-
-	return map[string]interface{}{
-		"status":   "ok",
-		"duration": duration.String(),
-	}
-}
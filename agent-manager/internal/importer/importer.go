@@ -0,0 +1,521 @@
+// Package importer migrates handoffs from the legacy file-based system into
+// Redis: YAML, JSON and Markdown handoff files, and the archive written by the
+// manager's dispatcher.
+package importer
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/vot3k/agent-handoff/agent-manager/internal/models"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/service"
+	"github.com/vot3k/agent-handoff/handoff"
+)
+
+// Mode selects what imported handoffs become
+type Mode string
+
+const (
+	ModeEnqueue Mode = "enqueue" // Queue the handoff for its to_agent again
+	ModeHistory Mode = "history" // Keep it as a completed (or failed) record
+)
+
+// ParseMode parses a --mode value
+func ParseMode(s string) (Mode, error) {
+	switch Mode(s) {
+	case ModeEnqueue, ModeHistory:
+		return Mode(s), nil
+	}
+	return "", fmt.Errorf("unknown import mode %q (expected enqueue or history)", s)
+}
+
+// Outcome is what happened to one file
+type Outcome string
+
+const (
+	OutcomeImported    Outcome = "imported"
+	OutcomeWouldImport Outcome = "would_import" // Dry run: every check passed
+	OutcomeExists      Outcome = "exists"       // The handoff ID is already stored
+	OutcomeInvalid     Outcome = "invalid"      // The file could not be parsed or failed validation
+	OutcomeFailed      Outcome = "failed"       // Storing the handoff failed
+)
+
+// Options configures an import
+type Options struct {
+	Mode    Mode
+	DryRun  bool
+	Project string // Project for files whose project cannot be derived
+}
+
+// Importer stores migrated handoffs
+type Importer interface {
+	ImportHandoff(ctx context.Context, h *models.Handoff, opts service.ImportOptions) error
+}
+
+// FileResult reports one handoff file
+type FileResult struct {
+	Path       string                        `json:"path"`
+	Outcome    Outcome                       `json:"outcome"`
+	HandoffID  string                        `json:"handoff_id,omitempty"`
+	FromAgent  string                        `json:"from_agent,omitempty"`
+	ToAgent    string                        `json:"to_agent,omitempty"`
+	Notes      []string                      `json:"notes,omitempty"` // Migrations applied to the legacy handoff
+	Error      string                        `json:"error,omitempty"`
+	Violations []handoff.ValidationViolation `json:"violations,omitempty"`
+}
+
+// Report summarises an import
+type Report struct {
+	Root    string         `json:"root"`
+	Mode    Mode           `json:"mode"`
+	DryRun  bool           `json:"dry_run"`
+	Files   []FileResult   `json:"files"`
+	Totals  map[string]int `json:"totals"`
+	Skipped int            `json:"skipped"` // Files that are not handoffs by extension
+}
+
+// Failed reports whether any file was invalid or could not be stored
+func (r *Report) Failed() bool {
+	return r.Totals[string(OutcomeInvalid)] > 0 || r.Totals[string(OutcomeFailed)] > 0
+}
+
+// Run imports every handoff file under root, in path order. Errors for
+// individual files are recorded in the report; the returned error is for
+// walking the directory.
+func Run(ctx context.Context, importer Importer, root string, opts Options) (*Report, error) {
+	report := &Report{Root: root, Mode: opts.Mode, DryRun: opts.DryRun, Totals: make(map[string]int)}
+
+	var paths []string
+	err := filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() {
+			return nil
+		}
+		if fileFormat(path) == "" {
+			report.Skipped++
+			return nil
+		}
+		paths = append(paths, path)
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("failed to walk %s: %w", root, err)
+	}
+	sort.Strings(paths)
+
+	for _, path := range paths {
+		result := importFile(ctx, importer, root, path, opts)
+		report.Files = append(report.Files, result)
+		report.Totals[string(result.Outcome)]++
+	}
+	return report, nil
+}
+
+func importFile(ctx context.Context, importer Importer, root, path string, opts Options) FileResult {
+	result := FileResult{Path: path}
+	if rel, err := filepath.Rel(root, path); err == nil {
+		result.Path = rel
+	}
+
+	h, notes, err := LoadFile(path, opts)
+	result.Notes = notes
+	if err != nil {
+		result.Outcome = OutcomeInvalid
+		result.Error = err.Error()
+		return result
+	}
+	result.HandoffID = h.Metadata.HandoffID
+	result.FromAgent = h.Metadata.FromAgent
+	result.ToAgent = h.Metadata.ToAgent
+
+	err = importer.ImportHandoff(ctx, h, service.ImportOptions{History: opts.Mode == ModeHistory, DryRun: opts.DryRun})
+	var validationErr *handoff.ValidationError
+	switch {
+	case err == nil && opts.DryRun:
+		result.Outcome = OutcomeWouldImport
+	case err == nil:
+		result.Outcome = OutcomeImported
+	case errors.Is(err, service.ErrHandoffExists):
+		result.Outcome = OutcomeExists
+	case errors.As(err, &validationErr):
+		result.Outcome = OutcomeInvalid
+		result.Error = "validation failed"
+		result.Violations = validationErr.Report.Violations
+	case errors.Is(err, service.ErrInvalidHandoff):
+		result.Outcome = OutcomeInvalid
+		result.Error = err.Error()
+	default:
+		result.Outcome = OutcomeFailed
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// fileFormat returns how a file is parsed, or "" for files that are not handoffs
+func fileFormat(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return "json"
+	case ".yaml", ".yml":
+		return "yaml"
+	case ".md", ".markdown":
+		return "markdown"
+	}
+	return ""
+}
+
+// legacyHandoff is the shape legacy files are decoded into. Fields are loose
+// where older writers were: timestamps may be in several layouts and
+// priorities use the old high|medium|low scale.
+type legacyHandoff struct {
+	Metadata struct {
+		ProjectName string `json:"project_name"`
+		FromAgent   string `json:"from_agent"`
+		ToAgent     string `json:"to_agent"`
+		Timestamp   string `json:"timestamp"`
+		TaskContext string `json:"task_context"`
+		Priority    string `json:"priority"`
+		HandoffID   string `json:"handoff_id"`
+	} `json:"metadata"`
+	Content models.HandoffContent `json:"content"`
+	Status  string                `json:"status"`
+}
+
+// LoadFile parses a legacy handoff file and migrates it to the current model.
+// The notes describe every value that was derived or changed.
+func LoadFile(path string, opts Options) (*models.Handoff, []string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	// The archive layout may start above the import root
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return Migrate(path, data, info.ModTime(), opts)
+}
+
+// Migrate converts the content of a legacy handoff file at path to the
+// current model. Missing values are
+// derived from the path where the legacy layouts encode them, from the
+// archive layout archive/<project>/<date>/<time>-<to_agent>-<id>.<ext> and
+// the handoff file name <from_agent>-to-<to_agent>.<ext>, then from opts and
+// the file's modification time.
+func Migrate(path string, data []byte, modTime time.Time, opts Options) (*models.Handoff, []string, error) {
+	var notes []string
+	note := func(format string, args ...interface{}) {
+		notes = append(notes, fmt.Sprintf(format, args...))
+	}
+
+	document, body, err := extractDocument(path, data)
+	if err != nil {
+		return nil, notes, err
+	}
+	document, err = unwrapDocument(document)
+	if err != nil {
+		return nil, notes, err
+	}
+
+	var legacy legacyHandoff
+	if err := json.Unmarshal(document, &legacy); err != nil {
+		return nil, notes, fmt.Errorf("not a handoff: %w", err)
+	}
+	if legacy.Metadata.FromAgent == "" && legacy.Metadata.ToAgent == "" && legacy.Content.Summary == "" && body == "" {
+		return nil, notes, fmt.Errorf("not a handoff: no metadata or content")
+	}
+
+	// Archived handoffs were sealed; a mismatch means the file was edited or
+	// written before the current checksum format
+	if err := handoff.VerifyPayloadChecksum(document); err != nil && !errors.Is(err, handoff.ErrChecksumMissing) {
+		note("stored checksum does not verify (%v); the handoff is resealed", err)
+	}
+
+	archive := parseArchivePath(path)
+	h := &models.Handoff{
+		Metadata: models.HandoffMetadata{
+			ProjectName: legacy.Metadata.ProjectName,
+			FromAgent:   legacy.Metadata.FromAgent,
+			ToAgent:     legacy.Metadata.ToAgent,
+			TaskContext: legacy.Metadata.TaskContext,
+			HandoffID:   legacy.Metadata.HandoffID,
+		},
+		Content: legacy.Content,
+	}
+
+	if h.Metadata.ProjectName == "" {
+		switch {
+		case archive.project != "":
+			h.Metadata.ProjectName = archive.project
+			note("project_name %q taken from the archive path", archive.project)
+		case opts.Project != "":
+			h.Metadata.ProjectName = opts.Project
+			note("project_name %q taken from the import options", opts.Project)
+		default:
+			return nil, notes, fmt.Errorf("project_name is missing and cannot be derived; pass a default project")
+		}
+	}
+
+	from, to := agentsFromFileName(path)
+	if h.Metadata.FromAgent == "" && from != "" {
+		h.Metadata.FromAgent = from
+		note("from_agent %q taken from the file name", from)
+	}
+	if h.Metadata.ToAgent == "" {
+		switch {
+		case to != "":
+			h.Metadata.ToAgent = to
+			note("to_agent %q taken from the file name", to)
+		case archive.toAgent != "":
+			h.Metadata.ToAgent = archive.toAgent
+			note("to_agent %q taken from the archive path", archive.toAgent)
+		}
+	}
+
+	if h.Content.Summary == "" && body != "" {
+		h.Content.Summary = markdownSummary(body)
+		if h.Content.Summary != "" {
+			note("summary taken from the Markdown body")
+		}
+	}
+
+	priority, err := migratePriority(legacy.Metadata.Priority)
+	if err != nil {
+		return nil, notes, err
+	}
+	if legacy.Metadata.Priority != "" && string(priority) != legacy.Metadata.Priority {
+		note("priority %q migrated to %q", legacy.Metadata.Priority, priority)
+	}
+	h.Metadata.Priority = priority
+
+	timestamp, ok := parseTimestamp(legacy.Metadata.Timestamp)
+	switch {
+	case ok:
+	case !archive.timestamp.IsZero():
+		timestamp = archive.timestamp
+		note("timestamp taken from the archive file name")
+	default:
+		timestamp = modTime.UTC()
+		note("timestamp taken from the file modification time")
+	}
+	if legacy.Metadata.Timestamp != "" && !ok {
+		note("timestamp %q could not be parsed", legacy.Metadata.Timestamp)
+	}
+	h.Metadata.Timestamp = timestamp
+	h.CreatedAt = timestamp
+	h.UpdatedAt = timestamp
+
+	if h.Metadata.HandoffID == "" {
+		// Derived from the content so importing the same file twice is detected
+		h.Metadata.HandoffID = uuid.NewSHA1(uuid.NameSpaceURL, data).String()
+		note("handoff_id assigned from the file content")
+	}
+
+	h.Status = migrateStatus(legacy.Status, opts.Mode)
+	if opts.Mode == ModeHistory && string(h.Status) != legacy.Status {
+		if legacy.Status == "" {
+			note("status recorded as %q", h.Status)
+		} else {
+			note("status %q recorded as %q", legacy.Status, h.Status)
+		}
+	}
+
+	if h.Content.Requirements == nil {
+		h.Content.Requirements = []string{}
+	}
+	if h.Content.NextSteps == nil {
+		h.Content.NextSteps = []string{}
+	}
+	return h, notes, nil
+}
+
+// extractDocument returns the handoff document of a file as JSON, and for
+// Markdown the text around it
+func extractDocument(path string, data []byte) ([]byte, string, error) {
+	switch fileFormat(path) {
+	case "json":
+		return data, "", nil
+	case "yaml":
+		document, err := handoff.YAMLToJSON(data)
+		return document, "", err
+	case "markdown":
+		block, lang, body := markdownBlock(string(data))
+		if block == "" {
+			return []byte("{}"), body, nil
+		}
+		if lang == "json" {
+			return []byte(block), body, nil
+		}
+		document, err := handoff.YAMLToJSON([]byte(block))
+		return document, body, err
+	}
+	return nil, "", fmt.Errorf("unsupported file type %s", filepath.Ext(path))
+}
+
+// unwrapDocument accepts handoffs stored bare, under a "handoff" key, or as a
+// queue message under "payload"
+func unwrapDocument(document []byte) ([]byte, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(document, &fields); err != nil {
+		return nil, fmt.Errorf("not a handoff: %w", err)
+	}
+	if _, ok := fields["metadata"]; ok {
+		return document, nil
+	}
+	for _, key := range []string{"handoff", "payload"} {
+		if inner, ok := fields[key]; ok && bytes.HasPrefix(bytes.TrimSpace(inner), []byte("{")) {
+			return unwrapDocument(inner)
+		}
+	}
+	return document, nil
+}
+
+var fencePattern = regexp.MustCompile("(?ms)^```(yaml|yml|json)\\s*\\n(.*?)^```")
+
+// markdownBlock returns a Markdown handoff's YAML front matter, or failing
+// that its first fenced YAML or JSON block mentioning metadata, with the rest
+// of the text
+func markdownBlock(text string) (block, lang, body string) {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	if strings.HasPrefix(text, "---\n") {
+		if end := strings.Index(text[4:], "\n---"); end >= 0 {
+			block = text[4 : 4+end+1]
+			rest := text[4+end+4:]
+			if newline := strings.IndexByte(rest, '\n'); newline >= 0 {
+				rest = rest[newline+1:]
+			} else {
+				rest = ""
+			}
+			return block, "yaml", rest
+		}
+	}
+
+	for _, match := range fencePattern.FindAllStringSubmatchIndex(text, -1) {
+		content := text[match[4]:match[5]]
+		if !strings.Contains(content, "metadata") {
+			continue
+		}
+		lang = text[match[2]:match[3]]
+		if lang == "yml" {
+			lang = "yaml"
+		}
+		return content, lang, text[:match[0]] + text[match[1]:]
+	}
+	return "", "", text
+}
+
+// markdownSummary returns the first heading or line of a Markdown body
+func markdownSummary(body string) string {
+	for _, line := range strings.Split(body, "\n") {
+		line = strings.TrimSpace(strings.TrimLeft(strings.TrimSpace(line), "#"))
+		if line != "" && line != "---" {
+			return line
+		}
+	}
+	return ""
+}
+
+// archivePath holds what the archive layout encodes in a path
+type archivePath struct {
+	project   string
+	toAgent   string
+	timestamp time.Time
+}
+
+var archiveFilePattern = regexp.MustCompile(`^(\d{8}T\d{6}Z)-(.+)-([0-9a-zA-Z]{1,8})$`)
+
+// parseArchivePath parses paths of the form
+// [...]archive/<project>/<YYYY-MM-DD>/<YYYYMMDDTHHMMSSZ>-<to_agent>-<id prefix>.<ext>
+func parseArchivePath(path string) archivePath {
+	parts := strings.Split(filepath.ToSlash(path), "/")
+	n := len(parts)
+	if n < 3 {
+		return archivePath{}
+	}
+	if _, err := time.Parse("2006-01-02", parts[n-2]); err != nil {
+		return archivePath{}
+	}
+	name := strings.TrimSuffix(parts[n-1], filepath.Ext(parts[n-1]))
+	match := archiveFilePattern.FindStringSubmatch(name)
+	if match == nil {
+		return archivePath{}
+	}
+	timestamp, err := time.Parse("20060102T150405Z", match[1])
+	if err != nil {
+		return archivePath{}
+	}
+	return archivePath{project: parts[n-3], toAgent: match[2], timestamp: timestamp}
+}
+
+// agentsFromFileName parses handoff file names of the form <from>-to-<to>
+func agentsFromFileName(path string) (string, string) {
+	name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	from, to, ok := strings.Cut(name, "-to-")
+	if !ok || from == "" || to == "" {
+		return "", ""
+	}
+	return from, to
+}
+
+var timestampLayouts = []string{
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02",
+}
+
+func parseTimestamp(value string) (time.Time, bool) {
+	for _, layout := range timestampLayouts {
+		if t, err := time.Parse(layout, strings.TrimSpace(value)); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// migratePriority maps the legacy high|medium|low scale, and the handoff
+// package's critical, onto the current priorities
+func migratePriority(value string) (models.Priority, error) {
+	switch strings.ToLower(strings.TrimSpace(value)) {
+	case "", "medium", "normal":
+		return models.PriorityNormal, nil
+	case "low":
+		return models.PriorityLow, nil
+	case "high":
+		return models.PriorityHigh, nil
+	case "critical", "urgent":
+		return models.PriorityUrgent, nil
+	}
+	return "", fmt.Errorf("unknown priority %q", value)
+}
+
+// migrateStatus returns the status an imported handoff is stored with: pending
+// when enqueued, otherwise its final status, assuming completed since the
+// archive only kept successful handoffs
+func migrateStatus(value string, mode Mode) models.HandoffStatus {
+	if mode != ModeHistory {
+		return models.StatusPending
+	}
+	switch status := models.HandoffStatus(strings.ToLower(value)); status {
+	case models.StatusCompleted, models.StatusFailed, models.StatusCancelled:
+		return status
+	}
+	return models.StatusCompleted
+}
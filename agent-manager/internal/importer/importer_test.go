@@ -0,0 +1,283 @@
+package importer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vot3k/agent-handoff/agent-manager/internal/models"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/service"
+	"github.com/vot3k/agent-handoff/handoff"
+)
+
+const archivedHandoff = `{
+  "metadata": {
+    "project_name": "",
+    "from_agent": "api-expert",
+    "to_agent": "golang-expert",
+    "timestamp": "2024-05-01T10:00:00Z",
+    "task_context": "invoices",
+    "priority": "high",
+    "handoff_id": "abcd1234-5678-90ab-cdef-1234567890ab"
+  },
+  "content": {
+    "summary": "Implement invoice endpoints",
+    "requirements": ["REST API"],
+    "artifacts": {"created": ["internal/invoice.go"]},
+    "technical_details": {"handlers": ["CreateInvoice"]},
+    "next_steps": []
+  },
+  "status": "processing"
+}`
+
+const legacyMarkdown = "# Deployment complete\n\n" +
+	"```yaml\n" +
+	"---\n" +
+	"metadata:\n" +
+	"  from_agent: devops-expert\n" +
+	"  to_agent: project-manager\n" +
+	"  task_context: \"Production deployment of auth feature\"\n" +
+	"  priority: high\n" +
+	"content:\n" +
+	"  summary: \"Successfully deployed v2.1.0 with zero downtime\"\n" +
+	"  requirements: [\"Zero downtime\", \"Health checks\"]\n" +
+	"  artifacts:\n" +
+	"    created: [\".github/workflows/deploy-production.yml\"]\n" +
+	"  technical_details:\n" +
+	"    replicas: 3\n" +
+	"  next_steps: [\"Monitor 24h\"]\n" +
+	"---\n" +
+	"```\n"
+
+func TestMigrate(t *testing.T) {
+	modTime := time.Date(2023, 11, 2, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name    string
+		path    string
+		data    string
+		opts    Options
+		check   func(t *testing.T, h *models.Handoff)
+		notes   []string
+		wantErr string
+	}{
+		{
+			name: "archived handoff",
+			path: "/srv/archive/billing/2024-05-01/20240501T100000Z-golang-expert-abcd1234.json",
+			data: archivedHandoff,
+			opts: Options{Mode: ModeHistory},
+			check: func(t *testing.T, h *models.Handoff) {
+				if h.Metadata.ProjectName != "billing" || h.Metadata.HandoffID != "abcd1234-5678-90ab-cdef-1234567890ab" {
+					t.Errorf("unexpected metadata %+v", h.Metadata)
+				}
+				if h.Status != models.StatusCompleted || h.Metadata.Priority != models.PriorityHigh {
+					t.Errorf("expected a completed high priority record, got %s %s", h.Status, h.Metadata.Priority)
+				}
+				if !h.Metadata.Timestamp.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+					t.Errorf("expected the stored timestamp, got %s", h.Metadata.Timestamp)
+				}
+			},
+			notes: []string{`project_name "billing" taken from the archive path`, `status "processing" recorded as "completed"`},
+		},
+		{
+			name: "archived handoff enqueued",
+			path: "archive/billing/2024-05-01/20240501T100000Z-golang-expert-abcd1234.json",
+			data: archivedHandoff,
+			opts: Options{Mode: ModeEnqueue},
+			check: func(t *testing.T, h *models.Handoff) {
+				if h.Status != models.StatusPending {
+					t.Errorf("expected pending, got %s", h.Status)
+				}
+			},
+		},
+		{
+			name: "archived YAML without a timestamp",
+			path: "archive/billing/2024-05-01/20240501T100000Z-golang-expert-abcd1234.yaml",
+			data: "metadata:\n  from_agent: api-expert\ncontent:\n  summary: Implement invoice endpoints\n",
+			opts: Options{Mode: ModeHistory},
+			check: func(t *testing.T, h *models.Handoff) {
+				if h.Metadata.ToAgent != "golang-expert" || !h.Metadata.Timestamp.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) {
+					t.Errorf("expected agent and time from the archive name, got %+v", h.Metadata)
+				}
+			},
+			notes: []string{`to_agent "golang-expert" taken from the archive path`, "timestamp taken from the archive file name"},
+		},
+		{
+			name: "markdown handoff file",
+			path: ".claude/handoffs/devops-expert-to-project-manager.md",
+			data: legacyMarkdown,
+			opts: Options{Mode: ModeHistory, Project: "auth"},
+			check: func(t *testing.T, h *models.Handoff) {
+				if h.Metadata.ProjectName != "auth" || h.Metadata.FromAgent != "devops-expert" || h.Content.Summary != "Successfully deployed v2.1.0 with zero downtime" {
+					t.Errorf("unexpected handoff %+v", h)
+				}
+				if h.Content.Artifacts["created"][0] != ".github/workflows/deploy-production.yml" || h.Content.TechnicalDetails["replicas"] != 3.0 {
+					t.Errorf("unexpected content %+v", h.Content)
+				}
+				if !h.Metadata.Timestamp.Equal(modTime) || h.Metadata.HandoffID == "" {
+					t.Errorf("expected a derived timestamp and ID, got %+v", h.Metadata)
+				}
+			},
+			notes: []string{"timestamp taken from the file modification time", "handoff_id assigned from the file content"},
+		},
+		{
+			name: "markdown front matter",
+			path: "handoffs/architect-expert-to-golang-expert.md",
+			data: "---\nmetadata:\n  priority: medium\n  timestamp: 2024-03-04 09:30:00\n---\n\n## Implement the billing service\n\nDetails follow.\n",
+			opts: Options{Mode: ModeEnqueue, Project: "billing"},
+			check: func(t *testing.T, h *models.Handoff) {
+				if h.Metadata.FromAgent != "architect-expert" || h.Metadata.ToAgent != "golang-expert" {
+					t.Errorf("expected agents from the file name, got %+v", h.Metadata)
+				}
+				if h.Content.Summary != "Implement the billing service" || h.Metadata.Priority != models.PriorityNormal {
+					t.Errorf("unexpected handoff %+v", h)
+				}
+			},
+			notes: []string{"summary taken from the Markdown body", `priority "medium" migrated to "normal"`},
+		},
+		{
+			name:    "no project",
+			path:    "handoffs/api-expert-to-golang-expert.yaml",
+			data:    "content:\n  summary: Implement invoice endpoints\n",
+			opts:    Options{Mode: ModeEnqueue},
+			wantErr: "project_name is missing",
+		},
+		{
+			name:    "unknown priority",
+			path:    "handoffs/api-expert-to-golang-expert.yaml",
+			data:    "metadata:\n  priority: asap\ncontent:\n  summary: Implement invoice endpoints\n",
+			opts:    Options{Mode: ModeEnqueue, Project: "billing"},
+			wantErr: `unknown priority "asap"`,
+		},
+		{
+			name:    "not a handoff",
+			path:    "docs/notes.md",
+			data:    "",
+			opts:    Options{Mode: ModeEnqueue, Project: "billing"},
+			wantErr: "not a handoff",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, notes, err := Migrate(tt.path, []byte(tt.data), modTime, tt.opts)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Migrate failed: %v", err)
+			}
+			if err := h.Validate(); err != nil {
+				t.Errorf("expected a valid handoff, got %v", err)
+			}
+			tt.check(t, h)
+			for _, want := range tt.notes {
+				if !containsNote(notes, want) {
+					t.Errorf("expected note %q, got %q", want, notes)
+				}
+			}
+		})
+	}
+}
+
+func containsNote(notes []string, want string) bool {
+	for _, note := range notes {
+		if note == want {
+			return true
+		}
+	}
+	return false
+}
+
+type fakeImporter struct {
+	imported map[string]service.ImportOptions
+	errs     map[string]error
+}
+
+func (f *fakeImporter) ImportHandoff(ctx context.Context, h *models.Handoff, opts service.ImportOptions) error {
+	if err := f.errs[h.Metadata.FromAgent]; err != nil {
+		return err
+	}
+	f.imported[h.Metadata.HandoffID] = opts
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"archive/billing/2024-05-01/20240501T100000Z-golang-expert-abcd1234.json": archivedHandoff,
+		"handoffs/devops-expert-to-project-manager.md":                            legacyMarkdown,
+		"handoffs/architect-expert-to-golang-expert.yaml":                         "content:\n  summary: Design the billing service\n",
+		"handoffs/test-expert-to-golang-expert.yml":                               "content:\n  summary: Fix flaky tests\n",
+		"handoffs/qa-expert-to-golang-expert.yaml":                                "content:\n  summary: Check the release\n",
+		"handoffs/ux-expert-to-golang-expert.yaml":                                "content:\n  summary: Review the mockups\n",
+		"handoffs/broken.json":                                                    "{",
+		"handoffs/README.txt":                                                     "not a handoff",
+	}
+	for name, content := range files {
+		path := filepath.Join(root, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	importer := &fakeImporter{
+		imported: map[string]service.ImportOptions{},
+		errs: map[string]error{
+			"architect-expert": fmt.Errorf("%w: existing", service.ErrHandoffExists),
+			"qa-expert":        fmt.Errorf("%w: summary is required", service.ErrInvalidHandoff),
+			"ux-expert":        fmt.Errorf("failed to import handoff: %w", errors.New("redis: connection refused")),
+			"test-expert": &handoff.ValidationError{Report: &handoff.HandoffValidationReport{Violations: []handoff.ValidationViolation{
+				{Path: "content.summary", Code: handoff.ValidationTooLong, Severity: handoff.SeverityError, Message: "summary is too long"},
+			}}},
+		},
+	}
+
+	report, err := Run(context.Background(), importer, root, Options{Mode: ModeHistory, DryRun: true, Project: "platform"})
+	if err != nil {
+		t.Fatalf("Run failed: %v", err)
+	}
+
+	outcomes := map[string]Outcome{}
+	for _, file := range report.Files {
+		outcomes[filepath.ToSlash(file.Path)] = file.Outcome
+	}
+	want := map[string]Outcome{
+		"archive/billing/2024-05-01/20240501T100000Z-golang-expert-abcd1234.json": OutcomeWouldImport,
+		"handoffs/devops-expert-to-project-manager.md":                            OutcomeWouldImport,
+		"handoffs/architect-expert-to-golang-expert.yaml":                         OutcomeExists,
+		"handoffs/test-expert-to-golang-expert.yml":                               OutcomeInvalid,
+		"handoffs/qa-expert-to-golang-expert.yaml":                                OutcomeInvalid,
+		"handoffs/ux-expert-to-golang-expert.yaml":                                OutcomeFailed,
+		"handoffs/broken.json":                                                    OutcomeInvalid,
+	}
+	for path, outcome := range want {
+		if outcomes[path] != outcome {
+			t.Errorf("%s: expected %s, got %s", path, outcome, outcomes[path])
+		}
+	}
+	if report.Skipped != 1 || report.Totals[string(OutcomeWouldImport)] != 2 || !report.Failed() {
+		t.Errorf("unexpected report totals %v, skipped %d", report.Totals, report.Skipped)
+	}
+	for id, opts := range importer.imported {
+		if !opts.DryRun || !opts.History {
+			t.Errorf("%s: expected a dry-run history import, got %+v", id, opts)
+		}
+	}
+	for _, file := range report.Files {
+		if file.Outcome == OutcomeInvalid && file.Path == filepath.FromSlash("handoffs/test-expert-to-golang-expert.yml") && len(file.Violations) != 1 {
+			t.Errorf("expected the validation violations in the report, got %+v", file)
+		}
+	}
+}
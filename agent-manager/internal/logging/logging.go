@@ -0,0 +1,149 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	stdlog "log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/vot3k/agent-handoff/handoff"
+)
+
+// Output formats
+const (
+	FormatJSON    = "json"
+	FormatConsole = "console"
+)
+
+// Field names shared by every log line that concerns a request or handoff
+const (
+	FieldRequestID = "request_id"
+	FieldHandoffID = "handoff_id"
+	FieldProject   = "project"
+	FieldAgent     = "agent"
+	FieldStrategy  = "strategy"
+)
+
+// Redacted replaces environment values that may hold secrets
+const Redacted = "[REDACTED]"
+
+// visibleEnv lists environment variables set by the manager itself, whose
+// values are safe to log
+var visibleEnv = map[string]bool{
+	"AGENT_PROJECT_NAME":   true,
+	"HANDOFF_ID":           true,
+	"FROM_AGENT":           true,
+	"PROJECT_ROOT":         true,
+	handoff.TraceparentEnv: true,
+}
+
+// Config selects the log level and output format
+type Config struct {
+	Level  string // trace, debug, info, warn or error (default info)
+	Format string // json or console (default console)
+}
+
+// Validate checks the level and format
+func (c Config) Validate() error {
+	if c.Level != "" {
+		if _, err := zerolog.ParseLevel(strings.ToLower(c.Level)); err != nil {
+			return fmt.Errorf("invalid log level %q", c.Level)
+		}
+	}
+	switch c.Format {
+	case "", FormatJSON, FormatConsole:
+		return nil
+	default:
+		return fmt.Errorf("unknown log format %q (want json or console)", c.Format)
+	}
+}
+
+// New creates a logger writing to w, tagging each line with component
+func New(w io.Writer, cfg Config, component string) (zerolog.Logger, error) {
+	if err := cfg.Validate(); err != nil {
+		return zerolog.Nop(), err
+	}
+	level := zerolog.InfoLevel
+	if cfg.Level != "" {
+		level, _ = zerolog.ParseLevel(strings.ToLower(cfg.Level))
+	}
+	if cfg.Format != FormatJSON {
+		w = zerolog.ConsoleWriter{Out: w, TimeFormat: time.RFC3339}
+	}
+	return zerolog.New(w).Level(level).With().Timestamp().Str("component", component).Logger(), nil
+}
+
+// Setup makes a logger writing to stderr the default for log, for contexts
+// without a logger of their own and for the standard library's log package
+func Setup(cfg Config, component string) error {
+	logger, err := New(os.Stderr, cfg, component)
+	if err != nil {
+		return err
+	}
+	log.Logger = logger
+	zerolog.DefaultContextLogger = &log.Logger
+	stdlog.SetFlags(0)
+	stdlog.SetOutput(logger)
+	return nil
+}
+
+// Ctx returns the logger carried by ctx, or the default logger
+func Ctx(ctx context.Context) *zerolog.Logger {
+	return zerolog.Ctx(ctx)
+}
+
+// WithRequestID returns ctx with a logger that includes request_id
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return with(ctx, FieldRequestID, requestID)
+}
+
+// WithHandoff returns ctx with a logger that includes the handoff's ID,
+// project and receiving agent; empty values are left out
+func WithHandoff(ctx context.Context, handoffID, project, agent string) context.Context {
+	return with(ctx, FieldHandoffID, handoffID, FieldProject, project, FieldAgent, agent)
+}
+
+// WithStrategy returns ctx with a logger that includes the execution strategy
+func WithStrategy(ctx context.Context, strategy string) context.Context {
+	return with(ctx, FieldStrategy, strategy)
+}
+
+// with adds the non-empty key/value pairs to ctx's logger
+func with(ctx context.Context, pairs ...string) context.Context {
+	logger := Ctx(ctx)
+	if logger.GetLevel() == zerolog.Disabled {
+		return ctx
+	}
+	c := logger.With()
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if pairs[i+1] != "" {
+			c = c.Str(pairs[i], pairs[i+1])
+		}
+	}
+	return c.Logger().WithContext(ctx)
+}
+
+// RedactEnv returns env for logging: variables set by the manager keep their
+// values, with any credentials in them redacted, and every other value is
+// replaced by Redacted
+func RedactEnv(env map[string]string) map[string]string {
+	redacted := make(map[string]string, len(env))
+	for key, value := range env {
+		if visibleEnv[key] {
+			redacted[key] = handoff.RedactSecrets(value)
+		} else {
+			redacted[key] = Redacted
+		}
+	}
+	return redacted
+}
+
+// Handoff returns ctx's logger with the handoff's ID, project and agent
+func Handoff(ctx context.Context, handoffID, project, agent string) *zerolog.Logger {
+	return Ctx(WithHandoff(ctx, handoffID, project, agent))
+}
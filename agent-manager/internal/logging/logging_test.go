@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestContextFields(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Level: "debug", Format: FormatJSON}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithRequestID(logger.WithContext(context.Background()), "req-1")
+	ctx = WithHandoff(ctx, "h-1", "demo", "")
+	ctx = WithStrategy(ctx, "BuiltInAgent")
+	Ctx(ctx).Info().Msg("Executing agent")
+
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("expected one JSON line, got %q: %v", buf.String(), err)
+	}
+	for field, want := range map[string]string{
+		FieldRequestID: "req-1",
+		FieldHandoffID: "h-1",
+		FieldProject:   "demo",
+		FieldStrategy:  "BuiltInAgent",
+		"component":    "test",
+		"level":        "info",
+		"message":      "Executing agent",
+	} {
+		if line[field] != want {
+			t.Errorf("expected %s %q, got %v", field, want, line[field])
+		}
+	}
+	if _, ok := line[FieldAgent]; ok {
+		t.Error("expected an empty agent to be left out")
+	}
+
+	// Without a logger in ctx and before Setup, nothing is written or attached
+	if got := WithHandoff(context.Background(), "h-1", "demo", "api-expert"); got != context.Background() {
+		t.Error("expected a context without a logger to be returned unchanged")
+	}
+}
+
+func TestConsoleFormatAndLevel(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, Config{Level: "WARN", Format: FormatConsole}, "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info().Msg("hidden")
+	logger.Warn().Str(FieldAgent, "api-expert").Msg("shown")
+
+	out := buf.String()
+	if strings.Contains(out, "hidden") || !strings.Contains(out, "shown") || !strings.Contains(out, "api-expert") {
+		t.Errorf("unexpected console output %q", out)
+	}
+	if strings.HasPrefix(strings.TrimSpace(out), "{") {
+		t.Errorf("expected console output rather than JSON, got %q", out)
+	}
+
+	for _, cfg := range []Config{{Level: "loud"}, {Format: "xml"}} {
+		if _, err := New(&buf, cfg, "test"); err == nil {
+			t.Errorf("%+v: expected an invalid configuration", cfg)
+		}
+	}
+}
+
+func TestRedactEnv(t *testing.T) {
+	redacted := RedactEnv(map[string]string{
+		"HANDOFF_ID":         "h-1",
+		"AGENT_PROJECT_NAME": "demo",
+		"ANTHROPIC_API_KEY":  "sk-ant-REDACTED",
+		"DATABASE_URL":       "postgres://user:pass@db/app",
+	})
+	if redacted["HANDOFF_ID"] != "h-1" || redacted["AGENT_PROJECT_NAME"] != "demo" {
+		t.Errorf("expected the manager's own variables to be kept, got %v", redacted)
+	}
+	if redacted["ANTHROPIC_API_KEY"] != Redacted || redacted["DATABASE_URL"] != Redacted {
+		t.Errorf("expected other values to be redacted, got %v", redacted)
+	}
+	if len(redacted) != 4 {
+		t.Errorf("expected every variable name to be kept, got %v", redacted)
+	}
+}
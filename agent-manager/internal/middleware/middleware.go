@@ -3,11 +3,12 @@ package middleware
 import (
 	"context"
 	"fmt"
-	"log"
 	"net/http"
 	"runtime/debug"
 	"sync"
 	"time"
+
+	"github.com/vot3k/agent-handoff/agent-manager/internal/logging"
 )
 
 type contextKey string
@@ -22,7 +23,7 @@ func Chain(handler http.Handler, middlewares ...func(http.Handler) http.Handler)
 	return handler
 }
 
-// RequestID adds a unique request ID to each request
+// RequestID adds a unique request ID to each request and to its logger
 func RequestID(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		requestID := r.Header.Get("X-Request-ID")
@@ -31,6 +32,7 @@ func RequestID(next http.Handler) http.Handler {
 		}
 		
 		ctx := context.WithValue(r.Context(), RequestIDKey, requestID)
+		ctx = logging.WithRequestID(ctx, requestID)
 		w.Header().Set("X-Request-ID", requestID)
 		
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -47,18 +49,14 @@ func Logger(next http.Handler) http.Handler {
 		
 		next.ServeHTTP(wrapped, r)
 		
-		duration := time.Since(start)
-		requestID := GetRequestID(r.Context())
-		
-		log.Printf("[%s] %s %s %d %v %s %s",
-			requestID,
-			r.Method,
-			r.URL.Path,
-			wrapped.statusCode,
-			duration,
-			r.RemoteAddr,
-			r.UserAgent(),
-		)
+		logging.Ctx(r.Context()).Info().
+			Str("method", r.Method).
+			Str("path", r.URL.Path).
+			Int("status", wrapped.statusCode).
+			Dur("duration", time.Since(start)).
+			Str("remote_addr", r.RemoteAddr).
+			Str("user_agent", r.UserAgent()).
+			Msg("Request handled")
 	})
 }
 
@@ -67,7 +65,7 @@ func CORS(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Access-Control-Allow-Origin", "*")
 		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization, X-Request-ID, Idempotency-Key")
 		w.Header().Set("Access-Control-Max-Age", "3600")
 		
 		if r.Method == "OPTIONS" {
@@ -85,7 +83,10 @@ func Recovery(next http.Handler) http.Handler {
 		defer func() {
 			if err := recover(); err != nil {
 				requestID := GetRequestID(r.Context())
-				log.Printf("[%s] PANIC: %v\n%s", requestID, err, debug.Stack())
+				logging.Ctx(r.Context()).Error().
+					Interface("panic", err).
+					Bytes("stack", debug.Stack()).
+					Msg("Recovered from panic")
 				
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusInternalServerError)
@@ -116,7 +117,7 @@ func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
 			case <-ctx.Done():
 				// Request timed out
 				requestID := GetRequestID(r.Context())
-				log.Printf("[%s] Request timeout after %v", requestID, timeout)
+				logging.Ctx(r.Context()).Warn().Dur("timeout", timeout).Msg("Request timed out")
 				
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusRequestTimeout)
@@ -126,6 +127,17 @@ func Timeout(timeout time.Duration) func(http.Handler) http.Handler {
 	}
 }
 
+// MaxBodySize limits request bodies to limit bytes; reading past it fails with
+// *http.MaxBytesError
+func MaxBodySize(limit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			r.Body = http.MaxBytesReader(w, r.Body, limit)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // RateLimit implements a simple in-memory rate limiter
 func RateLimit(requestsPerMinute int) func(http.Handler) http.Handler {
 	limiter := newRateLimiter(requestsPerMinute)
@@ -136,7 +148,7 @@ func RateLimit(requestsPerMinute int) func(http.Handler) http.Handler {
 			
 			if !limiter.Allow(clientIP) {
 				requestID := GetRequestID(r.Context())
-				log.Printf("[%s] Rate limit exceeded for IP: %s", requestID, clientIP)
+				logging.Ctx(r.Context()).Warn().Str("client_ip", clientIP).Msg("Rate limit exceeded")
 				
 				w.Header().Set("Content-Type", "application/json")
 				w.WriteHeader(http.StatusTooManyRequests)
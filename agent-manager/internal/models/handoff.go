@@ -4,17 +4,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"time"
+
+	"github.com/vot3k/agent-handoff/handoff"
 )
 
 // HandoffStatus represents the current status of a handoff
 type HandoffStatus string
 
 const (
-	StatusPending    HandoffStatus = "pending"
-	StatusProcessing HandoffStatus = "processing"
-	StatusCompleted  HandoffStatus = "completed"
-	StatusFailed     HandoffStatus = "failed"
-	StatusCancelled  HandoffStatus = "cancelled"
+	StatusPending     HandoffStatus = "pending"
+	StatusProcessing  HandoffStatus = "processing"
+	StatusCompleted   HandoffStatus = "completed"
+	StatusFailed      HandoffStatus = "failed"
+	StatusCancelled   HandoffStatus = "cancelled"
+	StatusQuarantined HandoffStatus = "quarantined" // Failed its integrity check on consume
 )
 
 // Priority represents the priority level of a handoff
@@ -47,9 +50,22 @@ func (p Priority) GetScore() float64 {
 type Handoff struct {
 	Metadata HandoffMetadata `json:"metadata"`
 	Content  HandoffContent  `json:"content"`
+	Validation handoff.Validation `json:"validation"`
 	Status   HandoffStatus   `json:"status"`
 	CreatedAt time.Time      `json:"created_at"`
 	UpdatedAt time.Time      `json:"updated_at"`
+
+	// FanOut is set on a handoff that was routed to several agents; its status
+	// aggregates the child handoffs listed here
+	FanOut *handoff.FanOut `json:"fan_out,omitempty"`
+
+	// SLABreach is set when the handoff waited in its queue longer than its
+	// priority's SLA allows
+	SLABreach *handoff.SLABreach `json:"sla_breach,omitempty"`
+
+	// Trace is the trace context started when the handoff was created; the
+	// dispatcher and executed agents continue it
+	Trace *handoff.TraceContext `json:"trace,omitempty"`
 }
 
 // HandoffMetadata contains metadata about the handoff
@@ -61,6 +77,20 @@ type HandoffMetadata struct {
 	TaskContext string    `json:"task_context"`
 	Priority    Priority  `json:"priority"`
 	HandoffID   string    `json:"handoff_id"`
+
+	// Route records that the handoff was created with routing requested
+	Route bool `json:"route,omitempty"`
+
+	// Routing provenance, set when the handoff was routed on creation
+	RequestedAgent string `json:"requested_agent,omitempty"`
+	RouteRule      string `json:"route_rule,omitempty"`
+
+	// ParentID links a fan-out child to the handoff it was split from
+	ParentID string `json:"parent_id,omitempty"`
+
+	// IdempotencyKey identifies the producer's attempt; retries with the same
+	// key return this handoff instead of creating another
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 // HandoffContent contains the actual content and requirements
@@ -70,6 +100,8 @@ type HandoffContent struct {
 	Artifacts        map[string][]string    `json:"artifacts"`
 	TechnicalDetails map[string]interface{} `json:"technical_details"`
 	NextSteps        []string               `json:"next_steps"`
+	ArtifactFiles    []handoff.ArtifactFile `json:"artifact_files,omitempty"` // Size and hash of each artifact, when verified
+	Offloaded        map[string]handoff.BlobRef `json:"offloaded,omitempty"`  // technical_details fields stored as blobs
 }
 
 // CreateHandoffRequest represents a request to create a new handoff
@@ -84,6 +116,9 @@ type CreateHandoffRequest struct {
 	Artifacts        map[string][]string    `json:"artifacts"`
 	TechnicalDetails map[string]interface{} `json:"technical_details"`
 	NextSteps        []string               `json:"next_steps"`
+	Route            bool                   `json:"route,omitempty"`           // Route with the configured rules, keeping to_agent when none matches
+	IdempotencyKey   string                 `json:"idempotency_key,omitempty"` // Also accepted as the Idempotency-Key header
+	Signature        *handoff.Signature     `json:"signature,omitempty"`       // Producer's signature over the other fields (see Sign)
 }
 
 // UpdateStatusRequest represents a request to update handoff status
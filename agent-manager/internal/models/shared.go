@@ -0,0 +1,248 @@
+package models
+
+import (
+	"fmt"
+
+	"github.com/vot3k/agent-handoff/handoff"
+)
+
+// ToShared converts the handoff into the shared handoff package representation
+// so routing and validation logic can be reused by the agent-manager
+func (h *Handoff) ToShared() *handoff.Handoff {
+	shared := &handoff.Handoff{
+		Metadata: handoff.Metadata{
+			ProjectName:    h.Metadata.ProjectName,
+			FromAgent:      h.Metadata.FromAgent,
+			ToAgent:        h.Metadata.ToAgent,
+			Timestamp:      h.Metadata.Timestamp,
+			TaskContext:    h.Metadata.TaskContext,
+			Priority:       h.Metadata.Priority.ToShared(),
+			HandoffID:      h.Metadata.HandoffID,
+			Route:          h.Metadata.Route,
+			RequestedAgent: h.Metadata.RequestedAgent,
+			RouteRule:      h.Metadata.RouteRule,
+			ParentID:       h.Metadata.ParentID,
+			IdempotencyKey: h.Metadata.IdempotencyKey,
+		},
+		Content: handoff.Content{
+			Summary:          h.Content.Summary,
+			Requirements:     h.Content.Requirements,
+			TechnicalDetails: h.Content.TechnicalDetails,
+			NextSteps:        h.Content.NextSteps,
+		},
+		Status:    handoff.HandoffStatus(h.Status),
+		CreatedAt: h.CreatedAt,
+		UpdatedAt: h.UpdatedAt,
+		FanOut:    h.FanOut,
+		Trace:     h.Trace,
+	}
+
+	if h.Content.Artifacts != nil {
+		shared.Content.Artifacts = handoff.Artifacts{
+			Created:  h.Content.Artifacts["created"],
+			Modified: h.Content.Artifacts["modified"],
+			Reviewed: h.Content.Artifacts["reviewed"],
+		}
+	}
+	shared.Content.ArtifactFiles = h.Content.ArtifactFiles
+	shared.Content.Offloaded = h.Content.Offloaded
+
+	return shared
+}
+
+// UpdateFromShared copies metadata and content back from a shared handoff,
+// picking up any changes made by routing transforms or sanitization
+func (h *Handoff) UpdateFromShared(shared *handoff.Handoff) {
+	h.Metadata.ProjectName = shared.Metadata.ProjectName
+	h.Metadata.FromAgent = shared.Metadata.FromAgent
+	h.Metadata.ToAgent = shared.Metadata.ToAgent
+	h.Metadata.TaskContext = shared.Metadata.TaskContext
+	h.Metadata.Priority = PriorityFromShared(shared.Metadata.Priority)
+	h.Metadata.Route = shared.Metadata.Route
+	h.Metadata.RequestedAgent = shared.Metadata.RequestedAgent
+	h.Metadata.RouteRule = shared.Metadata.RouteRule
+	h.Metadata.ParentID = shared.Metadata.ParentID
+	h.Metadata.IdempotencyKey = shared.Metadata.IdempotencyKey
+
+	h.Content.Summary = shared.Content.Summary
+	h.Content.Requirements = shared.Content.Requirements
+	h.Content.TechnicalDetails = shared.Content.TechnicalDetails
+	h.Content.NextSteps = shared.Content.NextSteps
+	h.Content.ArtifactFiles = shared.Content.ArtifactFiles
+	h.Content.Offloaded = shared.Content.Offloaded
+
+	artifacts := shared.Content.Artifacts
+	if h.Content.Artifacts != nil || len(artifacts.Created)+len(artifacts.Modified)+len(artifacts.Reviewed) > 0 {
+		if h.Content.Artifacts == nil {
+			h.Content.Artifacts = make(map[string][]string)
+		}
+		setArtifacts(h.Content.Artifacts, "created", artifacts.Created)
+		setArtifacts(h.Content.Artifacts, "modified", artifacts.Modified)
+		setArtifacts(h.Content.Artifacts, "reviewed", artifacts.Reviewed)
+	}
+}
+
+// Seal computes the handoff checksum over its metadata and content. Call it
+// after the last change to either, just before the handoff is stored.
+func (h *Handoff) Seal() error {
+	checksum, err := handoff.ComputeChecksum(h.Metadata, h.Content)
+	if err != nil {
+		return fmt.Errorf("cannot checksum handoff: %w", err)
+	}
+	h.Validation.SchemaVersion = "1.0"
+	h.Validation.Checksum = checksum
+	h.Validation.Signature = nil
+	return nil
+}
+
+// Sign signs the handoff as its from_agent. Call it after Seal.
+func (h *Handoff) Sign(keys *handoff.KeyRegistry) error {
+	signature, err := keys.SignContent(h.Metadata.FromAgent, h.Metadata, h.Content)
+	if err != nil {
+		return err
+	}
+	h.Validation.Signature = signature
+	return nil
+}
+
+// VerifySignature checks the handoff's signature against the keys of its from_agent
+func (h *Handoff) VerifySignature(keys *handoff.KeyRegistry) error {
+	return keys.VerifyContent(h.Metadata.FromAgent, h.Metadata, h.Content, h.Validation.Signature)
+}
+
+// VerifyChecksum checks the handoff against the checksum it was sealed with
+func (h *Handoff) VerifyChecksum() error {
+	if h.Validation.Checksum == "" {
+		return handoff.ErrChecksumMissing
+	}
+
+	checksum, err := handoff.ComputeChecksum(h.Metadata, h.Content)
+	if err != nil {
+		return fmt.Errorf("%w: %v", handoff.ErrChecksumMismatch, err)
+	}
+	if checksum != h.Validation.Checksum {
+		return fmt.Errorf("%w: stored %s, computed %s", handoff.ErrChecksumMismatch, h.Validation.Checksum, checksum)
+	}
+	return nil
+}
+
+// NewFanOutChild copies a fan-out parent into a pending child handoff addressed to one agent
+func (h *Handoff) NewFanOutChild(toAgent, handoffID string) *Handoff {
+	child := &Handoff{
+		Metadata:  h.Metadata,
+		Status:    StatusPending,
+		CreatedAt: h.CreatedAt,
+		UpdatedAt: h.UpdatedAt,
+		Trace:     h.Trace,
+	}
+	child.UpdateFromShared(handoff.NewFanOutChild(h.ToShared(), toAgent, handoffID))
+	child.Metadata.HandoffID = handoffID
+	return child
+}
+
+// ApplyFanOutChildStatus records a child's status on this fan-out parent and
+// refreshes the aggregate status. It reports whether the parent changed.
+func (h *Handoff) ApplyFanOutChildStatus(childID string, status HandoffStatus) bool {
+	shared := &handoff.Handoff{Status: handoff.HandoffStatus(h.Status), FanOut: h.FanOut}
+	if !handoff.ApplyFanOutChildStatus(shared, childID, handoff.HandoffStatus(status)) {
+		return false
+	}
+
+	h.Status = HandoffStatus(shared.Status)
+	h.UpdatedAt = shared.UpdatedAt
+	return true
+}
+
+// ToShared maps the agent-manager priority onto the shared priority levels
+func (p Priority) ToShared() handoff.Priority {
+	switch p {
+	case PriorityUrgent:
+		return handoff.PriorityCritical
+	case PriorityHigh:
+		return handoff.PriorityHigh
+	case PriorityLow:
+		return handoff.PriorityLow
+	default:
+		return handoff.PriorityNormal
+	}
+}
+
+// PriorityFromShared maps a shared priority onto the agent-manager priority levels
+func PriorityFromShared(p handoff.Priority) Priority {
+	switch p {
+	case handoff.PriorityCritical:
+		return PriorityUrgent
+	case handoff.PriorityHigh:
+		return PriorityHigh
+	case handoff.PriorityLow:
+		return PriorityLow
+	default:
+		return PriorityNormal
+	}
+}
+
+// setArtifacts stores a category of artifacts, dropping empty categories that were not present
+func setArtifacts(artifacts map[string][]string, category string, paths []string) {
+	if _, exists := artifacts[category]; !exists && len(paths) == 0 {
+		return
+	}
+	artifacts[category] = paths
+}
+
+// signedRequestMetadata and signedRequestContent are the fields of a create
+// request covered by its producer's signature
+type signedRequestMetadata struct {
+	ProjectName    string   `json:"project_name"`
+	FromAgent      string   `json:"from_agent"`
+	ToAgent        string   `json:"to_agent"`
+	TaskContext    string   `json:"task_context"`
+	Priority       Priority `json:"priority"`
+	Route          bool     `json:"route,omitempty"`
+	IdempotencyKey string   `json:"idempotency_key,omitempty"`
+}
+
+type signedRequestContent struct {
+	Summary          string                 `json:"summary"`
+	Requirements     []string               `json:"requirements"`
+	Artifacts        map[string][]string    `json:"artifacts"`
+	TechnicalDetails map[string]interface{} `json:"technical_details"`
+	NextSteps        []string               `json:"next_steps"`
+}
+
+func (r *CreateHandoffRequest) signedParts() (signedRequestMetadata, signedRequestContent) {
+	metadata := signedRequestMetadata{
+		ProjectName:    r.ProjectName,
+		FromAgent:      r.FromAgent,
+		ToAgent:        r.ToAgent,
+		TaskContext:    r.TaskContext,
+		Priority:       r.Priority,
+		Route:          r.Route,
+		IdempotencyKey: r.IdempotencyKey,
+	}
+	content := signedRequestContent{
+		Summary:          r.Summary,
+		Requirements:     r.Requirements,
+		Artifacts:        r.Artifacts,
+		TechnicalDetails: r.TechnicalDetails,
+		NextSteps:        r.NextSteps,
+	}
+	return metadata, content
+}
+
+// Sign signs the request as its from_agent. Producers sign with their own key
+// so the server can tell who sent it.
+func (r *CreateHandoffRequest) Sign(keys *handoff.KeyRegistry) error {
+	metadata, content := r.signedParts()
+	signature, err := keys.SignContent(r.FromAgent, metadata, content)
+	if err != nil {
+		return err
+	}
+	r.Signature = signature
+	return nil
+}
+
+// VerifySignature checks the request's signature against the keys of its from_agent
+func (r *CreateHandoffRequest) VerifySignature(keys *handoff.KeyRegistry) error {
+	metadata, content := r.signedParts()
+	return keys.VerifyContent(r.FromAgent, metadata, content, r.Signature)
+}
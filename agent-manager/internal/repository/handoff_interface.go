@@ -2,21 +2,39 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/vot3k/agent-handoff/agent-manager/internal/models"
 )
 
+// ErrHandoffNotFound is returned, wrapped with the ID, for a handoff that is not stored
+var ErrHandoffNotFound = errors.New("handoff not found")
+
 // HandoffRepositoryInterface defines the interface for handoff repository operations
 type HandoffRepositoryInterface interface {
 	// Create stores a new handoff
 	Create(ctx context.Context, handoff *models.Handoff) error
 
-	// GetByID retrieves a handoff by its ID
+	// CreateFanOut stores a fan-out parent and queues its children atomically
+	CreateFanOut(ctx context.Context, parent *models.Handoff, children []*models.Handoff) error
+
+	// CreateRecord stores a handoff without queueing it or expiring it, for
+	// historical records imported from the legacy file system
+	CreateRecord(ctx context.Context, handoff *models.Handoff) error
+
+	// RecordFanOutChild updates a fan-out parent with the status of one of its children
+	RecordFanOutChild(ctx context.Context, parentID, childID string, status models.HandoffStatus) error
+
+	// GetByID retrieves a handoff by its ID, or returns ErrHandoffNotFound
 	GetByID(ctx context.Context, handoffID string) (*models.Handoff, error)
 
 	// UpdateStatus updates the status of a handoff
 	UpdateStatus(ctx context.Context, handoffID string, status models.HandoffStatus) error
 
+	// Quarantine marks a handoff that failed its integrity check and keeps it for inspection
+	Quarantine(ctx context.Context, handoffID, reason string) error
+
 	// List retrieves handoffs with pagination
 	List(ctx context.Context, projectName string, page, pageSize int) (*models.HandoffListResponse, error)
 
@@ -31,4 +49,11 @@ type HandoffRepositoryInterface interface {
 
 	// PopFromQueue removes and returns the highest priority handoff from a queue
 	PopFromQueue(ctx context.Context, queueName string) (string, error)
+
+	// ClaimDedupKeys claims idempotency and fingerprint keys for a new handoff for
+	// the window, returning the ID of the handoff already holding one of them
+	ClaimDedupKeys(ctx context.Context, keys []string, handoffID string, window time.Duration) (string, error)
+
+	// ReleaseDedupKeys gives up the dedup keys still held by a handoff
+	ReleaseDedupKeys(ctx context.Context, keys []string, handoffID string) error
 }
@@ -7,14 +7,19 @@ import (
 	"time"
 
 	"github.com/vot3k/agent-handoff/agent-manager/internal/config"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/logging"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/models"
+	"github.com/vot3k/agent-handoff/handoff"
 
 	"github.com/go-redis/redis/v8"
 )
 
 // RedisClient wraps redis client with our interface
 type RedisClient struct {
-	client *redis.Client
+	client  *redis.Client
+	metrics handoff.MetricsRecorder
+	sla     handoff.SLAPolicy
+	history []handoff.HistoryTier
 }
 
 // NewRedisClient creates a new Redis client
@@ -33,7 +38,40 @@ func NewRedisClient(cfg config.RedisConfig) (*RedisClient, error) {
 		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
 	}
 
-	return &RedisClient{client: rdb}, nil
+	return &RedisClient{
+		client:  rdb,
+		metrics: handoff.NewMetricsRecorder(handoff.DefaultMetricsRetention),
+		history: handoff.DefaultHistoryTiers(),
+	}, nil
+}
+
+// SetMetricsRetention sets how long the per-minute metric buckets recorded
+// with handoff changes are kept
+func (r *RedisClient) SetMetricsRetention(retention time.Duration) {
+	r.metrics = handoff.NewMetricsRecorder(retention)
+}
+
+// SetSLAPolicy sets how long handoffs of each priority may wait in their
+// queue; handoffs popped later are marked with an SLA breach
+func (r *RedisClient) SetSLAPolicy(policy handoff.SLAPolicy) {
+	r.sla = policy
+}
+
+// SetHistoryTiers sets the tiers metric history is read from. They should
+// match the tiers the handoff monitor records at.
+func (r *RedisClient) SetHistoryTiers(tiers []handoff.HistoryTier) {
+	r.history = tiers
+}
+
+// BlobStore returns a store for offloaded handoff fields on this connection
+func (r *RedisClient) BlobStore() *handoff.RedisBlobStore {
+	return handoff.NewRedisBlobStore(r.client)
+}
+
+// Client returns the underlying connection, for callers that also use the
+// handoff package's Redis functions
+func (r *RedisClient) Client() *redis.Client {
+	return r.client
 }
 
 // Close closes the Redis connection
@@ -46,6 +84,41 @@ func (r *RedisClient) Ping(ctx context.Context) error {
 	return r.client.Ping(ctx).Err()
 }
 
+// CollectMetrics returns the handoff metrics stored in Redis and this
+// connection's pool statistics, for serving with handoff.MetricsHandler
+func (r *RedisClient) CollectMetrics(ctx context.Context) (*handoff.MetricsSnapshot, error) {
+	snapshot, err := handoff.CollectMetrics(ctx, r.client)
+	if err != nil {
+		return nil, err
+	}
+	pool := handoff.PoolMetricsFromStats(r.client.PoolStats())
+	snapshot.Pool = &pool
+	return snapshot, nil
+}
+
+// QueryRollingMetrics returns handoff counts and processing times per project,
+// agent and priority between from and to
+func (r *RedisClient) QueryRollingMetrics(ctx context.Context, from, to time.Time) (*handoff.RollingMetrics, error) {
+	return handoff.QueryRollingMetrics(ctx, r.client, from, to)
+}
+
+// QueryHistory returns the history of a metric recorded by the handoff monitor
+func (r *RedisClient) QueryHistory(ctx context.Context, q handoff.HistoryQuery) (*handoff.HistoryResult, error) {
+	return handoff.QueryHistory(ctx, r.client, r.history, q)
+}
+
+// HealthChecks returns the checks of Redis, the queue backlog, failure rate,
+// latency, active agents and this connection's pool, for a handoff.HealthChecker
+func (r *RedisClient) HealthChecks(cfg handoff.HealthConfig) []handoff.HealthCheck {
+	return []handoff.HealthCheck{
+		handoff.RedisHealthCheck(r.client),
+		handoff.MetricsHealthCheck(r.client, cfg),
+		handoff.PoolHealthCheck(func() handoff.RedisPoolMetrics {
+			return handoff.PoolMetricsFromStats(r.client.PoolStats())
+		}),
+	}
+}
+
 // HandoffRepository handles handoff data persistence in Redis
 type HandoffRepository struct {
 	redis *RedisClient
@@ -84,11 +157,13 @@ func (r *HandoffRepository) Create(ctx context.Context, handoff *models.Handoff)
 			Score:  score,
 			Member: handoff.Metadata.HandoffID,
 		})
+		markEnqueued(ctx, pipe, queueName, handoff.Metadata.HandoffID)
 
 		// Add to project set for efficient listing
 		projectSetKey := GetHandoffProjectSetKey(handoff.Metadata.ProjectName)
 		pipe.SAdd(ctx, projectSetKey, handoff.Metadata.HandoffID)
 
+		r.redis.recordPublished(ctx, pipe, handoff)
 		return nil
 	})
 
@@ -99,6 +174,108 @@ func (r *HandoffRepository) Create(ctx context.Context, handoff *models.Handoff)
 	return nil
 }
 
+// CreateRecord stores a handoff that is kept for its history: it is listed
+// with its project but not queued, and does not expire
+func (r *HandoffRepository) CreateRecord(ctx context.Context, handoff *models.Handoff) error {
+	data, err := handoff.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to serialize handoff: %w", err)
+	}
+
+	_, err = r.redis.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, GetHandoffKey(handoff.Metadata.HandoffID), data, 0)
+		pipe.SAdd(ctx, GetHandoffProjectSetKey(handoff.Metadata.ProjectName), handoff.Metadata.HandoffID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to store handoff record: %w", err)
+	}
+	return nil
+}
+
+// CreateFanOut stores a fan-out parent without queueing it and queues each child,
+// all in one transaction so a parent never exists without its children
+func (r *HandoffRepository) CreateFanOut(ctx context.Context, parent *models.Handoff, children []*models.Handoff) error {
+	parentData, err := parent.ToJSON()
+	if err != nil {
+		return fmt.Errorf("failed to serialize handoff: %w", err)
+	}
+
+	childData := make([][]byte, len(children))
+	for i, child := range children {
+		if childData[i], err = child.ToJSON(); err != nil {
+			return fmt.Errorf("failed to serialize child handoff: %w", err)
+		}
+	}
+
+	_, err = r.redis.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, GetHandoffKey(parent.Metadata.HandoffID), parentData, 24*time.Hour)
+		pipe.SAdd(ctx, GetHandoffProjectSetKey(parent.Metadata.ProjectName), parent.Metadata.HandoffID)
+
+		for i, child := range children {
+			pipe.Set(ctx, GetHandoffKey(child.Metadata.HandoffID), childData[i], 24*time.Hour)
+			pipe.ZAdd(ctx, child.GetQueueName(), &redis.Z{
+				Score:  child.GetPriorityScore(),
+				Member: child.Metadata.HandoffID,
+			})
+			markEnqueued(ctx, pipe, child.GetQueueName(), child.Metadata.HandoffID)
+			pipe.SAdd(ctx, GetHandoffProjectSetKey(child.Metadata.ProjectName), child.Metadata.HandoffID)
+			r.redis.recordPublished(ctx, pipe, child)
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return fmt.Errorf("failed to store fan-out handoffs with transaction: %w", err)
+	}
+
+	return nil
+}
+
+// RecordFanOutChild updates a fan-out parent with a child's status, retrying
+// when another child updates the parent concurrently
+func (r *HandoffRepository) RecordFanOutChild(ctx context.Context, parentID, childID string, status models.HandoffStatus) error {
+	key := GetHandoffKey(parentID)
+
+	update := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err != nil {
+			if err == redis.Nil {
+				return fmt.Errorf("%w: %s", ErrHandoffNotFound, parentID)
+			}
+			return fmt.Errorf("failed to retrieve handoff: %w", err)
+		}
+
+		var parent models.Handoff
+		if err := parent.FromJSON(data); err != nil {
+			return fmt.Errorf("failed to deserialize handoff: %w", err)
+		}
+		if !parent.ApplyFanOutChildStatus(childID, status) {
+			return nil
+		}
+
+		updated, err := parent.ToJSON()
+		if err != nil {
+			return fmt.Errorf("failed to serialize updated handoff: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, updated, 24*time.Hour)
+			return nil
+		})
+		return err
+	}
+
+	for attempt := 0; attempt < 10; attempt++ {
+		err := r.redis.client.Watch(ctx, update, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+
+	return fmt.Errorf("failed to update fan-out parent %s: too many concurrent updates", parentID)
+}
+
 // GetByID retrieves a handoff by its ID
 func (r *HandoffRepository) GetByID(ctx context.Context, handoffID string) (*models.Handoff, error) {
 	key := GetHandoffKey(handoffID)
@@ -106,7 +283,7 @@ func (r *HandoffRepository) GetByID(ctx context.Context, handoffID string) (*mod
 	data, err := r.redis.client.Get(ctx, key).Result()
 	if err != nil {
 		if err == redis.Nil {
-			return nil, fmt.Errorf("handoff not found: %s", handoffID)
+			return nil, fmt.Errorf("%w: %s", ErrHandoffNotFound, handoffID)
 		}
 		return nil, fmt.Errorf("failed to retrieve handoff: %w", err)
 	}
@@ -128,6 +305,7 @@ func (r *HandoffRepository) UpdateStatus(ctx context.Context, handoffID string,
 	}
 
 	// Update status and timestamp
+	previous, processingStarted := handoff.Status, handoff.UpdatedAt
 	handoff.Status = status
 	handoff.UpdatedAt = time.Now()
 
@@ -138,13 +316,94 @@ func (r *HandoffRepository) UpdateStatus(ctx context.Context, handoffID string,
 	}
 
 	key := handoff.GetRedisKey()
-	if err := r.redis.client.Set(ctx, key, data, 24*time.Hour).Err(); err != nil {
+	_, err = r.redis.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, key, data, 24*time.Hour)
+		if previous != status {
+			r.redis.recordStatusChange(ctx, pipe, handoff, previous, processingStarted)
+		}
+		return nil
+	})
+	if err != nil {
 		return fmt.Errorf("failed to update handoff: %w", err)
 	}
 
 	return nil
 }
 
+// statusEvents are the status changes counted as handoff events
+var statusEvents = map[models.HandoffStatus]handoff.HandoffEvent{
+	models.StatusCompleted:   handoff.EventCompleted,
+	models.StatusFailed:      handoff.EventFailed,
+	models.StatusQuarantined: handoff.EventQuarantined,
+}
+
+// metricLabels returns the metric labels of a handoff
+func metricLabels(h *models.Handoff) handoff.MetricLabels {
+	return handoff.MetricLabels{
+		Project:  h.Metadata.ProjectName,
+		Agent:    h.Metadata.ToAgent,
+		Priority: handoff.Priority(h.Metadata.Priority),
+	}
+}
+
+// recordPublished counts a queued handoff for its project, agent and priority
+func (r *RedisClient) recordPublished(ctx context.Context, pipe redis.Pipeliner, h *models.Handoff) {
+	r.metrics.RecordEvent(ctx, pipe, handoff.EventPublished, metricLabels(h))
+}
+
+// recordStatusChange counts a handoff reaching a final status and, when it
+// leaves processing, how long processing took
+func (r *RedisClient) recordStatusChange(ctx context.Context, pipe redis.Pipeliner, h *models.Handoff, previous models.HandoffStatus, processingStarted time.Time) {
+	event, ok := statusEvents[h.Status]
+	if !ok {
+		return
+	}
+	r.metrics.RecordEvent(ctx, pipe, event, metricLabels(h))
+	if previous == models.StatusProcessing && event != handoff.EventQuarantined {
+		r.metrics.RecordProcessingTime(ctx, pipe, metricLabels(h), h.UpdatedAt.Sub(processingStarted))
+	}
+}
+
+// markEnqueued records when a handoff was queued, so queue ages and SLAs are
+// measured from it rather than from the priority-based queue score
+func markEnqueued(ctx context.Context, pipe redis.Pipeliner, queueName, handoffID string) {
+	handoff.MarkEnqueued(ctx, pipe, queueName, handoffID, time.Now())
+}
+
+// checkSLA records an SLA breach on a handoff popped from its queue later than
+// its priority allows, counting breaches not recorded while it waited
+func (r *RedisClient) checkSLA(ctx context.Context, queueName, handoffID string) error {
+	labels, first, err := handoff.StartSLA(ctx, r.client, r.sla, queueName, handoffID, time.Now())
+	if err != nil {
+		return err
+	}
+	if first {
+		r.metrics.RecordEvent(ctx, r.client, handoff.EventSLABreached, labels)
+	}
+	return nil
+}
+
+// Quarantine marks a handoff as quarantined and records it in the shared
+// quarantine set, extending its retention so it can be inspected. A handoff
+// too corrupt to update is still recorded as quarantined.
+func (r *HandoffRepository) Quarantine(ctx context.Context, handoffID, reason string) error {
+	statusErr := r.UpdateStatus(ctx, handoffID, models.StatusQuarantined)
+	if err := handoff.QuarantineHandoff(ctx, r.redis.client, handoffID, reason); err != nil {
+		return err
+	}
+	return statusErr
+}
+
+// ClaimDedupKeys claims dedup keys shared with the handoff service
+func (r *HandoffRepository) ClaimDedupKeys(ctx context.Context, keys []string, handoffID string, window time.Duration) (string, error) {
+	return handoff.ClaimDedupKeys(ctx, r.redis.client, keys, handoffID, window)
+}
+
+// ReleaseDedupKeys gives up the dedup keys still held by a handoff
+func (r *HandoffRepository) ReleaseDedupKeys(ctx context.Context, keys []string, handoffID string) error {
+	return handoff.ReleaseDedupKeys(ctx, r.redis.client, keys, handoffID)
+}
+
 // List retrieves handoffs with pagination
 func (r *HandoffRepository) List(ctx context.Context, projectName string, page, pageSize int) (*models.HandoffListResponse, error) {
 	// Use Redis sets for efficient listing instead of KEYS command
@@ -265,6 +524,7 @@ func (r *HandoffRepository) RemoveFromQueue(ctx context.Context, queueName, hand
 	if removed == 0 {
 		return fmt.Errorf("handoff not found in queue: %s", handoffID)
 	}
+	handoff.ClearEnqueued(ctx, r.redis.client, queueName, handoffID)
 	return nil
 }
 
@@ -280,6 +540,10 @@ func (r *HandoffRepository) PopFromQueue(ctx context.Context, queueName string)
 	}
 
 	handoffID := result[0].Member.(string)
+	// The pop has succeeded, so a failed SLA check is only logged
+	if err := r.redis.checkSLA(ctx, queueName, handoffID); err != nil {
+		logging.Ctx(ctx).Error().Err(err).Str(logging.FieldHandoffID, handoffID).Msg("SLA check failed")
+	}
 	return handoffID, nil
 }
 
@@ -295,8 +559,14 @@ func parseQueueName(queueName string) (string, string) {
 	return "", ""
 }
 
-// getOldestTaskTime retrieves the creation time of the oldest task in a queue
+// getOldestTaskTime retrieves when the longest waiting task in a queue was
+// queued, falling back to the creation time of the next task for queues
+// filled before enqueue times were tracked
 func (r *HandoffRepository) getOldestTaskTime(ctx context.Context, queueName string) (time.Time, error) {
+	if enqueued, err := handoff.OldestEnqueued(ctx, r.redis.client, queueName); err == nil && !enqueued.IsZero() {
+		return enqueued, nil
+	}
+
 	// Get the task with the lowest score (highest priority, oldest timestamp)
 	result, err := r.redis.client.ZRangeWithScores(ctx, queueName, 0, 0).Result()
 	if err != nil || len(result) == 0 {
@@ -0,0 +1,91 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/rs/zerolog/log"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/models"
+	"github.com/vot3k/agent-handoff/handoff"
+)
+
+// AutoAgent is the to_agent value that opts a handoff without an agent of its
+// own in to routing; others set route
+const AutoAgent = handoff.AutoRouteAgent
+
+// RoutesFile mirrors the "routes" section of the handoff service configuration,
+// so both services can share one routing config file
+type RoutesFile struct {
+	Agents []handoff.AgentCapabilities    `json:"agents"`
+	Routes map[string][]handoff.RouteRule `json:"routes"`
+}
+
+// Router applies the shared handoff routing rules to agent-manager handoffs
+type Router struct {
+	router *handoff.HandoffRouter
+}
+
+// NewRouter wraps an existing handoff router
+func NewRouter(router *handoff.HandoffRouter) *Router {
+	return &Router{router: router}
+}
+
+// LoadRouter builds a router from a routing config file, rejecting configs with
+// validation errors. Target agents are checked against the file's agents list when present.
+func LoadRouter(path, fallbackAgent string) (*Router, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read routing config: %w", err)
+	}
+
+	var file RoutesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse routing config: %w", err)
+	}
+
+	router := handoff.NewHandoffRouter(fallbackAgent)
+	for fromAgent, rules := range file.Routes {
+		for _, rule := range rules {
+			router.AddRoute(fromAgent, rule)
+		}
+	}
+
+	names := make([]string, len(file.Agents))
+	for i, agent := range file.Agents {
+		names[i] = agent.Name
+	}
+
+	report := router.Validate(names)
+	for _, issue := range report.Warnings() {
+		log.Warn().Stringer("warning", issue).Msg("Routing config warning")
+	}
+	if err := report.Err(); err != nil {
+		return nil, err
+	}
+
+	return NewRouter(router), nil
+}
+
+// Route resolves the target agent for a handoff that requested routing,
+// recording the agent the producer addressed, if any, and the deciding rule on
+// its metadata. When the
+// decision fans out, to_agent is left as requested and the caller creates the children.
+func (r *Router) Route(ctx context.Context, h *models.Handoff) (*handoff.RouteDecision, error) {
+	shared := h.ToShared()
+
+	decision, err := r.router.Route(ctx, shared)
+	if err != nil {
+		return nil, err
+	}
+
+	shared.Metadata.RequestedAgent = shared.Metadata.RequestedTarget()
+	shared.Metadata.RouteRule = decision.RuleName
+	if !decision.IsFanOut() {
+		shared.Metadata.ToAgent = decision.TargetAgent
+	}
+
+	h.UpdateFromShared(shared)
+	return decision, nil
+}
@@ -2,20 +2,38 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/vot3k/agent-handoff/agent-manager/internal/config"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/logging"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/models"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/repository"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/routing"
+	"github.com/vot3k/agent-handoff/handoff"
 
 	"github.com/google/uuid"
+	"github.com/rs/zerolog"
 )
 
 // HandoffService provides business logic for handoff operations
 type HandoffService struct {
-	repo   repository.HandoffRepositoryInterface
-	config *config.Config
+	repo      repository.HandoffRepositoryInterface
+	config    *config.Config
+	router    *routing.Router
+	validator *handoff.HandoffValidator
+	keys      *handoff.KeyRegistry
+	sigPolicy handoff.SignaturePolicy
+	signAll   bool
+	checksums handoff.ChecksumPolicy
+
+	artifactMode handoff.ArtifactVerification
+	projects     handoff.ArtifactProjects
+	blobs        handoff.BlobStore
+	dedup        handoff.DedupPolicy
+	tracer       *handoff.Tracer
 }
 
 // NewHandoffService creates a new handoff service
@@ -26,12 +44,81 @@ func NewHandoffService(repo repository.HandoffRepositoryInterface, cfg *config.C
 	}
 }
 
+// SetValidator enforces a handoff validation policy on created handoffs
+func (s *HandoffService) SetValidator(validator *handoff.HandoffValidator) {
+	s.validator = validator
+}
+
+// SetSigning verifies the signatures producers put on create requests and,
+// unless the policy is off, on handoffs before they are processed. Handoffs
+// from requests with a valid signature are stored signed as their from_agent.
+func (s *HandoffService) SetSigning(keys *handoff.KeyRegistry, policy handoff.SignaturePolicy) {
+	s.keys = keys
+	s.sigPolicy = policy
+}
+
+// SetServerSigning also signs handoffs from unsigned requests as their
+// from_agent. Callers are not authenticated, so anyone reaching the API can
+// then publish as any agent the server holds a key for.
+func (s *HandoffService) SetServerSigning(enabled bool) {
+	s.signAll = enabled
+}
+
+// SetChecksumPolicy decides whether handoffs stored before checksums were
+// added are processed or quarantined; the default is handoff.ChecksumPolicyMigrate
+func (s *HandoffService) SetChecksumPolicy(policy handoff.ChecksumPolicy) {
+	s.checksums = policy
+}
+
+// SetArtifactVerification checks artifact paths of created handoffs against
+// their project directory, found through projects, and records each artifact's
+// size and hash
+func (s *HandoffService) SetArtifactVerification(mode handoff.ArtifactVerification, projects handoff.ArtifactProjects) {
+	s.artifactMode = mode
+	s.projects = projects
+}
+
+// SetBlobStore offloads large technical_details fields of created handoffs to
+// store and serves them through GetOffloadedField
+func (s *HandoffService) SetBlobStore(store handoff.BlobStore) {
+	s.blobs = store
+}
+
+// SetDeduplication returns the existing handoff, with handoff.ErrDuplicateHandoff,
+// for requests repeating the idempotency key (or, with ByContent, the content)
+// of a handoff created within the policy's window
+func (s *HandoffService) SetDeduplication(policy handoff.DedupPolicy) {
+	s.dedup = policy
+}
+
+// SetTracer exports spans for creating and routing handoffs. Created handoffs
+// carry their trace context with or without a tracer.
+func (s *HandoffService) SetTracer(tracer *handoff.Tracer) {
+	s.tracer = tracer
+}
+
+// SetRouter enables routing for handoffs created with to_agent set to "auto"
+func (s *HandoffService) SetRouter(router *routing.Router) {
+	s.router = router
+}
+
 // CreateHandoff creates a new handoff from a request
-func (s *HandoffService) CreateHandoff(ctx context.Context, req *models.CreateHandoffRequest) (*models.Handoff, error) {
+func (s *HandoffService) CreateHandoff(ctx context.Context, req *models.CreateHandoffRequest) (created *models.Handoff, err error) {
+	// Start the handoff's trace, continuing the caller's if it sent one
+	ctx, span := s.tracer.Start(ctx, "handoff.publish", handoff.SpanProducer)
+	defer func() { s.endPublishSpan(span, req, created, err) }()
+
 	// Validate request
 	if err := req.Validate(); err != nil {
 		return nil, fmt.Errorf("validation failed: %w", err)
 	}
+	if err := handoff.ValidateIdempotencyKey(req.IdempotencyKey); err != nil {
+		return nil, fmt.Errorf("validation failed: %w", err)
+	}
+	sign, err := s.authenticate(req)
+	if err != nil {
+		return nil, err
+	}
 
 	// Generate handoff ID
 	handoffID := s.generateHandoffID()
@@ -47,6 +134,9 @@ func (s *HandoffService) CreateHandoff(ctx context.Context, req *models.CreateHa
 			TaskContext: req.TaskContext,
 			Priority:    req.Priority,
 			HandoffID:   handoffID,
+			Route:       req.Route,
+
+			IdempotencyKey: req.IdempotencyKey,
 		},
 		Content: models.HandoffContent{
 			Summary:          req.Summary,
@@ -59,20 +149,294 @@ func (s *HandoffService) CreateHandoff(ctx context.Context, req *models.CreateHa
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
+	trace := span.Context()
+	handoff.Trace = &trace
+
+	// Keep credentials out of Redis, logs and the archive
+	if err := s.scanSecrets(ctx, handoff); err != nil {
+		return nil, err
+	}
+
+	// Fingerprint the handoff as requested, before routing changes it
+	dedupKeys, err := s.dedupKeys(handoff)
+	if err != nil {
+		return nil, err
+	}
+
+	// Resolve the target agent so the handoff lands in the routed agent's queue
+	if handoff.Metadata.Route || handoff.Metadata.ToAgent == routing.AutoAgent {
+		if s.router == nil {
+			return nil, fmt.Errorf("validation failed: route or to_agent %q requires routing to be configured", routing.AutoAgent)
+		}
+		decision, err := s.route(ctx, handoff)
+		if err != nil {
+			return nil, fmt.Errorf("failed to route handoff: %w", err)
+		}
+		if decision.IsFanOut() {
+			return s.createFanOut(ctx, handoff, decision, dedupKeys, sign)
+		}
+	}
 
 	// Validate handoff
 	if err := handoff.Validate(); err != nil {
 		return nil, fmt.Errorf("handoff validation failed: %w", err)
 	}
+	if err := s.validatePolicy(ctx, handoff); err != nil {
+		return nil, err
+	}
+	if err := s.verifyArtifacts(ctx, handoff); err != nil {
+		return nil, err
+	}
+	if err := s.applyPayloadLimits(ctx, handoff, s.blobs); err != nil {
+		return nil, err
+	}
+	if err := s.seal(handoff, sign); err != nil {
+		return nil, err
+	}
+
+	if existing, err := s.claimDedupKeys(ctx, handoff, dedupKeys); err != nil {
+		return existing, err
+	}
 
 	// Store handoff
 	if err := s.repo.Create(ctx, handoff); err != nil {
+		s.releaseDedupKeys(ctx, dedupKeys, handoffID)
 		return nil, fmt.Errorf("failed to create handoff: %w", err)
 	}
 
 	return handoff, nil
 }
 
+// route resolves the target agents of a handoff in a span of its trace
+func (s *HandoffService) route(ctx context.Context, h *models.Handoff) (*handoff.RouteDecision, error) {
+	_, span := s.tracer.Start(ctx, "handoff.route", handoff.SpanInternal)
+	decision, err := s.router.Route(ctx, h)
+	if err == nil {
+		span.SetAttribute("route.rule", decision.RuleName)
+		span.SetAttribute("route.targets", strings.Join(decision.TargetAgents, ","))
+	}
+	span.End(err)
+	return decision, err
+}
+
+// endPublishSpan finishes the span of a create request. A duplicate request
+// is not a failure.
+func (s *HandoffService) endPublishSpan(span *handoff.Span, req *models.CreateHandoffRequest, created *models.Handoff, err error) {
+	span.SetAttribute("handoff.from_agent", req.FromAgent)
+	span.SetAttribute("handoff.project", req.ProjectName)
+	if created != nil {
+		span.SetAttribute("handoff.id", created.Metadata.HandoffID)
+		span.SetAttribute("handoff.to_agent", created.Metadata.ToAgent)
+	}
+	if errors.Is(err, handoff.ErrDuplicateHandoff) {
+		span.SetAttribute("handoff.duplicate", "true")
+		err = nil
+	}
+	span.End(err)
+}
+
+// createFanOut stores a parent handoff with one queued child per routed agent
+func (s *HandoffService) createFanOut(ctx context.Context, parent *models.Handoff, decision *handoff.RouteDecision, dedupKeys []string, sign bool) (*models.Handoff, error) {
+	if err := parent.Validate(); err != nil {
+		return nil, fmt.Errorf("handoff validation failed: %w", err)
+	}
+	if err := s.validatePolicy(ctx, parent); err != nil {
+		return nil, err
+	}
+	if err := s.verifyArtifacts(ctx, parent); err != nil {
+		return nil, err
+	}
+	if err := s.applyPayloadLimits(ctx, parent, s.blobs); err != nil {
+		return nil, err
+	}
+
+	children := make([]*models.Handoff, len(decision.TargetAgents))
+	parent.FanOut = &handoff.FanOut{FailurePolicy: decision.FailurePolicy}
+	for i, target := range decision.TargetAgents {
+		children[i] = parent.NewFanOutChild(target, s.generateHandoffID())
+		parent.FanOut.Children = append(parent.FanOut.Children, handoff.FanOutChild{
+			HandoffID: children[i].Metadata.HandoffID,
+			ToAgent:   target,
+			Status:    handoff.StatusPending,
+		})
+		if err := s.validatePolicy(ctx, children[i]); err != nil {
+			return nil, err
+		}
+		if err := s.seal(children[i], sign); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.seal(parent, sign); err != nil {
+		return nil, err
+	}
+
+	if existing, err := s.claimDedupKeys(ctx, parent, dedupKeys); err != nil {
+		return existing, err
+	}
+
+	if err := s.repo.CreateFanOut(ctx, parent, children); err != nil {
+		s.releaseDedupKeys(ctx, dedupKeys, parent.Metadata.HandoffID)
+		return nil, fmt.Errorf("failed to create handoff: %w", err)
+	}
+
+	return parent, nil
+}
+
+// dedupKeys returns the keys a new handoff claims against duplicates
+func (s *HandoffService) dedupKeys(h *models.Handoff) ([]string, error) {
+	if !s.dedup.Enabled() {
+		return nil, nil
+	}
+
+	fingerprint := ""
+	if s.dedup.ByContent {
+		shared := h.ToShared()
+		var err error
+		if fingerprint, err = handoff.ComputeFingerprint(shared.Metadata, shared.Content); err != nil {
+			return nil, fmt.Errorf("failed to fingerprint handoff: %w", err)
+		}
+	}
+	return s.dedup.Keys(h.Metadata.FromAgent, h.Metadata.IdempotencyKey, fingerprint), nil
+}
+
+// claimDedupKeys claims the handoff's dedup keys. When another handoff holds
+// them it is returned with handoff.ErrDuplicateHandoff; keys held by a handoff
+// that has since expired are taken over.
+func (s *HandoffService) claimDedupKeys(ctx context.Context, h *models.Handoff, keys []string) (*models.Handoff, error) {
+	for attempt := 0; attempt < 2; attempt++ {
+		existingID, err := s.repo.ClaimDedupKeys(ctx, keys, h.Metadata.HandoffID, s.dedup.Window)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check for duplicate handoff: %w", err)
+		}
+		if existingID == "" {
+			return nil, nil
+		}
+
+		existing, err := s.repo.GetByID(ctx, existingID)
+		if errors.Is(err, repository.ErrHandoffNotFound) {
+			if err := s.repo.ReleaseDedupKeys(ctx, keys, existingID); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to retrieve duplicate handoff: %w", err)
+		}
+
+		handoffLogger(ctx, h).Info().Str("existing_handoff_id", existingID).Str("from_agent", h.Metadata.FromAgent).
+			Msg("Duplicate handoff, returning the existing handoff")
+		return existing, fmt.Errorf("%w: %s", handoff.ErrDuplicateHandoff, existingID)
+	}
+	return nil, fmt.Errorf("failed to claim dedup keys for handoff %s", h.Metadata.HandoffID)
+}
+
+// releaseDedupKeys gives up the keys of a handoff that could not be stored
+func (s *HandoffService) releaseDedupKeys(ctx context.Context, keys []string, handoffID string) {
+	if err := s.repo.ReleaseDedupKeys(ctx, keys, handoffID); err != nil {
+		logging.Ctx(ctx).Error().Err(err).Str(logging.FieldHandoffID, handoffID).Msg("Failed to release dedup keys")
+	}
+}
+
+// validatePolicy checks a handoff against the configured validation policy.
+// Violations are returned as a *handoff.ValidationError; warnings are logged.
+func (s *HandoffService) validatePolicy(ctx context.Context, h *models.Handoff) error {
+	if s.validator == nil {
+		return nil
+	}
+
+	report := s.validator.CheckPolicy(h.ToShared())
+	for _, warning := range report.Warnings() {
+		handoffLogger(ctx, h).Warn().Stringer("warning", warning).Msg("Validation warning")
+	}
+	return report.Err()
+}
+
+// scanSecrets scans the handoff's free text for credentials using the
+// validation policy's secrets settings, or the default redacting scanner when no
+// policy is configured. Rejections are returned as a *handoff.ValidationError.
+func (s *HandoffService) scanSecrets(ctx context.Context, h *models.Handoff) error {
+	shared := h.ToShared()
+
+	var report *handoff.HandoffValidationReport
+	if s.validator != nil {
+		report = s.validator.ScanSecrets(shared)
+	} else {
+		report = handoff.ScanSecrets(shared)
+	}
+	for _, warning := range report.Warnings() {
+		handoffLogger(ctx, h).Warn().Stringer("warning", warning).Msg("Secret scan finding")
+	}
+	if err := report.Err(); err != nil {
+		return err
+	}
+
+	h.UpdateFromShared(shared)
+	return nil
+}
+
+// verifyArtifacts resolves the handoff's artifacts against its project tree and
+// records their sizes and hashes. Errors are returned as a *handoff.ValidationError.
+func (s *HandoffService) verifyArtifacts(ctx context.Context, h *models.Handoff) error {
+	if s.artifactMode == "" || s.artifactMode == handoff.ArtifactVerificationOff {
+		return nil
+	}
+
+	shared := h.ToShared()
+	files, report := handoff.VerifyArtifacts(s.projects, h.Metadata.ProjectName, shared.Content.Artifacts, s.artifactMode)
+	for _, warning := range report.Warnings() {
+		handoffLogger(ctx, h).Warn().Stringer("warning", warning).Msg("Artifact verification warning")
+	}
+	if err := report.Err(); err != nil {
+		return err
+	}
+
+	h.Content.ArtifactFiles = files
+	return nil
+}
+
+// applyPayloadLimits offloads large technical_details fields to store, when
+// there is one, and enforces the validation policy's payload limits, or
+// handoff.DefaultPayloadLimits without a policy. Oversized handoffs are
+// returned as a *handoff.ValidationError.
+func (s *HandoffService) applyPayloadLimits(ctx context.Context, h *models.Handoff, store handoff.BlobStore) error {
+	limits := handoff.DefaultPayloadLimits()
+	if s.validator != nil {
+		limits = s.validator.Policy().Payload
+	}
+
+	shared := h.ToShared()
+	report, err := handoff.ApplyPayloadLimits(ctx, store, shared, limits)
+	if err != nil {
+		return fmt.Errorf("failed to offload handoff fields: %w", err)
+	}
+	if err := report.Err(); err != nil {
+		return err
+	}
+
+	h.Content.TechnicalDetails = shared.Content.TechnicalDetails
+	h.Content.Offloaded = shared.Content.Offloaded
+	return nil
+}
+
+// GetOffloadedField returns a technical_details field of a handoff, loading it
+// from the blob store when it was offloaded
+func (s *HandoffService) GetOffloadedField(ctx context.Context, handoffID, field string) (interface{}, error) {
+	h, err := s.GetHandoff(ctx, handoffID)
+	if err != nil {
+		return nil, err
+	}
+	_, offloaded := h.Content.Offloaded[field]
+	if _, inline := h.Content.TechnicalDetails[field]; !offloaded && !inline {
+		return nil, fmt.Errorf("technical_details field %s not found", field)
+	}
+
+	value, err := handoff.LoadOffloaded(ctx, s.blobs, h.ToShared(), field)
+	if errors.Is(err, handoff.ErrBlobNotFound) {
+		return nil, fmt.Errorf("technical_details field %s not found: %w", field, err)
+	}
+	return value, err
+}
+
 // GetHandoff retrieves a handoff by ID
 func (s *HandoffService) GetHandoff(ctx context.Context, handoffID string) (*models.Handoff, error) {
 	if handoffID == "" {
@@ -134,6 +498,8 @@ func (s *HandoffService) UpdateStatus(ctx context.Context, handoffID string, sta
 		return fmt.Errorf("failed to update status: %w", err)
 	}
 
+	s.recordFanOutChild(ctx, handoffID, status)
+
 	return nil
 }
 
@@ -175,11 +541,23 @@ func (s *HandoffService) ProcessNextHandoff(ctx context.Context, queueName strin
 		return nil, fmt.Errorf("failed to get handoff details: %w", err)
 	}
 
+	// Never hand a modified, corrupted or forged handoff to an agent
+	if err := handoff.VerifyChecksum(); s.checksums.Tolerates(err) {
+		handoffLogger(ctx, handoff).Warn().Err(err).Msg("Processing handoff stored before checksums")
+	} else if err != nil {
+		return nil, s.quarantine(ctx, handoff, err)
+	}
+	if err := s.verifySignature(handoff); err != nil {
+		return nil, s.refuseSignature(ctx, handoff, err)
+	}
+
 	// Update status to processing
 	if err := s.repo.UpdateStatus(ctx, handoffID, models.StatusProcessing); err != nil {
 		return nil, fmt.Errorf("failed to update status to processing: %w", err)
 	}
 
+	s.recordFanOutChild(ctx, handoffID, models.StatusProcessing)
+
 	return handoff, nil
 }
 
@@ -220,6 +598,107 @@ func (s *HandoffService) generateHandoffID() string {
 	return uuid.New().String()
 }
 
+// recordFanOutChild reports a child's new status to its fan-out parent. The child's
+// own update has already succeeded, so failures are logged rather than returned.
+func (s *HandoffService) recordFanOutChild(ctx context.Context, handoffID string, status models.HandoffStatus) {
+	child, err := s.repo.GetByID(ctx, handoffID)
+	if err != nil || child == nil || child.Metadata.ParentID == "" {
+		return
+	}
+
+	if err := s.repo.RecordFanOutChild(ctx, child.Metadata.ParentID, handoffID, status); err != nil {
+		handoffLogger(ctx, child).Error().Err(err).Str("parent_id", child.Metadata.ParentID).Msg("Failed to update fan-out parent")
+	}
+}
+
+// authenticate checks the signature a producer put on its create request and
+// reports whether the handoff should be stored signed: when the request was
+// signed by its from_agent, or when server signing is on. With an enforced
+// policy, unsigned requests are refused unless the server signs them.
+func (s *HandoffService) authenticate(req *models.CreateHandoffRequest) (bool, error) {
+	if req.Signature == nil {
+		if s.sigPolicy.Enforced() && !s.signAll {
+			return false, fmt.Errorf("request from %s: %w", req.FromAgent, handoff.ErrSignatureMissing)
+		}
+		return s.signAll, nil
+	}
+	if s.keys == nil {
+		return false, fmt.Errorf("%w: no key registry configured", handoff.ErrSignatureInvalid)
+	}
+	if err := req.VerifySignature(s.keys); err != nil {
+		return false, fmt.Errorf("request from %s: %w", req.FromAgent, err)
+	}
+	return true, nil
+}
+
+// seal checksums a handoff and, if sign is set, signs it as its from_agent
+// just before it is stored. With an enforced signature policy, an agent
+// without a signing key cannot create handoffs since they would be refused
+// when processed.
+func (s *HandoffService) seal(h *models.Handoff, sign bool) error {
+	if err := h.Seal(); err != nil {
+		return err
+	}
+
+	switch {
+	case s.keys == nil && s.sigPolicy.Enforced():
+		return fmt.Errorf("cannot sign handoff: %w %s", handoff.ErrNoSigningKey, h.Metadata.FromAgent)
+	case !sign, s.keys == nil, !s.keys.CanSign(h.Metadata.FromAgent) && !s.sigPolicy.Enforced():
+		return nil
+	}
+	if err := h.Sign(s.keys); err != nil {
+		return fmt.Errorf("cannot sign handoff: %w", err)
+	}
+	return nil
+}
+
+// verifySignature checks a handoff's signature when the policy requires one
+func (s *HandoffService) verifySignature(h *models.Handoff) error {
+	if !s.sigPolicy.Enforced() {
+		return nil
+	}
+	if s.keys == nil {
+		return fmt.Errorf("%w: no key registry configured", handoff.ErrSignatureInvalid)
+	}
+	return h.VerifySignature(s.keys)
+}
+
+// quarantine takes a handoff that failed an integrity or signature check out of
+// circulation; the payload is kept for inspection but never logged
+func (s *HandoffService) quarantine(ctx context.Context, h *models.Handoff, reason error) error {
+	handoffID := h.Metadata.HandoffID
+	logger := handoffLogger(ctx, h)
+	logger.Warn().Str("reason", reason.Error()).Msg("Quarantining handoff")
+	if err := s.repo.Quarantine(ctx, handoffID, reason.Error()); err != nil {
+		logger.Error().Err(err).Msg("Failed to quarantine handoff")
+	}
+	s.recordFanOutChild(ctx, handoffID, models.StatusQuarantined)
+	return fmt.Errorf("handoff %s quarantined: %w", handoffID, reason)
+}
+
+// refuseSignature quarantines or rejects a handoff that failed its signature
+// check, as the signature policy says. Rejected handoffs are marked failed
+// without being processed.
+func (s *HandoffService) refuseSignature(ctx context.Context, h *models.Handoff, reason error) error {
+	if s.sigPolicy == handoff.SignaturePolicyQuarantine {
+		return s.quarantine(ctx, h, reason)
+	}
+
+	handoffID := h.Metadata.HandoffID
+	logger := handoffLogger(ctx, h)
+	logger.Warn().Str("from_agent", h.Metadata.FromAgent).Str("reason", reason.Error()).Msg("Rejecting handoff")
+	if err := s.repo.UpdateStatus(ctx, handoffID, models.StatusFailed); err != nil {
+		logger.Error().Err(err).Msg("Failed to mark handoff failed")
+	}
+	s.recordFanOutChild(ctx, handoffID, models.StatusFailed)
+	return fmt.Errorf("handoff %s rejected: %w", handoffID, reason)
+}
+
+// handoffLogger returns ctx's logger with the handoff's ID, project and agent
+func handoffLogger(ctx context.Context, h *models.Handoff) *zerolog.Logger {
+	return logging.Handoff(ctx, h.Metadata.HandoffID, h.Metadata.ProjectName, h.Metadata.ToAgent)
+}
+
 // validateStatusTransition validates that a status transition is allowed
 func (s *HandoffService) validateStatusTransition(ctx context.Context, handoffID string, newStatus models.HandoffStatus) error {
 	handoff, err := s.repo.GetByID(ctx, handoffID)
@@ -249,6 +728,9 @@ func (s *HandoffService) validateStatusTransition(ctx context.Context, handoffID
 		models.StatusCancelled: {
 			// Terminal state - no transitions allowed
 		},
+		models.StatusQuarantined: {
+			// Terminal state - kept for inspection only
+		},
 	}
 
 	allowedNext, exists := allowedTransitions[currentStatus]
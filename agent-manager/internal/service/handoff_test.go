@@ -2,11 +2,19 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/vot3k/agent-handoff/agent-manager/internal/config"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/models"
 	"github.com/vot3k/agent-handoff/agent-manager/internal/repository"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/routing"
+	"github.com/vot3k/agent-handoff/handoff"
 )
 
 func TestHandoffService_GenerateHandoffID(t *testing.T) {
@@ -42,17 +50,53 @@ func TestHandoffService_GenerateHandoffID(t *testing.T) {
 }
 
 // Mock repository for testing (simplified)
-type MockHandoffRepository struct{}
+type MockHandoffRepository struct {
+	dedupKeys      map[string]string
+	fanOutChildren []*models.Handoff
+	handoffs       map[string]*models.Handoff
+	queue          []string
+	quarantined    []string
+	records        []*models.Handoff
+	statuses       map[string]models.HandoffStatus
+}
 
 func (m *MockHandoffRepository) Create(ctx context.Context, handoff *models.Handoff) error {
+	if m.handoffs != nil {
+		m.handoffs[handoff.Metadata.HandoffID] = handoff
+	}
+	return nil
+}
+
+func (m *MockHandoffRepository) CreateFanOut(ctx context.Context, parent *models.Handoff, children []*models.Handoff) error {
+	m.fanOutChildren = children
+	return nil
+}
+
+func (m *MockHandoffRepository) CreateRecord(ctx context.Context, handoff *models.Handoff) error {
+	m.records = append(m.records, handoff)
+	return nil
+}
+
+func (m *MockHandoffRepository) RecordFanOutChild(ctx context.Context, parentID, childID string, status models.HandoffStatus) error {
 	return nil
 }
 
 func (m *MockHandoffRepository) GetByID(ctx context.Context, handoffID string) (*models.Handoff, error) {
-	return nil, nil
+	if _, ok := m.handoffs[handoffID]; !ok && m.handoffs != nil {
+		return nil, fmt.Errorf("%w: %s", repository.ErrHandoffNotFound, handoffID)
+	}
+	return m.handoffs[handoffID], nil
 }
 
 func (m *MockHandoffRepository) UpdateStatus(ctx context.Context, handoffID string, status models.HandoffStatus) error {
+	if m.statuses != nil {
+		m.statuses[handoffID] = status
+	}
+	return nil
+}
+
+func (m *MockHandoffRepository) Quarantine(ctx context.Context, handoffID, reason string) error {
+	m.quarantined = append(m.quarantined, handoffID)
 	return nil
 }
 
@@ -73,8 +117,719 @@ func (m *MockHandoffRepository) RemoveFromQueue(ctx context.Context, queueName,
 }
 
 func (m *MockHandoffRepository) PopFromQueue(ctx context.Context, queueName string) (string, error) {
+	if len(m.queue) == 0 {
+		return "", nil
+	}
+	handoffID := m.queue[0]
+	m.queue = m.queue[1:]
+	return handoffID, nil
+}
+
+func (m *MockHandoffRepository) ClaimDedupKeys(ctx context.Context, keys []string, handoffID string, window time.Duration) (string, error) {
+	for _, key := range keys {
+		if holder, ok := m.dedupKeys[key]; ok {
+			return holder, nil
+		}
+	}
+	if m.dedupKeys == nil {
+		m.dedupKeys = make(map[string]string)
+	}
+	for _, key := range keys {
+		m.dedupKeys[key] = handoffID
+	}
 	return "", nil
 }
 
+func (m *MockHandoffRepository) ReleaseDedupKeys(ctx context.Context, keys []string, handoffID string) error {
+	for _, key := range keys {
+		if m.dedupKeys[key] == handoffID {
+			delete(m.dedupKeys, key)
+		}
+	}
+	return nil
+}
+
 // Ensure MockHandoffRepository implements the interface at compile time
 var _ repository.HandoffRepositoryInterface = (*MockHandoffRepository)(nil)
+
+func newRoutingTestService() *HandoffService {
+	router := handoff.NewHandoffRouter("project-manager")
+	router.AddRoute("api-expert", handoff.RouteRule{
+		Name:        "route-go-implementation",
+		TargetAgent: "golang-expert",
+		Priority:    100,
+		Conditions: []handoff.RouteCondition{
+			{Type: handoff.ConditionComplexQuery, Field: "has_go_files", Operator: "equals", Value: true},
+		},
+	})
+
+	service := NewHandoffService(&MockHandoffRepository{}, &config.Config{})
+	service.SetRouter(routing.NewRouter(router))
+	return service
+}
+
+func TestHandoffService_CreateHandoffAutoRouting(t *testing.T) {
+	service := newRoutingTestService()
+
+	created, err := service.CreateHandoff(context.Background(), &models.CreateHandoffRequest{
+		ProjectName: "test-project",
+		FromAgent:   "api-expert",
+		ToAgent:     routing.AutoAgent,
+		Summary:     "Implement user endpoints",
+		Artifacts:   map[string][]string{"created": {"handlers/user.go"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateHandoff failed: %v", err)
+	}
+
+	if created.Metadata.ToAgent != "golang-expert" {
+		t.Errorf("expected to_agent golang-expert, got %s", created.Metadata.ToAgent)
+	}
+	if created.Metadata.RequestedAgent != "" {
+		t.Errorf("expected no requested_agent for %q, got %q", routing.AutoAgent, created.Metadata.RequestedAgent)
+	}
+	if created.Metadata.RouteRule != "route-go-implementation" {
+		t.Errorf("expected route_rule route-go-implementation, got %q", created.Metadata.RouteRule)
+	}
+	if got := created.Content.Artifacts["created"]; len(got) != 1 || got[0] != "handlers/user.go" {
+		t.Errorf("expected artifacts to survive routing, got %v", created.Content.Artifacts)
+	}
+}
+
+func TestHandoffService_CreateHandoffRouteKeepsRequestedAgent(t *testing.T) {
+	service := newRoutingTestService()
+	req := func(artifact string) *models.CreateHandoffRequest {
+		return &models.CreateHandoffRequest{
+			ProjectName: "test-project",
+			FromAgent:   "api-expert",
+			ToAgent:     "devops-expert",
+			Route:       true,
+			Summary:     "Implement user endpoints",
+			Artifacts:   map[string][]string{"created": {artifact}},
+		}
+	}
+
+	routed, err := service.CreateHandoff(context.Background(), req("handlers/user.go"))
+	if err != nil {
+		t.Fatalf("CreateHandoff failed: %v", err)
+	}
+	if routed.Metadata.ToAgent != "golang-expert" || routed.Metadata.RequestedAgent != "devops-expert" || !routed.Metadata.Route {
+		t.Errorf("expected golang-expert routed from devops-expert, got %+v", routed.Metadata)
+	}
+
+	kept, err := service.CreateHandoff(context.Background(), req("deploy.yaml"))
+	if err != nil {
+		t.Fatalf("CreateHandoff failed: %v", err)
+	}
+	if kept.Metadata.ToAgent != "devops-expert" || kept.Metadata.RequestedAgent != "devops-expert" || kept.Metadata.RouteRule != "" {
+		t.Errorf("expected devops-expert to be kept without a matching rule, got %+v", kept.Metadata)
+	}
+	if err := kept.VerifyChecksum(); err != nil {
+		t.Errorf("expected the checksum to cover the routing fields, got %v", err)
+	}
+}
+
+func TestHandoffService_CreateHandoffAutoWithoutRouter(t *testing.T) {
+	service := NewHandoffService(&MockHandoffRepository{}, &config.Config{})
+
+	_, err := service.CreateHandoff(context.Background(), &models.CreateHandoffRequest{
+		ProjectName: "test-project",
+		FromAgent:   "api-expert",
+		ToAgent:     routing.AutoAgent,
+		Summary:     "Implement user endpoints",
+	})
+	if err == nil || !strings.Contains(err.Error(), "validation failed") {
+		t.Errorf("expected validation error without a router, got %v", err)
+	}
+}
+
+func TestHandoffService_CreateHandoffFanOut(t *testing.T) {
+	router := handoff.NewHandoffRouter("project-manager")
+	router.AddRoute("api-expert", handoff.RouteRule{
+		Name:         "spec-ready",
+		TargetAgents: []string{"golang-expert", "typescript-expert"},
+		Priority:     100,
+		Conditions: []handoff.RouteCondition{
+			{Type: handoff.ConditionComplexQuery, Field: "has_api_spec", Operator: "equals", Value: true},
+		},
+	})
+
+	repo := &MockHandoffRepository{}
+	service := NewHandoffService(repo, &config.Config{})
+	service.SetRouter(routing.NewRouter(router))
+
+	parent, err := service.CreateHandoff(context.Background(), &models.CreateHandoffRequest{
+		ProjectName: "test-project",
+		FromAgent:   "api-expert",
+		ToAgent:     routing.AutoAgent,
+		Summary:     "API spec finished",
+		Artifacts:   map[string][]string{"created": {"api/openapi.yaml"}},
+	})
+	if err != nil {
+		t.Fatalf("CreateHandoff failed: %v", err)
+	}
+
+	if parent.FanOut == nil || len(parent.FanOut.Children) != 2 {
+		t.Fatalf("expected parent to track two children, got %+v", parent.FanOut)
+	}
+	if parent.FanOut.FailurePolicy != handoff.FanOutFailFast {
+		t.Errorf("expected default fail_fast policy, got %s", parent.FanOut.FailurePolicy)
+	}
+	if len(repo.fanOutChildren) != 2 {
+		t.Fatalf("expected two children to be stored, got %d", len(repo.fanOutChildren))
+	}
+
+	for i, child := range repo.fanOutChildren {
+		if child.Metadata.ParentID != parent.Metadata.HandoffID {
+			t.Errorf("child %d not linked to parent: %q", i, child.Metadata.ParentID)
+		}
+		if child.Metadata.ToAgent != parent.FanOut.Children[i].ToAgent {
+			t.Errorf("child %d addressed to %s, parent tracks %s", i, child.Metadata.ToAgent, parent.FanOut.Children[i].ToAgent)
+		}
+		if child.Metadata.HandoffID == parent.Metadata.HandoffID || child.Metadata.HandoffID != parent.FanOut.Children[i].HandoffID {
+			t.Errorf("child %d has unexpected ID %s", i, child.Metadata.HandoffID)
+		}
+		if got := child.Content.Artifacts["created"]; len(got) != 1 || got[0] != "api/openapi.yaml" {
+			t.Errorf("child %d lost artifacts: %v", i, child.Content.Artifacts)
+		}
+		if err := child.VerifyChecksum(); err != nil {
+			t.Errorf("child %d not sealed: %v", i, err)
+		}
+	}
+}
+
+func TestHandoffService_CreateHandoffValidationPolicy(t *testing.T) {
+	policy := handoff.DefaultValidationPolicy()
+	policy.Agents["rust-expert"] = handoff.AgentValidationPolicy{
+		TechnicalDetails: map[string]handoff.FieldSpec{
+			"edition": {Type: handoff.FieldTypeString, Required: true, Enum: []interface{}{"2018", "2021"}},
+		},
+	}
+	validator, err := handoff.NewHandoffValidatorWithPolicy(policy)
+	if err != nil {
+		t.Fatalf("NewHandoffValidatorWithPolicy failed: %v", err)
+	}
+
+	service := NewHandoffService(&MockHandoffRepository{}, &config.Config{})
+	service.SetValidator(validator)
+
+	req := &models.CreateHandoffRequest{
+		ProjectName:      "test-project",
+		FromAgent:        "api-expert",
+		ToAgent:          "rust-expert",
+		Summary:          "Port the invoice service",
+		Requirements:     []string{"keep the API stable"},
+		TechnicalDetails: map[string]interface{}{"edition": "2015"},
+	}
+	_, err = service.CreateHandoff(context.Background(), req)
+	if err == nil || !strings.Contains(err.Error(), "validation failed") || !strings.Contains(err.Error(), "edition") {
+		t.Errorf("expected edition validation error, got %v", err)
+	}
+
+	req.TechnicalDetails["edition"] = "2021"
+	if _, err := service.CreateHandoff(context.Background(), req); err != nil {
+		t.Errorf("expected valid handoff to be created, got %v", err)
+	}
+}
+
+func TestHandoffService_ProcessNextHandoffQuarantine(t *testing.T) {
+	repo := &MockHandoffRepository{handoffs: make(map[string]*models.Handoff)}
+	service := NewHandoffService(repo, &config.Config{})
+
+	created, err := service.CreateHandoff(context.Background(), &models.CreateHandoffRequest{
+		ProjectName:  "test-project",
+		FromAgent:    "api-expert",
+		ToAgent:      "golang-expert",
+		Summary:      "Implement user endpoints",
+		Artifacts:    map[string][]string{"created": {"api/users.yaml"}},
+		Requirements: []string{"REST API"},
+	})
+	if err != nil {
+		t.Fatalf("CreateHandoff failed: %v", err)
+	}
+	if err := created.VerifyChecksum(); err != nil {
+		t.Fatalf("created handoff not sealed: %v", err)
+	}
+
+	id := created.Metadata.HandoffID
+	repo.handoffs[id] = created
+	repo.queue = []string{id}
+	if _, err := service.ProcessNextHandoff(context.Background(), created.GetQueueName()); err != nil {
+		t.Fatalf("expected intact handoff to be processed, got %v", err)
+	}
+
+	created.Content.Artifacts["created"] = []string{"../../etc/passwd"}
+	repo.queue = []string{id}
+	_, err = service.ProcessNextHandoff(context.Background(), created.GetQueueName())
+	if !errors.Is(err, handoff.ErrChecksumMismatch) {
+		t.Errorf("expected checksum mismatch, got %v", err)
+	}
+	if len(repo.quarantined) != 1 || repo.quarantined[0] != id {
+		t.Errorf("expected handoff %s to be quarantined, got %v", id, repo.quarantined)
+	}
+}
+
+func TestHandoffService_ProcessNextHandoffPreUpgrade(t *testing.T) {
+	// Handoffs stored before checksums were added carry none
+	stored := &models.Handoff{
+		Metadata: models.HandoffMetadata{
+			ProjectName: "test-project",
+			FromAgent:   "api-expert",
+			ToAgent:     "golang-expert",
+			HandoffID:   "handoff-pre-upgrade",
+			Timestamp:   time.Now(),
+		},
+		Content: models.HandoffContent{Summary: "Implement user endpoints"},
+		Status:  models.StatusPending,
+	}
+
+	for _, policy := range []handoff.ChecksumPolicy{"", handoff.ChecksumPolicyMigrate, handoff.ChecksumPolicyStrict} {
+		repo := &MockHandoffRepository{
+			handoffs: map[string]*models.Handoff{stored.Metadata.HandoffID: stored},
+			queue:    []string{stored.Metadata.HandoffID},
+		}
+		service := NewHandoffService(repo, &config.Config{})
+		service.SetChecksumPolicy(policy)
+
+		_, err := service.ProcessNextHandoff(context.Background(), stored.GetQueueName())
+		if policy == handoff.ChecksumPolicyStrict {
+			if !errors.Is(err, handoff.ErrChecksumMissing) || len(repo.quarantined) != 1 {
+				t.Errorf("strict: expected the handoff to be quarantined, got %v", err)
+			}
+			continue
+		}
+		if err != nil || len(repo.quarantined) != 0 {
+			t.Errorf("policy %q: expected the pre-upgrade handoff to be processed, got %v", policy, err)
+		}
+	}
+}
+
+func TestHandoffService_SignatureVerification(t *testing.T) {
+	keys := handoff.NewKeyRegistry()
+	if err := keys.AddHMACKey("api-expert", "api-1", []byte(strings.Repeat("k", 32))); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, policy := range []handoff.SignaturePolicy{handoff.SignaturePolicyReject, handoff.SignaturePolicyQuarantine} {
+		t.Run(string(policy), func(t *testing.T) {
+			repo := &MockHandoffRepository{
+				handoffs: make(map[string]*models.Handoff),
+				statuses: make(map[string]models.HandoffStatus),
+			}
+			service := NewHandoffService(repo, &config.Config{})
+			service.SetSigning(keys, policy)
+
+			req := &models.CreateHandoffRequest{
+				ProjectName: "test-project",
+				FromAgent:   "api-expert",
+				ToAgent:     "golang-expert",
+				Summary:     "Implement user endpoints",
+			}
+
+			// Requests must be signed by their producer
+			if _, err := service.CreateHandoff(context.Background(), req); !errors.Is(err, handoff.ErrSignatureMissing) {
+				t.Fatalf("expected an unsigned request to be refused, got %v", err)
+			}
+			if err := req.Sign(keys); err != nil {
+				t.Fatal(err)
+			}
+			forged := *req
+			forged.Summary = "Drop the users table"
+			if _, err := service.CreateHandoff(context.Background(), &forged); !errors.Is(err, handoff.ErrSignatureInvalid) {
+				t.Fatalf("expected a tampered request to be refused, got %v", err)
+			}
+
+			created, err := service.CreateHandoff(context.Background(), req)
+			if err != nil {
+				t.Fatalf("CreateHandoff failed: %v", err)
+			}
+			if created.Validation.Signature == nil || created.Validation.Signature.KeyID != "api-1" {
+				t.Fatalf("expected handoff to be signed with api-1, got %+v", created.Validation.Signature)
+			}
+
+			id := created.Metadata.HandoffID
+			repo.handoffs[id] = created
+			repo.queue = []string{id}
+			if _, err := service.ProcessNextHandoff(context.Background(), created.GetQueueName()); err != nil {
+				t.Fatalf("expected signed handoff to be processed, got %v", err)
+			}
+
+			// A forger with Redis access can reseal the checksum but not re-sign
+			created.Metadata.FromAgent = "architect-expert"
+			if err := created.Seal(); err != nil {
+				t.Fatal(err)
+			}
+			repo.queue = []string{id}
+			_, err = service.ProcessNextHandoff(context.Background(), created.GetQueueName())
+			if !errors.Is(err, handoff.ErrSignatureMissing) {
+				t.Errorf("expected missing signature, got %v", err)
+			}
+
+			quarantined := len(repo.quarantined) == 1
+			if quarantined != (policy == handoff.SignaturePolicyQuarantine) {
+				t.Errorf("policy %s: quarantined %v", policy, repo.quarantined)
+			}
+			if policy == handoff.SignaturePolicyReject && repo.statuses[id] != models.StatusFailed {
+				t.Errorf("expected rejected handoff to be failed, got %s", repo.statuses[id])
+			}
+
+			// A request signed for another agent does not verify as architect-expert
+			req.FromAgent = "architect-expert"
+			if _, err := service.CreateHandoff(context.Background(), req); !errors.Is(err, handoff.ErrSignatureInvalid) {
+				t.Errorf("expected ErrSignatureInvalid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestHandoffService_ServerSigning(t *testing.T) {
+	keys := handoff.NewKeyRegistry()
+	if err := keys.AddHMACKey("api-expert", "api-1", []byte(strings.Repeat("k", 32))); err != nil {
+		t.Fatal(err)
+	}
+	service := NewHandoffService(&MockHandoffRepository{}, &config.Config{})
+	service.SetSigning(keys, handoff.SignaturePolicyOff)
+
+	req := &models.CreateHandoffRequest{
+		ProjectName: "test-project",
+		FromAgent:   "api-expert",
+		ToAgent:     "golang-expert",
+		Summary:     "Implement user endpoints",
+	}
+	created, err := service.CreateHandoff(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CreateHandoff failed: %v", err)
+	}
+	if created.Validation.Signature != nil {
+		t.Errorf("expected the server not to sign an unsigned request by default, got %+v", created.Validation.Signature)
+	}
+
+	// Opting in makes the server sign for callers it cannot identify
+	service.SetServerSigning(true)
+	if created, err = service.CreateHandoff(context.Background(), req); err != nil {
+		t.Fatalf("CreateHandoff failed: %v", err)
+	}
+	if created.Validation.Signature == nil || created.Validation.Signature.KeyID != "api-1" {
+		t.Errorf("expected handoff to be signed with api-1, got %+v", created.Validation.Signature)
+	}
+
+	// Agents without a key cannot create handoffs under an enforced policy
+	service.SetSigning(keys, handoff.SignaturePolicyReject)
+	req.FromAgent = "architect-expert"
+	if _, err := service.CreateHandoff(context.Background(), req); !errors.Is(err, handoff.ErrNoSigningKey) {
+		t.Errorf("expected ErrNoSigningKey, got %v", err)
+	}
+}
+
+func TestHandoffService_CreateHandoffSecrets(t *testing.T) {
+	service := NewHandoffService(&MockHandoffRepository{}, &config.Config{})
+
+	req := &models.CreateHandoffRequest{
+		ProjectName:      "test-project",
+		FromAgent:        "api-expert",
+		ToAgent:          "golang-expert",
+		Summary:          "Connect with postgres://app:hunter2hunter2@db/app",
+		Requirements:     []string{"REST API"},
+		TechnicalDetails: map[string]interface{}{"api_key": "sk-live-8fK2pQ9xLm3v"},
+	}
+	created, err := service.CreateHandoff(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CreateHandoff failed: %v", err)
+	}
+	if strings.Contains(created.Content.Summary, "hunter2") || created.Content.TechnicalDetails["api_key"] != "[REDACTED:sensitive-key]" {
+		t.Errorf("expected secrets to be redacted by default, got %q and %v", created.Content.Summary, created.Content.TechnicalDetails)
+	}
+	if err := created.VerifyChecksum(); err != nil {
+		t.Errorf("expected checksum over the redacted content, got %v", err)
+	}
+
+	policy := handoff.DefaultValidationPolicy()
+	policy.Secrets.Action = handoff.SecretActionReject
+	validator, err := handoff.NewHandoffValidatorWithPolicy(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.SetValidator(validator)
+
+	req.Summary = "Connect with postgres://app:hunter2hunter2@db/app"
+	req.TechnicalDetails = nil
+	_, err = service.CreateHandoff(context.Background(), req)
+	var validationErr *handoff.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Report.Errors()[0].Code != handoff.ValidationSecret {
+		t.Errorf("expected secret validation error, got %v", err)
+	}
+	if err != nil && strings.Contains(err.Error(), "hunter2") {
+		t.Errorf("error leaks the secret: %v", err)
+	}
+}
+
+func TestHandoffService_CreateHandoffArtifactVerification(t *testing.T) {
+	project := t.TempDir()
+	if err := os.WriteFile(filepath.Join(project, "main.go"), []byte("package main\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	service := NewHandoffService(&MockHandoffRepository{}, &config.Config{})
+	service.SetArtifactVerification(handoff.ArtifactVerificationEnforce, handoff.ArtifactProjects{
+		BaseDir: filepath.Dir(project),
+		Path: func(projectName string) string {
+			if projectName != "test-project" {
+				t.Errorf("expected project path lookup for test-project, got %s", projectName)
+			}
+			return project
+		},
+	})
+
+	req := &models.CreateHandoffRequest{
+		ProjectName:  "test-project",
+		FromAgent:    "api-expert",
+		ToAgent:      "golang-expert",
+		Summary:      "Implement the service entry point",
+		Requirements: []string{"REST API"},
+		Artifacts:    map[string][]string{"created": {"main.go"}},
+	}
+	created, err := service.CreateHandoff(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CreateHandoff failed: %v", err)
+	}
+	files := created.Content.ArtifactFiles
+	if len(files) != 1 || files[0].Path != "main.go" || files[0].Size != 13 || files[0].SHA256 == "" {
+		t.Errorf("expected main.go to be recorded, got %+v", files)
+	}
+	if err := created.VerifyChecksum(); err != nil {
+		t.Errorf("expected checksum to cover the recorded artifacts, got %v", err)
+	}
+
+	for _, artifact := range []string{"missing.go", "../outside.go"} {
+		req.Artifacts = map[string][]string{"created": {artifact}}
+		_, err := service.CreateHandoff(context.Background(), req)
+		var validationErr *handoff.ValidationError
+		if !errors.As(err, &validationErr) {
+			t.Errorf("expected validation error for %s, got %v", artifact, err)
+		}
+	}
+
+	// Project names cannot point the lookup outside the project base directory
+	req.ProjectName = "../../../.."
+	req.Artifacts = map[string][]string{"created": {"etc/passwd"}}
+	_, err = service.CreateHandoff(context.Background(), req)
+	var validationErr *handoff.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Report.Errors()[0].Path != "metadata.project_name" {
+		t.Errorf("expected the project name to be refused, got %v", err)
+	}
+}
+
+func TestHandoffService_CreateHandoffPayloadLimits(t *testing.T) {
+	store, err := handoff.NewFileBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &MockHandoffRepository{}
+	service := NewHandoffService(repo, &config.Config{})
+
+	schema := strings.Repeat("CREATE TABLE invoices (id INT);\n", 40000)
+	req := &models.CreateHandoffRequest{
+		ProjectName:      "test-project",
+		FromAgent:        "api-expert",
+		ToAgent:          "golang-expert",
+		Summary:          "Implement the invoice schema",
+		Requirements:     []string{"REST API"},
+		TechnicalDetails: map[string]interface{}{"schema": schema, "tables": 1},
+	}
+
+	// Without a blob store the handoff is over the default 1 MiB limit
+	_, err = service.CreateHandoff(context.Background(), req)
+	var validationErr *handoff.ValidationError
+	if !errors.As(err, &validationErr) || validationErr.Report.Errors()[0].Code != handoff.ValidationTooLarge {
+		t.Fatalf("expected too_large validation error, got %v", err)
+	}
+
+	service.SetBlobStore(store)
+	created, err := service.CreateHandoff(context.Background(), req)
+	if err != nil {
+		t.Fatalf("CreateHandoff failed: %v", err)
+	}
+	if _, inline := created.Content.TechnicalDetails["schema"]; inline || created.Content.Offloaded["schema"].Size == 0 {
+		t.Errorf("expected schema to be offloaded, got %v", created.Content.Offloaded)
+	}
+	if err := created.VerifyChecksum(); err != nil {
+		t.Errorf("expected checksum over the offloaded form, got %v", err)
+	}
+
+	repo.handoffs = map[string]*models.Handoff{created.Metadata.HandoffID: created}
+	value, err := service.GetOffloadedField(context.Background(), created.Metadata.HandoffID, "schema")
+	if err != nil || value != schema {
+		t.Errorf("expected the offloaded schema back, got %.20v, %v", value, err)
+	}
+	if _, err := service.GetOffloadedField(context.Background(), created.Metadata.HandoffID, "absent"); err == nil || !strings.Contains(err.Error(), "not found") {
+		t.Errorf("expected not found for an unknown field, got %v", err)
+	}
+}
+
+func TestHandoffService_CreateHandoffDeduplication(t *testing.T) {
+	repo := &MockHandoffRepository{handoffs: map[string]*models.Handoff{}}
+	service := NewHandoffService(repo, &config.Config{})
+	service.SetDeduplication(handoff.DedupPolicy{Window: time.Hour})
+
+	newRequest := func(key string) *models.CreateHandoffRequest {
+		return &models.CreateHandoffRequest{
+			ProjectName:    "test-project",
+			FromAgent:      "api-expert",
+			ToAgent:        "golang-expert",
+			Summary:        "Implement invoice endpoints",
+			Requirements:   []string{"REST API"},
+			IdempotencyKey: key,
+		}
+	}
+
+	first, err := service.CreateHandoff(context.Background(), newRequest("invoice-42"))
+	if err != nil {
+		t.Fatalf("CreateHandoff failed: %v", err)
+	}
+
+	retry, err := service.CreateHandoff(context.Background(), newRequest("invoice-42"))
+	if !errors.Is(err, handoff.ErrDuplicateHandoff) {
+		t.Fatalf("expected ErrDuplicateHandoff, got %v", err)
+	}
+	if retry == nil || retry.Metadata.HandoffID != first.Metadata.HandoffID {
+		t.Errorf("expected the existing handoff %s, got %v", first.Metadata.HandoffID, retry)
+	}
+
+	// Without ByContent only the key identifies a retry
+	if _, err := service.CreateHandoff(context.Background(), newRequest("")); err != nil {
+		t.Errorf("expected a request without a key to be created, got %v", err)
+	}
+	if _, err := service.CreateHandoff(context.Background(), newRequest("invoice-43")); err != nil {
+		t.Errorf("expected a different key to be created, got %v", err)
+	}
+
+	service.SetDeduplication(handoff.DedupPolicy{Window: time.Hour, ByContent: true})
+	original, err := service.CreateHandoff(context.Background(), newRequest(""))
+	if err != nil {
+		t.Fatalf("CreateHandoff failed: %v", err)
+	}
+	repeat, err := service.CreateHandoff(context.Background(), newRequest(""))
+	if !errors.Is(err, handoff.ErrDuplicateHandoff) || repeat.Metadata.HandoffID != original.Metadata.HandoffID {
+		t.Errorf("expected identical content to return %s, got %v", original.Metadata.HandoffID, err)
+	}
+
+	// Keys held by a handoff that is gone are taken over
+	service.SetDeduplication(handoff.DedupPolicy{Window: time.Hour})
+	delete(repo.handoffs, first.Metadata.HandoffID)
+	if _, err := service.CreateHandoff(context.Background(), newRequest("invoice-42")); err != nil {
+		t.Errorf("expected the key of an expired handoff to be reused, got %v", err)
+	}
+
+	if _, err := service.CreateHandoff(context.Background(), newRequest("has space")); err == nil || !strings.Contains(err.Error(), "validation failed") {
+		t.Errorf("expected an invalid idempotency key to fail validation, got %v", err)
+	}
+}
+
+func TestHandoffService_ImportHandoff(t *testing.T) {
+	validator, err := handoff.NewHandoffValidatorWithPolicy(handoff.DefaultValidationPolicy())
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &MockHandoffRepository{handoffs: map[string]*models.Handoff{}}
+	service := NewHandoffService(repo, &config.Config{})
+	service.SetValidator(validator)
+
+	newLegacy := func(id string) *models.Handoff {
+		return &models.Handoff{
+			Metadata: models.HandoffMetadata{
+				ProjectName: "test-project",
+				FromAgent:   "api-expert",
+				ToAgent:     "golang-expert",
+				Timestamp:   time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+				TaskContext: "invoices",
+				Priority:    models.PriorityNormal,
+				HandoffID:   id,
+			},
+			Content: models.HandoffContent{
+				Summary:      "Implement invoice endpoints",
+				Requirements: []string{"REST API"},
+				Artifacts:    map[string][]string{},
+				NextSteps:    []string{},
+			},
+			Status: models.StatusCompleted,
+		}
+	}
+
+	// Historical records keep their status and may be older than the policy allows
+	record := newLegacy("legacy-1")
+	if err := service.ImportHandoff(context.Background(), record, ImportOptions{History: true}); err != nil {
+		t.Fatalf("ImportHandoff failed: %v", err)
+	}
+	if len(repo.records) != 1 || repo.records[0].Status != models.StatusCompleted || repo.records[0].Metadata.HandoffID != "legacy-1" {
+		t.Fatalf("expected a completed record, got %+v", repo.records)
+	}
+	if err := repo.records[0].VerifyChecksum(); err != nil {
+		t.Errorf("expected an imported record to be sealed, got %v", err)
+	}
+
+	// Enqueued handoffs are checked against the whole policy
+	err = service.ImportHandoff(context.Background(), newLegacy("legacy-2"), ImportOptions{})
+	var validationErr *handoff.ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a timestamp violation, got %v", err)
+	}
+	current := newLegacy("legacy-2")
+	current.Metadata.Timestamp = time.Now()
+	if err := service.ImportHandoff(context.Background(), current, ImportOptions{}); err != nil {
+		t.Fatalf("ImportHandoff failed: %v", err)
+	}
+	if repo.handoffs["legacy-2"] == nil || repo.handoffs["legacy-2"].Status != models.StatusPending {
+		t.Errorf("expected a pending handoff, got %+v", repo.handoffs["legacy-2"])
+	}
+
+	if err := service.ImportHandoff(context.Background(), newLegacy("legacy-2"), ImportOptions{History: true}); !errors.Is(err, ErrHandoffExists) {
+		t.Errorf("expected ErrHandoffExists, got %v", err)
+	}
+
+	// Handoffs missing required fields are refused before anything is looked up
+	incomplete := newLegacy("legacy-4")
+	incomplete.Content.Summary = ""
+	if err := service.ImportHandoff(context.Background(), incomplete, ImportOptions{History: true}); !errors.Is(err, ErrInvalidHandoff) {
+		t.Errorf("expected ErrInvalidHandoff, got %v", err)
+	}
+
+	// A dry run stores nothing
+	if err := service.ImportHandoff(context.Background(), newLegacy("legacy-3"), ImportOptions{History: true, DryRun: true}); err != nil {
+		t.Errorf("expected the dry run to pass, got %v", err)
+	}
+	if len(repo.records) != 1 || repo.handoffs["legacy-3"] != nil {
+		t.Errorf("expected the dry run to store nothing, got %d records", len(repo.records))
+	}
+}
+
+func TestHandoffService_CreateHandoffTrace(t *testing.T) {
+	service := NewHandoffService(&MockHandoffRepository{}, &config.Config{})
+	req := func() *models.CreateHandoffRequest {
+		return &models.CreateHandoffRequest{
+			ProjectName: "test-project",
+			FromAgent:   "api-expert",
+			ToAgent:     "golang-expert",
+			Summary:     "Implement user endpoints",
+		}
+	}
+
+	parent, err := handoff.ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created, err := service.CreateHandoff(handoff.ContextWithTrace(context.Background(), parent), req())
+	if err != nil {
+		t.Fatalf("CreateHandoff failed: %v", err)
+	}
+	if created.Trace == nil || created.Trace.TraceID != parent.TraceID || created.Trace.SpanID == parent.SpanID || !created.Trace.Sampled {
+		t.Errorf("expected the caller's trace to be continued, got %+v", created.Trace)
+	}
+
+	created, err = service.CreateHandoff(context.Background(), req())
+	if err != nil {
+		t.Fatalf("CreateHandoff failed: %v", err)
+	}
+	if created.Trace == nil || !created.Trace.IsValid() || created.Trace.TraceID == parent.TraceID {
+		t.Errorf("expected a new trace, got %+v", created.Trace)
+	}
+}
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/vot3k/agent-handoff/agent-manager/internal/models"
+	"github.com/vot3k/agent-handoff/agent-manager/internal/repository"
+	"github.com/vot3k/agent-handoff/handoff"
+)
+
+var (
+	// ErrHandoffExists is returned by ImportHandoff for a handoff ID already stored
+	ErrHandoffExists = errors.New("handoff already exists")
+	// ErrInvalidHandoff is returned by ImportHandoff, wrapping the cause, for a
+	// handoff missing required fields or carrying malformed values
+	ErrInvalidHandoff = errors.New("handoff validation failed")
+)
+
+// ImportOptions controls how ImportHandoff stores a migrated legacy handoff
+type ImportOptions struct {
+	History bool // Store as a historical record, neither queued nor expired, instead of enqueuing
+	DryRun  bool // Run every check and seal the handoff, but store nothing
+}
+
+// ImportHandoff validates, seals and stores a handoff migrated from the legacy
+// file system, keeping its ID. Secrets are handled and the validation policy
+// applied as for created handoffs, except that historical records may be older
+// than the policy's timestamp window. Artifacts are not verified: they refer to
+// the project tree as it was. Malformed handoffs are returned as
+// ErrInvalidHandoff, policy violations as a *handoff.ValidationError and an ID
+// that is already stored as ErrHandoffExists.
+func (s *HandoffService) ImportHandoff(ctx context.Context, h *models.Handoff, opts ImportOptions) error {
+	if err := h.Validate(); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidHandoff, err)
+	}
+	if !opts.History {
+		h.Status = models.StatusPending
+	}
+
+	if existing, err := s.repo.GetByID(ctx, h.Metadata.HandoffID); err == nil && existing != nil {
+		return fmt.Errorf("%w: %s", ErrHandoffExists, h.Metadata.HandoffID)
+	} else if err != nil && !errors.Is(err, repository.ErrHandoffNotFound) {
+		return fmt.Errorf("failed to check for existing handoff: %w", err)
+	}
+
+	if err := s.scanSecrets(ctx, h); err != nil {
+		return err
+	}
+	if err := s.validateImportPolicy(ctx, h, opts.History); err != nil {
+		return err
+	}
+
+	// A dry run computes blob references without storing the blobs
+	blobs := s.blobs
+	if opts.DryRun && blobs != nil {
+		blobs = discardBlobStore{}
+	}
+	if err := s.applyPayloadLimits(ctx, h, blobs); err != nil {
+		return err
+	}
+	// The importer runs with the operator's key registry, so it signs as the agents
+	if err := s.seal(h, true); err != nil {
+		return err
+	}
+	if opts.DryRun {
+		return nil
+	}
+
+	if opts.History {
+		if err := s.repo.CreateRecord(ctx, h); err != nil {
+			return fmt.Errorf("failed to import handoff: %w", err)
+		}
+		return nil
+	}
+	if err := s.repo.Create(ctx, h); err != nil {
+		return fmt.Errorf("failed to import handoff: %w", err)
+	}
+	return nil
+}
+
+// validateImportPolicy is validatePolicy, ignoring the timestamp window for
+// historical records
+func (s *HandoffService) validateImportPolicy(ctx context.Context, h *models.Handoff, history bool) error {
+	if s.validator == nil {
+		return nil
+	}
+
+	report := s.validator.CheckPolicy(h.ToShared())
+	if history {
+		kept := report.Violations[:0]
+		for _, violation := range report.Violations {
+			if violation.Path != "metadata.timestamp" {
+				kept = append(kept, violation)
+			}
+		}
+		report.Violations = kept
+	}
+	for _, warning := range report.Warnings() {
+		handoffLogger(ctx, h).Warn().Stringer("warning", warning).Msg("Validation warning for imported handoff")
+	}
+	return report.Err()
+}
+
+// discardBlobStore returns the references blobs would be stored under
+type discardBlobStore struct{}
+
+func (discardBlobStore) Put(ctx context.Context, data []byte) (handoff.BlobRef, error) {
+	return handoff.BlobRef{Digest: fmt.Sprintf("sha256:%x", sha256.Sum256(data)), Size: int64(len(data))}, nil
+}
+
+func (discardBlobStore) Get(ctx context.Context, ref handoff.BlobRef) ([]byte, error) {
+	return nil, fmt.Errorf("%w: %s", handoff.ErrBlobNotFound, ref.Digest)
+}
@@ -10,6 +10,7 @@ import (
 type HandoffServiceInterface interface {
 	CreateHandoff(ctx context.Context, req *models.CreateHandoffRequest) (*models.Handoff, error)
 	GetHandoff(ctx context.Context, handoffID string) (*models.Handoff, error)
+	GetOffloadedField(ctx context.Context, handoffID, field string) (interface{}, error)
 	ListHandoffs(ctx context.Context, projectName string, page, pageSize int) (*models.HandoffListResponse, error)
 	UpdateStatus(ctx context.Context, handoffID string, status models.HandoffStatus) error
 	GetQueues(ctx context.Context, projectName string) ([]models.QueueInfo, error)
@@ -2,11 +2,12 @@ package tools
 
 import (
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+
+	"github.com/rs/zerolog/log"
 )
 
 // ToolInfo contains information about an available tool
@@ -52,7 +53,7 @@ func DetectAvailableTools(projectPath string) (*ToolSet, error) {
 	// Detect project-specific tools
 	detectProjectTools(toolSet, projectPath)
 
-	log.Printf("🔍 Detected %d tools for project type: %s", len(toolSet.Available), toolSet.Project.Type)
+	log.Debug().Int("tools", len(toolSet.Available)).Str("project_type", toolSet.Project.Type).Msg("Detected tools")
 
 	return toolSet, nil
 }
@@ -69,7 +70,7 @@ func detectAITools(toolSet *ToolSet) {
 			Priority:     100,
 			Capabilities: []string{"task", "code-gen", "analysis", "refactor", "test"},
 		}
-		log.Printf("✅ Found Claude Code at: %s", path)
+		log.Debug().Str("tool", "claude").Str("path", path).Msg("Found tool")
 	}
 
 	// Cursor (high priority)
@@ -80,7 +81,7 @@ func detectAITools(toolSet *ToolSet) {
 			Priority:     90,
 			Capabilities: []string{"edit", "generate", "refactor"},
 		}
-		log.Printf("✅ Found Cursor at: %s", path)
+		log.Debug().Str("tool", "cursor").Str("path", path).Msg("Found tool")
 	}
 
 	// VS Code (medium priority)
@@ -91,7 +92,7 @@ func detectAITools(toolSet *ToolSet) {
 			Priority:     80,
 			Capabilities: []string{"edit", "debug", "extension"},
 		}
-		log.Printf("✅ Found VS Code at: %s", path)
+		log.Debug().Str("tool", "vscode").Str("path", path).Msg("Found tool")
 	}
 }
 
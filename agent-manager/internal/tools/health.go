@@ -0,0 +1,18 @@
+package tools
+
+import (
+	"context"
+
+	"github.com/vot3k/agent-handoff/handoff"
+)
+
+// HealthCheck reports how many development tools the agent executor can run
+func HealthCheck() handoff.HealthCheck {
+	return func(ctx context.Context) []handoff.HealthReading {
+		toolSet, err := DetectAvailableTools("")
+		if err != nil {
+			return []handoff.HealthReading{{Component: handoff.HealthExecutorTools, Err: err}}
+		}
+		return []handoff.HealthReading{{Component: handoff.HealthExecutorTools, Value: float64(len(toolSet.Available))}}
+	}
+}
@@ -21,9 +21,21 @@ type OptimizedHandoffAgent struct {
 	capabilities  map[string]AgentCapabilities
 	retryPolicy   RetryPolicy
 	metrics       *HandoffMetrics
+	latency       LatencyHistogram
 	metricsMutex  sync.RWMutex
+	recorder      MetricsRecorder
 	consumers     map[string]context.CancelFunc
 	consumerMutex sync.RWMutex
+	router        *HandoffRouter
+	validator     *HandoffValidator
+	keys          *KeyRegistry
+	sigPolicy     SignaturePolicy
+	checksums     ChecksumPolicy
+	blobs         BlobStore
+	dedup         DedupPolicy
+	sla           SLAPolicy
+	tracer        *Tracer
+	heartbeat     HeartbeatConfig
 }
 
 // OptimizedConfig contains OptimizedHandoffAgent configuration
@@ -68,7 +80,11 @@ func NewOptimizedHandoffAgent(cfg OptimizedConfig) (*OptimizedHandoffAgent, erro
 		metrics: &HandoffMetrics{
 			LastUpdated: time.Now(),
 		},
+		latency:   NewLatencyHistogram(),
+		recorder:  NewMetricsRecorder(DefaultMetricsRetention),
 		consumers: make(map[string]context.CancelFunc),
+		dedup:     DefaultDedupPolicy(),
+		sla:       DefaultSLAPolicy(),
 	}
 
 	logger.Info().
@@ -102,6 +118,14 @@ func (h *OptimizedHandoffAgent) RegisterAgent(cap AgentCapabilities) error {
 	}
 
 	h.capabilities[cap.Name] = cap
+
+	// Record the agent as one that should have a live consumer
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := RegisterAgentName(ctx, h.redisManager.GetClient(), cap.Name); err != nil {
+		h.logger.Warn().Err(err).Str("agent", cap.Name).Msg("Failed to record agent registration")
+	}
+
 	h.logger.Info().
 		Str("agent", cap.Name).
 		Str("queue", cap.QueueName).
@@ -111,17 +135,127 @@ func (h *OptimizedHandoffAgent) RegisterAgent(cap AgentCapabilities) error {
 	return nil
 }
 
-// PublishHandoff publishes a handoff to the appropriate queue with optimized operations
-func (h *OptimizedHandoffAgent) PublishHandoff(ctx context.Context, handoff *Handoff) error {
-	// Validate handoff
-	if err := handoff.Validate(); err != nil {
-		return fmt.Errorf("invalid handoff: %w", err)
+// SetValidator enforces a validation policy on published handoffs in addition
+// to the basic schema checks
+func (h *OptimizedHandoffAgent) SetValidator(validator *HandoffValidator) {
+	h.validator = validator
+}
+
+// SetSigning signs published handoffs with their from_agent's key and, unless
+// the policy is SignaturePolicyOff, verifies signatures before processing
+func (h *OptimizedHandoffAgent) SetSigning(keys *KeyRegistry, policy SignaturePolicy) {
+	h.keys = keys
+	h.sigPolicy = policy
+}
+
+// SetChecksumPolicy decides whether handoffs published before canonical
+// checksums are processed or quarantined; the default is ChecksumPolicyMigrate
+func (h *OptimizedHandoffAgent) SetChecksumPolicy(policy ChecksumPolicy) {
+	h.checksums = policy
+}
+
+// SetBlobStore offloads large technical_details fields of published handoffs
+// to store, and lets consumers load them with LoadOffloaded
+func (h *OptimizedHandoffAgent) SetBlobStore(store BlobStore) {
+	h.blobs = store
+}
+
+// LoadOffloaded returns a technical_details field of a consumed handoff,
+// fetching it from the blob store when it was offloaded
+func (h *OptimizedHandoffAgent) LoadOffloaded(ctx context.Context, handoff *Handoff, field string) (interface{}, error) {
+	return LoadOffloaded(ctx, h.blobs, handoff, field)
+}
+
+// SetDeduplication configures how long idempotency keys, and with ByContent
+// handoff fingerprints, are remembered. DefaultDedupPolicy applies otherwise.
+func (h *OptimizedHandoffAgent) SetDeduplication(policy DedupPolicy) {
+	h.dedup = policy
+}
+
+// SetMetricsRetention sets how long the per-minute metric buckets behind
+// rolling counts and percentiles are kept
+func (h *OptimizedHandoffAgent) SetMetricsRetention(retention time.Duration) {
+	h.recorder = NewMetricsRecorder(retention)
+}
+
+// SetSLAPolicy sets how long handoffs of each priority may wait in their queue;
+// handoffs that start later carry an SLABreach. DefaultSLAPolicy applies otherwise.
+func (h *OptimizedHandoffAgent) SetSLAPolicy(policy SLAPolicy) {
+	h.sla = policy
+}
+
+// SetHeartbeat sets how often consumers send heartbeats, how long they count
+// and the instance ID and version they report
+func (h *OptimizedHandoffAgent) SetHeartbeat(cfg HeartbeatConfig) {
+	h.heartbeat = cfg
+}
+
+// SetTracer exports spans for publishing, routing, queue wait and processing.
+// Trace context is stored on published handoffs with or without a tracer.
+func (h *OptimizedHandoffAgent) SetTracer(tracer *Tracer) {
+	h.tracer = tracer
+}
+
+// SetRouter enables routing for handoffs that opt in with Metadata.Route or a
+// to_agent of AutoRouteAgent
+func (h *OptimizedHandoffAgent) SetRouter(router *HandoffRouter) {
+	h.router = router
+}
+
+// PublishHandoff publishes a handoff to the appropriate queue with optimized operations.
+// A handoff repeating the idempotency key (or, with DedupPolicy.ByContent, the
+// content) of one published within the dedup window is not published again:
+// the existing handoff is copied into handoff and ErrDuplicateHandoff returned.
+func (h *OptimizedHandoffAgent) PublishHandoff(ctx context.Context, handoff *Handoff) (err error) {
+	// Start the handoff's trace, continuing the caller's or a republished handoff's
+	if _, ok := TraceFromContext(ctx); !ok {
+		ctx = ContextWithHandoffTrace(ctx, handoff.Trace)
 	}
+	ctx, span := h.tracer.Start(ctx, "handoff.publish", SpanProducer)
+	span.SetAttribute("handoff.from_agent", handoff.Metadata.FromAgent)
+	defer func() {
+		span.SetAttribute("handoff.id", handoff.Metadata.HandoffID)
+		span.SetAttribute("handoff.to_agent", handoff.Metadata.ToAgent)
+		span.End(err)
+	}()
+	trace := span.Context()
+	handoff.Trace = &trace
 
-	// Set handoff metadata
+	// Fingerprint the handoff as the producer sent it, before routing changes it
+	dedupKeys, err := h.dedupKeys(handoff)
+	if err != nil {
+		return err
+	}
+
+	// Resolve the target agent before validation so the checksum covers it
+	decision, err := h.applyRouting(ctx, handoff)
+	if err != nil {
+		return err
+	}
+	if decision != nil && decision.IsFanOut() {
+		return h.publishFanOut(ctx, handoff, decision, dedupKeys)
+	}
+
+	// Set the ID before validation so the checksum covers it; the checksum is
+	// always computed here so it seals the handoff exactly as published
 	if handoff.Metadata.HandoffID == "" {
 		handoff.Metadata.HandoffID = uuid.New().String()
 	}
+	handoff.Validation.Checksum = ""
+	handoff.Validation.Signature = nil
+
+	// Validate handoff
+	if err := h.validate(handoff); err != nil {
+		return err
+	}
+	if err := h.applyPayloadLimits(ctx, handoff); err != nil {
+		return err
+	}
+	if err := h.sign(handoff); err != nil {
+		return err
+	}
+
+	// Set handoff metadata
 	handoff.Status = StatusPending
 	handoff.CreatedAt = time.Now()
 	handoff.UpdatedAt = time.Now()
@@ -177,18 +311,23 @@ func (h *OptimizedHandoffAgent) PublishHandoff(ctx context.Context, handoff *Han
 				Score:  score,
 				Member: handoff.Metadata.HandoffID,
 			})
+			MarkEnqueued(ctx, pipe, targetCap.QueueName, handoff.Metadata.HandoffID, time.Now())
 			return nil
 		},
 		// Update metrics
 		func(pipe redis.Pipeliner) error {
-			pipe.Incr(ctx, "handoff:metrics:total")
-			pipe.Expire(ctx, "handoff:metrics:total", 24*time.Hour)
+			h.recorder.RecordEvent(ctx, pipe, EventPublished, LabelsOf(handoff))
 			return nil
 		},
 	}
 
+	if err := h.claimDedupKeys(ctx, handoff, dedupKeys); err != nil {
+		return err
+	}
+
 	// Execute all operations in a single batch
 	if err := h.redisManager.ExecuteBatch(ctx, operations); err != nil {
+		h.releaseDedupKeys(ctx, dedupKeys, handoff.Metadata.HandoffID)
 		return fmt.Errorf("failed to publish handoff: %w", err)
 	}
 
@@ -209,6 +348,305 @@ func (h *OptimizedHandoffAgent) PublishHandoff(ctx context.Context, handoff *Han
 	return nil
 }
 
+// applyRouting routes handoffs that opted in to routing and records the agent
+// the producer addressed, if any, and the deciding rule on the handoff
+// metadata. It returns nil when the handoff was not routed. Fan-out decisions leave to_agent as requested;
+// publishFanOut addresses the children.
+func (h *OptimizedHandoffAgent) applyRouting(ctx context.Context, handoff *Handoff) (*RouteDecision, error) {
+	if !handoff.Metadata.WantsRouting() {
+		return nil, nil
+	}
+	if h.router == nil {
+		return nil, fmt.Errorf("handoff requests automatic routing but no router is configured")
+	}
+
+	_, span := h.tracer.Start(ctx, "handoff.route", SpanInternal)
+	decision, err := h.router.Route(ctx, handoff)
+	if err != nil {
+		span.End(err)
+		return nil, fmt.Errorf("failed to route handoff: %w", err)
+	}
+	span.SetAttribute("route.rule", decision.RuleName)
+	span.SetAttribute("route.targets", strings.Join(decision.TargetAgents, ","))
+	span.End(nil)
+
+	handoff.Metadata.RequestedAgent = handoff.Metadata.RequestedTarget()
+	handoff.Metadata.RouteRule = decision.RuleName
+	if !decision.IsFanOut() {
+		handoff.Metadata.ToAgent = decision.TargetAgent
+	}
+
+	h.logger.Debug().
+		Str("from_agent", handoff.Metadata.FromAgent).
+		Strs("to_agents", decision.TargetAgents).
+		Str("to_agent", decision.TargetAgent).
+		Str("route_rule", decision.RuleName).
+		Msg("Handoff routed")
+
+	return decision, nil
+}
+
+// validate scans for secrets, then runs the schema checks and, when configured,
+// the validation policy. Without a validator, secrets are scanned with
+// DefaultSecretScanPolicy so credentials are redacted before storage.
+func (h *OptimizedHandoffAgent) validate(handoff *Handoff) error {
+	var secrets *HandoffValidationReport
+	if h.validator != nil {
+		secrets = h.validator.SanitizeHandoff(handoff)
+	} else {
+		secrets = ScanSecrets(handoff)
+	}
+	for _, warning := range secrets.Warnings() {
+		h.logger.Warn().
+			Str("handoff_id", handoff.Metadata.HandoffID).
+			Str("from_agent", handoff.Metadata.FromAgent).
+			Str("path", warning.Path).
+			Msg(warning.Message)
+	}
+	if err := secrets.Err(); err != nil {
+		return fmt.Errorf("invalid handoff: %w", err)
+	}
+
+	if err := handoff.Validate(); err != nil {
+		return fmt.Errorf("invalid handoff: %w", err)
+	}
+	if h.validator != nil {
+		if err := h.validator.ValidatePolicy(handoff); err != nil {
+			return fmt.Errorf("invalid handoff: %w", err)
+		}
+	}
+	return nil
+}
+
+// dedupKeys returns the keys a handoff claims against duplicates. Fan-out
+// children are published under their parent's claim.
+func (h *OptimizedHandoffAgent) dedupKeys(handoff *Handoff) ([]string, error) {
+	if !h.dedup.Enabled() || handoff.Metadata.ParentID != "" {
+		return nil, nil
+	}
+	if err := ValidateIdempotencyKey(handoff.Metadata.IdempotencyKey); err != nil {
+		return nil, fmt.Errorf("invalid handoff: %w", err)
+	}
+
+	fingerprint := ""
+	if h.dedup.ByContent {
+		var err error
+		if fingerprint, err = ComputeFingerprint(handoff.Metadata, handoff.Content); err != nil {
+			return nil, fmt.Errorf("cannot fingerprint handoff: %w", err)
+		}
+	}
+	return h.dedup.Keys(handoff.Metadata.FromAgent, handoff.Metadata.IdempotencyKey, fingerprint), nil
+}
+
+// claimDedupKeys claims the handoff's dedup keys. When another handoff holds
+// them, that handoff is copied into handoff and ErrDuplicateHandoff returned.
+// Keys held by a handoff that has since expired are taken over.
+func (h *OptimizedHandoffAgent) claimDedupKeys(ctx context.Context, handoff *Handoff, keys []string) error {
+	client := h.redisManager.GetClient()
+	for attempt := 0; attempt < 2; attempt++ {
+		existingID, err := ClaimDedupKeys(ctx, client, keys, handoff.Metadata.HandoffID, h.dedup.Window)
+		if err != nil {
+			return fmt.Errorf("failed to check for duplicate handoff: %w", err)
+		}
+		if existingID == "" {
+			return nil
+		}
+
+		var message HandoffQueueMessage
+		err = h.redisManager.GetWithDeserialization(ctx, fmt.Sprintf("handoff:%s", existingID), &message)
+		if err == redis.Nil {
+			if err := ReleaseDedupKeys(ctx, client, keys, existingID); err != nil {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to retrieve duplicate handoff: %w", err)
+		}
+
+		h.logger.Info().
+			Str("handoff_id", existingID).
+			Str("from_agent", handoff.Metadata.FromAgent).
+			Msg("Duplicate handoff, returning the existing one")
+		*handoff = message.Payload
+		return fmt.Errorf("%w: %s", ErrDuplicateHandoff, existingID)
+	}
+	return fmt.Errorf("failed to claim dedup keys for handoff %s", handoff.Metadata.HandoffID)
+}
+
+// releaseDedupKeys gives up the keys of a handoff that could not be stored
+func (h *OptimizedHandoffAgent) releaseDedupKeys(ctx context.Context, keys []string, handoffID string) {
+	if err := ReleaseDedupKeys(ctx, h.redisManager.GetClient(), keys, handoffID); err != nil {
+		h.logger.Error().Err(err).Str("handoff_id", handoffID).Msg("Failed to release dedup keys")
+	}
+}
+
+// applyPayloadLimits offloads large technical_details fields, when a blob store
+// is configured, and enforces the policy's payload limits, or DefaultPayloadLimits
+// without a validator. The checksum is recomputed over the stored form.
+func (h *OptimizedHandoffAgent) applyPayloadLimits(ctx context.Context, handoff *Handoff) error {
+	limits := DefaultPayloadLimits()
+	if h.validator != nil {
+		limits = h.validator.Policy().Payload
+	}
+
+	report, err := ApplyPayloadLimits(ctx, h.blobs, handoff, limits)
+	if err != nil {
+		return fmt.Errorf("failed to offload handoff fields: %w", err)
+	}
+	if err := report.Err(); err != nil {
+		return fmt.Errorf("invalid handoff: %w", err)
+	}
+	if len(handoff.Content.Offloaded) > 0 {
+		h.logger.Debug().
+			Str("handoff_id", handoff.Metadata.HandoffID).
+			Int("offloaded_fields", len(handoff.Content.Offloaded)).
+			Msg("Large technical details offloaded")
+	}
+
+	handoff.Validation.Checksum = handoff.GenerateChecksum()
+	return nil
+}
+
+// sign signs the handoff as its from_agent when the agent has a signing key.
+// With an enforced signature policy an agent without one cannot publish, since
+// consumers would refuse the handoff.
+func (h *OptimizedHandoffAgent) sign(handoff *Handoff) error {
+	if h.keys == nil {
+		if h.sigPolicy.Enforced() {
+			return fmt.Errorf("cannot sign handoff: %w %s", ErrNoSigningKey, handoff.Metadata.FromAgent)
+		}
+		return nil
+	}
+	if !h.keys.CanSign(handoff.Metadata.FromAgent) && !h.sigPolicy.Enforced() {
+		return nil
+	}
+	if err := h.keys.Sign(handoff); err != nil {
+		return fmt.Errorf("cannot sign handoff: %w", err)
+	}
+	return nil
+}
+
+// publishFanOut stores the parent handoff and publishes one child per target agent.
+// The parent is not queued; its status aggregates the children's outcomes.
+func (h *OptimizedHandoffAgent) publishFanOut(ctx context.Context, parent *Handoff, decision *RouteDecision, dedupKeys []string) error {
+	for _, target := range decision.TargetAgents {
+		if _, exists := h.capabilities[target]; !exists {
+			return fmt.Errorf("target agent %s not registered", target)
+		}
+	}
+
+	if parent.Metadata.HandoffID == "" {
+		parent.Metadata.HandoffID = uuid.New().String()
+	}
+	parent.Validation.Checksum = ""
+	parent.Validation.Signature = nil
+	if err := h.validate(parent); err != nil {
+		return err
+	}
+	if err := h.applyPayloadLimits(ctx, parent); err != nil {
+		return err
+	}
+	if err := h.sign(parent); err != nil {
+		return err
+	}
+	parent.CreatedAt = time.Now()
+
+	children := make([]*Handoff, len(decision.TargetAgents))
+	parent.FanOut = &FanOut{FailurePolicy: decision.FailurePolicy}
+	for i, target := range decision.TargetAgents {
+		children[i] = NewFanOutChild(parent, target, uuid.New().String())
+		parent.FanOut.Children = append(parent.FanOut.Children, FanOutChild{
+			HandoffID: children[i].Metadata.HandoffID,
+			ToAgent:   target,
+			Status:    StatusPending,
+		})
+	}
+
+	if err := h.claimDedupKeys(ctx, parent, dedupKeys); err != nil {
+		return err
+	}
+
+	// Store the parent first so children finishing quickly can find it
+	if err := h.updateHandoffStatusOptimized(ctx, parent, StatusPending); err != nil {
+		h.releaseDedupKeys(ctx, dedupKeys, parent.Metadata.HandoffID)
+		return fmt.Errorf("failed to store fan-out parent: %w", err)
+	}
+
+	for i, child := range children {
+		if err := h.PublishHandoff(ctx, child); err != nil {
+			// Children that were never queued will not report back
+			for _, unpublished := range children[i:] {
+				ApplyFanOutChildStatus(parent, unpublished.Metadata.HandoffID, StatusCancelled)
+			}
+			if updateErr := h.updateHandoffStatusOptimized(ctx, parent, parent.Status); updateErr != nil {
+				h.logger.Error().Err(updateErr).Str("handoff_id", parent.Metadata.HandoffID).Msg("Failed to update fan-out parent")
+			}
+			return fmt.Errorf("failed to publish fan-out child for %s: %w", child.Metadata.ToAgent, err)
+		}
+	}
+
+	h.logger.Info().
+		Str("handoff_id", parent.Metadata.HandoffID).
+		Str("from_agent", parent.Metadata.FromAgent).
+		Strs("to_agents", decision.TargetAgents).
+		Str("failure_policy", string(decision.FailurePolicy)).
+		Msg("Handoff fanned out")
+
+	return nil
+}
+
+// recordFanOutChild updates the fan-out parent of a child handoff with the
+// child's status. Concurrent children are serialized with an optimistic transaction.
+func (h *OptimizedHandoffAgent) recordFanOutChild(ctx context.Context, child *Handoff) {
+	parentID := child.Metadata.ParentID
+	if parentID == "" {
+		return
+	}
+
+	parentKey := fmt.Sprintf("handoff:%s", parentID)
+	client := h.redisManager.GetClient()
+
+	update := func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, parentKey).Bytes()
+		if err != nil {
+			return err
+		}
+
+		var message HandoffQueueMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			return fmt.Errorf("failed to deserialize fan-out parent: %w", err)
+		}
+		if !ApplyFanOutChildStatus(&message.Payload, child.Metadata.HandoffID, child.Status) {
+			return nil
+		}
+
+		updated, err := json.Marshal(message)
+		if err != nil {
+			return fmt.Errorf("failed to serialize fan-out parent: %w", err)
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, parentKey, updated, 24*time.Hour)
+			return nil
+		})
+		return err
+	}
+
+	var err error
+	for attempt := 0; attempt < 10; attempt++ {
+		if err = client.Watch(ctx, update, parentKey); err != redis.TxFailedErr {
+			break
+		}
+	}
+	if err != nil {
+		h.logger.Error().
+			Err(err).
+			Str("handoff_id", child.Metadata.HandoffID).
+			Str("parent_id", parentID).
+			Msg("Failed to update fan-out parent")
+	}
+}
+
 // ConsumeHandoffs starts consuming handoffs for a specific agent with optimized queue operations
 func (h *OptimizedHandoffAgent) ConsumeHandoffs(ctx context.Context, agentName string, handler func(context.Context, *Handoff) error) error {
 	cap, exists := h.capabilities[agentName]
@@ -232,6 +670,12 @@ func (h *OptimizedHandoffAgent) ConsumeHandoffs(ctx context.Context, agentName s
 	// Create semaphore for concurrency control
 	semaphore := make(chan struct{}, cap.MaxConcurrent)
 
+	// Report this instance as a live consumer, with its in-flight handoffs as
+	// load, until the consumer stops
+	heartbeater := NewHeartbeater(h.redisManager.GetClient(), h.heartbeat)
+	heartbeater.Track(agentName, cap.MaxConcurrent, func() int { return len(semaphore) })
+	go heartbeater.Run(consumerCtx)
+
 	// Use optimized queue operations
 	queueOps := h.redisManager.GetQueueOps()
 
@@ -258,6 +702,10 @@ func (h *OptimizedHandoffAgent) ConsumeHandoffs(ctx context.Context, agentName s
 			}
 
 			handoffID := queueResults[0].Member.(string)
+			enqueuedAt, err := TakeEnqueued(consumerCtx, h.redisManager.GetClient(), cap.QueueName, handoffID)
+			if err != nil {
+				h.logger.Error().Err(err).Str("handoff_id", handoffID).Msg("Failed to read enqueue time")
+			}
 
 			// Acquire semaphore
 			select {
@@ -267,22 +715,23 @@ func (h *OptimizedHandoffAgent) ConsumeHandoffs(ctx context.Context, agentName s
 			}
 
 			// Process handoff in goroutine
-			go func(id string) {
+			go func(id string, enqueuedAt time.Time) {
 				defer func() { <-semaphore }()
 
-				if err := h.processHandoffOptimized(consumerCtx, id, handler); err != nil {
+				if err := h.processHandoffOptimized(consumerCtx, id, enqueuedAt, handler); err != nil {
 					h.logger.Error().
 						Err(err).
 						Str("handoff_id", id).
 						Msg("Failed to process handoff")
 				}
-			}(handoffID)
+			}(handoffID, enqueuedAt)
 		}
 	}
 }
 
-// processHandoffOptimized processes a single handoff with optimized Redis operations
-func (h *OptimizedHandoffAgent) processHandoffOptimized(ctx context.Context, handoffID string, handler func(context.Context, *Handoff) error) error {
+// processHandoffOptimized processes a single handoff with optimized Redis
+// operations; enqueuedAt is when it was queued, zero when unknown
+func (h *OptimizedHandoffAgent) processHandoffOptimized(ctx context.Context, handoffID string, enqueuedAt time.Time, handler func(context.Context, *Handoff) error) error {
 	// Retrieve handoff data using optimized operations
 	var message HandoffQueueMessage
 	handoffKey := fmt.Sprintf("handoff:%s", handoffID)
@@ -297,15 +746,45 @@ func (h *OptimizedHandoffAgent) processHandoffOptimized(ctx context.Context, han
 
 	handoff := &message.Payload
 
-	// Update status to processing
+	// Refuse to process a payload that changed since it was published
+	if err := handoff.VerifyChecksum(); h.checksums.Tolerates(err) {
+		h.logger.Warn().Err(err).Str("handoff_id", handoffID).Msg("Processing handoff published before canonical checksums")
+	} else if err != nil {
+		return h.quarantineHandoff(ctx, handoff, err)
+	}
+	if err := h.verifySignature(handoff); err != nil {
+		if h.sigPolicy == SignaturePolicyQuarantine {
+			return h.quarantineHandoff(ctx, handoff, err)
+		}
+		return h.rejectHandoff(ctx, handoff, err)
+	}
+
+	// Trace the wait in the queue and the processing, continuing the publisher's trace
+	ctx = ContextWithHandoffTrace(ctx, handoff.Trace)
+	waitStart := enqueuedAt
+	if waitStart.IsZero() {
+		waitStart = handoff.CreatedAt
+	}
+	_, wait := h.tracer.StartAt(ctx, "handoff.queue_wait", SpanInternal, waitStart)
+	wait.SetAttribute("handoff.id", handoffID)
+	wait.SetAttribute("handoff.priority", string(handoff.Metadata.Priority))
+	wait.End(nil)
+	ctx, span := h.tracer.Start(ctx, "handoff.process", SpanConsumer)
+	span.SetAttribute("handoff.id", handoffID)
+	span.SetAttribute("handoff.to_agent", handoff.Metadata.ToAgent)
+
+	// Update status to processing, with any SLA breach
+	h.checkSLA(ctx, handoff, enqueuedAt)
 	if err := h.updateHandoffStatusOptimized(ctx, handoff, StatusProcessing); err != nil {
 		h.logger.Error().Err(err).Str("handoff_id", handoffID).Msg("Failed to update status")
 	}
+	h.recordFanOutChild(ctx, handoff)
 
 	// Process handoff
 	start := time.Now()
 	err := handler(ctx, handoff)
 	duration := time.Since(start)
+	span.End(err)
 
 	// Update metrics and status using optimized operations
 	success := err == nil
@@ -313,20 +792,16 @@ func (h *OptimizedHandoffAgent) processHandoffOptimized(ctx context.Context, han
 	// Prepare batch operations for metrics update
 	operations := []func(redis.Pipeliner) error{
 		func(pipe redis.Pipeliner) error {
+			event := EventFailed
 			if success {
-				pipe.Incr(ctx, "handoff:metrics:completed")
-			} else {
-				pipe.Incr(ctx, "handoff:metrics:failed")
+				event = EventCompleted
 			}
-			pipe.Expire(ctx, "handoff:metrics:completed", 24*time.Hour)
-			pipe.Expire(ctx, "handoff:metrics:failed", 24*time.Hour)
+			h.recorder.RecordEvent(ctx, pipe, event, LabelsOf(handoff))
 			return nil
 		},
 		func(pipe redis.Pipeliner) error {
 			// Record processing time
-			pipe.LPush(ctx, "handoff:processing_times", duration.String())
-			pipe.LTrim(ctx, "handoff:processing_times", 0, 99) // Keep last 100
-			pipe.Expire(ctx, "handoff:processing_times", 24*time.Hour)
+			h.recorder.RecordProcessingTime(ctx, pipe, LabelsOf(handoff), duration)
 			return nil
 		},
 	}
@@ -348,13 +823,12 @@ func (h *OptimizedHandoffAgent) processHandoffOptimized(ctx context.Context, han
 		handoff.ErrorMsg = ""
 	}
 
-	// Update average processing time
-	totalCompleted := h.metrics.CompletedHandoffs + h.metrics.FailedHandoffs
-	if totalCompleted > 0 {
-		h.metrics.AvgProcessingTime = time.Duration(
-			(int64(h.metrics.AvgProcessingTime)*(totalCompleted-1) + int64(duration)) / totalCompleted,
-		)
-	}
+	// Update processing time percentiles
+	h.latency.Observe(duration)
+	h.metrics.AvgProcessingTime = h.latency.Mean()
+	h.metrics.ProcessingP50 = h.latency.Quantile(0.50)
+	h.metrics.ProcessingP95 = h.latency.Quantile(0.95)
+	h.metrics.ProcessingP99 = h.latency.Quantile(0.99)
 	h.metrics.LastUpdated = time.Now()
 	h.metricsMutex.Unlock()
 
@@ -368,6 +842,7 @@ func (h *OptimizedHandoffAgent) processHandoffOptimized(ctx context.Context, han
 		if h.shouldRetry(err) && handoff.RetryCount < h.retryPolicy.MaxRetries {
 			return h.retryHandoffOptimized(ctx, handoff, err)
 		}
+		h.recordFanOutChild(ctx, handoff)
 
 		h.logger.Error().
 			Err(err).
@@ -381,6 +856,8 @@ func (h *OptimizedHandoffAgent) processHandoffOptimized(ctx context.Context, han
 		return err
 	}
 
+	h.recordFanOutChild(ctx, handoff)
+
 	h.logger.Info().
 		Str("handoff_id", handoffID).
 		Str("from_agent", handoff.Metadata.FromAgent).
@@ -407,6 +884,108 @@ func (h *OptimizedHandoffAgent) updateHandoffStatusOptimized(ctx context.Context
 	return h.redisManager.SetWithOptimizedExpiry(ctx, handoffKey, message, 24*time.Hour)
 }
 
+// checkSLA sets the breach of a handoff starting later than its priority's
+// SLA allows. A breach the monitor recorded while it waited is completed with
+// the start time and not counted again, and retries keep the first breach.
+func (h *OptimizedHandoffAgent) checkSLA(ctx context.Context, handoff *Handoff, enqueuedAt time.Time) {
+	now := time.Now()
+	if breach := handoff.SLABreach; breach != nil {
+		if breach.StartedAt.IsZero() {
+			breach.Started(now)
+		}
+		return
+	}
+	breach := h.sla.Check(handoff.Metadata.Priority, enqueuedAt, now)
+	if breach == nil {
+		return
+	}
+	breach.StartedAt = now
+	handoff.SLABreach = breach
+	h.recordEvent(ctx, handoff, EventSLABreached)
+
+	h.logger.Warn().
+		Str("handoff_id", handoff.Metadata.HandoffID).
+		Str("priority", string(breach.Priority)).
+		Dur("waited", breach.Waited).
+		Dur("target", breach.Target).
+		Msg("Handoff started past its SLA")
+}
+
+// quarantineHandoff takes a handoff that failed its integrity check out of
+// circulation. The payload is never logged.
+func (h *OptimizedHandoffAgent) quarantineHandoff(ctx context.Context, handoff *Handoff, reason error) error {
+	h.logger.Error().
+		Err(reason).
+		Str("handoff_id", handoff.Metadata.HandoffID).
+		Str("to_agent", handoff.Metadata.ToAgent).
+		Msg("Handoff failed integrity check, quarantining")
+
+	// Store the status first: QuarantineHandoff extends the key's expiry
+	handoff.ErrorMsg = reason.Error()
+	if err := h.updateHandoffStatusOptimized(ctx, handoff, StatusQuarantined); err != nil {
+		h.logger.Error().Err(err).Str("handoff_id", handoff.Metadata.HandoffID).Msg("Failed to update status")
+	}
+	if err := QuarantineHandoff(ctx, h.redisManager.GetClient(), handoff.Metadata.HandoffID, reason.Error()); err != nil {
+		return err
+	}
+	h.recordFanOutChild(ctx, handoff)
+	h.recordEvent(ctx, handoff, EventQuarantined)
+
+	h.metricsMutex.Lock()
+	h.metrics.FailedHandoffs++
+	h.metricsMutex.Unlock()
+
+	return fmt.Errorf("handoff %s quarantined: %w", handoff.Metadata.HandoffID, reason)
+}
+
+// verifySignature checks the handoff's signature when the policy requires one
+func (h *OptimizedHandoffAgent) verifySignature(handoff *Handoff) error {
+	if !h.sigPolicy.Enforced() {
+		return nil
+	}
+	if h.keys == nil {
+		return fmt.Errorf("%w: no key registry configured", ErrSignatureInvalid)
+	}
+	return h.keys.Verify(handoff)
+}
+
+// rejectHandoff marks a handoff that failed signature verification as failed
+// without processing or retrying it
+func (h *OptimizedHandoffAgent) rejectHandoff(ctx context.Context, handoff *Handoff, reason error) error {
+	h.logger.Error().
+		Err(reason).
+		Str("handoff_id", handoff.Metadata.HandoffID).
+		Str("from_agent", handoff.Metadata.FromAgent).
+		Str("to_agent", handoff.Metadata.ToAgent).
+		Msg("Handoff failed signature check, rejecting")
+
+	handoff.ErrorMsg = reason.Error()
+	if err := h.updateHandoffStatusOptimized(ctx, handoff, StatusFailed); err != nil {
+		h.logger.Error().Err(err).Str("handoff_id", handoff.Metadata.HandoffID).Msg("Failed to update status")
+	}
+	h.recordFanOutChild(ctx, handoff)
+	h.recordEvent(ctx, handoff, EventFailed)
+
+	h.metricsMutex.Lock()
+	h.metrics.FailedHandoffs++
+	h.metricsMutex.Unlock()
+
+	return fmt.Errorf("handoff %s rejected: %w", handoff.Metadata.HandoffID, reason)
+}
+
+// recordEvent counts a lifecycle event for the handoff's project, agent and priority
+func (h *OptimizedHandoffAgent) recordEvent(ctx context.Context, handoff *Handoff, event HandoffEvent) {
+	operations := []func(redis.Pipeliner) error{
+		func(pipe redis.Pipeliner) error {
+			h.recorder.RecordEvent(ctx, pipe, event, LabelsOf(handoff))
+			return nil
+		},
+	}
+	if err := h.redisManager.ExecuteBatch(ctx, operations); err != nil {
+		h.logger.Error().Err(err).Str("handoff_id", handoff.Metadata.HandoffID).Str("event", string(event)).Msg("Failed to record handoff event")
+	}
+}
+
 // shouldRetry checks if an error is retriable
 func (h *OptimizedHandoffAgent) shouldRetry(err error) bool {
 	errStr := strings.ToLower(err.Error())
@@ -441,6 +1020,7 @@ func (h *OptimizedHandoffAgent) retryHandoffOptimized(ctx context.Context, hando
 	if err := h.updateHandoffStatusOptimized(ctx, handoff, StatusRetrying); err != nil {
 		return fmt.Errorf("failed to update retry status: %w", err)
 	}
+	h.recordEvent(ctx, handoff, EventRetried)
 
 	// Schedule retry by re-queuing with delay
 	go func() {
@@ -460,7 +1040,9 @@ func (h *OptimizedHandoffAgent) retryHandoffOptimized(ctx context.Context, hando
 				Err(err).
 				Str("handoff_id", handoff.Metadata.HandoffID).
 				Msg("Failed to schedule retry")
+			return
 		}
+		MarkEnqueued(ctx, h.redisManager.GetClient(), cap.QueueName, handoff.Metadata.HandoffID, time.Now())
 	}()
 
 	return nil
@@ -497,6 +1079,20 @@ func (h *OptimizedHandoffAgent) GetOptimizedMetrics() (HandoffMetrics, RedisPool
 	return metrics, redisMetrics
 }
 
+// CollectMetrics returns the queue, event and processing time metrics stored
+// in Redis together with this agent's totals and Redis pool metrics, for
+// serving with MetricsHandler
+func (h *OptimizedHandoffAgent) CollectMetrics(ctx context.Context) (*MetricsSnapshot, error) {
+	snapshot, err := CollectMetrics(ctx, h.redisManager.GetClient())
+	if err != nil {
+		return nil, err
+	}
+	metrics, pool := h.GetOptimizedMetrics()
+	snapshot.Handoff = &metrics
+	snapshot.Pool = &pool
+	return snapshot, nil
+}
+
 // GetHandoffStatus retrieves the current status of a handoff using optimized operations
 func (h *OptimizedHandoffAgent) GetHandoffStatus(ctx context.Context, handoffID string) (*Handoff, error) {
 	var message HandoffQueueMessage
@@ -557,7 +1153,6 @@ func (h *OptimizedHandoffAgent) PerformMaintenance(ctx context.Context) error {
 	expiredPatterns := []string{
 		"handoff:*",
 		"handoff:metrics:*",
-		"handoff:processing_times",
 	}
 	
 	if err := h.redisManager.CleanupExpiredKeys(ctx, expiredPatterns); err != nil {
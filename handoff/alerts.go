@@ -0,0 +1,651 @@
+package handoff
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// AlertCondition is a parsed AlertRule condition such as
+//
+//	queue_depth > 100
+//	processing_p95{agent="golang-expert",priority!="low"} > 30000
+//	failure_rate by (agent) >= 10
+//
+// Label matchers select the series the metric is computed over; with by, the
+// condition is evaluated, and alerts fire and resolve, for each distinct value
+// of the listed labels.
+type AlertCondition struct {
+	Metric    string         `json:"metric"`
+	Matchers  []LabelMatcher `json:"matchers,omitempty"`
+	GroupBy   []string       `json:"group_by,omitempty"`
+	Op        string         `json:"op"`
+	Threshold float64        `json:"threshold"`
+}
+
+// LabelMatcher selects series by one label
+type LabelMatcher struct {
+	Label  string `json:"label"`
+	Value  string `json:"value"`
+	Negate bool   `json:"negate,omitempty"` // != instead of =
+}
+
+// AlertState is where a rule's condition stands for one label set
+type AlertState string
+
+const (
+	AlertPending  AlertState = "pending" // Condition holds but not yet for the rule's Duration
+	AlertFiring   AlertState = "firing"
+	AlertResolved AlertState = "resolved" // Condition cleared after firing; only sent as an event
+)
+
+// AlertStatus is the state of a rule for one label set
+type AlertStatus struct {
+	Rule      string            `json:"rule"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	State     AlertState        `json:"state"`
+	Value     float64           `json:"value"`
+	Since     time.Time         `json:"since"` // When the condition started to hold
+	LastFired time.Time         `json:"last_fired,omitempty"`
+}
+
+// alertMetric is a metric conditions can refer to. Queue metrics are computed
+// over the matching queues, window metrics over the matching rolling series,
+// SLA metrics over the matching handoffs waiting past their SLA, agent
+// metrics over the matching registered or heartbeating agents, and scalar
+// metrics have no labels.
+type alertMetric struct {
+	labels     []string
+	queue      func(queues []QueueMetrics) float64
+	window     func(series RollingSeries) float64
+	sla        func(breaches []WaitingBreach) float64
+	agent      func(agents []AgentLiveness) float64
+	scalar     func(src *alertSource) float64
+	legacyType AlertType // Rule type whose legacy conditions use this metric
+}
+
+var (
+	queueMetricLabels  = []string{"project", "agent"}
+	windowMetricLabels = []string{"project", "agent", "priority"}
+	agentMetricLabels  = []string{"agent"}
+)
+
+// alertMetrics are the metrics conditions can refer to. Times are in
+// milliseconds except queue_oldest_age, in seconds; window metrics cover the
+// monitor's metrics window.
+var alertMetrics = map[string]alertMetric{
+	"queue_depth": {labels: queueMetricLabels, queue: func(queues []QueueMetrics) float64 {
+		var depth int64
+		for _, q := range queues {
+			depth += q.Depth
+		}
+		return float64(depth)
+	}},
+	"queue_oldest_age": {labels: queueMetricLabels, legacyType: AlertQueueAge, queue: func(queues []QueueMetrics) float64 {
+		var oldest time.Duration
+		for _, q := range queues {
+			if q.OldestAge > oldest {
+				oldest = q.OldestAge
+			}
+		}
+		return oldest.Seconds()
+	}},
+	"processing_time": {labels: windowMetricLabels, window: func(s RollingSeries) float64 {
+		return milliseconds(s.Processing.Mean())
+	}},
+	"processing_p50": {labels: windowMetricLabels, window: func(s RollingSeries) float64 {
+		return milliseconds(s.Processing.Quantile(0.50))
+	}},
+	"processing_p95": {labels: windowMetricLabels, window: func(s RollingSeries) float64 {
+		return milliseconds(s.Processing.Quantile(0.95))
+	}},
+	"processing_p99": {labels: windowMetricLabels, window: func(s RollingSeries) float64 {
+		return milliseconds(s.Processing.Quantile(0.99))
+	}},
+	"failure_rate": {labels: windowMetricLabels, window: func(s RollingSeries) float64 {
+		if s.Counts[EventPublished] == 0 {
+			return 0
+		}
+		return float64(s.Counts[EventFailed]) / float64(s.Counts[EventPublished]) * 100
+	}},
+	"published":    windowCount(EventPublished),
+	"completed":    windowCount(EventCompleted),
+	"failed":       windowCount(EventFailed),
+	"retried":      windowCount(EventRetried),
+	"quarantined":  windowCount(EventQuarantined),
+	"sla_breached": windowCount(EventSLABreached),
+	"queue_sla_breaches": {labels: windowMetricLabels, sla: func(breaches []WaitingBreach) float64 {
+		return float64(len(breaches))
+	}},
+	"active_agents": {scalar: func(src *alertSource) float64 {
+		return float64(len(src.metrics.ActiveAgents))
+	}},
+	"agent_instances": {labels: agentMetricLabels, agent: func(agents []AgentLiveness) float64 {
+		instances := 0
+		for _, a := range agents {
+			instances += len(a.Instances)
+		}
+		return float64(instances)
+	}},
+	"agent_load": {labels: agentMetricLabels, agent: func(agents []AgentLiveness) float64 {
+		load := 0
+		for _, a := range agents {
+			load += a.Load()
+		}
+		return float64(load)
+	}},
+	"agents_without_consumer": {labels: agentMetricLabels, legacyType: AlertAgentHealth, agent: func(agents []AgentLiveness) float64 {
+		missing := 0
+		for _, a := range agents {
+			if a.Registered && len(a.Instances) == 0 {
+				missing++
+			}
+		}
+		return float64(missing)
+	}},
+	"system_health": {scalar: func(src *alertSource) float64 {
+		return src.systemHealth
+	}},
+}
+
+func windowCount(event HandoffEvent) alertMetric {
+	return alertMetric{labels: windowMetricLabels, window: func(s RollingSeries) float64 {
+		return float64(s.Counts[event])
+	}}
+}
+
+func milliseconds(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// legacyOperators are the operator names conditions could be given as before
+// expressions, comparing the metric named by the rule's Type with Threshold
+var legacyOperators = map[string]string{
+	"":              ">",
+	"greater_than":  ">",
+	"less_than":     "<",
+	"equals":        "==",
+	"greater_equal": ">=",
+	"less_equal":    "<=",
+	">":             ">",
+	"<":             "<",
+	"=":             "==",
+	"==":            "==",
+	">=":            ">=",
+	"<=":            "<=",
+	"!=":            "!=",
+}
+
+// ConditionForRule returns the parsed condition of a rule. A condition that is
+// empty or only an operator ("greater_than", ">", ...) compares the metric
+// named by the rule's Type with its Threshold.
+func ConditionForRule(rule AlertRule) (*AlertCondition, error) {
+	if op, ok := legacyOperators[strings.TrimSpace(rule.Condition)]; ok {
+		metric := string(rule.Type)
+		for name, m := range alertMetrics {
+			if m.legacyType != "" && m.legacyType == rule.Type {
+				metric = name
+			}
+		}
+		if _, ok := alertMetrics[metric]; !ok {
+			return nil, fmt.Errorf("alert rule %s: unknown alert type %q", rule.Name, rule.Type)
+		}
+		return &AlertCondition{Metric: metric, Op: op, Threshold: rule.Threshold}, nil
+	}
+	condition, err := ParseAlertCondition(rule.Condition)
+	if err != nil {
+		return nil, fmt.Errorf("alert rule %s: %w", rule.Name, err)
+	}
+	return condition, nil
+}
+
+// ParseAlertCondition parses a condition expression:
+//
+//	metric [{label="value", label!="value", ...}] [by (label, ...)] op number
+//
+// where op is one of >, >=, <, <=, == or !=
+func ParseAlertCondition(expr string) (*AlertCondition, error) {
+	p := &conditionParser{input: expr}
+	condition, err := p.parse()
+	if err != nil {
+		return nil, fmt.Errorf("invalid condition %q: %w", expr, err)
+	}
+	return condition, nil
+}
+
+type conditionParser struct {
+	input string
+	pos   int
+}
+
+func (p *conditionParser) parse() (*AlertCondition, error) {
+	c := &AlertCondition{}
+	c.Metric = p.identifier()
+	if c.Metric == "" {
+		return nil, fmt.Errorf("expected a metric name at offset %d", p.pos)
+	}
+	metric, ok := alertMetrics[c.Metric]
+	if !ok {
+		return nil, fmt.Errorf("unknown metric %q", c.Metric)
+	}
+
+	if p.consume("{") {
+		for !p.consume("}") {
+			if len(c.Matchers) > 0 && !p.consume(",") {
+				return nil, fmt.Errorf("expected , or } at offset %d", p.pos)
+			}
+			m := LabelMatcher{Label: p.identifier()}
+			if m.Label == "" {
+				return nil, fmt.Errorf("expected a label name at offset %d", p.pos)
+			}
+			switch {
+			case p.consume("!="):
+				m.Negate = true
+			case p.consume("="):
+			default:
+				return nil, fmt.Errorf("expected = or != after %s", m.Label)
+			}
+			value, err := p.quoted()
+			if err != nil {
+				return nil, err
+			}
+			m.Value = value
+			c.Matchers = append(c.Matchers, m)
+		}
+	}
+
+	if p.keyword("by") {
+		if !p.consume("(") {
+			return nil, fmt.Errorf("expected ( after by")
+		}
+		for !p.consume(")") {
+			if len(c.GroupBy) > 0 && !p.consume(",") {
+				return nil, fmt.Errorf("expected , or ) at offset %d", p.pos)
+			}
+			label := p.identifier()
+			if label == "" {
+				return nil, fmt.Errorf("expected a label name at offset %d", p.pos)
+			}
+			c.GroupBy = append(c.GroupBy, label)
+		}
+	}
+
+	for _, op := range []string{">=", "<=", "==", "!=", ">", "<"} {
+		if p.consume(op) {
+			c.Op = op
+			break
+		}
+	}
+	if c.Op == "" {
+		return nil, fmt.Errorf("expected a comparison operator at offset %d", p.pos)
+	}
+	p.skipSpace()
+	threshold, err := strconv.ParseFloat(strings.TrimSpace(p.input[p.pos:]), 64)
+	if err != nil || math.IsNaN(threshold) {
+		return nil, fmt.Errorf("expected a number after %s", c.Op)
+	}
+	c.Threshold = threshold
+
+	for _, m := range c.Matchers {
+		if !hasLabel(metric.labels, m.Label) {
+			return nil, fmt.Errorf("metric %s has no label %q", c.Metric, m.Label)
+		}
+	}
+	for _, label := range c.GroupBy {
+		if !hasLabel(metric.labels, label) {
+			return nil, fmt.Errorf("metric %s has no label %q", c.Metric, label)
+		}
+	}
+	return c, nil
+}
+
+func (p *conditionParser) skipSpace() {
+	for p.pos < len(p.input) && unicode.IsSpace(rune(p.input[p.pos])) {
+		p.pos++
+	}
+}
+
+func (p *conditionParser) consume(token string) bool {
+	p.skipSpace()
+	if strings.HasPrefix(p.input[p.pos:], token) {
+		p.pos += len(token)
+		return true
+	}
+	return false
+}
+
+func (p *conditionParser) identifier() string {
+	p.skipSpace()
+	start := p.pos
+	for p.pos < len(p.input) {
+		c := p.input[p.pos]
+		if c != '_' && !unicode.IsLetter(rune(c)) && !(p.pos > start && unicode.IsDigit(rune(c))) {
+			break
+		}
+		p.pos++
+	}
+	return p.input[start:p.pos]
+}
+
+// keyword consumes word when it is the next identifier
+func (p *conditionParser) keyword(word string) bool {
+	start := p.pos
+	if p.identifier() == word {
+		return true
+	}
+	p.pos = start
+	return false
+}
+
+func (p *conditionParser) quoted() (string, error) {
+	p.skipSpace()
+	if p.pos >= len(p.input) || p.input[p.pos] != '"' {
+		return "", fmt.Errorf("expected a quoted label value at offset %d", p.pos)
+	}
+	end := p.pos + 1
+	for end < len(p.input) && p.input[end] != '"' {
+		if p.input[end] == '\\' {
+			end++
+		}
+		end++
+	}
+	if end >= len(p.input) {
+		return "", fmt.Errorf("unterminated label value at offset %d", p.pos)
+	}
+	value, err := strconv.Unquote(p.input[p.pos : end+1])
+	if err != nil {
+		return "", fmt.Errorf("invalid label value at offset %d: %w", p.pos, err)
+	}
+	p.pos = end + 1
+	return value, nil
+}
+
+func hasLabel(labels []string, label string) bool {
+	for _, l := range labels {
+		if l == label {
+			return true
+		}
+	}
+	return false
+}
+
+// String returns the condition as an expression
+func (c *AlertCondition) String() string {
+	var b strings.Builder
+	b.WriteString(c.Metric)
+	if len(c.Matchers) > 0 {
+		b.WriteByte('{')
+		for i, m := range c.Matchers {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			op := "="
+			if m.Negate {
+				op = "!="
+			}
+			fmt.Fprintf(&b, "%s%s%q", m.Label, op, m.Value)
+		}
+		b.WriteByte('}')
+	}
+	if len(c.GroupBy) > 0 {
+		fmt.Fprintf(&b, " by (%s)", strings.Join(c.GroupBy, ", "))
+	}
+	fmt.Fprintf(&b, " %s %s", c.Op, formatFloat(c.Threshold))
+	return b.String()
+}
+
+// holds compares a value with the threshold
+func (c *AlertCondition) holds(value float64) bool {
+	switch c.Op {
+	case ">":
+		return value > c.Threshold
+	case ">=":
+		return value >= c.Threshold
+	case "<":
+		return value < c.Threshold
+	case "<=":
+		return value <= c.Threshold
+	case "==":
+		return value == c.Threshold
+	case "!=":
+		return value != c.Threshold
+	}
+	return false
+}
+
+// alertSource is what conditions are evaluated against
+type alertSource struct {
+	metrics      *HandoffMetrics
+	queues       []QueueMetrics
+	rolling      *RollingMetrics
+	breaches     []WaitingBreach
+	agents       []AgentLiveness
+	systemHealth float64
+}
+
+// alertSample is a condition's value for one group of labels
+type alertSample struct {
+	labels map[string]string
+	value  float64
+	holds  bool
+}
+
+// evaluate computes the condition's value for each group. Without by there is
+// a single, unlabelled group, evaluated even when no series match.
+func (c *AlertCondition) evaluate(src *alertSource) []alertSample {
+	metric := alertMetrics[c.Metric]
+	if metric.scalar != nil {
+		value := metric.scalar(src)
+		return []alertSample{{value: value, holds: c.holds(value)}}
+	}
+
+	type series struct {
+		labels map[string]string
+		queue  QueueMetrics
+		window RollingSeries
+		breach WaitingBreach
+		agent  AgentLiveness
+	}
+	var all []series
+	if metric.queue != nil {
+		for _, q := range src.queues {
+			all = append(all, series{labels: map[string]string{"project": q.Project, "agent": q.Agent}, queue: q})
+		}
+	} else if metric.agent != nil {
+		for _, a := range src.agents {
+			all = append(all, series{labels: map[string]string{"agent": a.Agent}, agent: a})
+		}
+	} else if metric.sla != nil {
+		for _, b := range src.breaches {
+			all = append(all, series{labels: map[string]string{"project": b.Labels.Project, "agent": b.Labels.Agent, "priority": string(b.Labels.Priority)}, breach: b})
+		}
+	} else if src.rolling != nil {
+		for _, s := range src.rolling.Series {
+			all = append(all, series{labels: map[string]string{"project": s.Labels.Project, "agent": s.Labels.Agent, "priority": string(s.Labels.Priority)}, window: s})
+		}
+	}
+
+	groups := map[string][]series{}
+	groupLabels := map[string]map[string]string{}
+	if len(c.GroupBy) == 0 {
+		groups[""] = nil
+		groupLabels[""] = nil
+	}
+	for _, s := range all {
+		if !c.matches(s.labels) {
+			continue
+		}
+		var labels map[string]string
+		if len(c.GroupBy) > 0 {
+			labels = make(map[string]string, len(c.GroupBy))
+			for _, label := range c.GroupBy {
+				labels[label] = s.labels[label]
+			}
+		}
+		key := labelsKey(labels)
+		groups[key] = append(groups[key], s)
+		groupLabels[key] = labels
+	}
+
+	keys := make([]string, 0, len(groups))
+	for key := range groups {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	samples := make([]alertSample, 0, len(keys))
+	for _, key := range keys {
+		var value float64
+		if metric.queue != nil {
+			queues := make([]QueueMetrics, len(groups[key]))
+			for i, s := range groups[key] {
+				queues[i] = s.queue
+			}
+			value = metric.queue(queues)
+		} else if metric.sla != nil {
+			breaches := make([]WaitingBreach, len(groups[key]))
+			for i, s := range groups[key] {
+				breaches[i] = s.breach
+			}
+			value = metric.sla(breaches)
+		} else if metric.agent != nil {
+			agents := make([]AgentLiveness, len(groups[key]))
+			for i, s := range groups[key] {
+				agents[i] = s.agent
+			}
+			value = metric.agent(agents)
+		} else {
+			total := RollingSeries{Counts: map[HandoffEvent]int64{}, Processing: NewLatencyHistogram()}
+			for _, s := range groups[key] {
+				for event, count := range s.window.Counts {
+					total.Counts[event] += count
+				}
+				total.Processing.Merge(s.window.Processing)
+			}
+			value = metric.window(total)
+		}
+		samples = append(samples, alertSample{labels: groupLabels[key], value: value, holds: c.holds(value)})
+	}
+	return samples
+}
+
+func (c *AlertCondition) matches(labels map[string]string) bool {
+	for _, m := range c.Matchers {
+		if (labels[m.Label] == m.Value) == m.Negate {
+			return false
+		}
+	}
+	return true
+}
+
+// labelsKey identifies a label set
+func labelsKey(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s=%q,", name, labels[name])
+	}
+	return b.String()
+}
+
+// formatLabels writes labels as {name="value",...} for messages
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	return "{" + strings.TrimSuffix(labelsKey(labels), ",") + "}"
+}
+
+// alertTracker follows a rule's alert state for each label set
+type alertTracker struct {
+	states map[string]*AlertStatus
+}
+
+func newAlertTracker() *alertTracker {
+	return &alertTracker{states: make(map[string]*AlertStatus)}
+}
+
+// alertTransition is a firing or resolution to announce
+type alertTransition struct {
+	status AlertStatus
+	state  AlertState
+}
+
+// observe advances the states with one evaluation. A condition must hold
+// for the rule's Duration before it fires; a firing alert is announced again
+// every Cooldown (when set) while it holds, and resolved when it no longer
+// holds or its group disappears.
+func (t *alertTracker) observe(rule AlertRule, samples []alertSample, now time.Time) []alertTransition {
+	var transitions []alertTransition
+	seen := make(map[string]bool, len(samples))
+
+	for _, sample := range samples {
+		key := labelsKey(sample.labels)
+		seen[key] = true
+		status := t.states[key]
+
+		if !sample.holds {
+			if status != nil && status.State == AlertFiring {
+				status.Value = sample.value
+				transitions = append(transitions, alertTransition{status: *status, state: AlertResolved})
+			}
+			delete(t.states, key)
+			continue
+		}
+
+		if status == nil {
+			status = &AlertStatus{Rule: rule.Name, Labels: sample.labels, State: AlertPending, Since: now}
+			t.states[key] = status
+		}
+		status.Value = sample.value
+
+		switch status.State {
+		case AlertPending:
+			if now.Sub(status.Since) >= rule.Duration {
+				status.State = AlertFiring
+				status.LastFired = now
+				transitions = append(transitions, alertTransition{status: *status, state: AlertFiring})
+			}
+		case AlertFiring:
+			if rule.Cooldown > 0 && now.Sub(status.LastFired) >= rule.Cooldown {
+				status.LastFired = now
+				transitions = append(transitions, alertTransition{status: *status, state: AlertFiring})
+			}
+		}
+	}
+
+	for key, status := range t.states {
+		if seen[key] {
+			continue
+		}
+		if status.State == AlertFiring {
+			transitions = append(transitions, alertTransition{status: *status, state: AlertResolved})
+		}
+		delete(t.states, key)
+	}
+	return transitions
+}
+
+// statuses returns the pending and firing states, ordered by labels
+func (t *alertTracker) statuses() []AlertStatus {
+	keys := make([]string, 0, len(t.states))
+	for key := range t.states {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	statuses := make([]AlertStatus, len(keys))
+	for i, key := range keys {
+		statuses[i] = *t.states[key]
+	}
+	return statuses
+}
@@ -0,0 +1,193 @@
+package handoff
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseAlertCondition(t *testing.T) {
+	tests := []struct {
+		expr    string
+		want    string
+		wantErr string
+	}{
+		{expr: "queue_depth > 100", want: "queue_depth > 100"},
+		{expr: `processing_p95{agent="golang-expert", priority!="low"}>=30000`, want: `processing_p95{agent="golang-expert",priority!="low"} >= 30000`},
+		{expr: "failure_rate by (project, agent) > 12.5", want: "failure_rate by (project, agent) > 12.5"},
+		{expr: `queue_oldest_age{project="billing"} by (agent) != 0`, want: `queue_oldest_age{project="billing"} by (agent) != 0`},
+		{expr: "system_health < 50", want: "system_health < 50"},
+		{expr: "queue_size > 100", wantErr: `unknown metric "queue_size"`},
+		{expr: `queue_depth{priority="high"} > 1`, wantErr: `metric queue_depth has no label "priority"`},
+		{expr: "system_health by (agent) < 50", wantErr: `metric system_health has no label "agent"`},
+		{expr: `queue_depth{agent=golang-expert} > 1`, wantErr: "expected a quoted label value"},
+		{expr: `queue_depth{agent="golang-expert" > 1`, wantErr: "expected , or }"},
+		{expr: "queue_depth 100", wantErr: "expected a comparison operator"},
+		{expr: "queue_depth > lots", wantErr: "expected a number"},
+		{expr: "", wantErr: "expected a metric name"},
+	}
+
+	for _, tt := range tests {
+		condition, err := ParseAlertCondition(tt.expr)
+		if tt.wantErr != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("%q: expected error containing %q, got %v", tt.expr, tt.wantErr, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q: %v", tt.expr, err)
+			continue
+		}
+		if got := condition.String(); got != tt.want {
+			t.Errorf("%q: parsed as %q, want %q", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestConditionForRule(t *testing.T) {
+	condition, err := ConditionForRule(AlertRule{Name: "slow", Type: AlertProcessingTime, Condition: "greater_than", Threshold: 30000})
+	if err != nil || condition.String() != "processing_time > 30000" {
+		t.Errorf("unexpected legacy condition %v, %v", condition, err)
+	}
+	condition, err = ConditionForRule(AlertRule{Name: "agents", Type: AlertAgentHealth, Condition: ">", Threshold: 0})
+	if err != nil || condition.String() != "agents_without_consumer > 0" {
+		t.Errorf("unexpected legacy condition %v, %v", condition, err)
+	}
+	if _, err := ConditionForRule(AlertRule{Name: "typo", Type: "queue_size", Condition: "greater_than"}); err == nil {
+		t.Error("expected an unknown type to be refused")
+	}
+	if _, err := ConditionForRule(AlertRule{Name: "broken", Condition: "queue_depth >"}); err == nil || !strings.Contains(err.Error(), "alert rule broken") {
+		t.Errorf("expected the rule name in the error, got %v", err)
+	}
+}
+
+func TestAlertConditionEvaluate(t *testing.T) {
+	var slow LatencyHistogram
+	for i := 0; i < 10; i++ {
+		slow.Observe(45 * time.Second)
+	}
+	src := &alertSource{
+		metrics: &HandoffMetrics{ActiveAgents: []string{"golang-expert"}},
+		queues: []QueueMetrics{
+			{Project: "billing", Agent: "golang-expert", Depth: 80, OldestAge: 5 * time.Minute},
+			{Project: "auth", Agent: "golang-expert", Depth: 40, OldestAge: 20 * time.Minute},
+			{Project: "billing", Agent: "test-expert", Depth: 3},
+		},
+		rolling: &RollingMetrics{Series: []RollingSeries{
+			{Labels: MetricLabels{Project: "billing", Agent: "golang-expert", Priority: PriorityHigh}, Counts: map[HandoffEvent]int64{EventPublished: 10, EventFailed: 4}, Processing: slow},
+			{Labels: MetricLabels{Project: "billing", Agent: "test-expert", Priority: PriorityLow}, Counts: map[HandoffEvent]int64{EventPublished: 10}},
+		}},
+		breaches: []WaitingBreach{
+			{HandoffID: "a", Labels: MetricLabels{Project: "billing", Agent: "golang-expert", Priority: PriorityCritical}},
+			{HandoffID: "b", Labels: MetricLabels{Project: "auth", Agent: "golang-expert", Priority: PriorityCritical}},
+			{HandoffID: "c", Labels: MetricLabels{Project: "billing", Agent: "test-expert", Priority: PriorityLow}},
+		},
+		agents: []AgentLiveness{
+			{Agent: "golang-expert", Registered: true, Instances: []AgentHeartbeat{{InstanceID: "a", Load: 2}, {InstanceID: "b", Load: 1}}},
+			{Agent: "test-expert", Registered: true},
+			{Agent: "scratch-agent", Instances: []AgentHeartbeat{{InstanceID: "c"}}},
+		},
+	}
+
+	evaluate := func(expr string) []alertSample {
+		condition, err := ParseAlertCondition(expr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return condition.evaluate(src)
+	}
+
+	if samples := evaluate("queue_depth > 100"); len(samples) != 1 || samples[0].value != 123 || !samples[0].holds {
+		t.Errorf("expected total depth 123, got %+v", samples)
+	}
+	if samples := evaluate(`queue_depth{agent="golang-expert",project!="auth"} > 100`); samples[0].value != 80 || samples[0].holds {
+		t.Errorf("expected the matching queue only, got %+v", samples)
+	}
+	samples := evaluate("queue_oldest_age by (agent) > 600")
+	if len(samples) != 2 || samples[0].labels["agent"] != "golang-expert" || samples[0].value != 1200 || !samples[0].holds || samples[1].holds {
+		t.Errorf("expected one sample per agent, got %+v", samples)
+	}
+	samples = evaluate("failure_rate by (agent) >= 20")
+	if len(samples) != 2 || samples[0].value != 40 || !samples[0].holds || samples[1].value != 0 {
+		t.Errorf("unexpected failure rates %+v", samples)
+	}
+	if samples := evaluate(`processing_p95{priority="high"} > 30000`); !samples[0].holds {
+		t.Errorf("expected slow high priority processing, got %+v", samples)
+	}
+	if samples := evaluate(`failure_rate{agent="api-expert"} by (agent) > 0`); len(samples) != 0 {
+		t.Errorf("expected no groups without matching series, got %+v", samples)
+	}
+	if samples := evaluate(`published{agent="api-expert"} < 1`); len(samples) != 1 || !samples[0].holds {
+		t.Errorf("expected an ungrouped condition to hold with no series, got %+v", samples)
+	}
+	samples = evaluate("queue_sla_breaches by (agent, priority) > 0")
+	if len(samples) != 2 || samples[0].labels["priority"] != "critical" || samples[0].value != 2 || samples[1].value != 1 {
+		t.Errorf("unexpected SLA breaches %+v", samples)
+	}
+	if samples := evaluate(`queue_sla_breaches{priority="high"} > 0`); len(samples) != 1 || samples[0].holds {
+		t.Errorf("expected no high priority breaches, got %+v", samples)
+	}
+	if samples := evaluate("active_agents < 1"); samples[0].value != 1 || samples[0].holds {
+		t.Errorf("unexpected active agents %+v", samples)
+	}
+	samples = evaluate("agent_instances by (agent) < 1")
+	if len(samples) != 3 || samples[2].labels["agent"] != "test-expert" || !samples[2].holds || samples[0].value != 2 || samples[0].holds {
+		t.Errorf("expected test-expert to have no live instances, got %+v", samples)
+	}
+	if samples := evaluate("agents_without_consumer > 0"); samples[0].value != 1 || !samples[0].holds {
+		t.Errorf("expected one registered agent without a consumer, got %+v", samples)
+	}
+	if samples := evaluate(`agent_load{agent="golang-expert"} >= 3`); !samples[0].holds {
+		t.Errorf("expected golang-expert's instances' load to add up, got %+v", samples)
+	}
+}
+
+func TestAlertTracker(t *testing.T) {
+	rule := AlertRule{Name: "stale-queue", Duration: 2 * time.Minute, Cooldown: 10 * time.Minute}
+	tracker := newAlertTracker()
+	start := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	golang := map[string]string{"agent": "golang-expert"}
+	test := map[string]string{"agent": "test-expert"}
+
+	step := func(minutes int, samples ...alertSample) []alertTransition {
+		return tracker.observe(rule, samples, start.Add(time.Duration(minutes)*time.Minute))
+	}
+
+	// A single spike does not fire
+	if got := step(0, alertSample{labels: golang, value: 900, holds: true}); len(got) != 0 {
+		t.Fatalf("expected the condition to be pending, got %+v", got)
+	}
+	if statuses := tracker.statuses(); len(statuses) != 1 || statuses[0].State != AlertPending {
+		t.Fatalf("expected a pending alert, got %+v", statuses)
+	}
+	if got := step(1, alertSample{labels: golang, value: 30}); len(got) != 0 || len(tracker.statuses()) != 0 {
+		t.Fatalf("expected a cleared pending alert to be dropped silently, got %+v", got)
+	}
+
+	// A sustained condition fires once Duration has passed
+	step(2, alertSample{labels: golang, value: 900, holds: true}, alertSample{labels: test, value: 700, holds: true})
+	step(3, alertSample{labels: golang, value: 950, holds: true}, alertSample{labels: test, value: 10})
+	got := step(4, alertSample{labels: golang, value: 1000, holds: true})
+	if len(got) != 1 || got[0].state != AlertFiring || got[0].status.Labels["agent"] != "golang-expert" || !got[0].status.Since.Equal(start.Add(2*time.Minute)) {
+		t.Fatalf("expected golang-expert to fire, got %+v", got)
+	}
+
+	// Firing alerts repeat after the cooldown only
+	if got := step(10, alertSample{labels: golang, value: 1000, holds: true}); len(got) != 0 {
+		t.Errorf("expected no repeat within the cooldown, got %+v", got)
+	}
+	if got := step(14, alertSample{labels: golang, value: 1000, holds: true}); len(got) != 1 || got[0].state != AlertFiring {
+		t.Errorf("expected a repeat after the cooldown, got %+v", got)
+	}
+
+	// A group that clears or disappears resolves
+	if got := step(15, alertSample{labels: golang, value: 40}); len(got) != 1 || got[0].state != AlertResolved || got[0].status.Value != 40 {
+		t.Errorf("expected a resolution, got %+v", got)
+	}
+	step(16, alertSample{labels: test, value: 900, holds: true})
+	step(18, alertSample{labels: test, value: 900, holds: true})
+	if got := step(19); len(got) != 1 || got[0].state != AlertResolved || got[0].status.Labels["agent"] != "test-expert" {
+		t.Errorf("expected a vanished group to resolve, got %+v", got)
+	}
+}
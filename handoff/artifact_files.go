@@ -0,0 +1,218 @@
+package handoff
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ArtifactVerification controls whether artifact paths are checked against the
+// project tree when a handoff is created
+type ArtifactVerification string
+
+const (
+	ArtifactVerificationOff     ArtifactVerification = "off"
+	ArtifactVerificationWarn    ArtifactVerification = "warn"    // Missing artifacts are warnings
+	ArtifactVerificationEnforce ArtifactVerification = "enforce" // Missing artifacts are errors
+)
+
+// ParseArtifactVerification parses a verification mode; an empty name means off
+func ParseArtifactVerification(name string) (ArtifactVerification, error) {
+	switch mode := ArtifactVerification(strings.ToLower(strings.TrimSpace(name))); mode {
+	case "":
+		return ArtifactVerificationOff, nil
+	case ArtifactVerificationOff, ArtifactVerificationWarn, ArtifactVerificationEnforce:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown artifact verification %q (expected off, warn or enforce)", name)
+	}
+}
+
+// ArtifactProjects locates the project trees artifacts are verified against
+type ArtifactProjects struct {
+	BaseDir string                          // Every project root must resolve inside this directory
+	Path    func(projectName string) string // Returns the root directory of a project
+}
+
+// projectNamePattern is what a project name may contain when it is used to
+// find a directory: a single path element of plain characters
+var projectNamePattern = regexp.MustCompile(`^[A-Za-z0-9._-]+$`)
+
+// CheckProjectName refuses project names that could name a directory other
+// than the project's own, such as "..", "a/b" or names with other characters
+func CheckProjectName(name string) error {
+	if !projectNamePattern.MatchString(name) {
+		return fmt.Errorf("project name %q may only contain letters, digits, '.', '_' and '-'", name)
+	}
+	if name == "." || strings.Contains(name, "..") {
+		return fmt.Errorf("project name %q may not be or contain a relative directory", name)
+	}
+	return nil
+}
+
+// ArtifactFile records an artifact as it was when the handoff was created
+type ArtifactFile struct {
+	Path     string `json:"path" yaml:"path"`
+	Category string `json:"category" yaml:"category"`
+	Size     int64  `json:"size" yaml:"size"`
+	SHA256   string `json:"sha256" yaml:"sha256"`
+}
+
+// unsafeArtifactPath explains why an artifact path could refer to a file
+// outside the project, or returns "" if it cannot
+func unsafeArtifactPath(artifact string) string {
+	slashed := filepath.ToSlash(artifact)
+	if filepath.IsAbs(artifact) || strings.HasPrefix(slashed, "/") || filepath.VolumeName(artifact) != "" {
+		return "is absolute; artifact paths are relative to the project root"
+	}
+	if cleaned := path.Clean(slashed); cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "escapes the project root"
+	}
+	return ""
+}
+
+// VerifyArtifacts resolves every artifact path against the root of the named
+// project. Project names that are not plain directory names and roots outside
+// projects.BaseDir are errors, so nothing outside the base directory is read.
+// Paths that are absolute or escape the project, directly or through a
+// symlink, are errors. Missing artifacts are errors with
+// ArtifactVerificationEnforce and warnings otherwise. Each artifact found is
+// recorded with its size and SHA-256, in category order.
+func VerifyArtifacts(projects ArtifactProjects, projectName string, artifacts Artifacts, mode ArtifactVerification) ([]ArtifactFile, *HandoffValidationReport) {
+	report := &HandoffValidationReport{}
+	if mode == ArtifactVerificationOff {
+		return nil, report
+	}
+
+	if err := CheckProjectName(projectName); err != nil {
+		report.add("metadata.project_name", ValidationInvalidValue, "%v", err)
+		return nil, report
+	}
+	base, err := resolveProjectRoot(projects.BaseDir)
+	if err != nil {
+		report.add("metadata.project_name", ValidationNotFound, "project base directory cannot be resolved: %v", err)
+		return nil, report
+	}
+	var projectRoot string
+	if projects.Path != nil {
+		projectRoot = projects.Path(projectName)
+	}
+	root, err := resolveProjectRoot(projectRoot)
+	if err != nil {
+		report.add("metadata.project_name", ValidationNotFound, "project directory cannot be resolved: %v", err)
+		return nil, report
+	}
+	if !withinDir(base, root) {
+		report.add("metadata.project_name", ValidationPathEscape, "project directory of %s is outside the project base directory", projectName)
+		return nil, report
+	}
+
+	var files []ArtifactFile
+	categories := []struct {
+		name  string
+		paths []string
+	}{
+		{"created", artifacts.Created},
+		{"modified", artifacts.Modified},
+		{"reviewed", artifacts.Reviewed},
+	}
+	for _, category := range categories {
+		for i, artifact := range category.paths {
+			fieldPath := indexPath("content.artifacts."+category.name, i)
+			if file, ok := verifyArtifact(report, fieldPath, root, artifact, mode); ok {
+				file.Category = category.name
+				files = append(files, file)
+			}
+		}
+	}
+	return files, report
+}
+
+// resolveProjectRoot returns a directory as a clean absolute path with its
+// symlinks resolved
+func resolveProjectRoot(projectRoot string) (string, error) {
+	if projectRoot == "" {
+		return "", fmt.Errorf("no project path")
+	}
+	root, err := filepath.Abs(projectRoot)
+	if err != nil {
+		return "", err
+	}
+	if root, err = filepath.EvalSymlinks(root); err != nil {
+		return "", err
+	}
+	if info, err := os.Stat(root); err != nil {
+		return "", err
+	} else if !info.IsDir() {
+		return "", fmt.Errorf("%s is not a directory", root)
+	}
+	return root, nil
+}
+
+func verifyArtifact(report *HandoffValidationReport, fieldPath, root, artifact string, mode ArtifactVerification) (ArtifactFile, bool) {
+	if reason := unsafeArtifactPath(artifact); reason != "" {
+		report.add(fieldPath, ValidationPathEscape, "artifact %s %s", artifact, reason)
+		return ArtifactFile{}, false
+	}
+
+	resolved, err := filepath.EvalSymlinks(filepath.Join(root, filepath.FromSlash(artifact)))
+	if os.IsNotExist(err) {
+		if mode == ArtifactVerificationEnforce {
+			report.add(fieldPath, ValidationNotFound, "artifact %s does not exist in the project", artifact)
+		} else {
+			report.warn(fieldPath, ValidationNotFound, "artifact %s does not exist in the project", artifact)
+		}
+		return ArtifactFile{}, false
+	}
+	if err != nil {
+		report.add(fieldPath, ValidationInvalidValue, "artifact %s cannot be resolved: %v", artifact, err)
+		return ArtifactFile{}, false
+	}
+	if !withinDir(root, resolved) {
+		report.add(fieldPath, ValidationPathEscape, "artifact %s resolves outside the project root", artifact)
+		return ArtifactFile{}, false
+	}
+
+	info, err := os.Stat(resolved)
+	if err != nil {
+		report.add(fieldPath, ValidationInvalidValue, "artifact %s cannot be read: %v", artifact, err)
+		return ArtifactFile{}, false
+	}
+	if !info.Mode().IsRegular() {
+		report.warn(fieldPath, ValidationInvalidType, "artifact %s is not a regular file and is not hashed", artifact)
+		return ArtifactFile{}, false
+	}
+
+	size, hash, err := hashFile(resolved)
+	if err != nil {
+		report.add(fieldPath, ValidationInvalidValue, "artifact %s cannot be read: %v", artifact, err)
+		return ArtifactFile{}, false
+	}
+	return ArtifactFile{Path: artifact, Size: size, SHA256: hash}, true
+}
+
+// withinDir reports whether the resolved path is dir or inside it
+func withinDir(dir, resolved string) bool {
+	relative, err := filepath.Rel(dir, resolved)
+	return err == nil && relative != ".." && !strings.HasPrefix(relative, ".."+string(filepath.Separator))
+}
+
+func hashFile(name string) (int64, string, error) {
+	file, err := os.Open(name)
+	if err != nil {
+		return 0, "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", err
+	}
+	return size, fmt.Sprintf("%x", hash.Sum(nil)), nil
+}
@@ -0,0 +1,185 @@
+package handoff
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newArtifactProject creates a project tree with one source file and a
+// sibling file outside the project, returning the project directory
+func newArtifactProject(t *testing.T) string {
+	t.Helper()
+	base := t.TempDir()
+	project := filepath.Join(base, "project")
+	if err := os.MkdirAll(filepath.Join(project, "internal", "api"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(project, "internal", "api", "handler.go"), []byte("package api\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "secrets.env"), []byte("TOKEN=x\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return project
+}
+
+// projectsAt locates every project at root, confined to base
+func projectsAt(base, root string) ArtifactProjects {
+	return ArtifactProjects{BaseDir: base, Path: func(string) string { return root }}
+}
+
+func TestVerifyArtifacts(t *testing.T) {
+	project := newArtifactProject(t)
+	if err := os.Symlink(filepath.Join(project, "..", "secrets.env"), filepath.Join(project, "link.env")); err != nil {
+		t.Fatal(err)
+	}
+
+	artifacts := Artifacts{
+		Created:  []string{"internal/api/handler.go", "internal/api/missing.go"},
+		Modified: []string{"../secrets.env", "/etc/passwd", "internal/../../secrets.env"},
+		Reviewed: []string{"link.env", "internal/api"},
+	}
+
+	tests := []struct {
+		mode     ArtifactVerification
+		errors   []string
+		warnings []string
+	}{
+		{
+			mode: ArtifactVerificationEnforce,
+			errors: []string{
+				"content.artifacts.created[1]:not_found",
+				"content.artifacts.modified[0]:path_escape",
+				"content.artifacts.modified[1]:path_escape",
+				"content.artifacts.modified[2]:path_escape",
+				"content.artifacts.reviewed[0]:path_escape",
+			},
+			warnings: []string{"content.artifacts.reviewed[1]:invalid_type"},
+		},
+		{
+			mode: ArtifactVerificationWarn,
+			errors: []string{
+				"content.artifacts.modified[0]:path_escape",
+				"content.artifacts.modified[1]:path_escape",
+				"content.artifacts.modified[2]:path_escape",
+				"content.artifacts.reviewed[0]:path_escape",
+			},
+			warnings: []string{
+				"content.artifacts.created[1]:not_found",
+				"content.artifacts.reviewed[1]:invalid_type",
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.mode), func(t *testing.T) {
+			files, report := VerifyArtifacts(projectsAt(filepath.Dir(project), project), "project", artifacts, tt.mode)
+
+			if got := violationKeys(report.Errors()); strings.Join(got, ",") != strings.Join(tt.errors, ",") {
+				t.Errorf("errors = %v, want %v", got, tt.errors)
+			}
+			if got := violationKeys(report.Warnings()); strings.Join(got, ",") != strings.Join(tt.warnings, ",") {
+				t.Errorf("warnings = %v, want %v", got, tt.warnings)
+			}
+
+			want := ArtifactFile{
+				Path:     "internal/api/handler.go",
+				Category: "created",
+				Size:     12,
+				SHA256:   fmt.Sprintf("%x", sha256.Sum256([]byte("package api\n"))),
+			}
+			if len(files) != 1 || files[0] != want {
+				t.Errorf("recorded %+v, want [%+v]", files, want)
+			}
+		})
+	}
+}
+
+func TestVerifyArtifactsProjectRoot(t *testing.T) {
+	artifacts := Artifacts{Created: []string{"main.go"}}
+
+	base := t.TempDir()
+	files, report := VerifyArtifacts(projectsAt(base, filepath.Join(base, "absent")), "absent", artifacts, ArtifactVerificationWarn)
+	if files != nil || !report.HasErrors() || report.Errors()[0].Code != ValidationNotFound {
+		t.Errorf("expected missing project to be an error, got %v", report.Violations)
+	}
+
+	files, report = VerifyArtifacts(ArtifactProjects{}, "", artifacts, ArtifactVerificationOff)
+	if files != nil || len(report.Violations) != 0 {
+		t.Errorf("expected verification off to do nothing, got %v %v", files, report.Violations)
+	}
+}
+
+func TestVerifyArtifactsConfinesProject(t *testing.T) {
+	project := newArtifactProject(t)
+	base := filepath.Dir(project)
+	artifacts := Artifacts{Created: []string{"internal/api/handler.go"}}
+
+	for _, name := range []string{"../../..", "a/b", `a\b`, ".", "..", "app..v2", "my app", ""} {
+		files, report := VerifyArtifacts(projectsAt(base, project), name, artifacts, ArtifactVerificationEnforce)
+		if got := violationKeys(report.Errors()); files != nil || strings.Join(got, ",") != "metadata.project_name:invalid_value" {
+			t.Errorf("%q: expected the project name to be refused, got %v", name, got)
+		}
+	}
+	if err := CheckProjectName("billing-api_v2.1"); err != nil {
+		t.Errorf("expected a plain project name to be accepted, got %v", err)
+	}
+
+	// Roots outside the base directory, directly or through a symlink, are refused
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(base, "linked")); err != nil {
+		t.Fatal(err)
+	}
+	for _, root := range []string{"/", outside, filepath.Join(base, "linked"), filepath.Join(project, "..", "..")} {
+		files, report := VerifyArtifacts(projectsAt(project, root), "project", artifacts, ArtifactVerificationEnforce)
+		if got := violationKeys(report.Errors()); files != nil || strings.Join(got, ",") != "metadata.project_name:path_escape" {
+			t.Errorf("%s: expected a root outside the base directory to be refused, got %v", root, got)
+		}
+	}
+
+	files, report := VerifyArtifacts(ArtifactProjects{Path: func(string) string { return project }}, "project", artifacts, ArtifactVerificationEnforce)
+	if got := violationKeys(report.Errors()); files != nil || strings.Join(got, ",") != "metadata.project_name:not_found" {
+		t.Errorf("expected verification without a base directory to fail, got %v", got)
+	}
+}
+
+// Unsafe paths are only refused by VerifyArtifacts, so producers of handoffs
+// without artifact verification are validated as before
+func TestValidatorLeavesUnsafeArtifactPathsToVerification(t *testing.T) {
+	validator := NewHandoffValidator()
+	h := newPolicyTestHandoff("golang-expert", nil)
+	h.Content.Artifacts.Created = []string{"../outside.go", "/etc/hosts", "internal/./api.go"}
+	h.Validation.Checksum = h.GenerateChecksum()
+
+	if err := validator.ValidateHandoff(h); err != nil {
+		t.Errorf("expected validation to accept the paths, got %v", err)
+	}
+}
+
+func TestParseArtifactVerification(t *testing.T) {
+	for name, want := range map[string]ArtifactVerification{
+		"":        ArtifactVerificationOff,
+		"off":     ArtifactVerificationOff,
+		"Warn":    ArtifactVerificationWarn,
+		"enforce": ArtifactVerificationEnforce,
+	} {
+		if got, err := ParseArtifactVerification(name); err != nil || got != want {
+			t.Errorf("ParseArtifactVerification(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseArtifactVerification("strict"); err == nil {
+		t.Error("expected unknown mode to fail")
+	}
+}
+
+func violationKeys(violations []ValidationViolation) []string {
+	keys := make([]string, len(violations))
+	for i, violation := range violations {
+		keys[i] = violation.Path + ":" + string(violation.Code)
+	}
+	return keys
+}
@@ -0,0 +1,226 @@
+package handoff
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+)
+
+// BlobKeyPrefix prefixes the Redis keys of offloaded handoff fields. Blobs
+// outlive the handoffs that reference them, including quarantined ones; file
+// blobs are kept as long by RunPrune, checking every BlobPruneInterval.
+const (
+	BlobKeyPrefix     = "handoff:blob:"
+	BlobRetention     = QuarantineRetention
+	BlobPruneInterval = time.Hour
+)
+
+var (
+	// ErrBlobNotFound is returned when a referenced blob is not in the store
+	ErrBlobNotFound = errors.New("blob not found")
+	// ErrBlobCorrupt is returned when a stored blob does not match its digest
+	ErrBlobCorrupt = errors.New("blob does not match its digest")
+)
+
+var blobDigestPattern = regexp.MustCompile(`^sha256:([a-f0-9]{64})$`)
+
+// BlobRef references a handoff field stored outside the handoff. The digest
+// is "sha256:" followed by the hex SHA-256 of the field's JSON encoding, so
+// the handoff checksum covers the offloaded content too.
+type BlobRef struct {
+	Digest string `json:"digest" yaml:"digest"`
+	Size   int64  `json:"size" yaml:"size"`
+}
+
+// BlobStore stores content-addressed blobs. Storing the same data twice
+// returns the same reference.
+type BlobStore interface {
+	Put(ctx context.Context, data []byte) (BlobRef, error)
+	Get(ctx context.Context, ref BlobRef) ([]byte, error)
+}
+
+func newBlobRef(data []byte) BlobRef {
+	return BlobRef{
+		Digest: fmt.Sprintf("sha256:%x", sha256.Sum256(data)),
+		Size:   int64(len(data)),
+	}
+}
+
+// blobHash returns the hex hash of a digest, rejecting anything else so a
+// digest can safely name a key or file
+func blobHash(ref BlobRef) (string, error) {
+	match := blobDigestPattern.FindStringSubmatch(ref.Digest)
+	if match == nil {
+		return "", fmt.Errorf("invalid blob digest %q", ref.Digest)
+	}
+	return match[1], nil
+}
+
+func verifyBlob(ref BlobRef, data []byte) error {
+	if newBlobRef(data) != ref {
+		return fmt.Errorf("%w: %s", ErrBlobCorrupt, ref.Digest)
+	}
+	return nil
+}
+
+// RedisBlobStore keeps blobs in Redis under BlobKeyPrefix
+type RedisBlobStore struct {
+	client redis.Cmdable
+	ttl    time.Duration
+}
+
+// NewRedisBlobStore creates a blob store keeping blobs for BlobRetention
+func NewRedisBlobStore(client redis.Cmdable) *RedisBlobStore {
+	return &RedisBlobStore{client: client, ttl: BlobRetention}
+}
+
+// Put stores data, refreshing the expiry if the blob already exists
+func (s *RedisBlobStore) Put(ctx context.Context, data []byte) (BlobRef, error) {
+	ref := newBlobRef(data)
+	hash, _ := blobHash(ref)
+	if err := s.client.Set(ctx, BlobKeyPrefix+hash, data, s.ttl).Err(); err != nil {
+		return BlobRef{}, fmt.Errorf("failed to store blob: %w", err)
+	}
+	return ref, nil
+}
+
+// Get fetches and verifies a blob
+func (s *RedisBlobStore) Get(ctx context.Context, ref BlobRef) ([]byte, error) {
+	hash, err := blobHash(ref)
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.client.Get(ctx, BlobKeyPrefix+hash).Bytes()
+	if err == redis.Nil {
+		return nil, fmt.Errorf("%w: %s", ErrBlobNotFound, ref.Digest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve blob: %w", err)
+	}
+	if err := verifyBlob(ref, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// FileBlobStore keeps blobs as files named by their hash, in subdirectories
+// named by its first two characters. Blobs are not expired; use Prune.
+type FileBlobStore struct {
+	dir string
+}
+
+// NewFileBlobStore creates a blob store in dir, creating it if needed
+func NewFileBlobStore(dir string) (*FileBlobStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create blob directory: %w", err)
+	}
+	return &FileBlobStore{dir: dir}, nil
+}
+
+func (s *FileBlobStore) path(hash string) string {
+	return filepath.Join(s.dir, hash[:2], hash)
+}
+
+// Put stores data. Writes go through a temporary file so readers never see a
+// partial blob; an existing blob only has its modification time refreshed.
+func (s *FileBlobStore) Put(ctx context.Context, data []byte) (BlobRef, error) {
+	ref := newBlobRef(data)
+	hash, _ := blobHash(ref)
+	name := s.path(hash)
+
+	now := time.Now()
+	if err := os.Chtimes(name, now, now); err == nil {
+		return ref, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return BlobRef{}, fmt.Errorf("failed to store blob: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(name), hash+".tmp-*")
+	if err != nil {
+		return BlobRef{}, fmt.Errorf("failed to store blob: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return BlobRef{}, fmt.Errorf("failed to store blob: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return BlobRef{}, fmt.Errorf("failed to store blob: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return BlobRef{}, fmt.Errorf("failed to store blob: %w", err)
+	}
+	return ref, nil
+}
+
+// Get reads and verifies a blob
+func (s *FileBlobStore) Get(ctx context.Context, ref BlobRef) ([]byte, error) {
+	hash, err := blobHash(ref)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(s.path(hash))
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%w: %s", ErrBlobNotFound, ref.Digest)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to retrieve blob: %w", err)
+	}
+	if err := verifyBlob(ref, data); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// Prune removes blobs not stored or re-stored within maxAge and returns how
+// many were removed
+func (s *FileBlobStore) Prune(maxAge time.Duration) (int, error) {
+	cutoff := time.Now().Add(-maxAge)
+	removed := 0
+	err := filepath.WalkDir(s.dir, func(path string, entry os.DirEntry, err error) error {
+		if err != nil || entry.IsDir() {
+			return err
+		}
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(path); err != nil {
+				return err
+			}
+			removed++
+		}
+		return nil
+	})
+	return removed, err
+}
+
+// RunPrune removes blobs older than BlobRetention, the Redis store's expiry,
+// now and then every interval until ctx is done
+func (s *FileBlobStore) RunPrune(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if removed, err := s.Prune(BlobRetention); err != nil {
+			log.Error().Err(err).Str("path", s.dir).Msg("Failed to prune blobs")
+		} else if removed > 0 {
+			log.Info().Int("removed", removed).Str("path", s.dir).Msg("Pruned expired blobs")
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -20,6 +21,11 @@ var (
 	logLevel   = flag.String("log-level", "info", "Log level (debug, info, warn, error)")
 	redisAddr  = flag.String("redis-addr", "localhost:6379", "Redis server address")
 	redisDB    = flag.Int("redis-db", 0, "Redis database number")
+
+	metricsAddr = flag.String("metrics-addr", "", "Address to serve Prometheus metrics on /metrics (overrides metrics.addr)")
+
+	validateRoutes = flag.Bool("validate-routes", false, "Validate the routing rules in the config file and exit")
+	strictRoutes   = flag.Bool("strict", false, "With -validate-routes, treat warnings as failures")
 )
 
 // ServiceConfig represents the complete service configuration
@@ -40,10 +46,67 @@ type ServiceConfig struct {
 
 	AlertRules []handoff.AlertRule `json:"alert_rules"`
 
+	// SLA is how long handoffs of each priority may wait in their queue before
+	// processing starts, e.g. {"critical": 120000000000}
+	SLA handoff.SLAPolicy `json:"sla"`
+
+	// Notifications delivers alerts to webhook, exec, file and redis_stream
+	// sinks; rules pick sinks by name or fall back to the default sinks
+	Notifications handoff.AlertNotifierConfig `json:"notifications"`
+
+	// Tracing exports spans of each handoff's journey to an OTLP endpoint or
+	// a JSON lines file; without an exporter trace context is only propagated
+	Tracing handoff.TracingConfig `json:"tracing"`
+
+	// ValidationPolicyFile optionally points at a handoff.ValidationPolicy JSON file
+	ValidationPolicyFile string `json:"validation_policy_file,omitempty"`
+
+	// Signing configures per-agent handoff signatures; see handoff.LoadKeyRegistry
+	Signing struct {
+		KeysFile string `json:"keys_file,omitempty"`
+		Policy   string `json:"policy,omitempty"`
+	} `json:"signing"`
+
+	// ChecksumPolicy is "migrate" (default) to process handoffs published
+	// before canonical checksums with a warning, or "strict" to quarantine them
+	ChecksumPolicy string `json:"checksum_policy,omitempty"`
+
+	// Blobs configures where large technical_details fields are offloaded:
+	// "redis", "file" (in dir) or empty to keep them inline
+	Blobs struct {
+		Store string `json:"store,omitempty"`
+		Dir   string `json:"dir,omitempty"`
+	} `json:"blobs"`
+
+	// Deduplication sets how long idempotency keys (and, with by_content,
+	// handoff fingerprints) are remembered; a zero window disables it
+	Deduplication handoff.DedupPolicy `json:"deduplication"`
+
+	// Monitoring window is the period alert counts and percentiles cover.
+	// History lists the resolutions and retentions each collection's metrics
+	// are kept at for /metrics/history.
 	Monitoring struct {
-		Enabled  bool          `json:"enabled"`
-		Interval time.Duration `json:"interval"`
+		Enabled  bool                  `json:"enabled"`
+		Interval time.Duration         `json:"interval"`
+		Window   time.Duration         `json:"window"`
+		History  []handoff.HistoryTier `json:"history"`
 	} `json:"monitoring"`
+
+	// Health sets the weights and thresholds of the system health score and
+	// the score /health/ready needs; unlisted components keep their defaults
+	Health handoff.HealthConfig `json:"health"`
+
+	// Heartbeat sets how often consumers report themselves live and how long
+	// each report counts
+	Heartbeat handoff.HeartbeatConfig `json:"heartbeat"`
+
+	// Metrics serves Prometheus metrics on /metrics, windowed percentiles on
+	// /metrics/summary, metric history on /metrics/history and the health
+	// report on /health/ready at addr; empty disables it. Retention is how long per-minute metric buckets are kept.
+	Metrics struct {
+		Addr      string        `json:"addr"`
+		Retention time.Duration `json:"retention"`
+	} `json:"metrics"`
 }
 
 // DefaultConfig returns a default configuration
@@ -209,13 +272,55 @@ func DefaultConfig() ServiceConfig {
 				Enabled:   true,
 				Cooldown:  10 * time.Minute,
 			},
+			{
+				Name:      "stale-agent-queue",
+				Condition: "queue_oldest_age by (agent) > 600", // Seconds, per agent
+				Duration:  5 * time.Minute,
+				Enabled:   true,
+				Cooldown:  30 * time.Minute,
+			},
+			{
+				Name:      "sla-breach",
+				Condition: "queue_sla_breaches by (agent, priority) > 0",
+				Enabled:   true,
+				Cooldown:  15 * time.Minute,
+			},
+			{
+				Name:      "agent-down",
+				Type:      handoff.AlertAgentHealth,
+				Condition: "agents_without_consumer by (agent) > 0",
+				Duration:  time.Minute,
+				Enabled:   true,
+				Cooldown:  15 * time.Minute,
+			},
 		},
+		SLA:           handoff.DefaultSLAPolicy(),
+		Deduplication: handoff.DefaultDedupPolicy(),
 		Monitoring: struct {
-			Enabled  bool          `json:"enabled"`
-			Interval time.Duration `json:"interval"`
+			Enabled  bool                  `json:"enabled"`
+			Interval time.Duration         `json:"interval"`
+			Window   time.Duration         `json:"window"`
+			History  []handoff.HistoryTier `json:"history"`
 		}{
 			Enabled:  true,
 			Interval: 30 * time.Second,
+			Window:   handoff.DefaultMetricsWindow,
+			History:  handoff.DefaultHistoryTiers(),
+		},
+		Health: handoff.HealthConfig{
+			MinScore: handoff.DefaultHealthMinScore,
+			Window:   handoff.DefaultHealthWindow,
+		},
+		Heartbeat: handoff.HeartbeatConfig{
+			Interval: handoff.DefaultHeartbeatInterval,
+			TTL:      3 * handoff.DefaultHeartbeatInterval,
+		},
+		Metrics: struct {
+			Addr      string        `json:"addr"`
+			Retention time.Duration `json:"retention"`
+		}{
+			Addr:      ":9464",
+			Retention: handoff.DefaultMetricsRetention,
 		},
 	}
 }
@@ -244,6 +349,72 @@ func main() {
 	if *logLevel != "info" {
 		config.Logging.Level = *logLevel
 	}
+	if *metricsAddr != "" {
+		config.Metrics.Addr = *metricsAddr
+	}
+
+	// Setup router and check the rules before anything starts consuming
+	router := handoff.NewHandoffRouter("default-agent")
+	for fromAgent, rules := range config.Routes {
+		for _, rule := range rules {
+			router.AddRoute(fromAgent, rule)
+		}
+	}
+	routeReport := router.Validate(agentNames(config.Agents))
+
+	if *validateRoutes {
+		os.Exit(printRouteReport(routeReport, *strictRoutes))
+	}
+
+	for _, issue := range routeReport.Warnings() {
+		log.Warn().
+			Str("code", string(issue.Code)).
+			Str("from_agent", issue.FromAgent).
+			Str("rule_name", issue.Rule).
+			Msg(issue.Message)
+	}
+	if err := routeReport.Err(); err != nil {
+		log.Fatal().Err(err).Msg("Routing configuration is invalid")
+	}
+
+	// Load the validation policy, if one is configured
+	var validator *handoff.HandoffValidator
+	if config.ValidationPolicyFile != "" {
+		policy, err := handoff.LoadValidationPolicy(config.ValidationPolicyFile)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to load validation policy")
+		}
+		if validator, err = handoff.NewHandoffValidatorWithPolicy(policy); err != nil {
+			log.Fatal().Err(err).Msg("Validation policy is invalid")
+		}
+		log.Info().
+			Str("file", config.ValidationPolicyFile).
+			Int("agent_policies", len(policy.Agents)).
+			Msg("Validation policy loaded")
+	}
+
+	// Load signing keys, if configured
+	signaturePolicy, err := handoff.ParseSignaturePolicy(config.Signing.Policy)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid signing configuration")
+	}
+	var keys *handoff.KeyRegistry
+	if config.Signing.KeysFile != "" {
+		if keys, err = handoff.LoadKeyRegistry(config.Signing.KeysFile); err != nil {
+			log.Fatal().Err(err).Msg("Failed to load signing keys")
+		}
+		log.Info().
+			Str("file", config.Signing.KeysFile).
+			Strs("agents", keys.Agents()).
+			Str("policy", string(signaturePolicy)).
+			Msg("Signing keys loaded")
+	} else if signaturePolicy.Enforced() {
+		log.Fatal().Str("policy", string(signaturePolicy)).Msg("Signature policy requires signing.keys_file")
+	}
+	checksumPolicy, err := handoff.ParseChecksumPolicy(config.ChecksumPolicy)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid checksum policy")
+	}
 
 	// Create optimized handoff agent
 	poolConfig := handoff.DefaultRedisPoolConfig()
@@ -274,11 +445,9 @@ func main() {
 			Msg("Agent registered")
 	}
 
-	// Setup router
-	router := handoff.NewHandoffRouter("default-agent")
-	for fromAgent, rules := range config.Routes {
+	// Enable routing
+	for fromAgent, rules := range router.Routes() {
 		for _, rule := range rules {
-			router.AddRoute(fromAgent, rule)
 			log.Info().
 				Str("from_agent", fromAgent).
 				Str("rule_name", rule.Name).
@@ -287,15 +456,81 @@ func main() {
 				Msg("Route rule added")
 		}
 	}
+	agent.SetRouter(router)
+	if validator != nil {
+		agent.SetValidator(validator)
+	}
+	if keys != nil {
+		agent.SetSigning(keys, signaturePolicy)
+	}
+	agent.SetChecksumPolicy(checksumPolicy)
+	agent.SetDeduplication(config.Deduplication)
+	if store := newBlobStore(config, agent); store != nil {
+		agent.SetBlobStore(store)
+		log.Info().Str("store", config.Blobs.Store).Msg("Large handoff fields will be offloaded")
+	}
+	agent.SetMetricsRetention(config.Metrics.Retention)
+	agent.SetSLAPolicy(config.SLA)
+
+	tracer, err := handoff.NewTracer("handoff-service", config.Tracing)
+	if err != nil {
+		log.Fatal().Err(err).Msg("Invalid tracing configuration")
+	}
+	agent.SetTracer(tracer)
+	defer func() {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 10*time.Second)
+		if err := tracer.Shutdown(shutdownCtx); err != nil {
+			log.Error().Err(err).Msg("Failed to export remaining spans")
+		}
+		shutdownCancel()
+	}()
+
+	if err := config.Health.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("Invalid health configuration")
+	}
+	if err := config.Heartbeat.Validate(); err != nil {
+		log.Fatal().Err(err).Msg("Invalid heartbeat configuration")
+	}
+	agent.SetHeartbeat(config.Heartbeat)
 
 	// Setup monitoring
 	var monitor *handoff.OptimizedHandoffMonitor
 	if config.Monitoring.Enabled {
 		monitor = handoff.NewOptimizedHandoffMonitor(agent.GetRedisManager())
+		monitor.SetMetricsWindow(config.Monitoring.Window)
+		monitor.SetMetricsRetention(config.Metrics.Retention)
+		monitor.SetSLAPolicy(config.SLA)
+		if err := handoff.ValidateHistoryTiers(config.Monitoring.History); err != nil {
+			log.Fatal().Err(err).Msg("Invalid metric history tiers")
+		}
+		monitor.SetHistoryTiers(config.Monitoring.History)
+		monitor.SetHealthConfig(config.Health)
+
+		notifier, err := handoff.NewAlertNotifier(config.Notifications, agent.GetRedisClient())
+		if err != nil {
+			log.Fatal().Err(err).Msg("Invalid alert notifications")
+		}
+		if err := notifier.CheckRules(config.AlertRules); err != nil {
+			log.Fatal().Err(err).Msg("Invalid alert rule")
+		}
+		monitor.SetNotifier(notifier)
+
+		// On shutdown, stop evaluating alerts before the notifier stops delivering
+		monitorCtx, stopMonitoring := context.WithCancel(context.Background())
+		monitorDone := make(chan struct{})
+		defer func() {
+			stopMonitoring()
+			<-monitorDone
+			closeCtx, closeCancel := context.WithTimeout(context.Background(), 10*time.Second)
+			notifier.Close(closeCtx)
+			closeCancel()
+		}()
 
 		// Add alert rules
 		for _, rule := range config.AlertRules {
-			monitor.AddAlertRule(rule)
+			if err := monitor.AddAlertRule(rule); err != nil {
+				log.Fatal().Err(err).Msg("Invalid alert rule")
+			}
 		}
 
 		// Subscribe to alerts and log them
@@ -304,25 +539,64 @@ func main() {
 			for alert := range alertChan {
 				log.Warn().
 					Str("rule", alert.Rule.Name).
+					Str("state", string(alert.State)).
 					Float64("value", alert.Value).
 					Str("severity", string(alert.Severity)).
 					Str("message", alert.Message).
-					Msg("Alert triggered")
+					Msg("Alert " + string(alert.State))
 			}
 		}()
 
 		// Start monitoring
 		go func() {
-			ctx := context.Background()
-			monitor.StartMonitoring(ctx, config.Monitoring.Interval)
+			defer close(monitorDone)
+			monitor.StartMonitoring(monitorCtx, config.Monitoring.Interval)
 		}()
 
 		log.Info().
 			Dur("interval", config.Monitoring.Interval).
 			Int("alert_rules", len(config.AlertRules)).
+			Int("alert_sinks", len(config.Notifications.Sinks)).
 			Msg("Monitoring started")
 	}
 
+	// Serve Prometheus metrics
+	var metricsServer *http.Server
+	if config.Metrics.Addr != "" {
+		mux := http.NewServeMux()
+		collect := agent.CollectMetrics
+		if monitor != nil {
+			collect = func(ctx context.Context) (*handoff.MetricsSnapshot, error) {
+				snapshot, err := agent.CollectMetrics(ctx)
+				if err != nil {
+					return nil, err
+				}
+				snapshot.Alerts = monitor.AlertDeliveryStats()
+				return snapshot, nil
+			}
+		}
+		mux.Handle("/metrics", handoff.MetricsHandler(collect))
+		mux.Handle("/metrics/summary", handoff.MetricsSummaryHandler(func(ctx context.Context, from, to time.Time) (*handoff.RollingMetrics, error) {
+			return handoff.QueryRollingMetrics(ctx, agent.GetRedisClient(), from, to)
+		}))
+		mux.Handle("/metrics/history", handoff.HistoryHandler(func(ctx context.Context, q handoff.HistoryQuery) (*handoff.HistoryResult, error) {
+			return handoff.QueryHistory(ctx, agent.GetRedisClient(), config.Monitoring.History, q)
+		}))
+		health := handoff.NewHealthChecker(config.Health,
+			handoff.RedisHealthCheck(agent.GetRedisClient()),
+			handoff.MetricsHealthCheck(agent.GetRedisClient(), config.Health),
+			handoff.PoolHealthCheck(agent.GetRedisManager().GetDetailedMetrics),
+		)
+		mux.Handle("/health/ready", handoff.HealthHandler(health.Report))
+		metricsServer = &http.Server{Addr: config.Metrics.Addr, Handler: mux}
+		go func() {
+			if err := metricsServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Error().Err(err).Str("addr", config.Metrics.Addr).Msg("Metrics server failed")
+			}
+		}()
+		log.Info().Str("addr", config.Metrics.Addr).Msg("Serving metrics on /metrics")
+	}
+
 	// Setup example consumer (this would be replaced by actual agent implementations)
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
@@ -334,7 +608,7 @@ func main() {
 			log.Info().
 				Str("handoff_id", h.Metadata.HandoffID).
 				Str("from_agent", h.Metadata.FromAgent).
-				Str("summary", h.Content.Summary).
+				Str("summary", handoff.RedactSecrets(h.Content.Summary)).
 				Msg("Processing handoff")
 
 			// Simulate processing time
@@ -370,6 +644,11 @@ func main() {
 
 	// Graceful shutdown
 	cancel()
+	if metricsServer != nil {
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 5*time.Second)
+		metricsServer.Shutdown(shutdownCtx)
+		shutdownCancel()
+	}
 
 	log.Info().Msg("Handoff agent service stopped")
 }
@@ -401,3 +680,52 @@ func loadConfig(filename string) ServiceConfig {
 	log.Info().Str("file", filename).Msg("Configuration loaded")
 	return config
 }
+
+// agentNames returns the names of the configured agents
+func agentNames(agents []handoff.AgentCapabilities) []string {
+	names := make([]string, len(agents))
+	for i, agent := range agents {
+		names[i] = agent.Name
+	}
+	return names
+}
+
+// printRouteReport prints a route validation report and returns the process exit code
+func printRouteReport(report *handoff.RouteValidationReport, strict bool) int {
+	for _, issue := range report.Issues {
+		fmt.Println(issue.String())
+	}
+
+	errors, warnings := len(report.Errors()), len(report.Warnings())
+	fmt.Printf("%d errors, %d warnings\n", errors, warnings)
+
+	if errors > 0 || (strict && warnings > 0) {
+		return 1
+	}
+	return 0
+}
+
+// newBlobStore creates the configured blob store, or returns nil when large
+// fields stay inline
+func newBlobStore(config ServiceConfig, agent *handoff.OptimizedHandoffAgent) handoff.BlobStore {
+	switch config.Blobs.Store {
+	case "":
+		return nil
+	case "redis":
+		return handoff.NewRedisBlobStore(agent.GetRedisClient())
+	case "file":
+		if config.Blobs.Dir == "" {
+			log.Fatal().Msg("Blob store \"file\" requires blobs.dir")
+		}
+		store, err := handoff.NewFileBlobStore(config.Blobs.Dir)
+		if err != nil {
+			log.Fatal().Err(err).Msg("Failed to create blob store")
+		}
+		// Expire file blobs as the Redis store does
+		go store.RunPrune(context.Background(), handoff.BlobPruneInterval)
+		return store
+	default:
+		log.Fatal().Str("store", config.Blobs.Store).Msg("Unknown blob store (expected redis or file)")
+		return nil
+	}
+}
@@ -0,0 +1,148 @@
+package handoff
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Deduplication keys shared by every producer. Each holds the ID of the
+// handoff that claimed it, until the dedup window expires.
+const (
+	IdempotencyKeyPrefix    = "handoff:idempotency:"
+	FingerprintKeyPrefix    = "handoff:fingerprint:"
+	MaxIdempotencyKeyLength = 255
+)
+
+// ErrDuplicateHandoff is returned, together with the existing handoff, when a
+// handoff repeats one published within the dedup window
+var ErrDuplicateHandoff = errors.New("duplicate handoff")
+
+// DedupPolicy configures duplicate detection on publish
+type DedupPolicy struct {
+	Window    time.Duration `json:"window"`     // How long idempotency keys and fingerprints are remembered; zero disables deduplication
+	ByContent bool          `json:"by_content"` // Also treat identical handoffs without an idempotency key as duplicates
+}
+
+// DefaultDedupPolicy honours idempotency keys for as long as handoffs are kept
+func DefaultDedupPolicy() DedupPolicy {
+	return DedupPolicy{Window: 24 * time.Hour}
+}
+
+// Enabled reports whether duplicates are detected at all
+func (p DedupPolicy) Enabled() bool {
+	return p.Window > 0
+}
+
+// Keys returns the Redis keys a new handoff claims: one for its idempotency
+// key, scoped to the producing agent, and with ByContent one for its
+// fingerprint. Fan-out children share their parent's key and are never
+// deduplicated, so pass an empty idempotency key and fingerprint for them.
+func (p DedupPolicy) Keys(fromAgent, idempotencyKey, fingerprint string) []string {
+	if !p.Enabled() {
+		return nil
+	}
+	var keys []string
+	if idempotencyKey != "" {
+		keys = append(keys, fmt.Sprintf("%s%s:%x", IdempotencyKeyPrefix, fromAgent, sha256.Sum256([]byte(idempotencyKey))))
+	}
+	if p.ByContent && fingerprint != "" {
+		keys = append(keys, FingerprintKeyPrefix+fingerprint)
+	}
+	return keys
+}
+
+// ValidateIdempotencyKey checks a producer-supplied idempotency key; an empty
+// key is valid and means none was given
+func ValidateIdempotencyKey(key string) error {
+	if len(key) > MaxIdempotencyKeyLength {
+		return fmt.Errorf("idempotency key is longer than %d characters", MaxIdempotencyKeyLength)
+	}
+	for _, r := range key {
+		if r < 0x21 || r > 0x7e {
+			return fmt.Errorf("idempotency key must be printable ASCII without spaces")
+		}
+	}
+	return nil
+}
+
+// volatileMetadataFields differ between retries of the same handoff
+var volatileMetadataFields = []string{"handoff_id", "timestamp", "idempotency_key"}
+
+// ComputeFingerprint returns the checksum of a handoff's canonical metadata and
+// content without its ID, timestamp and idempotency key, so a retried handoff
+// fingerprints the same as the original. Compute it before routing.
+func ComputeFingerprint(metadata, content interface{}) (string, error) {
+	canonical, err := canonicalize(map[string]interface{}{"metadata": metadata, "content": content})
+	if err != nil {
+		return "", err
+	}
+	if doc, ok := canonical.(map[string]interface{}); ok {
+		if fields, ok := doc["metadata"].(map[string]interface{}); ok {
+			for _, field := range volatileMetadataFields {
+				delete(fields, field)
+			}
+		}
+	}
+
+	data, err := encodeCanonical(canonical)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(data)), nil
+}
+
+// claimScript sets every key to the handoff ID unless one is already held,
+// in which case it returns the holder
+var claimScript = redis.NewScript(`
+for _, key in ipairs(KEYS) do
+	local holder = redis.call("GET", key)
+	if holder then
+		return holder
+	end
+end
+for _, key in ipairs(KEYS) do
+	redis.call("SET", key, ARGV[1], "PX", ARGV[2])
+end
+return ""
+`)
+
+// releaseScript deletes the keys still held by the handoff ID
+var releaseScript = redis.NewScript(`
+for _, key in ipairs(KEYS) do
+	if redis.call("GET", key) == ARGV[1] then
+		redis.call("DEL", key)
+	end
+end
+return 0
+`)
+
+// ClaimDedupKeys atomically claims keys for handoffID for the window. It
+// returns the ID of the handoff already holding one of them, or "" when
+// handoffID now holds them all.
+func ClaimDedupKeys(ctx context.Context, client redis.Scripter, keys []string, handoffID string, window time.Duration) (string, error) {
+	if len(keys) == 0 {
+		return "", nil
+	}
+	holder, err := claimScript.Run(ctx, client, keys, handoffID, window.Milliseconds()).Text()
+	if err != nil {
+		return "", fmt.Errorf("failed to claim dedup keys: %w", err)
+	}
+	return holder, nil
+}
+
+// ReleaseDedupKeys gives up keys claimed by handoffID, for a handoff that was
+// not stored after all or whose original has expired
+func ReleaseDedupKeys(ctx context.Context, client redis.Scripter, keys []string, handoffID string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	if err := releaseScript.Run(ctx, client, keys, handoffID).Err(); err != nil {
+		return fmt.Errorf("failed to release dedup keys: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,85 @@
+package handoff
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestComputeFingerprint(t *testing.T) {
+	original := newPolicyTestHandoff("golang-expert", map[string]interface{}{"handlers": []interface{}{"CreateInvoice"}})
+	original.Metadata.HandoffID = "first-attempt"
+	original.Metadata.IdempotencyKey = "invoice-42"
+	want, err := ComputeFingerprint(original.Metadata, original.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		modify func(h *Handoff)
+		same   bool
+	}{
+		{"retry with new id and timestamp", func(h *Handoff) {
+			h.Metadata.HandoffID = "second-attempt"
+			h.Metadata.Timestamp = h.Metadata.Timestamp.Add(time.Minute)
+		}, true},
+		{"different idempotency key", func(h *Handoff) { h.Metadata.IdempotencyKey = "invoice-43" }, true},
+		{"checksum set", func(h *Handoff) { h.Validation.Checksum = h.GenerateChecksum() }, true},
+		{"different summary", func(h *Handoff) { h.Content.Summary = "Implement refund endpoints" }, false},
+		{"different target", func(h *Handoff) { h.Metadata.ToAgent = "test-expert" }, false},
+		{"different sender", func(h *Handoff) { h.Metadata.FromAgent = "architect-expert" }, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newPolicyTestHandoff("golang-expert", map[string]interface{}{"handlers": []interface{}{"CreateInvoice"}})
+			h.Metadata = original.Metadata
+			tt.modify(h)
+			got, err := ComputeFingerprint(h.Metadata, h.Content)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (got == want) != tt.same {
+				t.Errorf("fingerprint match = %v, want %v", got == want, tt.same)
+			}
+		})
+	}
+}
+
+func TestDedupPolicyKeys(t *testing.T) {
+	policy := DefaultDedupPolicy()
+	keys := policy.Keys("api-expert", "invoice-42", "abc123")
+	if len(keys) != 1 || !strings.HasPrefix(keys[0], IdempotencyKeyPrefix+"api-expert:") {
+		t.Errorf("expected one idempotency key scoped to the agent, got %v", keys)
+	}
+	if other := policy.Keys("test-expert", "invoice-42", ""); other[0] == keys[0] {
+		t.Error("expected the same idempotency key from another agent not to collide")
+	}
+
+	policy.ByContent = true
+	if keys := policy.Keys("api-expert", "", "abc123"); len(keys) != 1 || keys[0] != FingerprintKeyPrefix+"abc123" {
+		t.Errorf("expected a fingerprint key, got %v", keys)
+	}
+	if keys := policy.Keys("api-expert", "invoice-42", "abc123"); len(keys) != 2 {
+		t.Errorf("expected both keys, got %v", keys)
+	}
+
+	policy.Window = 0
+	if keys := policy.Keys("api-expert", "invoice-42", "abc123"); keys != nil {
+		t.Errorf("expected no keys with deduplication disabled, got %v", keys)
+	}
+}
+
+func TestValidateIdempotencyKey(t *testing.T) {
+	for _, key := range []string{"", "invoice-42", "01HV6Z2W3Q7XK9", strings.Repeat("k", MaxIdempotencyKeyLength)} {
+		if err := ValidateIdempotencyKey(key); err != nil {
+			t.Errorf("ValidateIdempotencyKey(%q) failed: %v", key, err)
+		}
+	}
+	for _, key := range []string{"has space", "tab\tkey", "clé", strings.Repeat("k", MaxIdempotencyKeyLength+1)} {
+		if err := ValidateIdempotencyKey(key); err == nil {
+			t.Errorf("expected ValidateIdempotencyKey(%q) to fail", key)
+		}
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !linux && !darwin
+
+package handoff
+
+func diskFreePercent(path string) (float64, error) {
+	return 0, errDiskStatsUnsupported
+}
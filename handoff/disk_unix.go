@@ -0,0 +1,18 @@
+//go:build linux || darwin
+
+package handoff
+
+import "syscall"
+
+// diskFreePercent returns the share of the filesystem holding path that is
+// available to unprivileged users, in percent
+func diskFreePercent(path string) (float64, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return 0, err
+	}
+	if stat.Blocks == 0 {
+		return 0, nil
+	}
+	return float64(stat.Bavail) / float64(stat.Blocks) * 100, nil
+}
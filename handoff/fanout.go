@@ -0,0 +1,165 @@
+package handoff
+
+import (
+	"fmt"
+	"time"
+)
+
+// FanOutFailurePolicy decides how child failures roll up to a fan-out parent
+type FanOutFailurePolicy string
+
+const (
+	FanOutFailFast   FanOutFailurePolicy = "fail_fast"   // Parent fails as soon as any child fails
+	FanOutWaitAll    FanOutFailurePolicy = "wait_all"    // Parent waits for every child, then fails if any failed
+	FanOutBestEffort FanOutFailurePolicy = "best_effort" // Parent completes once every child finished and at least one completed
+)
+
+// IsValid reports whether the policy is known; empty means FanOutFailFast
+func (p FanOutFailurePolicy) IsValid() bool {
+	switch p {
+	case "", FanOutFailFast, FanOutWaitAll, FanOutBestEffort:
+		return true
+	default:
+		return false
+	}
+}
+
+// FanOutChild tracks one child handoff of a fan-out parent
+type FanOutChild struct {
+	HandoffID string        `json:"handoff_id" yaml:"handoff_id"`
+	ToAgent   string        `json:"to_agent" yaml:"to_agent"`
+	Status    HandoffStatus `json:"status" yaml:"status"`
+}
+
+// FanOut records the children of a handoff that was routed to several agents
+type FanOut struct {
+	FailurePolicy FanOutFailurePolicy `json:"failure_policy" yaml:"failure_policy"`
+	Children      []FanOutChild       `json:"children" yaml:"children"`
+}
+
+// RecordChild stores a child's latest status and reports whether it changed
+func (f *FanOut) RecordChild(handoffID string, status HandoffStatus) bool {
+	for i := range f.Children {
+		if f.Children[i].HandoffID != handoffID {
+			continue
+		}
+		if f.Children[i].Status == status {
+			return false
+		}
+		f.Children[i].Status = status
+		return true
+	}
+	return false
+}
+
+// AggregateStatus derives the parent status from its children and failure policy
+func (f *FanOut) AggregateStatus() HandoffStatus {
+	var completed, failed, started int
+	for _, child := range f.Children {
+		switch child.Status {
+		case StatusCompleted:
+			completed++
+		case StatusFailed, StatusCancelled, StatusQuarantined:
+			failed++
+		case StatusProcessing, StatusRetrying:
+			started++
+		}
+	}
+
+	finished := completed+failed == len(f.Children)
+
+	switch f.FailurePolicy {
+	case FanOutWaitAll:
+		if finished {
+			if failed > 0 {
+				return StatusFailed
+			}
+			return StatusCompleted
+		}
+	case FanOutBestEffort:
+		if finished {
+			if completed > 0 {
+				return StatusCompleted
+			}
+			return StatusFailed
+		}
+	default:
+		if failed > 0 {
+			return StatusFailed
+		}
+		if finished {
+			return StatusCompleted
+		}
+	}
+
+	if completed+failed+started > 0 {
+		return StatusProcessing
+	}
+	return StatusPending
+}
+
+// FailureSummary describes failed children for the parent's error message
+func (f *FanOut) FailureSummary() string {
+	failed := 0
+	for _, child := range f.Children {
+		switch child.Status {
+		case StatusFailed, StatusCancelled, StatusQuarantined:
+			failed++
+		}
+	}
+	return fmt.Sprintf("%d of %d child handoffs failed", failed, len(f.Children))
+}
+
+// ApplyFanOutChildStatus records a child's status on its parent and refreshes the
+// parent's aggregate status. It reports whether the parent changed.
+func ApplyFanOutChildStatus(parent *Handoff, childID string, status HandoffStatus) bool {
+	if parent.FanOut == nil || !parent.FanOut.RecordChild(childID, status) {
+		return false
+	}
+
+	parent.Status = parent.FanOut.AggregateStatus()
+	parent.UpdatedAt = time.Now()
+	if parent.Status == StatusFailed {
+		parent.ErrorMsg = parent.FanOut.FailureSummary()
+	} else {
+		parent.ErrorMsg = ""
+	}
+	return true
+}
+
+// NewFanOutChild copies a fan-out parent into a child handoff addressed to one agent
+func NewFanOutChild(parent *Handoff, toAgent, handoffID string) *Handoff {
+	child := &Handoff{
+		Metadata: parent.Metadata,
+		Content: Content{
+			Summary:      parent.Content.Summary,
+			Requirements: append([]string(nil), parent.Content.Requirements...),
+			Artifacts: Artifacts{
+				Created:  append([]string(nil), parent.Content.Artifacts.Created...),
+				Modified: append([]string(nil), parent.Content.Artifacts.Modified...),
+				Reviewed: append([]string(nil), parent.Content.Artifacts.Reviewed...),
+			},
+			NextSteps:     append([]string(nil), parent.Content.NextSteps...),
+			ArtifactFiles: append([]ArtifactFile(nil), parent.Content.ArtifactFiles...),
+		},
+		Validation: Validation{SchemaVersion: parent.Validation.SchemaVersion},
+	}
+
+	if parent.Content.Offloaded != nil {
+		child.Content.Offloaded = make(map[string]BlobRef, len(parent.Content.Offloaded))
+		for field, ref := range parent.Content.Offloaded {
+			child.Content.Offloaded[field] = ref
+		}
+	}
+	if parent.Content.TechnicalDetails != nil {
+		child.Content.TechnicalDetails = make(map[string]interface{}, len(parent.Content.TechnicalDetails))
+		for key, value := range parent.Content.TechnicalDetails {
+			child.Content.TechnicalDetails[key] = value
+		}
+	}
+
+	child.Metadata.HandoffID = handoffID
+	child.Metadata.ToAgent = toAgent
+	child.Metadata.ParentID = parent.Metadata.HandoffID
+	return child
+}
@@ -0,0 +1,127 @@
+package handoff
+
+import (
+	"context"
+	"testing"
+)
+
+func newFanOut(policy FanOutFailurePolicy, statuses ...HandoffStatus) *FanOut {
+	fanOut := &FanOut{FailurePolicy: policy}
+	for i, status := range statuses {
+		fanOut.Children = append(fanOut.Children, FanOutChild{
+			HandoffID: string(rune('a' + i)),
+			Status:    status,
+		})
+	}
+	return fanOut
+}
+
+func TestFanOutAggregateStatus(t *testing.T) {
+	tests := []struct {
+		name     string
+		fanOut   *FanOut
+		expected HandoffStatus
+	}{
+		{"nothing started", newFanOut(FanOutFailFast, StatusPending, StatusPending), StatusPending},
+		{"in progress", newFanOut(FanOutFailFast, StatusCompleted, StatusPending), StatusProcessing},
+		{"all completed", newFanOut(FanOutFailFast, StatusCompleted, StatusCompleted), StatusCompleted},
+		{"fail fast", newFanOut(FanOutFailFast, StatusFailed, StatusPending), StatusFailed},
+		{"default policy fails fast", newFanOut("", StatusFailed, StatusProcessing), StatusFailed},
+		{"wait all still running", newFanOut(FanOutWaitAll, StatusFailed, StatusProcessing), StatusProcessing},
+		{"wait all finished", newFanOut(FanOutWaitAll, StatusFailed, StatusCompleted), StatusFailed},
+		{"best effort partial", newFanOut(FanOutBestEffort, StatusCancelled, StatusCompleted), StatusCompleted},
+		{"best effort all failed", newFanOut(FanOutBestEffort, StatusFailed, StatusFailed), StatusFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.fanOut.AggregateStatus(); got != tt.expected {
+				t.Errorf("expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestApplyFanOutChildStatus(t *testing.T) {
+	parent := &Handoff{Status: StatusPending, FanOut: newFanOut(FanOutWaitAll, StatusPending, StatusPending)}
+
+	if !ApplyFanOutChildStatus(parent, "a", StatusFailed) {
+		t.Fatal("expected parent to change")
+	}
+	if parent.Status != StatusProcessing {
+		t.Errorf("expected processing while b is outstanding, got %s", parent.Status)
+	}
+	if ApplyFanOutChildStatus(parent, "a", StatusFailed) {
+		t.Error("repeating a status should not change the parent")
+	}
+	if ApplyFanOutChildStatus(parent, "unknown", StatusCompleted) {
+		t.Error("unknown children should be ignored")
+	}
+
+	ApplyFanOutChildStatus(parent, "b", StatusCompleted)
+	if parent.Status != StatusFailed || parent.ErrorMsg != "1 of 2 child handoffs failed" {
+		t.Errorf("expected failed parent with summary, got %s %q", parent.Status, parent.ErrorMsg)
+	}
+}
+
+func TestNewFanOutChildCopiesParent(t *testing.T) {
+	parent := &Handoff{
+		Metadata: Metadata{HandoffID: "parent-1", FromAgent: "api-expert", ToAgent: AutoRouteAgent, RouteRule: "spec-ready"},
+		Content: Content{
+			Summary:          "API spec finished",
+			Requirements:     []string{"implement endpoints"},
+			TechnicalDetails: map[string]interface{}{"spec": "openapi.yaml"},
+		},
+	}
+
+	child := NewFanOutChild(parent, "golang-expert", "child-1")
+	child.Content.Requirements[0] = "changed"
+	child.Content.TechnicalDetails["spec"] = "changed"
+
+	if child.Metadata.ParentID != "parent-1" || child.Metadata.ToAgent != "golang-expert" || child.Metadata.HandoffID != "child-1" {
+		t.Errorf("unexpected child metadata: %+v", child.Metadata)
+	}
+	if child.Metadata.RouteRule != "spec-ready" {
+		t.Errorf("expected child to keep routing provenance, got %q", child.Metadata.RouteRule)
+	}
+	if parent.Content.Requirements[0] != "implement endpoints" || parent.Content.TechnicalDetails["spec"] != "openapi.yaml" {
+		t.Error("modifying the child must not modify the parent")
+	}
+}
+
+func TestRouteFanOutDecision(t *testing.T) {
+	router := NewHandoffRouter("project-manager")
+	router.AddRoute("api-expert", RouteRule{
+		Name:          "spec-ready",
+		TargetAgents:  []string{"golang-expert", "typescript-expert"},
+		FailurePolicy: FanOutWaitAll,
+		Priority:      100,
+		Conditions: []RouteCondition{
+			{Type: ConditionComplexQuery, Field: "has_api_spec", Operator: "equals", Value: true},
+		},
+	})
+
+	h := &Handoff{
+		Metadata: Metadata{FromAgent: "api-expert", ToAgent: AutoRouteAgent},
+		Content:  Content{Artifacts: Artifacts{Created: []string{"api/openapi.yaml"}}},
+	}
+
+	decision, err := router.Route(context.Background(), h)
+	if err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if !decision.IsFanOut() || len(decision.TargetAgents) != 2 {
+		t.Fatalf("expected fan-out to two agents, got %+v", decision)
+	}
+	if decision.FailurePolicy != FanOutWaitAll {
+		t.Errorf("expected wait_all policy, got %s", decision.FailurePolicy)
+	}
+
+	agent := &OptimizedHandoffAgent{router: router}
+	if _, err := agent.applyRouting(context.Background(), h); err != nil {
+		t.Fatalf("applyRouting failed: %v", err)
+	}
+	if h.Metadata.ToAgent != AutoRouteAgent || h.Metadata.RouteRule != "spec-ready" {
+		t.Errorf("expected parent to stay addressed to %q with rule recorded, got %+v", AutoRouteAgent, h.Metadata)
+	}
+}
@@ -0,0 +1,307 @@
+package handoff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"mime"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Format is a serialization format for handoffs. Redis always stores JSON;
+// YAML is accepted and emitted at the edges, converted through JSON so field
+// names, numbers, checksums and validation are the same in both formats.
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+)
+
+// ParseFormat parses a --format value, defaulting to JSON
+func ParseFormat(s string) (Format, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "", "json":
+		return FormatJSON, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	}
+	return "", fmt.Errorf("unknown format %q (expected json or yaml)", s)
+}
+
+// ContentType returns the media type written for the format
+func (f Format) ContentType() string {
+	if f == FormatYAML {
+		return "application/yaml"
+	}
+	return "application/json"
+}
+
+// Extension returns the file extension, with its dot, used for the format
+func (f Format) Extension() string {
+	if f == FormatYAML {
+		return ".yaml"
+	}
+	return ".json"
+}
+
+// FormatForContentType returns the format of a Content-Type header value.
+// Empty or unrecognised media types report false.
+func FormatForContentType(contentType string) (Format, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return "", false
+	}
+	switch {
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		return FormatJSON, true
+	case mediaType == "application/yaml" || mediaType == "application/x-yaml" ||
+		mediaType == "text/yaml" || mediaType == "text/x-yaml" || strings.HasSuffix(mediaType, "+yaml"):
+		return FormatYAML, true
+	}
+	return "", false
+}
+
+// NegotiateFormat picks the response format for an Accept header: YAML when
+// a YAML media type is preferred over JSON, otherwise JSON
+func NegotiateFormat(accept string) Format {
+	best, bestQ := FormatJSON, 0.0
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+		format, ok := FormatForContentType(mediaType)
+		if !ok {
+			continue
+		}
+		// Ties go to JSON, the format every client reads
+		if q > bestQ || (q == bestQ && format == FormatJSON) {
+			best, bestQ = format, q
+		}
+	}
+	return best
+}
+
+// Marshal encodes v in the format. JSON is indented for files and terminals.
+func Marshal(v interface{}, format Format) ([]byte, error) {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	if format != FormatYAML {
+		return data, nil
+	}
+	return JSONToYAML(data)
+}
+
+// Unmarshal decodes data in the format into v
+func Unmarshal(data []byte, format Format, v interface{}) error {
+	if format == FormatYAML {
+		var err error
+		if data, err = YAMLToJSON(data); err != nil {
+			return err
+		}
+	}
+	return json.Unmarshal(data, v)
+}
+
+// JSONToYAML converts a JSON document to block-style YAML, keeping key order
+func JSONToYAML(data []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %w", err)
+	}
+	blockStyle(&node)
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&node); err != nil {
+		return nil, fmt.Errorf("cannot encode YAML: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return nil, fmt.Errorf("cannot encode YAML: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// blockStyle drops the flow and quoting styles of a document parsed from
+// JSON; the encoder still quotes strings that would read as another type
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// Limits on YAML alias expansion. Aliases may add up to maxYAMLAliasNodes
+// nodes beyond those written in the document, so a small document cannot
+// expand into a huge one ("billion laughs").
+const (
+	maxYAMLAliasNodes = 10000
+	maxYAMLDepth      = 100
+)
+
+// ErrYAMLTooComplex is returned for YAML documents whose aliases expand past
+// the node limit or that nest too deeply
+var ErrYAMLTooComplex = errors.New("YAML document expands too far")
+
+// YAMLToJSON converts a single YAML document to JSON. Scalars keep the type
+// YAML resolves them to, except timestamps, which stay strings as they would
+// be in JSON. Anchors, aliases and merge keys are expanded, within limits.
+func YAMLToJSON(data []byte) ([]byte, error) {
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	if node.Kind == 0 {
+		return nil, fmt.Errorf("invalid YAML: empty document")
+	}
+	expander := &yamlExpander{budget: yamlNodeCount(&node) + maxYAMLAliasNodes}
+	value, err := expander.value(&node, 0)
+	if err != nil {
+		return nil, fmt.Errorf("invalid YAML: %w", err)
+	}
+	return json.Marshal(value)
+}
+
+// yamlNodeCount counts the nodes written in a document, without following aliases
+func yamlNodeCount(node *yaml.Node) int {
+	count := 1
+	for _, child := range node.Content {
+		count += yamlNodeCount(child)
+	}
+	return count
+}
+
+// yamlExpander converts YAML nodes to JSON values, charging every node it
+// produces, aliased or not, against budget
+type yamlExpander struct {
+	budget int
+}
+
+// enter charges one node at depth against the expander's limits
+func (e *yamlExpander) enter(node *yaml.Node, depth int) error {
+	if depth > maxYAMLDepth {
+		return fmt.Errorf("line %d: %w: nested deeper than %d levels", node.Line, ErrYAMLTooComplex, maxYAMLDepth)
+	}
+	if e.budget--; e.budget < 0 {
+		return fmt.Errorf("line %d: %w: aliases add more than %d nodes", node.Line, ErrYAMLTooComplex, maxYAMLAliasNodes)
+	}
+	return nil
+}
+
+func (e *yamlExpander) value(node *yaml.Node, depth int) (interface{}, error) {
+	if err := e.enter(node, depth); err != nil {
+		return nil, err
+	}
+	switch node.Kind {
+	case yaml.DocumentNode:
+		return e.value(node.Content[0], depth+1)
+	case yaml.AliasNode:
+		return e.value(node.Alias, depth)
+	case yaml.SequenceNode:
+		values := make([]interface{}, len(node.Content))
+		for i, child := range node.Content {
+			value, err := e.value(child, depth+1)
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case yaml.MappingNode:
+		fields := make(map[string]interface{}, len(node.Content)/2)
+		if err := e.fields(node, fields, depth); err != nil {
+			return nil, err
+		}
+		return fields, nil
+	case yaml.ScalarNode:
+		return yamlScalar(node)
+	}
+	return nil, fmt.Errorf("line %d: unsupported node", node.Line)
+}
+
+// fields adds a mapping's fields to fields. Merged mappings are added first so
+// the mapping's own keys override them.
+func (e *yamlExpander) fields(node *yaml.Node, fields map[string]interface{}, depth int) error {
+	var own [][2]*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		key, value := node.Content[i], node.Content[i+1]
+		if key.ShortTag() != "!!merge" {
+			own = append(own, [2]*yaml.Node{key, value})
+			continue
+		}
+		if value.Kind == yaml.AliasNode {
+			value = value.Alias
+		}
+		merged := []*yaml.Node{value}
+		if value.Kind == yaml.SequenceNode {
+			merged = value.Content
+		}
+		for _, m := range merged {
+			if m.Kind == yaml.AliasNode {
+				m = m.Alias
+			}
+			if m.Kind != yaml.MappingNode {
+				return fmt.Errorf("line %d: merge key needs a mapping", m.Line)
+			}
+			if err := e.enter(m, depth); err != nil {
+				return err
+			}
+			if err := e.fields(m, fields, depth); err != nil {
+				return err
+			}
+		}
+	}
+
+	for _, pair := range own {
+		key := pair[0]
+		if key.Kind == yaml.AliasNode {
+			key = key.Alias
+		}
+		if key.Kind != yaml.ScalarNode {
+			return fmt.Errorf("line %d: mapping keys must be scalars", key.Line)
+		}
+		value, err := e.value(pair[1], depth+1)
+		if err != nil {
+			return err
+		}
+		fields[key.Value] = value
+	}
+	return nil
+}
+
+func yamlScalar(node *yaml.Node) (interface{}, error) {
+	switch node.ShortTag() {
+	case "!!null":
+		return nil, nil
+	case "!!bool", "!!int", "!!float":
+		// JSON-compatible numbers are passed through so large integers keep
+		// every digit
+		if node.ShortTag() != "!!bool" && json.Valid([]byte(node.Value)) {
+			return json.Number(node.Value), nil
+		}
+		var value interface{}
+		if err := node.Decode(&value); err != nil {
+			return nil, err
+		}
+		if f, ok := value.(float64); ok && (math.IsInf(f, 0) || math.IsNaN(f)) {
+			return nil, fmt.Errorf("line %d: %s cannot be represented in JSON", node.Line, node.Value)
+		}
+		return value, nil
+	}
+	return node.Value, nil
+}
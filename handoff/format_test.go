@@ -0,0 +1,219 @@
+package handoff
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatRoundTrip(t *testing.T) {
+	original := newPolicyTestHandoff("golang-expert", map[string]interface{}{
+		"handlers":      []interface{}{"CreateInvoice", "GetInvoice"},
+		"test_coverage": 85.5,
+		"release":       "2024-01-01",
+		"flag":          "true",
+		"schema":        "CREATE TABLE invoices (\n  id INT\n);",
+		"notes":         nil,
+	})
+	original.Metadata.HandoffID = "invoice-handoff"
+	original.Content.Artifacts = Artifacts{Created: []string{"internal/invoice.go"}}
+	original.Validation = Validation{SchemaVersion: "1.0", Checksum: original.GenerateChecksum()}
+
+	jsonData, err := Marshal(original, FormatJSON)
+	if err != nil {
+		t.Fatal(err)
+	}
+	yamlData, err := Marshal(original, FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(yamlData, []byte("summary: Implement invoice endpoints")) || bytes.Contains(yamlData, []byte("{")) {
+		t.Errorf("expected block-style YAML, got:\n%s", yamlData)
+	}
+
+	var fromJSON, fromYAML Handoff
+	if err := Unmarshal(jsonData, FormatJSON, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unmarshal(yamlData, FormatYAML, &fromYAML); err != nil {
+		t.Fatalf("Unmarshal YAML failed: %v\n%s", err, yamlData)
+	}
+	if !reflect.DeepEqual(fromJSON, fromYAML) {
+		t.Errorf("formats decoded differently:\njson: %+v\nyaml: %+v", fromJSON, fromYAML)
+	}
+	if err := fromYAML.VerifyChecksum(); err != nil {
+		t.Errorf("expected the checksum to survive YAML, got %v", err)
+	}
+
+	// And back: YAML produced from the YAML-decoded handoff is unchanged
+	again, err := Marshal(&fromYAML, FormatYAML)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(again, yamlData) {
+		t.Errorf("expected a stable YAML encoding, got:\n%s\nwant:\n%s", again, yamlData)
+	}
+}
+
+func TestYAMLValidationMatchesJSON(t *testing.T) {
+	validator := NewHandoffValidator()
+
+	jsonDoc := `{
+  "metadata": {"from_agent": "api-expert", "to_agent": "golang-expert", "timestamp": "2024-05-01T10:00:00Z", "task_context": "invoices", "priority": "urgent"},
+  "content": {"summary": "", "requirements": ["REST API"], "technical_details": {"handlers": [42], "test_coverage": 120}},
+  "validation": {"schema_version": "1.0"}
+}`
+	yamlDoc := `
+metadata:
+  from_agent: api-expert
+  to_agent: golang-expert
+  timestamp: 2024-05-01T10:00:00Z
+  task_context: invoices
+  priority: urgent
+content:
+  summary: ""
+  requirements: [REST API]
+  technical_details:
+    handlers: [42]
+    test_coverage: 120
+validation:
+  schema_version: "1.0"
+`
+
+	var fromJSON, fromYAML Handoff
+	if err := Unmarshal([]byte(jsonDoc), FormatJSON, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if err := Unmarshal([]byte(yamlDoc), FormatYAML, &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+
+	jsonReport := validator.Check(&fromJSON)
+	yamlReport := validator.Check(&fromYAML)
+	if len(jsonReport.Violations) == 0 {
+		t.Fatal("expected the fixture to have violations")
+	}
+	if !reflect.DeepEqual(jsonReport.Violations, yamlReport.Violations) {
+		t.Errorf("validation differs:\njson: %v\nyaml: %v", jsonReport.Violations, yamlReport.Violations)
+	}
+}
+
+func TestYAMLToJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		yaml    string
+		want    string
+		wantErr string
+	}{
+		{"timestamps stay strings", "released: 2024-01-01", `{"released":"2024-01-01"}`, ""},
+		{"large integers keep their digits", "id: 12345678901234567890", `{"id":12345678901234567890}`, ""},
+		{"yaml-only numbers", "mode: 0x1F\nratio: .5", `{"mode":31,"ratio":0.5}`, ""},
+		{"anchors and merge keys", "base: &b {x: 1, y: 2}\nd:\n  <<: *b\n  y: 3", `{"base":{"x":1,"y":2},"d":{"x":1,"y":3}}`, ""},
+		{"null and booleans", "a: ~\nb: true\nc: 'true'", `{"a":null,"b":true,"c":"true"}`, ""},
+		{"infinity", "limit: .inf", "", "cannot be represented in JSON"},
+		{"empty document", "", "", "empty document"},
+		{"malformed", "a: [1, 2", "", "invalid YAML"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := YAMLToJSON([]byte(tt.yaml))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(got) != tt.want {
+				t.Errorf("YAMLToJSON = %s, want %s", got, tt.want)
+			}
+			if !json.Valid(got) {
+				t.Errorf("expected valid JSON, got %s", got)
+			}
+		})
+	}
+}
+
+func TestYAMLToJSONExpansionLimits(t *testing.T) {
+	// Billion laughs: each level repeats the one before nine times
+	var doc strings.Builder
+	doc.WriteString("a0: &a0 [lol, lol, lol, lol, lol, lol, lol, lol, lol]\n")
+	for i := 1; i <= 9; i++ {
+		fmt.Fprintf(&doc, "a%d: &a%d [", i, i)
+		for j := 0; j < 9; j++ {
+			if j > 0 {
+				doc.WriteString(", ")
+			}
+			fmt.Fprintf(&doc, "*a%d", i-1)
+		}
+		doc.WriteString("]\n")
+	}
+	got, err := YAMLToJSON([]byte(doc.String()))
+	if !errors.Is(err, ErrYAMLTooComplex) {
+		t.Fatalf("expected ErrYAMLTooComplex, got %d bytes and %v", len(got), err)
+	}
+
+	deep := strings.Repeat("[", maxYAMLDepth+1) + strings.Repeat("]", maxYAMLDepth+1)
+	if _, err := YAMLToJSON([]byte(deep)); !errors.Is(err, ErrYAMLTooComplex) {
+		t.Errorf("expected deep nesting to be refused, got %v", err)
+	}
+
+	// Large documents without aliases and modest reuse of anchors still convert
+	large := strings.Repeat("- {name: item, tags: [a, b, c]}\n", 5000)
+	if _, err := YAMLToJSON([]byte(large)); err != nil {
+		t.Errorf("expected a large plain document to convert, got %v", err)
+	}
+	reuse := "base: &b {x: 1, y: 2}\nitems: [" + strings.TrimSuffix(strings.Repeat("*b, ", 1000), ", ") + "]"
+	if _, err := YAMLToJSON([]byte(reuse)); err != nil {
+		t.Errorf("expected modest anchor reuse to convert, got %v", err)
+	}
+}
+
+func TestNegotiateFormat(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   Format
+	}{
+		{"", FormatJSON},
+		{"*/*", FormatJSON},
+		{"application/yaml", FormatYAML},
+		{"application/x-yaml, text/plain", FormatYAML},
+		{"application/json, application/yaml", FormatJSON},
+		{"application/json;q=0.5, application/yaml", FormatYAML},
+		{"application/yaml;q=0.9, application/json", FormatJSON},
+		{"text/html", FormatJSON},
+	}
+	for _, tt := range tests {
+		if got := NegotiateFormat(tt.accept); got != tt.want {
+			t.Errorf("NegotiateFormat(%q) = %s, want %s", tt.accept, got, tt.want)
+		}
+	}
+
+	for contentType, want := range map[string]Format{
+		"application/json; charset=utf-8": FormatJSON,
+		"application/yaml":                FormatYAML,
+		"text/yaml":                       FormatYAML,
+		"application/vnd.handoff+yaml":    FormatYAML,
+	} {
+		if got, ok := FormatForContentType(contentType); !ok || got != want {
+			t.Errorf("FormatForContentType(%q) = %s, %v", contentType, got, ok)
+		}
+	}
+	if _, ok := FormatForContentType("text/plain"); ok {
+		t.Error("expected text/plain not to be recognised")
+	}
+
+	if f, err := ParseFormat("YML"); err != nil || f != FormatYAML {
+		t.Errorf("ParseFormat(YML) = %s, %v", f, err)
+	}
+	if _, err := ParseFormat("xml"); err == nil {
+		t.Error("expected unknown format to be refused")
+	}
+}
@@ -6,6 +6,7 @@ require (
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/google/uuid v1.4.0
 	github.com/rs/zerolog v1.31.0
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
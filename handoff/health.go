@@ -0,0 +1,379 @@
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Health components scored by EvaluateHealth
+const (
+	HealthRedis         = "redis"          // 1 when Redis answers, 0 when it does not
+	HealthQueueBacklog  = "queue_backlog"  // Handoffs waiting in every queue
+	HealthFailureRate   = "failure_rate"   // Failed handoffs per published handoff over the window, in percent
+	HealthLatency       = "latency"        // 95th percentile processing time over the window, in seconds
+	HealthPoolUsage     = "pool_usage"     // Redis connections in use, in percent of the pool
+	HealthActiveAgents  = "active_agents"  // Agents with a live heartbeat
+	HealthExecutorTools = "executor_tools" // External tools the agent executor can run
+	HealthArchiveDisk   = "archive_disk"   // Free space on the archive's filesystem, in percent
+)
+
+// HealthComponents lists the scored components in report order
+var HealthComponents = []string{
+	HealthRedis, HealthQueueBacklog, HealthFailureRate, HealthLatency,
+	HealthPoolUsage, HealthActiveAgents, HealthExecutorTools, HealthArchiveDisk,
+}
+
+// DefaultHealthMinScore is the lowest score at which a service is ready
+const DefaultHealthMinScore = 50
+
+// errDiskStatsUnsupported is returned where filesystem statistics are unavailable
+var errDiskStatsUnsupported = errors.New("disk statistics are not supported on this platform")
+
+// DefaultHealthWindow is the period failure rate and latency are read over
+const DefaultHealthWindow = 15 * time.Minute
+
+// HealthLevel is how a component's reading compares with its thresholds
+type HealthLevel string
+
+const (
+	HealthOK       HealthLevel = "ok"
+	HealthWarn     HealthLevel = "warn"
+	HealthCritical HealthLevel = "critical"
+)
+
+// HealthThreshold scores one component. Readings past Warn take points off
+// the score, growing linearly until the whole Weight is lost at Critical.
+// Components where lower readings are worse have Critical below Warn.
+type HealthThreshold struct {
+	Weight   float64 `json:"weight"`
+	Warn     float64 `json:"warn"`
+	Critical float64 `json:"critical"`
+	Required bool    `json:"required,omitempty"` // A critical reading makes the service not ready whatever its score
+}
+
+// HealthConfig sets each component's threshold and the score readiness
+// needs. Components not listed keep their DefaultHealthThresholds.
+type HealthConfig struct {
+	Components map[string]HealthThreshold `json:"components,omitempty"`
+	MinScore   float64                    `json:"min_score,omitempty"` // Default DefaultHealthMinScore
+	Window     time.Duration              `json:"window,omitempty"`    // Default DefaultHealthWindow
+}
+
+// DefaultHealthThresholds are the thresholds of components a HealthConfig
+// does not list. Their weights add up to 100.
+func DefaultHealthThresholds() map[string]HealthThreshold {
+	return map[string]HealthThreshold{
+		HealthRedis:         {Weight: 30, Warn: 1, Critical: 0, Required: true},
+		HealthQueueBacklog:  {Weight: 15, Warn: 50, Critical: 250},
+		HealthFailureRate:   {Weight: 15, Warn: 5, Critical: 25},
+		HealthLatency:       {Weight: 10, Warn: 60, Critical: 600},
+		HealthPoolUsage:     {Weight: 10, Warn: 80, Critical: 100},
+		HealthActiveAgents:  {Weight: 10, Warn: 1, Critical: 0},
+		HealthExecutorTools: {Weight: 5, Warn: 1, Critical: 0},
+		HealthArchiveDisk:   {Weight: 5, Warn: 20, Critical: 5},
+	}
+}
+
+// Threshold returns the threshold configured for component, or its default
+func (c HealthConfig) Threshold(component string) HealthThreshold {
+	if threshold, ok := c.Components[component]; ok {
+		return threshold
+	}
+	return DefaultHealthThresholds()[component]
+}
+
+func (c HealthConfig) minScore() float64 {
+	if c.MinScore <= 0 {
+		return DefaultHealthMinScore
+	}
+	return c.MinScore
+}
+
+func (c HealthConfig) window() time.Duration {
+	if c.Window <= 0 {
+		return DefaultHealthWindow
+	}
+	return c.Window
+}
+
+// Validate checks that every configured component is known and has a usable
+// threshold
+func (c HealthConfig) Validate() error {
+	defaults := DefaultHealthThresholds()
+	for name, threshold := range c.Components {
+		if _, ok := defaults[name]; !ok {
+			return fmt.Errorf("unknown health component %q (expected one of %s)", name, strings.Join(HealthComponents, ", "))
+		}
+		if threshold.Weight < 0 {
+			return fmt.Errorf("health component %s has a negative weight", name)
+		}
+		if threshold.Warn == threshold.Critical {
+			return fmt.Errorf("health component %s needs different warn and critical thresholds", name)
+		}
+	}
+	if c.MinScore < 0 || c.MinScore > 100 {
+		return fmt.Errorf("health min score %v is outside 0-100", c.MinScore)
+	}
+	if c.Window < 0 {
+		return fmt.Errorf("health window cannot be negative")
+	}
+	return nil
+}
+
+// ParseHealthThresholds parses "component=weight:warn:critical[:required],..."
+func ParseHealthThresholds(s string) (map[string]HealthThreshold, error) {
+	thresholds := map[string]HealthThreshold{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid health threshold %q: expected component=weight:warn:critical", part)
+		}
+		fields := strings.Split(spec, ":")
+		if len(fields) < 3 || len(fields) > 4 || (len(fields) == 4 && fields[3] != "required") {
+			return nil, fmt.Errorf("invalid health threshold %q: expected component=weight:warn:critical[:required]", part)
+		}
+		var values [3]float64
+		for i := range values {
+			value, err := strconv.ParseFloat(fields[i], 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid health threshold %q: %w", part, err)
+			}
+			values[i] = value
+		}
+		thresholds[strings.TrimSpace(name)] = HealthThreshold{
+			Weight:   values[0],
+			Warn:     values[1],
+			Critical: values[2],
+			Required: len(fields) == 4,
+		}
+	}
+	if err := (HealthConfig{Components: thresholds}).Validate(); err != nil {
+		return nil, err
+	}
+	return thresholds, nil
+}
+
+// HealthReading is a component's measured value. A reading with Err set is
+// a check that could not run, which counts as critical.
+type HealthReading struct {
+	Component string
+	Value     float64
+	Err       error
+}
+
+// HealthComponentReport is one component's reading and what it cost the score
+type HealthComponentReport struct {
+	Name      string      `json:"name"`
+	Status    HealthLevel `json:"status"`
+	Value     float64     `json:"value"`
+	Deduction float64     `json:"deduction"` // Points taken off the score
+	Weight    float64     `json:"weight"`
+	Warn      float64     `json:"warn"`
+	Critical  float64     `json:"critical"`
+	Required  bool        `json:"required,omitempty"`
+	Error     string      `json:"error,omitempty"`
+}
+
+// HealthReport is a service's health score and each measured component's
+// contribution to it
+type HealthReport struct {
+	Score      float64                 `json:"score"`
+	MinScore   float64                 `json:"min_score"`
+	Ready      bool                    `json:"ready"`
+	Components []HealthComponentReport `json:"components"`
+	CheckedAt  time.Time               `json:"checked_at"`
+}
+
+// EvaluateHealth scores readings against cfg. The score starts at 100 and
+// loses each component's deduction; the service is ready when the score
+// reaches the minimum and no required component is critical. Components
+// without a reading are left out.
+func EvaluateHealth(cfg HealthConfig, readings []HealthReading, now time.Time) HealthReport {
+	report := HealthReport{Score: 100, MinScore: cfg.minScore(), Ready: true, CheckedAt: now}
+	requiredCritical := false
+	for _, reading := range readings {
+		threshold := cfg.Threshold(reading.Component)
+		component := HealthComponentReport{
+			Name:     reading.Component,
+			Value:    reading.Value,
+			Weight:   threshold.Weight,
+			Warn:     threshold.Warn,
+			Critical: threshold.Critical,
+			Required: threshold.Required,
+		}
+
+		fraction := 1.0
+		if reading.Err != nil {
+			component.Error = reading.Err.Error()
+		} else if threshold.Warn != threshold.Critical {
+			fraction = (reading.Value - threshold.Warn) / (threshold.Critical - threshold.Warn)
+		}
+		switch {
+		case fraction <= 0:
+			component.Status = HealthOK
+			fraction = 0
+		case fraction >= 1:
+			component.Status = HealthCritical
+			fraction = 1
+		default:
+			component.Status = HealthWarn
+		}
+		component.Deduction = threshold.Weight * fraction
+		report.Score -= component.Deduction
+		if component.Status == HealthCritical && threshold.Required {
+			requiredCritical = true
+		}
+		report.Components = append(report.Components, component)
+	}
+
+	order := make(map[string]int, len(HealthComponents))
+	for i, name := range HealthComponents {
+		order[name] = i
+	}
+	sort.SliceStable(report.Components, func(i, j int) bool {
+		return order[report.Components[i].Name] < order[report.Components[j].Name]
+	})
+
+	if report.Score < 0 {
+		report.Score = 0
+	}
+	report.Ready = report.Score >= report.MinScore && !requiredCritical
+	return report
+}
+
+// HealthCheck measures one or more components
+type HealthCheck func(ctx context.Context) []HealthReading
+
+// HealthChecker runs health checks and scores their readings
+type HealthChecker struct {
+	config HealthConfig
+	checks []HealthCheck
+}
+
+// NewHealthChecker creates a checker scoring checks' readings with cfg
+func NewHealthChecker(cfg HealthConfig, checks ...HealthCheck) *HealthChecker {
+	return &HealthChecker{config: cfg, checks: checks}
+}
+
+// Report runs every check and scores the readings
+func (c *HealthChecker) Report(ctx context.Context) HealthReport {
+	var readings []HealthReading
+	for _, check := range c.checks {
+		readings = append(readings, check(ctx)...)
+	}
+	return EvaluateHealth(c.config, readings, time.Now())
+}
+
+// RedisHealthCheck pings Redis
+func RedisHealthCheck(client redis.Cmdable) HealthCheck {
+	return func(ctx context.Context) []HealthReading {
+		if err := client.Ping(ctx).Err(); err != nil {
+			return []HealthReading{{Component: HealthRedis, Err: err}}
+		}
+		return []HealthReading{{Component: HealthRedis, Value: 1}}
+	}
+}
+
+// MetricsHealthCheck reads the queue backlog, the active agents and, over
+// cfg's window, the failure rate and 95th percentile processing time from the
+// metrics kept in Redis
+func MetricsHealthCheck(client redis.Cmdable, cfg HealthConfig) HealthCheck {
+	return func(ctx context.Context) []HealthReading {
+		now := time.Now()
+		var readings []HealthReading
+
+		queues, err := collectQueueMetrics(ctx, client, now)
+		backlog := HealthReading{Component: HealthQueueBacklog, Err: err}
+		for _, q := range queues {
+			backlog.Value += float64(q.Depth)
+		}
+		readings = append(readings, backlog)
+
+		rolling, err := QueryRollingMetrics(ctx, client, now.Add(-cfg.window()), now)
+		if err != nil {
+			readings = append(readings,
+				HealthReading{Component: HealthFailureRate, Err: err},
+				HealthReading{Component: HealthLatency, Err: err})
+		} else {
+			total := rolling.Aggregate(MetricLabels{}).Summary()
+			failureRate := 0.0
+			if total.Published > 0 {
+				failureRate = float64(total.Failed) / float64(total.Published) * 100
+			}
+			readings = append(readings,
+				HealthReading{Component: HealthFailureRate, Value: failureRate},
+				HealthReading{Component: HealthLatency, Value: total.P95.Seconds()})
+		}
+
+		agents, err := CollectAgentLiveness(ctx, client, now)
+		readings = append(readings, HealthReading{Component: HealthActiveAgents, Value: float64(len(liveAgents(agents))), Err: err})
+		return readings
+	}
+}
+
+// PoolHealthCheck reports the share of the connection pool in use
+func PoolHealthCheck(pool func() RedisPoolMetrics) HealthCheck {
+	return func(ctx context.Context) []HealthReading {
+		return []HealthReading{{Component: HealthPoolUsage, Value: poolUsage(pool())}}
+	}
+}
+
+func poolUsage(pool RedisPoolMetrics) float64 {
+	if pool.TotalConns == 0 || pool.IdleConns > pool.TotalConns {
+		return 0
+	}
+	return float64(pool.TotalConns-pool.IdleConns) / float64(pool.TotalConns) * 100
+}
+
+// DiskHealthCheck reports the free space on the filesystem holding dir, or
+// the nearest parent that exists, as HealthArchiveDisk. Platforms without
+// filesystem statistics report nothing.
+func DiskHealthCheck(dir string) HealthCheck {
+	return func(ctx context.Context) []HealthReading {
+		path, err := filepath.Abs(dir)
+		if err != nil {
+			return []HealthReading{{Component: HealthArchiveDisk, Err: err}}
+		}
+		for {
+			if _, err := os.Stat(path); err == nil || filepath.Dir(path) == path {
+				break
+			}
+			path = filepath.Dir(path)
+		}
+		free, err := diskFreePercent(path)
+		if err == errDiskStatsUnsupported {
+			return nil
+		}
+		return []HealthReading{{Component: HealthArchiveDisk, Value: free, Err: err}}
+	}
+}
+
+// HealthHandler serves report as JSON, with 503 Service Unavailable when
+// the service is not ready
+func HealthHandler(report func(ctx context.Context) HealthReport) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		health := report(r.Context())
+		status := http.StatusOK
+		if !health.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(status)
+		json.NewEncoder(w).Encode(health)
+	})
+}
@@ -0,0 +1,141 @@
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEvaluateHealth(t *testing.T) {
+	now := time.Now()
+	report := EvaluateHealth(HealthConfig{}, []HealthReading{
+		{Component: HealthArchiveDisk, Value: 12.5}, // Halfway from 20% to 5% free
+		{Component: HealthQueueBacklog, Value: 150}, // Halfway from 50 to 250
+		{Component: HealthFailureRate, Value: 1},    // Below warn
+		{Component: HealthActiveAgents, Value: 0},   // Critical, lower is worse
+		{Component: HealthPoolUsage, Value: 120},    // Past critical, capped at the weight
+		{Component: HealthRedis, Value: 1},          // Reachable
+		{Component: HealthLatency, Err: errors.New("no data")},
+	}, now)
+
+	want := map[string]struct {
+		status    HealthLevel
+		deduction float64
+	}{
+		HealthRedis:        {HealthOK, 0},
+		HealthQueueBacklog: {HealthWarn, 7.5},
+		HealthFailureRate:  {HealthOK, 0},
+		HealthLatency:      {HealthCritical, 10},
+		HealthPoolUsage:    {HealthCritical, 10},
+		HealthActiveAgents: {HealthCritical, 10},
+		HealthArchiveDisk:  {HealthWarn, 2.5},
+	}
+	if len(report.Components) != len(want) {
+		t.Fatalf("expected %d components, got %+v", len(want), report.Components)
+	}
+	for i, component := range report.Components {
+		if i > 0 && component.Name == HealthRedis {
+			t.Errorf("expected components in report order, got %s at %d", component.Name, i)
+		}
+		w := want[component.Name]
+		if component.Status != w.status || math.Abs(component.Deduction-w.deduction) > 1e-9 {
+			t.Errorf("%s: expected %s with %v deducted, got %s with %v", component.Name, w.status, w.deduction, component.Status, component.Deduction)
+		}
+	}
+	if report.Components[3].Error != "no data" {
+		t.Errorf("expected the latency check's error to be reported, got %+v", report.Components[3])
+	}
+	if math.Abs(report.Score-60) > 1e-9 || !report.Ready || report.MinScore != DefaultHealthMinScore {
+		t.Errorf("expected a ready score of 60, got %+v", report)
+	}
+
+	// A required component that is critical makes the service not ready
+	report = EvaluateHealth(HealthConfig{}, []HealthReading{{Component: HealthRedis, Value: 0}}, now)
+	if report.Score != 70 || report.Ready {
+		t.Errorf("expected a score of 70 and not ready without Redis, got %+v", report)
+	}
+
+	// Overrides replace the default threshold and minimum score
+	cfg := HealthConfig{
+		Components: map[string]HealthThreshold{HealthQueueBacklog: {Weight: 80, Warn: 0, Critical: 10}},
+		MinScore:   90,
+	}
+	report = EvaluateHealth(cfg, []HealthReading{{Component: HealthQueueBacklog, Value: 5}}, now)
+	if report.Score != 60 || report.Ready {
+		t.Errorf("expected a score of 60 below the minimum of 90, got %+v", report)
+	}
+}
+
+func TestParseHealthThresholds(t *testing.T) {
+	thresholds, err := ParseHealthThresholds(" queue_backlog=20:100:500, redis=40:1:0:required,, ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := thresholds[HealthQueueBacklog]; got != (HealthThreshold{Weight: 20, Warn: 100, Critical: 500}) {
+		t.Errorf("unexpected queue_backlog threshold %+v", got)
+	}
+	if got := thresholds[HealthRedis]; got != (HealthThreshold{Weight: 40, Warn: 1, Critical: 0, Required: true}) {
+		t.Errorf("unexpected redis threshold %+v", got)
+	}
+
+	for bad, wantErr := range map[string]string{
+		"latency":                    "expected component=weight",
+		"latency=10:60":              "expected component=weight",
+		"latency=10:60:600:optional": "expected component=weight",
+		"latency=ten:60:600":         "invalid health threshold",
+		"latency=10:60:60":           "different warn and critical",
+		"latency=-1:60:600":          "negative weight",
+		"uptime=10:1:0":              "unknown health component",
+	} {
+		if _, err := ParseHealthThresholds(bad); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%q: expected an error containing %q, got %v", bad, wantErr, err)
+		}
+	}
+	if err := (HealthConfig{MinScore: 120}).Validate(); err == nil {
+		t.Error("expected a minimum score above 100 to be refused")
+	}
+}
+
+func TestHealthHandler(t *testing.T) {
+	agents := 1.0
+	checker := NewHealthChecker(HealthConfig{MinScore: 95},
+		func(ctx context.Context) []HealthReading {
+			return []HealthReading{{Component: HealthActiveAgents, Value: agents}}
+		},
+		PoolHealthCheck(func() RedisPoolMetrics { return RedisPoolMetrics{TotalConns: 10, IdleConns: 8} }),
+		DiskHealthCheck(t.TempDir()+"/archive/not-yet-created"),
+	)
+	serve := func() (*httptest.ResponseRecorder, HealthReport) {
+		rec := httptest.NewRecorder()
+		HealthHandler(checker.Report).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+		var report HealthReport
+		if err := json.Unmarshal(rec.Body.Bytes(), &report); err != nil {
+			t.Fatalf("expected a JSON report, got %q: %v", rec.Body.String(), err)
+		}
+		return rec, report
+	}
+
+	rec, report := serve()
+	if rec.Code != http.StatusOK || !report.Ready {
+		t.Fatalf("expected a ready service, got %d: %s", rec.Code, rec.Body.String())
+	}
+	for _, component := range report.Components {
+		if component.Name == HealthPoolUsage && component.Value != 20 {
+			t.Errorf("expected 20%% of the pool in use, got %v", component.Value)
+		}
+		if component.Name == HealthArchiveDisk && component.Error != "" {
+			t.Errorf("expected the archive's nearest existing parent to be checked, got %q", component.Error)
+		}
+	}
+
+	agents = 0
+	if rec, report = serve(); rec.Code != http.StatusServiceUnavailable || report.Ready {
+		t.Errorf("expected 503 without agents, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,368 @@
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+)
+
+// Each instance's heartbeat is its own key, "handoff:heartbeat:<agent>:<instance>",
+// expiring on its own; the index lists the instances with their expiry so
+// they can be found without scanning
+const (
+	HeartbeatIndexKey   = "handoff:heartbeats"        // "<agent>\x1f<instance>" scored by expiry in unix milliseconds
+	RegisteredAgentsKey = "handoff:registered_agents" // Agents expected to have a live consumer
+)
+
+const (
+	heartbeatKeyPrefix     = "handoff:heartbeat:"
+	heartbeatMemberSep     = "\x1f"
+	heartbeatRemoveTimeout = 5 * time.Second
+)
+
+// DefaultHeartbeatInterval is how often instances send heartbeats
+const DefaultHeartbeatInterval = 10 * time.Second
+
+// AgentHeartbeat is the latest heartbeat of one instance of an agent
+type AgentHeartbeat struct {
+	Agent      string    `json:"agent"`
+	InstanceID string    `json:"instance_id"`
+	Host       string    `json:"host"`
+	Version    string    `json:"version,omitempty"`
+	Load       int       `json:"load"`               // Handoffs being processed
+	Capacity   int       `json:"capacity,omitempty"` // Handoffs it processes at once; 0 when unbounded
+	StartedAt  time.Time `json:"started_at"`
+	SentAt     time.Time `json:"sent_at"`
+}
+
+// HeartbeatConfig sets how instances report that they are alive
+type HeartbeatConfig struct {
+	Interval   time.Duration `json:"interval,omitempty"`    // Default DefaultHeartbeatInterval
+	TTL        time.Duration `json:"ttl,omitempty"`         // How long a heartbeat counts; default three intervals
+	InstanceID string        `json:"instance_id,omitempty"` // Default <host>-<pid>
+	Version    string        `json:"version,omitempty"`     // Reported with each heartbeat
+}
+
+// Validate checks that the heartbeat outlives the interval it is sent at
+func (c HeartbeatConfig) Validate() error {
+	if c.Interval < 0 || c.TTL < 0 {
+		return fmt.Errorf("heartbeat interval and ttl cannot be negative")
+	}
+	if c.TTL > 0 && c.TTL <= c.interval() {
+		return fmt.Errorf("heartbeat ttl %s must be longer than the interval %s", c.TTL, c.interval())
+	}
+	if strings.Contains(c.InstanceID, heartbeatMemberSep) {
+		return fmt.Errorf("invalid heartbeat instance ID %q", c.InstanceID)
+	}
+	return nil
+}
+
+func (c HeartbeatConfig) interval() time.Duration {
+	if c.Interval <= 0 {
+		return DefaultHeartbeatInterval
+	}
+	return c.Interval
+}
+
+func (c HeartbeatConfig) ttl() time.Duration {
+	if c.TTL <= 0 {
+		return 3 * c.interval()
+	}
+	return c.TTL
+}
+
+func (c HeartbeatConfig) instanceID(host string) string {
+	if c.InstanceID == "" {
+		return fmt.Sprintf("%s-%d", host, os.Getpid())
+	}
+	return c.InstanceID
+}
+
+func heartbeatKey(agent, instanceID string) string {
+	return heartbeatKeyPrefix + agent + ":" + instanceID
+}
+
+// SendHeartbeat records hb, which counts as live for ttl
+func SendHeartbeat(ctx context.Context, client redis.Cmdable, hb AgentHeartbeat, ttl time.Duration) error {
+	data, err := json.Marshal(hb)
+	if err != nil {
+		return fmt.Errorf("failed to encode heartbeat: %w", err)
+	}
+	pipe := client.TxPipeline()
+	pipe.Set(ctx, heartbeatKey(hb.Agent, hb.InstanceID), data, ttl)
+	pipe.ZAdd(ctx, HeartbeatIndexKey, &redis.Z{
+		Score:  float64(hb.SentAt.Add(ttl).UnixMilli()),
+		Member: hb.Agent + heartbeatMemberSep + hb.InstanceID,
+	})
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to send heartbeat: %w", err)
+	}
+	return nil
+}
+
+// RemoveHeartbeat removes an instance's heartbeat, so it stops counting as
+// live straight away
+func RemoveHeartbeat(ctx context.Context, client redis.Cmdable, agent, instanceID string) error {
+	pipe := client.TxPipeline()
+	pipe.Del(ctx, heartbeatKey(agent, instanceID))
+	pipe.ZRem(ctx, HeartbeatIndexKey, agent+heartbeatMemberSep+instanceID)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return fmt.Errorf("failed to remove heartbeat: %w", err)
+	}
+	return nil
+}
+
+// LiveHeartbeats returns the heartbeats that have not expired, by agent and
+// instance
+func LiveHeartbeats(ctx context.Context, client redis.Cmdable, now time.Time) ([]AgentHeartbeat, error) {
+	if err := client.ZRemRangeByScore(ctx, HeartbeatIndexKey, "-inf", fmt.Sprint(now.UnixMilli())).Err(); err != nil {
+		return nil, fmt.Errorf("failed to prune heartbeats: %w", err)
+	}
+	members, err := client.ZRange(ctx, HeartbeatIndexKey, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list heartbeats: %w", err)
+	}
+	if len(members) == 0 {
+		return nil, nil
+	}
+	keys := make([]string, len(members))
+	for i, member := range members {
+		agent, instanceID, _ := strings.Cut(member, heartbeatMemberSep)
+		keys[i] = heartbeatKey(agent, instanceID)
+	}
+	values, err := client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read heartbeats: %w", err)
+	}
+
+	heartbeats := make([]AgentHeartbeat, 0, len(values))
+	for i, value := range values {
+		data, ok := value.(string)
+		if !ok {
+			continue // Expired since the index was read
+		}
+		var hb AgentHeartbeat
+		if err := json.Unmarshal([]byte(data), &hb); err != nil {
+			log.Warn().Err(err).Str("key", keys[i]).Msg("Ignoring malformed heartbeat")
+			continue
+		}
+		heartbeats = append(heartbeats, hb)
+	}
+	sort.Slice(heartbeats, func(i, j int) bool {
+		if heartbeats[i].Agent != heartbeats[j].Agent {
+			return heartbeats[i].Agent < heartbeats[j].Agent
+		}
+		return heartbeats[i].InstanceID < heartbeats[j].InstanceID
+	})
+	return heartbeats, nil
+}
+
+// RegisterAgentName records that agent is expected to have a live consumer
+func RegisterAgentName(ctx context.Context, client redis.Cmdable, agent string) error {
+	if err := client.SAdd(ctx, RegisteredAgentsKey, agent).Err(); err != nil {
+		return fmt.Errorf("failed to register agent %s: %w", agent, err)
+	}
+	return nil
+}
+
+// DeregisterAgentName records that agent is no longer expected to run
+func DeregisterAgentName(ctx context.Context, client redis.Cmdable, agent string) error {
+	if err := client.SRem(ctx, RegisteredAgentsKey, agent).Err(); err != nil {
+		return fmt.Errorf("failed to deregister agent %s: %w", agent, err)
+	}
+	return nil
+}
+
+// AgentLiveness is an agent's live instances, for every registered agent and
+// every agent with a live heartbeat
+type AgentLiveness struct {
+	Agent      string           `json:"agent"`
+	Registered bool             `json:"registered"`
+	Instances  []AgentHeartbeat `json:"instances"`
+}
+
+// Load returns the handoffs the agent's live instances are processing
+func (a AgentLiveness) Load() int {
+	load := 0
+	for _, hb := range a.Instances {
+		load += hb.Load
+	}
+	return load
+}
+
+// CollectAgentLiveness returns the live instances of each registered or
+// heartbeating agent, sorted by agent
+func CollectAgentLiveness(ctx context.Context, client redis.Cmdable, now time.Time) ([]AgentLiveness, error) {
+	registered, err := client.SMembers(ctx, RegisteredAgentsKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read registered agents: %w", err)
+	}
+	heartbeats, err := LiveHeartbeats(ctx, client, now)
+	if err != nil {
+		return nil, err
+	}
+
+	byAgent := map[string]*AgentLiveness{}
+	for _, agent := range registered {
+		byAgent[agent] = &AgentLiveness{Agent: agent, Registered: true}
+	}
+	for _, hb := range heartbeats {
+		liveness, ok := byAgent[hb.Agent]
+		if !ok {
+			liveness = &AgentLiveness{Agent: hb.Agent}
+			byAgent[hb.Agent] = liveness
+		}
+		liveness.Instances = append(liveness.Instances, hb)
+	}
+
+	agents := make([]AgentLiveness, 0, len(byAgent))
+	for _, liveness := range byAgent {
+		agents = append(agents, *liveness)
+	}
+	sort.Slice(agents, func(i, j int) bool { return agents[i].Agent < agents[j].Agent })
+	return agents, nil
+}
+
+// liveAgents returns the names of the agents with at least one live instance
+func liveAgents(agents []AgentLiveness) []string {
+	names := []string{}
+	for _, a := range agents {
+		if len(a.Instances) > 0 {
+			names = append(names, a.Agent)
+		}
+	}
+	return names
+}
+
+// Heartbeater sends the heartbeats of one process's instances of the agents
+// it tracks
+type Heartbeater struct {
+	client    redis.Cmdable
+	config    HeartbeatConfig
+	host      string
+	startedAt time.Time
+	mu        sync.Mutex
+	agents    map[string]heartbeatSource
+}
+
+type heartbeatSource struct {
+	capacity int
+	load     func() int
+}
+
+// NewHeartbeater creates a heartbeater sending to client
+func NewHeartbeater(client redis.Cmdable, cfg HeartbeatConfig) *Heartbeater {
+	host, err := os.Hostname()
+	if err != nil {
+		host = "unknown"
+	}
+	return &Heartbeater{
+		client:    client,
+		config:    cfg,
+		host:      host,
+		startedAt: time.Now(),
+		agents:    make(map[string]heartbeatSource),
+	}
+}
+
+// InstanceID returns the instance ID heartbeats are sent under
+func (h *Heartbeater) InstanceID() string {
+	return h.config.instanceID(h.host)
+}
+
+// Track adds agent to the heartbeats sent. load reports the handoffs being
+// processed and capacity how many can be at once, 0 when unbounded.
+func (h *Heartbeater) Track(agent string, capacity int, load func() int) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.agents[agent] = heartbeatSource{capacity: capacity, load: load}
+}
+
+// Tracks reports whether agent's heartbeats are being sent
+func (h *Heartbeater) Tracks(agent string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, ok := h.agents[agent]
+	return ok
+}
+
+// Untrack stops sending agent's heartbeats and removes its current one
+func (h *Heartbeater) Untrack(ctx context.Context, agent string) error {
+	h.mu.Lock()
+	delete(h.agents, agent)
+	h.mu.Unlock()
+	return RemoveHeartbeat(ctx, h.client, agent, h.InstanceID())
+}
+
+// Beat sends the heartbeat of every tracked agent
+func (h *Heartbeater) Beat(ctx context.Context) error {
+	h.mu.Lock()
+	heartbeats := make([]AgentHeartbeat, 0, len(h.agents))
+	now := time.Now()
+	for agent, source := range h.agents {
+		hb := AgentHeartbeat{
+			Agent:      agent,
+			InstanceID: h.InstanceID(),
+			Host:       h.host,
+			Version:    h.config.Version,
+			Capacity:   source.capacity,
+			StartedAt:  h.startedAt,
+			SentAt:     now,
+		}
+		if source.load != nil {
+			hb.Load = source.load()
+		}
+		heartbeats = append(heartbeats, hb)
+	}
+	h.mu.Unlock()
+
+	for _, hb := range heartbeats {
+		if err := SendHeartbeat(ctx, h.client, hb, h.config.ttl()); err != nil {
+			return fmt.Errorf("agent %s: %w", hb.Agent, err)
+		}
+	}
+	return nil
+}
+
+// Run sends heartbeats every interval until ctx is done, then removes them
+func (h *Heartbeater) Run(ctx context.Context) {
+	ticker := time.NewTicker(h.config.interval())
+	defer ticker.Stop()
+	for {
+		if err := h.Beat(ctx); err != nil && ctx.Err() == nil {
+			log.Error().Err(err).Str("instance_id", h.InstanceID()).Msg("Failed to send heartbeat")
+		}
+		select {
+		case <-ctx.Done():
+			h.removeAll()
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// removeAll removes the heartbeats of every tracked agent
+func (h *Heartbeater) removeAll() {
+	h.mu.Lock()
+	agents := make([]string, 0, len(h.agents))
+	for agent := range h.agents {
+		agents = append(agents, agent)
+	}
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), heartbeatRemoveTimeout)
+	defer cancel()
+	for _, agent := range agents {
+		if err := RemoveHeartbeat(ctx, h.client, agent, h.InstanceID()); err != nil {
+			log.Error().Err(err).Str("agent", agent).Msg("Failed to remove heartbeat")
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package handoff
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+func TestHeartbeatConfig(t *testing.T) {
+	var cfg HeartbeatConfig
+	if cfg.interval() != DefaultHeartbeatInterval || cfg.ttl() != 3*DefaultHeartbeatInterval {
+		t.Errorf("unexpected defaults: interval %s, ttl %s", cfg.interval(), cfg.ttl())
+	}
+	if got, want := cfg.instanceID("build-01"), fmt.Sprintf("build-01-%d", os.Getpid()); got != want {
+		t.Errorf("expected instance ID %q, got %q", want, got)
+	}
+
+	for _, bad := range []HeartbeatConfig{
+		{Interval: -time.Second},
+		{Interval: time.Minute, TTL: 30 * time.Second},
+		{TTL: DefaultHeartbeatInterval},
+		{InstanceID: "a\x1fb"},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("%+v: expected an invalid configuration", bad)
+		}
+	}
+	if err := (HeartbeatConfig{Interval: 5 * time.Second, TTL: 15 * time.Second, InstanceID: "worker-1"}).Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestHeartbeatLiveness(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: "localhost:6379", DB: 15})
+	defer client.Close()
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		t.Skipf("Redis not available for testing: %v", err)
+	}
+	if err := client.FlushDB(ctx).Err(); err != nil {
+		t.Fatal(err)
+	}
+	defer client.FlushDB(ctx)
+
+	for _, agent := range []string{"golang-expert", "test-expert"} {
+		if err := RegisterAgentName(ctx, client, agent); err != nil {
+			t.Fatal(err)
+		}
+	}
+	heartbeater := NewHeartbeater(client, HeartbeatConfig{Interval: time.Second, TTL: 2 * time.Second, InstanceID: "worker-1", Version: "1.2.3"})
+	heartbeater.Track("golang-expert", 5, func() int { return 3 })
+	heartbeater.Track("test-expert", 5, func() int { return 0 })
+	if err := heartbeater.Beat(ctx); err != nil {
+		t.Fatal(err)
+	}
+	// A second instance whose heartbeat has already expired
+	stale := AgentHeartbeat{Agent: "test-expert", InstanceID: "worker-2", SentAt: time.Now().Add(-time.Minute)}
+	if err := SendHeartbeat(ctx, client, stale, time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	agents, err := CollectAgentLiveness(ctx, client, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(agents) != 2 || len(agents[0].Instances) != 1 || agents[0].Load() != 3 || len(agents[1].Instances) != 1 {
+		t.Fatalf("expected one live instance per agent, got %+v", agents)
+	}
+	if hb := agents[0].Instances[0]; hb.InstanceID != "worker-1" || hb.Version != "1.2.3" || hb.Capacity != 5 || hb.Host == "" {
+		t.Errorf("unexpected heartbeat %+v", hb)
+	}
+
+	// Each instance expires on its own: test-expert goes down while
+	// golang-expert's heartbeats continue
+	if err := heartbeater.Untrack(ctx, "test-expert"); err != nil {
+		t.Fatal(err)
+	}
+	agents, err = CollectAgentLiveness(ctx, client, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	src := &alertSource{metrics: &HandoffMetrics{}, agents: agents}
+	condition, err := ParseAlertCondition("agents_without_consumer by (agent) > 0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var down []string
+	for _, sample := range condition.evaluate(src) {
+		if sample.holds {
+			down = append(down, sample.labels["agent"])
+		}
+	}
+	if strings.Join(down, ",") != "test-expert" {
+		t.Errorf("expected only test-expert without a consumer, got %v", down)
+	}
+	if live := liveAgents(agents); len(live) != 1 || live[0] != "golang-expert" {
+		t.Errorf("expected golang-expert to be active, got %v", live)
+	}
+}
@@ -0,0 +1,507 @@
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// The monitor's view of the system is kept as a time series so trends can be
+// charted. Every collection adds one sample per metric and label set to a
+// bucket in each history tier: a hash named
+// handoff:history:<resolution seconds>:<bucket start, unix seconds> with
+// "<metric>\x1f<project>\x1f<agent>\x1f<priority>\x1f<sum|count|min|max>"
+// fields. Coarser tiers downsample the same samples and are kept for longer;
+// each bucket expires once it is older than its tier's retention.
+const historyKeyPrefix = "handoff:history:"
+
+// HistoryMetrics are the metrics recorded in the history, with the meanings
+// and units of the alert condition metrics of the same name
+var HistoryMetrics = []string{
+	"queue_depth",
+	"queue_oldest_age",
+	"queue_sla_breaches",
+	"published",
+	"completed",
+	"failed",
+	"failure_rate",
+	"processing_p95",
+	"active_agents",
+	"system_health",
+}
+
+// ErrInvalidHistoryQuery is returned for queries that can never be answered
+var ErrInvalidHistoryQuery = errors.New("invalid history query")
+
+const (
+	// DefaultHistoryWindow is the range HistoryHandler reports without from or window
+	DefaultHistoryWindow = 6 * time.Hour
+	// maxHistoryPoints bounds the buckets one query reads
+	maxHistoryPoints = 5000
+)
+
+// HistoryTier is one resolution samples are kept at
+type HistoryTier struct {
+	Resolution time.Duration `json:"resolution"`
+	Retention  time.Duration `json:"retention"`
+}
+
+// DefaultHistoryTiers keeps minutes for a day, quarter hours for two weeks and
+// hours for 90 days
+func DefaultHistoryTiers() []HistoryTier {
+	return []HistoryTier{
+		{Resolution: time.Minute, Retention: 24 * time.Hour},
+		{Resolution: 15 * time.Minute, Retention: 14 * 24 * time.Hour},
+		{Resolution: time.Hour, Retention: 90 * 24 * time.Hour},
+	}
+}
+
+// ParseHistoryTiers parses tiers written as "1m:24h,15m:336h", resolution
+// then retention
+func ParseHistoryTiers(s string) ([]HistoryTier, error) {
+	var tiers []HistoryTier
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		resolution, retention, ok := strings.Cut(part, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid history tier %q: expected resolution:retention", part)
+		}
+		tier := HistoryTier{}
+		var err error
+		if tier.Resolution, err = time.ParseDuration(resolution); err != nil {
+			return nil, fmt.Errorf("invalid history tier %q: %w", part, err)
+		}
+		if tier.Retention, err = time.ParseDuration(retention); err != nil {
+			return nil, fmt.Errorf("invalid history tier %q: %w", part, err)
+		}
+		tiers = append(tiers, tier)
+	}
+	if err := ValidateHistoryTiers(tiers); err != nil {
+		return nil, err
+	}
+	return tiers, nil
+}
+
+// ValidateHistoryTiers checks that tiers have whole-second resolutions and
+// keep each bucket for longer than it covers
+func ValidateHistoryTiers(tiers []HistoryTier) error {
+	seen := map[time.Duration]bool{}
+	for _, tier := range tiers {
+		if tier.Resolution < time.Second || tier.Resolution%time.Second != 0 {
+			return fmt.Errorf("history resolution %v must be a whole number of seconds", tier.Resolution)
+		}
+		if tier.Retention < tier.Resolution {
+			return fmt.Errorf("history retention %v is shorter than its resolution %v", tier.Retention, tier.Resolution)
+		}
+		if seen[tier.Resolution] {
+			return fmt.Errorf("history resolution %v is configured twice", tier.Resolution)
+		}
+		seen[tier.Resolution] = true
+	}
+	return nil
+}
+
+// HistorySample is one metric value at one collection
+type HistorySample struct {
+	Metric string
+	Labels MetricLabels
+	Value  float64
+}
+
+// historyScript adds samples to a bucket: ARGV is the expiry in milliseconds
+// followed by field prefix, value pairs
+var historyScript = redis.NewScript(`
+for i = 2, #ARGV, 2 do
+	local field, value = ARGV[i], tonumber(ARGV[i + 1])
+	redis.call('HINCRBYFLOAT', KEYS[1], field .. '\31sum', value)
+	redis.call('HINCRBY', KEYS[1], field .. '\31count', 1)
+	local min = tonumber(redis.call('HGET', KEYS[1], field .. '\31min'))
+	if not min or value < min then
+		redis.call('HSET', KEYS[1], field .. '\31min', ARGV[i + 1])
+	end
+	local max = tonumber(redis.call('HGET', KEYS[1], field .. '\31max'))
+	if not max or value > max then
+		redis.call('HSET', KEYS[1], field .. '\31max', ARGV[i + 1])
+	end
+end
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return #ARGV
+`)
+
+// RecordHistory adds samples taken at the given time to every tier
+func RecordHistory(ctx context.Context, client redis.Cmdable, tiers []HistoryTier, at time.Time, samples []HistorySample) error {
+	if len(samples) == 0 {
+		return nil
+	}
+	for _, tier := range tiers {
+		args := make([]interface{}, 0, 1+2*len(samples))
+		args = append(args, (tier.Retention + tier.Resolution).Milliseconds())
+		for _, s := range samples {
+			args = append(args, metricsField(s.Metric, s.Labels.Project, s.Labels.Agent, string(s.Labels.Priority)), formatFloat(s.Value))
+		}
+		if err := historyScript.Run(ctx, client, []string{historyKey(tier.Resolution, at)}, args...).Err(); err != nil {
+			return fmt.Errorf("failed to record %v history: %w", tier.Resolution, err)
+		}
+	}
+	return nil
+}
+
+func historyKey(resolution time.Duration, at time.Time) string {
+	seconds := int64(resolution / time.Second)
+	bucket := at.Unix() / seconds * seconds
+	return historyKeyPrefix + strconv.FormatInt(seconds, 10) + ":" + strconv.FormatInt(bucket, 10)
+}
+
+// HistoryQuery selects one metric over a time range. Series whose labels do
+// not match Filter are left out; with By, the remaining series are added up
+// per distinct value of the listed labels (project, agent, priority). Step
+// merges buckets into longer points.
+type HistoryQuery struct {
+	Metric string
+	Filter MetricLabels
+	By     []string
+	From   time.Time
+	To     time.Time
+	Step   time.Duration
+}
+
+// HistoryPoint summarises the samples of one series in one step
+type HistoryPoint struct {
+	Time  time.Time `json:"time"` // Start of the step
+	Avg   float64   `json:"avg"`
+	Min   float64   `json:"min"`
+	Max   float64   `json:"max"`
+	Count int64     `json:"count"` // Samples taken
+}
+
+// HistorySeries is the points of one label set, oldest first
+type HistorySeries struct {
+	Labels MetricLabels   `json:"labels"`
+	Points []HistoryPoint `json:"points"`
+}
+
+// HistoryResult is the answer to a HistoryQuery
+type HistoryResult struct {
+	Metric     string          `json:"metric"`
+	From       time.Time       `json:"from"`
+	To         time.Time       `json:"to"`
+	Resolution time.Duration   `json:"resolution"` // Of the tier read
+	Step       time.Duration   `json:"step"`
+	Series     []HistorySeries `json:"series"`
+}
+
+// QueryHistory reads a metric's history from the finest tier that still
+// covers q.From; with a Step, from the coarsest such tier no coarser than it
+func QueryHistory(ctx context.Context, client redis.Cmdable, tiers []HistoryTier, q HistoryQuery) (*HistoryResult, error) {
+	if !isHistoryMetric(q.Metric) {
+		return nil, fmt.Errorf("%w: unknown metric %q", ErrInvalidHistoryQuery, q.Metric)
+	}
+	for _, label := range q.By {
+		if label != "project" && label != "agent" && label != "priority" {
+			return nil, fmt.Errorf("%w: cannot group by %q", ErrInvalidHistoryQuery, label)
+		}
+	}
+	if !q.To.After(q.From) {
+		return nil, fmt.Errorf("%w: range ends before it starts", ErrInvalidHistoryQuery)
+	}
+	tier, ok := chooseHistoryTier(tiers, time.Since(q.From), q.Step)
+	if !ok {
+		return nil, fmt.Errorf("no history tiers are configured")
+	}
+	step := q.Step
+	if step < tier.Resolution {
+		step = tier.Resolution
+	}
+	step = step.Truncate(tier.Resolution)
+
+	seconds := int64(tier.Resolution / time.Second)
+	first, last := q.From.Unix()/seconds*seconds, q.To.Unix()/seconds*seconds
+	if (last-first)/seconds+1 > maxHistoryPoints {
+		return nil, fmt.Errorf("%w: range spans more than %d %v buckets", ErrInvalidHistoryQuery, maxHistoryPoints, tier.Resolution)
+	}
+
+	pipe := client.Pipeline()
+	var buckets []int64
+	var cmds []*redis.StringStringMapCmd
+	for bucket := first; bucket <= last; bucket += seconds {
+		buckets = append(buckets, bucket)
+		cmds = append(cmds, pipe.HGetAll(ctx, historyKeyPrefix+strconv.FormatInt(seconds, 10)+":"+strconv.FormatInt(bucket, 10)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to read history: %w", err)
+	}
+
+	// Each bucket is summarised per series, series are added up per group
+	// and the bucket points of a step are then merged
+	type key struct {
+		labels MetricLabels
+		step   int64
+	}
+	steps := map[key]*HistoryPoint{}
+	stepSeconds := int64(step / time.Second)
+	for i, cmd := range cmds {
+		grouped := map[MetricLabels]*HistoryPoint{}
+		for labels, agg := range parseHistoryBucket(cmd.Val(), q.Metric) {
+			if agg.count == 0 || !labels.Matches(q.Filter) {
+				continue
+			}
+			labels = groupLabels(labels, q.By)
+			point := agg.point()
+			if grouped[labels] == nil {
+				grouped[labels] = &point
+			} else {
+				grouped[labels].addSeries(point)
+			}
+		}
+		stepStart := buckets[i] / stepSeconds * stepSeconds
+		for labels, point := range grouped {
+			k := key{labels, stepStart}
+			if steps[k] == nil {
+				point.Time = time.Unix(stepStart, 0).UTC()
+				steps[k] = point
+			} else {
+				steps[k].merge(*point)
+			}
+		}
+	}
+
+	bySeries := map[MetricLabels][]HistoryPoint{}
+	for k, point := range steps {
+		bySeries[k.labels] = append(bySeries[k.labels], *point)
+	}
+	result := &HistoryResult{Metric: q.Metric, From: q.From, To: q.To, Resolution: tier.Resolution, Step: step, Series: []HistorySeries{}}
+	for labels, points := range bySeries {
+		sort.Slice(points, func(i, j int) bool { return points[i].Time.Before(points[j].Time) })
+		result.Series = append(result.Series, HistorySeries{Labels: labels, Points: points})
+	}
+	sort.Slice(result.Series, func(i, j int) bool {
+		return result.Series[i].Labels.less(result.Series[j].Labels)
+	})
+	return result, nil
+}
+
+// chooseHistoryTier picks the tier to read a range reaching age back
+func chooseHistoryTier(tiers []HistoryTier, age, step time.Duration) (HistoryTier, bool) {
+	if len(tiers) == 0 {
+		return HistoryTier{}, false
+	}
+	sorted := append([]HistoryTier(nil), tiers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Resolution < sorted[j].Resolution })
+
+	var covering []HistoryTier
+	for _, tier := range sorted {
+		if tier.Retention >= age {
+			covering = append(covering, tier)
+		}
+	}
+	if len(covering) == 0 {
+		// Nothing reaches that far back; the longest kept tier has the most
+		return sorted[len(sorted)-1], true
+	}
+	chosen := covering[0]
+	for _, tier := range covering[1:] {
+		if step > 0 && tier.Resolution <= step {
+			chosen = tier
+		}
+	}
+	return chosen, true
+}
+
+func isHistoryMetric(metric string) bool {
+	for _, m := range HistoryMetrics {
+		if m == metric {
+			return true
+		}
+	}
+	return false
+}
+
+// groupLabels keeps the labels listed in by; nil keeps them all
+func groupLabels(labels MetricLabels, by []string) MetricLabels {
+	if by == nil {
+		return labels
+	}
+	var grouped MetricLabels
+	for _, label := range by {
+		switch label {
+		case "project":
+			grouped.Project = labels.Project
+		case "agent":
+			grouped.Agent = labels.Agent
+		case "priority":
+			grouped.Priority = labels.Priority
+		}
+	}
+	return grouped
+}
+
+// historyAggregate is the samples of one series in one bucket
+type historyAggregate struct {
+	sum, min, max float64
+	count         int64
+}
+
+func (a historyAggregate) point() HistoryPoint {
+	return HistoryPoint{Avg: a.sum / float64(a.count), Min: a.min, Max: a.max, Count: a.count}
+}
+
+func parseHistoryBucket(hash map[string]string, metric string) map[MetricLabels]historyAggregate {
+	found := map[MetricLabels]historyAggregate{}
+	for field, value := range hash {
+		parts := strings.Split(field, "\x1f")
+		if len(parts) != 5 || parts[0] != metric {
+			continue
+		}
+		labels := MetricLabels{Project: parts[1], Agent: parts[2], Priority: Priority(parts[3])}
+		agg := found[labels]
+		switch parts[4] {
+		case "sum":
+			agg.sum, _ = strconv.ParseFloat(value, 64)
+		case "count":
+			agg.count, _ = strconv.ParseInt(value, 10, 64)
+		case "min":
+			agg.min, _ = strconv.ParseFloat(value, 64)
+		case "max":
+			agg.max, _ = strconv.ParseFloat(value, 64)
+		}
+		found[labels] = agg
+	}
+	return found
+}
+
+// addSeries adds another series' point of the same bucket, so a group's
+// average, minimum and maximum are the sums of its series'
+func (p *HistoryPoint) addSeries(other HistoryPoint) {
+	p.Avg += other.Avg
+	p.Min += other.Min
+	p.Max += other.Max
+	p.Count += other.Count
+}
+
+// merge folds a later bucket of the same series into a step
+func (p *HistoryPoint) merge(other HistoryPoint) {
+	total := p.Count + other.Count
+	p.Avg = (p.Avg*float64(p.Count) + other.Avg*float64(other.Count)) / float64(total)
+	if other.Min < p.Min {
+		p.Min = other.Min
+	}
+	if other.Max > p.Max {
+		p.Max = other.Max
+	}
+	p.Count = total
+}
+
+// historySamples are the values of the history metrics at one collection
+func historySamples(src *alertSource) []HistorySample {
+	var samples []HistorySample
+	for _, name := range HistoryMetrics {
+		metric := alertMetrics[name]
+		switch {
+		case metric.scalar != nil:
+			samples = append(samples, HistorySample{Metric: name, Value: metric.scalar(src)})
+		case metric.queue != nil:
+			for _, q := range src.queues {
+				samples = append(samples, HistorySample{Metric: name, Labels: MetricLabels{Project: q.Project, Agent: q.Agent}, Value: metric.queue([]QueueMetrics{q})})
+			}
+		case metric.sla != nil:
+			byLabels := map[MetricLabels][]WaitingBreach{}
+			for _, b := range src.breaches {
+				byLabels[b.Labels] = append(byLabels[b.Labels], b)
+			}
+			for labels, breaches := range byLabels {
+				samples = append(samples, HistorySample{Metric: name, Labels: labels, Value: metric.sla(breaches)})
+			}
+		case metric.window != nil && src.rolling != nil:
+			for _, s := range src.rolling.Series {
+				samples = append(samples, HistorySample{Metric: name, Labels: s.Labels, Value: metric.window(s)})
+			}
+		}
+	}
+	return samples
+}
+
+// HistoryHandler serves a metric's history as JSON. Parameters:
+//
+//	metric    one of HistoryMetrics (required)
+//	from, to  RFC 3339 times; to defaults to now
+//	window    duration before to, instead of from (default 6h)
+//	step      duration each point covers (default the tier's resolution)
+//	project, agent, priority  filter the series
+//	by        comma-separated labels to add the series up by
+func HistoryHandler(query func(ctx context.Context, q HistoryQuery) (*HistoryResult, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		q := HistoryQuery{
+			Metric: params.Get("metric"),
+			Filter: MetricLabels{
+				Project:  params.Get("project"),
+				Agent:    params.Get("agent"),
+				Priority: Priority(params.Get("priority")),
+			},
+			To: time.Now(),
+		}
+		if q.Metric == "" {
+			http.Error(w, fmt.Sprintf("metric is required (one of %s)", strings.Join(HistoryMetrics, ", ")), http.StatusBadRequest)
+			return
+		}
+		if !isHistoryMetric(q.Metric) {
+			http.Error(w, fmt.Sprintf("unknown metric %q (expected one of %s)", q.Metric, strings.Join(HistoryMetrics, ", ")), http.StatusBadRequest)
+			return
+		}
+		if by := params.Get("by"); by != "" {
+			q.By = strings.Split(by, ",")
+		}
+
+		var err error
+		parseTime := func(name string, into *time.Time) bool {
+			if value := params.Get(name); value != "" {
+				if *into, err = time.Parse(time.RFC3339, value); err != nil {
+					http.Error(w, fmt.Sprintf("invalid %s %q", name, value), http.StatusBadRequest)
+					return false
+				}
+			}
+			return true
+		}
+		parseDuration := func(name string, into *time.Duration) bool {
+			if value := params.Get(name); value != "" {
+				parsed, err := time.ParseDuration(value)
+				if err != nil || parsed <= 0 {
+					http.Error(w, fmt.Sprintf("invalid %s %q", name, value), http.StatusBadRequest)
+					return false
+				}
+				*into = parsed
+			}
+			return true
+		}
+		window := DefaultHistoryWindow
+		if !parseTime("to", &q.To) || !parseTime("from", &q.From) || !parseDuration("window", &window) || !parseDuration("step", &q.Step) {
+			return
+		}
+		if q.From.IsZero() {
+			q.From = q.To.Add(-window)
+		}
+
+		result, err := query(r.Context(), q)
+		if err != nil {
+			status := http.StatusServiceUnavailable
+			if errors.Is(err, ErrInvalidHistoryQuery) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(result)
+	})
+}
@@ -0,0 +1,206 @@
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseHistoryTiers(t *testing.T) {
+	tiers, err := ParseHistoryTiers(" 1m:24h, 15m:336h,,1h:2160h ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := DefaultHistoryTiers()
+	if len(tiers) != len(want) {
+		t.Fatalf("expected %v, got %v", want, tiers)
+	}
+	for i := range want {
+		if tiers[i] != want[i] {
+			t.Errorf("tier %d: expected %v, got %v", i, want[i], tiers[i])
+		}
+	}
+
+	for bad, wantErr := range map[string]string{
+		"1m":          "expected resolution:retention",
+		"soon:24h":    "invalid history tier",
+		"1m:forever":  "invalid history tier",
+		"500ms:1h":    "whole number of seconds",
+		"1h:1m":       "shorter than its resolution",
+		"1m:1h,1m:2h": "configured twice",
+	} {
+		if _, err := ParseHistoryTiers(bad); err == nil || !strings.Contains(err.Error(), wantErr) {
+			t.Errorf("%q: expected an error containing %q, got %v", bad, wantErr, err)
+		}
+	}
+}
+
+func TestChooseHistoryTier(t *testing.T) {
+	tiers := DefaultHistoryTiers()
+	tests := []struct {
+		age, step time.Duration
+		want      time.Duration
+	}{
+		{age: 6 * time.Hour, want: time.Minute},
+		{age: 6 * time.Hour, step: 5 * time.Minute, want: time.Minute},
+		{age: 6 * time.Hour, step: 30 * time.Minute, want: 15 * time.Minute},
+		{age: 6 * time.Hour, step: 24 * time.Hour, want: time.Hour},
+		{age: 3 * 24 * time.Hour, want: 15 * time.Minute},
+		{age: 30 * 24 * time.Hour, want: time.Hour},
+		{age: 365 * 24 * time.Hour, want: time.Hour},
+	}
+	for _, tt := range tests {
+		tier, ok := chooseHistoryTier(tiers, tt.age, tt.step)
+		if !ok || tier.Resolution != tt.want {
+			t.Errorf("age %v step %v: expected the %v tier, got %v", tt.age, tt.step, tt.want, tier.Resolution)
+		}
+	}
+	if _, ok := chooseHistoryTier(nil, time.Hour, 0); ok {
+		t.Error("expected no tier without configured tiers")
+	}
+}
+
+func TestHistoryKey(t *testing.T) {
+	at := time.Unix(1714557723, 0)
+	if got := historyKey(time.Minute, at); got != "handoff:history:60:1714557720" {
+		t.Errorf("unexpected minute key %q", got)
+	}
+	if got := historyKey(time.Hour, at); got != "handoff:history:3600:1714557600" {
+		t.Errorf("unexpected hour key %q", got)
+	}
+}
+
+func TestParseHistoryBucket(t *testing.T) {
+	hash := map[string]string{
+		metricsField("queue_depth", "billing", "golang-expert", "", "sum"):      "30",
+		metricsField("queue_depth", "billing", "golang-expert", "", "count"):    "3",
+		metricsField("queue_depth", "billing", "golang-expert", "", "min"):      "5",
+		metricsField("queue_depth", "billing", "golang-expert", "", "max"):      "15",
+		metricsField("queue_oldest_age", "billing", "golang-expert", "", "sum"): "60",
+		"malformed": "1",
+	}
+	found := parseHistoryBucket(hash, "queue_depth")
+	if len(found) != 1 {
+		t.Fatalf("expected one series, got %v", found)
+	}
+	point := found[MetricLabels{Project: "billing", Agent: "golang-expert"}].point()
+	if point.Avg != 10 || point.Min != 5 || point.Max != 15 || point.Count != 3 {
+		t.Errorf("unexpected point %+v", point)
+	}
+}
+
+func TestHistoryPointMerging(t *testing.T) {
+	step := HistoryPoint{Avg: 10, Min: 5, Max: 15, Count: 3}
+	step.merge(HistoryPoint{Avg: 2, Min: 1, Max: 4, Count: 1})
+	if step.Avg != 8 || step.Min != 1 || step.Max != 15 || step.Count != 4 {
+		t.Errorf("unexpected step %+v", step)
+	}
+
+	group := HistoryPoint{Avg: 10, Min: 5, Max: 15, Count: 3}
+	group.addSeries(HistoryPoint{Avg: 2, Min: 1, Max: 4, Count: 1})
+	if group.Avg != 12 || group.Min != 6 || group.Max != 19 || group.Count != 4 {
+		t.Errorf("unexpected group %+v", group)
+	}
+
+	labels := MetricLabels{Project: "billing", Agent: "golang-expert", Priority: PriorityHigh}
+	if got := groupLabels(labels, []string{"agent"}); got != (MetricLabels{Agent: "golang-expert"}) {
+		t.Errorf("unexpected grouped labels %+v", got)
+	}
+	if got := groupLabels(labels, nil); got != labels {
+		t.Errorf("expected labels to be kept without by, got %+v", got)
+	}
+	if got := groupLabels(labels, []string{}); got != (MetricLabels{}) {
+		t.Errorf("expected an empty by to add up every series, got %+v", got)
+	}
+}
+
+func TestHistorySamples(t *testing.T) {
+	src := &alertSource{
+		metrics: &HandoffMetrics{ActiveAgents: []string{"a", "b"}},
+		queues: []QueueMetrics{
+			{Project: "billing", Agent: "golang-expert", Depth: 4, OldestAge: 90 * time.Second},
+		},
+		rolling: &RollingMetrics{Series: []RollingSeries{{
+			Labels: MetricLabels{Agent: "golang-expert", Priority: PriorityHigh},
+			Counts: map[HandoffEvent]int64{EventPublished: 6, EventCompleted: 3, EventFailed: 3},
+		}}},
+		breaches: []WaitingBreach{
+			{Labels: MetricLabels{Agent: "golang-expert", Priority: PriorityHigh}},
+			{Labels: MetricLabels{Agent: "golang-expert", Priority: PriorityHigh}},
+		},
+		systemHealth: 90,
+	}
+	values := map[string]float64{}
+	for _, s := range historySamples(src) {
+		values[s.Metric+s.Labels.Agent+string(s.Labels.Priority)] = s.Value
+	}
+	for key, want := range map[string]float64{
+		"queue_depthgolang-expert":            4,
+		"queue_oldest_agegolang-expert":       90,
+		"queue_sla_breachesgolang-experthigh": 2,
+		"publishedgolang-experthigh":          6,
+		"failure_rategolang-experthigh":       50,
+		"active_agents":                       2,
+		"system_health":                       90,
+	} {
+		if got, ok := values[key]; !ok || got != want {
+			t.Errorf("%s: expected %v, got %v (found %v)", key, want, got, ok)
+		}
+	}
+}
+
+func TestHistoryHandler(t *testing.T) {
+	var got HistoryQuery
+	handler := HistoryHandler(func(ctx context.Context, q HistoryQuery) (*HistoryResult, error) {
+		got = q
+		if q.Metric == "failed" {
+			return nil, fmt.Errorf("failed to read history: connection refused")
+		}
+		if q.Step > time.Hour {
+			return nil, fmt.Errorf("%w: too coarse", ErrInvalidHistoryQuery)
+		}
+		return &HistoryResult{Metric: q.Metric, Series: []HistorySeries{}}, nil
+	})
+	serve := func(query string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics/history?"+query, nil))
+		return rec
+	}
+
+	rec := serve("metric=queue_depth&from=2024-05-01T10:00:00Z&to=2024-05-01T16:00:00Z&step=15m&agent=golang-expert&by=agent,priority")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/json" {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body)
+	}
+	var result HistoryResult
+	if err := json.Unmarshal(rec.Body.Bytes(), &result); err != nil || result.Metric != "queue_depth" {
+		t.Errorf("unexpected body %s (%v)", rec.Body, err)
+	}
+	if !got.From.Equal(time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)) || got.To.Sub(got.From) != 6*time.Hour ||
+		got.Step != 15*time.Minute || got.Filter.Agent != "golang-expert" || strings.Join(got.By, ",") != "agent,priority" {
+		t.Errorf("unexpected query %+v", got)
+	}
+
+	serve("metric=active_agents&window=2h")
+	if d := got.To.Sub(got.From); d != 2*time.Hour {
+		t.Errorf("expected a 2h window, got %v", d)
+	}
+
+	for query, want := range map[string]int{
+		"":                              http.StatusBadRequest,
+		"metric=nope":                   http.StatusBadRequest,
+		"metric=queue_depth&window=-1h": http.StatusBadRequest,
+		"metric=queue_depth&from=today": http.StatusBadRequest,
+		"metric=queue_depth&step=soon":  http.StatusBadRequest,
+		"metric=queue_depth&step=2h":    http.StatusBadRequest,
+		"metric=failed":                 http.StatusServiceUnavailable,
+	} {
+		if rec := serve(query); rec.Code != want {
+			t.Errorf("%q: expected %d, got %d: %s", query, want, rec.Code, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package handoff
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Quarantine keys shared by every consumer. QuarantineKey is a sorted set of
+// handoff IDs scored by quarantine time; QuarantineReasonsKey is a hash of
+// handoff ID to reason.
+const (
+	QuarantineKey        = "handoff:quarantine"
+	QuarantineReasonsKey = "handoff:quarantine:reasons"
+	QuarantineRetention  = 7 * 24 * time.Hour
+)
+
+var (
+	// ErrChecksumMissing is returned when a consumed handoff carries no checksum
+	ErrChecksumMissing = errors.New("handoff has no checksum")
+	// ErrChecksumMismatch is returned when a consumed handoff does not match its checksum
+	ErrChecksumMismatch = errors.New("handoff checksum mismatch")
+	// ErrChecksumLegacy is returned when a consumed handoff carries a checksum
+	// in the format used before canonical checksums, which covers only agents,
+	// summary, requirements and next steps
+	ErrChecksumLegacy = errors.New("handoff has a legacy checksum")
+)
+
+// ChecksumPolicy decides whether consumers accept handoffs published before
+// canonical checksums
+type ChecksumPolicy string
+
+const (
+	// ChecksumPolicyMigrate accepts handoffs with no checksum or a matching
+	// legacy checksum, with a warning, so queues drain across an upgrade
+	ChecksumPolicyMigrate ChecksumPolicy = "migrate"
+	// ChecksumPolicyStrict quarantines every handoff without a canonical checksum
+	ChecksumPolicyStrict ChecksumPolicy = "strict"
+)
+
+// ParseChecksumPolicy parses a policy name; an empty name means ChecksumPolicyMigrate
+func ParseChecksumPolicy(name string) (ChecksumPolicy, error) {
+	switch policy := ChecksumPolicy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case "":
+		return ChecksumPolicyMigrate, nil
+	case ChecksumPolicyMigrate, ChecksumPolicyStrict:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown checksum policy %q (expected migrate or strict)", name)
+	}
+}
+
+// Tolerates reports whether a checksum error from VerifyChecksum or
+// VerifyPayloadChecksum only marks a pre-upgrade handoff the policy lets through
+func (p ChecksumPolicy) Tolerates(err error) bool {
+	return p != ChecksumPolicyStrict && (errors.Is(err, ErrChecksumMissing) || errors.Is(err, ErrChecksumLegacy))
+}
+
+// ComputeChecksum returns the SHA-256 of the canonical form of a handoff's
+// metadata and content. The canonical form is their JSON encoding with object
+// keys sorted, numbers normalized and null or empty values dropped, so the same
+// handoff checksums identically whichever struct (or raw JSON) it is held in.
+func ComputeChecksum(metadata, content interface{}) (string, error) {
+	canonical, err := canonicalBytes(metadata, content)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", sha256.Sum256(canonical)), nil
+}
+
+// VerifyPayloadChecksum verifies a handoff stored as raw JSON, either a bare
+// handoff or a HandoffQueueMessage, without decoding it into a specific struct
+func VerifyPayloadChecksum(payload []byte) error {
+	var stored struct {
+		Metadata   json.RawMessage `json:"metadata"`
+		Content    json.RawMessage `json:"content"`
+		Validation Validation      `json:"validation"`
+		Payload    json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(payload, &stored); err != nil {
+		return fmt.Errorf("%w: payload is not valid JSON: %v", ErrChecksumMismatch, err)
+	}
+	if len(stored.Payload) > 0 && len(stored.Metadata) == 0 {
+		return VerifyPayloadChecksum(stored.Payload)
+	}
+
+	if stored.Validation.Checksum == "" {
+		return ErrChecksumMissing
+	}
+
+	computed, err := ComputeChecksum(nullIfEmpty(stored.Metadata), nullIfEmpty(stored.Content))
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrChecksumMismatch, err)
+	}
+	if stored.Validation.Checksum != computed {
+		var legacy struct {
+			Metadata legacyChecksumMetadata `json:"metadata"`
+			Content  legacyChecksumContent  `json:"content"`
+		}
+		if json.Unmarshal(payload, &legacy) == nil && stored.Validation.Checksum == legacyChecksum(legacy.Metadata, legacy.Content) {
+			return ErrChecksumLegacy
+		}
+	}
+	return compareChecksums(stored.Validation.Checksum, computed)
+}
+
+// VerifyChecksum checks the handoff against its stored checksum
+func (h *Handoff) VerifyChecksum() error {
+	if h.Validation.Checksum == "" {
+		return ErrChecksumMissing
+	}
+
+	computed, err := ComputeChecksum(h.Metadata, h.Content)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrChecksumMismatch, err)
+	}
+	if h.Validation.Checksum != computed && h.Validation.Checksum == legacyChecksum(
+		legacyChecksumMetadata{FromAgent: h.Metadata.FromAgent, ToAgent: h.Metadata.ToAgent},
+		legacyChecksumContent{Summary: h.Content.Summary, Requirements: h.Content.Requirements, NextSteps: h.Content.NextSteps},
+	) {
+		return ErrChecksumLegacy
+	}
+	return compareChecksums(h.Validation.Checksum, computed)
+}
+
+// legacyChecksumMetadata and legacyChecksumContent are the fields covered by
+// legacy checksums
+type legacyChecksumMetadata struct {
+	FromAgent string `json:"from_agent"`
+	ToAgent   string `json:"to_agent"`
+}
+
+type legacyChecksumContent struct {
+	Summary      string   `json:"summary"`
+	Requirements []string `json:"requirements"`
+	NextSteps    []string `json:"next_steps"`
+}
+
+// legacyChecksum computes a checksum in the format used before canonical checksums
+func legacyChecksum(metadata legacyChecksumMetadata, content legacyChecksumContent) string {
+	data := fmt.Sprintf("%s:%s:%s:%v:%v", metadata.FromAgent, metadata.ToAgent, content.Summary, content.Requirements, content.NextSteps)
+	return fmt.Sprintf("%x", sha256.Sum256([]byte(data)))
+}
+
+// QuarantineHandoff records a handoff that failed an integrity check and keeps
+// its stored payload for QuarantineRetention. It does not modify the payload;
+// callers that track status should store it before calling, since storing
+// resets the expiry.
+func QuarantineHandoff(ctx context.Context, client redis.Cmdable, handoffID, reason string) error {
+	_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, QuarantineKey, &redis.Z{
+			Score:  float64(time.Now().Unix()),
+			Member: handoffID,
+		})
+		pipe.HSet(ctx, QuarantineReasonsKey, handoffID, reason)
+		pipe.Expire(ctx, fmt.Sprintf("handoff:%s", handoffID), QuarantineRetention)
+		pipe.Incr(ctx, "handoff:metrics:quarantined")
+		pipe.Expire(ctx, "handoff:metrics:quarantined", 24*time.Hour)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to quarantine handoff %s: %w", handoffID, err)
+	}
+	return nil
+}
+
+func compareChecksums(stored, computed string) error {
+	if stored != computed {
+		return fmt.Errorf("%w: stored %s, computed %s", ErrChecksumMismatch, stored, computed)
+	}
+	return nil
+}
+
+// canonicalBytes returns the canonical encoding of a handoff's metadata and
+// content; it is what checksums and signatures are computed over
+func canonicalBytes(metadata, content interface{}) ([]byte, error) {
+	canonical, err := canonicalize(map[string]interface{}{"metadata": metadata, "content": content})
+	if err != nil {
+		return nil, err
+	}
+	return encodeCanonical(canonical)
+}
+
+// encodeCanonical encodes a canonicalized value without HTML escaping or a
+// trailing newline
+func encodeCanonical(canonical interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(canonical); err != nil {
+		return nil, fmt.Errorf("failed to encode canonical handoff: %w", err)
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// canonicalize converts a value to its canonical generic JSON form
+func canonicalize(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode handoff: %w", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, fmt.Errorf("failed to decode handoff: %w", err)
+	}
+
+	normalized, _ := normalizeCanonical(generic)
+	return normalized, nil
+}
+
+// normalizeCanonical drops null and empty values and rewrites numbers in one
+// form; the second result reports whether the value should be kept
+func normalizeCanonical(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case nil:
+		return nil, false
+	case string:
+		return v, v != ""
+	case json.Number:
+		if i, err := strconv.ParseInt(v.String(), 10, 64); err == nil {
+			return json.Number(strconv.FormatInt(i, 10)), true
+		}
+		if f, err := strconv.ParseFloat(v.String(), 64); err == nil {
+			return json.Number(strconv.FormatFloat(f, 'g', -1, 64)), true
+		}
+		return v, true
+	case []interface{}:
+		items := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			// Keep positions stable: an empty element still occupies its index
+			normalized, _ := normalizeCanonical(item)
+			items = append(items, normalized)
+		}
+		return items, len(items) > 0
+	case map[string]interface{}:
+		fields := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if normalized, keep := normalizeCanonical(item); keep {
+				fields[key] = normalized
+			}
+		}
+		return fields, len(fields) > 0
+	default:
+		return v, true
+	}
+}
+
+func nullIfEmpty(raw json.RawMessage) json.RawMessage {
+	if len(raw) == 0 {
+		return json.RawMessage("null")
+	}
+	return raw
+}
@@ -0,0 +1,156 @@
+package handoff
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newIntegrityTestHandoff() *Handoff {
+	h := &Handoff{
+		Metadata: Metadata{
+			ProjectName: "billing",
+			FromAgent:   "api-expert",
+			ToAgent:     "golang-expert",
+			Timestamp:   time.Date(2026, 10, 18, 9, 30, 0, 0, time.FixedZone("CEST", 2*60*60)),
+			TaskContext: "invoices",
+			Priority:    PriorityHigh,
+			HandoffID:   "handoff-1",
+		},
+		Content: Content{
+			Summary:      "Implement invoice endpoints",
+			Requirements: []string{"REST API"},
+			Artifacts:    Artifacts{Created: []string{"api/openapi.yaml"}},
+			TechnicalDetails: map[string]interface{}{
+				"coverage": 80,
+				"spec":     struct{ Version, Format string }{"3.1", "yaml"},
+			},
+		},
+	}
+	if err := h.Validate(); err != nil {
+		panic(err)
+	}
+	return h
+}
+
+func TestChecksumCoversMetadataAndContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(h *Handoff)
+	}{
+		{"project", func(h *Handoff) { h.Metadata.ProjectName = "payments" }},
+		{"priority", func(h *Handoff) { h.Metadata.Priority = PriorityLow }},
+		{"timestamp", func(h *Handoff) { h.Metadata.Timestamp = h.Metadata.Timestamp.Add(time.Second) }},
+		{"artifacts", func(h *Handoff) { h.Content.Artifacts.Modified = []string{"main.go"} }},
+		{"technical details", func(h *Handoff) { h.Content.TechnicalDetails["coverage"] = 81 }},
+		{"requirement order", func(h *Handoff) { h.Content.Requirements = append([]string{"auth"}, h.Content.Requirements...) }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newIntegrityTestHandoff()
+			tt.modify(h)
+			if err := h.VerifyChecksum(); !errors.Is(err, ErrChecksumMismatch) {
+				t.Errorf("expected checksum mismatch, got %v", err)
+			}
+		})
+	}
+}
+
+func TestChecksumSurvivesStorage(t *testing.T) {
+	h := newIntegrityTestHandoff()
+
+	data, err := json.Marshal(HandoffQueueMessage{HandoffID: h.Metadata.HandoffID, Payload: *h})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var message HandoffQueueMessage
+	if err := json.Unmarshal(data, &message); err != nil {
+		t.Fatal(err)
+	}
+	if err := message.Payload.VerifyChecksum(); err != nil {
+		t.Errorf("decoded handoff failed verification: %v", err)
+	}
+	if err := VerifyPayloadChecksum(data); err != nil {
+		t.Errorf("raw queue message failed verification: %v", err)
+	}
+
+	// Fields another producer writes differently must not change the checksum
+	raw := `{
+		"metadata": {"project_name": "billing", "from_agent": "api-expert", "to_agent": "golang-expert",
+			"timestamp": "2026-10-18T09:30:00+02:00", "task_context": "invoices", "priority": "high",
+			"handoff_id": "handoff-1", "parent_id": null},
+		"content": {"summary": "Implement invoice endpoints", "requirements": ["REST API"],
+			"artifacts": {"created": ["api/openapi.yaml"], "modified": []},
+			"technical_details": {"spec": {"Format": "yaml", "Version": "3.1"}, "coverage": 80.0},
+			"next_steps": null},
+		"validation": {"schema_version": "1.0", "checksum": "` + h.Validation.Checksum + `"}
+	}`
+	if err := VerifyPayloadChecksum([]byte(raw)); err != nil {
+		t.Errorf("equivalent JSON failed verification: %v", err)
+	}
+}
+
+func TestVerifyPayloadChecksumDetectsTampering(t *testing.T) {
+	h := newIntegrityTestHandoff()
+	data, _ := json.Marshal(h)
+
+	var tampered map[string]interface{}
+	json.Unmarshal(data, &tampered)
+	tampered["content"].(map[string]interface{})["summary"] = "Delete the invoices table"
+	tamperedData, _ := json.Marshal(tampered)
+
+	if err := VerifyPayloadChecksum(tamperedData); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected checksum mismatch, got %v", err)
+	}
+	if err := VerifyPayloadChecksum([]byte(`{"metadata": {"handoff_id": "x"}`)); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected corrupt JSON to fail verification, got %v", err)
+	}
+
+	h.Validation.Checksum = ""
+	data, _ = json.Marshal(h)
+	if err := VerifyPayloadChecksum(data); !errors.Is(err, ErrChecksumMissing) {
+		t.Errorf("expected missing checksum, got %v", err)
+	}
+}
+
+func TestChecksumPolicyPreUpgradePayloads(t *testing.T) {
+	// A handoff as published before canonical checksums, queued in a HandoffQueueMessage
+	h := newIntegrityTestHandoff()
+	h.Validation.Checksum = legacyChecksum(
+		legacyChecksumMetadata{FromAgent: h.Metadata.FromAgent, ToAgent: h.Metadata.ToAgent},
+		legacyChecksumContent{Summary: h.Content.Summary, Requirements: h.Content.Requirements, NextSteps: h.Content.NextSteps},
+	)
+	data, _ := json.Marshal(HandoffQueueMessage{HandoffID: h.Metadata.HandoffID, Payload: *h})
+
+	if err := h.VerifyChecksum(); !errors.Is(err, ErrChecksumLegacy) {
+		t.Errorf("expected a legacy checksum, got %v", err)
+	}
+	err := VerifyPayloadChecksum(data)
+	if !errors.Is(err, ErrChecksumLegacy) {
+		t.Fatalf("expected a legacy checksum, got %v", err)
+	}
+
+	migrate, err2 := ParseChecksumPolicy("")
+	if err2 != nil || migrate != ChecksumPolicyMigrate {
+		t.Fatalf("expected migrate by default, got %q, %v", migrate, err2)
+	}
+	if !migrate.Tolerates(err) || !migrate.Tolerates(ErrChecksumMissing) {
+		t.Error("expected migrate to let pre-upgrade handoffs through")
+	}
+	if ChecksumPolicyStrict.Tolerates(err) || ChecksumPolicyStrict.Tolerates(ErrChecksumMissing) {
+		t.Error("expected strict to refuse pre-upgrade handoffs")
+	}
+	if _, err := ParseChecksumPolicy("lenient"); err == nil {
+		t.Error("expected an unknown policy to be refused")
+	}
+
+	// Changing a field the legacy checksum covers is still a mismatch
+	h.Content.Summary = "Delete the invoices table"
+	err = h.VerifyChecksum()
+	if !errors.Is(err, ErrChecksumMismatch) || migrate.Tolerates(err) {
+		t.Errorf("expected a tampered legacy handoff to mismatch, got %v", err)
+	}
+}
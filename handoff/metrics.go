@@ -0,0 +1,652 @@
+package handoff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Redis hashes holding the labelled counters and processing time histograms
+// exported by WritePrometheus. They never expire, since Prometheus counters
+// must only go up; windowed counts come from the per-minute buckets instead.
+const (
+	MetricsCountersKey   = "handoff:metrics:counters"   // "<event>\x1f<project>\x1f<agent>\x1f<priority>" -> count
+	MetricsProcessingKey = "handoff:metrics:processing" // "<project>\x1f<agent>\x1f<priority>\x1f<le|count|sum>" -> value
+)
+
+// MetricsContentType is the media type of the Prometheus text exposition format
+const MetricsContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// maxAgeSample bounds how many queued handoffs are read to find a queue's
+// oldest item and split a shared queue by project
+const maxAgeSample = 1000
+
+// HandoffEvent is a counted step in a handoff's lifecycle
+type HandoffEvent string
+
+const (
+	EventPublished   HandoffEvent = "published"
+	EventCompleted   HandoffEvent = "completed"
+	EventFailed      HandoffEvent = "failed" // Failed processing attempts, retried or not
+	EventRetried     HandoffEvent = "retried"
+	EventQuarantined HandoffEvent = "quarantined"
+	EventSLABreached HandoffEvent = "sla_breached" // Handoffs that waited longer than their priority's SLA
+)
+
+// HandoffEvents lists the counted events in exposition order
+var HandoffEvents = []HandoffEvent{EventPublished, EventCompleted, EventFailed, EventRetried, EventQuarantined, EventSLABreached}
+
+// ProcessingTimeBuckets are the histogram upper bounds, in seconds, for
+// handoff processing times
+var ProcessingTimeBuckets = []float64{0.1, 0.5, 1, 2.5, 5, 10, 30, 60, 300, 900, 1800, 3600}
+
+// QueueMetrics describes the handoffs queued for one agent in one project.
+// Library queues (handoff:queue:<agent>) are shared by projects and are split
+// by the project of their handoffs.
+type QueueMetrics struct {
+	Queue     string        `json:"queue"`
+	Project   string        `json:"project"`
+	Agent     string        `json:"agent"`
+	Depth     int64         `json:"depth"`
+	OldestAge time.Duration `json:"oldest_age"`
+}
+
+// MetricLabels identify a metric series: the receiving agent, its project
+// and the handoff's priority
+type MetricLabels struct {
+	Project  string   `json:"project"`
+	Agent    string   `json:"agent"`
+	Priority Priority `json:"priority"`
+}
+
+// LabelsOf returns the metric labels of a handoff
+func LabelsOf(h *Handoff) MetricLabels {
+	return MetricLabels{Project: h.Metadata.ProjectName, Agent: h.Metadata.ToAgent, Priority: h.Metadata.Priority}
+}
+
+// Matches reports whether l has every non-empty label of filter
+func (l MetricLabels) Matches(filter MetricLabels) bool {
+	return (filter.Project == "" || filter.Project == l.Project) &&
+		(filter.Agent == "" || filter.Agent == l.Agent) &&
+		(filter.Priority == "" || filter.Priority == l.Priority)
+}
+
+func (l MetricLabels) less(other MetricLabels) bool {
+	if l.Project != other.Project {
+		return l.Project < other.Project
+	}
+	if l.Agent != other.Agent {
+		return l.Agent < other.Agent
+	}
+	return l.Priority < other.Priority
+}
+
+// EventCounts are the lifecycle counters of one label set
+type EventCounts struct {
+	MetricLabels
+	Counts map[HandoffEvent]int64 `json:"counts"`
+}
+
+// ProcessingHistogram is the processing time distribution of one label set.
+// Buckets are cumulative counts for each ProcessingTimeBuckets bound.
+type ProcessingHistogram struct {
+	MetricLabels
+	Buckets []uint64 `json:"buckets"`
+	Count   uint64   `json:"count"`
+	Sum     float64  `json:"sum"` // Seconds
+}
+
+// MetricsSnapshot is everything exported on /metrics
+type MetricsSnapshot struct {
+	Queues      []QueueMetrics        `json:"queues"`
+	Events      []EventCounts         `json:"events"`
+	Processing  []ProcessingHistogram `json:"processing"`
+	Agents      []AgentLiveness       `json:"agents,omitempty"`
+	Handoff     *HandoffMetrics       `json:"handoff,omitempty"` // In-process totals of a library agent
+	Pool        *RedisPoolMetrics     `json:"pool,omitempty"`
+	Alerts      []AlertSinkStats      `json:"alerts,omitempty"` // Alert deliveries of this process
+	CollectedAt time.Time             `json:"collected_at"`
+}
+
+// MetricsRecorder records handoff events and processing times, both in the
+// cumulative counters exported by WritePrometheus and in the per-minute
+// buckets read by QueryRollingMetrics, which are kept for Retention
+type MetricsRecorder struct {
+	Retention time.Duration
+}
+
+// NewMetricsRecorder returns a recorder keeping per-minute buckets for
+// retention, or DefaultMetricsRetention when it is not positive
+func NewMetricsRecorder(retention time.Duration) MetricsRecorder {
+	if retention <= 0 {
+		retention = DefaultMetricsRetention
+	}
+	return MetricsRecorder{Retention: retention}
+}
+
+// RecordEvent counts an event for a label set. cmd is usually the pipeline
+// that changes the handoff, so the count is kept with it.
+func (r MetricsRecorder) RecordEvent(ctx context.Context, cmd redis.Cmdable, event HandoffEvent, labels MetricLabels) {
+	cmd.HIncrBy(ctx, MetricsCountersKey, metricsField(string(event), labels.Project, labels.Agent, string(labels.Priority)), 1)
+
+	key := r.minuteKey(time.Now())
+	cmd.HIncrBy(ctx, key, minuteEventField(event, labels), 1)
+	cmd.Expire(ctx, key, r.retention())
+}
+
+// RecordProcessingTime adds an observation to a label set's processing time
+// histograms
+func (r MetricsRecorder) RecordProcessingTime(ctx context.Context, cmd redis.Cmdable, labels MetricLabels, d time.Duration) {
+	seconds := d.Seconds()
+	le := "+Inf"
+	for _, bound := range ProcessingTimeBuckets {
+		if seconds <= bound {
+			le = formatFloat(bound)
+			break
+		}
+	}
+	project, agent, priority := labels.Project, labels.Agent, string(labels.Priority)
+	cmd.HIncrBy(ctx, MetricsProcessingKey, metricsField(project, agent, priority, le), 1)
+	cmd.HIncrBy(ctx, MetricsProcessingKey, metricsField(project, agent, priority, "count"), 1)
+	cmd.HIncrByFloat(ctx, MetricsProcessingKey, metricsField(project, agent, priority, "sum"), seconds)
+
+	key := r.minuteKey(time.Now())
+	cmd.HIncrBy(ctx, key, minuteLatencyField(labels, strconv.Itoa(latencyBucket(d))), 1)
+	cmd.HIncrByFloat(ctx, key, minuteLatencyField(labels, "sum"), seconds)
+	cmd.Expire(ctx, key, r.retention())
+}
+
+// CollectMetrics reads queue depths and ages, event counters and processing
+// time histograms from Redis. Queues are the sorted sets named
+// handoff:queue:<agent> (library agents) and handoff:project:<project>:queue:<agent>
+// (agent-manager); the age of a queue is that of its oldest handoff's created_at.
+func CollectMetrics(ctx context.Context, client redis.Cmdable) (*MetricsSnapshot, error) {
+	snapshot := &MetricsSnapshot{CollectedAt: time.Now()}
+
+	queues, err := collectQueueMetrics(ctx, client, snapshot.CollectedAt)
+	if err != nil {
+		return nil, err
+	}
+	snapshot.Queues = queues
+
+	counters, err := client.HGetAll(ctx, MetricsCountersKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read handoff counters: %w", err)
+	}
+	snapshot.Events = parseEventCounts(counters)
+
+	processing, err := client.HGetAll(ctx, MetricsProcessingKey).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read processing times: %w", err)
+	}
+	snapshot.Processing = parseProcessingHistograms(processing)
+
+	if snapshot.Agents, err = CollectAgentLiveness(ctx, client, snapshot.CollectedAt); err != nil {
+		return nil, err
+	}
+
+	return snapshot, nil
+}
+
+func collectQueueMetrics(ctx context.Context, client redis.Cmdable, now time.Time) ([]QueueMetrics, error) {
+	var keys []string
+	for _, pattern := range []string{"handoff:queue:*", "handoff:project:*:queue:*"} {
+		var cursor uint64
+		for {
+			batch, next, err := client.Scan(ctx, cursor, pattern, 100).Result()
+			if err != nil {
+				return nil, fmt.Errorf("failed to scan queue keys: %w", err)
+			}
+			keys = append(keys, batch...)
+			if cursor = next; cursor == 0 {
+				break
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	var queues []QueueMetrics
+	for _, key := range keys {
+		project, agent, ok := parseQueueKey(key)
+		if !ok {
+			continue
+		}
+		depth, err := client.ZCard(ctx, key).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read depth of %s: %w", key, err)
+		}
+		if depth == 0 {
+			queues = append(queues, QueueMetrics{Queue: key, Project: project, Agent: agent})
+			continue
+		}
+
+		ids, err := client.ZRange(ctx, key, 0, maxAgeSample-1).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", key, err)
+		}
+		handoffKeys := make([]string, len(ids))
+		for i, id := range ids {
+			handoffKeys[i] = "handoff:" + id
+		}
+		docs, err := client.MGet(ctx, handoffKeys...).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read handoffs queued in %s: %w", key, err)
+		}
+		enqueued, err := enqueueTimes(ctx, client, key, ids)
+		if err != nil {
+			return nil, err
+		}
+
+		byProject := map[string]*QueueMetrics{}
+		metricsFor := func(name string) *QueueMetrics {
+			if byProject[name] == nil {
+				byProject[name] = &QueueMetrics{Queue: key, Project: name, Agent: agent}
+			}
+			return byProject[name]
+		}
+		for i, doc := range docs {
+			data, _ := doc.(string)
+			entry := decodeQueuedHandoff(data)
+			name := project
+			if name == "" {
+				name = entry.project
+			}
+			queue := metricsFor(name)
+			queue.Depth++
+			// Handoffs queued before enqueue times were tracked fall back to
+			// their creation time
+			since, ok := enqueued[ids[i]]
+			if !ok {
+				since = entry.createdAt
+			}
+			if !since.IsZero() {
+				if age := now.Sub(since); age > queue.OldestAge {
+					queue.OldestAge = age
+				}
+			}
+		}
+		// Handoffs beyond the sample are counted against the queue's own project
+		if sampled := int64(len(docs)); depth > sampled {
+			metricsFor(project).Depth += depth - sampled
+		}
+
+		names := make([]string, 0, len(byProject))
+		for name := range byProject {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			queues = append(queues, *byProject[name])
+		}
+	}
+	return queues, nil
+}
+
+// parseQueueKey returns the project and agent of a queue key; library queues
+// have no project
+func parseQueueKey(key string) (project, agent string, ok bool) {
+	parts := strings.Split(key, ":")
+	switch {
+	case len(parts) == 3 && parts[0] == "handoff" && parts[1] == "queue":
+		return "", parts[2], true
+	case len(parts) == 5 && parts[0] == "handoff" && parts[1] == "project" && parts[3] == "queue":
+		return parts[2], parts[4], true
+	}
+	return "", "", false
+}
+
+type queuedHandoff struct {
+	project     string
+	priority    Priority
+	createdAt   time.Time
+	slaBreached bool
+}
+
+// decodeQueuedHandoff reads the project, priority and creation time of a
+// stored handoff, either a HandoffQueueMessage or a bare handoff as the
+// agent-manager stores it
+func decodeQueuedHandoff(data string) queuedHandoff {
+	type stored struct {
+		Metadata struct {
+			ProjectName string   `json:"project_name"`
+			Priority    Priority `json:"priority"`
+		} `json:"metadata"`
+		CreatedAt time.Time  `json:"created_at"`
+		SLABreach *SLABreach `json:"sla_breach"`
+	}
+	var doc struct {
+		stored
+		Payload *stored `json:"payload"`
+	}
+	if data == "" || json.Unmarshal([]byte(data), &doc) != nil {
+		return queuedHandoff{}
+	}
+	found := doc.stored
+	if doc.Payload != nil {
+		found = *doc.Payload
+	}
+	return queuedHandoff{
+		project:     found.Metadata.ProjectName,
+		priority:    found.Metadata.Priority,
+		createdAt:   found.CreatedAt,
+		slaBreached: found.SLABreach != nil,
+	}
+}
+
+func metricsField(parts ...string) string {
+	return strings.Join(parts, "\x1f")
+}
+
+func parseEventCounts(hash map[string]string) []EventCounts {
+	byLabels := map[MetricLabels]*EventCounts{}
+	for field, value := range hash {
+		parts := strings.Split(field, "\x1f")
+		count, err := strconv.ParseInt(value, 10, 64)
+		if len(parts) != 4 || err != nil {
+			continue
+		}
+		labels := MetricLabels{Project: parts[1], Agent: parts[2], Priority: Priority(parts[3])}
+		if byLabels[labels] == nil {
+			byLabels[labels] = &EventCounts{MetricLabels: labels, Counts: map[HandoffEvent]int64{}}
+		}
+		byLabels[labels].Counts[HandoffEvent(parts[0])] = count
+	}
+
+	events := make([]EventCounts, 0, len(byLabels))
+	for _, counts := range byLabels {
+		events = append(events, *counts)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].MetricLabels.less(events[j].MetricLabels)
+	})
+	return events
+}
+
+func parseProcessingHistograms(hash map[string]string) []ProcessingHistogram {
+	bounds := make(map[string]int, len(ProcessingTimeBuckets))
+	for i, bound := range ProcessingTimeBuckets {
+		bounds[formatFloat(bound)] = i
+	}
+
+	byLabels := map[MetricLabels]*ProcessingHistogram{}
+	for field, value := range hash {
+		parts := strings.Split(field, "\x1f")
+		if len(parts) != 4 {
+			continue
+		}
+		labels := MetricLabels{Project: parts[0], Agent: parts[1], Priority: Priority(parts[2])}
+		histogram := byLabels[labels]
+		if histogram == nil {
+			histogram = &ProcessingHistogram{MetricLabels: labels, Buckets: make([]uint64, len(ProcessingTimeBuckets))}
+			byLabels[labels] = histogram
+		}
+
+		switch parts[3] {
+		case "sum":
+			histogram.Sum, _ = strconv.ParseFloat(value, 64)
+		case "count":
+			histogram.Count, _ = strconv.ParseUint(value, 10, 64)
+		default:
+			// Buckets are stored per bound and made cumulative below
+			if i, ok := bounds[parts[3]]; ok {
+				histogram.Buckets[i], _ = strconv.ParseUint(value, 10, 64)
+			}
+		}
+	}
+
+	histograms := make([]ProcessingHistogram, 0, len(byLabels))
+	for _, histogram := range byLabels {
+		for i := 1; i < len(histogram.Buckets); i++ {
+			histogram.Buckets[i] += histogram.Buckets[i-1]
+		}
+		histograms = append(histograms, *histogram)
+	}
+	sort.Slice(histograms, func(i, j int) bool {
+		return histograms[i].MetricLabels.less(histograms[j].MetricLabels)
+	})
+	return histograms
+}
+
+// labels returns the Prometheus label pairs of a series
+func (l MetricLabels) labels() []string {
+	return []string{"project", l.Project, "agent", l.Agent, "priority", string(l.Priority)}
+}
+
+// PoolMetricsFromStats converts go-redis pool statistics for export. Request
+// counts and latencies are only tracked by RedisPoolManager and are left zero.
+func PoolMetricsFromStats(stats *redis.PoolStats) RedisPoolMetrics {
+	return RedisPoolMetrics{
+		TotalConns:  stats.TotalConns,
+		IdleConns:   stats.IdleConns,
+		StaleConns:  stats.StaleConns,
+		Hits:        uint64(stats.Hits),
+		Misses:      uint64(stats.Misses),
+		Timeouts:    uint64(stats.Timeouts),
+		LastUpdated: time.Now(),
+	}
+}
+
+// WritePrometheus writes the snapshot in the Prometheus text exposition format
+func (s *MetricsSnapshot) WritePrometheus(w io.Writer) error {
+	p := &promWriter{w: w}
+
+	p.family("handoff_queue_depth", "Handoffs waiting in an agent's queue.", "gauge")
+	for _, q := range s.Queues {
+		p.sample("handoff_queue_depth", float64(q.Depth), "project", q.Project, "agent", q.Agent)
+	}
+	p.family("handoff_queue_oldest_age_seconds", "Age of the oldest handoff waiting in an agent's queue.", "gauge")
+	for _, q := range s.Queues {
+		p.sample("handoff_queue_oldest_age_seconds", q.OldestAge.Seconds(), "project", q.Project, "agent", q.Agent)
+	}
+
+	eventHelp := map[HandoffEvent]string{
+		EventPublished:   "Handoffs published to an agent.",
+		EventCompleted:   "Handoffs an agent processed successfully.",
+		EventFailed:      "Failed processing attempts, including those retried.",
+		EventRetried:     "Handoffs scheduled for another processing attempt.",
+		EventQuarantined: "Handoffs quarantined after failing an integrity or signature check.",
+		EventSLABreached: "Handoffs that waited in their queue longer than their priority's SLA.",
+	}
+	for _, event := range HandoffEvents {
+		name := "handoff_" + string(event) + "_total"
+		if event == EventRetried {
+			name = "handoff_retries_total"
+		}
+		p.family(name, eventHelp[event], "counter")
+		for _, e := range s.Events {
+			if count, ok := e.Counts[event]; ok {
+				p.sample(name, float64(count), e.labels()...)
+			}
+		}
+	}
+
+	p.family("handoff_processing_seconds", "Time agents took to process handoffs.", "histogram")
+	for _, h := range s.Processing {
+		labels := h.labels()
+		for i, bound := range ProcessingTimeBuckets {
+			p.sample("handoff_processing_seconds_bucket", float64(h.Buckets[i]), append(labels, "le", formatFloat(bound))...)
+		}
+		p.sample("handoff_processing_seconds_bucket", float64(h.Count), append(labels, "le", "+Inf")...)
+		p.sample("handoff_processing_seconds_sum", h.Sum, labels...)
+		p.sample("handoff_processing_seconds_count", float64(h.Count), labels...)
+	}
+
+	if len(s.Agents) > 0 {
+		p.family("handoff_agent_instances", "Live instances of each registered or heartbeating agent.", "gauge")
+		for _, a := range s.Agents {
+			p.sample("handoff_agent_instances", float64(len(a.Instances)), "agent", a.Agent)
+		}
+		p.family("handoff_agent_load", "Handoffs being processed by an agent's live instances.", "gauge")
+		for _, a := range s.Agents {
+			p.sample("handoff_agent_load", float64(a.Load()), "agent", a.Agent)
+		}
+	}
+
+	if s.Handoff != nil {
+		p.family("handoff_active_agents", "Agents with a running consumer in this process.", "gauge")
+		p.sample("handoff_active_agents", float64(len(s.Handoff.ActiveAgents)))
+	}
+
+	if len(s.Alerts) > 0 {
+		p.family("handoff_alert_notifications_total", "Alert notifications by sink and result.", "counter")
+		for _, a := range s.Alerts {
+			p.sample("handoff_alert_notifications_total", float64(a.Delivered), "sink", a.Sink, "result", "delivered")
+			p.sample("handoff_alert_notifications_total", float64(a.Failed), "sink", a.Sink, "result", "failed")
+			p.sample("handoff_alert_notifications_total", float64(a.Dropped), "sink", a.Sink, "result", "dropped")
+		}
+	}
+
+	if pool := s.Pool; pool != nil {
+		p.family("handoff_redis_pool_connections", "Redis connections in the pool by state.", "gauge")
+		p.sample("handoff_redis_pool_connections", float64(pool.TotalConns), "state", "total")
+		p.sample("handoff_redis_pool_connections", float64(pool.IdleConns), "state", "idle")
+		p.sample("handoff_redis_pool_connections", float64(pool.StaleConns), "state", "stale")
+		p.counter("handoff_redis_pool_hits_total", "Times a free connection was found in the pool.", float64(pool.Hits))
+		p.counter("handoff_redis_pool_misses_total", "Times a free connection was not found in the pool.", float64(pool.Misses))
+		p.counter("handoff_redis_pool_timeouts_total", "Times waiting for a connection timed out.", float64(pool.Timeouts))
+
+		if pool.TotalRequests > 0 {
+			p.counter("handoff_redis_requests_total", "Redis operations executed.", float64(pool.TotalRequests))
+			p.counter("handoff_redis_requests_failed_total", "Redis operations that failed.", float64(pool.FailedRequests))
+			p.counter("handoff_redis_pipeline_hits_total", "Operations executed in pipelines.", float64(pool.PipelineHits))
+			p.counter("handoff_redis_batch_operations_total", "Batches of operations executed.", float64(pool.BatchOperations))
+			p.family("handoff_redis_request_latency_seconds", "Redis operation latency.", "gauge")
+			p.sample("handoff_redis_request_latency_seconds", pool.AvgLatency.Seconds(), "stat", "avg")
+			p.sample("handoff_redis_request_latency_seconds", pool.MaxLatency.Seconds(), "stat", "max")
+		}
+	}
+
+	return p.err
+}
+
+// MetricsHandler serves the snapshots returned by collect on /metrics. A
+// failed collection is reported as 503 so the scrape is marked as down.
+func MetricsHandler(collect func(ctx context.Context) (*MetricsSnapshot, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		snapshot, err := collect(r.Context())
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to collect metrics: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+		var buf bytes.Buffer
+		if err := snapshot.WritePrometheus(&buf); err != nil {
+			http.Error(w, fmt.Sprintf("failed to write metrics: %v", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", MetricsContentType)
+		w.Write(buf.Bytes())
+	})
+}
+
+// DefaultSummaryWindow is the window MetricsSummaryHandler reports without a window parameter
+const DefaultSummaryWindow = time.Hour
+
+// MetricsSummary is the JSON body served by MetricsSummaryHandler
+type MetricsSummary struct {
+	From   time.Time      `json:"from"`
+	To     time.Time      `json:"to"`
+	Total  MetricSeries   `json:"total"`
+	Series []MetricSeries `json:"series"`
+}
+
+// MetricsSummaryHandler serves counts and processing time percentiles over a
+// recent window as JSON. The window query parameter is a duration (default
+// one hour); project, agent and priority parameters filter the series.
+func MetricsSummaryHandler(query func(ctx context.Context, from, to time.Time) (*RollingMetrics, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		window := DefaultSummaryWindow
+		if value := r.URL.Query().Get("window"); value != "" {
+			parsed, err := time.ParseDuration(value)
+			if err != nil || parsed <= 0 {
+				http.Error(w, fmt.Sprintf("invalid window %q", value), http.StatusBadRequest)
+				return
+			}
+			window = parsed
+		}
+		filter := MetricLabels{
+			Project:  r.URL.Query().Get("project"),
+			Agent:    r.URL.Query().Get("agent"),
+			Priority: Priority(r.URL.Query().Get("priority")),
+		}
+
+		to := time.Now()
+		rolling, err := query(r.Context(), to.Add(-window), to)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to read metrics: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+		summary := MetricsSummary{From: rolling.From, To: rolling.To, Total: rolling.Aggregate(filter).Summary(), Series: []MetricSeries{}}
+		for _, series := range rolling.Series {
+			if series.Labels.Matches(filter) {
+				summary.Series = append(summary.Series, series.Summary())
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(summary)
+	})
+}
+
+// promWriter writes the text exposition format, keeping the first write error
+type promWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (p *promWriter) printf(format string, args ...interface{}) {
+	if p.err == nil {
+		_, p.err = fmt.Fprintf(p.w, format, args...)
+	}
+}
+
+func (p *promWriter) family(name, help, metricType string) {
+	p.printf("# HELP %s %s\n# TYPE %s %s\n", name, escapeHelp(help), name, metricType)
+}
+
+// sample writes one sample; labels are name, value pairs
+func (p *promWriter) sample(name string, value float64, labels ...string) {
+	var b strings.Builder
+	b.WriteString(name)
+	if len(labels) > 0 {
+		b.WriteByte('{')
+		for i := 0; i+1 < len(labels); i += 2 {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			fmt.Fprintf(&b, "%s=\"%s\"", labels[i], escapeLabelValue(labels[i+1]))
+		}
+		b.WriteByte('}')
+	}
+	p.printf("%s %s\n", b.String(), formatFloat(value))
+}
+
+func (p *promWriter) counter(name, help string, value float64) {
+	p.family(name, help, "counter")
+	p.sample(name, value)
+}
+
+var (
+	helpEscaper       = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+	labelValueEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+)
+
+func escapeHelp(s string) string {
+	return helpEscaper.Replace(s)
+}
+
+func escapeLabelValue(s string) string {
+	return labelValueEscaper.Replace(s)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,302 @@
+package handoff
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Each minute of handoff activity is a hash named
+// handoff:metrics:minute:<unix minute> that expires once it is older than the
+// recorder's retention, so windows roll forward instead of resetting.
+// Fields are "e\x1f<event>\x1f<project>\x1f<agent>\x1f<priority>" event counts
+// and "l\x1f<project>\x1f<agent>\x1f<priority>\x1f<bucket|sum>" latencies.
+const metricsMinuteKeyPrefix = "handoff:metrics:minute:"
+
+// DefaultMetricsRetention is how long per-minute buckets are kept by default
+const DefaultMetricsRetention = 7 * 24 * time.Hour
+
+// LatencyBounds are the upper bounds of the buckets processing times are
+// counted in for percentiles: 10ms, growing by 20% per bucket to about 7 hours.
+// Longer times fall in one more, unbounded bucket.
+var LatencyBounds = func() []time.Duration {
+	bounds := make([]time.Duration, 82)
+	for i := range bounds {
+		bounds[i] = time.Duration(float64(10*time.Millisecond) * math.Pow(1.2, float64(i)))
+	}
+	return bounds
+}()
+
+// LatencyHistogram counts processing times in LatencyBounds buckets, which
+// keeps percentiles within 20% of the true value at any volume
+type LatencyHistogram struct {
+	Counts []uint64      `json:"counts"` // One per LatencyBounds bound, then the overflow bucket
+	Sum    time.Duration `json:"sum"`
+}
+
+// NewLatencyHistogram returns an empty histogram
+func NewLatencyHistogram() LatencyHistogram {
+	return LatencyHistogram{Counts: make([]uint64, len(LatencyBounds)+1)}
+}
+
+// Observe adds a processing time
+func (h *LatencyHistogram) Observe(d time.Duration) {
+	if h.Counts == nil {
+		h.Counts = make([]uint64, len(LatencyBounds)+1)
+	}
+	h.Counts[latencyBucket(d)]++
+	h.Sum += d
+}
+
+// Merge adds the observations of other
+func (h *LatencyHistogram) Merge(other LatencyHistogram) {
+	if h.Counts == nil {
+		h.Counts = make([]uint64, len(LatencyBounds)+1)
+	}
+	for i, count := range other.Counts {
+		h.Counts[i] += count
+	}
+	h.Sum += other.Sum
+}
+
+// Count returns the number of observations
+func (h LatencyHistogram) Count() uint64 {
+	var total uint64
+	for _, count := range h.Counts {
+		total += count
+	}
+	return total
+}
+
+// Mean returns the average processing time
+func (h LatencyHistogram) Mean() time.Duration {
+	count := h.Count()
+	if count == 0 {
+		return 0
+	}
+	return h.Sum / time.Duration(count)
+}
+
+// Quantile estimates the processing time below which a fraction q of the
+// observations fall, interpolating within the bucket it lands in. Times in the
+// overflow bucket are reported as the largest bound.
+func (h LatencyHistogram) Quantile(q float64) time.Duration {
+	count := h.Count()
+	if count == 0 {
+		return 0
+	}
+	rank := q * float64(count)
+	var cumulative uint64
+	for i, n := range h.Counts {
+		if n == 0 {
+			continue
+		}
+		if float64(cumulative+n) >= rank {
+			if i >= len(LatencyBounds) {
+				return LatencyBounds[len(LatencyBounds)-1]
+			}
+			var lower time.Duration
+			if i > 0 {
+				lower = LatencyBounds[i-1]
+			}
+			fraction := (rank - float64(cumulative)) / float64(n)
+			return lower + time.Duration(fraction*float64(LatencyBounds[i]-lower))
+		}
+		cumulative += n
+	}
+	return LatencyBounds[len(LatencyBounds)-1]
+}
+
+// latencyBucket returns the index of the bucket d is counted in
+func latencyBucket(d time.Duration) int {
+	return sort.Search(len(LatencyBounds), func(i int) bool { return d <= LatencyBounds[i] })
+}
+
+// RollingSeries is the activity of one label set over a window
+type RollingSeries struct {
+	Labels     MetricLabels           `json:"labels"`
+	Counts     map[HandoffEvent]int64 `json:"counts"`
+	Processing LatencyHistogram       `json:"processing"`
+}
+
+// Summary returns the series' counts and processing time percentiles
+func (s RollingSeries) Summary() MetricSeries {
+	return MetricSeries{
+		MetricLabels: s.Labels,
+		Published:    s.Counts[EventPublished],
+		Completed:    s.Counts[EventCompleted],
+		Failed:       s.Counts[EventFailed],
+		Retried:      s.Counts[EventRetried],
+		Quarantined:  s.Counts[EventQuarantined],
+		SLABreached:  s.Counts[EventSLABreached],
+		Processed:    s.Processing.Count(),
+		Mean:         s.Processing.Mean(),
+		P50:          s.Processing.Quantile(0.50),
+		P95:          s.Processing.Quantile(0.95),
+		P99:          s.Processing.Quantile(0.99),
+	}
+}
+
+// MetricSeries summarises one label set's activity over a window
+type MetricSeries struct {
+	MetricLabels
+	Published   int64         `json:"published"`
+	Completed   int64         `json:"completed"`
+	Failed      int64         `json:"failed"`
+	Retried     int64         `json:"retried"`
+	Quarantined int64         `json:"quarantined"`
+	SLABreached int64         `json:"sla_breached"`
+	Processed   uint64        `json:"processed"` // Processing times observed
+	Mean        time.Duration `json:"mean"`
+	P50         time.Duration `json:"p50"`
+	P95         time.Duration `json:"p95"`
+	P99         time.Duration `json:"p99"`
+}
+
+// RollingMetrics is handoff activity over a window, per label set
+type RollingMetrics struct {
+	From   time.Time       `json:"from"`
+	To     time.Time       `json:"to"`
+	Series []RollingSeries `json:"series"`
+}
+
+// QueryRollingMetrics sums the per-minute buckets of the minutes from from to
+// to, inclusive. Minutes older than the retention have expired and count as
+// no activity.
+func QueryRollingMetrics(ctx context.Context, client redis.Cmdable, from, to time.Time) (*RollingMetrics, error) {
+	first, last := from.Unix()/60, to.Unix()/60
+	if last < first {
+		return nil, fmt.Errorf("metrics window ends before it starts")
+	}
+
+	pipe := client.Pipeline()
+	cmds := make([]*redis.StringStringMapCmd, 0, last-first+1)
+	for minute := first; minute <= last; minute++ {
+		cmds = append(cmds, pipe.HGetAll(ctx, metricsMinuteKeyPrefix+strconv.FormatInt(minute, 10)))
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to read metric buckets: %w", err)
+	}
+
+	series := map[MetricLabels]*RollingSeries{}
+	for _, cmd := range cmds {
+		addMinuteBucket(series, cmd.Val())
+	}
+	return &RollingMetrics{From: from, To: to, Series: sortedSeries(series)}, nil
+}
+
+// Aggregate sums the series whose labels match filter; empty labels in filter
+// match any value
+func (m *RollingMetrics) Aggregate(filter MetricLabels) RollingSeries {
+	total := RollingSeries{Labels: filter, Counts: map[HandoffEvent]int64{}, Processing: NewLatencyHistogram()}
+	for _, s := range m.Series {
+		if !s.Labels.Matches(filter) {
+			continue
+		}
+		for event, count := range s.Counts {
+			total.Counts[event] += count
+		}
+		total.Processing.Merge(s.Processing)
+	}
+	return total
+}
+
+// Summaries returns the summary of every series
+func (m *RollingMetrics) Summaries() []MetricSeries {
+	summaries := make([]MetricSeries, len(m.Series))
+	for i, s := range m.Series {
+		summaries[i] = s.Summary()
+	}
+	return summaries
+}
+
+func (r MetricsRecorder) minuteKey(t time.Time) string {
+	return metricsMinuteKeyPrefix + strconv.FormatInt(t.Unix()/60, 10)
+}
+
+// retention is how long a minute's bucket lives after its last write, which
+// keeps it for Retention after the minute ends
+func (r MetricsRecorder) retention() time.Duration {
+	if r.Retention <= 0 {
+		return DefaultMetricsRetention + time.Minute
+	}
+	return r.Retention + time.Minute
+}
+
+func minuteEventField(event HandoffEvent, labels MetricLabels) string {
+	return metricsField("e", string(event), labels.Project, labels.Agent, string(labels.Priority))
+}
+
+func minuteLatencyField(labels MetricLabels, bucket string) string {
+	return metricsField("l", labels.Project, labels.Agent, string(labels.Priority), bucket)
+}
+
+// addMinuteBucket adds one minute's hash to the series it holds counts for
+func addMinuteBucket(series map[MetricLabels]*RollingSeries, hash map[string]string) {
+	seriesFor := func(labels MetricLabels) *RollingSeries {
+		if series[labels] == nil {
+			series[labels] = &RollingSeries{Labels: labels, Counts: map[HandoffEvent]int64{}, Processing: NewLatencyHistogram()}
+		}
+		return series[labels]
+	}
+
+	for field, value := range hash {
+		parts := strings.Split(field, "\x1f")
+		if len(parts) != 5 {
+			continue
+		}
+		switch parts[0] {
+		case "e":
+			count, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				continue
+			}
+			labels := MetricLabels{Project: parts[2], Agent: parts[3], Priority: Priority(parts[4])}
+			seriesFor(labels).Counts[HandoffEvent(parts[1])] += count
+		case "l":
+			labels := MetricLabels{Project: parts[1], Agent: parts[2], Priority: Priority(parts[3])}
+			if parts[4] == "sum" {
+				seconds, err := strconv.ParseFloat(value, 64)
+				if err == nil {
+					seriesFor(labels).Processing.Sum += time.Duration(seconds * float64(time.Second))
+				}
+				continue
+			}
+			bucket, err := strconv.Atoi(parts[4])
+			count, countErr := strconv.ParseUint(value, 10, 64)
+			if err != nil || countErr != nil || bucket < 0 || bucket > len(LatencyBounds) {
+				continue
+			}
+			seriesFor(labels).Processing.Counts[bucket] += count
+		}
+	}
+}
+
+func sortedSeries(byLabels map[MetricLabels]*RollingSeries) []RollingSeries {
+	series := make([]RollingSeries, 0, len(byLabels))
+	for _, s := range byLabels {
+		series = append(series, *s)
+	}
+	sort.Slice(series, func(i, j int) bool { return series[i].Labels.less(series[j].Labels) })
+	return series
+}
+
+// applyRollingMetrics sets the counts, processing times and breakdown of
+// metrics from a window of rolling metrics
+func applyRollingMetrics(metrics *HandoffMetrics, rolling *RollingMetrics) {
+	total := rolling.Aggregate(MetricLabels{}).Summary()
+	metrics.TotalHandoffs = total.Published
+	metrics.CompletedHandoffs = total.Completed
+	metrics.FailedHandoffs = total.Failed
+	metrics.AvgProcessingTime = total.Mean
+	metrics.ProcessingP50 = total.P50
+	metrics.ProcessingP95 = total.P95
+	metrics.ProcessingP99 = total.P99
+	metrics.Breakdown = rolling.Summaries()
+}
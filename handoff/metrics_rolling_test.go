@@ -0,0 +1,99 @@
+package handoff
+
+import (
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestLatencyHistogramQuantile(t *testing.T) {
+	var h LatencyHistogram
+	if h.Quantile(0.5) != 0 || h.Mean() != 0 {
+		t.Fatal("expected an empty histogram to report zero")
+	}
+
+	// 90 fast handoffs and 10 slow ones
+	for i := 0; i < 90; i++ {
+		h.Observe(200 * time.Millisecond)
+	}
+	for i := 0; i < 10; i++ {
+		h.Observe(30 * time.Second)
+	}
+
+	within := func(got, want time.Duration) bool {
+		return got >= want*8/10 && got <= want*12/10
+	}
+	if p50 := h.Quantile(0.50); !within(p50, 200*time.Millisecond) {
+		t.Errorf("expected p50 near 200ms, got %s", p50)
+	}
+	if p95 := h.Quantile(0.95); !within(p95, 30*time.Second) {
+		t.Errorf("expected p95 near 30s, got %s", p95)
+	}
+	if mean := h.Mean(); mean != 3180*time.Millisecond {
+		t.Errorf("expected the exact mean, got %s", mean)
+	}
+
+	h.Observe(48 * time.Hour)
+	if p100 := h.Quantile(1); p100 != LatencyBounds[len(LatencyBounds)-1] {
+		t.Errorf("expected overflow reported as the largest bound, got %s", p100)
+	}
+}
+
+func TestLatencyBucket(t *testing.T) {
+	for _, d := range []time.Duration{0, time.Millisecond, 10 * time.Millisecond, 137 * time.Millisecond, time.Minute, 6 * time.Hour} {
+		i := latencyBucket(d)
+		if i >= len(LatencyBounds) || d > LatencyBounds[i] || (i > 0 && d <= LatencyBounds[i-1]) {
+			t.Errorf("%s counted in the wrong bucket %d", d, i)
+		}
+	}
+	if i := latencyBucket(24 * time.Hour); i != len(LatencyBounds) {
+		t.Errorf("expected a day in the overflow bucket, got %d", i)
+	}
+}
+
+func TestAddMinuteBucket(t *testing.T) {
+	high := MetricLabels{Project: "billing", Agent: "golang-expert", Priority: PriorityHigh}
+	low := MetricLabels{Project: "billing", Agent: "golang-expert", Priority: PriorityLow}
+	auth := MetricLabels{Project: "auth", Agent: "test-expert", Priority: PriorityNormal}
+	fast := strconv.Itoa(latencyBucket(time.Second))
+
+	series := map[MetricLabels]*RollingSeries{}
+	addMinuteBucket(series, map[string]string{
+		minuteEventField(EventPublished, high): "3",
+		minuteEventField(EventCompleted, high): "2",
+		minuteLatencyField(high, fast):         "2",
+		minuteLatencyField(high, "sum"):        "2",
+		minuteEventField(EventFailed, auth):    "1",
+		minuteLatencyField(auth, "999"):        "1",
+		"malformed":                            "1",
+	})
+	addMinuteBucket(series, map[string]string{
+		minuteEventField(EventPublished, high): "1",
+		minuteEventField(EventPublished, low):  "4",
+		minuteLatencyField(high, fast):         "1",
+		minuteLatencyField(high, "sum"):        "1",
+	})
+
+	rolling := &RollingMetrics{Series: sortedSeries(series)}
+	if len(rolling.Series) != 3 || rolling.Series[0].Labels != auth || rolling.Series[1].Labels != high {
+		t.Fatalf("unexpected series %+v", rolling.Series)
+	}
+	summary := rolling.Series[1].Summary()
+	if summary.Published != 4 || summary.Completed != 2 || summary.Processed != 3 || summary.Mean != time.Second {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+
+	project := rolling.Aggregate(MetricLabels{Project: "billing"})
+	if project.Counts[EventPublished] != 8 || project.Processing.Count() != 3 {
+		t.Errorf("unexpected project totals %+v", project.Counts)
+	}
+	if all := rolling.Aggregate(MetricLabels{}); all.Counts[EventFailed] != 1 || all.Counts[EventPublished] != 8 {
+		t.Errorf("unexpected totals %+v", all.Counts)
+	}
+
+	var metrics HandoffMetrics
+	applyRollingMetrics(&metrics, rolling)
+	if metrics.TotalHandoffs != 8 || metrics.FailedHandoffs != 1 || metrics.ProcessingP50 == 0 || len(metrics.Breakdown) != 3 {
+		t.Errorf("unexpected handoff metrics %+v", metrics)
+	}
+}
@@ -0,0 +1,216 @@
+package handoff
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseProcessingHistograms(t *testing.T) {
+	hash := map[string]string{
+		metricsField("billing", "golang-expert", "high", "0.5"):   "2",
+		metricsField("billing", "golang-expert", "high", "5"):     "1",
+		metricsField("billing", "golang-expert", "high", "+Inf"):  "1",
+		metricsField("billing", "golang-expert", "high", "count"): "4",
+		metricsField("billing", "golang-expert", "high", "sum"):   "7201.25",
+		metricsField("auth", "test-expert", "normal", "0.1"):      "1",
+		metricsField("auth", "test-expert", "normal", "count"):    "1",
+		metricsField("auth", "test-expert", "normal", "sum"):      "0.05",
+		"malformed": "1",
+	}
+
+	histograms := parseProcessingHistograms(hash)
+	if len(histograms) != 2 || histograms[0].Project != "auth" || histograms[1].Agent != "golang-expert" || histograms[1].Priority != PriorityHigh {
+		t.Fatalf("expected two histograms sorted by project, got %+v", histograms)
+	}
+	billing := histograms[1]
+	want := []uint64{0, 2, 2, 2, 3, 3, 3, 3, 3, 3, 3, 3}
+	for i, count := range want {
+		if billing.Buckets[i] != count {
+			t.Fatalf("expected cumulative buckets %v, got %v", want, billing.Buckets)
+		}
+	}
+	if billing.Count != 4 || billing.Sum != 7201.25 {
+		t.Errorf("unexpected count and sum %d %v", billing.Count, billing.Sum)
+	}
+}
+
+func TestParseEventCounts(t *testing.T) {
+	events := parseEventCounts(map[string]string{
+		metricsField("published", "billing", "golang-expert", "high"):   "5",
+		metricsField("completed", "billing", "golang-expert", "high"):   "3",
+		metricsField("published", "billing", "golang-expert", "normal"): "2",
+		metricsField("retried", "", "api-expert", "low"):                "1",
+		metricsField("published", "billing", "golang-expert"):           "9",
+	})
+	if len(events) != 3 || events[0].Agent != "api-expert" || events[0].Counts[EventRetried] != 1 {
+		t.Fatalf("unexpected events %+v", events)
+	}
+	if events[1].Priority != PriorityHigh || events[1].Counts[EventPublished] != 5 || events[1].Counts[EventCompleted] != 3 {
+		t.Errorf("unexpected counts %+v", events[1])
+	}
+	if events[2].Priority != PriorityNormal || events[2].Counts[EventPublished] != 2 {
+		t.Errorf("unexpected counts %+v", events[2])
+	}
+}
+
+func TestDecodeQueuedHandoff(t *testing.T) {
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	message := `{"handoff_id":"h1","payload":{"metadata":{"project_name":"billing"},"created_at":"2024-05-01T10:00:00Z"}}`
+	if got := decodeQueuedHandoff(message); got.project != "billing" || !got.createdAt.Equal(created) {
+		t.Errorf("unexpected queue message entry %+v", got)
+	}
+	bare := `{"metadata":{"project_name":"auth"},"created_at":"2024-05-01T10:00:00Z"}`
+	if got := decodeQueuedHandoff(bare); got.project != "auth" || !got.createdAt.Equal(created) {
+		t.Errorf("unexpected stored handoff entry %+v", got)
+	}
+	if got := decodeQueuedHandoff("not json"); !got.createdAt.IsZero() {
+		t.Errorf("expected nothing from a corrupt entry, got %+v", got)
+	}
+
+	for key, want := range map[string][2]string{
+		"handoff:queue:golang-expert":                 {"", "golang-expert"},
+		"handoff:project:billing:queue:golang-expert": {"billing", "golang-expert"},
+	} {
+		if project, agent, ok := parseQueueKey(key); !ok || project != want[0] || agent != want[1] {
+			t.Errorf("parseQueueKey(%q) = %q, %q, %v", key, project, agent, ok)
+		}
+	}
+	if _, _, ok := parseQueueKey("handoff:queue:golang-expert:extra"); ok {
+		t.Error("expected an unknown key layout to be refused")
+	}
+}
+
+func TestWritePrometheus(t *testing.T) {
+	buckets := make([]uint64, len(ProcessingTimeBuckets))
+	for i := range buckets {
+		buckets[i] = 2
+	}
+	buckets[0] = 1
+	labels := MetricLabels{Project: "billing", Agent: "golang-expert", Priority: PriorityHigh}
+	snapshot := &MetricsSnapshot{
+		Queues: []QueueMetrics{{Queue: "handoff:queue:golang-expert", Project: `bill"ing`, Agent: "golang-expert", Depth: 3, OldestAge: 90 * time.Second}},
+		Events: []EventCounts{{MetricLabels: labels, Counts: map[HandoffEvent]int64{EventPublished: 5, EventRetried: 1}}},
+		Processing: []ProcessingHistogram{
+			{MetricLabels: labels, Buckets: buckets, Count: 3, Sum: 4000.5},
+		},
+		Agents: []AgentLiveness{
+			{Agent: "golang-expert", Registered: true, Instances: []AgentHeartbeat{{InstanceID: "a", Load: 2}, {InstanceID: "b", Load: 1}}},
+			{Agent: "test-expert", Registered: true},
+		},
+		Handoff: &HandoffMetrics{ActiveAgents: []string{"golang-expert"}},
+		Pool:    &RedisPoolMetrics{TotalConns: 10, IdleConns: 7, Hits: 42},
+	}
+
+	var out strings.Builder
+	if err := snapshot.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	text := out.String()
+
+	for _, want := range []string{
+		"# TYPE handoff_queue_depth gauge\n",
+		`handoff_queue_depth{project="bill\"ing",agent="golang-expert"} 3` + "\n",
+		`handoff_queue_oldest_age_seconds{project="bill\"ing",agent="golang-expert"} 90` + "\n",
+		"# TYPE handoff_published_total counter\n",
+		`handoff_published_total{project="billing",agent="golang-expert",priority="high"} 5` + "\n",
+		`handoff_retries_total{project="billing",agent="golang-expert",priority="high"} 1` + "\n",
+		"# TYPE handoff_processing_seconds histogram\n",
+		`handoff_processing_seconds_bucket{project="billing",agent="golang-expert",priority="high",le="0.1"} 1` + "\n",
+		`handoff_processing_seconds_bucket{project="billing",agent="golang-expert",priority="high",le="3600"} 2` + "\n",
+		`handoff_processing_seconds_bucket{project="billing",agent="golang-expert",priority="high",le="+Inf"} 3` + "\n",
+		`handoff_processing_seconds_sum{project="billing",agent="golang-expert",priority="high"} 4000.5` + "\n",
+		`handoff_agent_instances{agent="golang-expert"} 2` + "\n",
+		`handoff_agent_instances{agent="test-expert"} 0` + "\n",
+		`handoff_agent_load{agent="golang-expert"} 3` + "\n",
+		"handoff_active_agents 1\n",
+		`handoff_redis_pool_connections{state="idle"} 7` + "\n",
+		"handoff_redis_pool_hits_total 42\n",
+	} {
+		if !strings.Contains(text, want) {
+			t.Errorf("expected %q in:\n%s", want, text)
+		}
+	}
+	if strings.Contains(text, "handoff_completed_total{") || strings.Contains(text, "handoff_redis_requests_total") {
+		t.Errorf("expected no samples for unrecorded counters, got:\n%s", text)
+	}
+
+	// Every sample belongs to the family declared before it
+	family := ""
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "# TYPE ") {
+			family = strings.Fields(line)[2]
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, family) {
+			t.Errorf("sample %q outside its family %s", line, family)
+		}
+	}
+}
+
+func TestMetricsHandler(t *testing.T) {
+	handler := MetricsHandler(func(ctx context.Context) (*MetricsSnapshot, error) {
+		return &MetricsSnapshot{Queues: []QueueMetrics{{Agent: "golang-expert", Depth: 1}}}, nil
+	})
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != MetricsContentType {
+		t.Fatalf("unexpected response %d %s", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), `handoff_queue_depth{project="",agent="golang-expert"} 1`) {
+		t.Errorf("unexpected body:\n%s", rec.Body.String())
+	}
+
+	failing := MetricsHandler(func(ctx context.Context) (*MetricsSnapshot, error) {
+		return nil, errors.New("redis unavailable")
+	})
+	rec = httptest.NewRecorder()
+	failing.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 when collection fails, got %d", rec.Code)
+	}
+}
+
+func TestMetricsSummaryHandler(t *testing.T) {
+	high := MetricLabels{Project: "billing", Agent: "golang-expert", Priority: PriorityHigh}
+	low := MetricLabels{Project: "auth", Agent: "golang-expert", Priority: PriorityLow}
+	var queried time.Duration
+	handler := MetricsSummaryHandler(func(ctx context.Context, from, to time.Time) (*RollingMetrics, error) {
+		queried = to.Sub(from)
+		return &RollingMetrics{From: from, To: to, Series: []RollingSeries{
+			{Labels: low, Counts: map[HandoffEvent]int64{EventPublished: 2}},
+			{Labels: high, Counts: map[HandoffEvent]int64{EventPublished: 3, EventFailed: 1}},
+		}}, nil
+	})
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics/summary?window=15m&project=billing", nil))
+	if rec.Code != http.StatusOK || queried != 15*time.Minute {
+		t.Fatalf("unexpected response %d for a %s window", rec.Code, queried)
+	}
+	var summary MetricsSummary
+	if err := json.Unmarshal(rec.Body.Bytes(), &summary); err != nil {
+		t.Fatal(err)
+	}
+	if len(summary.Series) != 1 || summary.Series[0].Priority != PriorityHigh || summary.Total.Published != 3 || summary.Total.Failed != 1 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics/summary?window=soon", nil))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a bad window, got %d", rec.Code)
+	}
+}
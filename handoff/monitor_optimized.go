@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -16,33 +17,138 @@ type OptimizedHandoffMonitor struct {
 	redisManager *RedisManager
 	metrics      *HandoffMetrics
 	metricsMutex sync.RWMutex
+	window       time.Duration
+	recorder     MetricsRecorder
+	queues       []QueueMetrics
+	rolling      *RollingMetrics
+	sla          SLAPolicy
+	breaches     []WaitingBreach
+	agents       []AgentLiveness
+	history      []HistoryTier
 	alertRules   []AlertRule
+	conditions   map[string]*AlertCondition
+	trackers     map[string]*alertTracker
 	subscribers  map[string][]chan AlertEvent
 	subMutex     sync.RWMutex
+	subDrops     uint64
+	notifier     *AlertNotifier
+	health       HealthConfig
 }
 
+// DefaultMetricsWindow is the period the monitor's counts and percentiles cover by default
+const DefaultMetricsWindow = time.Hour
+
 // NewOptimizedHandoffMonitor creates a new optimized handoff monitor
 func NewOptimizedHandoffMonitor(redisManager *RedisManager) *OptimizedHandoffMonitor {
 	return &OptimizedHandoffMonitor{
 		redisManager: redisManager,
 		metrics:      &HandoffMetrics{LastUpdated: time.Now()},
+		window:       DefaultMetricsWindow,
+		recorder:     NewMetricsRecorder(DefaultMetricsRetention),
+		sla:          DefaultSLAPolicy(),
+		history:      DefaultHistoryTiers(),
 		alertRules:   make([]AlertRule, 0),
+		conditions:   make(map[string]*AlertCondition),
+		trackers:     make(map[string]*alertTracker),
 		subscribers:  make(map[string][]chan AlertEvent),
 	}
 }
 
-// AddAlertRule adds a new alert rule
-func (m *OptimizedHandoffMonitor) AddAlertRule(rule AlertRule) {
+// SetMetricsWindow sets the period the collected counts, failure rate and
+// processing time percentiles cover
+func (m *OptimizedHandoffMonitor) SetMetricsWindow(window time.Duration) {
+	m.metricsMutex.Lock()
+	defer m.metricsMutex.Unlock()
+	if window > 0 {
+		m.window = window
+	}
+}
+
+// SetMetricsRetention sets how long the per-minute metric buckets written by
+// RecordHandoffMetrics are kept
+func (m *OptimizedHandoffMonitor) SetMetricsRetention(retention time.Duration) {
+	m.recorder = NewMetricsRecorder(retention)
+}
+
+// SetSLAPolicy sets how long handoffs of each priority may wait in their queue.
+// Each collection records breaches on the handoffs still waiting past their
+// target and counts them in the queue_sla_breaches alert metric.
+// DefaultSLAPolicy applies otherwise; an empty policy disables the check.
+func (m *OptimizedHandoffMonitor) SetSLAPolicy(policy SLAPolicy) {
+	m.metricsMutex.Lock()
+	defer m.metricsMutex.Unlock()
+	m.sla = policy
+}
+
+// SetHistoryTiers sets the resolutions and retentions each collection's
+// metrics are kept at for QueryHistory. DefaultHistoryTiers applies
+// otherwise; no tiers disables the history.
+func (m *OptimizedHandoffMonitor) SetHistoryTiers(tiers []HistoryTier) {
+	m.metricsMutex.Lock()
+	defer m.metricsMutex.Unlock()
+	m.history = tiers
+}
+
+// SetHealthConfig sets the weights and thresholds the system health score
+// is calculated with
+func (m *OptimizedHandoffMonitor) SetHealthConfig(cfg HealthConfig) {
+	m.metricsMutex.Lock()
+	defer m.metricsMutex.Unlock()
+	m.health = cfg
+}
+
+// QueryHistory returns the recorded history of a metric
+func (m *OptimizedHandoffMonitor) QueryHistory(ctx context.Context, q HistoryQuery) (*HistoryResult, error) {
+	m.metricsMutex.RLock()
+	tiers := m.history
+	m.metricsMutex.RUnlock()
+	return QueryHistory(ctx, m.redisManager.GetClient(), tiers, q)
+}
+
+// AddAlertRule adds a new alert rule. Rules whose condition cannot be parsed
+// are refused.
+func (m *OptimizedHandoffMonitor) AddAlertRule(rule AlertRule) error {
+	condition, err := ConditionForRule(rule)
+	if err != nil {
+		log.Error().Err(err).Str("rule_name", rule.Name).Msg("Alert rule refused")
+		return err
+	}
+	
 	m.metricsMutex.Lock()
 	defer m.metricsMutex.Unlock()
 	
 	m.alertRules = append(m.alertRules, rule)
+	m.conditions[rule.Name] = condition
+	m.trackers[rule.Name] = newAlertTracker()
 	
 	log.Info().
 		Str("rule_name", rule.Name).
-		Str("type", string(rule.Type)).
-		Float64("threshold", rule.Threshold).
+		Str("condition", condition.String()).
+		Dur("duration", rule.Duration).
 		Msg("Alert rule added")
+	return nil
+}
+
+// SetNotifier delivers alerts to the notifier's sinks as well as to subscribers
+func (m *OptimizedHandoffMonitor) SetNotifier(notifier *AlertNotifier) {
+	m.subMutex.Lock()
+	defer m.subMutex.Unlock()
+	m.notifier = notifier
+}
+
+// AlertDeliveryStats returns the delivery counts of the notifier's sinks and,
+// as the "subscribers" entry, the alerts dropped because a subscriber's
+// channel was full
+func (m *OptimizedHandoffMonitor) AlertDeliveryStats() []AlertSinkStats {
+	m.subMutex.RLock()
+	notifier := m.notifier
+	m.subMutex.RUnlock()
+
+	var stats []AlertSinkStats
+	if notifier != nil {
+		stats = notifier.Stats()
+	}
+	return append(stats, AlertSinkStats{Sink: "subscribers", Dropped: atomic.LoadUint64(&m.subDrops)})
 }
 
 // SubscribeToAlerts subscribes to alert events of a specific type
@@ -127,218 +233,222 @@ func (m *OptimizedHandoffMonitor) collectOptimizedMetrics(ctx context.Context) e
 	
 	m.metrics.QueueDepth = totalQueueDepth
 	
-	// Get handoff counts from Redis counters using optimized operations
-	var totalHandoffs, completedHandoffs, failedHandoffs int64
-	
-	// Use pipeline for batch metric retrieval
-	pipe := client.Pipeline()
-	totalCmd := pipe.Get(ctx, "handoff:metrics:total")
-	completedCmd := pipe.Get(ctx, "handoff:metrics:completed")
-	failedCmd := pipe.Get(ctx, "handoff:metrics:failed")
-	activeAgentsCmd := pipe.SMembers(ctx, "handoff:active_agents")
-	processingTimesCmd := pipe.LRange(ctx, "handoff:processing_times", 0, 99)
-	
-	_, err = pipe.Exec(ctx)
-	if err != nil && err != redis.Nil {
-		log.Error().Err(err).Msg("Failed to execute metrics pipeline")
+	// Get handoff counts and processing times over the window from the
+	// per-minute buckets
+	now := time.Now()
+	rolling, err := QueryRollingMetrics(ctx, client, now.Add(-m.window), now)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to read rolling metrics")
+	} else {
+		applyRollingMetrics(m.metrics, rolling)
+		m.metrics.Window = m.window
+		m.rolling = rolling
 	}
 	
-	// Process results
-	if val, err := totalCmd.Int64(); err == nil {
-		totalHandoffs = val
-	}
-	if val, err := completedCmd.Int64(); err == nil {
-		completedHandoffs = val
-	}
-	if val, err := failedCmd.Int64(); err == nil {
-		failedHandoffs = val
+	// Get per-queue depths and ages for alert conditions
+	if queues, err := collectQueueMetrics(ctx, client, now); err != nil {
+		log.Error().Err(err).Msg("Failed to read queue metrics")
+	} else {
+		m.queues = queues
 	}
 	
-	m.metrics.TotalHandoffs = totalHandoffs
-	m.metrics.CompletedHandoffs = completedHandoffs
-	m.metrics.FailedHandoffs = failedHandoffs
+	// Find and record handoffs waiting past their SLA
+	if len(m.sla) > 0 {
+		m.breaches = m.checkSLAs(ctx, client, now)
+	}
 	
-	// Get active agents
-	if activeAgents, err := activeAgentsCmd.Result(); err == nil {
-		m.metrics.ActiveAgents = activeAgents
+	// Get the registered agents and their live instances
+	if agents, err := CollectAgentLiveness(ctx, client, now); err == nil {
+		m.agents = agents
+		m.metrics.ActiveAgents = liveAgents(agents)
 	} else {
+		log.Error().Err(err).Msg("Failed to read agent heartbeats")
 		m.metrics.ActiveAgents = []string{}
 	}
 	
-	// Calculate average processing time from recent handoffs
-	if processingTimes, err := processingTimesCmd.Result(); err == nil && len(processingTimes) > 0 {
-		var totalTime time.Duration
-		validTimes := 0
-		
-		for _, timeStr := range processingTimes {
-			if duration, err := time.ParseDuration(timeStr); err == nil {
-				totalTime += duration
-				validTimes++
-			}
-		}
-		
-		if validTimes > 0 {
-			m.metrics.AvgProcessingTime = totalTime / time.Duration(validTimes)
-		}
-	}
-	
-	m.metrics.LastUpdated = time.Now()
+	m.metrics.LastUpdated = now
 	
 	// Store metrics snapshot in Redis for persistence using optimized operations
 	if err := m.redisManager.SetWithOptimizedExpiry(ctx, "handoff:metrics:snapshot", m.metrics, time.Hour); err != nil {
 		log.Error().Err(err).Msg("Failed to store metrics snapshot")
 	}
 	
+	// Add the collection to the metric history
+	src := &alertSource{
+		metrics:      m.metrics,
+		queues:       m.queues,
+		rolling:      m.rolling,
+		breaches:     m.breaches,
+		agents:       m.agents,
+		systemHealth: m.calculateSystemHealthScore(),
+	}
+	if err := RecordHistory(ctx, client, m.history, now, historySamples(src)); err != nil {
+		log.Error().Err(err).Msg("Failed to record metric history")
+	}
+	
 	return nil
 }
 
-// evaluateAlerts evaluates all alert rules
+// checkSLAs returns the handoffs waiting past their SLA, recording the breach
+// on those that do not carry it yet
+func (m *OptimizedHandoffMonitor) checkSLAs(ctx context.Context, client *redis.Client, now time.Time) []WaitingBreach {
+	breaches, err := FindSLABreaches(ctx, client, m.sla, now)
+	if err != nil {
+		log.Error().Err(err).Msg("Failed to check queue SLAs")
+		return m.breaches
+	}
+	
+	for _, b := range breaches {
+		if b.Recorded {
+			continue
+		}
+		first, err := RecordSLABreach(ctx, client, b.HandoffID, b.Breach)
+		if err != nil {
+			log.Error().Err(err).Str("handoff_id", b.HandoffID).Msg("Failed to record SLA breach")
+			continue
+		}
+		if !first {
+			continue
+		}
+		m.recorder.RecordEvent(ctx, client, EventSLABreached, b.Labels)
+		log.Warn().
+			Str("handoff_id", b.HandoffID).
+			Str("queue", b.Queue).
+			Str("priority", string(b.Breach.Priority)).
+			Dur("waited", b.Breach.Waited).
+			Dur("target", b.Breach.Target).
+			Msg("Handoff waiting past its SLA")
+	}
+	return breaches
+}
+
+// evaluateAlerts evaluates all alert rules and sends an alert for each label
+// set whose condition starts firing, keeps firing past the rule's cooldown or
+// resolves
 func (m *OptimizedHandoffMonitor) evaluateAlerts() {
-	m.metricsMutex.RLock()
-	defer m.metricsMutex.RUnlock()
+	m.metricsMutex.Lock()
+	defer m.metricsMutex.Unlock()
+	
+	src := &alertSource{
+		metrics:      m.metrics,
+		queues:       m.queues,
+		rolling:      m.rolling,
+		breaches:     m.breaches,
+		agents:       m.agents,
+		systemHealth: m.calculateSystemHealthScore(),
+	}
+	now := time.Now()
 	
 	for i, rule := range m.alertRules {
 		if !rule.Enabled {
 			continue
 		}
-		
-		// Check cooldown
-		if time.Since(rule.LastFired) < rule.Cooldown {
+		condition := m.conditions[rule.Name]
+		if condition == nil {
 			continue
 		}
 		
-		value, triggered := m.evaluateAlertRule(rule)
-		if triggered {
-			alert := AlertEvent{
-				Rule:      rule,
-				Value:     value,
-				Timestamp: time.Now(),
-				Message:   m.generateAlertMessage(rule, value),
-				Severity:  m.calculateSeverity(rule, value),
+		for _, transition := range m.trackers[rule.Name].observe(rule, condition.evaluate(src), now) {
+			alert := m.alertEvent(rule, condition, transition, now)
+			if transition.state == AlertFiring {
+				m.alertRules[i].LastFired = now
 			}
 			
-			// Update last fired time
-			m.alertRules[i].LastFired = time.Now()
-			
 			// Send alert to subscribers
-			m.sendAlert(rule.Type, alert)
+			m.sendAlert(alert.Rule.Type, alert)
 			
 			log.Warn().
 				Str("rule", rule.Name).
-				Float64("value", value).
-				Float64("threshold", rule.Threshold).
+				Str("state", string(alert.State)).
+				Str("labels", formatLabels(alert.Labels)).
+				Float64("value", alert.Value).
+				Str("condition", condition.String()).
 				Str("severity", string(alert.Severity)).
-				Msg("Alert triggered")
+				Msg("Alert " + string(alert.State))
 		}
 	}
 }
 
-// evaluateAlertRule evaluates a single alert rule
-func (m *OptimizedHandoffMonitor) evaluateAlertRule(rule AlertRule) (float64, bool) {
-	var value float64
-	
-	switch rule.Type {
-	case AlertQueueDepth:
-		value = float64(m.metrics.QueueDepth)
-	case AlertProcessingTime:
-		value = float64(m.metrics.AvgProcessingTime / time.Millisecond)
-	case AlertFailureRate:
-		if m.metrics.TotalHandoffs > 0 {
-			value = float64(m.metrics.FailedHandoffs) / float64(m.metrics.TotalHandoffs) * 100
-		}
-	case AlertAgentHealth:
-		value = float64(len(m.metrics.ActiveAgents))
-	case AlertSystemHealth:
-		// System health score based on multiple factors including Redis health
-		value = m.calculateSystemHealthScore()
-	default:
-		return 0, false
-	}
-	
-	return value, m.checkThreshold(rule, value)
-}
-
-// calculateSystemHealthScore calculates an overall system health score including Redis metrics
-func (m *OptimizedHandoffMonitor) calculateSystemHealthScore() float64 {
-	score := 100.0
-	
-	// Deduct points for high queue depth
-	if m.metrics.QueueDepth > 50 {
-		score -= float64(m.metrics.QueueDepth-50) * 0.5
-	}
-	
-	// Deduct points for high failure rate
-	if m.metrics.TotalHandoffs > 0 {
-		failureRate := float64(m.metrics.FailedHandoffs) / float64(m.metrics.TotalHandoffs) * 100
-		if failureRate > 5 {
-			score -= (failureRate - 5) * 2
-		}
-	}
-	
-	// Deduct points for slow processing
-	avgProcessingMs := float64(m.metrics.AvgProcessingTime / time.Millisecond)
-	if avgProcessingMs > 5000 { // 5 seconds
-		score -= (avgProcessingMs - 5000) * 0.01
+// alertEvent describes a firing or resolved alert. Rules given as an
+// expression take their type from the metric and their threshold from the
+// condition, so messages, severities and subscriptions work as for typed rules.
+func (m *OptimizedHandoffMonitor) alertEvent(rule AlertRule, condition *AlertCondition, transition alertTransition, now time.Time) AlertEvent {
+	if legacyType := alertMetrics[condition.Metric].legacyType; legacyType != "" {
+		rule.Type = legacyType
+	} else {
+		rule.Type = AlertType(condition.Metric)
 	}
+	rule.Threshold = condition.Threshold
 	
-	// Deduct points for inactive agents
-	if len(m.metrics.ActiveAgents) == 0 {
-		score -= 50
+	value := transition.status.Value
+	message := m.generateAlertMessage(rule, value)
+	if labels := formatLabels(transition.status.Labels); labels != "" {
+		message += " for " + labels
 	}
-	
-	// Factor in Redis health
-	if !m.redisManager.IsHealthy() {
-		score -= 30 // Major deduction for unhealthy Redis
+	severity := m.calculateSeverity(rule, value)
+	if transition.state == AlertResolved {
+		message = "Resolved: " + message
+		severity = SeverityInfo
 	}
 	
-	// Factor in Redis connection pool metrics
-	redisMetrics := m.redisManager.GetDetailedMetrics()
-	
-	// Deduct points for high connection pool usage
-	if redisMetrics.TotalConns > 0 {
-		poolUsage := float64(redisMetrics.TotalConns-redisMetrics.IdleConns) / float64(redisMetrics.TotalConns) * 100
-		if poolUsage > 80 {
-			score -= (poolUsage - 80) * 0.5
-		}
+	return AlertEvent{
+		Rule:      rule,
+		Value:     value,
+		Timestamp: now,
+		Message:   message,
+		Severity:  severity,
+		State:     transition.state,
+		Labels:    transition.status.Labels,
+		Since:     transition.status.Since,
 	}
+}
+
+// ActiveAlerts returns the pending and firing alerts of every rule
+func (m *OptimizedHandoffMonitor) ActiveAlerts() []AlertStatus {
+	m.metricsMutex.RLock()
+	defer m.metricsMutex.RUnlock()
 	
-	// Deduct points for high failure rate in Redis operations
-	if redisMetrics.TotalRequests > 0 {
-		redisFailureRate := float64(redisMetrics.FailedRequests) / float64(redisMetrics.TotalRequests) * 100
-		if redisFailureRate > 1 {
-			score -= (redisFailureRate - 1) * 5
+	var statuses []AlertStatus
+	for _, rule := range m.alertRules {
+		if tracker := m.trackers[rule.Name]; tracker != nil {
+			statuses = append(statuses, tracker.statuses()...)
 		}
 	}
-	
-	// Deduct points for high Redis latency
-	avgLatencyMs := float64(redisMetrics.AvgLatency / time.Millisecond)
-	if avgLatencyMs > 50 {
-		score -= (avgLatencyMs - 50) * 0.1
-	}
-	
-	if score < 0 {
-		score = 0
-	}
-	
-	return score
+	return statuses
 }
 
-// checkThreshold checks if the value crosses the threshold
-func (m *OptimizedHandoffMonitor) checkThreshold(rule AlertRule, value float64) bool {
-	switch rule.Condition {
-	case "greater_than", ">":
-		return value > rule.Threshold
-	case "less_than", "<":
-		return value < rule.Threshold
-	case "equals", "=":
-		return value == rule.Threshold
-	case "greater_equal", ">=":
-		return value >= rule.Threshold
-	case "less_equal", "<=":
-		return value <= rule.Threshold
-	default:
-		return value > rule.Threshold // Default behavior
+// calculateSystemHealthScore scores the collected metrics and Redis state
+// against the health configuration
+func (m *OptimizedHandoffMonitor) calculateSystemHealthScore() float64 {
+	return m.healthReport().Score
+}
+
+// HealthReport returns the system health score with each component's
+// contribution, from the latest collection
+func (m *OptimizedHandoffMonitor) HealthReport() HealthReport {
+	m.metricsMutex.RLock()
+	defer m.metricsMutex.RUnlock()
+	return m.healthReport()
+}
+
+func (m *OptimizedHandoffMonitor) healthReport() HealthReport {
+	failureRate := 0.0
+	if m.metrics.TotalHandoffs > 0 {
+		failureRate = float64(m.metrics.FailedHandoffs) / float64(m.metrics.TotalHandoffs) * 100
 	}
+	redisUp := 0.0
+	if m.redisManager.IsHealthy() {
+		redisUp = 1
+	}
+	readings := []HealthReading{
+		{Component: HealthRedis, Value: redisUp},
+		{Component: HealthQueueBacklog, Value: float64(m.metrics.QueueDepth)},
+		{Component: HealthFailureRate, Value: failureRate},
+		{Component: HealthLatency, Value: m.metrics.ProcessingP95.Seconds()},
+		{Component: HealthPoolUsage, Value: poolUsage(m.redisManager.GetDetailedMetrics())},
+		{Component: HealthActiveAgents, Value: float64(len(m.metrics.ActiveAgents))},
+	}
+	return EvaluateHealth(m.health, readings, time.Now())
 }
 
 // generateAlertMessage generates a human-readable alert message
@@ -346,16 +456,18 @@ func (m *OptimizedHandoffMonitor) generateAlertMessage(rule AlertRule, value flo
 	switch rule.Type {
 	case AlertQueueDepth:
 		return fmt.Sprintf("Queue depth is %.0f (threshold: %.0f)", value, rule.Threshold)
+	case AlertQueueAge:
+		return fmt.Sprintf("Oldest queued handoff has waited %.0fs (threshold: %.0fs)", value, rule.Threshold)
 	case AlertProcessingTime:
 		return fmt.Sprintf("Average processing time is %.0fms (threshold: %.0fms)", value, rule.Threshold)
 	case AlertFailureRate:
 		return fmt.Sprintf("Failure rate is %.1f%% (threshold: %.1f%%)", value, rule.Threshold)
 	case AlertAgentHealth:
-		return fmt.Sprintf("Active agents: %.0f (threshold: %.0f)", value, rule.Threshold)
+		return fmt.Sprintf("Registered agents without a live consumer: %.0f (threshold: %.0f)", value, rule.Threshold)
 	case AlertSystemHealth:
 		return fmt.Sprintf("System health score is %.1f (threshold: %.1f)", value, rule.Threshold)
 	default:
-		return fmt.Sprintf("Alert %s triggered: %.2f", rule.Name, value)
+		return fmt.Sprintf("Alert %s triggered: %s is %.2f", rule.Name, rule.Type, value)
 	}
 }
 
@@ -364,7 +476,7 @@ func (m *OptimizedHandoffMonitor) calculateSeverity(rule AlertRule, value float6
 	ratio := value / rule.Threshold
 	
 	switch rule.Type {
-	case AlertQueueDepth:
+	case AlertQueueDepth, AlertQueueAge:
 		if ratio >= 3.0 {
 			return SeverityCritical
 		} else if ratio >= 2.0 {
@@ -401,11 +513,15 @@ func (m *OptimizedHandoffMonitor) calculateSeverity(rule AlertRule, value float6
 	}
 }
 
-// sendAlert sends an alert to all subscribers
+// sendAlert sends an alert to the notifier and all subscribers
 func (m *OptimizedHandoffMonitor) sendAlert(alertType AlertType, alert AlertEvent) {
 	m.subMutex.RLock()
 	defer m.subMutex.RUnlock()
 	
+	if m.notifier != nil {
+		m.notifier.Notify(alert)
+	}
+	
 	typeStr := string(alertType)
 	subscribers := m.subscribers[typeStr]
 	
@@ -414,6 +530,7 @@ func (m *OptimizedHandoffMonitor) sendAlert(alertType AlertType, alert AlertEven
 		case ch <- alert:
 		default:
 			// Channel is full, log warning
+			atomic.AddUint64(&m.subDrops, 1)
 			log.Warn().
 				Str("alert_type", typeStr).
 				Msg("Alert channel is full, dropping alert")
@@ -426,6 +543,7 @@ func (m *OptimizedHandoffMonitor) sendAlert(alertType AlertType, alert AlertEven
 		select {
 		case ch <- alert:
 		default:
+			atomic.AddUint64(&m.subDrops, 1)
 			log.Warn().Msg("All alerts channel is full, dropping alert")
 		}
 	}
@@ -467,7 +585,13 @@ func (m *OptimizedHandoffMonitor) UpdateAlertRule(name string, rule AlertRule) e
 	for i, existingRule := range m.alertRules {
 		if existingRule.Name == name {
 			rule.Name = name // Ensure name doesn't change
+			condition, err := ConditionForRule(rule)
+			if err != nil {
+				return err
+			}
 			m.alertRules[i] = rule
+			m.conditions[name] = condition
+			m.trackers[name] = newAlertTracker()
 			log.Info().Str("rule_name", name).Msg("Alert rule updated")
 			return nil
 		}
@@ -485,6 +609,8 @@ func (m *OptimizedHandoffMonitor) RemoveAlertRule(name string) error {
 		if rule.Name == name {
 			// Remove rule by slicing
 			m.alertRules = append(m.alertRules[:i], m.alertRules[i+1:]...)
+			delete(m.conditions, name)
+			delete(m.trackers, name)
 			log.Info().Str("rule_name", name).Msg("Alert rule removed")
 			return nil
 		}
@@ -498,25 +624,16 @@ func (m *OptimizedHandoffMonitor) RecordHandoffMetrics(ctx context.Context, hand
 	// Use optimized batch operations for recording metrics
 	operations := []func(redis.Pipeliner) error{
 		func(pipe redis.Pipeliner) error {
-			pipe.Incr(ctx, "handoff:metrics:total")
 			if success {
-				pipe.Incr(ctx, "handoff:metrics:completed")
+				m.recorder.RecordEvent(ctx, pipe, EventCompleted, LabelsOf(handoff))
 			} else {
-				pipe.Incr(ctx, "handoff:metrics:failed")
+				m.recorder.RecordEvent(ctx, pipe, EventFailed, LabelsOf(handoff))
 			}
 			return nil
 		},
 		func(pipe redis.Pipeliner) error {
 			// Record processing time
-			pipe.LPush(ctx, "handoff:processing_times", processingTime.String())
-			pipe.LTrim(ctx, "handoff:processing_times", 0, 99) // Keep last 100 processing times
-			return nil
-		},
-		func(pipe redis.Pipeliner) error {
-			// Set expiry on counters (they'll be recreated if needed)
-			pipe.Expire(ctx, "handoff:metrics:total", 24*time.Hour)
-			pipe.Expire(ctx, "handoff:metrics:completed", 24*time.Hour)
-			pipe.Expire(ctx, "handoff:metrics:failed", 24*time.Hour)
+			m.recorder.RecordProcessingTime(ctx, pipe, LabelsOf(handoff), processingTime)
 			return nil
 		},
 	}
@@ -526,25 +643,21 @@ func (m *OptimizedHandoffMonitor) RecordHandoffMetrics(ctx context.Context, hand
 	}
 }
 
-// SetAgentActive marks an agent as active using optimized operations
+// SetAgentActive sends a heartbeat for this process's instance of an agent,
+// which counts as live for three DefaultHeartbeatIntervals. Consumers send
+// their own heartbeats; this is for agents consuming by other means.
 func (m *OptimizedHandoffMonitor) SetAgentActive(ctx context.Context, agentName string) {
-	operations := []func(redis.Pipeliner) error{
-		func(pipe redis.Pipeliner) error {
-			pipe.SAdd(ctx, "handoff:active_agents", agentName)
-			pipe.Expire(ctx, "handoff:active_agents", 5*time.Minute) // Expire after 5 minutes of inactivity
-			return nil
-		},
-	}
-	
-	if err := m.redisManager.ExecuteBatch(ctx, operations); err != nil {
+	heartbeater := NewHeartbeater(m.redisManager.GetClient(), HeartbeatConfig{})
+	heartbeater.Track(agentName, 0, nil)
+	if err := heartbeater.Beat(ctx); err != nil {
 		log.Error().Err(err).Str("agent", agentName).Msg("Failed to mark agent as active")
 	}
 }
 
-// SetAgentInactive removes an agent from the active list
+// SetAgentInactive removes the heartbeat of this process's instance of an agent
 func (m *OptimizedHandoffMonitor) SetAgentInactive(ctx context.Context, agentName string) {
-	client := m.redisManager.GetClient()
-	if err := client.SRem(ctx, "handoff:active_agents", agentName).Err(); err != nil {
+	heartbeater := NewHeartbeater(m.redisManager.GetClient(), HeartbeatConfig{})
+	if err := heartbeater.Untrack(ctx, agentName); err != nil {
 		log.Error().Err(err).Str("agent", agentName).Msg("Failed to mark agent as inactive")
 	}
 }
@@ -571,7 +684,7 @@ func (m *OptimizedHandoffMonitor) GetQueueStatus(ctx context.Context) (map[strin
 	
 	for _, key := range keys {
 		cardCmds[key] = pipe.ZCard(ctx, key)
-		rangeCmds[key] = pipe.ZRangeWithScores(ctx, key, 0, 0)
+		rangeCmds[key] = pipe.ZRangeWithScores(ctx, EnqueuedKey(key), 0, 0)
 	}
 	
 	_, err = pipe.Exec(ctx)
@@ -595,9 +708,8 @@ func (m *OptimizedHandoffMonitor) GetQueueStatus(ctx context.Context) (map[strin
 		if depth > 0 {
 			if cmd, exists := rangeCmds[key]; exists {
 				if items, err := cmd.Result(); err == nil && len(items) > 0 {
-					// Score contains timestamp as fractional part
-					score := items[0].Score
-					oldestTimestamp = time.Unix(int64(score), int64((score-float64(int64(score)))*1e9))
+					// Scores are enqueue times in Unix milliseconds
+					oldestTimestamp = time.UnixMilli(int64(items[0].Score))
 				}
 			}
 		}
@@ -0,0 +1,476 @@
+package handoff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rs/zerolog/log"
+)
+
+// Alert sink types
+const (
+	SinkWebhook     = "webhook"      // JSON POST to URL
+	SinkExec        = "exec"         // Run Command with the alert as JSON on stdin
+	SinkFile        = "file"         // Append JSON lines to Path
+	SinkRedisStream = "redis_stream" // XADD to Stream
+)
+
+// DefaultAlertStream is the Redis stream alerts are added to when a
+// redis_stream sink names none
+const DefaultAlertStream = "handoff:alerts"
+
+// DefaultAlertTemplate renders the text of a notification
+const DefaultAlertTemplate = `[{{.Severity}}] {{.Rule.Name}} {{.State}}: {{.Message}}`
+
+// AlertNotifierConfig configures where alerts are delivered. Rules choose
+// sinks by name with AlertRule.Sinks; rules that name none use DefaultSinks.
+type AlertNotifierConfig struct {
+	Sinks        []AlertSinkConfig `json:"sinks"`
+	DefaultSinks []string          `json:"default_sinks,omitempty"`
+	QueueSize    int               `json:"queue_size,omitempty"` // Alerts waiting per sink before new ones are dropped (default 100)
+}
+
+// AlertSinkConfig configures one alert sink
+type AlertSinkConfig struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	URL        string            `json:"url,omitempty"`     // webhook
+	Headers    map[string]string `json:"headers,omitempty"` // webhook
+	Command    []string          `json:"command,omitempty"` // exec: program and arguments
+	Path       string            `json:"path,omitempty"`    // file
+	Stream     string            `json:"stream,omitempty"`  // redis_stream
+	MaxLen     int64             `json:"max_len,omitempty"` // redis_stream: approximate cap on entries
+	Template   string            `json:"template,omitempty"`
+	Retries    int               `json:"retries,omitempty"`     // Further attempts after a failed delivery
+	RetryDelay time.Duration     `json:"retry_delay,omitempty"` // Before the first retry, doubling after each (default 1s)
+	Timeout    time.Duration     `json:"timeout,omitempty"`     // Per attempt (default 10s)
+}
+
+// AlertNotification is what sinks deliver: the alert and its rendered text
+type AlertNotification struct {
+	Text  string     `json:"text"`
+	Alert AlertEvent `json:"alert"`
+}
+
+// AlertSink delivers notifications outside the process
+type AlertSink interface {
+	Send(ctx context.Context, notification AlertNotification) error
+}
+
+// AlertSinkStats counts the deliveries of one sink
+type AlertSinkStats struct {
+	Sink        string    `json:"sink"`
+	Delivered   uint64    `json:"delivered"`
+	Failed      uint64    `json:"failed"`  // Given up after every retry
+	Dropped     uint64    `json:"dropped"` // Not attempted because the sink's queue was full
+	LastError   string    `json:"last_error,omitempty"`
+	LastFailure time.Time `json:"last_failure,omitempty"`
+}
+
+// permanentError marks a delivery failure retrying will not fix
+type permanentError struct{ error }
+
+func (e permanentError) Unwrap() error { return e.error }
+
+// AlertNotifier delivers alerts to sinks in the background, each sink with its
+// own queue so a slow sink does not hold up the others
+type AlertNotifier struct {
+	sinks    map[string]*notifierSink
+	defaults []string
+	wg       sync.WaitGroup
+	cancel   context.CancelFunc
+	ctx      context.Context
+
+	mu     sync.RWMutex // Held by Notify while queuing; Close takes it to close the queues
+	closed bool
+}
+
+type notifierSink struct {
+	name     string
+	sink     AlertSink
+	template *template.Template
+	retries  int
+	delay    time.Duration
+	timeout  time.Duration
+	queue    chan AlertEvent
+
+	mu    sync.Mutex
+	stats AlertSinkStats
+}
+
+// NewAlertNotifier creates the configured sinks and starts delivering. client
+// is used by redis_stream sinks and may be nil without them.
+func NewAlertNotifier(cfg AlertNotifierConfig, client redis.Cmdable) (*AlertNotifier, error) {
+	sinks := make(map[string]AlertSink, len(cfg.Sinks))
+	for _, sc := range cfg.Sinks {
+		sink, err := newAlertSink(sc, client)
+		if err != nil {
+			return nil, err
+		}
+		if _, exists := sinks[sc.Name]; exists {
+			return nil, fmt.Errorf("alert sink %s is configured twice", sc.Name)
+		}
+		sinks[sc.Name] = sink
+	}
+	return newAlertNotifier(cfg, sinks)
+}
+
+func newAlertNotifier(cfg AlertNotifierConfig, sinks map[string]AlertSink) (*AlertNotifier, error) {
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = 100
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	n := &AlertNotifier{sinks: make(map[string]*notifierSink), defaults: cfg.DefaultSinks, ctx: ctx, cancel: cancel}
+
+	configs := make(map[string]AlertSinkConfig, len(cfg.Sinks))
+	for _, sc := range cfg.Sinks {
+		configs[sc.Name] = sc
+	}
+	for name, sink := range sinks {
+		sc := configs[name]
+		text := sc.Template
+		if text == "" {
+			text = DefaultAlertTemplate
+		}
+		tmpl, err := template.New(name).Funcs(template.FuncMap{"labels": formatLabels}).Parse(text)
+		if err != nil {
+			cancel()
+			return nil, fmt.Errorf("alert sink %s: invalid template: %w", name, err)
+		}
+		ns := &notifierSink{
+			name:     name,
+			sink:     sink,
+			template: tmpl,
+			retries:  sc.Retries,
+			delay:    sc.RetryDelay,
+			timeout:  sc.Timeout,
+			queue:    make(chan AlertEvent, queueSize),
+			stats:    AlertSinkStats{Sink: name},
+		}
+		if ns.delay <= 0 {
+			ns.delay = time.Second
+		}
+		if ns.timeout <= 0 {
+			ns.timeout = 10 * time.Second
+		}
+		n.sinks[name] = ns
+	}
+	for _, name := range cfg.DefaultSinks {
+		if n.sinks[name] == nil {
+			cancel()
+			return nil, fmt.Errorf("default alert sink %s is not configured", name)
+		}
+	}
+
+	for _, ns := range n.sinks {
+		n.wg.Add(1)
+		go n.deliver(ns)
+	}
+	return n, nil
+}
+
+// CheckRules reports rules routed to sinks that are not configured
+func (n *AlertNotifier) CheckRules(rules []AlertRule) error {
+	for _, rule := range rules {
+		for _, name := range rule.Sinks {
+			if n.sinks[name] == nil {
+				return fmt.Errorf("alert rule %s: sink %s is not configured", rule.Name, name)
+			}
+		}
+	}
+	return nil
+}
+
+// Notify queues an alert for the sinks its rule is routed to. It never
+// blocks: an alert a sink has no room for is dropped and counted. Alerts
+// notified after Close are ignored.
+func (n *AlertNotifier) Notify(alert AlertEvent) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+	if n.closed {
+		return
+	}
+
+	names := alert.Rule.Sinks
+	if len(names) == 0 {
+		names = n.defaults
+	}
+	for _, name := range names {
+		ns := n.sinks[name]
+		if ns == nil {
+			continue
+		}
+		select {
+		case ns.queue <- alert:
+		default:
+			ns.mu.Lock()
+			ns.stats.Dropped++
+			ns.mu.Unlock()
+			log.Warn().Str("sink", name).Str("rule", alert.Rule.Name).Msg("Alert sink queue is full, dropping alert")
+		}
+	}
+}
+
+// Stats returns the delivery counts of every sink, ordered by name
+func (n *AlertNotifier) Stats() []AlertSinkStats {
+	stats := make([]AlertSinkStats, 0, len(n.sinks))
+	for _, ns := range n.sinks {
+		ns.mu.Lock()
+		stats = append(stats, ns.stats)
+		ns.mu.Unlock()
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Sink < stats[j].Sink })
+	return stats
+}
+
+// Close delivers the alerts already queued and stops the sinks. Retries still
+// waiting when ctx ends are abandoned and counted as failed.
+func (n *AlertNotifier) Close(ctx context.Context) {
+	n.mu.Lock()
+	if !n.closed {
+		n.closed = true
+		for _, ns := range n.sinks {
+			close(ns.queue)
+		}
+	}
+	n.mu.Unlock()
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+		n.cancel()
+		<-done
+	}
+	n.cancel()
+}
+
+func (n *AlertNotifier) deliver(ns *notifierSink) {
+	defer n.wg.Done()
+	for alert := range ns.queue {
+		err := ns.send(n.ctx, alert)
+		ns.mu.Lock()
+		if err != nil {
+			ns.stats.Failed++
+			ns.stats.LastError = err.Error()
+			ns.stats.LastFailure = time.Now()
+		} else {
+			ns.stats.Delivered++
+		}
+		ns.mu.Unlock()
+		if err != nil {
+			log.Error().Err(err).Str("sink", ns.name).Str("rule", alert.Rule.Name).Msg("Failed to deliver alert")
+		}
+	}
+}
+
+// send renders and delivers one alert, retrying with backoff
+func (ns *notifierSink) send(ctx context.Context, alert AlertEvent) error {
+	var text bytes.Buffer
+	if err := ns.template.Execute(&text, alert); err != nil {
+		return fmt.Errorf("failed to render alert: %w", err)
+	}
+	notification := AlertNotification{Text: text.String(), Alert: alert}
+
+	delay := ns.delay
+	var err error
+	attempts := 0
+	for {
+		attempts++
+		attemptCtx, cancel := context.WithTimeout(ctx, ns.timeout)
+		err = ns.sink.Send(attemptCtx, notification)
+		cancel()
+		var permanent permanentError
+		if err == nil || errors.As(err, &permanent) || attempts > ns.retries {
+			break
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("after %d attempts: %w (gave up on shutdown)", attempts, err)
+		case <-time.After(delay):
+		}
+		delay *= 2
+	}
+	if err != nil && attempts > 1 {
+		return fmt.Errorf("after %d attempts: %w", attempts, err)
+	}
+	return err
+}
+
+func newAlertSink(sc AlertSinkConfig, client redis.Cmdable) (AlertSink, error) {
+	if sc.Name == "" {
+		return nil, fmt.Errorf("alert sink of type %q has no name", sc.Type)
+	}
+	switch sc.Type {
+	case SinkWebhook:
+		if sc.URL == "" {
+			return nil, fmt.Errorf("alert sink %s: webhook needs a url", sc.Name)
+		}
+		return &webhookSink{url: sc.URL, headers: sc.Headers, client: &http.Client{}}, nil
+	case SinkExec:
+		if len(sc.Command) == 0 {
+			return nil, fmt.Errorf("alert sink %s: exec needs a command", sc.Name)
+		}
+		return &execSink{command: sc.Command}, nil
+	case SinkFile:
+		if sc.Path == "" {
+			return nil, fmt.Errorf("alert sink %s: file needs a path", sc.Name)
+		}
+		return &fileSink{path: sc.Path}, nil
+	case SinkRedisStream:
+		if client == nil {
+			return nil, fmt.Errorf("alert sink %s: redis_stream needs a Redis client", sc.Name)
+		}
+		stream := sc.Stream
+		if stream == "" {
+			stream = DefaultAlertStream
+		}
+		return &redisStreamSink{client: client, stream: stream, maxLen: sc.MaxLen}, nil
+	}
+	return nil, fmt.Errorf("alert sink %s: unknown type %q (expected webhook, exec, file or redis_stream)", sc.Name, sc.Type)
+}
+
+// webhookSink POSTs the notification as JSON. Client errors other than 429
+// are not retried.
+type webhookSink struct {
+	url     string
+	headers map[string]string
+	client  *http.Client
+}
+
+func (s *webhookSink) Send(ctx context.Context, notification AlertNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return permanentError{fmt.Errorf("failed to encode alert: %w", err)}
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{fmt.Errorf("invalid webhook request: %w", err)}
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range s.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("webhook returned %s", resp.Status)
+	if resp.StatusCode >= 400 && resp.StatusCode < 500 && resp.StatusCode != http.StatusTooManyRequests {
+		return permanentError{err}
+	}
+	return err
+}
+
+// execSink runs a command with the notification as JSON on stdin and the
+// main fields in ALERT_* environment variables
+type execSink struct {
+	command []string
+}
+
+func (s *execSink) Send(ctx context.Context, notification AlertNotification) error {
+	body, err := json.Marshal(notification)
+	if err != nil {
+		return permanentError{fmt.Errorf("failed to encode alert: %w", err)}
+	}
+	alert := notification.Alert
+	cmd := exec.CommandContext(ctx, s.command[0], s.command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"ALERT_RULE="+alert.Rule.Name,
+		"ALERT_STATE="+string(alert.State),
+		"ALERT_SEVERITY="+string(alert.Severity),
+		"ALERT_VALUE="+formatFloat(alert.Value),
+		"ALERT_LABELS="+formatLabels(alert.Labels),
+		"ALERT_TEXT="+notification.Text,
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if detail := strings.TrimSpace(stderr.String()); detail != "" {
+			return fmt.Errorf("alert command failed: %w: %s", err, detail)
+		}
+		return fmt.Errorf("alert command failed: %w", err)
+	}
+	return nil
+}
+
+// fileSink appends one JSON notification per line
+type fileSink struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (s *fileSink) Send(ctx context.Context, notification AlertNotification) error {
+	line, err := json.Marshal(notification)
+	if err != nil {
+		return permanentError{fmt.Errorf("failed to encode alert: %w", err)}
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	f, err := os.OpenFile(s.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open alert file: %w", err)
+	}
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to append alert: %w", err)
+	}
+	return f.Close()
+}
+
+// redisStreamSink adds each notification to a Redis stream
+type redisStreamSink struct {
+	client redis.Cmdable
+	stream string
+	maxLen int64
+}
+
+func (s *redisStreamSink) Send(ctx context.Context, notification AlertNotification) error {
+	alert, err := json.Marshal(notification.Alert)
+	if err != nil {
+		return permanentError{fmt.Errorf("failed to encode alert: %w", err)}
+	}
+	args := &redis.XAddArgs{
+		Stream: s.stream,
+		Values: map[string]interface{}{
+			"rule":     notification.Alert.Rule.Name,
+			"state":    string(notification.Alert.State),
+			"severity": string(notification.Alert.Severity),
+			"text":     notification.Text,
+			"alert":    alert,
+		},
+	}
+	if s.maxLen > 0 {
+		args.MaxLen = s.maxLen
+		args.Approx = true
+	}
+	if err := s.client.XAdd(ctx, args).Err(); err != nil {
+		return fmt.Errorf("failed to add alert to %s: %w", s.stream, err)
+	}
+	return nil
+}
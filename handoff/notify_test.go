@@ -0,0 +1,250 @@
+package handoff
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func testAlert(rule string, sinks ...string) AlertEvent {
+	return AlertEvent{
+		Rule:     AlertRule{Name: rule, Sinks: sinks},
+		Value:    1200,
+		Message:  "Oldest handoff has waited 1200s",
+		Severity: SeverityWarning,
+		State:    AlertFiring,
+		Labels:   map[string]string{"agent": "golang-expert"},
+	}
+}
+
+func closeNotifier(t *testing.T, n *AlertNotifier) {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	n.Close(ctx)
+}
+
+func TestWebhookSinkRetries(t *testing.T) {
+	var calls int32
+	var received AlertNotification
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	n, err := NewAlertNotifier(AlertNotifierConfig{
+		Sinks: []AlertSinkConfig{{
+			Name: "ops", Type: SinkWebhook, URL: server.URL,
+			Headers: map[string]string{"Authorization": "Bearer token"},
+			Retries: 3, RetryDelay: time.Millisecond,
+		}},
+		DefaultSinks: []string{"ops"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Notify(testAlert("stale-queue"))
+	closeNotifier(t, n)
+
+	if calls != 3 {
+		t.Errorf("expected 3 attempts, got %d", calls)
+	}
+	if received.Text != "[warning] stale-queue firing: Oldest handoff has waited 1200s" || received.Alert.Labels["agent"] != "golang-expert" {
+		t.Errorf("unexpected notification %+v", received)
+	}
+	if stats := n.Stats(); len(stats) != 1 || stats[0].Delivered != 1 || stats[0].Failed != 0 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+}
+
+func TestWebhookSinkClientErrorIsNotRetried(t *testing.T) {
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		http.Error(w, "bad request", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	n, err := NewAlertNotifier(AlertNotifierConfig{
+		Sinks:        []AlertSinkConfig{{Name: "ops", Type: SinkWebhook, URL: server.URL, Retries: 3, RetryDelay: time.Millisecond}},
+		DefaultSinks: []string{"ops"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Notify(testAlert("stale-queue"))
+	closeNotifier(t, n)
+
+	if calls != 1 {
+		t.Errorf("expected a single attempt, got %d", calls)
+	}
+	stats := n.Stats()
+	if stats[0].Failed != 1 || !strings.Contains(stats[0].LastError, "400") || stats[0].LastFailure.IsZero() {
+		t.Errorf("expected a recorded failure, got %+v", stats[0])
+	}
+	if strings.Contains(stats[0].LastError, "attempts") {
+		t.Errorf("expected no retry count for a single attempt, got %q", stats[0].LastError)
+	}
+}
+
+func TestAlertNotifierCloseWhileNotifying(t *testing.T) {
+	n, err := newAlertNotifier(AlertNotifierConfig{DefaultSinks: []string{"file"}}, map[string]AlertSink{
+		"file": &fileSink{path: filepath.Join(t.TempDir(), "alerts.jsonl")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 200; j++ {
+				n.Notify(testAlert("stale-queue"))
+			}
+		}()
+	}
+	closeNotifier(t, n)
+	closeNotifier(t, n)
+	wg.Wait()
+
+	// Alerts notified after Close are ignored rather than sent on a closed queue
+	n.Notify(testAlert("stale-queue"))
+}
+
+func TestFileAndExecSinks(t *testing.T) {
+	dir := t.TempDir()
+	alerts := filepath.Join(dir, "alerts.jsonl")
+	execOut := filepath.Join(dir, "exec.txt")
+
+	n, err := NewAlertNotifier(AlertNotifierConfig{
+		Sinks: []AlertSinkConfig{
+			{Name: "log", Type: SinkFile, Path: alerts, Template: `{{.Rule.Name}}{{labels .Labels}}={{.Value}}`},
+			{Name: "script", Type: SinkExec, Command: []string{"sh", "-c", `echo "$ALERT_RULE $ALERT_STATE $ALERT_LABELS" > "$1"; cat >> "$1"`, "sh", execOut}},
+		},
+		DefaultSinks: []string{"log"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n.Notify(testAlert("stale-queue"))
+	n.Notify(testAlert("high-failure-rate", "log", "script"))
+	closeNotifier(t, n)
+
+	f, err := os.Open(alerts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var texts []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var notification AlertNotification
+		if err := json.Unmarshal(scanner.Bytes(), &notification); err != nil {
+			t.Fatal(err)
+		}
+		texts = append(texts, notification.Text)
+	}
+	if strings.Join(texts, "|") != `stale-queue{agent="golang-expert"}=1200|high-failure-rate{agent="golang-expert"}=1200` {
+		t.Errorf("unexpected file contents %q", texts)
+	}
+
+	out, err := os.ReadFile(execOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(out), "high-failure-rate firing {agent=\"golang-expert\"}\n{") {
+		t.Errorf("unexpected command output %q", out)
+	}
+}
+
+func TestAlertNotifierConfig(t *testing.T) {
+	tests := []struct {
+		cfg     AlertNotifierConfig
+		wantErr string
+	}{
+		{cfg: AlertNotifierConfig{Sinks: []AlertSinkConfig{{Name: "ops", Type: "pager"}}}, wantErr: `unknown type "pager"`},
+		{cfg: AlertNotifierConfig{Sinks: []AlertSinkConfig{{Name: "ops", Type: SinkWebhook}}}, wantErr: "needs a url"},
+		{cfg: AlertNotifierConfig{Sinks: []AlertSinkConfig{{Name: "ops", Type: SinkRedisStream}}}, wantErr: "needs a Redis client"},
+		{cfg: AlertNotifierConfig{Sinks: []AlertSinkConfig{{Name: "ops", Type: SinkFile, Path: "a"}, {Name: "ops", Type: SinkFile, Path: "b"}}}, wantErr: "configured twice"},
+		{cfg: AlertNotifierConfig{Sinks: []AlertSinkConfig{{Name: "ops", Type: SinkFile, Path: "a", Template: "{{.Nope"}}}, wantErr: "invalid template"},
+		{cfg: AlertNotifierConfig{DefaultSinks: []string{"ops"}}, wantErr: "default alert sink ops is not configured"},
+	}
+	for _, tt := range tests {
+		if _, err := NewAlertNotifier(tt.cfg, nil); err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+			t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+		}
+	}
+
+	n, err := NewAlertNotifier(AlertNotifierConfig{Sinks: []AlertSinkConfig{{Name: "log", Type: SinkFile, Path: filepath.Join(t.TempDir(), "a")}}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer closeNotifier(t, n)
+	if err := n.CheckRules([]AlertRule{{Name: "ok", Sinks: []string{"log"}}, {Name: "typo", Sinks: []string{"slack"}}}); err == nil || !strings.Contains(err.Error(), "alert rule typo: sink slack") {
+		t.Errorf("expected an unknown sink to be refused, got %v", err)
+	}
+}
+
+// blockingSink holds every delivery until released
+type blockingSink struct{ release chan struct{} }
+
+func (s blockingSink) Send(ctx context.Context, notification AlertNotification) error {
+	<-s.release
+	return nil
+}
+
+func TestAlertNotifierDropsWhenFull(t *testing.T) {
+	sink := blockingSink{release: make(chan struct{})}
+	n, err := newAlertNotifier(AlertNotifierConfig{DefaultSinks: []string{"slow"}, QueueSize: 1}, map[string]AlertSink{"slow": sink})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		n.Notify(testAlert("stale-queue"))
+	}
+	close(sink.release)
+	closeNotifier(t, n)
+
+	stats := n.Stats()[0]
+	if stats.Delivered+stats.Dropped != 5 || stats.Dropped < 3 {
+		t.Errorf("expected full queue drops to be counted, got %+v", stats)
+	}
+}
+
+func TestAlertDeliveryPrometheus(t *testing.T) {
+	snapshot := &MetricsSnapshot{Alerts: []AlertSinkStats{{Sink: "ops", Delivered: 4, Failed: 1}, {Sink: "subscribers", Dropped: 2}}}
+	var out strings.Builder
+	if err := snapshot.WritePrometheus(&out); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"# TYPE handoff_alert_notifications_total counter",
+		`handoff_alert_notifications_total{sink="ops",result="delivered"} 4`,
+		`handoff_alert_notifications_total{sink="ops",result="failed"} 1`,
+		`handoff_alert_notifications_total{sink="subscribers",result="dropped"} 2`,
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("expected %q in\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+// PayloadLimits bounds the size of stored handoffs. Zero limits are not enforced.
+type PayloadLimits struct {
+	MaxBytes         int `json:"max_bytes"`         // Largest handoff stored, after offloading
+	OffloadThreshold int `json:"offload_threshold"` // technical_details fields larger than this are offloaded when a blob store is configured
+	MaxBlobBytes     int `json:"max_blob_bytes"`    // Largest offloaded field
+}
+
+// DefaultPayloadLimits returns the limits used when no validation policy sets them
+func DefaultPayloadLimits() PayloadLimits {
+	return PayloadLimits{
+		MaxBytes:         1 << 20,
+		OffloadThreshold: 64 << 10,
+		MaxBlobBytes:     16 << 20,
+	}
+}
+
+// Validate checks the limits themselves
+func (l PayloadLimits) Validate() error {
+	if l.MaxBytes < 0 || l.OffloadThreshold < 0 || l.MaxBlobBytes < 0 {
+		return fmt.Errorf("payload limits cannot be negative")
+	}
+	return nil
+}
+
+// ApplyPayloadLimits moves technical_details fields whose JSON encoding is
+// larger than the offload threshold into store, recording references in
+// content.offloaded, then checks the size of the handoff as it will be
+// stored. Pass a nil store to only check the size. Oversized handoffs and
+// fields are reported as errors; the returned error is for store failures.
+// Reseal the handoff afterwards.
+func ApplyPayloadLimits(ctx context.Context, store BlobStore, h *Handoff, limits PayloadLimits) (*HandoffValidationReport, error) {
+	report := &HandoffValidationReport{}
+
+	if store != nil && limits.OffloadThreshold > 0 && len(h.Content.TechnicalDetails) > 0 {
+		kept := make(map[string]interface{}, len(h.Content.TechnicalDetails))
+		for _, field := range sortedKeys(h.Content.TechnicalDetails) {
+			value := h.Content.TechnicalDetails[field]
+			kept[field] = value
+
+			data, err := json.Marshal(value)
+			if err != nil {
+				return report, fmt.Errorf("cannot encode technical_details field %s: %w", field, err)
+			}
+			if len(data) <= limits.OffloadThreshold {
+				continue
+			}
+			if limits.MaxBlobBytes > 0 && len(data) > limits.MaxBlobBytes {
+				report.add("content.technical_details."+field, ValidationTooLarge,
+					"technical_details field %s is %d bytes, more than %d", field, len(data), limits.MaxBlobBytes)
+				continue
+			}
+
+			ref, err := store.Put(ctx, data)
+			if err != nil {
+				return report, fmt.Errorf("cannot offload technical_details field %s: %w", field, err)
+			}
+			if h.Content.Offloaded == nil {
+				h.Content.Offloaded = make(map[string]BlobRef)
+			}
+			h.Content.Offloaded[field] = ref
+			delete(kept, field)
+		}
+		// Replace rather than edit the map, which callers may share
+		h.Content.TechnicalDetails = kept
+	}
+
+	if limits.MaxBytes > 0 {
+		data, err := json.Marshal(h)
+		if err != nil {
+			return report, fmt.Errorf("cannot encode handoff: %w", err)
+		}
+		if len(data) > limits.MaxBytes {
+			hint := ""
+			if store == nil {
+				hint = "; configure a blob store to offload large technical_details fields"
+			}
+			report.add("content", ValidationTooLarge,
+				"handoff is %d bytes, more than the %d byte limit%s", len(data), limits.MaxBytes, hint)
+		}
+	}
+	return report, nil
+}
+
+// LoadOffloaded returns a technical_details field, fetching it from store
+// when it was offloaded. It returns nil for fields the handoff does not have.
+func LoadOffloaded(ctx context.Context, store BlobStore, h *Handoff, field string) (interface{}, error) {
+	ref, offloaded := h.Content.Offloaded[field]
+	if !offloaded {
+		return h.Content.TechnicalDetails[field], nil
+	}
+	if store == nil {
+		return nil, fmt.Errorf("technical_details field %s is offloaded but no blob store is configured", field)
+	}
+
+	data, err := store.Get(ctx, ref)
+	if err != nil {
+		return nil, fmt.Errorf("cannot load technical_details field %s: %w", field, err)
+	}
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, fmt.Errorf("cannot decode technical_details field %s: %w", field, err)
+	}
+	return value, nil
+}
+
+// InflateHandoff returns a copy of the handoff with every offloaded field
+// loaded back into technical_details. The copy no longer matches the stored
+// checksum; verify the stored handoff before inflating it.
+func InflateHandoff(ctx context.Context, store BlobStore, h *Handoff) (*Handoff, error) {
+	inflated := *h
+	if len(h.Content.Offloaded) == 0 {
+		return &inflated, nil
+	}
+
+	details := make(map[string]interface{}, len(h.Content.TechnicalDetails)+len(h.Content.Offloaded))
+	for field, value := range h.Content.TechnicalDetails {
+		details[field] = value
+	}
+	for _, field := range sortedKeys(h.Content.Offloaded) {
+		value, err := LoadOffloaded(ctx, store, h, field)
+		if err != nil {
+			return nil, err
+		}
+		details[field] = value
+	}
+	inflated.Content.TechnicalDetails = details
+	inflated.Content.Offloaded = nil
+	return &inflated, nil
+}
+
+// InflatePayload is InflateHandoff for a handoff stored as raw JSON. Fields
+// outside technical_details and offloaded are passed through unchanged, and
+// loaded fields keep their exact JSON, so large numbers keep every digit.
+func InflatePayload(ctx context.Context, store BlobStore, payload []byte) ([]byte, error) {
+	var stored map[string]json.RawMessage
+	if err := json.Unmarshal(payload, &stored); err != nil {
+		return nil, fmt.Errorf("cannot decode handoff: %w", err)
+	}
+	var content map[string]json.RawMessage
+	if err := json.Unmarshal(stored["content"], &content); err != nil || len(content["offloaded"]) == 0 {
+		return payload, nil
+	}
+	var offloaded map[string]BlobRef
+	if err := json.Unmarshal(content["offloaded"], &offloaded); err != nil {
+		return nil, fmt.Errorf("cannot decode offloaded fields: %w", err)
+	}
+	if len(offloaded) == 0 {
+		return payload, nil
+	}
+	if store == nil {
+		return nil, fmt.Errorf("handoff has offloaded technical_details fields but no blob store is configured")
+	}
+
+	details := make(map[string]json.RawMessage, len(offloaded))
+	if len(content["technical_details"]) > 0 {
+		if err := json.Unmarshal(content["technical_details"], &details); err != nil {
+			return nil, fmt.Errorf("cannot decode technical_details: %w", err)
+		}
+	}
+	for _, field := range sortedKeys(offloaded) {
+		data, err := store.Get(ctx, offloaded[field])
+		if err != nil {
+			return nil, fmt.Errorf("cannot load technical_details field %s: %w", field, err)
+		}
+		if !json.Valid(data) {
+			return nil, fmt.Errorf("cannot decode technical_details field %s: not JSON", field)
+		}
+		details[field] = data
+	}
+
+	var err error
+	if content["technical_details"], err = json.Marshal(details); err != nil {
+		return nil, err
+	}
+	delete(content, "offloaded")
+	if stored["content"], err = json.Marshal(content); err != nil {
+		return nil, err
+	}
+	return json.Marshal(stored)
+}
@@ -0,0 +1,280 @@
+package handoff
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newPayloadTestHandoff() *Handoff {
+	h := newPolicyTestHandoff("golang-expert", map[string]interface{}{
+		"handlers": []interface{}{"CreateUser", "GetUser"},
+		"schema":   strings.Repeat("CREATE TABLE users (id INT);\n", 100),
+	})
+	return h
+}
+
+func TestApplyPayloadLimitsOffloads(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newPayloadTestHandoff()
+	original := h.Content.TechnicalDetails
+	limits := PayloadLimits{MaxBytes: 2048, OffloadThreshold: 512}
+
+	report, err := ApplyPayloadLimits(ctx, store, h, limits)
+	if err != nil {
+		t.Fatalf("ApplyPayloadLimits failed: %v", err)
+	}
+	if report.HasErrors() {
+		t.Fatalf("expected the offloaded handoff to fit, got %v", report.Violations)
+	}
+	if _, inline := h.Content.TechnicalDetails["schema"]; inline || len(h.Content.Offloaded) != 1 {
+		t.Fatalf("expected schema to be offloaded, got %v and %v", h.Content.TechnicalDetails, h.Content.Offloaded)
+	}
+	if _, kept := original["schema"]; !kept {
+		t.Error("expected the caller's technical_details map to be left alone")
+	}
+
+	schema, err := LoadOffloaded(ctx, store, h, "schema")
+	if err != nil || schema != original["schema"] {
+		t.Errorf("LoadOffloaded = %.20v, %v", schema, err)
+	}
+	if handlers, err := LoadOffloaded(ctx, store, h, "handlers"); err != nil || len(handlers.([]interface{})) != 2 {
+		t.Errorf("expected inline field to be returned as is, got %v, %v", handlers, err)
+	}
+
+	inflated, err := InflateHandoff(ctx, store, h)
+	if err != nil {
+		t.Fatalf("InflateHandoff failed: %v", err)
+	}
+	if inflated.Content.TechnicalDetails["schema"] != original["schema"] || inflated.Content.Offloaded != nil {
+		t.Error("expected the inflated copy to hold every field inline")
+	}
+	if len(h.Content.Offloaded) != 1 {
+		t.Error("expected InflateHandoff to leave the stored form alone")
+	}
+
+	// Children share the parent's blobs
+	child := NewFanOutChild(h, "test-expert", "child-1")
+	if child.Content.Offloaded["schema"] != h.Content.Offloaded["schema"] {
+		t.Errorf("expected fan-out child to reference the same blob, got %v", child.Content.Offloaded)
+	}
+}
+
+func TestInflatePayload(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newPayloadTestHandoff()
+	original := h.Content.TechnicalDetails["schema"]
+	if _, err := ApplyPayloadLimits(ctx, store, h, PayloadLimits{OffloadThreshold: 512}); err != nil {
+		t.Fatal(err)
+	}
+	payload, _ := json.Marshal(h)
+	payload = bytes.Replace(payload, []byte(`"content":{`), []byte(`"content":{"id":12345678901234567890,`), 1)
+
+	inflated, err := InflatePayload(ctx, store, payload)
+	if err != nil {
+		t.Fatalf("InflatePayload failed: %v", err)
+	}
+	if !bytes.Contains(inflated, []byte("12345678901234567890")) {
+		t.Errorf("expected unknown fields to keep their exact JSON, got %.200s", inflated)
+	}
+	var decoded Handoff
+	if err := json.Unmarshal(inflated, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if decoded.Content.TechnicalDetails["schema"] != original || decoded.Content.Offloaded != nil || len(decoded.Content.TechnicalDetails["handlers"].([]interface{})) != 2 {
+		t.Errorf("expected every field inline, got %v and %v", decoded.Content.TechnicalDetails, decoded.Content.Offloaded)
+	}
+	if decoded.Metadata.HandoffID != h.Metadata.HandoffID {
+		t.Errorf("expected metadata to be kept, got %+v", decoded.Metadata)
+	}
+
+	if _, err := InflatePayload(ctx, nil, payload); err == nil {
+		t.Error("expected an offloaded payload without a blob store to fail")
+	}
+	plain, _ := json.Marshal(newPayloadTestHandoff())
+	if got, err := InflatePayload(ctx, nil, plain); err != nil || !bytes.Equal(got, plain) {
+		t.Errorf("expected a payload without offloaded fields to be returned as is, got %v", err)
+	}
+}
+
+func TestApplyPayloadLimitsRejectsOversized(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		store    BlobStore
+		limits   PayloadLimits
+		wantPath string
+	}{
+		{"no blob store", nil, PayloadLimits{MaxBytes: 2048, OffloadThreshold: 512}, "content"},
+		{"blob too large", store, PayloadLimits{MaxBytes: 2048, OffloadThreshold: 512, MaxBlobBytes: 1024}, "content.technical_details.schema"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report, err := ApplyPayloadLimits(ctx, tt.store, newPayloadTestHandoff(), tt.limits)
+			if err != nil {
+				t.Fatalf("ApplyPayloadLimits failed: %v", err)
+			}
+			errs := report.Errors()
+			if len(errs) == 0 || errs[0].Path != tt.wantPath || errs[0].Code != ValidationTooLarge {
+				t.Errorf("expected too_large at %s, got %v", tt.wantPath, report.Violations)
+			}
+		})
+	}
+}
+
+func TestOffloadedFieldsAreSealed(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := NewFileBlobStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newPayloadTestHandoff()
+	if _, err := ApplyPayloadLimits(ctx, store, h, PayloadLimits{OffloadThreshold: 512}); err != nil {
+		t.Fatal(err)
+	}
+	h.Validation.Checksum = h.GenerateChecksum()
+
+	// Swapping the reference breaks the checksum
+	tampered := *h
+	tampered.Content.Offloaded = map[string]BlobRef{"schema": newBlobRef([]byte(`"DROP TABLE users"`))}
+	if err := tampered.VerifyChecksum(); !errors.Is(err, ErrChecksumMismatch) {
+		t.Errorf("expected checksum mismatch for a swapped reference, got %v", err)
+	}
+
+	// Changing the blob itself is caught when it is loaded
+	hash, _ := blobHash(h.Content.Offloaded["schema"])
+	if err := os.WriteFile(filepath.Join(dir, hash[:2], hash), []byte(`"DROP TABLE users"`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadOffloaded(ctx, store, h, "schema"); !errors.Is(err, ErrBlobCorrupt) {
+		t.Errorf("expected ErrBlobCorrupt, got %v", err)
+	}
+}
+
+func TestFileBlobStore(t *testing.T) {
+	ctx := context.Background()
+	store, err := NewFileBlobStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	first, err := store.Put(ctx, []byte(`{"a":1}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := store.Put(ctx, []byte(`{"a":1}`))
+	if first != second || first.Size != 7 || !strings.HasPrefix(first.Digest, "sha256:") {
+		t.Errorf("expected identical content to share a reference, got %v and %v", first, second)
+	}
+
+	if _, err := store.Get(ctx, newBlobRef([]byte("absent"))); !errors.Is(err, ErrBlobNotFound) {
+		t.Errorf("expected ErrBlobNotFound, got %v", err)
+	}
+	if _, err := store.Get(ctx, BlobRef{Digest: "sha256:../../etc/passwd"}); err == nil {
+		t.Error("expected malformed digest to be refused")
+	}
+
+	if removed, err := store.Prune(time.Hour); err != nil || removed != 0 {
+		t.Errorf("expected recent blobs to be kept, got %d, %v", removed, err)
+	}
+	if removed, err := store.Prune(-time.Second); err != nil || removed != 1 {
+		t.Errorf("expected one blob pruned, got %d, %v", removed, err)
+	}
+}
+
+func TestFileBlobStoreRunPrune(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	dir := t.TempDir()
+	store, err := NewFileBlobStore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired, err := store.Put(ctx, []byte(`"expired"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := store.Put(ctx, []byte(`"kept"`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash, _ := blobHash(expired)
+	old := time.Now().Add(-BlobRetention - time.Minute)
+	if err := os.Chtimes(filepath.Join(dir, hash[:2], hash), old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		store.RunPrune(ctx, time.Hour)
+		close(done)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := store.Get(ctx, expired); errors.Is(err, ErrBlobNotFound) {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the blob older than the retention to be pruned")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if _, err := store.Get(ctx, kept); err != nil {
+		t.Errorf("expected the recent blob to be kept, got %v", err)
+	}
+
+	cancel()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected RunPrune to stop when its context is done")
+	}
+}
+
+func TestValidatorAcceptsOffloadedFields(t *testing.T) {
+	policy := DefaultValidationPolicy()
+	spec := policy.Agents["golang-expert"].TechnicalDetails["handlers"]
+	spec.Required = true
+	policy.Agents["golang-expert"].TechnicalDetails["handlers"] = spec
+	validator, err := NewHandoffValidatorWithPolicy(policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := newPolicyTestHandoff("golang-expert", nil)
+	h.Content.Offloaded = map[string]BlobRef{"handlers": newBlobRef([]byte(`["CreateUser"]`))}
+	if err := validator.ValidatePolicy(h); err != nil {
+		t.Errorf("expected an offloaded required field to count as present, got %v", err)
+	}
+
+	policy.Payload.OffloadThreshold = -1
+	if _, err := NewHandoffValidatorWithPolicy(policy); err == nil || !strings.Contains(err.Error(), "payload") {
+		t.Errorf("expected negative payload limit to be refused, got %v", err)
+	}
+}
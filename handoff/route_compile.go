@@ -0,0 +1,223 @@
+package handoff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// compiledRule is a RouteRule with its conditions prepared for evaluation
+type compiledRule struct {
+	RouteRule
+	conditions []compiledCondition
+}
+
+// compiledCondition caches everything about a condition that does not depend
+// on the handoff being routed: the canonical operator, the expected value as a
+// string and number, the "in" list and the compiled regex
+type compiledCondition struct {
+	RouteCondition
+	operator    string
+	expected    string
+	expectedNil bool
+	expectedNum float64
+	isNumeric   bool
+	inValues    []compiledValue
+	inValid     bool
+	pattern     *regexp.Regexp
+}
+
+// compiledValue is one entry of an "in" condition
+type compiledValue struct {
+	value string
+	isNil bool
+}
+
+// routeValueKind says which field of a routeValue holds the value
+type routeValueKind int
+
+const (
+	routeValueAny routeValueKind = iota // other, including nil
+	routeValueString
+	routeValueList
+	routeValueInt
+)
+
+// routeValue is a handoff value a condition is matched against. Handoff fields
+// are held by their own type instead of being boxed in an interface, so that
+// evaluating a condition does not allocate; technical details are already
+// interfaces and are held as they are.
+type routeValue struct {
+	kind  routeValueKind
+	str   string
+	list  []string
+	num   int
+	other interface{}
+}
+
+func stringRouteValue(s string) routeValue    { return routeValue{kind: routeValueString, str: s} }
+func listRouteValue(list []string) routeValue { return routeValue{kind: routeValueList, list: list} }
+func intRouteValue(n int) routeValue          { return routeValue{kind: routeValueInt, num: n} }
+func anyRouteValue(v interface{}) routeValue  { return routeValue{other: v} }
+
+// isNil reports whether the value is missing
+func (v routeValue) isNil() bool {
+	return v.kind == routeValueAny && v.other == nil
+}
+
+// String formats the value as stringOf does
+func (v routeValue) String() string {
+	switch v.kind {
+	case routeValueString:
+		return v.str
+	case routeValueList:
+		return "[" + strings.Join(v.list, " ") + "]"
+	case routeValueInt:
+		return strconv.Itoa(v.num)
+	default:
+		return stringOf(v.other)
+	}
+}
+
+// float returns the value as a number, as toFloat64 does
+func (v routeValue) float() (float64, bool) {
+	switch v.kind {
+	case routeValueInt:
+		return float64(v.num), true
+	case routeValueAny:
+		return toFloat64(v.other)
+	default:
+		return 0, false
+	}
+}
+
+// compileRule prepares a rule's conditions; invalid conditions compile to ones
+// that never match, as they did before compilation
+func compileRule(rule RouteRule) compiledRule {
+	compiled := compiledRule{
+		RouteRule:  rule,
+		conditions: make([]compiledCondition, len(rule.Conditions)),
+	}
+	for i, condition := range rule.Conditions {
+		compiled.conditions[i] = compileCondition(condition)
+	}
+	return compiled
+}
+
+func compileCondition(condition RouteCondition) compiledCondition {
+	compiled := compiledCondition{
+		RouteCondition: condition,
+		operator:       routeOperatorAliases[condition.Operator],
+		expected:       stringOf(condition.Value),
+		expectedNil:    condition.Value == nil,
+	}
+	compiled.expectedNum, compiled.isNumeric = toFloat64(condition.Value)
+
+	switch compiled.operator {
+	case "in":
+		if items, ok := condition.Value.([]interface{}); ok {
+			compiled.inValid = true
+			compiled.inValues = make([]compiledValue, len(items))
+			for i, item := range items {
+				compiled.inValues[i] = compiledValue{value: stringOf(item), isNil: item == nil}
+			}
+		}
+	case "regex":
+		if pattern, err := regexp.Compile(compiled.expected); err == nil {
+			compiled.pattern = pattern
+		}
+	}
+	return compiled
+}
+
+// matches compares an actual handoff value against the condition
+func (c *compiledCondition) matches(actual routeValue) bool {
+	switch c.operator {
+	case "equals":
+		return c.equals(actual, c.expected, c.expectedNil)
+	case "not_equals":
+		return !c.equals(actual, c.expected, c.expectedNil)
+	case "contains":
+		return c.compareStrings(actual, strings.Contains)
+	case "not_contains":
+		return !c.compareStrings(actual, strings.Contains)
+	case "starts_with":
+		return c.compareStrings(actual, strings.HasPrefix)
+	case "ends_with":
+		return c.compareStrings(actual, strings.HasSuffix)
+	case "greater_than":
+		actualNum, ok := actual.float()
+		return ok && c.isNumeric && actualNum > c.expectedNum
+	case "less_than":
+		actualNum, ok := actual.float()
+		return ok && c.isNumeric && actualNum < c.expectedNum
+	case "greater_equal":
+		actualNum, ok := actual.float()
+		return ok && c.isNumeric && actualNum >= c.expectedNum
+	case "less_equal":
+		actualNum, ok := actual.float()
+		return ok && c.isNumeric && actualNum <= c.expectedNum
+	case "in":
+		if !c.inValid {
+			return false
+		}
+		for _, item := range c.inValues {
+			if c.equals(actual, item.value, item.isNil) {
+				return true
+			}
+		}
+		return false
+	case "regex":
+		return c.pattern != nil && c.pattern.MatchString(actual.String())
+	default:
+		return false
+	}
+}
+
+func (c *compiledCondition) equals(actual routeValue, expected string, expectedNil bool) bool {
+	if actual.isNil() || expectedNil {
+		return actual.isNil() && expectedNil
+	}
+
+	actualStr := actual.String()
+	if !c.CaseSensitive {
+		return strings.EqualFold(actualStr, expected)
+	}
+	return actualStr == expected
+}
+
+func (c *compiledCondition) compareStrings(actual routeValue, compare func(s, substr string) bool) bool {
+	actualStr := actual.String()
+	if !c.CaseSensitive {
+		return compare(strings.ToLower(actualStr), strings.ToLower(c.expected))
+	}
+	return compare(actualStr, c.expected)
+}
+
+// stringOf formats a value the way fmt's %v verb does, without going through
+// fmt for the types handoff fields and decoded JSON actually contain
+func stringOf(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "<nil>"
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case int:
+		return strconv.Itoa(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case int32:
+		return strconv.FormatInt(int64(v), 10)
+	case float64:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'g', -1, 32)
+	case []string:
+		return "[" + strings.Join(v, " ") + "]"
+	default:
+		return fmt.Sprint(v)
+	}
+}
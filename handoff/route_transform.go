@@ -0,0 +1,547 @@
+package handoff
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Transform actions supported by RouteTransform
+const (
+	TransformActionSet      = "set"      // Replace the field with Value
+	TransformActionAppend   = "append"   // Append Value to a string (space separated) or list
+	TransformActionRemove   = "remove"   // Clear the field, or drop the items in Value from a list
+	TransformActionReplace  = "replace"  // Replace Value["old"] with Value["new"] in a string or list
+	TransformActionTemplate = "template" // Render Value as a text/template over the handoff fields, then set (or append to lists)
+)
+
+// transformFieldKind describes the shape of a transformable field
+type transformFieldKind int
+
+const (
+	transformFieldString transformFieldKind = iota
+	transformFieldList
+	transformFieldPriority
+	transformFieldTime
+	transformFieldTechnical
+)
+
+// transformActions lists the actions each field kind supports
+var transformActions = map[transformFieldKind]map[string]bool{
+	transformFieldString: {
+		TransformActionSet: true, TransformActionAppend: true, TransformActionRemove: true,
+		TransformActionReplace: true, TransformActionTemplate: true,
+	},
+	transformFieldList: {
+		TransformActionSet: true, TransformActionAppend: true, TransformActionRemove: true,
+		TransformActionReplace: true, TransformActionTemplate: true,
+	},
+	transformFieldPriority: {TransformActionSet: true, TransformActionTemplate: true},
+	transformFieldTime:     {TransformActionSet: true, TransformActionTemplate: true},
+	transformFieldTechnical: {
+		TransformActionSet: true, TransformActionAppend: true, TransformActionRemove: true,
+		TransformActionReplace: true, TransformActionTemplate: true,
+	},
+}
+
+// transformField is a resolved transform target bound to a handoff
+type transformField struct {
+	kind transformFieldKind
+	name string
+	str  *string
+	list *[]string
+	time *time.Time
+}
+
+// resolveTransformField binds a transform's field to the handoff it modifies.
+// Metadata fields owned by routing and publishing (to_agent, handoff_id and the
+// routing provenance) are rejected.
+func resolveTransformField(handoff *Handoff, transform RouteTransform) (*transformField, error) {
+	field := transform.Field
+
+	switch transform.Type {
+	case TransformMetadata:
+		switch field {
+		case "project_name":
+			return &transformField{kind: transformFieldString, name: field, str: &handoff.Metadata.ProjectName}, nil
+		case "from_agent":
+			return &transformField{kind: transformFieldString, name: field, str: &handoff.Metadata.FromAgent}, nil
+		case "task_context":
+			return &transformField{kind: transformFieldString, name: field, str: &handoff.Metadata.TaskContext}, nil
+		case "priority":
+			return &transformField{kind: transformFieldPriority, name: field, str: (*string)(&handoff.Metadata.Priority)}, nil
+		case "timestamp":
+			return &transformField{kind: transformFieldTime, name: field, time: &handoff.Metadata.Timestamp}, nil
+		case "to_agent", "handoff_id", "requested_agent", "route_rule":
+			return nil, fmt.Errorf("metadata field %q is managed by the router and cannot be transformed", field)
+		}
+	case TransformContent:
+		switch field {
+		case "summary":
+			return &transformField{kind: transformFieldString, name: field, str: &handoff.Content.Summary}, nil
+		case "requirements":
+			return &transformField{kind: transformFieldList, name: field, list: &handoff.Content.Requirements}, nil
+		case "next_steps":
+			return &transformField{kind: transformFieldList, name: field, list: &handoff.Content.NextSteps}, nil
+		case "artifacts.created":
+			return &transformField{kind: transformFieldList, name: field, list: &handoff.Content.Artifacts.Created}, nil
+		case "artifacts.modified":
+			return &transformField{kind: transformFieldList, name: field, list: &handoff.Content.Artifacts.Modified}, nil
+		case "artifacts.reviewed":
+			return &transformField{kind: transformFieldList, name: field, list: &handoff.Content.Artifacts.Reviewed}, nil
+		}
+	case TransformTechnical:
+		if field == "" {
+			return nil, fmt.Errorf("technical transform requires a field")
+		}
+		return &transformField{kind: transformFieldTechnical, name: field}, nil
+	case TransformPriority:
+		if field != "" && field != "priority" {
+			return nil, fmt.Errorf("priority transform does not take field %q", field)
+		}
+		return &transformField{kind: transformFieldPriority, name: "priority", str: (*string)(&handoff.Metadata.Priority)}, nil
+	default:
+		return nil, fmt.Errorf("unknown transform type: %s", transform.Type)
+	}
+
+	return nil, fmt.Errorf("unknown %s field %q", transform.Type, field)
+}
+
+// transformAction returns the transform's action; priority transforms default to set
+func transformAction(transform RouteTransform) string {
+	if transform.Action == "" && transform.Type == TransformPriority {
+		return TransformActionSet
+	}
+	return transform.Action
+}
+
+// CheckRouteTransform statically checks that a transform targets a known field
+// with a supported action and a correctly shaped value
+func CheckRouteTransform(transform RouteTransform) error {
+	field, err := resolveTransformField(&Handoff{}, transform)
+	if err != nil {
+		return err
+	}
+
+	action := transformAction(transform)
+	if !transformActions[field.kind][action] {
+		return fmt.Errorf("unsupported action %q for %s field %q", transform.Action, transform.Type, field.name)
+	}
+
+	switch action {
+	case TransformActionTemplate:
+		text, ok := transform.Value.(string)
+		if !ok {
+			return fmt.Errorf("template for field %q must be a string, got %T", field.name, transform.Value)
+		}
+		if _, err := parseTransformTemplate(text); err != nil {
+			return fmt.Errorf("invalid template for field %q: %w", field.name, err)
+		}
+		return nil
+	case TransformActionReplace:
+		_, _, err := replacementValue(transform.Value)
+		return err
+	case TransformActionRemove:
+		if field.kind == transformFieldList && transform.Value != nil {
+			_, err := stringsValue(transform.Value)
+			return err
+		}
+		return nil
+	}
+
+	switch field.kind {
+	case transformFieldString:
+		_, err = stringValue(transform.Value)
+	case transformFieldList:
+		_, err = stringsValue(transform.Value)
+	case transformFieldPriority:
+		var value string
+		if value, err = stringValue(transform.Value); err == nil {
+			err = checkPriority(value)
+		}
+	case transformFieldTime:
+		var value string
+		if value, err = stringValue(transform.Value); err == nil {
+			_, err = time.Parse(time.RFC3339, value)
+		}
+	case transformFieldTechnical:
+		if action == TransformActionAppend {
+			_, err = stringValue(transform.Value)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("invalid value for field %q: %w", field.name, err)
+	}
+	return nil
+}
+
+// applyTransforms applies route transforms to a handoff. The transforms run
+// against a copy that replaces the handoff only once every one has succeeded,
+// so a failure partway through leaves the handoff untouched.
+func (r *HandoffRouter) applyTransforms(handoff *Handoff, transforms []RouteTransform) error {
+	if len(transforms) == 0 {
+		return nil
+	}
+
+	working := cloneTransformFields(handoff)
+	for _, transform := range transforms {
+		if err := r.applyTransform(working, transform); err != nil {
+			return fmt.Errorf("transform failed: %w", err)
+		}
+	}
+	*handoff = *working
+	return nil
+}
+
+// cloneTransformFields copies a handoff deeply enough that transforms on the
+// copy cannot reach the original: the lists and technical details they edit
+// in place get their own backing storage
+func cloneTransformFields(handoff *Handoff) *Handoff {
+	clone := *handoff
+	clone.Content.Requirements = cloneStrings(handoff.Content.Requirements)
+	clone.Content.NextSteps = cloneStrings(handoff.Content.NextSteps)
+	clone.Content.Artifacts.Created = cloneStrings(handoff.Content.Artifacts.Created)
+	clone.Content.Artifacts.Modified = cloneStrings(handoff.Content.Artifacts.Modified)
+	clone.Content.Artifacts.Reviewed = cloneStrings(handoff.Content.Artifacts.Reviewed)
+
+	if handoff.Content.TechnicalDetails != nil {
+		clone.Content.TechnicalDetails = make(map[string]interface{}, len(handoff.Content.TechnicalDetails))
+		for key, value := range handoff.Content.TechnicalDetails {
+			clone.Content.TechnicalDetails[key] = cloneDetail(value)
+		}
+	}
+	return &clone
+}
+
+// cloneDetail deep-copies a technical detail's lists and maps
+func cloneDetail(value interface{}) interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		if v == nil {
+			return v
+		}
+		list := make([]interface{}, len(v))
+		for i, item := range v {
+			list[i] = cloneDetail(item)
+		}
+		return list
+	case map[string]interface{}:
+		if v == nil {
+			return v
+		}
+		fields := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			fields[key] = cloneDetail(item)
+		}
+		return fields
+	case []string:
+		return cloneStrings(v)
+	default:
+		return value
+	}
+}
+
+// cloneStrings copies a list, keeping nil and empty lists distinct
+func cloneStrings(list []string) []string {
+	if list == nil {
+		return nil
+	}
+	return append(make([]string, 0, len(list)), list...)
+}
+
+// applyTransform applies a single transform, rejecting unknown fields, actions and value types
+func (r *HandoffRouter) applyTransform(handoff *Handoff, transform RouteTransform) error {
+	if err := CheckRouteTransform(transform); err != nil {
+		return err
+	}
+
+	field, err := resolveTransformField(handoff, transform)
+	if err != nil {
+		return err
+	}
+
+	action := transformAction(transform)
+	value := transform.Value
+
+	if action == TransformActionTemplate {
+		rendered, err := renderTransformTemplate(handoff, value.(string))
+		if err != nil {
+			return fmt.Errorf("failed to render template for field %q: %w", field.name, err)
+		}
+		value = rendered
+		action = TransformActionSet
+		if field.kind == transformFieldList || (field.kind == transformFieldTechnical && isTechnicalList(handoff, field.name)) {
+			action = TransformActionAppend
+		}
+	}
+
+	switch field.kind {
+	case transformFieldString:
+		return transformString(field.str, action, value)
+	case transformFieldList:
+		return transformList(field.list, action, value)
+	case transformFieldPriority:
+		priority, err := stringValue(value)
+		if err != nil {
+			return err
+		}
+		if err := checkPriority(priority); err != nil {
+			return err
+		}
+		*field.str = priority
+	case transformFieldTime:
+		text, err := stringValue(value)
+		if err != nil {
+			return err
+		}
+		timestamp, err := time.Parse(time.RFC3339, text)
+		if err != nil {
+			return fmt.Errorf("invalid timestamp for field %q: %w", field.name, err)
+		}
+		*field.time = timestamp
+	case transformFieldTechnical:
+		return transformTechnical(handoff, field.name, action, value)
+	}
+	return nil
+}
+
+func transformString(target *string, action string, value interface{}) error {
+	switch action {
+	case TransformActionSet:
+		text, err := stringValue(value)
+		if err != nil {
+			return err
+		}
+		*target = text
+	case TransformActionAppend:
+		text, err := stringValue(value)
+		if err != nil {
+			return err
+		}
+		if *target == "" {
+			*target = text
+		} else {
+			*target += " " + text
+		}
+	case TransformActionRemove:
+		*target = ""
+	case TransformActionReplace:
+		old, replacement, err := replacementValue(value)
+		if err != nil {
+			return err
+		}
+		*target = strings.ReplaceAll(*target, old, replacement)
+	}
+	return nil
+}
+
+func transformList(target *[]string, action string, value interface{}) error {
+	switch action {
+	case TransformActionSet:
+		items, err := stringsValue(value)
+		if err != nil {
+			return err
+		}
+		*target = items
+	case TransformActionAppend:
+		items, err := stringsValue(value)
+		if err != nil {
+			return err
+		}
+		*target = append(*target, items...)
+	case TransformActionRemove:
+		if value == nil {
+			*target = nil
+			return nil
+		}
+		items, err := stringsValue(value)
+		if err != nil {
+			return err
+		}
+		drop := make(map[string]bool, len(items))
+		for _, item := range items {
+			drop[item] = true
+		}
+		kept := make([]string, 0, len(*target))
+		for _, item := range *target {
+			if !drop[item] {
+				kept = append(kept, item)
+			}
+		}
+		*target = kept
+	case TransformActionReplace:
+		old, replacement, err := replacementValue(value)
+		if err != nil {
+			return err
+		}
+		for i, item := range *target {
+			if item == old {
+				(*target)[i] = replacement
+			}
+		}
+	}
+	return nil
+}
+
+func transformTechnical(handoff *Handoff, key, action string, value interface{}) error {
+	if handoff.Content.TechnicalDetails == nil {
+		handoff.Content.TechnicalDetails = make(map[string]interface{})
+	}
+	details := handoff.Content.TechnicalDetails
+
+	switch action {
+	case TransformActionSet:
+		// The value belongs to the rule; later transforms edit lists in place
+		details[key] = cloneDetail(value)
+	case TransformActionAppend:
+		text, err := stringValue(value)
+		if err != nil {
+			return err
+		}
+		existing, exists := details[key]
+		if !exists {
+			details[key] = []interface{}{text}
+			return nil
+		}
+		existingSlice, ok := existing.([]interface{})
+		if !ok {
+			return fmt.Errorf("technical detail %q is %T, not a list", key, existing)
+		}
+		details[key] = append(existingSlice, text)
+	case TransformActionRemove:
+		delete(details, key)
+	case TransformActionReplace:
+		old, replacement, err := replacementValue(value)
+		if err != nil {
+			return err
+		}
+		switch existing := details[key].(type) {
+		case string:
+			details[key] = strings.ReplaceAll(existing, old, replacement)
+		case []interface{}:
+			for i, item := range existing {
+				if item == old {
+					existing[i] = replacement
+				}
+			}
+		case nil:
+		default:
+			return fmt.Errorf("technical detail %q is %T and cannot be replaced", key, existing)
+		}
+	}
+	return nil
+}
+
+func isTechnicalList(handoff *Handoff, key string) bool {
+	_, ok := handoff.Content.TechnicalDetails[key].([]interface{})
+	return ok
+}
+
+// parseTransformTemplate parses a transform template; referencing an unknown field is an error
+func parseTransformTemplate(text string) (*template.Template, error) {
+	return template.New("transform").Option("missingkey=error").Parse(text)
+}
+
+// renderTransformTemplate renders a template over the handoff's fields, using
+// the same names as the JSON schema, e.g. {{.project_name}} or {{.technical_details.language}}
+func renderTransformTemplate(handoff *Handoff, text string) (string, error) {
+	tmpl, err := parseTransformTemplate(text)
+	if err != nil {
+		return "", err
+	}
+
+	var out strings.Builder
+	if err := tmpl.Execute(&out, transformTemplateData(handoff)); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func transformTemplateData(handoff *Handoff) map[string]interface{} {
+	technical := handoff.Content.TechnicalDetails
+	if technical == nil {
+		technical = map[string]interface{}{}
+	}
+
+	return map[string]interface{}{
+		"project_name": handoff.Metadata.ProjectName,
+		"from_agent":   handoff.Metadata.FromAgent,
+		"to_agent":     handoff.Metadata.ToAgent,
+		"task_context": handoff.Metadata.TaskContext,
+		"priority":     string(handoff.Metadata.Priority),
+		"handoff_id":   handoff.Metadata.HandoffID,
+		"timestamp":    handoff.Metadata.Timestamp.Format(time.RFC3339),
+		"summary":      handoff.Content.Summary,
+		"requirements": handoff.Content.Requirements,
+		"next_steps":   handoff.Content.NextSteps,
+		"artifacts": map[string]interface{}{
+			"created":  handoff.Content.Artifacts.Created,
+			"modified": handoff.Content.Artifacts.Modified,
+			"reviewed": handoff.Content.Artifacts.Reviewed,
+		},
+		"technical_details": technical,
+	}
+}
+
+func stringValue(value interface{}) (string, error) {
+	text, ok := value.(string)
+	if !ok {
+		return "", fmt.Errorf("expected a string value, got %T", value)
+	}
+	return text, nil
+}
+
+// stringsValue accepts a single string or a list of strings
+func stringsValue(value interface{}) ([]string, error) {
+	switch v := value.(type) {
+	case string:
+		return []string{v}, nil
+	case []string:
+		return append([]string(nil), v...), nil
+	case []interface{}:
+		items := make([]string, len(v))
+		for i, item := range v {
+			text, ok := item.(string)
+			if !ok {
+				return nil, fmt.Errorf("expected a list of strings, item %d is %T", i, item)
+			}
+			items[i] = text
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("expected a string or list of strings, got %T", value)
+	}
+}
+
+// replacementValue reads a {"old": ..., "new": ...} replace value
+func replacementValue(value interface{}) (string, string, error) {
+	var fields map[string]interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		fields = v
+	case map[string]string:
+		fields = map[string]interface{}{"old": v["old"], "new": v["new"]}
+	default:
+		return "", "", fmt.Errorf("replace expects {\"old\": ..., \"new\": ...}, got %T", value)
+	}
+
+	old, ok := fields["old"].(string)
+	if !ok || old == "" {
+		return "", "", fmt.Errorf("replace requires a non-empty string \"old\"")
+	}
+	replacement, ok := fields["new"].(string)
+	if !ok {
+		return "", "", fmt.Errorf("replace requires a string \"new\"")
+	}
+	return old, replacement, nil
+}
+
+func checkPriority(value string) error {
+	switch Priority(value) {
+	case PriorityLow, PriorityNormal, PriorityHigh, PriorityCritical:
+		return nil
+	default:
+		return fmt.Errorf("unknown priority %q", value)
+	}
+}
@@ -0,0 +1,158 @@
+package handoff
+
+import (
+	"context"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func newTransformTestHandoff() *Handoff {
+	return &Handoff{
+		Metadata: Metadata{
+			ProjectName: "billing",
+			FromAgent:   "api-expert",
+			TaskContext: "invoices",
+			Priority:    PriorityNormal,
+		},
+		Content: Content{
+			Summary:          "Implement invoice endpoints",
+			Requirements:     []string{"REST API", "draft: pagination", "auth"},
+			Artifacts:        Artifacts{Created: []string{"api/openapi.yaml", "tmp/scratch.txt"}},
+			TechnicalDetails: map[string]interface{}{"language": "go"},
+		},
+	}
+}
+
+func TestApplyTransformActions(t *testing.T) {
+	router := NewHandoffRouter("")
+	h := newTransformTestHandoff()
+
+	transforms := []RouteTransform{
+		{Type: TransformMetadata, Field: "project_name", Action: TransformActionSet, Value: "billing-v2"},
+		{Type: TransformMetadata, Field: "task_context", Action: TransformActionTemplate, Value: "{{.project_name}}/{{.technical_details.language}}"},
+		{Type: TransformContent, Field: "summary", Action: TransformActionReplace, Value: map[string]interface{}{"old": "Implement", "new": "Build"}},
+		{Type: TransformContent, Field: "requirements", Action: TransformActionRemove, Value: []interface{}{"draft: pagination"}},
+		{Type: TransformContent, Field: "requirements", Action: TransformActionReplace, Value: map[string]interface{}{"old": "auth", "new": "OAuth2"}},
+		{Type: TransformContent, Field: "next_steps", Action: TransformActionTemplate, Value: "Review {{len .artifacts.created}} new files"},
+		{Type: TransformContent, Field: "artifacts.created", Action: TransformActionRemove, Value: "tmp/scratch.txt"},
+		{Type: TransformContent, Field: "artifacts.reviewed", Action: TransformActionAppend, Value: []interface{}{"api/openapi.yaml"}},
+		{Type: TransformTechnical, Field: "language", Action: TransformActionRemove},
+		{Type: TransformPriority, Value: "high"},
+	}
+
+	if err := router.applyTransforms(h, transforms); err != nil {
+		t.Fatalf("applyTransforms failed: %v", err)
+	}
+
+	if h.Metadata.ProjectName != "billing-v2" {
+		t.Errorf("project_name = %q", h.Metadata.ProjectName)
+	}
+	if h.Metadata.TaskContext != "billing-v2/go" {
+		t.Errorf("task_context = %q", h.Metadata.TaskContext)
+	}
+	if h.Content.Summary != "Build invoice endpoints" {
+		t.Errorf("summary = %q", h.Content.Summary)
+	}
+	if want := []string{"REST API", "OAuth2"}; !reflect.DeepEqual(h.Content.Requirements, want) {
+		t.Errorf("requirements = %v, want %v", h.Content.Requirements, want)
+	}
+	if want := []string{"Review 2 new files"}; !reflect.DeepEqual(h.Content.NextSteps, want) {
+		t.Errorf("next_steps = %v, want %v", h.Content.NextSteps, want)
+	}
+	if want := []string{"api/openapi.yaml"}; !reflect.DeepEqual(h.Content.Artifacts.Created, want) {
+		t.Errorf("artifacts.created = %v, want %v", h.Content.Artifacts.Created, want)
+	}
+	if want := []string{"api/openapi.yaml"}; !reflect.DeepEqual(h.Content.Artifacts.Reviewed, want) {
+		t.Errorf("artifacts.reviewed = %v, want %v", h.Content.Artifacts.Reviewed, want)
+	}
+	if _, exists := h.Content.TechnicalDetails["language"]; exists {
+		t.Error("expected technical detail language to be removed")
+	}
+	if h.Metadata.Priority != PriorityHigh {
+		t.Errorf("priority = %q", h.Metadata.Priority)
+	}
+}
+
+func TestApplyTransformsLeavesHandoffUntouchedOnFailure(t *testing.T) {
+	router := NewHandoffRouter("")
+	h := newTransformTestHandoff()
+	h.Content.TechnicalDetails["frameworks"] = []interface{}{"gin", "gorm"}
+	want := newTransformTestHandoff()
+	want.Content.TechnicalDetails["frameworks"] = []interface{}{"gin", "gorm"}
+
+	transforms := []RouteTransform{
+		{Type: TransformMetadata, Field: "project_name", Action: TransformActionSet, Value: "billing-v2"},
+		{Type: TransformContent, Field: "requirements", Action: TransformActionReplace, Value: map[string]interface{}{"old": "auth", "new": "OAuth2"}},
+		{Type: TransformTechnical, Field: "frameworks", Action: TransformActionReplace, Value: map[string]interface{}{"old": "gorm", "new": "sqlc"}},
+		{Type: TransformPriority, Value: "high"},
+		// Passes the static checks but fails at runtime: language is a string, not a list
+		{Type: TransformTechnical, Field: "language", Action: TransformActionAppend, Value: "rust"},
+	}
+
+	err := router.applyTransforms(h, transforms)
+	if err == nil || !strings.Contains(err.Error(), "not a list") {
+		t.Fatalf("expected the append onto a string to fail, got %v", err)
+	}
+	if !reflect.DeepEqual(h, want) {
+		t.Errorf("expected the handoff to be unchanged after a failed transform, got %+v", h)
+	}
+}
+
+func TestRouteTransformsLeaveRuleConfigUnchanged(t *testing.T) {
+	// Spare capacity would let an append write into the rule's own list
+	checks := make([]interface{}, 2, 4)
+	checks[0], checks[1] = "lint", "unit"
+
+	router := NewHandoffRouter("project-manager")
+	router.AddRoute("api-expert", RouteRule{
+		Name:        "set-checks",
+		TargetAgent: "test-expert",
+		Priority:    100,
+		Transforms: []RouteTransform{
+			{Type: TransformTechnical, Field: "checks", Action: TransformActionSet, Value: checks},
+			{Type: TransformTechnical, Field: "checks", Action: TransformActionReplace, Value: map[string]interface{}{"old": "unit", "new": "integration"}},
+			{Type: TransformTechnical, Field: "checks", Action: TransformActionAppend, Value: "e2e"},
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		h := &Handoff{Metadata: Metadata{FromAgent: "api-expert", ToAgent: AutoRouteAgent}}
+		if _, err := router.Route(context.Background(), h); err != nil {
+			t.Fatalf("Route failed: %v", err)
+		}
+		if want := []interface{}{"lint", "integration", "e2e"}; !reflect.DeepEqual(h.Content.TechnicalDetails["checks"], want) {
+			t.Errorf("handoff %d: checks = %v, want %v", i, h.Content.TechnicalDetails["checks"], want)
+		}
+	}
+	if want := []interface{}{"lint", "unit"}; !reflect.DeepEqual(checks, want) || !reflect.DeepEqual(checks[:cap(checks)][2:], []interface{}{nil, nil}) {
+		t.Errorf("expected the rule's value to be unchanged, got %v", checks[:cap(checks)])
+	}
+}
+
+func TestApplyTransformRejectsInvalidTransforms(t *testing.T) {
+	router := NewHandoffRouter("")
+
+	tests := []struct {
+		name      string
+		transform RouteTransform
+		wantErr   string
+	}{
+		{"unknown field", RouteTransform{Type: TransformContent, Field: "title", Action: TransformActionSet, Value: "x"}, "unknown content field"},
+		{"unknown action", RouteTransform{Type: TransformContent, Field: "summary", Action: "prepend", Value: "x"}, "unsupported action"},
+		{"non-string value", RouteTransform{Type: TransformMetadata, Field: "task_context", Action: TransformActionSet, Value: 42}, "expected a string"},
+		{"router-owned field", RouteTransform{Type: TransformMetadata, Field: "to_agent", Action: TransformActionSet, Value: "x"}, "managed by the router"},
+		{"unknown priority", RouteTransform{Type: TransformPriority, Value: "asap"}, "unknown priority"},
+		{"unknown template field", RouteTransform{Type: TransformContent, Field: "summary", Action: TransformActionTemplate, Value: "{{.owner}}"}, "owner"},
+		{"malformed replace", RouteTransform{Type: TransformContent, Field: "summary", Action: TransformActionReplace, Value: "x"}, "replace expects"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := router.applyTransform(newTransformTestHandoff(), tt.transform)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
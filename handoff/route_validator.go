@@ -0,0 +1,435 @@
+package handoff
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// RouteIssueCode identifies the kind of problem found in a routing config
+type RouteIssueCode string
+
+const (
+	RouteIssueUnknownAgent     RouteIssueCode = "unknown_agent"
+	RouteIssueMissingTarget    RouteIssueCode = "missing_target_agent"
+	RouteIssueMissingName      RouteIssueCode = "missing_rule_name"
+	RouteIssueDuplicateName    RouteIssueCode = "duplicate_rule_name"
+	RouteIssueUnknownCondition RouteIssueCode = "unknown_condition_type"
+	RouteIssueUnknownField     RouteIssueCode = "unknown_condition_field"
+	RouteIssueUnknownOperator  RouteIssueCode = "unknown_operator"
+	RouteIssueInvalidValue     RouteIssueCode = "invalid_condition_value"
+	RouteIssueInvalidRegex     RouteIssueCode = "invalid_regex"
+	RouteIssueIgnoredOperator  RouteIssueCode = "ignored_operator"
+	RouteIssueInvalidTransform RouteIssueCode = "invalid_transform"
+	RouteIssueInvalidFanOut    RouteIssueCode = "invalid_fan_out"
+	RouteIssueUnreachable      RouteIssueCode = "unreachable_rule"
+	RouteIssueCycle            RouteIssueCode = "routing_cycle"
+)
+
+// RouteIssue describes a single problem found in a routing config
+type RouteIssue struct {
+	Code      RouteIssueCode `json:"code"`
+	Severity  Severity       `json:"severity"`
+	FromAgent string         `json:"from_agent,omitempty"`
+	Rule      string         `json:"rule,omitempty"`
+	Message   string         `json:"message"`
+}
+
+// String formats the issue for CLI and log output
+func (i RouteIssue) String() string {
+	location := i.FromAgent
+	if i.Rule != "" {
+		location += "/" + i.Rule
+	}
+	if location == "" {
+		return fmt.Sprintf("%s [%s] %s", i.Severity, i.Code, i.Message)
+	}
+	return fmt.Sprintf("%s [%s] %s: %s", i.Severity, i.Code, location, i.Message)
+}
+
+// RouteValidationReport collects the issues found in a routing config
+type RouteValidationReport struct {
+	Issues []RouteIssue `json:"issues"`
+}
+
+// HasErrors reports whether any issue has error severity
+func (r *RouteValidationReport) HasErrors() bool {
+	return len(r.Errors()) > 0
+}
+
+// Errors returns the issues with error severity
+func (r *RouteValidationReport) Errors() []RouteIssue {
+	return r.filter(SeverityError)
+}
+
+// Warnings returns the issues with warning severity
+func (r *RouteValidationReport) Warnings() []RouteIssue {
+	return r.filter(SeverityWarning)
+}
+
+// Err returns an error summarizing the error-severity issues, or nil if there are none
+func (r *RouteValidationReport) Err() error {
+	errs := r.Errors()
+	if len(errs) == 0 {
+		return nil
+	}
+
+	messages := make([]string, len(errs))
+	for i, issue := range errs {
+		messages[i] = issue.String()
+	}
+	return fmt.Errorf("invalid routing config (%d errors): %s", len(errs), strings.Join(messages, "; "))
+}
+
+func (r *RouteValidationReport) filter(severity Severity) []RouteIssue {
+	var issues []RouteIssue
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			issues = append(issues, issue)
+		}
+	}
+	return issues
+}
+
+func (r *RouteValidationReport) add(code RouteIssueCode, severity Severity, fromAgent, rule, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, RouteIssue{
+		Code:      code,
+		Severity:  severity,
+		FromAgent: fromAgent,
+		Rule:      rule,
+		Message:   fmt.Sprintf(format, args...),
+	})
+}
+
+// routeConditionFields lists the fields each condition type can read; technical
+// conditions accept any key since technical_details is free-form
+var routeConditionFields = map[ConditionType]map[string]bool{
+	ConditionMetadata: {
+		"from_agent": true, "to_agent": true, "task_context": true, "priority": true, "handoff_id": true,
+	},
+	ConditionContent: {
+		"summary": true, "requirements": true, "next_steps": true, "requirements_count": true, "next_steps_count": true,
+	},
+	ConditionArtifact: {
+		"created": true, "modified": true, "reviewed": true,
+		"created_count": true, "modified_count": true, "reviewed_count": true, "total_artifacts": true,
+	},
+	ConditionComplexQuery: {
+		"has_go_files": true, "has_typescript_files": true, "has_test_files": true, "has_api_spec": true,
+		"is_implementation_handoff": true, "is_testing_handoff": true, "is_deployment_handoff": true,
+	},
+	ConditionTechnical: nil,
+}
+
+// routeOperatorAliases maps every supported operator to its canonical name
+var routeOperatorAliases = map[string]string{
+	"equals": "equals", "eq": "equals",
+	"not_equals": "not_equals", "ne": "not_equals",
+	"contains":     "contains",
+	"not_contains": "not_contains",
+	"starts_with":  "starts_with",
+	"ends_with":    "ends_with",
+	"greater_than": "greater_than", "gt": "greater_than",
+	"less_than": "less_than", "lt": "less_than",
+	"greater_equal": "greater_equal", "ge": "greater_equal",
+	"less_equal": "less_equal", "le": "less_equal",
+	"in":    "in",
+	"regex": "regex",
+}
+
+var routeNumericOperators = map[string]bool{
+	"greater_than": true, "less_than": true, "greater_equal": true, "less_equal": true,
+}
+
+// RouteValidator statically checks routing rules for mistakes that would
+// otherwise only show up as misrouted handoffs
+type RouteValidator struct {
+	knownAgents   map[string]bool
+	fallbackAgent string
+}
+
+// NewRouteValidator creates a validator; target agent checks are skipped when knownAgents is empty
+func NewRouteValidator(knownAgents []string, fallbackAgent string) *RouteValidator {
+	v := &RouteValidator{
+		knownAgents:   make(map[string]bool, len(knownAgents)),
+		fallbackAgent: fallbackAgent,
+	}
+	for _, agent := range knownAgents {
+		v.knownAgents[agent] = true
+	}
+	return v
+}
+
+// Validate checks the routing rules for every source agent
+func (r *HandoffRouter) Validate(knownAgents []string) *RouteValidationReport {
+	return NewRouteValidator(knownAgents, r.fallbackAgent).Validate(r.Routes())
+}
+
+// Validate checks routes keyed by source agent and returns every issue found
+func (v *RouteValidator) Validate(routes map[string][]RouteRule) *RouteValidationReport {
+	report := &RouteValidationReport{}
+
+	if v.fallbackAgent != "" && !v.isKnownAgent(v.fallbackAgent) {
+		report.add(RouteIssueUnknownAgent, SeverityWarning, "", "",
+			"fallback agent %q is not a registered agent", v.fallbackAgent)
+	}
+
+	fromAgents := make([]string, 0, len(routes))
+	for fromAgent := range routes {
+		fromAgents = append(fromAgents, fromAgent)
+	}
+	sort.Strings(fromAgents)
+
+	for _, fromAgent := range fromAgents {
+		if !v.isKnownAgent(fromAgent) {
+			report.add(RouteIssueUnknownAgent, SeverityWarning, fromAgent, "",
+				"source agent %q is not a registered agent", fromAgent)
+		}
+
+		// Evaluate in the same order the router does
+		rules := append([]RouteRule(nil), routes[fromAgent]...)
+		sortRulesByPriority(rules)
+
+		v.validateRuleNames(report, fromAgent, rules)
+		for _, rule := range rules {
+			v.validateRule(report, fromAgent, rule)
+		}
+		v.validateReachability(report, fromAgent, rules)
+	}
+
+	v.validateCycles(report, fromAgents, routes)
+
+	return report
+}
+
+func (v *RouteValidator) isKnownAgent(agent string) bool {
+	return len(v.knownAgents) == 0 || v.knownAgents[agent]
+}
+
+// validateRuleNames reports missing and duplicate rule names for one source agent
+func (v *RouteValidator) validateRuleNames(report *RouteValidationReport, fromAgent string, rules []RouteRule) {
+	seen := make(map[string]bool, len(rules))
+	for i, rule := range rules {
+		if rule.Name == "" {
+			report.add(RouteIssueMissingName, SeverityWarning, fromAgent, fmt.Sprintf("#%d", i),
+				"rule has no name, so routed handoffs cannot record which rule selected them")
+			continue
+		}
+		if seen[rule.Name] {
+			report.add(RouteIssueDuplicateName, SeverityError, fromAgent, rule.Name,
+				"rule name is used more than once")
+		}
+		seen[rule.Name] = true
+	}
+}
+
+// validateRule checks a rule's target, conditions and transforms
+func (v *RouteValidator) validateRule(report *RouteValidationReport, fromAgent string, rule RouteRule) {
+	targets := rule.Targets()
+	if len(targets) == 0 {
+		report.add(RouteIssueMissingTarget, SeverityError, fromAgent, rule.Name, "rule has no target_agent")
+	}
+
+	seenTargets := make(map[string]bool, len(targets))
+	for _, target := range targets {
+		switch {
+		case target == "":
+			report.add(RouteIssueMissingTarget, SeverityError, fromAgent, rule.Name, "target_agents contains an empty agent name")
+		case seenTargets[target]:
+			report.add(RouteIssueInvalidFanOut, SeverityError, fromAgent, rule.Name,
+				"target agent %q is listed more than once", target)
+		case !v.isKnownAgent(target):
+			report.add(RouteIssueUnknownAgent, SeverityError, fromAgent, rule.Name,
+				"target agent %q is not a registered agent", target)
+		}
+		seenTargets[target] = true
+	}
+
+	if !rule.FailurePolicy.IsValid() {
+		report.add(RouteIssueInvalidFanOut, SeverityError, fromAgent, rule.Name,
+			"unknown failure_policy %q", rule.FailurePolicy)
+	}
+
+	for _, condition := range rule.Conditions {
+		v.validateCondition(report, fromAgent, rule.Name, condition)
+	}
+
+	for _, transform := range rule.Transforms {
+		if err := CheckRouteTransform(transform); err != nil {
+			report.add(RouteIssueInvalidTransform, SeverityError, fromAgent, rule.Name,
+				"invalid transform: %v", err)
+		}
+	}
+}
+
+// validateCondition checks a single condition against what the router can evaluate
+func (v *RouteValidator) validateCondition(report *RouteValidationReport, fromAgent, ruleName string, condition RouteCondition) {
+	fields, knownType := routeConditionFields[condition.Type]
+	if !knownType {
+		report.add(RouteIssueUnknownCondition, SeverityError, fromAgent, ruleName,
+			"unknown condition type %q", condition.Type)
+		return
+	}
+
+	if condition.Field == "" || (fields != nil && !fields[condition.Field]) {
+		report.add(RouteIssueUnknownField, SeverityError, fromAgent, ruleName,
+			"unknown %s condition field %q", condition.Type, condition.Field)
+	}
+
+	// Complex queries are boolean checks that ignore the operator and value
+	if condition.Type == ConditionComplexQuery {
+		if op := routeOperatorAliases[condition.Operator]; op != "equals" || condition.Value != true {
+			report.add(RouteIssueIgnoredOperator, SeverityWarning, fromAgent, ruleName,
+				"complex query %q only matches when true; operator %q and value %v are ignored",
+				condition.Field, condition.Operator, condition.Value)
+		}
+		return
+	}
+
+	operator, ok := routeOperatorAliases[condition.Operator]
+	if !ok {
+		report.add(RouteIssueUnknownOperator, SeverityError, fromAgent, ruleName,
+			"unknown operator %q on field %q", condition.Operator, condition.Field)
+		return
+	}
+
+	switch {
+	case operator == "regex":
+		pattern, isString := condition.Value.(string)
+		if !isString {
+			report.add(RouteIssueInvalidRegex, SeverityError, fromAgent, ruleName,
+				"regex value for field %q must be a string, got %T", condition.Field, condition.Value)
+		} else if _, err := regexp.Compile(pattern); err != nil {
+			report.add(RouteIssueInvalidRegex, SeverityError, fromAgent, ruleName,
+				"invalid regex for field %q: %v", condition.Field, err)
+		}
+	case operator == "in":
+		if _, isList := condition.Value.([]interface{}); !isList {
+			report.add(RouteIssueInvalidValue, SeverityError, fromAgent, ruleName,
+				"operator \"in\" on field %q needs a list value, got %T", condition.Field, condition.Value)
+		}
+	case routeNumericOperators[operator]:
+		if _, isNumber := toFloat64(condition.Value); !isNumber {
+			report.add(RouteIssueInvalidValue, SeverityError, fromAgent, ruleName,
+				"operator %q on field %q needs a numeric value, got %T", condition.Operator, condition.Field, condition.Value)
+		}
+	}
+}
+
+// validateReachability reports rules that can never be selected because an
+// earlier rule's conditions are a subset of theirs, so it always matches first
+func (v *RouteValidator) validateReachability(report *RouteValidationReport, fromAgent string, rules []RouteRule) {
+	keys := make([]map[string]bool, len(rules))
+	for i, rule := range rules {
+		keys[i] = make(map[string]bool, len(rule.Conditions))
+		for _, condition := range rule.Conditions {
+			keys[i][conditionKey(condition)] = true
+		}
+	}
+
+	for j := range rules {
+		for i := 0; i < j; i++ {
+			if !isConditionSubset(keys[i], keys[j]) {
+				continue
+			}
+			if len(keys[i]) == 0 {
+				report.add(RouteIssueUnreachable, SeverityWarning, fromAgent, rules[j].Name,
+					"rule is unreachable: %q has no conditions and always matches first", rules[i].Name)
+			} else {
+				report.add(RouteIssueUnreachable, SeverityWarning, fromAgent, rules[j].Name,
+					"rule is shadowed by %q (priority %d), which matches whenever this rule does",
+					rules[i].Name, rules[i].Priority)
+			}
+			break
+		}
+	}
+}
+
+// conditionKey normalizes a condition so equivalent conditions compare equal
+func conditionKey(condition RouteCondition) string {
+	operator := condition.Operator
+	if canonical, ok := routeOperatorAliases[operator]; ok {
+		operator = canonical
+	}
+	if condition.Type == ConditionComplexQuery {
+		return fmt.Sprintf("%s|%s", condition.Type, condition.Field)
+	}
+	return fmt.Sprintf("%s|%s|%s|%t|%v", condition.Type, condition.Field, operator, condition.CaseSensitive, condition.Value)
+}
+
+func isConditionSubset(subset, superset map[string]bool) bool {
+	for key := range subset {
+		if !superset[key] {
+			return false
+		}
+	}
+	return true
+}
+
+// validateCycles reports agents that can route handoffs back to themselves
+func (v *RouteValidator) validateCycles(report *RouteValidationReport, fromAgents []string, routes map[string][]RouteRule) {
+	graph := make(map[string][]string, len(routes))
+	for _, fromAgent := range fromAgents {
+		seen := make(map[string]bool)
+		for _, rule := range routes[fromAgent] {
+			for _, target := range rule.Targets() {
+				if target != "" && !seen[target] {
+					seen[target] = true
+					graph[fromAgent] = append(graph[fromAgent], target)
+				}
+			}
+		}
+		sort.Strings(graph[fromAgent])
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		done
+	)
+	state := make(map[string]int, len(graph))
+	reported := make(map[string]bool)
+	var path []string
+
+	var visit func(agent string)
+	visit = func(agent string) {
+		state[agent] = visiting
+		path = append(path, agent)
+
+		for _, next := range graph[agent] {
+			switch state[next] {
+			case unvisited:
+				visit(next)
+			case visiting:
+				// Back edge: the cycle is the path from next to here
+				start := 0
+				for i, a := range path {
+					if a == next {
+						start = i
+						break
+					}
+				}
+				cycle := append(append([]string(nil), path[start:]...), next)
+
+				members := append([]string(nil), cycle[:len(cycle)-1]...)
+				sort.Strings(members)
+				key := strings.Join(members, ",")
+				if !reported[key] {
+					reported[key] = true
+					report.add(RouteIssueCycle, SeverityWarning, next, "",
+						"routing cycle %s", strings.Join(cycle, " -> "))
+				}
+			}
+		}
+
+		path = path[:len(path)-1]
+		state[agent] = done
+	}
+
+	for _, agent := range fromAgents {
+		if state[agent] == unvisited {
+			visit(agent)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package handoff
+
+import (
+	"testing"
+)
+
+var testKnownAgents = []string{"api-expert", "golang-expert", "test-expert", "project-manager"}
+
+func issueCodes(report *RouteValidationReport) map[RouteIssueCode]int {
+	codes := make(map[RouteIssueCode]int)
+	for _, issue := range report.Issues {
+		codes[issue.Code]++
+	}
+	return codes
+}
+
+func TestRouteValidatorAcceptsValidConfig(t *testing.T) {
+	report := newTestRouter().Validate(testKnownAgents)
+
+	if len(report.Issues) != 0 {
+		t.Errorf("expected no issues, got %v", report.Issues)
+	}
+	if report.Err() != nil {
+		t.Errorf("expected no error, got %v", report.Err())
+	}
+}
+
+func TestRouteValidatorReportsRuleErrors(t *testing.T) {
+	routes := map[string][]RouteRule{
+		"api-expert": {
+			{
+				Name:        "unknown-target",
+				TargetAgent: "rust-expert",
+				Priority:    100,
+				Conditions:  []RouteCondition{{Type: ConditionContent, Field: "summary", Operator: "contains", Value: "rust"}},
+				Transforms:  []RouteTransform{{Type: TransformContent, Field: "title", Action: TransformActionSet, Value: "x"}},
+			},
+			{
+				Name:        "bad-conditions",
+				TargetAgent: "golang-expert",
+				Priority:    90,
+				Conditions: []RouteCondition{
+					{Type: "semantic", Field: "summary", Operator: "equals", Value: "x"},
+					{Type: ConditionMetadata, Field: "owner", Operator: "equals", Value: "x"},
+					{Type: ConditionContent, Field: "summary", Operator: "like", Value: "x"},
+					{Type: ConditionContent, Field: "summary", Operator: "regex", Value: "(unclosed"},
+					{Type: ConditionArtifact, Field: "created_count", Operator: "gt", Value: "three"},
+				},
+			},
+			{
+				Name:        "bad-conditions",
+				TargetAgent: "test-expert",
+				Priority:    80,
+				Conditions:  []RouteCondition{{Type: ConditionContent, Field: "summary", Operator: "contains", Value: "test"}},
+			},
+		},
+	}
+
+	report := NewRouteValidator(testKnownAgents, "project-manager").Validate(routes)
+	codes := issueCodes(report)
+
+	expected := map[RouteIssueCode]int{
+		RouteIssueUnknownAgent:     1,
+		RouteIssueUnknownCondition: 1,
+		RouteIssueUnknownField:     1,
+		RouteIssueUnknownOperator:  1,
+		RouteIssueInvalidRegex:     1,
+		RouteIssueInvalidValue:     1,
+		RouteIssueDuplicateName:    1,
+		RouteIssueInvalidTransform: 1,
+	}
+	for code, count := range expected {
+		if codes[code] != count {
+			t.Errorf("expected %d %s issues, got %d (%v)", count, code, codes[code], report.Issues)
+		}
+	}
+	if !report.HasErrors() || report.Err() == nil {
+		t.Error("expected report to have errors")
+	}
+}
+
+func TestRouteValidatorReportsShadowedRules(t *testing.T) {
+	goFiles := RouteCondition{Type: ConditionComplexQuery, Field: "has_go_files", Operator: "equals", Value: true}
+	tests := RouteCondition{Type: ConditionComplexQuery, Field: "has_test_files", Operator: "equals", Value: true}
+
+	routes := map[string][]RouteRule{
+		"api-expert": {
+			{Name: "go", TargetAgent: "golang-expert", Priority: 100, Conditions: []RouteCondition{goFiles}},
+			{Name: "go-tests", TargetAgent: "test-expert", Priority: 90, Conditions: []RouteCondition{goFiles, tests}},
+			{Name: "tests", TargetAgent: "test-expert", Priority: 80, Conditions: []RouteCondition{tests}},
+		},
+		"golang-expert": {
+			{Name: "catch-all", TargetAgent: "test-expert", Priority: 100},
+			{Name: "never", TargetAgent: "api-expert", Priority: 50, Conditions: []RouteCondition{tests}},
+		},
+	}
+
+	report := NewRouteValidator(testKnownAgents, "").Validate(routes)
+
+	shadowed := make(map[string]bool)
+	for _, issue := range report.Issues {
+		if issue.Code == RouteIssueUnreachable {
+			shadowed[issue.FromAgent+"/"+issue.Rule] = true
+		}
+	}
+	if !shadowed["api-expert/go-tests"] || !shadowed["golang-expert/never"] {
+		t.Errorf("expected go-tests and never to be unreachable, got %v", report.Issues)
+	}
+	if shadowed["api-expert/tests"] {
+		t.Error("tests rule is reachable and should not be reported")
+	}
+	if report.HasErrors() {
+		t.Errorf("shadowed rules should be warnings, got errors %v", report.Errors())
+	}
+}
+
+func TestRouteValidatorReportsCycles(t *testing.T) {
+	always := []RouteCondition{{Type: ConditionContent, Field: "summary", Operator: "contains", Value: "review"}}
+	routes := map[string][]RouteRule{
+		"api-expert":    {{Name: "to-go", TargetAgent: "golang-expert", Conditions: always}},
+		"golang-expert": {{Name: "to-test", TargetAgent: "test-expert", Conditions: always}},
+		"test-expert":   {{Name: "back-to-api", TargetAgent: "api-expert", Conditions: always}},
+	}
+
+	report := NewRouteValidator(testKnownAgents, "").Validate(routes)
+
+	var cycles []RouteIssue
+	for _, issue := range report.Issues {
+		if issue.Code == RouteIssueCycle {
+			cycles = append(cycles, issue)
+		}
+	}
+	if len(cycles) != 1 {
+		t.Fatalf("expected one cycle, got %v", report.Issues)
+	}
+	if cycles[0].Message != "routing cycle api-expert -> golang-expert -> test-expert -> api-expert" {
+		t.Errorf("unexpected cycle message: %s", cycles[0].Message)
+	}
+}
@@ -3,14 +3,14 @@ package handoff
 import (
 	"context"
 	"fmt"
-	"regexp"
+	"sort"
 	"strings"
 	"sync"
 )
 
 // HandoffRouter manages intelligent routing of handoffs to appropriate agents
 type HandoffRouter struct {
-	routes        map[string][]RouteRule
+	routes        map[string][]compiledRule
 	fallbackAgent string
 	routesMutex   sync.RWMutex
 }
@@ -22,6 +22,21 @@ type RouteRule struct {
 	Priority    int              `json:"priority"` // Higher number = higher priority
 	Conditions  []RouteCondition `json:"conditions"`
 	Transforms  []RouteTransform `json:"transforms,omitempty"`
+
+	// TargetAgents fans the handoff out to several agents, overriding TargetAgent
+	TargetAgents  []string            `json:"target_agents,omitempty"`
+	FailurePolicy FanOutFailurePolicy `json:"failure_policy,omitempty"`
+}
+
+// Targets returns every agent the rule routes to
+func (r RouteRule) Targets() []string {
+	if len(r.TargetAgents) > 0 {
+		return r.TargetAgents
+	}
+	if r.TargetAgent == "" {
+		return nil
+	}
+	return []string{r.TargetAgent}
 }
 
 // RouteCondition defines a condition that must be met for the rule to apply
@@ -41,6 +56,29 @@ type RouteTransform struct {
 	Value  interface{}   `json:"value,omitempty"`
 }
 
+// RouteDecision describes the outcome of routing a handoff
+type RouteDecision struct {
+	TargetAgent string `json:"target_agent"`        // First target when the rule fans out
+	RuleName    string `json:"rule_name,omitempty"` // Empty when the requested agent was kept
+
+	// Set when the matching rule fans out to several agents
+	TargetAgents  []string            `json:"target_agents,omitempty"`
+	FailurePolicy FanOutFailurePolicy `json:"failure_policy,omitempty"`
+}
+
+// IsFanOut reports whether the handoff should be split across several agents
+func (d *RouteDecision) IsFanOut() bool {
+	return len(d.TargetAgents) > 1
+}
+
+const (
+	// AutoRouteAgent is the to_agent value producers without an agent of their
+	// own use to opt in to routing; others set Metadata.Route
+	AutoRouteAgent = "auto"
+	// FallbackRouteRule is recorded as the rule name when the fallback agent was used
+	FallbackRouteRule = "fallback"
+)
+
 // ConditionType defines the type of condition
 type ConditionType string
 
@@ -62,83 +100,151 @@ const (
 	TransformPriority  TransformType = "priority"  // Modify priority
 )
 
+// WantsRouting reports whether the handoff opted in to routing, with Route or
+// a to_agent of AutoRouteAgent
+func (m Metadata) WantsRouting() bool {
+	return m.Route || m.ToAgent == AutoRouteAgent
+}
+
+// RequestedTarget returns the agent the producer addressed, or "" when
+// to_agent is AutoRouteAgent
+func (m Metadata) RequestedTarget() string {
+	if m.ToAgent == AutoRouteAgent {
+		return ""
+	}
+	return m.ToAgent
+}
+
 // NewHandoffRouter creates a new handoff router
 func NewHandoffRouter(fallbackAgent string) *HandoffRouter {
 	return &HandoffRouter{
-		routes:        make(map[string][]RouteRule),
+		routes:        make(map[string][]compiledRule),
 		fallbackAgent: fallbackAgent,
 	}
 }
 
-// AddRoute adds a routing rule for a specific source agent
+// AddRoute adds a routing rule for a specific source agent. Conditions are
+// compiled once here; a regex condition with an invalid pattern never matches
+// (RouteValidator reports it).
 func (r *HandoffRouter) AddRoute(fromAgent string, rule RouteRule) {
+	compiled := compileRule(rule)
+
 	r.routesMutex.Lock()
 	defer r.routesMutex.Unlock()
 
-	if r.routes[fromAgent] == nil {
-		r.routes[fromAgent] = make([]RouteRule, 0)
-	}
+	// Insert after every rule of equal or higher priority so the slice stays
+	// in the order sortRulesByPriority would give it
+	rules := r.routes[fromAgent]
+	i := sort.Search(len(rules), func(i int) bool {
+		return rules[i].Priority < rule.Priority
+	})
+	rules = append(rules, compiledRule{})
+	copy(rules[i+1:], rules[i:])
+	rules[i] = compiled
+	r.routes[fromAgent] = rules
+}
+
+// sortRulesByPriority orders rules by priority (higher first), keeping the
+// order rules were added in when priorities are equal
+func sortRulesByPriority(rules []RouteRule) {
+	sort.SliceStable(rules, func(i, j int) bool {
+		return rules[i].Priority > rules[j].Priority
+	})
+}
 
-	r.routes[fromAgent] = append(r.routes[fromAgent], rule)
+// Routes returns a copy of the configured rules, in evaluation order
+func (r *HandoffRouter) Routes() map[string][]RouteRule {
+	r.routesMutex.RLock()
+	defer r.routesMutex.RUnlock()
 
-	// Sort by priority (higher first)
-	rules := r.routes[fromAgent]
-	for i := 0; i < len(rules); i++ {
-		for j := i + 1; j < len(rules); j++ {
-			if rules[i].Priority < rules[j].Priority {
-				rules[i], rules[j] = rules[j], rules[i]
-			}
+	routes := make(map[string][]RouteRule, len(r.routes))
+	for fromAgent, rules := range r.routes {
+		routes[fromAgent] = make([]RouteRule, len(rules))
+		for i, rule := range rules {
+			routes[fromAgent][i] = rule.RouteRule
 		}
 	}
+	return routes
+}
+
+// FallbackAgent returns the agent used when no rule matches
+func (r *HandoffRouter) FallbackAgent() string {
+	return r.fallbackAgent
 }
 
 // RouteHandoff determines the best target agent for a handoff
 func (r *HandoffRouter) RouteHandoff(ctx context.Context, handoff *Handoff) (string, error) {
+	decision, err := r.Route(ctx, handoff)
+	if err != nil {
+		return "", err
+	}
+	return decision.TargetAgent, nil
+}
+
+// Route determines the target agent for a handoff and reports which rule selected it.
+// A to_agent of AutoRouteAgent is treated as unset, so only rules or the fallback
+// agent can satisfy it.
+func (r *HandoffRouter) Route(ctx context.Context, handoff *Handoff) (*RouteDecision, error) {
 	r.routesMutex.RLock()
 	defer r.routesMutex.RUnlock()
 
+	requested := handoff.Metadata.RequestedTarget()
+
 	fromAgent := handoff.Metadata.FromAgent
 	rules, exists := r.routes[fromAgent]
 
 	if !exists || len(rules) == 0 {
 		// No specific rules, use the target agent from handoff or fallback
-		if handoff.Metadata.ToAgent != "" {
-			return handoff.Metadata.ToAgent, nil
+		if requested != "" {
+			return &RouteDecision{TargetAgent: requested}, nil
 		}
 		if r.fallbackAgent != "" {
-			return r.fallbackAgent, nil
+			return &RouteDecision{TargetAgent: r.fallbackAgent, RuleName: FallbackRouteRule}, nil
 		}
-		return "", fmt.Errorf("no routing rules found for agent %s and no fallback configured", fromAgent)
+		return nil, fmt.Errorf("no routing rules found for agent %s and no fallback configured", fromAgent)
 	}
 
 	// Evaluate rules in priority order
-	for _, rule := range rules {
+	for i := range rules {
+		rule := &rules[i]
 		if r.evaluateRule(handoff, rule) {
 			// Apply transforms if specified
 			if err := r.applyTransforms(handoff, rule.Transforms); err != nil {
-				return "", fmt.Errorf("failed to apply transforms for rule %s: %w", rule.Name, err)
+				return nil, fmt.Errorf("failed to apply transforms for rule %s: %w", rule.Name, err)
 			}
 
-			return rule.TargetAgent, nil
+			targets := rule.Targets()
+			if len(targets) == 0 {
+				return nil, fmt.Errorf("rule %s has no target agent", rule.Name)
+			}
+			decision := &RouteDecision{TargetAgent: targets[0], RuleName: rule.Name}
+			if len(targets) > 1 {
+				decision.TargetAgents = append([]string(nil), targets...)
+				decision.FailurePolicy = rule.FailurePolicy
+				if decision.FailurePolicy == "" {
+					decision.FailurePolicy = FanOutFailFast
+				}
+			}
+			return decision, nil
 		}
 	}
 
 	// No rules matched, use original target or fallback
-	if handoff.Metadata.ToAgent != "" {
-		return handoff.Metadata.ToAgent, nil
+	if requested != "" {
+		return &RouteDecision{TargetAgent: requested}, nil
 	}
 
 	if r.fallbackAgent != "" {
-		return r.fallbackAgent, nil
+		return &RouteDecision{TargetAgent: r.fallbackAgent, RuleName: FallbackRouteRule}, nil
 	}
 
-	return "", fmt.Errorf("no routing rules matched and no fallback configured")
+	return nil, fmt.Errorf("no routing rules matched and no fallback configured")
 }
 
 // evaluateRule checks if all conditions in a rule are met
-func (r *HandoffRouter) evaluateRule(handoff *Handoff, rule RouteRule) bool {
-	for _, condition := range rule.Conditions {
-		if !r.evaluateCondition(handoff, condition) {
+func (r *HandoffRouter) evaluateRule(handoff *Handoff, rule *compiledRule) bool {
+	for i := range rule.conditions {
+		if !r.evaluateCondition(handoff, &rule.conditions[i]) {
 			return false
 		}
 	}
@@ -146,8 +252,8 @@ func (r *HandoffRouter) evaluateRule(handoff *Handoff, rule RouteRule) bool {
 }
 
 // evaluateCondition evaluates a single condition
-func (r *HandoffRouter) evaluateCondition(handoff *Handoff, condition RouteCondition) bool {
-	var value interface{}
+func (r *HandoffRouter) evaluateCondition(handoff *Handoff, condition *compiledCondition) bool {
+	var value routeValue
 
 	switch condition.Type {
 	case ConditionMetadata:
@@ -159,79 +265,79 @@ func (r *HandoffRouter) evaluateCondition(handoff *Handoff, condition RouteCondi
 	case ConditionArtifact:
 		value = r.getArtifactValue(handoff, condition.Field)
 	case ConditionComplexQuery:
-		return r.evaluateComplexQuery(handoff, condition)
+		return r.evaluateComplexQuery(handoff, condition.RouteCondition)
 	default:
 		return false
 	}
 
-	return r.compareValues(value, condition.Operator, condition.Value, condition.CaseSensitive)
+	return condition.matches(value)
 }
 
 // getMetadataValue retrieves a metadata field value
-func (r *HandoffRouter) getMetadataValue(handoff *Handoff, field string) interface{} {
+func (r *HandoffRouter) getMetadataValue(handoff *Handoff, field string) routeValue {
 	switch field {
 	case "from_agent":
-		return handoff.Metadata.FromAgent
+		return stringRouteValue(handoff.Metadata.FromAgent)
 	case "to_agent":
-		return handoff.Metadata.ToAgent
+		return stringRouteValue(handoff.Metadata.ToAgent)
 	case "task_context":
-		return handoff.Metadata.TaskContext
+		return stringRouteValue(handoff.Metadata.TaskContext)
 	case "priority":
-		return string(handoff.Metadata.Priority)
+		return stringRouteValue(string(handoff.Metadata.Priority))
 	case "handoff_id":
-		return handoff.Metadata.HandoffID
+		return stringRouteValue(handoff.Metadata.HandoffID)
 	default:
-		return nil
+		return routeValue{}
 	}
 }
 
 // getContentValue retrieves a content field value
-func (r *HandoffRouter) getContentValue(handoff *Handoff, field string) interface{} {
+func (r *HandoffRouter) getContentValue(handoff *Handoff, field string) routeValue {
 	switch field {
 	case "summary":
-		return handoff.Content.Summary
+		return stringRouteValue(handoff.Content.Summary)
 	case "requirements":
-		return handoff.Content.Requirements
+		return listRouteValue(handoff.Content.Requirements)
 	case "next_steps":
-		return handoff.Content.NextSteps
+		return listRouteValue(handoff.Content.NextSteps)
 	case "requirements_count":
-		return len(handoff.Content.Requirements)
+		return intRouteValue(len(handoff.Content.Requirements))
 	case "next_steps_count":
-		return len(handoff.Content.NextSteps)
+		return intRouteValue(len(handoff.Content.NextSteps))
 	default:
-		return nil
+		return routeValue{}
 	}
 }
 
 // getTechnicalValue retrieves a technical details value
-func (r *HandoffRouter) getTechnicalValue(handoff *Handoff, field string) interface{} {
+func (r *HandoffRouter) getTechnicalValue(handoff *Handoff, field string) routeValue {
 	if handoff.Content.TechnicalDetails == nil {
-		return nil
+		return routeValue{}
 	}
-	return handoff.Content.TechnicalDetails[field]
+	return anyRouteValue(handoff.Content.TechnicalDetails[field])
 }
 
 // getArtifactValue retrieves artifact-related values
-func (r *HandoffRouter) getArtifactValue(handoff *Handoff, field string) interface{} {
+func (r *HandoffRouter) getArtifactValue(handoff *Handoff, field string) routeValue {
 	switch field {
 	case "created":
-		return handoff.Content.Artifacts.Created
+		return listRouteValue(handoff.Content.Artifacts.Created)
 	case "modified":
-		return handoff.Content.Artifacts.Modified
+		return listRouteValue(handoff.Content.Artifacts.Modified)
 	case "reviewed":
-		return handoff.Content.Artifacts.Reviewed
+		return listRouteValue(handoff.Content.Artifacts.Reviewed)
 	case "created_count":
-		return len(handoff.Content.Artifacts.Created)
+		return intRouteValue(len(handoff.Content.Artifacts.Created))
 	case "modified_count":
-		return len(handoff.Content.Artifacts.Modified)
+		return intRouteValue(len(handoff.Content.Artifacts.Modified))
 	case "reviewed_count":
-		return len(handoff.Content.Artifacts.Reviewed)
+		return intRouteValue(len(handoff.Content.Artifacts.Reviewed))
 	case "total_artifacts":
-		return len(handoff.Content.Artifacts.Created) +
+		return intRouteValue(len(handoff.Content.Artifacts.Created) +
 			len(handoff.Content.Artifacts.Modified) +
-			len(handoff.Content.Artifacts.Reviewed)
+			len(handoff.Content.Artifacts.Reviewed))
 	default:
-		return nil
+		return routeValue{}
 	}
 }
 
@@ -273,154 +379,40 @@ func (r *HandoffRouter) evaluateComplexQuery(handoff *Handoff, condition RouteCo
 
 // hasFilesWithExtension checks if any artifacts have the specified extension
 func (r *HandoffRouter) hasFilesWithExtension(handoff *Handoff, ext string) bool {
-	allFiles := append(handoff.Content.Artifacts.Created, handoff.Content.Artifacts.Modified...)
-	allFiles = append(allFiles, handoff.Content.Artifacts.Reviewed...)
-
-	for _, file := range allFiles {
-		if strings.HasSuffix(strings.ToLower(file), ext) {
-			return true
-		}
-	}
-	return false
+	return anyArtifact(handoff, func(file string) bool {
+		return hasSuffixFold(file, ext)
+	})
 }
 
 // hasFilesWithPattern checks if any artifacts contain the specified pattern
 func (r *HandoffRouter) hasFilesWithPattern(handoff *Handoff, pattern string) bool {
-	allFiles := append(handoff.Content.Artifacts.Created, handoff.Content.Artifacts.Modified...)
-	allFiles = append(allFiles, handoff.Content.Artifacts.Reviewed...)
-
-	for _, file := range allFiles {
-		if strings.Contains(strings.ToLower(file), strings.ToLower(pattern)) {
-			return true
-		}
-	}
-	return false
-}
-
-// compareValues compares two values using the specified operator
-func (r *HandoffRouter) compareValues(actual interface{}, operator string, expected interface{}, caseSensitive bool) bool {
-	switch operator {
-	case "equals", "eq":
-		return r.valuesEqual(actual, expected, caseSensitive)
-	case "not_equals", "ne":
-		return !r.valuesEqual(actual, expected, caseSensitive)
-	case "contains":
-		return r.valueContains(actual, expected, caseSensitive)
-	case "not_contains":
-		return !r.valueContains(actual, expected, caseSensitive)
-	case "starts_with":
-		return r.valueStartsWith(actual, expected, caseSensitive)
-	case "ends_with":
-		return r.valueEndsWith(actual, expected, caseSensitive)
-	case "greater_than", "gt":
-		return r.valueGreaterThan(actual, expected)
-	case "less_than", "lt":
-		return r.valueLessThan(actual, expected)
-	case "greater_equal", "ge":
-		return r.valueGreaterEqual(actual, expected)
-	case "less_equal", "le":
-		return r.valueLessEqual(actual, expected)
-	case "in":
-		return r.valueIn(actual, expected, caseSensitive)
-	case "regex":
-		return r.valueMatchesRegex(actual, expected)
-	default:
-		return false
-	}
-}
-
-// Helper comparison functions
-func (r *HandoffRouter) valuesEqual(actual, expected interface{}, caseSensitive bool) bool {
-	if actual == nil || expected == nil {
-		return actual == expected
-	}
-
-	actualStr := fmt.Sprintf("%v", actual)
-	expectedStr := fmt.Sprintf("%v", expected)
-
-	if !caseSensitive {
-		return strings.EqualFold(actualStr, expectedStr)
-	}
-	return actualStr == expectedStr
-}
-
-func (r *HandoffRouter) valueContains(actual, expected interface{}, caseSensitive bool) bool {
-	actualStr := fmt.Sprintf("%v", actual)
-	expectedStr := fmt.Sprintf("%v", expected)
-
-	if !caseSensitive {
-		return strings.Contains(strings.ToLower(actualStr), strings.ToLower(expectedStr))
-	}
-	return strings.Contains(actualStr, expectedStr)
-}
-
-func (r *HandoffRouter) valueStartsWith(actual, expected interface{}, caseSensitive bool) bool {
-	actualStr := fmt.Sprintf("%v", actual)
-	expectedStr := fmt.Sprintf("%v", expected)
-
-	if !caseSensitive {
-		return strings.HasPrefix(strings.ToLower(actualStr), strings.ToLower(expectedStr))
-	}
-	return strings.HasPrefix(actualStr, expectedStr)
-}
-
-func (r *HandoffRouter) valueEndsWith(actual, expected interface{}, caseSensitive bool) bool {
-	actualStr := fmt.Sprintf("%v", actual)
-	expectedStr := fmt.Sprintf("%v", expected)
-
-	if !caseSensitive {
-		return strings.HasSuffix(strings.ToLower(actualStr), strings.ToLower(expectedStr))
-	}
-	return strings.HasSuffix(actualStr, expectedStr)
-}
-
-func (r *HandoffRouter) valueGreaterThan(actual, expected interface{}) bool {
-	actualNum, ok1 := r.toFloat64(actual)
-	expectedNum, ok2 := r.toFloat64(expected)
-	return ok1 && ok2 && actualNum > expectedNum
-}
-
-func (r *HandoffRouter) valueLessThan(actual, expected interface{}) bool {
-	actualNum, ok1 := r.toFloat64(actual)
-	expectedNum, ok2 := r.toFloat64(expected)
-	return ok1 && ok2 && actualNum < expectedNum
-}
-
-func (r *HandoffRouter) valueGreaterEqual(actual, expected interface{}) bool {
-	actualNum, ok1 := r.toFloat64(actual)
-	expectedNum, ok2 := r.toFloat64(expected)
-	return ok1 && ok2 && actualNum >= expectedNum
-}
-
-func (r *HandoffRouter) valueLessEqual(actual, expected interface{}) bool {
-	actualNum, ok1 := r.toFloat64(actual)
-	expectedNum, ok2 := r.toFloat64(expected)
-	return ok1 && ok2 && actualNum <= expectedNum
-}
-
-func (r *HandoffRouter) valueIn(actual, expected interface{}, caseSensitive bool) bool {
-	expectedSlice, ok := expected.([]interface{})
-	if !ok {
-		return false
-	}
-
-	for _, item := range expectedSlice {
-		if r.valuesEqual(actual, item, caseSensitive) {
-			return true
+	pattern = strings.ToLower(pattern)
+	return anyArtifact(handoff, func(file string) bool {
+		return strings.Contains(strings.ToLower(file), pattern)
+	})
+}
+
+// anyArtifact reports whether match holds for any created, modified or reviewed
+// artifact. It walks the slices in place rather than appending them together,
+// which could write into the spare capacity of the Created backing array.
+func anyArtifact(handoff *Handoff, match func(string) bool) bool {
+	artifacts := &handoff.Content.Artifacts
+	for _, files := range [...][]string{artifacts.Created, artifacts.Modified, artifacts.Reviewed} {
+		for _, file := range files {
+			if match(file) {
+				return true
+			}
 		}
 	}
 	return false
 }
 
-func (r *HandoffRouter) valueMatchesRegex(actual, expected interface{}) bool {
-	actualStr := fmt.Sprintf("%v", actual)
-	pattern := fmt.Sprintf("%v", expected)
-
-	matched, err := regexp.MatchString(pattern, actualStr)
-	return err == nil && matched
+// hasSuffixFold reports whether s ends with the lowercase suffix, ignoring case
+func hasSuffixFold(s, suffix string) bool {
+	return len(s) >= len(suffix) && strings.EqualFold(s[len(s)-len(suffix):], suffix)
 }
 
-func (r *HandoffRouter) toFloat64(value interface{}) (float64, bool) {
+func toFloat64(value interface{}) (float64, bool) {
 	switch v := value.(type) {
 	case float64:
 		return v, true
@@ -436,112 +428,3 @@ func (r *HandoffRouter) toFloat64(value interface{}) (float64, bool) {
 		return 0, false
 	}
 }
-
-// applyTransforms applies route transforms to a handoff
-func (r *HandoffRouter) applyTransforms(handoff *Handoff, transforms []RouteTransform) error {
-	for _, transform := range transforms {
-		if err := r.applyTransform(handoff, transform); err != nil {
-			return fmt.Errorf("transform failed: %w", err)
-		}
-	}
-	return nil
-}
-
-// applyTransform applies a single transform
-func (r *HandoffRouter) applyTransform(handoff *Handoff, transform RouteTransform) error {
-	switch transform.Type {
-	case TransformMetadata:
-		return r.transformMetadata(handoff, transform)
-	case TransformContent:
-		return r.transformContent(handoff, transform)
-	case TransformTechnical:
-		return r.transformTechnical(handoff, transform)
-	case TransformPriority:
-		return r.transformPriority(handoff, transform)
-	default:
-		return fmt.Errorf("unknown transform type: %s", transform.Type)
-	}
-}
-
-func (r *HandoffRouter) transformMetadata(handoff *Handoff, transform RouteTransform) error {
-	switch transform.Action {
-	case "set":
-		switch transform.Field {
-		case "task_context":
-			if val, ok := transform.Value.(string); ok {
-				handoff.Metadata.TaskContext = val
-			}
-		case "priority":
-			if val, ok := transform.Value.(string); ok {
-				handoff.Metadata.Priority = Priority(val)
-			}
-		}
-	case "append":
-		switch transform.Field {
-		case "task_context":
-			if val, ok := transform.Value.(string); ok {
-				handoff.Metadata.TaskContext += " " + val
-			}
-		}
-	}
-	return nil
-}
-
-func (r *HandoffRouter) transformContent(handoff *Handoff, transform RouteTransform) error {
-	switch transform.Action {
-	case "set":
-		switch transform.Field {
-		case "summary":
-			if val, ok := transform.Value.(string); ok {
-				handoff.Content.Summary = val
-			}
-		}
-	case "append":
-		switch transform.Field {
-		case "summary":
-			if val, ok := transform.Value.(string); ok {
-				handoff.Content.Summary += " " + val
-			}
-		case "requirements":
-			if val, ok := transform.Value.(string); ok {
-				handoff.Content.Requirements = append(handoff.Content.Requirements, val)
-			}
-		case "next_steps":
-			if val, ok := transform.Value.(string); ok {
-				handoff.Content.NextSteps = append(handoff.Content.NextSteps, val)
-			}
-		}
-	}
-	return nil
-}
-
-func (r *HandoffRouter) transformTechnical(handoff *Handoff, transform RouteTransform) error {
-	if handoff.Content.TechnicalDetails == nil {
-		handoff.Content.TechnicalDetails = make(map[string]interface{})
-	}
-
-	switch transform.Action {
-	case "set":
-		handoff.Content.TechnicalDetails[transform.Field] = transform.Value
-	case "append":
-		if existing, exists := handoff.Content.TechnicalDetails[transform.Field]; exists {
-			if existingSlice, ok := existing.([]interface{}); ok {
-				if val, ok := transform.Value.(string); ok {
-					handoff.Content.TechnicalDetails[transform.Field] = append(existingSlice, val)
-				}
-			}
-		} else {
-			if val, ok := transform.Value.(string); ok {
-				handoff.Content.TechnicalDetails[transform.Field] = []interface{}{val}
-			}
-		}
-	}
-	return nil
-}
-
-func (r *HandoffRouter) transformPriority(handoff *Handoff, transform RouteTransform) error {
-	if val, ok := transform.Value.(string); ok {
-		handoff.Metadata.Priority = Priority(val)
-	}
-	return nil
-}
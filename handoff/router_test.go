@@ -0,0 +1,295 @@
+package handoff
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+func newTestRouter() *HandoffRouter {
+	router := NewHandoffRouter("project-manager")
+	router.AddRoute("api-expert", RouteRule{
+		Name:        "route-go-implementation",
+		TargetAgent: "golang-expert",
+		Priority:    100,
+		Conditions: []RouteCondition{
+			{Type: ConditionComplexQuery, Field: "has_go_files", Operator: "equals", Value: true},
+		},
+	})
+	return router
+}
+
+func TestRouteRecordsMatchingRule(t *testing.T) {
+	router := newTestRouter()
+	h := &Handoff{
+		Metadata: Metadata{FromAgent: "api-expert", ToAgent: AutoRouteAgent},
+		Content:  Content{Artifacts: Artifacts{Created: []string{"user.go"}}},
+	}
+
+	decision, err := router.Route(context.Background(), h)
+	if err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if decision.TargetAgent != "golang-expert" {
+		t.Errorf("expected golang-expert, got %s", decision.TargetAgent)
+	}
+	if decision.RuleName != "route-go-implementation" {
+		t.Errorf("expected rule route-go-implementation, got %q", decision.RuleName)
+	}
+}
+
+func TestRouteAutoFallsBackWhenNoRuleMatches(t *testing.T) {
+	router := newTestRouter()
+	h := &Handoff{
+		Metadata: Metadata{FromAgent: "api-expert", ToAgent: AutoRouteAgent},
+		Content:  Content{Artifacts: Artifacts{Created: []string{"index.ts"}}},
+	}
+
+	decision, err := router.Route(context.Background(), h)
+	if err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if decision.TargetAgent != "project-manager" || decision.RuleName != FallbackRouteRule {
+		t.Errorf("expected fallback to project-manager, got %+v", decision)
+	}
+}
+
+func TestRouteKeepsExplicitTarget(t *testing.T) {
+	router := newTestRouter()
+	h := &Handoff{Metadata: Metadata{FromAgent: "test-expert", ToAgent: "devops-expert"}}
+
+	decision, err := router.Route(context.Background(), h)
+	if err != nil {
+		t.Fatalf("Route failed: %v", err)
+	}
+	if decision.TargetAgent != "devops-expert" || decision.RuleName != "" {
+		t.Errorf("expected explicit target to be kept, got %+v", decision)
+	}
+}
+
+func TestApplyRoutingRecordsProvenance(t *testing.T) {
+	agent := &OptimizedHandoffAgent{router: newTestRouter()}
+	h := &Handoff{
+		Metadata: Metadata{FromAgent: "api-expert", ToAgent: AutoRouteAgent},
+		Content:  Content{Artifacts: Artifacts{Modified: []string{"handlers/user.go"}}},
+	}
+
+	if _, err := agent.applyRouting(context.Background(), h); err != nil {
+		t.Fatalf("applyRouting failed: %v", err)
+	}
+	if h.Metadata.ToAgent != "golang-expert" {
+		t.Errorf("expected to_agent golang-expert, got %s", h.Metadata.ToAgent)
+	}
+	if h.Metadata.RequestedAgent != "" {
+		t.Errorf("expected no requested_agent for %q, got %q", AutoRouteAgent, h.Metadata.RequestedAgent)
+	}
+	if h.Metadata.RouteRule != "route-go-implementation" {
+		t.Errorf("expected route_rule route-go-implementation, got %q", h.Metadata.RouteRule)
+	}
+}
+
+func TestApplyRoutingKeepsRequestedAgent(t *testing.T) {
+	agent := &OptimizedHandoffAgent{router: newTestRouter()}
+
+	// A matching rule overrides the producer's choice, which is recorded
+	routed := &Handoff{
+		Metadata: Metadata{FromAgent: "api-expert", ToAgent: "devops-expert", Route: true},
+		Content:  Content{Artifacts: Artifacts{Modified: []string{"handlers/user.go"}}},
+	}
+	if _, err := agent.applyRouting(context.Background(), routed); err != nil {
+		t.Fatalf("applyRouting failed: %v", err)
+	}
+	if routed.Metadata.ToAgent != "golang-expert" || routed.Metadata.RequestedAgent != "devops-expert" || routed.Metadata.RouteRule != "route-go-implementation" {
+		t.Errorf("expected golang-expert routed from devops-expert, got %+v", routed.Metadata)
+	}
+
+	// Without a matching rule the requested agent is kept
+	kept := &Handoff{
+		Metadata: Metadata{FromAgent: "api-expert", ToAgent: "devops-expert", Route: true},
+		Content:  Content{Artifacts: Artifacts{Modified: []string{"deploy.yaml"}}},
+	}
+	if _, err := agent.applyRouting(context.Background(), kept); err != nil {
+		t.Fatalf("applyRouting failed: %v", err)
+	}
+	if kept.Metadata.ToAgent != "devops-expert" || kept.Metadata.RequestedAgent != "devops-expert" || kept.Metadata.RouteRule != "" {
+		t.Errorf("expected devops-expert to be kept, got %+v", kept.Metadata)
+	}
+}
+
+func TestApplyRoutingWithoutRouter(t *testing.T) {
+	agent := &OptimizedHandoffAgent{}
+
+	explicit := &Handoff{Metadata: Metadata{FromAgent: "api-expert", ToAgent: "golang-expert"}}
+	if _, err := agent.applyRouting(context.Background(), explicit); err != nil {
+		t.Errorf("explicit target should not require a router: %v", err)
+	}
+
+	auto := &Handoff{Metadata: Metadata{FromAgent: "api-expert", ToAgent: AutoRouteAgent}}
+	if _, err := agent.applyRouting(context.Background(), auto); err == nil {
+		t.Error("expected error when auto routing is requested without a router")
+	}
+	flagged := &Handoff{Metadata: Metadata{FromAgent: "api-expert", ToAgent: "golang-expert", Route: true}}
+	if _, err := agent.applyRouting(context.Background(), flagged); err == nil {
+		t.Error("expected error when routing is requested without a router")
+	}
+}
+
+func TestRouteConditionOperators(t *testing.T) {
+	h := &Handoff{
+		Metadata: Metadata{FromAgent: "api-expert", TaskContext: "Billing-API", Priority: PriorityHigh},
+		Content: Content{
+			Summary:          "Implement invoice endpoints",
+			Artifacts:        Artifacts{Created: []string{"api/invoices.go", "api/invoices_test.go"}},
+			TechnicalDetails: map[string]interface{}{"coverage": 82.5, "language": "go"},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		condition RouteCondition
+		expected  bool
+	}{
+		{"equals ignores case", RouteCondition{Type: ConditionMetadata, Field: "task_context", Operator: "eq", Value: "billing-api"}, true},
+		{"equals case sensitive", RouteCondition{Type: ConditionMetadata, Field: "task_context", Operator: "equals", Value: "billing-api", CaseSensitive: true}, false},
+		{"not equals nil", RouteCondition{Type: ConditionTechnical, Field: "missing", Operator: "ne", Value: nil}, false},
+		{"contains", RouteCondition{Type: ConditionContent, Field: "summary", Operator: "contains", Value: "INVOICE"}, true},
+		{"starts with", RouteCondition{Type: ConditionContent, Field: "summary", Operator: "starts_with", Value: "implement"}, true},
+		{"greater than", RouteCondition{Type: ConditionTechnical, Field: "coverage", Operator: "gt", Value: 80}, true},
+		{"less equal count", RouteCondition{Type: ConditionArtifact, Field: "created_count", Operator: "le", Value: 1}, false},
+		{"in list", RouteCondition{Type: ConditionMetadata, Field: "priority", Operator: "in", Value: []interface{}{"critical", "high"}}, true},
+		{"in requires a list", RouteCondition{Type: ConditionMetadata, Field: "priority", Operator: "in", Value: "high"}, false},
+		{"regex over list", RouteCondition{Type: ConditionArtifact, Field: "created", Operator: "regex", Value: `_test\.go\b`}, true},
+		{"invalid regex never matches", RouteCondition{Type: ConditionContent, Field: "summary", Operator: "regex", Value: "(unclosed"}, false},
+		{"unknown operator never matches", RouteCondition{Type: ConditionContent, Field: "summary", Operator: "like", Value: "x"}, false},
+	}
+
+	router := NewHandoffRouter("")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			condition := compileCondition(tt.condition)
+			if got := router.evaluateCondition(h, &condition); got != tt.expected {
+				t.Errorf("expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestStringOfMatchesFmt(t *testing.T) {
+	values := []interface{}{nil, "go", true, 42, int64(-7), int32(3), 82.5, 1e21, float32(0.1), []string{"a", "b"}, []interface{}{"x", 1}}
+	for _, value := range values {
+		if got, want := stringOf(value), fmt.Sprintf("%v", value); got != want {
+			t.Errorf("stringOf(%#v) = %q, want %q", value, got, want)
+		}
+	}
+}
+
+func TestArtifactQueriesDoNotModifyArtifacts(t *testing.T) {
+	created := make([]string, 1, 4)
+	created[0] = "main.go"
+	h := &Handoff{
+		Metadata: Metadata{FromAgent: "api-expert", ToAgent: AutoRouteAgent},
+		Content: Content{Artifacts: Artifacts{
+			Created:  created,
+			Modified: []string{"Dockerfile"},
+			Reviewed: []string{"service_test.go"},
+		}},
+	}
+
+	router := NewHandoffRouter("project-manager")
+	if !router.hasFilesWithPattern(h, "docker") || !router.hasFilesWithExtension(h, "_test.go") {
+		t.Fatal("expected modified and reviewed artifacts to be searched")
+	}
+	if spare := created[:cap(created)]; spare[1] != "" || spare[2] != "" {
+		t.Errorf("artifact queries wrote into Created's spare capacity: %q", spare)
+	}
+}
+
+// newBenchmarkRouter builds a router with n rules for one source agent, none of
+// which match the benchmark handoff except optionally the lowest priority one
+func newBenchmarkRouter(n int, matchLast bool) *HandoffRouter {
+	router := NewHandoffRouter("project-manager")
+	for i := 0; i < n; i++ {
+		rule := RouteRule{
+			Name:        fmt.Sprintf("rule-%d", i),
+			TargetAgent: "golang-expert",
+			Priority:    n - i,
+			Conditions: []RouteCondition{
+				{Type: ConditionMetadata, Field: "task_context", Operator: "equals", Value: fmt.Sprintf("context-%d", i)},
+				{Type: ConditionContent, Field: "summary", Operator: "contains", Value: fmt.Sprintf("feature %d", i)},
+				{Type: ConditionTechnical, Field: "coverage", Operator: "gt", Value: i},
+				{Type: ConditionArtifact, Field: "created", Operator: "regex", Value: fmt.Sprintf(`^pkg/module%d/.*\.go$`, i)},
+			},
+		}
+		if matchLast && i == n-1 {
+			rule.Conditions = []RouteCondition{
+				{Type: ConditionComplexQuery, Field: "has_go_files", Operator: "equals", Value: true},
+			}
+		}
+		router.AddRoute("api-expert", rule)
+	}
+	return router
+}
+
+func newBenchmarkHandoff() *Handoff {
+	return &Handoff{
+		Metadata: Metadata{FromAgent: "api-expert", ToAgent: AutoRouteAgent, TaskContext: "billing"},
+		Content: Content{
+			Summary:          "Implement invoice endpoints",
+			Artifacts:        Artifacts{Created: []string{"api/invoices.go"}, Modified: []string{"api/routes.go"}},
+			TechnicalDetails: map[string]interface{}{"coverage": -1.0},
+		},
+	}
+}
+
+// TestRouteAllocationsIndependentOfRuleCount checks that evaluating a rule does
+// not allocate: routing past 1000 rules costs what routing past 10 does
+func TestRouteAllocationsIndependentOfRuleCount(t *testing.T) {
+	for _, matchLast := range []bool{false, true} {
+		allocs := func(n int) float64 {
+			router := newBenchmarkRouter(n, matchLast)
+			h := newBenchmarkHandoff()
+			ctx := context.Background()
+			return testing.AllocsPerRun(100, func() {
+				h.Metadata.ToAgent = AutoRouteAgent
+				if _, err := router.Route(ctx, h); err != nil {
+					t.Fatal(err)
+				}
+			})
+		}
+		if few, many := allocs(10), allocs(1000); many > few {
+			t.Errorf("matchLast=%v: expected routing past 1000 rules to allocate no more than past 10, got %v and %v allocs", matchLast, many, few)
+		}
+	}
+}
+
+func BenchmarkRoute1kRules(b *testing.B) {
+	for _, bm := range []struct {
+		name      string
+		matchLast bool
+	}{
+		{"Fallback", false},
+		{"LastRuleMatches", true},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			router := newBenchmarkRouter(1000, bm.matchLast)
+			h := newBenchmarkHandoff()
+			ctx := context.Background()
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.Metadata.ToAgent = AutoRouteAgent
+				if _, err := router.Route(ctx, h); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkAddRoute1kRules(b *testing.B) {
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		newBenchmarkRouter(1000, false)
+	}
+}
@@ -0,0 +1,358 @@
+package handoff
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// SecretAction decides what happens to a handoff containing a possible secret
+type SecretAction string
+
+const (
+	SecretActionOff    SecretAction = "off"    // Content is not scanned
+	SecretActionWarn   SecretAction = "warn"   // Findings are reported as warnings and stored as is
+	SecretActionRedact SecretAction = "redact" // Findings are replaced with [REDACTED:<rule>] before storage
+	SecretActionReject SecretAction = "reject" // Findings are validation errors
+)
+
+// SecretRule flags text matching Pattern. When the pattern has a capturing
+// group, only the first group is the secret; otherwise the whole match is.
+// Matches whose Shannon entropy (bits per character) is below MinEntropy are ignored.
+type SecretRule struct {
+	Name       string  `json:"name"`
+	Pattern    string  `json:"pattern"`
+	MinEntropy float64 `json:"min_entropy,omitempty"`
+}
+
+// SecretScanPolicy configures the secret scanner. Rules and Allowlist add to
+// the built-in ones; an allowlist pattern matching a finding suppresses it.
+type SecretScanPolicy struct {
+	Action              SecretAction `json:"action"`
+	Rules               []SecretRule `json:"rules,omitempty"`
+	DisabledRules       []string     `json:"disabled_rules,omitempty"`
+	Allowlist           []string     `json:"allowlist,omitempty"`
+	SensitiveKeyPattern string       `json:"sensitive_key_pattern"`
+	EntropyThreshold    float64      `json:"entropy_threshold"`
+	EntropyMinLength    int          `json:"entropy_min_length"`
+}
+
+// Rule names for findings that do not come from a pattern
+const (
+	secretRuleSensitiveKey = "sensitive-key"
+	secretRuleHighEntropy  = "high-entropy"
+)
+
+// builtinSecretRules covers the credentials agents most often paste into handoffs
+var builtinSecretRules = []SecretRule{
+	{Name: "aws-access-key", Pattern: `\b(?:AKIA|ASIA)[0-9A-Z]{16}\b`},
+	{Name: "github-token", Pattern: `\bgh[pousr]_[A-Za-z0-9]{36,}\b`},
+	{Name: "slack-token", Pattern: `\bxox[abprs]-[A-Za-z0-9-]{10,}`},
+	{Name: "private-key", Pattern: `-----BEGIN [A-Z ]*PRIVATE KEY-----(?s:.*?)(?:-----END [A-Z ]*PRIVATE KEY-----|$)`},
+	{Name: "jwt", Pattern: `\beyJ[A-Za-z0-9_-]{10,}\.eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`},
+	{Name: "connection-string", Pattern: `\b[a-zA-Z][a-zA-Z0-9+.-]*://[^\s:/@]+:([^\s@/]+)@`},
+	{Name: "credential-assignment", Pattern: `(?i)\b(?:password|passwd|pwd|secret|token|api[_-]?key|access[_-]?key|client[_-]?secret)\b["']?\s*[:=]\s*["']?([^\s"',;]{8,})`, MinEntropy: 2.5},
+}
+
+// builtinSecretAllowlist skips placeholders and values that are already redacted
+var builtinSecretAllowlist = []string{
+	`^\[REDACTED:[a-z0-9-]+\]$`,
+	`^\$\{?[A-Za-z_][A-Za-z0-9_]*\}?$`,
+	`^<[^<>]*>$`,
+	`^(?i:x{4,}|\*{4,}|changeme|example|placeholder)$`,
+}
+
+// entropyTokenPattern finds the candidate tokens for the high-entropy check
+var entropyTokenPattern = regexp.MustCompile(`[A-Za-z0-9+/=_-]+`)
+
+// DefaultSecretScanPolicy redacts findings from the built-in rules
+func DefaultSecretScanPolicy() SecretScanPolicy {
+	return SecretScanPolicy{
+		Action:              SecretActionRedact,
+		SensitiveKeyPattern: `(?i)^(password|passwd|secret|client_secret|token|access_token|refresh_token|api_key|apikey|private_key|credentials?)$`,
+		EntropyThreshold:    4.3,
+		EntropyMinLength:    32,
+	}
+}
+
+type compiledSecretRule struct {
+	name       string
+	pattern    *regexp.Regexp
+	minEntropy float64
+}
+
+// secretScanner is a compiled SecretScanPolicy
+type secretScanner struct {
+	action           SecretAction
+	rules            []compiledSecretRule
+	allowlist        []*regexp.Regexp
+	sensitiveKey     *regexp.Regexp
+	entropyThreshold float64
+	entropyMinLength int
+}
+
+func newSecretScanner(p SecretScanPolicy) (*secretScanner, error) {
+	switch p.Action {
+	case SecretActionOff, SecretActionWarn, SecretActionRedact, SecretActionReject:
+	case "":
+		p.Action = SecretActionRedact
+	default:
+		return nil, fmt.Errorf("unknown secrets action %q (expected off, warn, redact or reject)", p.Action)
+	}
+	if p.EntropyThreshold < 0 || p.EntropyMinLength < 0 {
+		return nil, fmt.Errorf("secrets entropy settings cannot be negative")
+	}
+
+	s := &secretScanner{
+		action:           p.Action,
+		entropyThreshold: p.EntropyThreshold,
+		entropyMinLength: p.EntropyMinLength,
+	}
+
+	disabled := make(map[string]bool, len(p.DisabledRules))
+	for _, name := range p.DisabledRules {
+		disabled[name] = true
+	}
+	for _, rule := range append(append([]SecretRule(nil), builtinSecretRules...), p.Rules...) {
+		if disabled[rule.Name] {
+			continue
+		}
+		if rule.Name == "" {
+			return nil, fmt.Errorf("secret rules need a name")
+		}
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("secret rule %s: %w", rule.Name, err)
+		}
+		s.rules = append(s.rules, compiledSecretRule{name: rule.Name, pattern: pattern, minEntropy: rule.MinEntropy})
+	}
+	if disabled[secretRuleHighEntropy] {
+		s.entropyThreshold = 0
+	}
+
+	for _, allow := range append(append([]string(nil), builtinSecretAllowlist...), p.Allowlist...) {
+		pattern, err := regexp.Compile(allow)
+		if err != nil {
+			return nil, fmt.Errorf("secrets allowlist %q: %w", allow, err)
+		}
+		s.allowlist = append(s.allowlist, pattern)
+	}
+
+	if p.SensitiveKeyPattern != "" && !disabled[secretRuleSensitiveKey] {
+		pattern, err := regexp.Compile(p.SensitiveKeyPattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid sensitive_key_pattern: %w", err)
+		}
+		s.sensitiveKey = pattern
+	}
+	return s, nil
+}
+
+var (
+	defaultScannerOnce sync.Once
+	defaultScanner     *secretScanner
+)
+
+func defaultSecretScanner() *secretScanner {
+	defaultScannerOnce.Do(func() {
+		scanner, err := newSecretScanner(DefaultSecretScanPolicy())
+		if err != nil {
+			panic(err)
+		}
+		defaultScanner = scanner
+	})
+	return defaultScanner
+}
+
+// ScanSecrets scans a handoff with DefaultSecretScanPolicy, redacting findings
+// in place. Use HandoffValidator.ScanSecrets to apply a configured policy.
+func ScanSecrets(handoff *Handoff) *HandoffValidationReport {
+	report := &HandoffValidationReport{}
+	defaultSecretScanner().scanHandoff(report, handoff)
+	return report
+}
+
+// RedactSecrets returns text with anything the default rules flag replaced,
+// for use in log output
+func RedactSecrets(text string) string {
+	redacted, _ := defaultSecretScanner().scanString(text)
+	return redacted
+}
+
+// scanHandoff scans the free-text fields of a handoff: task context, summary,
+// requirements, next steps and every string in technical_details
+func (s *secretScanner) scanHandoff(report *HandoffValidationReport, handoff *Handoff) {
+	if s.action == SecretActionOff {
+		return
+	}
+
+	s.scanField(report, "metadata.task_context", &handoff.Metadata.TaskContext)
+	s.scanField(report, "content.summary", &handoff.Content.Summary)
+	for i := range handoff.Content.Requirements {
+		s.scanField(report, indexPath("content.requirements", i), &handoff.Content.Requirements[i])
+	}
+	for i := range handoff.Content.NextSteps {
+		s.scanField(report, indexPath("content.next_steps", i), &handoff.Content.NextSteps[i])
+	}
+	s.scanMap(report, "content.technical_details", handoff.Content.TechnicalDetails)
+}
+
+func (s *secretScanner) scanField(report *HandoffValidationReport, path string, value *string) {
+	redacted, rules := s.scanString(*value)
+	if len(rules) == 0 {
+		return
+	}
+	s.report(report, path, rules)
+	if s.action == SecretActionRedact {
+		*value = redacted
+	}
+}
+
+func (s *secretScanner) scanMap(report *HandoffValidationReport, path string, fields map[string]interface{}) {
+	for _, key := range sortedKeys(fields) {
+		fieldPath := path + "." + key
+		if text, ok := fields[key].(string); ok && s.sensitiveKey != nil && s.sensitiveKey.MatchString(key) {
+			if text != "" && !s.allowed(text) {
+				s.report(report, fieldPath, []string{secretRuleSensitiveKey})
+				if s.action == SecretActionRedact {
+					fields[key] = redactionMarker(secretRuleSensitiveKey)
+				}
+			}
+			continue
+		}
+		fields[key] = s.scanValue(report, fieldPath, fields[key])
+	}
+}
+
+// scanValue scans a decoded JSON value and returns it, redacted if required.
+// Values of other Go types are left alone.
+func (s *secretScanner) scanValue(report *HandoffValidationReport, path string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case string:
+		s.scanField(report, path, &v)
+		return v
+	case []string:
+		for i := range v {
+			s.scanField(report, indexPath(path, i), &v[i])
+		}
+	case []interface{}:
+		for i := range v {
+			v[i] = s.scanValue(report, indexPath(path, i), v[i])
+		}
+	case map[string]interface{}:
+		s.scanMap(report, path, v)
+	}
+	return value
+}
+
+// scanString returns the text with every finding redacted and the names of
+// the rules that matched. Finding values never leave this function.
+func (s *secretScanner) scanString(text string) (string, []string) {
+	var rules []string
+	for _, rule := range s.rules {
+		matches := rule.pattern.FindAllStringSubmatchIndex(text, -1)
+		// Replace from the end so earlier offsets stay valid
+		for i := len(matches) - 1; i >= 0; i-- {
+			start, end := matches[i][0], matches[i][1]
+			if len(matches[i]) >= 4 && matches[i][2] >= 0 {
+				start, end = matches[i][2], matches[i][3]
+			}
+			secret := text[start:end]
+			if s.allowed(secret) || shannonEntropy(secret) < rule.minEntropy {
+				continue
+			}
+			rules = appendUnique(rules, rule.name)
+			text = text[:start] + redactionMarker(rule.name) + text[end:]
+		}
+	}
+
+	if s.entropyThreshold > 0 {
+		matches := entropyTokenPattern.FindAllStringIndex(text, -1)
+		for i := len(matches) - 1; i >= 0; i-- {
+			token := text[matches[i][0]:matches[i][1]]
+			if len(token) < s.entropyMinLength || !mixedCharacterClasses(token) ||
+				shannonEntropy(token) < s.entropyThreshold || s.allowed(token) {
+				continue
+			}
+			rules = appendUnique(rules, secretRuleHighEntropy)
+			text = text[:matches[i][0]] + redactionMarker(secretRuleHighEntropy) + text[matches[i][1]:]
+		}
+	}
+
+	sort.Strings(rules)
+	return text, rules
+}
+
+func (s *secretScanner) allowed(secret string) bool {
+	for _, allow := range s.allowlist {
+		if allow.MatchString(secret) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *secretScanner) report(report *HandoffValidationReport, path string, rules []string) {
+	kind := strings.Join(rules, ", ")
+	switch s.action {
+	case SecretActionReject:
+		report.add(path, ValidationSecret, "contains a possible secret (%s)", kind)
+	case SecretActionWarn:
+		report.warn(path, ValidationSecret, "contains a possible secret (%s)", kind)
+	case SecretActionRedact:
+		report.warn(path, ValidationSecret, "possible secret (%s) was redacted", kind)
+	}
+}
+
+func redactionMarker(rule string) string {
+	return "[REDACTED:" + rule + "]"
+}
+
+// shannonEntropy returns the entropy of s in bits per character
+func shannonEntropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+	counts := make(map[rune]int)
+	total := 0
+	for _, r := range s {
+		counts[r]++
+		total++
+	}
+	entropy := 0.0
+	for _, count := range counts {
+		p := float64(count) / float64(total)
+		entropy -= p * math.Log2(p)
+	}
+	return entropy
+}
+
+// mixedCharacterClasses reports whether a token has upper and lower case
+// letters and digits, which hex digests and UUIDs do not
+func mixedCharacterClasses(token string) bool {
+	var upper, lower, digit bool
+	for _, r := range token {
+		switch {
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	return upper && lower && digit
+}
+
+func appendUnique(values []string, value string) []string {
+	for _, existing := range values {
+		if existing == value {
+			return values
+		}
+	}
+	return append(values, value)
+}
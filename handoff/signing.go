@@ -0,0 +1,385 @@
+package handoff
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// SignatureAlgorithm names how a handoff signature was produced
+type SignatureAlgorithm string
+
+const (
+	SignatureHMACSHA256 SignatureAlgorithm = "hmac-sha256"
+	SignatureEd25519    SignatureAlgorithm = "ed25519"
+)
+
+// Signature binds a handoff's metadata and content, including from_agent, to a
+// key registered for that agent. Value is base64 encoded.
+type Signature struct {
+	Algorithm SignatureAlgorithm `json:"algorithm" yaml:"algorithm"`
+	KeyID     string             `json:"key_id" yaml:"key_id"`
+	Value     string             `json:"value" yaml:"value"`
+}
+
+// SignaturePolicy decides what consumers do with unsigned or badly signed handoffs
+type SignaturePolicy string
+
+const (
+	SignaturePolicyOff        SignaturePolicy = "off"        // Signatures are added when possible but not checked
+	SignaturePolicyReject     SignaturePolicy = "reject"     // Failed handoffs are marked failed and never processed
+	SignaturePolicyQuarantine SignaturePolicy = "quarantine" // Failed handoffs are quarantined for inspection
+)
+
+// ParseSignaturePolicy parses a policy name; an empty name means SignaturePolicyOff
+func ParseSignaturePolicy(name string) (SignaturePolicy, error) {
+	switch policy := SignaturePolicy(strings.ToLower(strings.TrimSpace(name))); policy {
+	case "":
+		return SignaturePolicyOff, nil
+	case SignaturePolicyOff, SignaturePolicyReject, SignaturePolicyQuarantine:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown signature policy %q (expected off, reject or quarantine)", name)
+	}
+}
+
+// Enforced reports whether handoffs must carry a valid signature
+func (p SignaturePolicy) Enforced() bool {
+	return p == SignaturePolicyReject || p == SignaturePolicyQuarantine
+}
+
+var (
+	// ErrSignatureMissing is returned when a handoff carries no signature
+	ErrSignatureMissing = errors.New("handoff is not signed")
+	// ErrSignatureInvalid is returned when a signature does not match the handoff or its key
+	ErrSignatureInvalid = errors.New("handoff signature is invalid")
+	// ErrNoSigningKey is returned when the registry holds no key able to sign for an agent
+	ErrNoSigningKey = errors.New("no signing key for agent")
+)
+
+// signingKey is one key registered for an agent. HMAC keys both sign and
+// verify; Ed25519 keys verify with the public key and sign only if the
+// private key was loaded.
+type signingKey struct {
+	agent     string
+	id        string
+	algorithm SignatureAlgorithm
+	secret    []byte
+	public    ed25519.PublicKey
+	private   ed25519.PrivateKey
+}
+
+func (k *signingKey) canSign() bool {
+	return len(k.secret) > 0 || len(k.private) > 0
+}
+
+func (k *signingKey) sign(message []byte) []byte {
+	if k.algorithm == SignatureHMACSHA256 {
+		mac := hmac.New(sha256.New, k.secret)
+		mac.Write(message)
+		return mac.Sum(nil)
+	}
+	return ed25519.Sign(k.private, message)
+}
+
+func (k *signingKey) verify(message, signature []byte) bool {
+	if k.algorithm == SignatureHMACSHA256 {
+		return hmac.Equal(signature, k.sign(message))
+	}
+	return ed25519.Verify(k.public, message, signature)
+}
+
+// KeyRegistry holds the signing keys of each agent identity. An agent may have
+// several keys so they can be rotated; it signs with the last one added that
+// can sign, and a signature made with any of them verifies.
+type KeyRegistry struct {
+	mu      sync.RWMutex
+	keys    map[string]map[string]*signingKey
+	signers map[string]*signingKey
+}
+
+// NewKeyRegistry creates an empty key registry
+func NewKeyRegistry() *KeyRegistry {
+	return &KeyRegistry{
+		keys:    make(map[string]map[string]*signingKey),
+		signers: make(map[string]*signingKey),
+	}
+}
+
+// AddHMACKey registers a shared secret for an agent
+func (r *KeyRegistry) AddHMACKey(agent, keyID string, secret []byte) error {
+	if len(secret) < 32 {
+		return fmt.Errorf("hmac key %s for %s: secret must be at least 32 bytes", keyID, agent)
+	}
+	return r.add(&signingKey{agent: agent, id: keyID, algorithm: SignatureHMACSHA256, secret: secret})
+}
+
+// AddEd25519Key registers an Ed25519 key for an agent. Consumers only need the
+// public key; private may be nil. If public is nil it is derived from private.
+func (r *KeyRegistry) AddEd25519Key(agent, keyID string, public ed25519.PublicKey, private ed25519.PrivateKey) error {
+	if public == nil && private != nil {
+		public = private.Public().(ed25519.PublicKey)
+	}
+	if len(public) != ed25519.PublicKeySize {
+		return fmt.Errorf("ed25519 key %s for %s: invalid public key", keyID, agent)
+	}
+	if private != nil && !public.Equal(private.Public()) {
+		return fmt.Errorf("ed25519 key %s for %s: private key does not match public key", keyID, agent)
+	}
+	return r.add(&signingKey{agent: agent, id: keyID, algorithm: SignatureEd25519, public: public, private: private})
+}
+
+func (r *KeyRegistry) add(key *signingKey) error {
+	if key.agent == "" || key.id == "" {
+		return fmt.Errorf("signing keys need an agent and a key ID")
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, exists := r.keys[key.agent][key.id]; exists {
+		return fmt.Errorf("duplicate key %s for agent %s", key.id, key.agent)
+	}
+	if r.keys[key.agent] == nil {
+		r.keys[key.agent] = make(map[string]*signingKey)
+	}
+	r.keys[key.agent][key.id] = key
+	if key.canSign() {
+		r.signers[key.agent] = key
+	}
+	return nil
+}
+
+// Agents returns the agents with at least one registered key
+func (r *KeyRegistry) Agents() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return sortedKeys(r.keys)
+}
+
+// CanSign reports whether the registry holds a key able to sign for the agent
+func (r *KeyRegistry) CanSign(agent string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.signers[agent] != nil
+}
+
+// SignContent signs a handoff's metadata and content as the given agent. The
+// metadata and content may be any structs or raw JSON with the handoff layout.
+func (r *KeyRegistry) SignContent(agent string, metadata, content interface{}) (*Signature, error) {
+	r.mu.RLock()
+	key := r.signers[agent]
+	r.mu.RUnlock()
+	if key == nil {
+		return nil, fmt.Errorf("%w %s", ErrNoSigningKey, agent)
+	}
+
+	message, err := canonicalBytes(metadata, content)
+	if err != nil {
+		return nil, err
+	}
+	return &Signature{
+		Algorithm: key.algorithm,
+		KeyID:     key.id,
+		Value:     base64.StdEncoding.EncodeToString(key.sign(message)),
+	}, nil
+}
+
+// VerifyContent checks that a signature over a handoff's metadata and content
+// was made with one of the agent's keys
+func (r *KeyRegistry) VerifyContent(agent string, metadata, content interface{}, signature *Signature) error {
+	if signature == nil || signature.Value == "" {
+		return ErrSignatureMissing
+	}
+
+	r.mu.RLock()
+	key := r.keys[agent][signature.KeyID]
+	r.mu.RUnlock()
+	if key == nil {
+		return fmt.Errorf("%w: unknown key %q for agent %s", ErrSignatureInvalid, signature.KeyID, agent)
+	}
+	if key.algorithm != signature.Algorithm {
+		return fmt.Errorf("%w: key %s is %s, not %s", ErrSignatureInvalid, key.id, key.algorithm, signature.Algorithm)
+	}
+
+	value, err := base64.StdEncoding.DecodeString(signature.Value)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+	message, err := canonicalBytes(metadata, content)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+	if !key.verify(message, value) {
+		return fmt.Errorf("%w: signature does not match key %s for agent %s", ErrSignatureInvalid, key.id, agent)
+	}
+	return nil
+}
+
+// Sign signs the handoff as its from_agent
+func (r *KeyRegistry) Sign(h *Handoff) error {
+	signature, err := r.SignContent(h.Metadata.FromAgent, h.Metadata, h.Content)
+	if err != nil {
+		return err
+	}
+	h.Validation.Signature = signature
+	return nil
+}
+
+// Verify checks the handoff's signature against the keys of its from_agent
+func (r *KeyRegistry) Verify(h *Handoff) error {
+	return r.VerifyContent(h.Metadata.FromAgent, h.Metadata, h.Content, h.Validation.Signature)
+}
+
+// VerifyPayload verifies a handoff stored as raw JSON, either a bare handoff
+// or a HandoffQueueMessage
+func (r *KeyRegistry) VerifyPayload(payload []byte) error {
+	var stored struct {
+		Metadata   json.RawMessage `json:"metadata"`
+		Content    json.RawMessage `json:"content"`
+		Validation Validation      `json:"validation"`
+		Payload    json.RawMessage `json:"payload"`
+	}
+	if err := json.Unmarshal(payload, &stored); err != nil {
+		return fmt.Errorf("%w: payload is not valid JSON: %v", ErrSignatureInvalid, err)
+	}
+	if len(stored.Payload) > 0 && len(stored.Metadata) == 0 {
+		return r.VerifyPayload(stored.Payload)
+	}
+
+	var identity struct {
+		FromAgent string `json:"from_agent"`
+	}
+	if err := json.Unmarshal(nullIfEmpty(stored.Metadata), &identity); err != nil {
+		return fmt.Errorf("%w: %v", ErrSignatureInvalid, err)
+	}
+	return r.VerifyContent(identity.FromAgent, nullIfEmpty(stored.Metadata), nullIfEmpty(stored.Content), stored.Validation.Signature)
+}
+
+// KeyFileEntry describes one key in a key registry file. Relative paths are
+// resolved against the directory of the registry file.
+type KeyFileEntry struct {
+	Agent          string             `json:"agent"`
+	KeyID          string             `json:"key_id"`
+	Algorithm      SignatureAlgorithm `json:"algorithm"`
+	SecretFile     string             `json:"secret_file,omitempty"`
+	PublicKeyFile  string             `json:"public_key_file,omitempty"`
+	PrivateKeyFile string             `json:"private_key_file,omitempty"`
+}
+
+// LoadKeyRegistry reads a JSON key registry file of the form {"keys": [...]}.
+// HMAC secrets are read from raw files; Ed25519 keys from PEM files in the
+// PKIX and PKCS #8 formats written by `openssl genpkey -algorithm ed25519`.
+func LoadKeyRegistry(filename string) (*KeyRegistry, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key registry: %w", err)
+	}
+
+	var file struct {
+		Keys []KeyFileEntry `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse key registry %s: %w", filename, err)
+	}
+
+	registry := NewKeyRegistry()
+	dir := filepath.Dir(filename)
+	for i, entry := range file.Keys {
+		if err := registry.load(dir, entry); err != nil {
+			return nil, fmt.Errorf("key registry %s: keys[%d]: %w", filename, i, err)
+		}
+	}
+	return registry, nil
+}
+
+func (r *KeyRegistry) load(dir string, entry KeyFileEntry) error {
+	read := func(name string) ([]byte, error) {
+		if name == "" {
+			return nil, nil
+		}
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		return os.ReadFile(name)
+	}
+
+	switch entry.Algorithm {
+	case SignatureHMACSHA256:
+		if entry.SecretFile == "" {
+			return fmt.Errorf("hmac key %s needs a secret_file", entry.KeyID)
+		}
+		secret, err := read(entry.SecretFile)
+		if err != nil {
+			return err
+		}
+		return r.AddHMACKey(entry.Agent, entry.KeyID, []byte(strings.TrimSpace(string(secret))))
+
+	case SignatureEd25519:
+		if entry.PublicKeyFile == "" && entry.PrivateKeyFile == "" {
+			return fmt.Errorf("ed25519 key %s needs a public_key_file or private_key_file", entry.KeyID)
+		}
+		var public ed25519.PublicKey
+		var private ed25519.PrivateKey
+		if data, err := read(entry.PublicKeyFile); err != nil {
+			return err
+		} else if data != nil {
+			if public, err = parseEd25519PublicKey(data); err != nil {
+				return fmt.Errorf("%s: %w", entry.PublicKeyFile, err)
+			}
+		}
+		if data, err := read(entry.PrivateKeyFile); err != nil {
+			return err
+		} else if data != nil {
+			if private, err = parseEd25519PrivateKey(data); err != nil {
+				return fmt.Errorf("%s: %w", entry.PrivateKeyFile, err)
+			}
+		}
+		return r.AddEd25519Key(entry.Agent, entry.KeyID, public, private)
+
+	default:
+		return fmt.Errorf("unknown algorithm %q (expected %s or %s)", entry.Algorithm, SignatureHMACSHA256, SignatureEd25519)
+	}
+}
+
+func parseEd25519PublicKey(data []byte) (ed25519.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	public, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("not an ed25519 public key")
+	}
+	return public, nil
+}
+
+func parseEd25519PrivateKey(data []byte) (ed25519.PrivateKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM block found")
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	private, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("not an ed25519 private key")
+	}
+	return private, nil
+}
@@ -0,0 +1,201 @@
+package handoff
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeSigningKeys writes a key registry with an HMAC key for api-expert and an
+// Ed25519 key pair for architect-expert, returning the registry file path
+func writeSigningKeys(t *testing.T, withPrivate bool) string {
+	t.Helper()
+	dir := t.TempDir()
+
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicDER, _ := x509.MarshalPKIXPublicKey(public)
+	privateDER, _ := x509.MarshalPKCS8PrivateKey(private)
+
+	files := map[string][]byte{
+		"api.key":       []byte(strings.Repeat("s", 32) + "\n"),
+		"architect.pub": pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicDER}),
+		"architect.pem": pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privateDER}),
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	privateFile := ""
+	if withPrivate {
+		privateFile = "architect.pem"
+	}
+	registry, _ := json.Marshal(map[string]interface{}{"keys": []KeyFileEntry{
+		{Agent: "api-expert", KeyID: "api-1", Algorithm: SignatureHMACSHA256, SecretFile: "api.key"},
+		{Agent: "architect-expert", KeyID: "arch-1", Algorithm: SignatureEd25519, PublicKeyFile: "architect.pub", PrivateKeyFile: privateFile},
+	}})
+	file := filepath.Join(dir, "keys.json")
+	if err := os.WriteFile(file, registry, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestKeyRegistrySignAndVerify(t *testing.T) {
+	keys, err := LoadKeyRegistry(writeSigningKeys(t, true))
+	if err != nil {
+		t.Fatalf("LoadKeyRegistry failed: %v", err)
+	}
+
+	for _, agent := range []string{"api-expert", "architect-expert"} {
+		t.Run(agent, func(t *testing.T) {
+			h := newIntegrityTestHandoff()
+			h.Metadata.FromAgent = agent
+			if err := keys.Sign(h); err != nil {
+				t.Fatalf("Sign failed: %v", err)
+			}
+			if err := keys.Verify(h); err != nil {
+				t.Errorf("expected signature to verify, got %v", err)
+			}
+
+			data, _ := json.Marshal(HandoffQueueMessage{HandoffID: h.Metadata.HandoffID, Payload: *h})
+			if err := keys.VerifyPayload(data); err != nil {
+				t.Errorf("expected raw payload to verify, got %v", err)
+			}
+
+			// Claiming another identity must fail even with the checksum resealed
+			h.Metadata.FromAgent = "test-expert"
+			h.Validation.Checksum = h.GenerateChecksum()
+			if err := keys.Verify(h); !errors.Is(err, ErrSignatureInvalid) {
+				t.Errorf("expected invalid signature for spoofed from_agent, got %v", err)
+			}
+		})
+	}
+}
+
+func TestKeyRegistryRejectsBadSignatures(t *testing.T) {
+	keys, err := LoadKeyRegistry(writeSigningKeys(t, true))
+	if err != nil {
+		t.Fatalf("LoadKeyRegistry failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(h *Handoff)
+		wantErr error
+	}{
+		{"unsigned", func(h *Handoff) { h.Validation.Signature = nil }, ErrSignatureMissing},
+		{"tampered content", func(h *Handoff) { h.Content.Summary = "Drop the production database" }, ErrSignatureInvalid},
+		{"unknown key", func(h *Handoff) { h.Validation.Signature.KeyID = "api-2" }, ErrSignatureInvalid},
+		{"wrong algorithm", func(h *Handoff) { h.Validation.Signature.Algorithm = SignatureEd25519 }, ErrSignatureInvalid},
+		{"garbled value", func(h *Handoff) { h.Validation.Signature.Value = "not base64!" }, ErrSignatureInvalid},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newIntegrityTestHandoff()
+			if err := keys.Sign(h); err != nil {
+				t.Fatalf("Sign failed: %v", err)
+			}
+			tt.modify(h)
+			if err := keys.Verify(h); !errors.Is(err, tt.wantErr) {
+				t.Errorf("expected %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestKeyRegistryVerifyOnlyAndRotation(t *testing.T) {
+	signer, err := LoadKeyRegistry(writeSigningKeys(t, true))
+	if err != nil {
+		t.Fatalf("LoadKeyRegistry failed: %v", err)
+	}
+
+	verifier := NewKeyRegistry()
+	if err := verifier.AddEd25519Key("architect-expert", "arch-1", signer.keys["architect-expert"]["arch-1"].public, nil); err != nil {
+		t.Fatal(err)
+	}
+	if verifier.CanSign("architect-expert") {
+		t.Error("expected a public-key-only registry not to sign")
+	}
+
+	h := newIntegrityTestHandoff()
+	h.Metadata.FromAgent = "architect-expert"
+	if err := verifier.Sign(h); !errors.Is(err, ErrNoSigningKey) {
+		t.Errorf("expected ErrNoSigningKey, got %v", err)
+	}
+	signer.Sign(h)
+	if err := verifier.Verify(h); err != nil {
+		t.Errorf("expected verify-only registry to verify, got %v", err)
+	}
+
+	// A newer key signs; signatures made with the older key still verify
+	_, private, _ := ed25519.GenerateKey(rand.Reader)
+	if err := signer.AddEd25519Key("architect-expert", "arch-2", nil, private); err != nil {
+		t.Fatal(err)
+	}
+	old := *h.Validation.Signature
+	signer.Sign(h)
+	if h.Validation.Signature.KeyID != "arch-2" {
+		t.Errorf("expected newest key to sign, got %s", h.Validation.Signature.KeyID)
+	}
+	if err := signer.VerifyContent("architect-expert", h.Metadata, h.Content, &old); err != nil {
+		t.Errorf("expected signature from rotated key to verify, got %v", err)
+	}
+}
+
+func TestLoadKeyRegistryErrors(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "short.key"), []byte("too short"), 0o600)
+
+	tests := []struct {
+		name    string
+		entry   KeyFileEntry
+		wantErr string
+	}{
+		{"short secret", KeyFileEntry{Agent: "api-expert", KeyID: "k", Algorithm: SignatureHMACSHA256, SecretFile: "short.key"}, "at least 32 bytes"},
+		{"missing file", KeyFileEntry{Agent: "api-expert", KeyID: "k", Algorithm: SignatureHMACSHA256, SecretFile: "absent.key"}, "no such file"},
+		{"not PEM", KeyFileEntry{Agent: "api-expert", KeyID: "k", Algorithm: SignatureEd25519, PublicKeyFile: "short.key"}, "no PEM block"},
+		{"unknown algorithm", KeyFileEntry{Agent: "api-expert", KeyID: "k", Algorithm: "rsa"}, `unknown algorithm "rsa"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, _ := json.Marshal(map[string]interface{}{"keys": []KeyFileEntry{tt.entry}})
+			file := filepath.Join(dir, "keys.json")
+			os.WriteFile(file, data, 0o600)
+
+			_, err := LoadKeyRegistry(file)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestParseSignaturePolicy(t *testing.T) {
+	for name, want := range map[string]SignaturePolicy{
+		"":           SignaturePolicyOff,
+		"off":        SignaturePolicyOff,
+		"Reject":     SignaturePolicyReject,
+		"quarantine": SignaturePolicyQuarantine,
+	} {
+		if got, err := ParseSignaturePolicy(name); err != nil || got != want {
+			t.Errorf("ParseSignaturePolicy(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseSignaturePolicy("warn"); err == nil {
+		t.Error("expected unknown policy to fail")
+	}
+}
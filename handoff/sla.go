@@ -0,0 +1,365 @@
+package handoff
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Queues are scored by priority, so their scores say nothing about how long a
+// handoff has waited. Each queue's handoffs are also kept in a sorted set
+// named handoff:enqueued:<queue>, scored by the Unix millisecond they were
+// queued at, which producers add to and consumers take from.
+const enqueuedKeyPrefix = "handoff:enqueued:"
+
+// enqueuedRetention is refreshed on every enqueue; queued handoffs themselves
+// expire after a day
+const enqueuedRetention = 48 * time.Hour
+
+// EnqueuedKey returns the key tracking when the handoffs in queue were queued
+func EnqueuedKey(queue string) string {
+	return enqueuedKeyPrefix + queue
+}
+
+// MarkEnqueued records that a handoff was added to queue at the given time.
+// It is meant to be queued in the same pipeline or transaction as the ZADD.
+func MarkEnqueued(ctx context.Context, cmd redis.Cmdable, queue, handoffID string, at time.Time) {
+	key := EnqueuedKey(queue)
+	cmd.ZAdd(ctx, key, &redis.Z{Score: float64(at.UnixMilli()), Member: handoffID})
+	cmd.Expire(ctx, key, enqueuedRetention)
+}
+
+// ClearEnqueued forgets when a handoff was queued, for handoffs removed from
+// a queue without being processed
+func ClearEnqueued(ctx context.Context, cmd redis.Cmdable, queue, handoffID string) {
+	cmd.ZRem(ctx, EnqueuedKey(queue), handoffID)
+}
+
+// TakeEnqueued returns when a handoff popped from queue was queued and stops
+// tracking it. The time is zero for handoffs queued before tracking existed.
+func TakeEnqueued(ctx context.Context, client redis.Cmdable, queue, handoffID string) (time.Time, error) {
+	key := EnqueuedKey(queue)
+	var score *redis.FloatCmd
+	_, err := client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		score = pipe.ZScore(ctx, key, handoffID)
+		pipe.ZRem(ctx, key, handoffID)
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return time.Time{}, fmt.Errorf("failed to read enqueue time of %s: %w", handoffID, err)
+	}
+	ms, err := score.Result()
+	if err != nil {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(int64(ms)), nil
+}
+
+// OldestEnqueued returns when the longest waiting handoff in queue was queued,
+// or the zero time when none is tracked
+func OldestEnqueued(ctx context.Context, client redis.Cmdable, queue string) (time.Time, error) {
+	oldest, err := client.ZRangeWithScores(ctx, EnqueuedKey(queue), 0, 0).Result()
+	if err != nil && err != redis.Nil {
+		return time.Time{}, fmt.Errorf("failed to read enqueue times of %s: %w", queue, err)
+	}
+	if len(oldest) == 0 {
+		return time.Time{}, nil
+	}
+	return time.UnixMilli(int64(oldest[0].Score)), nil
+}
+
+// enqueueTimes returns when each of the given handoffs was queued; handoffs
+// that are not tracked are left out
+func enqueueTimes(ctx context.Context, client redis.Cmdable, queue string, ids []string) (map[string]time.Time, error) {
+	key := EnqueuedKey(queue)
+	pipe := client.Pipeline()
+	scores := make([]*redis.FloatCmd, len(ids))
+	for i, id := range ids {
+		scores[i] = pipe.ZScore(ctx, key, id)
+	}
+	if _, err := pipe.Exec(ctx); err != nil && err != redis.Nil {
+		return nil, fmt.Errorf("failed to read enqueue times of %s: %w", queue, err)
+	}
+	times := make(map[string]time.Time, len(ids))
+	for i, id := range ids {
+		if ms, err := scores[i].Result(); err == nil {
+			times[id] = time.UnixMilli(int64(ms))
+		}
+	}
+	return times, nil
+}
+
+// SLAPolicy is how long handoffs of each priority may wait in their queue
+// before processing starts. Priorities without a target have no SLA.
+type SLAPolicy map[Priority]time.Duration
+
+// DefaultSLAPolicy returns the default targets: critical handoffs must start
+// within 2 minutes, high within 10, normal within an hour and low within 4
+func DefaultSLAPolicy() SLAPolicy {
+	return SLAPolicy{
+		PriorityCritical: 2 * time.Minute,
+		PriorityHigh:     10 * time.Minute,
+		PriorityNormal:   time.Hour,
+		PriorityLow:      4 * time.Hour,
+	}
+}
+
+// ParseSLAPolicy parses targets written as "critical=2m,high=10m"
+func ParseSLAPolicy(s string) (SLAPolicy, error) {
+	policy := SLAPolicy{}
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		priority, target, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid SLA target %q: expected priority=duration", part)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(target))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid SLA target %q: expected a positive duration", part)
+		}
+		policy[Priority(strings.TrimSpace(priority))] = d
+	}
+	return policy, nil
+}
+
+// String formats the policy as ParseSLAPolicy reads it
+func (p SLAPolicy) String() string {
+	parts := make([]string, 0, len(p))
+	for priority, target := range p {
+		parts = append(parts, string(priority)+"="+target.String())
+	}
+	sort.Strings(parts)
+	return strings.Join(parts, ",")
+}
+
+// shortest returns the smallest target, or zero for an empty policy
+func (p SLAPolicy) shortest() time.Duration {
+	var shortest time.Duration
+	for _, target := range p {
+		if target > 0 && (shortest == 0 || target < shortest) {
+			shortest = target
+		}
+	}
+	return shortest
+}
+
+// Check returns the breach of a handoff queued at enqueuedAt that is still
+// waiting, or started, at the given time; nil when it is within its target,
+// its priority has none, or its enqueue time is unknown
+func (p SLAPolicy) Check(priority Priority, enqueuedAt, at time.Time) *SLABreach {
+	target, ok := p[priority]
+	if !ok || target <= 0 || enqueuedAt.IsZero() {
+		return nil
+	}
+	waited := at.Sub(enqueuedAt)
+	if waited <= target {
+		return nil
+	}
+	return &SLABreach{Priority: priority, Target: target, Waited: waited, EnqueuedAt: enqueuedAt, DetectedAt: at}
+}
+
+// SLABreach records that a handoff waited in its queue longer than its
+// priority's target. The monitor records breaches of handoffs still waiting;
+// consumers record them, or complete them with StartedAt, once processing starts.
+type SLABreach struct {
+	Priority   Priority      `json:"priority" yaml:"priority"`
+	Target     time.Duration `json:"target" yaml:"target"`
+	Waited     time.Duration `json:"waited" yaml:"waited"` // Until DetectedAt, or StartedAt once set
+	EnqueuedAt time.Time     `json:"enqueued_at" yaml:"enqueued_at"`
+	DetectedAt time.Time     `json:"detected_at" yaml:"detected_at"`
+	StartedAt  time.Time     `json:"started_at,omitempty" yaml:"started_at,omitempty"`
+}
+
+// Started completes a breach detected while the handoff waited with the time
+// processing started
+func (b *SLABreach) Started(at time.Time) {
+	b.StartedAt = at
+	b.Waited = at.Sub(b.EnqueuedAt)
+}
+
+// WaitingBreach is a handoff still waiting in its queue past its SLA
+type WaitingBreach struct {
+	HandoffID string
+	Queue     string
+	Labels    MetricLabels
+	Breach    SLABreach
+	Recorded  bool // The handoff already carries the breach
+}
+
+// FindSLABreaches returns the handoffs waiting in any queue longer than
+// policy allows their priority, oldest first within each queue. Tracking
+// entries of handoffs that no longer exist are removed.
+func FindSLABreaches(ctx context.Context, client redis.Cmdable, policy SLAPolicy, now time.Time) ([]WaitingBreach, error) {
+	shortest := policy.shortest()
+	if shortest == 0 {
+		return nil, nil
+	}
+
+	var keys []string
+	var cursor uint64
+	for {
+		batch, next, err := client.Scan(ctx, cursor, enqueuedKeyPrefix+"*", 100).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan enqueue times: %w", err)
+		}
+		keys = append(keys, batch...)
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	sort.Strings(keys)
+
+	var breaches []WaitingBreach
+	for _, key := range keys {
+		queue := strings.TrimPrefix(key, enqueuedKeyPrefix)
+		project, agent, ok := parseQueueKey(queue)
+		if !ok {
+			continue
+		}
+		waiting, err := client.ZRangeByScoreWithScores(ctx, key, &redis.ZRangeBy{
+			Min:   "-inf",
+			Max:   fmt.Sprint(now.Add(-shortest).UnixMilli()),
+			Count: maxAgeSample,
+		}).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read enqueue times of %s: %w", queue, err)
+		}
+		if len(waiting) == 0 {
+			continue
+		}
+
+		handoffKeys := make([]string, len(waiting))
+		for i, z := range waiting {
+			handoffKeys[i] = "handoff:" + z.Member.(string)
+		}
+		docs, err := client.MGet(ctx, handoffKeys...).Result()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read handoffs queued in %s: %w", queue, err)
+		}
+		for i, doc := range docs {
+			id := waiting[i].Member.(string)
+			data, _ := doc.(string)
+			if data == "" {
+				client.ZRem(ctx, key, id)
+				continue
+			}
+			entry := decodeQueuedHandoff(data)
+			breach := policy.Check(entry.priority, time.UnixMilli(int64(waiting[i].Score)), now)
+			if breach == nil {
+				continue
+			}
+			labels := MetricLabels{Project: project, Agent: agent, Priority: entry.priority}
+			if labels.Project == "" {
+				labels.Project = entry.project
+			}
+			breaches = append(breaches, WaitingBreach{HandoffID: id, Queue: queue, Labels: labels, Breach: *breach, Recorded: entry.slaBreached})
+		}
+	}
+	return breaches, nil
+}
+
+// RecordSLABreach stores a breach on a handoff, either a HandoffQueueMessage
+// or a bare handoff as the agent-manager stores it. A breach already recorded
+// is only completed with StartedAt, once; first reports whether the handoff
+// carried no breach before.
+func RecordSLABreach(ctx context.Context, client redis.UniversalClient, handoffID string, breach SLABreach) (first bool, err error) {
+	key := "handoff:" + handoffID
+	err = client.Watch(ctx, func(tx *redis.Tx) error {
+		data, err := tx.Get(ctx, key).Bytes()
+		if err != nil {
+			return err
+		}
+		ttl, err := tx.PTTL(ctx, key).Result()
+		if err != nil {
+			return err
+		}
+
+		var doc map[string]json.RawMessage
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return fmt.Errorf("cannot decode handoff: %w", err)
+		}
+		target := doc
+		if raw, ok := doc["payload"]; ok {
+			if err := json.Unmarshal(raw, &target); err != nil {
+				return fmt.Errorf("cannot decode handoff payload: %w", err)
+			}
+		}
+
+		if raw, ok := target["sla_breach"]; ok && string(raw) != "null" {
+			var existing SLABreach
+			if err := json.Unmarshal(raw, &existing); err != nil || breach.StartedAt.IsZero() || !existing.StartedAt.IsZero() {
+				return nil
+			}
+			existing.Started(breach.StartedAt)
+			breach = existing
+		} else {
+			first = true
+		}
+
+		if target["sla_breach"], err = json.Marshal(breach); err != nil {
+			return err
+		}
+		if _, ok := doc["payload"]; ok {
+			if doc["payload"], err = json.Marshal(target); err != nil {
+				return err
+			}
+		}
+		updated, err := json.Marshal(doc)
+		if err != nil {
+			return err
+		}
+		if ttl < 0 {
+			ttl = 0
+		}
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(ctx, key, updated, ttl)
+			return nil
+		})
+		return err
+	}, key)
+	if err != nil {
+		return false, fmt.Errorf("failed to record SLA breach on %s: %w", handoffID, err)
+	}
+	return first, nil
+}
+
+// StartSLA is called by consumers that popped a stored handoff from queue. It
+// stops tracking when the handoff was queued and, when processing starts past
+// its SLA, records the breach on the handoff or completes the one recorded
+// while it waited. first reports a newly recorded breach, to be counted as
+// EventSLABreached with labels.
+func StartSLA(ctx context.Context, client redis.UniversalClient, policy SLAPolicy, queue, handoffID string, now time.Time) (labels MetricLabels, first bool, err error) {
+	enqueuedAt, err := TakeEnqueued(ctx, client, queue, handoffID)
+	if err != nil || enqueuedAt.IsZero() || len(policy) == 0 {
+		return labels, false, err
+	}
+	data, err := client.Get(ctx, "handoff:"+handoffID).Result()
+	if err != nil {
+		return labels, false, fmt.Errorf("failed to retrieve handoff %s: %w", handoffID, err)
+	}
+	entry := decodeQueuedHandoff(data)
+	project, agent, _ := parseQueueKey(queue)
+	if project == "" {
+		project = entry.project
+	}
+	labels = MetricLabels{Project: project, Agent: agent, Priority: entry.priority}
+
+	breach := policy.Check(entry.priority, enqueuedAt, now)
+	if breach == nil {
+		if !entry.slaBreached {
+			return labels, false, nil
+		}
+		breach = &SLABreach{}
+	}
+	breach.StartedAt = now
+	first, err = RecordSLABreach(ctx, client, handoffID, *breach)
+	return labels, first, err
+}
@@ -0,0 +1,79 @@
+package handoff
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSLAPolicy(t *testing.T) {
+	policy, err := ParseSLAPolicy(" critical=2m, high=10m,,low=4h ")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy) != 3 || policy[PriorityCritical] != 2*time.Minute || policy[PriorityLow] != 4*time.Hour {
+		t.Errorf("unexpected policy %v", policy)
+	}
+	if got := policy.String(); got != "critical=2m0s,high=10m0s,low=4h0m0s" {
+		t.Errorf("unexpected formatting %q", got)
+	}
+	if policy.shortest() != 2*time.Minute || (SLAPolicy{}).shortest() != 0 {
+		t.Errorf("unexpected shortest target %v", policy.shortest())
+	}
+
+	for _, bad := range []string{"critical", "critical=soon", "high=-1m", "normal=0s"} {
+		if _, err := ParseSLAPolicy(bad); err == nil || !strings.Contains(err.Error(), "invalid SLA target") {
+			t.Errorf("%q: expected an invalid target, got %v", bad, err)
+		}
+	}
+}
+
+func TestSLAPolicyCheck(t *testing.T) {
+	policy := DefaultSLAPolicy()
+	queued := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	if breach := policy.Check(PriorityCritical, queued, queued.Add(2*time.Minute)); breach != nil {
+		t.Errorf("expected a start at the target to meet the SLA, got %+v", breach)
+	}
+	breach := policy.Check(PriorityCritical, queued, queued.Add(3*time.Minute))
+	if breach == nil || breach.Target != 2*time.Minute || breach.Waited != 3*time.Minute || !breach.EnqueuedAt.Equal(queued) {
+		t.Fatalf("expected a critical breach, got %+v", breach)
+	}
+	if policy.Check(PriorityNormal, queued, queued.Add(3*time.Minute)) != nil {
+		t.Error("expected normal priority to have longer to start")
+	}
+	if policy.Check("urgent", queued, queued.Add(24*time.Hour)) != nil {
+		t.Error("expected priorities without a target to have no SLA")
+	}
+	if policy.Check(PriorityCritical, time.Time{}, queued) != nil {
+		t.Error("expected unknown enqueue times to be skipped")
+	}
+
+	breach.Started(queued.Add(5 * time.Minute))
+	if breach.Waited != 5*time.Minute || !breach.DetectedAt.Equal(queued.Add(3*time.Minute)) {
+		t.Errorf("expected the start to complete the breach, got %+v", breach)
+	}
+}
+
+func TestDecodeQueuedHandoffSLA(t *testing.T) {
+	h := Handoff{
+		Metadata:  Metadata{ProjectName: "billing", Priority: PriorityHigh},
+		CreatedAt: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+		SLABreach: &SLABreach{Priority: PriorityHigh, Target: 10 * time.Minute},
+	}
+	bare, _ := json.Marshal(h)
+	wrapped, _ := json.Marshal(HandoffQueueMessage{Payload: h})
+
+	for name, data := range map[string][]byte{"bare": bare, "message": wrapped} {
+		entry := decodeQueuedHandoff(string(data))
+		if entry.project != "billing" || entry.priority != PriorityHigh || !entry.slaBreached || !entry.createdAt.Equal(h.CreatedAt) {
+			t.Errorf("%s: unexpected entry %+v", name, entry)
+		}
+	}
+	h.SLABreach = nil
+	bare, _ = json.Marshal(h)
+	if decodeQueuedHandoff(string(bare)).slaBreached {
+		t.Error("expected a handoff without a breach not to be marked")
+	}
+}
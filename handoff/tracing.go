@@ -0,0 +1,494 @@
+package handoff
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// A handoff's journey is traced with W3C Trace Context: the publisher starts a
+// trace and stores its context on the handoff, and each later step (routing,
+// queue wait, dispatch, strategy selection, execution) is a span of the same
+// trace. Child processes receive the context in the TRACEPARENT environment
+// variable, as OpenTelemetry SDKs expect.
+const (
+	TraceparentEnv    = "TRACEPARENT" // Environment variable carrying the trace context
+	TraceparentHeader = "traceparent" // HTTP header carrying the trace context
+)
+
+// Trace exporters
+const (
+	TraceExporterOTLP = "otlp" // OTLP/HTTP JSON to Endpoint
+	TraceExporterFile = "file" // Append JSON lines to Path
+)
+
+// DefaultOTLPEndpoint is where otlp exporters send spans when no endpoint is set
+const DefaultOTLPEndpoint = "http://localhost:4318/v1/traces"
+
+// TraceContext identifies a trace and the span the next step continues from
+type TraceContext struct {
+	TraceID string `json:"trace_id" yaml:"trace_id"` // 32 lowercase hex digits
+	SpanID  string `json:"span_id" yaml:"span_id"`   // 16 lowercase hex digits
+	Sampled bool   `json:"sampled" yaml:"sampled"`   // Whether the trace's spans are exported
+}
+
+// IsValid reports whether the context has well-formed, non-zero IDs
+func (tc TraceContext) IsValid() bool {
+	return isTraceID(tc.TraceID, 32) && isTraceID(tc.SpanID, 16)
+}
+
+// Traceparent formats the context as a W3C traceparent value, or "" when it
+// is not valid
+func (tc TraceContext) Traceparent() string {
+	if !tc.IsValid() {
+		return ""
+	}
+	flags := "00"
+	if tc.Sampled {
+		flags = "01"
+	}
+	return "00-" + tc.TraceID + "-" + tc.SpanID + "-" + flags
+}
+
+// ParseTraceparent parses a W3C traceparent value
+func ParseTraceparent(value string) (TraceContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || (parts[0] == "00" && len(parts) != 4) {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q", value)
+	}
+	flags, err := strconv.ParseUint(parts[3], 16, 8)
+	if err != nil || len(parts[3]) != 2 {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q", value)
+	}
+	tc := TraceContext{TraceID: parts[1], SpanID: parts[2], Sampled: flags&1 == 1}
+	if !tc.IsValid() {
+		return TraceContext{}, fmt.Errorf("invalid traceparent %q", value)
+	}
+	return tc, nil
+}
+
+func isTraceID(id string, length int) bool {
+	if len(id) != length || strings.Trim(id, "0") == "" {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func newTraceID(bytes int) string {
+	id := make([]byte, bytes)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+type traceContextKey struct{}
+
+// ContextWithTrace returns a context whose spans continue the given trace
+func ContextWithTrace(ctx context.Context, tc TraceContext) context.Context {
+	if !tc.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, traceContextKey{}, tc)
+}
+
+// TraceFromContext returns the trace context spans started from ctx continue
+func TraceFromContext(ctx context.Context) (TraceContext, bool) {
+	tc, ok := ctx.Value(traceContextKey{}).(TraceContext)
+	return tc, ok
+}
+
+// ContextWithHandoffTrace continues the trace stored on a handoff, if any
+func ContextWithHandoffTrace(ctx context.Context, trace *TraceContext) context.Context {
+	if trace == nil {
+		return ctx
+	}
+	return ContextWithTrace(ctx, *trace)
+}
+
+// SpanKind is the role of a span in the handoff's journey
+type SpanKind string
+
+const (
+	SpanInternal SpanKind = "internal"
+	SpanServer   SpanKind = "server"
+	SpanProducer SpanKind = "producer" // Publishing a handoff
+	SpanConsumer SpanKind = "consumer" // Taking a handoff off its queue
+)
+
+// SpanData is a finished span as exported
+type SpanData struct {
+	Service      string            `json:"service"`
+	Name         string            `json:"name"`
+	Kind         SpanKind          `json:"kind"`
+	TraceID      string            `json:"trace_id"`
+	SpanID       string            `json:"span_id"`
+	ParentSpanID string            `json:"parent_span_id,omitempty"`
+	Start        time.Time         `json:"start"`
+	End          time.Time         `json:"end"`
+	Attributes   map[string]string `json:"attributes,omitempty"`
+	Error        string            `json:"error,omitempty"`
+}
+
+// Span is one timed step of a trace. Spans of a nil Tracer, and of traces that
+// are not sampled, still propagate their context but are not exported.
+type Span struct {
+	tracer  *Tracer
+	sampled bool
+	ended   sync.Once
+
+	mu   sync.Mutex
+	data SpanData
+}
+
+// Context returns the span's trace context, to pass on to the next step
+func (s *Span) Context() TraceContext {
+	return TraceContext{TraceID: s.data.TraceID, SpanID: s.data.SpanID, Sampled: s.sampled}
+}
+
+// SetAttribute records a detail of the step
+func (s *Span) SetAttribute(key, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.data.Attributes == nil {
+		s.data.Attributes = make(map[string]string)
+	}
+	s.data.Attributes[key] = value
+}
+
+// End finishes the span, marking it failed when err is not nil. Only the
+// first call has an effect.
+func (s *Span) End(err error) {
+	s.ended.Do(func() {
+		s.mu.Lock()
+		s.data.End = time.Now()
+		if err != nil {
+			s.data.Error = err.Error()
+		}
+		data := s.data
+		s.mu.Unlock()
+		if s.sampled {
+			s.tracer.export(data)
+		}
+	})
+}
+
+// TracingConfig configures where spans are exported. Without an exporter
+// trace context is still created and propagated.
+type TracingConfig struct {
+	Exporter      string            `json:"exporter,omitempty"`       // otlp, file or empty
+	Endpoint      string            `json:"endpoint,omitempty"`       // otlp: traces URL (default DefaultOTLPEndpoint)
+	Headers       map[string]string `json:"headers,omitempty"`        // otlp: e.g. authentication
+	Path          string            `json:"path,omitempty"`           // file
+	BatchSize     int               `json:"batch_size,omitempty"`     // Spans per export (default 256)
+	FlushInterval time.Duration     `json:"flush_interval,omitempty"` // Longest a span waits to be exported (default 5s)
+	QueueSize     int               `json:"queue_size,omitempty"`     // Spans waiting before new ones are dropped (default 2048)
+}
+
+// SpanExporter sends finished spans outside the process
+type SpanExporter interface {
+	ExportSpans(ctx context.Context, service string, spans []SpanData) error
+}
+
+// Tracer starts spans for one service and exports finished spans in batches
+// in the background
+type Tracer struct {
+	service  string
+	exporter SpanExporter
+	batch    int
+	interval time.Duration
+	queue    chan SpanData
+	done     chan struct{}
+	dropped  uint64
+
+	mu      sync.RWMutex
+	stopped bool
+}
+
+// NewTracer creates a tracer for the named service exporting as configured
+func NewTracer(service string, cfg TracingConfig) (*Tracer, error) {
+	var exporter SpanExporter
+	switch cfg.Exporter {
+	case "":
+	case TraceExporterOTLP:
+		endpoint := cfg.Endpoint
+		if endpoint == "" {
+			endpoint = DefaultOTLPEndpoint
+		}
+		exporter = &otlpSpanExporter{endpoint: endpoint, headers: cfg.Headers, client: &http.Client{Timeout: 10 * time.Second}}
+	case TraceExporterFile:
+		if cfg.Path == "" {
+			return nil, fmt.Errorf("file trace exporter needs a path")
+		}
+		exporter = &fileSpanExporter{path: cfg.Path}
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q (expected otlp or file)", cfg.Exporter)
+	}
+	return newTracer(service, exporter, cfg), nil
+}
+
+func newTracer(service string, exporter SpanExporter, cfg TracingConfig) *Tracer {
+	t := &Tracer{service: service, exporter: exporter, batch: cfg.BatchSize, interval: cfg.FlushInterval}
+	if exporter == nil {
+		return t
+	}
+	if t.batch <= 0 {
+		t.batch = 256
+	}
+	if t.interval <= 0 {
+		t.interval = 5 * time.Second
+	}
+	queueSize := cfg.QueueSize
+	if queueSize <= 0 {
+		queueSize = 2048
+	}
+	t.queue = make(chan SpanData, queueSize)
+	t.done = make(chan struct{})
+	go t.run()
+	return t
+}
+
+// Start starts a span continuing the trace in ctx, or a new trace, and returns
+// a context that continues from the span
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	return t.StartAt(ctx, name, kind, time.Now())
+}
+
+// StartAt is Start for a step that began earlier, such as a queue wait
+func (t *Tracer) StartAt(ctx context.Context, name string, kind SpanKind, start time.Time) (context.Context, *Span) {
+	span := &Span{tracer: t, sampled: true, data: SpanData{Name: name, Kind: kind, SpanID: newTraceID(8), Start: start}}
+	if t != nil {
+		span.data.Service = t.service
+	}
+	if parent, ok := TraceFromContext(ctx); ok {
+		span.data.TraceID = parent.TraceID
+		span.data.ParentSpanID = parent.SpanID
+		span.sampled = parent.Sampled
+	} else {
+		span.data.TraceID = newTraceID(16)
+	}
+	return ContextWithTrace(ctx, span.Context()), span
+}
+
+// Dropped returns the spans not exported because the queue was full
+func (t *Tracer) Dropped() uint64 {
+	if t == nil {
+		return 0
+	}
+	return atomic.LoadUint64(&t.dropped)
+}
+
+// Shutdown exports the spans still queued, waiting at most until ctx is done
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil || t.queue == nil {
+		return nil
+	}
+	t.mu.Lock()
+	if !t.stopped {
+		t.stopped = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("trace export did not finish: %w", ctx.Err())
+	}
+}
+
+func (t *Tracer) export(span SpanData) {
+	if t == nil || t.queue == nil {
+		return
+	}
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.stopped {
+		// Spans ending after Shutdown are dropped
+		atomic.AddUint64(&t.dropped, 1)
+		return
+	}
+	select {
+	case t.queue <- span:
+	default:
+		atomic.AddUint64(&t.dropped, 1)
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	var pending []SpanData
+	flush := func() {
+		if len(pending) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if err := t.exporter.ExportSpans(ctx, t.service, pending); err != nil {
+			log.Error().Err(err).Int("spans", len(pending)).Msg("Failed to export spans")
+		}
+		pending = nil
+	}
+	for {
+		select {
+		case span, ok := <-t.queue:
+			if !ok {
+				flush()
+				return
+			}
+			pending = append(pending, span)
+			if len(pending) >= t.batch {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// fileSpanExporter appends each span to a file as a JSON line
+type fileSpanExporter struct {
+	mu   sync.Mutex
+	path string
+}
+
+func (e *fileSpanExporter) ExportSpans(ctx context.Context, service string, spans []SpanData) error {
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	for _, span := range spans {
+		if err := encoder.Encode(span); err != nil {
+			return fmt.Errorf("failed to encode span: %w", err)
+		}
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	f, err := os.OpenFile(e.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open trace file: %w", err)
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to append spans: %w", err)
+	}
+	return f.Close()
+}
+
+// otlpSpanExporter posts spans to an OTLP/HTTP endpoint in its JSON encoding
+type otlpSpanExporter struct {
+	endpoint string
+	headers  map[string]string
+	client   *http.Client
+}
+
+func (e *otlpSpanExporter) ExportSpans(ctx context.Context, service string, spans []SpanData) error {
+	body, err := json.Marshal(otlpRequest(service, spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("invalid OTLP request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range e.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("OTLP request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("OTLP endpoint returned %s", resp.Status)
+	}
+	return nil
+}
+
+// OTLP span kinds and status codes
+var otlpSpanKinds = map[SpanKind]int{SpanInternal: 1, SpanServer: 2, SpanProducer: 4, SpanConsumer: 5}
+
+const (
+	otlpStatusOK    = 1
+	otlpStatusError = 2
+)
+
+type otlpAttribute struct {
+	Key   string `json:"key"`
+	Value struct {
+		StringValue string `json:"stringValue"`
+	} `json:"value"`
+}
+
+func otlpAttributes(attributes map[string]string) []otlpAttribute {
+	keys := make([]string, 0, len(attributes))
+	for key := range attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	out := make([]otlpAttribute, len(keys))
+	for i, key := range keys {
+		out[i].Key = key
+		out[i].Value.StringValue = attributes[key]
+	}
+	return out
+}
+
+// otlpRequest builds an ExportTraceServiceRequest in the OTLP/HTTP JSON
+// encoding: IDs are hex and times are decimal strings of Unix nanoseconds
+func otlpRequest(service string, spans []SpanData) map[string]interface{} {
+	encoded := make([]map[string]interface{}, len(spans))
+	for i, span := range spans {
+		status := map[string]interface{}{"code": otlpStatusOK}
+		if span.Error != "" {
+			status = map[string]interface{}{"code": otlpStatusError, "message": span.Error}
+		}
+		kind, ok := otlpSpanKinds[span.Kind]
+		if !ok {
+			kind = otlpSpanKinds[SpanInternal]
+		}
+		encoded[i] = map[string]interface{}{
+			"traceId":           span.TraceID,
+			"spanId":            span.SpanID,
+			"parentSpanId":      span.ParentSpanID,
+			"name":              span.Name,
+			"kind":              kind,
+			"startTimeUnixNano": strconv.FormatInt(span.Start.UnixNano(), 10),
+			"endTimeUnixNano":   strconv.FormatInt(span.End.UnixNano(), 10),
+			"attributes":        otlpAttributes(span.Attributes),
+			"status":            status,
+		}
+	}
+	return map[string]interface{}{
+		"resourceSpans": []interface{}{map[string]interface{}{
+			"resource": map[string]interface{}{
+				"attributes": otlpAttributes(map[string]string{"service.name": service}),
+			},
+			"scopeSpans": []interface{}{map[string]interface{}{
+				"scope": map[string]interface{}{"name": "github.com/vot3k/agent-handoff/handoff"},
+				"spans": encoded,
+			}},
+		}},
+	}
+}
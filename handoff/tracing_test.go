@@ -0,0 +1,189 @@
+package handoff
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tc, err := ParseTraceparent("00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tc.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || tc.SpanID != "00f067aa0ba902b7" || !tc.Sampled {
+		t.Errorf("unexpected context %+v", tc)
+	}
+	if got := tc.Traceparent(); got != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Errorf("unexpected round trip %q", got)
+	}
+	if tc, err := ParseTraceparent("01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future"); err != nil || tc.Sampled {
+		t.Errorf("expected a later version to parse unsampled, got %+v, %v", tc, err)
+	}
+
+	for _, bad := range []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+	} {
+		if _, err := ParseTraceparent(bad); err == nil {
+			t.Errorf("%q: expected an invalid traceparent", bad)
+		}
+	}
+	if (TraceContext{}).Traceparent() != "" {
+		t.Error("expected an empty context to format as empty")
+	}
+}
+
+// recordingExporter keeps exported spans in memory
+type recordingExporter struct{ spans chan SpanData }
+
+func (e recordingExporter) ExportSpans(ctx context.Context, service string, spans []SpanData) error {
+	for _, span := range spans {
+		e.spans <- span
+	}
+	return nil
+}
+
+func TestTracerSpans(t *testing.T) {
+	exporter := recordingExporter{spans: make(chan SpanData, 10)}
+	tracer := newTracer("test", exporter, TracingConfig{})
+
+	ctx, publish := tracer.Start(context.Background(), "handoff.publish", SpanProducer)
+	trace := publish.Context()
+	if !trace.IsValid() || !trace.Sampled {
+		t.Fatalf("expected a new sampled trace, got %+v", trace)
+	}
+	publish.End(nil)
+	publish.End(errors.New("ignored"))
+
+	// A later step continues the trace stored on the handoff
+	h := &Handoff{Trace: &trace}
+	waited := time.Now().Add(-time.Minute)
+	_, wait := tracer.StartAt(ContextWithHandoffTrace(context.Background(), h.Trace), "handoff.queue_wait", SpanInternal, waited)
+	wait.SetAttribute("handoff.id", "h-1")
+	wait.End(errors.New("boom"))
+
+	_, unsampled := tracer.Start(ContextWithTrace(ctx, TraceContext{TraceID: trace.TraceID, SpanID: trace.SpanID}), "skipped", SpanInternal)
+	unsampled.End(nil)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(shutdownCtx); err != nil {
+		t.Fatal(err)
+	}
+	close(exporter.spans)
+
+	var spans []SpanData
+	for span := range exporter.spans {
+		spans = append(spans, span)
+	}
+	if len(spans) != 2 {
+		t.Fatalf("expected the two sampled spans, got %+v", spans)
+	}
+	if spans[0].Name != "handoff.publish" || spans[0].ParentSpanID != "" || spans[0].Error != "" || spans[0].Service != "test" {
+		t.Errorf("unexpected publish span %+v", spans[0])
+	}
+	if spans[1].TraceID != trace.TraceID || spans[1].ParentSpanID != trace.SpanID || !spans[1].Start.Equal(waited) ||
+		spans[1].Error != "boom" || spans[1].Attributes["handoff.id"] != "h-1" {
+		t.Errorf("unexpected queue wait span %+v", spans[1])
+	}
+
+	late := &Span{tracer: tracer, sampled: true}
+	late.End(nil)
+	if tracer.Dropped() != 1 {
+		t.Errorf("expected spans ending after shutdown to be dropped, got %d", tracer.Dropped())
+	}
+}
+
+func TestNilTracerPropagates(t *testing.T) {
+	var tracer *Tracer
+	ctx, span := tracer.Start(context.Background(), "handoff.publish", SpanProducer)
+	if tc, ok := TraceFromContext(ctx); !ok || tc != span.Context() || !tc.IsValid() {
+		t.Errorf("expected the span's context to be propagated, got %+v", tc)
+	}
+	span.End(nil)
+	if err := tracer.Shutdown(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestTraceExporters(t *testing.T) {
+	if _, err := NewTracer("test", TracingConfig{Exporter: "zipkin"}); err == nil || !strings.Contains(err.Error(), "unknown trace exporter") {
+		t.Errorf("expected an unknown exporter to be refused, got %v", err)
+	}
+	if _, err := NewTracer("test", TracingConfig{Exporter: TraceExporterFile}); err == nil {
+		t.Error("expected a file exporter without a path to be refused")
+	}
+
+	var request map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("unexpected headers %v", r.Header)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			t.Error(err)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "spans.jsonl")
+	otlp, err := NewTracer("handoff-service", TracingConfig{Exporter: TraceExporterOTLP, Endpoint: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err := NewTracer("handoff-service", TracingConfig{Exporter: TraceExporterFile, Path: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tracer := range []*Tracer{otlp, file} {
+		_, span := tracer.Start(context.Background(), "handoff.process", SpanConsumer)
+		span.SetAttribute("handoff.id", "h-1")
+		span.End(errors.New("agent failed"))
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := tracer.Shutdown(ctx); err != nil {
+			t.Fatal(err)
+		}
+		cancel()
+	}
+
+	encoded, _ := json.Marshal(request)
+	for _, want := range []string{
+		`"service.name"`, `"stringValue":"handoff-service"`, `"name":"handoff.process"`, `"kind":5`,
+		`"key":"handoff.id"`, `"status":{"code":2,"message":"agent failed"}`, `"startTimeUnixNano":"`,
+	} {
+		if !strings.Contains(string(encoded), want) {
+			t.Errorf("expected %s in OTLP request %s", want, encoded)
+		}
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		t.Fatal("expected a span in the trace file")
+	}
+	var span SpanData
+	if err := json.Unmarshal(scanner.Bytes(), &span); err != nil {
+		t.Fatal(err)
+	}
+	if span.Name != "handoff.process" || span.Service != "handoff-service" || span.Error != "agent failed" || len(span.TraceID) != 32 {
+		t.Errorf("unexpected file span %+v", span)
+	}
+}
@@ -1,7 +1,6 @@
 package handoff
 
 import (
-	"crypto/sha256"
 	"fmt"
 	"time"
 )
@@ -10,11 +9,13 @@ import (
 type HandoffStatus string
 
 const (
-	StatusPending    HandoffStatus = "pending"
-	StatusProcessing HandoffStatus = "processing"
-	StatusCompleted  HandoffStatus = "completed"
-	StatusFailed     HandoffStatus = "failed"
-	StatusRetrying   HandoffStatus = "retrying"
+	StatusPending     HandoffStatus = "pending"
+	StatusProcessing  HandoffStatus = "processing"
+	StatusCompleted   HandoffStatus = "completed"
+	StatusFailed      HandoffStatus = "failed"
+	StatusRetrying    HandoffStatus = "retrying"
+	StatusCancelled   HandoffStatus = "cancelled"
+	StatusQuarantined HandoffStatus = "quarantined" // Failed an integrity check on consume
 )
 
 // Priority defines the urgency level of a handoff
@@ -36,6 +37,21 @@ type Metadata struct {
 	TaskContext string    `json:"task_context"`
 	Priority    Priority  `json:"priority"`
 	HandoffID   string    `json:"handoff_id"`
+
+	// Route opts the handoff in to routing while to_agent keeps the
+	// producer's own choice, used when no rule matches
+	Route bool `json:"route,omitempty"`
+
+	// Routing provenance, set when the handoff was routed on publish
+	RequestedAgent string `json:"requested_agent,omitempty"`
+	RouteRule      string `json:"route_rule,omitempty"`
+
+	// ParentID links a fan-out child to the handoff it was split from
+	ParentID string `json:"parent_id,omitempty"`
+
+	// IdempotencyKey identifies a producer's attempt, so retries of it return
+	// the handoff first published instead of a duplicate
+	IdempotencyKey string `json:"idempotency_key,omitempty"`
 }
 
 // Artifacts represents files created, modified, or reviewed
@@ -52,12 +68,21 @@ type Content struct {
 	Artifacts        Artifacts              `json:"artifacts" yaml:"artifacts"`
 	TechnicalDetails map[string]interface{} `json:"technical_details" yaml:"technical_details"`
 	NextSteps        []string               `json:"next_steps" yaml:"next_steps"`
+
+	// ArtifactFiles records the size and hash of each artifact when artifact
+	// verification is enabled (see VerifyArtifacts)
+	ArtifactFiles []ArtifactFile `json:"artifact_files,omitempty" yaml:"artifact_files,omitempty"`
+
+	// Offloaded references technical_details fields stored as blobs because of
+	// their size (see ApplyPayloadLimits and LoadOffloaded)
+	Offloaded map[string]BlobRef `json:"offloaded,omitempty" yaml:"offloaded,omitempty"`
 }
 
 // Validation contains schema validation information
 type Validation struct {
-	SchemaVersion string `json:"schema_version" yaml:"schema_version"`
-	Checksum      string `json:"checksum" yaml:"checksum"`
+	SchemaVersion string     `json:"schema_version" yaml:"schema_version"`
+	Checksum      string     `json:"checksum" yaml:"checksum"`
+	Signature     *Signature `json:"signature,omitempty" yaml:"signature,omitempty"`
 }
 
 // Handoff represents a complete agent-to-agent handoff
@@ -70,19 +95,20 @@ type Handoff struct {
 	UpdatedAt  time.Time     `json:"updated_at" yaml:"updated_at"`
 	RetryCount int           `json:"retry_count" yaml:"retry_count"`
 	ErrorMsg   string        `json:"error_msg,omitempty" yaml:"error_msg,omitempty"`
+	FanOut     *FanOut       `json:"fan_out,omitempty" yaml:"fan_out,omitempty"`
+	SLABreach  *SLABreach    `json:"sla_breach,omitempty" yaml:"sla_breach,omitempty"`
+	Trace      *TraceContext `json:"trace,omitempty" yaml:"trace,omitempty"` // Set on publish; later steps' spans continue it
 }
 
-// GenerateChecksum creates a SHA256 checksum of the handoff content
+// GenerateChecksum creates a SHA256 checksum over the canonical form of the
+// handoff's metadata and content (see ComputeChecksum). It returns "" if the
+// handoff cannot be encoded as JSON.
 func (h *Handoff) GenerateChecksum() string {
-	data := fmt.Sprintf("%s:%s:%s:%v:%v",
-		h.Metadata.FromAgent,
-		h.Metadata.ToAgent,
-		h.Content.Summary,
-		h.Content.Requirements,
-		h.Content.NextSteps)
-
-	hash := sha256.Sum256([]byte(data))
-	return fmt.Sprintf("%x", hash)
+	checksum, err := ComputeChecksum(h.Metadata, h.Content)
+	if err != nil {
+		return ""
+	}
+	return checksum
 }
 
 // Validate checks if the handoff is valid according to the schema
@@ -100,7 +126,11 @@ func (h *Handoff) Validate() error {
 		h.Validation.SchemaVersion = "1.0"
 	}
 	if h.Validation.Checksum == "" {
-		h.Validation.Checksum = h.GenerateChecksum()
+		checksum, err := ComputeChecksum(h.Metadata, h.Content)
+		if err != nil {
+			return fmt.Errorf("cannot checksum handoff: %w", err)
+		}
+		h.Validation.Checksum = checksum
 	}
 	return nil
 }
@@ -125,15 +155,21 @@ type AgentCapabilities struct {
 	MaxConcurrent int      `json:"max_concurrent"`
 }
 
-// HandoffMetrics contains performance and monitoring data
+// HandoffMetrics contains performance and monitoring data. Counts and
+// processing times cover Window when read from the rolling metric buckets.
 type HandoffMetrics struct {
-	TotalHandoffs     int64         `json:"total_handoffs"`
-	CompletedHandoffs int64         `json:"completed_handoffs"`
-	FailedHandoffs    int64         `json:"failed_handoffs"`
-	AvgProcessingTime time.Duration `json:"avg_processing_time"`
-	QueueDepth        int64         `json:"queue_depth"`
-	ActiveAgents      []string      `json:"active_agents"`
-	LastUpdated       time.Time     `json:"last_updated"`
+	TotalHandoffs     int64          `json:"total_handoffs"`
+	CompletedHandoffs int64          `json:"completed_handoffs"`
+	FailedHandoffs    int64          `json:"failed_handoffs"`
+	AvgProcessingTime time.Duration  `json:"avg_processing_time"`
+	ProcessingP50     time.Duration  `json:"processing_p50"`
+	ProcessingP95     time.Duration  `json:"processing_p95"`
+	ProcessingP99     time.Duration  `json:"processing_p99"`
+	QueueDepth        int64          `json:"queue_depth"`
+	ActiveAgents      []string       `json:"active_agents"`
+	Window            time.Duration  `json:"window,omitempty"`
+	Breakdown         []MetricSeries `json:"breakdown,omitempty"` // Per project, agent and priority
+	LastUpdated       time.Time      `json:"last_updated"`
 }
 
 // RetryPolicy defines how failed handoffs should be retried
@@ -160,16 +196,19 @@ func DefaultRetryPolicy() RetryPolicy {
 	}
 }
 
-// AlertRule defines conditions that trigger alerts
+// AlertRule defines conditions that trigger alerts. Condition is an
+// expression (see ParseAlertCondition), or an operator name comparing the
+// metric of Type with Threshold.
 type AlertRule struct {
 	Name      string        `json:"name"`
-	Type      AlertType     `json:"type"`
+	Type      AlertType     `json:"type,omitempty"`
 	Condition string        `json:"condition"` // e.g., "queue_depth > 100"
-	Threshold float64       `json:"threshold"`
-	Duration  time.Duration `json:"duration"` // How long condition must persist
+	Threshold float64       `json:"threshold,omitempty"`
+	Duration  time.Duration `json:"duration"` // How long condition must persist before firing
 	Enabled   bool          `json:"enabled"`
 	LastFired time.Time     `json:"last_fired"`
-	Cooldown  time.Duration `json:"cooldown"` // Minimum time between alerts
+	Cooldown  time.Duration `json:"cooldown"`        // How often a firing alert is sent again; zero sends it once
+	Sinks     []string      `json:"sinks,omitempty"` // Notifier sinks to deliver to; empty uses the default sinks
 }
 
 // AlertType defines the type of alert
@@ -181,15 +220,19 @@ const (
 	AlertFailureRate    AlertType = "failure_rate"
 	AlertAgentHealth    AlertType = "agent_health"
 	AlertSystemHealth   AlertType = "system_health"
+	AlertQueueAge       AlertType = "queue_age" // Seconds the oldest queued handoff has waited
 )
 
-// AlertEvent represents an alert that was triggered
+// AlertEvent represents an alert that fired or resolved
 type AlertEvent struct {
-	Rule      AlertRule `json:"rule"`
-	Value     float64   `json:"value"`
-	Timestamp time.Time `json:"timestamp"`
-	Message   string    `json:"message"`
-	Severity  Severity  `json:"severity"`
+	Rule      AlertRule         `json:"rule"`
+	Value     float64           `json:"value"`
+	Timestamp time.Time         `json:"timestamp"`
+	Message   string            `json:"message"`
+	Severity  Severity          `json:"severity"`
+	State     AlertState        `json:"state"`            // Firing, or resolved when the condition cleared
+	Labels    map[string]string `json:"labels,omitempty"` // Group of a condition with by
+	Since     time.Time         `json:"since"`            // When the condition started to hold
 }
 
 // Severity defines alert severity levels
@@ -0,0 +1,315 @@
+package handoff
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"regexp"
+	"sort"
+	"time"
+)
+
+// FieldType is the expected JSON type of a technical_details field
+type FieldType string
+
+const (
+	FieldTypeString  FieldType = "string"
+	FieldTypeNumber  FieldType = "number"
+	FieldTypeInteger FieldType = "integer"
+	FieldTypeBoolean FieldType = "boolean"
+	FieldTypeArray   FieldType = "array"
+	FieldTypeObject  FieldType = "object"
+)
+
+// IsValid reports whether the field type is known
+func (t FieldType) IsValid() bool {
+	switch t {
+	case FieldTypeString, FieldTypeNumber, FieldTypeInteger, FieldTypeBoolean, FieldTypeArray, FieldTypeObject:
+		return true
+	default:
+		return false
+	}
+}
+
+// FieldSpec describes one technical_details field. Min and Max bound numbers,
+// and the length of strings and arrays.
+type FieldSpec struct {
+	Type     FieldType     `json:"type"`
+	Items    FieldType     `json:"items,omitempty"`
+	Required bool          `json:"required,omitempty"`
+	Enum     []interface{} `json:"enum,omitempty"`
+	Min      *float64      `json:"min,omitempty"`
+	Max      *float64      `json:"max,omitempty"`
+}
+
+// AgentValidationPolicy holds the validation rules for handoffs sent to one agent
+type AgentValidationPolicy struct {
+	TechnicalDetails map[string]FieldSpec `json:"technical_details"`
+}
+
+// ValidationPolicy configures HandoffValidator. Zero limits are not enforced.
+type ValidationPolicy struct {
+	MinSummaryLength int                              `json:"min_summary_length"`
+	MaxSummaryLength int                              `json:"max_summary_length"`
+	MinRequirements  int                              `json:"min_requirements"`
+	MaxRequirements  int                              `json:"max_requirements"`
+	MaxNextSteps     int                              `json:"max_next_steps"`
+	MaxTimestampAge  time.Duration                    `json:"max_timestamp_age"`
+	MaxTimestampSkew time.Duration                    `json:"max_timestamp_skew"`
+	AgentNamePattern string                           `json:"agent_name_pattern"`
+	Agents           map[string]AgentValidationPolicy `json:"agents"`
+	Secrets          SecretScanPolicy                 `json:"secrets"`
+	Payload          PayloadLimits                    `json:"payload"`
+}
+
+// DefaultValidationPolicy returns the limits and agent field specs HandoffValidator
+// has always enforced
+func DefaultValidationPolicy() ValidationPolicy {
+	percent := FieldSpec{Type: FieldTypeNumber, Min: float64Ptr(0), Max: float64Ptr(100)}
+	list := FieldSpec{Type: FieldTypeArray}
+	stringList := FieldSpec{Type: FieldTypeArray, Items: FieldTypeString}
+
+	return ValidationPolicy{
+		MinSummaryLength: 10,
+		MaxSummaryLength: 1000,
+		MinRequirements:  1,
+		MaxRequirements:  50,
+		MaxNextSteps:     20,
+		MaxTimestampAge:  24 * time.Hour,
+		MaxTimestampSkew: time.Hour,
+		AgentNamePattern: `^[a-z0-9-]+$`,
+		Agents: map[string]AgentValidationPolicy{
+			"golang-expert": {TechnicalDetails: map[string]FieldSpec{
+				"handlers":      stringList,
+				"services":      stringList,
+				"models":        stringList,
+				"repositories":  stringList,
+				"test_coverage": percent,
+			}},
+			"typescript-expert": {TechnicalDetails: map[string]FieldSpec{
+				"components": list,
+				"hooks":      list,
+			}},
+			"api-expert": {TechnicalDetails: map[string]FieldSpec{
+				"endpoints": list,
+				"schemas":   list,
+			}},
+			"test-expert": {TechnicalDetails: map[string]FieldSpec{
+				"test_suites":       list,
+				"coverage_achieved": percent,
+			}},
+			"devops-expert": {TechnicalDetails: map[string]FieldSpec{
+				"deployments":    list,
+				"configurations": list,
+			}},
+		},
+		Secrets: DefaultSecretScanPolicy(),
+		Payload: DefaultPayloadLimits(),
+	}
+}
+
+// LoadValidationPolicy reads a JSON policy file. Settings missing from the file
+// keep their DefaultValidationPolicy values, and agents listed in the file
+// replace the default spec for that agent.
+func LoadValidationPolicy(filename string) (ValidationPolicy, error) {
+	policy := DefaultValidationPolicy()
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return policy, fmt.Errorf("failed to read validation policy: %w", err)
+	}
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return policy, fmt.Errorf("failed to parse validation policy %s: %w", filename, err)
+	}
+	if err := policy.Validate(); err != nil {
+		return policy, fmt.Errorf("invalid validation policy %s: %w", filename, err)
+	}
+	return policy, nil
+}
+
+// Validate checks the policy itself for mistakes
+func (p ValidationPolicy) Validate() error {
+	if p.MaxSummaryLength > 0 && p.MinSummaryLength > p.MaxSummaryLength {
+		return fmt.Errorf("min_summary_length %d exceeds max_summary_length %d", p.MinSummaryLength, p.MaxSummaryLength)
+	}
+	if p.MaxRequirements > 0 && p.MinRequirements > p.MaxRequirements {
+		return fmt.Errorf("min_requirements %d exceeds max_requirements %d", p.MinRequirements, p.MaxRequirements)
+	}
+	if p.MaxTimestampAge < 0 || p.MaxTimestampSkew < 0 {
+		return fmt.Errorf("timestamp window cannot be negative")
+	}
+	if p.AgentNamePattern != "" {
+		if _, err := regexp.Compile(p.AgentNamePattern); err != nil {
+			return fmt.Errorf("invalid agent_name_pattern: %w", err)
+		}
+	}
+
+	if _, err := newSecretScanner(p.Secrets); err != nil {
+		return fmt.Errorf("secrets: %w", err)
+	}
+	if err := p.Payload.Validate(); err != nil {
+		return fmt.Errorf("payload: %w", err)
+	}
+
+	for _, agent := range sortedKeys(p.Agents) {
+		details := p.Agents[agent].TechnicalDetails
+		for _, field := range sortedKeys(details) {
+			if err := details[field].validate(); err != nil {
+				return fmt.Errorf("agent %s field %s: %w", agent, field, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (s FieldSpec) validate() error {
+	if !s.Type.IsValid() {
+		return fmt.Errorf("unknown type %q", s.Type)
+	}
+	if s.Items != "" {
+		if s.Type != FieldTypeArray {
+			return fmt.Errorf("items is only valid for arrays")
+		}
+		if !s.Items.IsValid() {
+			return fmt.Errorf("unknown items type %q", s.Items)
+		}
+	}
+	if s.Min != nil && s.Max != nil && *s.Min > *s.Max {
+		return fmt.Errorf("min %v exceeds max %v", *s.Min, *s.Max)
+	}
+	if (s.Min != nil || s.Max != nil) && (s.Type == FieldTypeBoolean || s.Type == FieldTypeObject) {
+		return fmt.Errorf("min and max do not apply to %s fields", s.Type)
+	}
+	for _, value := range s.Enum {
+		if !matchesFieldType(value, s.Type) {
+			return fmt.Errorf("enum value %v is not a %s", value, s.Type)
+		}
+	}
+	return nil
+}
+
+// check reports violations of the spec by a field value; label names the field in messages
+func (s FieldSpec) check(report *HandoffValidationReport, path, label string, value interface{}) {
+	if !matchesFieldType(value, s.Type) {
+		report.add(path, ValidationInvalidType, "%s should be %s %s", label, articleFor(s.Type), s.Type)
+		return
+	}
+
+	if s.Items != "" {
+		for i, item := range arrayItems(value) {
+			if !matchesFieldType(item, s.Items) {
+				report.add(indexPath(path, i), ValidationInvalidType,
+					"%s item %d should be %s %s", label, i, articleFor(s.Items), s.Items)
+			}
+		}
+	}
+
+	if len(s.Enum) > 0 && !enumContains(s.Enum, value) {
+		report.add(path, ValidationNotInEnum, "%s must be one of %v", label, s.Enum)
+	}
+
+	if s.Min == nil && s.Max == nil {
+		return
+	}
+
+	measure, unit := 0.0, ""
+	switch s.Type {
+	case FieldTypeString:
+		measure, unit = float64(len(value.(string))), " characters"
+	case FieldTypeArray:
+		measure, unit = float64(len(arrayItems(value))), " items"
+	default:
+		measure, _ = toFloat64(value)
+	}
+	switch {
+	case s.Min != nil && s.Max != nil && (measure < *s.Min || measure > *s.Max):
+		report.add(path, ValidationOutOfRange, "%s must be between %v and %v%s", label, *s.Min, *s.Max, unit)
+	case s.Min != nil && measure < *s.Min:
+		report.add(path, ValidationOutOfRange, "%s must be at least %v%s", label, *s.Min, unit)
+	case s.Max != nil && measure > *s.Max:
+		report.add(path, ValidationOutOfRange, "%s must be at most %v%s", label, *s.Max, unit)
+	}
+}
+
+// matchesFieldType reports whether a decoded JSON (or Go) value has the given type
+func matchesFieldType(value interface{}, fieldType FieldType) bool {
+	switch fieldType {
+	case FieldTypeString:
+		_, ok := value.(string)
+		return ok
+	case FieldTypeNumber:
+		_, ok := toFloat64(value)
+		return ok
+	case FieldTypeInteger:
+		num, ok := toFloat64(value)
+		return ok && num == math.Trunc(num)
+	case FieldTypeBoolean:
+		_, ok := value.(bool)
+		return ok
+	case FieldTypeArray:
+		switch value.(type) {
+		case []interface{}, []string:
+			return true
+		}
+		return false
+	case FieldTypeObject:
+		_, ok := value.(map[string]interface{})
+		return ok
+	default:
+		return false
+	}
+}
+
+func arrayItems(value interface{}) []interface{} {
+	switch v := value.(type) {
+	case []interface{}:
+		return v
+	case []string:
+		items := make([]interface{}, len(v))
+		for i, item := range v {
+			items[i] = item
+		}
+		return items
+	default:
+		return nil
+	}
+}
+
+func enumContains(enum []interface{}, value interface{}) bool {
+	num, isNumber := toFloat64(value)
+	for _, allowed := range enum {
+		if allowedNum, ok := toFloat64(allowed); ok && isNumber {
+			if allowedNum == num {
+				return true
+			}
+			continue
+		}
+		if stringOf(allowed) == stringOf(value) {
+			return true
+		}
+	}
+	return false
+}
+
+func articleFor(fieldType FieldType) string {
+	switch fieldType {
+	case FieldTypeArray, FieldTypeInteger, FieldTypeObject:
+		return "an"
+	default:
+		return "a"
+	}
+}
+
+func float64Ptr(v float64) *float64 {
+	return &v
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
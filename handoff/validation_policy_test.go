@@ -0,0 +1,166 @@
+package handoff
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newPolicyTestHandoff(toAgent string, details map[string]interface{}) *Handoff {
+	return &Handoff{
+		Metadata: Metadata{
+			FromAgent:   "api-expert",
+			ToAgent:     toAgent,
+			Timestamp:   time.Now(),
+			TaskContext: "invoices",
+			Priority:    PriorityNormal,
+		},
+		Content: Content{
+			Summary:          "Implement invoice endpoints",
+			Requirements:     []string{"REST API"},
+			TechnicalDetails: details,
+		},
+	}
+}
+
+func TestDefaultPolicyKeepsAgentFieldChecks(t *testing.T) {
+	validator := NewHandoffValidator()
+
+	tests := []struct {
+		name    string
+		toAgent string
+		details map[string]interface{}
+		wantErr string
+	}{
+		{"valid golang fields", "golang-expert", map[string]interface{}{"handlers": []interface{}{"user.go"}, "test_coverage": 85.0}, ""},
+		{"non-string handler", "golang-expert", map[string]interface{}{"handlers": []interface{}{42.0}}, "golang-expert field handlers item 0 should be a string"},
+		{"coverage out of range", "golang-expert", map[string]interface{}{"test_coverage": 120.0}, "test_coverage must be between 0 and 100"},
+		{"coverage not a number", "test-expert", map[string]interface{}{"coverage_achieved": "high"}, "coverage_achieved should be a number"},
+		{"components not an array", "typescript-expert", map[string]interface{}{"components": "Button"}, "components should be an array"},
+		{"unknown agent", "rust-expert", map[string]interface{}{"anything": 1}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validator.ValidateAgentSpecificFields(newPolicyTestHandoff(tt.toAgent, tt.details))
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadValidationPolicy(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "policy.json")
+	config := `{
+		"max_summary_length": 40,
+		"max_timestamp_age": 3600000000000,
+		"agent_name_pattern": "^[a-z][a-z0-9-]*$",
+		"agents": {
+			"rust-expert": {
+				"technical_details": {
+					"edition": {"type": "string", "required": true, "enum": ["2018", "2021"]},
+					"crates": {"type": "array", "items": "string", "max": 2},
+					"msrv_minor": {"type": "integer", "min": 56}
+				}
+			}
+		}
+	}`
+	if err := os.WriteFile(file, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := LoadValidationPolicy(file)
+	if err != nil {
+		t.Fatalf("LoadValidationPolicy failed: %v", err)
+	}
+	if policy.MaxSummaryLength != 40 || policy.MaxRequirements != 50 {
+		t.Errorf("expected file settings over defaults, got %+v", policy)
+	}
+	if _, exists := policy.Agents["golang-expert"]; !exists {
+		t.Error("expected default agent policies to be kept")
+	}
+
+	validator, err := NewHandoffValidatorWithPolicy(policy)
+	if err != nil {
+		t.Fatalf("NewHandoffValidatorWithPolicy failed: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(h *Handoff)
+		wantErr string
+	}{
+		{"valid", func(h *Handoff) {}, ""},
+		{"missing required field", func(h *Handoff) { delete(h.Content.TechnicalDetails, "edition") }, "edition is required"},
+		{"value outside enum", func(h *Handoff) { h.Content.TechnicalDetails["edition"] = "2015" }, "must be one of"},
+		{"too many items", func(h *Handoff) { h.Content.TechnicalDetails["crates"] = []interface{}{"a", "b", "c"} }, "at most 2 items"},
+		{"not an integer", func(h *Handoff) { h.Content.TechnicalDetails["msrv_minor"] = 60.5 }, "should be an integer"},
+		{"below minimum", func(h *Handoff) { h.Content.TechnicalDetails["msrv_minor"] = 40.0 }, "at least 56"},
+		{"summary too long", func(h *Handoff) { h.Content.Summary = strings.Repeat("x", 41) }, "summary too long (max 40 characters)"},
+		{"timestamp too old", func(h *Handoff) { h.Metadata.Timestamp = time.Now().Add(-2 * time.Hour) }, "more than 1h0m0s in the past"},
+		{"agent name pattern", func(h *Handoff) { h.Metadata.FromAgent = "1-expert" }, "does not match agent name pattern"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := newPolicyTestHandoff("rust-expert", map[string]interface{}{
+				"edition":    "2021",
+				"crates":     []interface{}{"serde"},
+				"msrv_minor": 70.0,
+			})
+			tt.modify(h)
+
+			err := validator.ValidatePolicy(h)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("expected no error, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidationPolicyRejectsInvalidPolicies(t *testing.T) {
+	tests := []struct {
+		name    string
+		modify  func(p *ValidationPolicy)
+		wantErr string
+	}{
+		{"bad pattern", func(p *ValidationPolicy) { p.AgentNamePattern = "[" }, "agent_name_pattern"},
+		{"inverted limits", func(p *ValidationPolicy) { p.MinRequirements = 60 }, "min_requirements"},
+		{"unknown type", func(p *ValidationPolicy) {
+			p.Agents["rust-expert"] = AgentValidationPolicy{TechnicalDetails: map[string]FieldSpec{"edition": {Type: "text"}}}
+		}, `unknown type "text"`},
+		{"enum of wrong type", func(p *ValidationPolicy) {
+			p.Agents["rust-expert"] = AgentValidationPolicy{TechnicalDetails: map[string]FieldSpec{"edition": {Type: FieldTypeNumber, Enum: []interface{}{"2021"}}}}
+		}, "is not a number"},
+		{"range on boolean", func(p *ValidationPolicy) {
+			p.Agents["rust-expert"] = AgentValidationPolicy{TechnicalDetails: map[string]FieldSpec{"nightly": {Type: FieldTypeBoolean, Min: float64Ptr(0)}}}
+		}, "do not apply"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := DefaultValidationPolicy()
+			tt.modify(&policy)
+
+			_, err := NewHandoffValidatorWithPolicy(policy)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package handoff
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ValidationCode identifies the kind of problem found in a handoff
+type ValidationCode string
+
+const (
+	ValidationRequired           ValidationCode = "required"
+	ValidationEmpty              ValidationCode = "empty"
+	ValidationSameAgent          ValidationCode = "same_agent"
+	ValidationUnknownAgent       ValidationCode = "unknown_agent"
+	ValidationInvalidFormat      ValidationCode = "invalid_format"
+	ValidationInvalidValue       ValidationCode = "invalid_value"
+	ValidationInvalidType        ValidationCode = "invalid_type"
+	ValidationNotInEnum          ValidationCode = "not_in_enum"
+	ValidationOutOfRange         ValidationCode = "out_of_range"
+	ValidationTooShort           ValidationCode = "too_short"
+	ValidationTooLong            ValidationCode = "too_long"
+	ValidationTooFew             ValidationCode = "too_few"
+	ValidationTooMany            ValidationCode = "too_many"
+	ValidationDuplicate          ValidationCode = "duplicate"
+	ValidationUnsupportedVersion ValidationCode = "unsupported_version"
+	ValidationUnknownField       ValidationCode = "unknown_field"
+	ValidationSecret             ValidationCode = "secret"
+	ValidationPathEscape         ValidationCode = "path_escape"
+	ValidationNotFound           ValidationCode = "not_found"
+	ValidationTooLarge           ValidationCode = "too_large"
+)
+
+// ValidationViolation describes one problem with a handoff. Path is the JSON
+// path of the offending field, such as content.artifacts.created[3].
+type ValidationViolation struct {
+	Path     string         `json:"path"`
+	Code     ValidationCode `json:"code"`
+	Severity Severity       `json:"severity"`
+	Message  string         `json:"message"`
+}
+
+// String formats the violation for log output
+func (v ValidationViolation) String() string {
+	return fmt.Sprintf("%s [%s] %s: %s", v.Severity, v.Code, v.Path, v.Message)
+}
+
+// HandoffValidationReport collects every violation found in a handoff
+type HandoffValidationReport struct {
+	Violations []ValidationViolation `json:"violations"`
+}
+
+// HasErrors reports whether any violation has error severity
+func (r *HandoffValidationReport) HasErrors() bool {
+	return len(r.Errors()) > 0
+}
+
+// Errors returns the violations with error severity
+func (r *HandoffValidationReport) Errors() []ValidationViolation {
+	return r.filter(SeverityError)
+}
+
+// Warnings returns the violations with warning severity
+func (r *HandoffValidationReport) Warnings() []ValidationViolation {
+	return r.filter(SeverityWarning)
+}
+
+// Err returns a *ValidationError carrying the report, or nil if there are no errors
+func (r *HandoffValidationReport) Err() error {
+	if !r.HasErrors() {
+		return nil
+	}
+	return &ValidationError{Report: r}
+}
+
+func (r *HandoffValidationReport) filter(severity Severity) []ValidationViolation {
+	var violations []ValidationViolation
+	for _, violation := range r.Violations {
+		if violation.Severity == severity {
+			violations = append(violations, violation)
+		}
+	}
+	return violations
+}
+
+func (r *HandoffValidationReport) add(path string, code ValidationCode, format string, args ...interface{}) {
+	r.addWithSeverity(path, code, SeverityError, format, args...)
+}
+
+func (r *HandoffValidationReport) warn(path string, code ValidationCode, format string, args ...interface{}) {
+	r.addWithSeverity(path, code, SeverityWarning, format, args...)
+}
+
+func (r *HandoffValidationReport) addWithSeverity(path string, code ValidationCode, severity Severity, format string, args ...interface{}) {
+	r.Violations = append(r.Violations, ValidationViolation{
+		Path:     path,
+		Code:     code,
+		Severity: severity,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// ValidationError is returned when a handoff has error-severity violations.
+// Use errors.As to get at the full report.
+type ValidationError struct {
+	Report *HandoffValidationReport
+}
+
+func (e *ValidationError) Error() string {
+	errs := e.Report.Errors()
+	messages := make([]string, len(errs))
+	for i, violation := range errs {
+		messages[i] = violation.Path + ": " + violation.Message
+	}
+	if len(errs) == 1 {
+		return "validation failed: " + messages[0]
+	}
+	return fmt.Sprintf("validation failed (%d errors): %s", len(errs), strings.Join(messages, "; "))
+}
+
+// indexPath formats the JSON path of a list element
+func indexPath(path string, index int) string {
+	return fmt.Sprintf("%s[%d]", path, index)
+}
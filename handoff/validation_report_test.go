@@ -0,0 +1,76 @@
+package handoff
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestCheckReportsEveryViolation(t *testing.T) {
+	h := &Handoff{
+		Metadata: Metadata{FromAgent: "api-expert", ToAgent: "golang-expert", Priority: "asap"},
+		Content: Content{
+			Summary:      "Implement invoice endpoints",
+			Requirements: []string{"REST API", " "},
+			Artifacts: Artifacts{
+				Created:  []string{"api/invoices.go", "api/spaces are bad.go"},
+				Modified: []string{"api/invoices.go"},
+			},
+			TechnicalDetails: map[string]interface{}{"test_coverage": 120.0, "notes": "extra"},
+		},
+		Validation: Validation{Checksum: "not-a-checksum"},
+	}
+
+	validator := NewHandoffValidator()
+	report := validator.Check(h)
+	report.Violations = append(report.Violations, validator.CheckPolicy(h).Violations...)
+
+	got := make(map[string]ValidationCode)
+	for _, violation := range report.Violations {
+		got[violation.Path] = violation.Code
+	}
+	expected := map[string]ValidationCode{
+		"metadata.task_context":                   ValidationRequired,
+		"metadata.priority":                       ValidationInvalidValue,
+		"content.requirements[1]":                 ValidationEmpty,
+		"content.artifacts.created[1]":            ValidationInvalidFormat,
+		"content.artifacts.modified[0]":           ValidationDuplicate,
+		"validation.checksum":                     ValidationInvalidFormat,
+		"content.technical_details.test_coverage": ValidationOutOfRange,
+		"content.technical_details.notes":         ValidationUnknownField,
+	}
+	for path, code := range expected {
+		if got[path] != code {
+			t.Errorf("expected %s at %s, got %q", code, path, got[path])
+		}
+	}
+
+	warnings := report.Warnings()
+	if len(warnings) != 1 || warnings[0].Path != "content.technical_details.notes" {
+		t.Errorf("expected only the undeclared field as a warning, got %v", warnings)
+	}
+}
+
+func TestValidationErrorCarriesReport(t *testing.T) {
+	report := &HandoffValidationReport{}
+	report.warn("content.technical_details.notes", ValidationUnknownField, "undeclared")
+	if report.Err() != nil {
+		t.Fatal("warnings alone should not fail validation")
+	}
+
+	report.add("content.summary", ValidationRequired, "summary is required")
+	report.add("metadata.to_agent", ValidationRequired, "to_agent is required")
+
+	err := fmt.Errorf("invalid handoff: %w", report.Err())
+	var validationErr *ValidationError
+	if !errors.As(err, &validationErr) {
+		t.Fatalf("expected a *ValidationError, got %T", err)
+	}
+	if len(validationErr.Report.Violations) != 3 {
+		t.Errorf("expected the full report, got %v", validationErr.Report.Violations)
+	}
+	if !strings.Contains(err.Error(), "validation failed (2 errors): content.summary: summary is required; metadata.to_agent") {
+		t.Errorf("unexpected message: %v", err)
+	}
+}
@@ -1,30 +1,62 @@
 package handoff
 
 import (
-	"fmt"
 	"regexp"
 	"strings"
 	"time"
 )
 
+// supportedSchemaVersions lists the schema versions the validator accepts
+var supportedSchemaVersions = []string{"1.0", "1.1"}
+
+var (
+	checksumPattern     = regexp.MustCompile(`^[a-f0-9]{64}$`)
+	artifactPathPattern = regexp.MustCompile(`^[a-zA-Z0-9/_.-]+$`)
+)
+
 // HandoffValidator provides validation for handoffs
 type HandoffValidator struct {
-	knownAgents     map[string]bool
-	schemaVersion   string
-	maxSummaryLen   int
-	maxRequirements int
-	maxNextSteps    int
+	knownAgents   map[string]bool
+	schemaVersion string
+	policy        ValidationPolicy
+	agentName     *regexp.Regexp
+	secrets       *secretScanner
 }
 
-// NewHandoffValidator creates a new validator instance
+// NewHandoffValidator creates a new validator instance using DefaultValidationPolicy
 func NewHandoffValidator() *HandoffValidator {
-	return &HandoffValidator{
-		knownAgents:     make(map[string]bool),
-		schemaVersion:   "1.0",
-		maxSummaryLen:   1000,
-		maxRequirements: 50,
-		maxNextSteps:    20,
+	validator, err := NewHandoffValidatorWithPolicy(DefaultValidationPolicy())
+	if err != nil {
+		panic(err)
+	}
+	return validator
+}
+
+// NewHandoffValidatorWithPolicy creates a validator enforcing the given policy
+func NewHandoffValidatorWithPolicy(policy ValidationPolicy) (*HandoffValidator, error) {
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+
+	v := &HandoffValidator{
+		knownAgents:   make(map[string]bool),
+		schemaVersion: "1.0",
+		policy:        policy,
+	}
+	if policy.AgentNamePattern != "" {
+		v.agentName = regexp.MustCompile(policy.AgentNamePattern)
 	}
+	secrets, err := newSecretScanner(policy.Secrets)
+	if err != nil {
+		return nil, err
+	}
+	v.secrets = secrets
+	return v, nil
+}
+
+// Policy returns the policy the validator enforces
+func (v *HandoffValidator) Policy() ValidationPolicy {
+	return v.policy
 }
 
 // RegisterAgent registers an agent for validation
@@ -32,58 +64,65 @@ func (v *HandoffValidator) RegisterAgent(agentName string) {
 	v.knownAgents[agentName] = true
 }
 
-// ValidateHandoff performs comprehensive handoff validation
+// ValidateHandoff performs comprehensive handoff validation. The returned error
+// is a *ValidationError listing every violation.
 func (v *HandoffValidator) ValidateHandoff(handoff *Handoff) error {
-	if err := v.validateMetadata(&handoff.Metadata); err != nil {
-		return fmt.Errorf("metadata validation failed: %w", err)
-	}
-
-	if err := v.validateContent(&handoff.Content); err != nil {
-		return fmt.Errorf("content validation failed: %w", err)
-	}
+	return v.Check(handoff).Err()
+}
 
-	if err := v.validateValidation(&handoff.Validation); err != nil {
-		return fmt.Errorf("validation section failed: %w", err)
-	}
+// Check performs comprehensive handoff validation and reports every violation,
+// including warnings. Missing defaults (timestamp, priority, schema version)
+// are filled in as before.
+func (v *HandoffValidator) Check(handoff *Handoff) *HandoffValidationReport {
+	report := &HandoffValidationReport{}
+	v.validateMetadata(report, &handoff.Metadata)
+	v.validateContent(report, &handoff.Content)
+	v.validateValidation(report, &handoff.Validation)
+	v.validateArtifacts(report, &handoff.Content.Artifacts)
+	return report
+}
 
-	if err := v.validateArtifacts(&handoff.Content.Artifacts); err != nil {
-		return fmt.Errorf("artifacts validation failed: %w", err)
-	}
+// ValidatePolicy checks only the configurable rules: agent names, the timestamp
+// window, summary, requirement and next step limits, and the receiving agent's
+// technical_details specs. It lets services with their own structural checks
+// share the policy.
+func (v *HandoffValidator) ValidatePolicy(handoff *Handoff) error {
+	return v.CheckPolicy(handoff).Err()
+}
 
-	return nil
+// CheckPolicy is ValidatePolicy returning the full report, including warnings
+func (v *HandoffValidator) CheckPolicy(handoff *Handoff) *HandoffValidationReport {
+	report := &HandoffValidationReport{}
+	v.validateAgentNames(report, &handoff.Metadata)
+	v.validateTimestamp(report, handoff.Metadata.Timestamp)
+	v.validateContentLimits(report, &handoff.Content)
+	v.checkAgentSpecificFields(report, handoff)
+	return report
 }
 
 // validateMetadata validates the metadata section
-func (v *HandoffValidator) validateMetadata(metadata *Metadata) error {
+func (v *HandoffValidator) validateMetadata(report *HandoffValidationReport, metadata *Metadata) {
 	if metadata.FromAgent == "" {
-		return fmt.Errorf("from_agent is required")
+		report.add("metadata.from_agent", ValidationRequired, "from_agent is required")
 	}
 
 	if metadata.ToAgent == "" {
-		return fmt.Errorf("to_agent is required")
+		report.add("metadata.to_agent", ValidationRequired, "to_agent is required")
 	}
 
-	if metadata.FromAgent == metadata.ToAgent {
-		return fmt.Errorf("from_agent and to_agent cannot be the same")
+	if metadata.FromAgent != "" && metadata.FromAgent == metadata.ToAgent {
+		report.add("metadata.to_agent", ValidationSameAgent, "from_agent and to_agent cannot be the same")
 	}
 
-	// Validate agent names format (lowercase, alphanumeric with dashes)
-	agentNameRegex := regexp.MustCompile(`^[a-z0-9-]+$`)
-	if !agentNameRegex.MatchString(metadata.FromAgent) {
-		return fmt.Errorf("from_agent must be lowercase alphanumeric with dashes only")
-	}
-
-	if !agentNameRegex.MatchString(metadata.ToAgent) {
-		return fmt.Errorf("to_agent must be lowercase alphanumeric with dashes only")
-	}
+	v.validateAgentNames(report, metadata)
 
 	// Check if agents are registered (optional check)
 	if len(v.knownAgents) > 0 {
-		if !v.knownAgents[metadata.FromAgent] {
-			return fmt.Errorf("from_agent %s is not registered", metadata.FromAgent)
+		if metadata.FromAgent != "" && !v.knownAgents[metadata.FromAgent] {
+			report.add("metadata.from_agent", ValidationUnknownAgent, "from_agent %s is not registered", metadata.FromAgent)
 		}
-		if !v.knownAgents[metadata.ToAgent] {
-			return fmt.Errorf("to_agent %s is not registered", metadata.ToAgent)
+		if metadata.ToAgent != "" && !v.knownAgents[metadata.ToAgent] {
+			report.add("metadata.to_agent", ValidationUnknownAgent, "to_agent %s is not registered", metadata.ToAgent)
 		}
 	}
 
@@ -91,17 +130,10 @@ func (v *HandoffValidator) validateMetadata(metadata *Metadata) error {
 		metadata.Timestamp = time.Now()
 	}
 
-	// Validate timestamp is not too far in the future or past
-	now := time.Now()
-	if metadata.Timestamp.After(now.Add(time.Hour)) {
-		return fmt.Errorf("timestamp cannot be more than 1 hour in the future")
-	}
-	if metadata.Timestamp.Before(now.Add(-24 * time.Hour)) {
-		return fmt.Errorf("timestamp cannot be more than 24 hours in the past")
-	}
+	v.validateTimestamp(report, metadata.Timestamp)
 
 	if metadata.TaskContext == "" {
-		return fmt.Errorf("task_context is required")
+		report.add("metadata.task_context", ValidationRequired, "task_context is required")
 	}
 
 	// Validate priority
@@ -111,50 +143,89 @@ func (v *HandoffValidator) validateMetadata(metadata *Metadata) error {
 	case "":
 		metadata.Priority = PriorityNormal // Default
 	default:
-		return fmt.Errorf("invalid priority: %s", metadata.Priority)
+		report.add("metadata.priority", ValidationInvalidValue, "invalid priority: %s", metadata.Priority)
 	}
+}
 
-	return nil
+// validateAgentNames checks agent names against the policy's agent name pattern
+func (v *HandoffValidator) validateAgentNames(report *HandoffValidationReport, metadata *Metadata) {
+	if v.agentName == nil {
+		return
+	}
+	if metadata.FromAgent != "" && !v.agentName.MatchString(metadata.FromAgent) {
+		report.add("metadata.from_agent", ValidationInvalidFormat,
+			"from_agent %q does not match agent name pattern %s", metadata.FromAgent, v.agentName)
+	}
+	if metadata.ToAgent != "" && !v.agentName.MatchString(metadata.ToAgent) {
+		report.add("metadata.to_agent", ValidationInvalidFormat,
+			"to_agent %q does not match agent name pattern %s", metadata.ToAgent, v.agentName)
+	}
 }
 
-// validateContent validates the content section
-func (v *HandoffValidator) validateContent(content *Content) error {
-	if content.Summary == "" {
-		return fmt.Errorf("summary is required")
+// validateTimestamp checks the timestamp is not too far in the future or past
+func (v *HandoffValidator) validateTimestamp(report *HandoffValidationReport, timestamp time.Time) {
+	if timestamp.IsZero() {
+		return
+	}
+
+	now := time.Now()
+	if v.policy.MaxTimestampSkew > 0 && timestamp.After(now.Add(v.policy.MaxTimestampSkew)) {
+		report.add("metadata.timestamp", ValidationOutOfRange,
+			"timestamp cannot be more than %s in the future", v.policy.MaxTimestampSkew)
+	}
+	if v.policy.MaxTimestampAge > 0 && timestamp.Before(now.Add(-v.policy.MaxTimestampAge)) {
+		report.add("metadata.timestamp", ValidationOutOfRange,
+			"timestamp cannot be more than %s in the past", v.policy.MaxTimestampAge)
 	}
+}
 
-	if len(content.Summary) > v.maxSummaryLen {
-		return fmt.Errorf("summary too long (max %d characters)", v.maxSummaryLen)
+// validateContentLimits checks summary length and list sizes against the policy
+func (v *HandoffValidator) validateContentLimits(report *HandoffValidationReport, content *Content) {
+	if max := v.policy.MaxSummaryLength; max > 0 && len(content.Summary) > max {
+		report.add("content.summary", ValidationTooLong, "summary too long (max %d characters)", max)
 	}
 
 	// Summary should be descriptive
-	if len(strings.TrimSpace(content.Summary)) < 10 {
-		return fmt.Errorf("summary too short (minimum 10 characters)")
+	if min := v.policy.MinSummaryLength; min > 0 && content.Summary != "" && len(strings.TrimSpace(content.Summary)) < min {
+		report.add("content.summary", ValidationTooShort, "summary too short (minimum %d characters)", min)
 	}
 
-	if len(content.Requirements) == 0 {
-		return fmt.Errorf("at least one requirement is needed")
+	if min := v.policy.MinRequirements; len(content.Requirements) < min {
+		if min == 1 {
+			report.add("content.requirements", ValidationTooFew, "at least one requirement is needed")
+		} else {
+			report.add("content.requirements", ValidationTooFew, "at least %d requirements are needed", min)
+		}
 	}
 
-	if len(content.Requirements) > v.maxRequirements {
-		return fmt.Errorf("too many requirements (max %d)", v.maxRequirements)
+	if max := v.policy.MaxRequirements; max > 0 && len(content.Requirements) > max {
+		report.add("content.requirements", ValidationTooMany, "too many requirements (max %d)", max)
 	}
 
+	if max := v.policy.MaxNextSteps; max > 0 && len(content.NextSteps) > max {
+		report.add("content.next_steps", ValidationTooMany, "too many next steps (max %d)", max)
+	}
+}
+
+// validateContent validates the content section
+func (v *HandoffValidator) validateContent(report *HandoffValidationReport, content *Content) {
+	if content.Summary == "" {
+		report.add("content.summary", ValidationRequired, "summary is required")
+	}
+
+	v.validateContentLimits(report, content)
+
 	// Validate requirements are not empty
 	for i, req := range content.Requirements {
 		if strings.TrimSpace(req) == "" {
-			return fmt.Errorf("requirement %d cannot be empty", i+1)
+			report.add(indexPath("content.requirements", i), ValidationEmpty, "requirement %d cannot be empty", i+1)
 		}
 	}
 
-	if len(content.NextSteps) > v.maxNextSteps {
-		return fmt.Errorf("too many next steps (max %d)", v.maxNextSteps)
-	}
-
 	// Validate next steps are not empty
 	for i, step := range content.NextSteps {
 		if strings.TrimSpace(step) == "" {
-			return fmt.Errorf("next step %d cannot be empty", i+1)
+			report.add(indexPath("content.next_steps", i), ValidationEmpty, "next step %d cannot be empty", i+1)
 		}
 	}
 
@@ -162,20 +233,17 @@ func (v *HandoffValidator) validateContent(content *Content) error {
 	if content.TechnicalDetails == nil {
 		content.TechnicalDetails = make(map[string]interface{})
 	}
-
-	return nil
 }
 
 // validateValidation validates the validation section
-func (v *HandoffValidator) validateValidation(validation *Validation) error {
+func (v *HandoffValidator) validateValidation(report *HandoffValidationReport, validation *Validation) {
 	if validation.SchemaVersion == "" {
 		validation.SchemaVersion = v.schemaVersion
 	}
 
 	// Check supported schema versions
-	supportedVersions := []string{"1.0", "1.1"}
 	versionSupported := false
-	for _, version := range supportedVersions {
+	for _, version := range supportedSchemaVersions {
 		if validation.SchemaVersion == version {
 			versionSupported = true
 			break
@@ -183,202 +251,107 @@ func (v *HandoffValidator) validateValidation(validation *Validation) error {
 	}
 
 	if !versionSupported {
-		return fmt.Errorf("unsupported schema version: %s (supported: %v)",
-			validation.SchemaVersion, supportedVersions)
+		report.add("validation.schema_version", ValidationUnsupportedVersion,
+			"unsupported schema version: %s (supported: %v)", validation.SchemaVersion, supportedSchemaVersions)
 	}
 
 	if validation.Checksum == "" {
-		return fmt.Errorf("checksum is required")
+		report.add("validation.checksum", ValidationRequired, "checksum is required")
+		return
 	}
 
 	// Checksum should be hex string (64 characters for SHA256)
-	checksumRegex := regexp.MustCompile(`^[a-f0-9]{64}$`)
-	if !checksumRegex.MatchString(validation.Checksum) {
-		return fmt.Errorf("checksum must be a valid 64-character hex string")
+	if !checksumPattern.MatchString(validation.Checksum) {
+		report.add("validation.checksum", ValidationInvalidFormat, "checksum must be a valid 64-character hex string")
 	}
-
-	return nil
 }
 
 // validateArtifacts validates the artifacts section
-func (v *HandoffValidator) validateArtifacts(artifacts *Artifacts) error {
-	// Validate file paths
-	pathRegex := regexp.MustCompile(`^[a-zA-Z0-9/_.-]+$`)
-
-	for _, path := range artifacts.Created {
-		if !pathRegex.MatchString(path) {
-			return fmt.Errorf("invalid file path in created artifacts: %s", path)
-		}
-	}
-
-	for _, path := range artifacts.Modified {
-		if !pathRegex.MatchString(path) {
-			return fmt.Errorf("invalid file path in modified artifacts: %s", path)
-		}
-	}
-
-	for _, path := range artifacts.Reviewed {
-		if !pathRegex.MatchString(path) {
-			return fmt.Errorf("invalid file path in reviewed artifacts: %s", path)
-		}
-	}
-
-	// Check for duplicates across categories
-	allPaths := make(map[string]string)
-
-	for _, path := range artifacts.Created {
-		if category, exists := allPaths[path]; exists {
-			return fmt.Errorf("duplicate artifact path %s in %s and created", path, category)
-		}
-		allPaths[path] = "created"
-	}
-
-	for _, path := range artifacts.Modified {
-		if category, exists := allPaths[path]; exists {
-			return fmt.Errorf("duplicate artifact path %s in %s and modified", path, category)
-		}
-		allPaths[path] = "modified"
-	}
-
-	for _, path := range artifacts.Reviewed {
-		if category, exists := allPaths[path]; exists {
-			return fmt.Errorf("duplicate artifact path %s in %s and reviewed", path, category)
-		}
-		allPaths[path] = "reviewed"
-	}
-
-	return nil
-}
-
-// ValidateAgentSpecificFields validates technical_details based on agent type
-func (v *HandoffValidator) ValidateAgentSpecificFields(handoff *Handoff) error {
-	toAgent := handoff.Metadata.ToAgent
-	techDetails := handoff.Content.TechnicalDetails
-
-	switch toAgent {
-	case "golang-expert":
-		return v.validateGolangFields(techDetails)
-	case "typescript-expert":
-		return v.validateTypescriptFields(techDetails)
-	case "api-expert":
-		return v.validateAPIFields(techDetails)
-	case "test-expert":
-		return v.validateTestFields(techDetails)
-	case "devops-expert":
-		return v.validateDevOpsFields(techDetails)
-	default:
-		// No specific validation for unknown agents
-		return nil
-	}
-}
-
-// validateGolangFields validates golang-expert specific fields
-func (v *HandoffValidator) validateGolangFields(details map[string]interface{}) error {
-	expectedFields := []string{"handlers", "services", "models", "repositories"}
-
-	for _, field := range expectedFields {
-		if val, exists := details[field]; exists {
-			// Should be a string array
-			if arr, ok := val.([]interface{}); ok {
-				for _, item := range arr {
-					if _, ok := item.(string); !ok {
-						return fmt.Errorf("golang field %s should contain string values", field)
-					}
-				}
-			} else {
-				return fmt.Errorf("golang field %s should be an array", field)
+func (v *HandoffValidator) validateArtifacts(report *HandoffValidationReport, artifacts *Artifacts) {
+	categories := []struct {
+		name  string
+		paths []string
+	}{
+		{"created", artifacts.Created},
+		{"modified", artifacts.Modified},
+		{"reviewed", artifacts.Reviewed},
+	}
+
+	// Validate file paths and check for duplicates across categories
+	seen := make(map[string]string)
+	for _, category := range categories {
+		for i, path := range category.paths {
+			fieldPath := indexPath("content.artifacts."+category.name, i)
+			if !artifactPathPattern.MatchString(path) {
+				report.add(fieldPath, ValidationInvalidFormat,
+					"invalid file path in %s artifacts: %s", category.name, path)
 			}
-		}
-	}
-
-	// Validate test coverage if present
-	if coverage, exists := details["test_coverage"]; exists {
-		if coverageNum, ok := coverage.(float64); ok {
-			if coverageNum < 0 || coverageNum > 100 {
-				return fmt.Errorf("test_coverage must be between 0 and 100")
+			if previous, exists := seen[path]; exists {
+				report.add(fieldPath, ValidationDuplicate,
+					"duplicate artifact path %s in %s and %s", path, previous, category.name)
+				continue
 			}
-		} else {
-			return fmt.Errorf("test_coverage must be a number")
-		}
-	}
-
-	return nil
-}
-
-// validateTypescriptFields validates typescript-expert specific fields
-func (v *HandoffValidator) validateTypescriptFields(details map[string]interface{}) error {
-	if components, exists := details["components"]; exists {
-		if _, ok := components.([]interface{}); !ok {
-			return fmt.Errorf("typescript components field should be an array")
-		}
-	}
-
-	if hooks, exists := details["hooks"]; exists {
-		if _, ok := hooks.([]interface{}); !ok {
-			return fmt.Errorf("typescript hooks field should be an array")
+			seen[path] = category.name
 		}
 	}
-
-	return nil
 }
 
-// validateAPIFields validates api-expert specific fields
-func (v *HandoffValidator) validateAPIFields(details map[string]interface{}) error {
-	if endpoints, exists := details["endpoints"]; exists {
-		if _, ok := endpoints.([]interface{}); !ok {
-			return fmt.Errorf("api endpoints field should be an array")
-		}
-	}
-
-	if schemas, exists := details["schemas"]; exists {
-		if _, ok := schemas.([]interface{}); !ok {
-			return fmt.Errorf("api schemas field should be an array")
-		}
-	}
-
-	return nil
+// ValidateAgentSpecificFields validates technical_details against the receiving
+// agent's field specs; agents without a policy are not checked
+func (v *HandoffValidator) ValidateAgentSpecificFields(handoff *Handoff) error {
+	report := &HandoffValidationReport{}
+	v.checkAgentSpecificFields(report, handoff)
+	return report.Err()
 }
 
-// validateTestFields validates test-expert specific fields
-func (v *HandoffValidator) validateTestFields(details map[string]interface{}) error {
-	if testSuites, exists := details["test_suites"]; exists {
-		if _, ok := testSuites.([]interface{}); !ok {
-			return fmt.Errorf("test_suites field should be an array")
+// checkAgentSpecificFields reports technical_details fields that break the
+// receiving agent's specs, and warns about fields the specs do not declare
+func (v *HandoffValidator) checkAgentSpecificFields(report *HandoffValidationReport, handoff *Handoff) {
+	agent := handoff.Metadata.ToAgent
+	agentPolicy, exists := v.policy.Agents[agent]
+	if !exists {
+		return
+	}
+
+	details := handoff.Content.TechnicalDetails
+	for _, field := range sortedKeys(agentPolicy.TechnicalDetails) {
+		spec := agentPolicy.TechnicalDetails[field]
+		path := "content.technical_details." + field
+		value, exists := details[field]
+		if _, offloaded := handoff.Content.Offloaded[field]; offloaded && !exists {
+			// Checked before it was offloaded
+			continue
 		}
-	}
-
-	if coverage, exists := details["coverage_achieved"]; exists {
-		if coverageNum, ok := coverage.(float64); ok {
-			if coverageNum < 0 || coverageNum > 100 {
-				return fmt.Errorf("coverage_achieved must be between 0 and 100")
+		if !exists {
+			if spec.Required {
+				report.add(path, ValidationRequired, "%s field %s is required", agent, field)
 			}
-		} else {
-			return fmt.Errorf("coverage_achieved must be a number")
+			continue
 		}
+		spec.check(report, path, agent+" field "+field, value)
 	}
 
-	return nil
-}
-
-// validateDevOpsFields validates devops-expert specific fields
-func (v *HandoffValidator) validateDevOpsFields(details map[string]interface{}) error {
-	if deployments, exists := details["deployments"]; exists {
-		if _, ok := deployments.([]interface{}); !ok {
-			return fmt.Errorf("deployments field should be an array")
-		}
-	}
-
-	if configs, exists := details["configurations"]; exists {
-		if _, ok := configs.([]interface{}); !ok {
-			return fmt.Errorf("configurations field should be an array")
+	for _, field := range sortedKeys(details) {
+		if _, declared := agentPolicy.TechnicalDetails[field]; !declared {
+			report.warn("content.technical_details."+field, ValidationUnknownField,
+				"%s does not declare technical_details field %s", agent, field)
 		}
 	}
+}
 
-	return nil
+// ScanSecrets scans the handoff's free-text fields for credentials and applies
+// the policy's secrets action: findings are reported as errors (reject) or
+// warnings (warn, redact), and with redact they are replaced in place
+func (v *HandoffValidator) ScanSecrets(handoff *Handoff) *HandoffValidationReport {
+	report := &HandoffValidationReport{}
+	v.secrets.scanHandoff(report, handoff)
+	return report
 }
 
-// SanitizeHandoff sanitizes and normalizes handoff data
-func (v *HandoffValidator) SanitizeHandoff(handoff *Handoff) {
+// SanitizeHandoff sanitizes and normalizes handoff data and scans it for
+// secrets. Call it before the handoff is stored; the report has errors when
+// the secrets policy rejects the handoff.
+func (v *HandoffValidator) SanitizeHandoff(handoff *Handoff) *HandoffValidationReport {
 	// Trim whitespace from strings
 	handoff.Metadata.FromAgent = strings.TrimSpace(handoff.Metadata.FromAgent)
 	handoff.Metadata.ToAgent = strings.TrimSpace(handoff.Metadata.ToAgent)
@@ -403,6 +376,8 @@ func (v *HandoffValidator) SanitizeHandoff(handoff *Handoff) {
 	handoff.Content.Artifacts.Created = normalizePaths(handoff.Content.Artifacts.Created)
 	handoff.Content.Artifacts.Modified = normalizePaths(handoff.Content.Artifacts.Modified)
 	handoff.Content.Artifacts.Reviewed = normalizePaths(handoff.Content.Artifacts.Reviewed)
+
+	return v.ScanSecrets(handoff)
 }
 
 // removeEmptyStrings removes empty strings from a slice